@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers that carry solved proof-of-work challenge of anonymous basket creation
+const (
+	ChallengeHeader      = "X-PoW-Challenge"
+	ChallengeNonceHeader = "X-PoW-Nonce"
+)
+
+const (
+	creationLimitWindow = time.Hour
+	challengeTTL        = 5 * time.Minute
+	maxChallengeBits    = 32
+)
+
+// creationLimiter limits number of baskets and user accounts created by anonymous clients of every IP address,
+// nil if not limited
+var creationLimiter *rateLimiter
+
+// challenges issues proof-of-work challenges to anonymous clients that create baskets, nil if not required
+var challenges *challengeIssuer
+
+// Challenge describes proof-of-work challenge: a client must find nonce, so that SHA-256 hash of
+// "<challenge>:<nonce>" starts with the number of zero bits defined by difficulty.
+type Challenge struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+	Expires    int64  `json:"expires"`
+}
+
+// challengeIssuer issues signed stateless challenges and remembers solved challenges until they expire,
+// so every challenge allows to create a single basket
+type challengeIssuer struct {
+	sync.Mutex
+	secret     []byte
+	difficulty int
+	solved     map[string]time.Time // expiration of solved challenges
+}
+
+// newChallengeIssuer creates issuer of challenges with difficulty in bits, challenges are signed with random secret
+func newChallengeIssuer(difficulty int) (*challengeIssuer, error) {
+	if difficulty < 1 || difficulty > maxChallengeBits {
+		return nil, fmt.Errorf("difficulty of proof-of-work challenge should be between 1 and %d bits", maxChallengeBits)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret of challenges: %s", err)
+	}
+	return &challengeIssuer{secret: secret, difficulty: difficulty, solved: make(map[string]time.Time)}, nil
+}
+
+// Issue issues a new challenge
+func (c *challengeIssuer) Issue(now time.Time) (Challenge, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return Challenge{}, err
+	}
+
+	expires := now.Add(challengeTTL).UnixNano() / toMs
+	payload := strconv.FormatInt(expires, 10) + "." + base64.RawURLEncoding.EncodeToString(random)
+	return Challenge{Challenge: payload + "." + c.sign(payload), Difficulty: c.difficulty, Expires: expires}, nil
+}
+
+// Verify verifies solution of challenge and marks the challenge solved
+func (c *challengeIssuer) Verify(challenge string, nonce string, now time.Time) error {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[2]), []byte(c.sign(parts[0]+"."+parts[1]))) {
+		return fmt.Errorf("invalid challenge")
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.UnixNano()/toMs > expires {
+		return fmt.Errorf("challenge is expired")
+	}
+	if len(nonce) == 0 || len(nonce) > 64 {
+		return fmt.Errorf("nonce of challenge is required")
+	}
+	if hash := sha256.Sum256([]byte(challenge + ":" + nonce)); leadingZeroBits(hash[:]) < c.difficulty {
+		return fmt.Errorf("challenge is not solved")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for solved, expiration := range c.solved {
+		if now.After(expiration) {
+			delete(c.solved, solved)
+		}
+	}
+	if _, exists := c.solved[challenge]; exists {
+		return fmt.Errorf("challenge is already used")
+	}
+	c.solved[challenge] = time.Unix(0, expires*toMs)
+	return nil
+}
+
+func (c *challengeIssuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// leadingZeroBits counts zero bits at the beginning of hash
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+// checkAnonymousCreation applies anti-abuse controls to anonymous clients that create baskets or sign up:
+// the rate limit of their IP address and the proof-of-work challenge; writes HTTP response and returns false
+// if creation is not allowed
+func checkAnonymousCreation(w http.ResponseWriter, r *http.Request) bool {
+	if creationLimiter != nil {
+		client := "ip:" + r.RemoteAddr
+		if ip := remoteIP(r); ip != nil {
+			client = "ip:" + ip.String()
+		}
+		if allowed, _, reset := creationLimiter.Allow(client, time.Now()); !allowed {
+			seconds := strconv.Itoa(int((reset + time.Second - 1) / time.Second))
+			w.Header().Set("Retry-After", seconds)
+			httpError(w, "too many baskets or accounts are created from this address, retry in "+seconds+" seconds",
+				http.StatusTooManyRequests)
+			return false
+		}
+	}
+
+	if challenges != nil {
+		if err := challenges.Verify(r.Header.Get(ChallengeHeader), r.Header.Get(ChallengeNonceHeader), time.Now()); err != nil {
+			writeError(w, http.StatusPreconditionRequired, ErrorChallengeRequired,
+				"proof-of-work challenge of "+serviceAPIPath+"/challenge is required: "+err.Error(), nil)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// solveChallenge finds nonce of proof-of-work challenge
+func solveChallenge(c Challenge) string {
+	for nonce := 0; ; nonce++ {
+		hash := sha256.Sum256([]byte(c.Challenge + ":" + strconv.Itoa(nonce)))
+		if leadingZeroBits(hash[:]) >= c.Difficulty {
+			return strconv.Itoa(nonce)
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	assert.Equal(t, 0, leadingZeroBits([]byte{0x80, 0}), "wrong number of zero bits")
+	assert.Equal(t, 7, leadingZeroBits([]byte{0x01, 0}), "wrong number of zero bits")
+	assert.Equal(t, 12, leadingZeroBits([]byte{0, 0x08}), "wrong number of zero bits")
+	assert.Equal(t, 16, leadingZeroBits([]byte{0, 0}), "wrong number of zero bits")
+}
+
+func TestNewChallengeIssuer(t *testing.T) {
+	_, err := newChallengeIssuer(0)
+	assert.Error(t, err, "difficulty is expected")
+	_, err = newChallengeIssuer(maxChallengeBits + 1)
+	assert.Error(t, err, "difficulty is expected to be limited")
+}
+
+func TestChallengeIssuer_Verify(t *testing.T) {
+	issuer, err := newChallengeIssuer(8)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	c, err := issuer.Issue(now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 8, c.Difficulty, "wrong difficulty")
+		assert.Equal(t, now.Add(challengeTTL).UnixNano()/toMs, c.Expires, "wrong expiration")
+
+		nonce := solveChallenge(c)
+		assert.EqualError(t, issuer.Verify(c.Challenge, "", now), "nonce of challenge is required")
+		assert.EqualError(t, issuer.Verify(c.Challenge+"x", nonce, now), "invalid challenge")
+		assert.EqualError(t, issuer.Verify(c.Challenge, nonce, now.Add(challengeTTL+time.Second)), "challenge is expired")
+		assert.NoError(t, issuer.Verify(c.Challenge, nonce, now))
+		assert.EqualError(t, issuer.Verify(c.Challenge, nonce, now), "challenge is already used")
+	}
+
+	// challenge of other issuer
+	other, _ := newChallengeIssuer(8)
+	c, _ = other.Issue(now)
+	assert.EqualError(t, issuer.Verify(c.Challenge, solveChallenge(c), now), "invalid challenge")
+
+	// solved challenges are forgotten once expired
+	c, _ = issuer.Issue(now)
+	assert.NoError(t, issuer.Verify(c.Challenge, solveChallenge(c), now))
+	c, _ = issuer.Issue(now.Add(challengeTTL + time.Second))
+	assert.NoError(t, issuer.Verify(c.Challenge, solveChallenge(c), now.Add(challengeTTL+time.Second)))
+	assert.Len(t, issuer.solved, 1, "expired challenges are not expected")
+}
+
+func TestAnonymousCreation(t *testing.T) {
+	call := func(method string, path string, addr string, token string, challenge http.Header) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(""))
+		r.RemoteAddr = addr
+		r.Header.Add("Authorization", token)
+		for name, values := range challenge {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// rate limit of anonymous clients
+	creationLimiter = newRateLimiter(2, creationLimitWindow)
+	assert.Equal(t, 201, call("POST", "/baskets/abuse01", "10.3.0.1:43210", "", nil).Code, "wrong HTTP result code")
+	assert.Equal(t, 201, call("POST", "/users/abuse01", "10.3.0.1:43210", "", nil).Code, "wrong HTTP result code")
+	w := call("POST", "/baskets/abuse02", "10.3.0.1:43210", "", nil)
+	if assert.Equal(t, 429, w.Code, "wrong HTTP result code") {
+		assert.NotEmpty(t, w.Header().Get("Retry-After"), "Retry-After header is expected")
+		assert.Contains(t, w.Body.String(), `"code":"rate_limited"`, "wrong error code")
+	}
+	assert.Equal(t, 201, call("POST", "/baskets/abuse02", "10.3.0.2:43210", "", nil).Code, "other client is expected")
+	assert.Equal(t, 201, call("POST", "/baskets/abuse03", "10.3.0.1:43210", serverConfig.MasterToken, nil).Code,
+		"master token is not expected to be limited")
+	creationLimiter = nil
+
+	// proof-of-work challenge
+	assert.Equal(t, 404, call("GET", "/challenge", "10.3.0.1:43210", "", nil).Code, "challenge is not expected")
+	challenges, _ = newChallengeIssuer(4)
+	defer func() { challenges = nil }()
+
+	w = call("POST", "/baskets/abuse04", "10.3.0.1:43210", "", nil)
+	if assert.Equal(t, 428, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"code":"challenge_required"`, "wrong error code")
+	}
+
+	w = call("GET", "/challenge", "10.3.0.1:43210", "", nil)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		c := Challenge{}
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &c)) {
+			solution := http.Header{}
+			solution.Set(ChallengeHeader, c.Challenge)
+			solution.Set(ChallengeNonceHeader, solveChallenge(c))
+			assert.Equal(t, 201, call("POST", "/baskets/abuse04", "10.3.0.1:43210", "", solution).Code,
+				"solved challenge is expected")
+			assert.Equal(t, 428, call("POST", "/baskets/abuse05", "10.3.0.1:43210", "", solution).Code,
+				"challenge is not expected to be reused")
+		}
+	}
+	assert.Equal(t, 201, call("POST", "/baskets/abuse05", "10.3.0.1:43210", serverConfig.MasterToken, nil).Code,
+		"master token is not expected to solve challenge")
+}
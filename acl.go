@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Access rights of basket ACL entries
+const (
+	AccessView   = "view"   // the same access as read scope of access tokens
+	AccessManage = "manage" // the same access as the basket token
+)
+
+const maxACLEntries = 50
+
+// ACLEntry grants access to basket to user account or to all users of a group, so teammates collaborate
+// on the same basket with their own user tokens.
+type ACLEntry struct {
+	User   string `json:"user,omitempty"`
+	Group  string `json:"group,omitempty"`
+	Access string `json:"access"`
+}
+
+// Matches checks if entry applies to the user
+func (entry ACLEntry) Matches(user *User) bool {
+	if len(entry.User) > 0 {
+		return entry.User == user.Name
+	}
+	return user.IsMember(entry.Group)
+}
+
+// validateACL validates entries of basket ACL
+func validateACL(entries []ACLEntry) error {
+	if len(entries) > maxACLEntries {
+		return fmt.Errorf("number of ACL entries may not be greater than %d", maxACLEntries)
+	}
+
+	for _, entry := range entries {
+		if len(entry.User) > 0 == (len(entry.Group) > 0) {
+			return fmt.Errorf("ACL entry requires either user or group")
+		}
+		if !validUserName.MatchString(entry.User + entry.Group) {
+			return fmt.Errorf("invalid ACL entry; user or group does not match pattern: %s", userNamePattern)
+		}
+		if entry.Access != AccessView && entry.Access != AccessManage {
+			return fmt.Errorf("unknown access of ACL entry: %s", entry.Access)
+		}
+	}
+
+	return nil
+}
+
+// authorizeACL checks if token belongs to user that is granted access to basket by its ACL: manage access
+// grants any scope, view access grants read scope only; empty scope stands for full access to basket
+func authorizeACL(basket Basket, token string, scope string) bool {
+	entries := basket.GetACL()
+	if len(entries) == 0 {
+		return false
+	}
+	user := users.Authenticate(token)
+	if user == nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if entry.Matches(user) && (entry.Access == AccessManage || (entry.Access == AccessView && scope == ScopeRead)) {
+			return true
+		}
+	}
+	return false
+}
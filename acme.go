@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeManager obtains and renews TLS certificates of the service from ACME provider (e.g. Let's Encrypt),
+// nil if certificates are not managed by the service
+var acmeManager *autocert.Manager
+
+// newACMEManager creates manager of TLS certificates of the configured domains, certificates are obtained
+// on the first TLS handshake of a domain, kept in cache directory and renewed before they expire
+func newACMEManager(config *ServerConfig) (*autocert.Manager, error) {
+	if len(config.TLSCert) > 0 || len(config.TLSKey) > 0 {
+		return nil, fmt.Errorf("ACME certificates may not be combined with TLS certificate and key of the service")
+	}
+	if len(config.ACMECache) == 0 {
+		return nil, fmt.Errorf("cache directory of ACME certificates is expected")
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.ACMEDomains...),
+		Cache:      autocert.DirCache(config.ACMECache),
+		Email:      config.ACMEEmail}
+	if len(config.ACMEDirectory) > 0 {
+		manager.Client = &acme.Client{DirectoryURL: config.ACMEDirectory}
+	}
+	return manager, nil
+}
+
+// acmeTLSConfig returns TLS configuration of the service with certificates of ACME provider, the configuration
+// answers TLS-ALPN-01 challenges and verifies client certificates if they are required
+func acmeTLSConfig(manager *autocert.Manager, certs *clientCertAuthenticator) *tls.Config {
+	config := manager.TLSConfig()
+	if certs != nil {
+		clientConfig := certs.TLSConfig()
+		config.ClientCAs = clientConfig.ClientCAs
+		config.ClientAuth = clientConfig.ClientAuth
+	}
+	return config
+}
+
+// serveACMEChallenges answers HTTP-01 challenges of ACME provider and redirects other plain HTTP requests
+// to HTTPS; the listener is optional, since TLS-ALPN-01 challenges are answered by the service itself
+func serveACMEChallenges(manager *autocert.Manager, addr string) {
+	log.Printf("[info] ACME challenge server is listening on %s", addr)
+	if err := http.ListenAndServe(addr, manager.HTTPHandler(nil)); err != nil {
+		log.Printf("[error] failed to serve ACME challenges: %s", err)
+	}
+}
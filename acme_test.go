@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewACMEManager(t *testing.T) {
+	manager, err := newACMEManager(&ServerConfig{ACMEDomains: []string{"rb.example.com"}, ACMECache: "./acme",
+		ACMEEmail: "admin@example.com", ACMEDirectory: "https://acme-staging-v02.api.letsencrypt.org/directory"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "admin@example.com", manager.Email, "wrong contact email")
+		assert.Equal(t, "https://acme-staging-v02.api.letsencrypt.org/directory", manager.Client.DirectoryURL,
+			"wrong directory URL")
+		assert.NoError(t, manager.HostPolicy(context.Background(), "rb.example.com"), "configured domain is expected")
+		assert.Error(t, manager.HostPolicy(context.Background(), "other.example.com"), "other domain is not expected")
+	}
+
+	_, err = newACMEManager(&ServerConfig{ACMEDomains: []string{"rb.example.com"}, ACMECache: "./acme",
+		TLSCert: "cert.pem", TLSKey: "key.pem"})
+	assert.Error(t, err, "TLS certificate of service is not expected")
+	_, err = newACMEManager(&ServerConfig{ACMEDomains: []string{"rb.example.com"}})
+	assert.Error(t, err, "cache directory is expected")
+}
+
+func TestACMETLSConfig(t *testing.T) {
+	manager, err := newACMEManager(&ServerConfig{ACMEDomains: []string{"rb.example.com"}, ACMECache: "./acme"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := acmeTLSConfig(manager, nil)
+	assert.NotNil(t, config.GetCertificate, "certificates are expected to be obtained from ACME provider")
+	assert.Contains(t, config.NextProtos, "acme-tls/1", "TLS-ALPN-01 challenges are expected to be answered")
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth, "client certificates are not expected")
+
+	// client certificates are verified along with certificates of ACME provider
+	ca, _ := testCertificate(t, "Test CA", nil, nil, nil)
+	certsConfig := testClientCertConfig(t, ca, "*=viewer")
+	defer os.Remove(certsConfig.ClientCA)
+	certsConfig.TLSCert, certsConfig.TLSKey = "", ""
+	certsConfig.ACMEDomains = []string{"rb.example.com"}
+	certs, err := newClientCertAuthenticator(certsConfig)
+	if assert.NoError(t, err, "ACME certificates are expected to serve client certificates") {
+		config = acmeTLSConfig(manager, certs)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth, "client certificates are expected")
+		assert.NotNil(t, config.ClientCAs, "client CAs are expected")
+		assert.NotNil(t, config.GetCertificate, "certificates are expected to be obtained from ACME provider")
+	}
+}
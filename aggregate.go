@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Supported groupings of collected requests
+const (
+	AggregateByPath   = "path"
+	AggregateByMethod = "method"
+	AggregateByStatus = "status"
+	AggregateByHour   = "hour"
+)
+
+// aggregateUnknownKey is a group key of requests without recorded response status
+const aggregateUnknownKey = "unknown"
+
+// statsIntervals defines intervals of request rate in basket statistics
+var statsIntervals = map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+
+// timelineRanges defines ranges of traffic timeline along with sizes of their buckets
+var timelineRanges = map[string]struct{ period, bucket time.Duration }{
+	"hour": {time.Hour, time.Minute},
+	"day":  {24 * time.Hour, time.Hour},
+	"week": {7 * 24 * time.Hour, time.Hour}}
+
+// errTimelineComplete stops reading of requests that are older than the timeline
+var errTimelineComplete = errors.New("timeline is complete")
+
+// RequestsGroup describes number of collected requests within a group.
+type RequestsGroup struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// RequestsAggregation describes collected requests of a basket grouped by a request attribute.
+type RequestsAggregation struct {
+	By     string           `json:"by"`
+	Count  int              `json:"count"`
+	Groups []*RequestsGroup `json:"groups"`
+}
+
+// BasketRequestsStats describes statistics of requests collected by a basket.
+type BasketRequestsStats struct {
+	Count             int              `json:"count"`
+	FirstDate         int64            `json:"first_date,omitempty"` // date of the oldest request in milliseconds
+	LastDate          int64            `json:"last_date,omitempty"`  // date of the latest request in milliseconds
+	Interval          string           `json:"interval"`
+	Rate              []*RequestsGroup `json:"rate"` // requests per interval ordered by time, empty intervals are omitted
+	Methods           []*RequestsGroup `json:"methods"`
+	Statuses          []*RequestsGroup `json:"statuses"`
+	AvgBodySize       float64          `json:"avg_body_size"` // bytes
+	Forwarded         int              `json:"forwarded"`
+	ForwardErrors     int              `json:"forward_errors"`
+	ForwardErrorRate  float64          `json:"forward_error_rate"`
+	AvgForwardLatency float64          `json:"avg_forward_latency"` // milliseconds, forwards without response are skipped
+}
+
+// RequestsTimeline describes numbers of requests collected by a basket within buckets of equal size, e.g.
+// per minute of the last hour, to render activity of the basket without loading its requests.
+type RequestsTimeline struct {
+	Range  string `json:"range"`
+	Start  int64  `json:"start"`  // start of the first bucket in milliseconds
+	Bucket int64  `json:"bucket"` // size of bucket in milliseconds
+	Counts []int  `json:"counts"` // numbers of requests from the oldest to the latest bucket
+	Total  int    `json:"total"`
+}
+
+// requestsGroups counts requests per group key
+type requestsGroups struct {
+	groups []*RequestsGroup
+	index  map[string]*RequestsGroup
+}
+
+func newRequestsGroups() *requestsGroups {
+	return &requestsGroups{groups: make([]*RequestsGroup, 0), index: make(map[string]*RequestsGroup)}
+}
+
+// Add counts request of group
+func (g *requestsGroups) Add(key string) {
+	group, exists := g.index[key]
+	if !exists {
+		group = &RequestsGroup{Key: key}
+		g.index[key] = group
+		g.groups = append(g.groups, group)
+	}
+	group.Count++
+}
+
+// ByKey returns groups ordered by key
+func (g *requestsGroups) ByKey() []*RequestsGroup {
+	sort.Slice(g.groups, func(i, j int) bool { return g.groups[i].Key < g.groups[j].Key })
+	return g.groups
+}
+
+// ByCount returns groups with more requests first
+func (g *requestsGroups) ByCount() []*RequestsGroup {
+	sort.Slice(g.groups, func(i, j int) bool {
+		gi, gj := g.groups[i], g.groups[j]
+		return gi.Count > gj.Count || (gi.Count == gj.Count && gi.Key < gj.Key)
+	})
+	return g.groups
+}
+
+// AggregateRequests counts requests of a basket per group, only requests matching the query are counted if query
+// is not nil; groups are ordered by time if requests are grouped by hour, otherwise groups with more requests go first
+func AggregateRequests(basket Basket, by string, query *RequestsQuery) (*RequestsAggregation, error) {
+	key, err := aggregateKey(by)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregation := &RequestsAggregation{By: by, Groups: make([]*RequestsGroup, 0)}
+	groups := newRequestsGroups()
+	StreamRequests(basket, query, exportPageSize, func(page []*RequestData) error {
+		for _, req := range page {
+			groups.Add(key(req))
+			aggregation.Count++
+		}
+		return nil
+	})
+
+	if by == AggregateByHour {
+		aggregation.Groups = groups.ByKey()
+	} else {
+		aggregation.Groups = groups.ByCount()
+	}
+
+	return aggregation, nil
+}
+
+// aggregateKey returns function that calculates group key of a request
+func aggregateKey(by string) (func(req *RequestData) string, error) {
+	switch by {
+	case AggregateByPath:
+		return func(req *RequestData) string { return relativePath(req.Path) }, nil
+	case AggregateByMethod:
+		return func(req *RequestData) string { return req.Method }, nil
+	case AggregateByStatus:
+		return func(req *RequestData) string {
+			if req.ResponseStatus == 0 {
+				return aggregateUnknownKey
+			}
+			return strconv.Itoa(req.ResponseStatus)
+		}, nil
+	case AggregateByHour:
+		return func(req *RequestData) string {
+			return time.Unix(0, req.Date*toMs).UTC().Truncate(time.Hour).Format(time.RFC3339)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown aggregation: %s", by)
+	}
+}
+
+// CollectBasketStats calculates statistics of requests collected by basket: request rate per interval (minute, hour
+// or day), breakdowns by method and response status, average body size and forwarding results; only requests
+// matching the query are counted if query is not nil
+func CollectBasketStats(basket Basket, interval string, query *RequestsQuery) (*BasketRequestsStats, error) {
+	if len(interval) == 0 {
+		interval = "hour"
+	}
+	period, ok := statsIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown interval: %s, expected minute, hour or day", interval)
+	}
+
+	method, _ := aggregateKey(AggregateByMethod)
+	status, _ := aggregateKey(AggregateByStatus)
+	rate, methods, statuses := newRequestsGroups(), newRequestsGroups(), newRequestsGroups()
+	stats := &BasketRequestsStats{Interval: interval}
+	var bodySize, latency, responded int64
+	err := StreamRequests(basket, query, exportPageSize, func(page []*RequestData) error {
+		for _, req := range page {
+			stats.Count++
+			if stats.LastDate == 0 || req.Date > stats.LastDate {
+				stats.LastDate = req.Date
+			}
+			if stats.FirstDate == 0 || req.Date < stats.FirstDate {
+				stats.FirstDate = req.Date
+			}
+			rate.Add(time.Unix(0, req.Date*toMs).UTC().Truncate(period).Format(time.RFC3339))
+			methods.Add(method(req))
+			statuses.Add(status(req))
+
+			// size of body as received, stored body may be truncated or encrypted
+			if req.ContentLength >= 0 {
+				bodySize += req.ContentLength
+			} else {
+				bodySize += int64(len(req.Body))
+			}
+
+			if req.ForwardStatus > 0 || len(req.ForwardError) > 0 {
+				stats.Forwarded++
+				if req.ForwardStatus > 0 {
+					latency += req.ForwardLatency
+					responded++
+				}
+				if len(req.ForwardError) > 0 || req.ForwardStatus >= 500 {
+					stats.ForwardErrors++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Rate, stats.Methods, stats.Statuses = rate.ByKey(), methods.ByCount(), statuses.ByCount()
+	if stats.Count > 0 {
+		stats.AvgBodySize = float64(bodySize) / float64(stats.Count)
+	}
+	if stats.Forwarded > 0 {
+		stats.ForwardErrorRate = float64(stats.ForwardErrors) / float64(stats.Forwarded)
+	}
+	if responded > 0 {
+		stats.AvgForwardLatency = float64(latency) / float64(responded)
+	}
+	return stats, nil
+}
+
+// CollectBasketTimeline counts requests collected by basket within the last hour (per minute), day or week (per hour)
+// before given time, the latest bucket includes given time; requests are read from the latest until the first
+// request older than the timeline
+func CollectBasketTimeline(basket Basket, span string, now time.Time) (*RequestsTimeline, error) {
+	if len(span) == 0 {
+		span = "day"
+	}
+	r, ok := timelineRanges[span]
+	if !ok {
+		return nil, fmt.Errorf("unknown range: %s, expected hour, day or week", span)
+	}
+
+	bucket := r.bucket.Nanoseconds() / toMs
+	end := now.UTC().Truncate(r.bucket).Add(r.bucket).UnixNano() / toMs
+	start := end - r.period.Nanoseconds()/toMs
+	timeline := &RequestsTimeline{Range: span, Start: start, Bucket: bucket, Counts: make([]int, r.period/r.bucket)}
+
+	err := StreamRequests(basket, nil, exportPageSize, func(page []*RequestData) error {
+		for _, req := range page {
+			if req.Date < start {
+				return errTimelineComplete
+			}
+			if req.Date < end {
+				timeline.Counts[(req.Date-start)/bucket]++
+				timeline.Total++
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errTimelineComplete {
+		return nil, err
+	}
+	return timeline, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateRequests(t *testing.T) {
+	name := "aggregate01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i := 0; i < 6; i++ {
+		path := "/orders"
+		if i%3 == 0 {
+			path = "/health"
+		}
+		r := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v%v", name, path), "test", "text/plain")
+		if i%2 == 0 {
+			r.Method = "GET"
+		}
+		data := basket.Add(r)
+		if i < 4 {
+			basket.UpdateRequest(data.ID, func(updated *RequestData) { updated.ResponseStatus = 200 + i%2*300 })
+		}
+	}
+
+	groups := func(aggregation *RequestsAggregation) map[string]int {
+		result := make(map[string]int)
+		for _, group := range aggregation.Groups {
+			result[group.Key] = group.Count
+		}
+		return result
+	}
+
+	aggregation, err := AggregateRequests(basket, AggregateByPath, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 6, aggregation.Count, "wrong number of aggregated requests")
+		assert.Equal(t, map[string]int{"/orders": 4, "/health": 2}, groups(aggregation))
+		assert.Equal(t, "/orders", aggregation.Groups[0].Key, "largest group is expected first")
+	}
+
+	aggregation, err = AggregateRequests(basket, AggregateByMethod, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]int{"GET": 3, "POST": 3}, groups(aggregation))
+	}
+
+	aggregation, err = AggregateRequests(basket, AggregateByStatus, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]int{"200": 2, "500": 2, "unknown": 2}, groups(aggregation))
+	}
+
+	aggregation, err = AggregateRequests(basket, AggregateByHour, nil)
+	if assert.NoError(t, err) && assert.Len(t, aggregation.Groups, 1, "wrong number of groups") {
+		hour := time.Now().UTC().Truncate(time.Hour)
+		assert.Equal(t, 6, aggregation.Groups[0].Count, "wrong number of requests")
+		if key, err := time.Parse(time.RFC3339, aggregation.Groups[0].Key); assert.NoError(t, err) {
+			// requests may be collected within previous hour
+			assert.True(t, !key.After(hour) && !key.Before(hour.Add(-time.Hour)), "wrong hour: %v", key)
+		}
+	}
+
+	// aggregate found requests only
+	query := NewTextQuery("", "any")
+	query.Method = "GET"
+	aggregation, err = AggregateRequests(basket, AggregateByPath, query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, aggregation.Count, "wrong number of aggregated requests")
+		assert.Equal(t, map[string]int{"/orders": 2, "/health": 1}, groups(aggregation))
+	}
+
+	_, err = AggregateRequests(basket, "day", nil)
+	assert.Error(t, err)
+}
+
+func TestAggregateRequests_EmptyBasket(t *testing.T) {
+	name := "aggregate02"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	aggregation, err := AggregateRequests(db.Get(name), AggregateByMethod, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, aggregation.Count, "no requests are expected")
+		assert.Empty(t, aggregation.Groups, "no groups are expected")
+	}
+}
+
+func TestCollectBasketStats(t *testing.T) {
+	name := "aggregate03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC).UnixNano() / toMs
+	for i, date := range []int64{hour + 1000, hour + 2000, hour + 3600*1000 + 5000} {
+		data := basket.AddRequest(&RequestData{Date: date, Method: "POST", Path: "/" + name, Body: "abcd",
+			ContentLength: int64(4 + i*2)})
+		basket.UpdateRequest(data.ID, func(updated *RequestData) {
+			updated.ResponseStatus = 200
+			switch i {
+			case 0:
+				updated.ForwardStatus, updated.ForwardLatency = 200, 10
+			case 1:
+				updated.ForwardStatus, updated.ForwardLatency = 502, 30
+			}
+		})
+	}
+	basket.AddRequest(&RequestData{Date: hour + 4000, Method: "GET", Path: "/" + name, ContentLength: -1, Body: "ab"})
+
+	stats, err := CollectBasketStats(basket, "", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 4, stats.Count, "wrong number of requests")
+	assert.Equal(t, hour+1000, stats.FirstDate, "wrong date of the oldest request")
+	assert.Equal(t, hour+3600*1000+5000, stats.LastDate, "wrong date of the latest request")
+	assert.Equal(t, "hour", stats.Interval, "hourly rate is expected by default")
+	if assert.Len(t, stats.Rate, 2, "wrong number of intervals") {
+		assert.Equal(t, RequestsGroup{Key: "2024-03-01T10:00:00Z", Count: 3}, *stats.Rate[0], "wrong rate")
+		assert.Equal(t, RequestsGroup{Key: "2024-03-01T11:00:00Z", Count: 1}, *stats.Rate[1], "wrong rate")
+	}
+	if assert.Len(t, stats.Methods, 2, "wrong number of methods") {
+		assert.Equal(t, RequestsGroup{Key: "POST", Count: 3}, *stats.Methods[0], "wrong method breakdown")
+	}
+	if assert.Len(t, stats.Statuses, 2, "wrong number of statuses") {
+		assert.Equal(t, RequestsGroup{Key: "200", Count: 3}, *stats.Statuses[0], "wrong status breakdown")
+	}
+	assert.Equal(t, float64(4+6+8+2)/4, stats.AvgBodySize, "wrong average body size")
+	assert.Equal(t, 2, stats.Forwarded, "wrong number of forwarded requests")
+	assert.Equal(t, 1, stats.ForwardErrors, "wrong number of forward errors")
+	assert.Equal(t, 0.5, stats.ForwardErrorRate, "wrong forward error rate")
+	assert.Equal(t, float64(20), stats.AvgForwardLatency, "wrong average forward latency")
+
+	stats, err = CollectBasketStats(basket, "day", nil)
+	if assert.NoError(t, err) && assert.Len(t, stats.Rate, 1, "wrong number of intervals") {
+		assert.Equal(t, "2024-03-01T00:00:00Z", stats.Rate[0].Key, "wrong interval")
+	}
+
+	query := NewTextQuery("", "any")
+	query.Method = "GET"
+	stats, err = CollectBasketStats(basket, "minute", query)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, stats.Count, "only found requests are expected")
+		assert.Equal(t, 0, stats.Forwarded, "no forwarded requests are expected")
+	}
+
+	_, err = CollectBasketStats(basket, "week", nil)
+	assert.Error(t, err, "unknown interval is not expected")
+}
+
+func TestCollectBasketTimeline(t *testing.T) {
+	name := "aggregate04"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	now := time.Date(2024, 3, 1, 10, 30, 15, 0, time.UTC)
+	ms := func(d time.Duration) int64 { return now.Add(d).UnixNano() / toMs }
+	for _, date := range []int64{ms(-25 * time.Hour), ms(-90 * time.Minute), ms(-59 * time.Minute), ms(-10 * time.Second),
+		ms(-5 * time.Second)} {
+		basket.AddRequest(&RequestData{Date: date, Method: "GET", Path: "/" + name})
+	}
+
+	timeline, err := CollectBasketTimeline(basket, "hour", now)
+	if assert.NoError(t, err) && assert.Len(t, timeline.Counts, 60, "wrong number of buckets") {
+		assert.Equal(t, int64(60000), timeline.Bucket, "wrong size of bucket")
+		assert.Equal(t, time.Date(2024, 3, 1, 9, 31, 0, 0, time.UTC).UnixNano()/toMs, timeline.Start, "wrong start")
+		assert.Equal(t, 3, timeline.Total, "wrong number of requests")
+		assert.Equal(t, 2, timeline.Counts[59], "latest requests are expected in the last bucket")
+		assert.Equal(t, 1, timeline.Counts[0], "wrong number of requests in the first bucket")
+	}
+
+	timeline, err = CollectBasketTimeline(basket, "", now)
+	if assert.NoError(t, err) && assert.Len(t, timeline.Counts, 24, "wrong number of buckets") {
+		assert.Equal(t, "day", timeline.Range, "day is expected by default")
+		assert.Equal(t, 4, timeline.Total, "wrong number of requests")
+		assert.Equal(t, 2, timeline.Counts[23], "wrong number of requests in the last bucket")
+		assert.Equal(t, 2, timeline.Counts[22], "wrong number of requests in the previous bucket")
+	}
+
+	timeline, err = CollectBasketTimeline(basket, "week", now)
+	if assert.NoError(t, err) {
+		assert.Len(t, timeline.Counts, 168, "wrong number of buckets")
+		assert.Equal(t, 5, timeline.Total, "wrong number of requests")
+	}
+
+	_, err = CollectBasketTimeline(basket, "month", now)
+	assert.Error(t, err, "unknown range is not expected")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// adminNetworks restricts service API and web UI to clients of the networks, nil if not restricted;
+// baskets collect requests of any client regardless of the restriction
+var adminNetworks []*net.IPNet
+
+// parseNetworks parses list of CIDRs, plain IP addresses stand for networks of a single address
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// remoteIP returns IP address of the client that directly sends HTTP request, nil if address is unknown
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// containsIP checks if IP address belongs to any of the networks
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminAllowed rejects HTTP requests to service API and web UI with 403 status unless the client belongs
+// to allowed networks of service administration and presents client certificate if certificates are required
+func adminAllowed(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if adminNetworks != nil && !containsIP(adminNetworks, remoteIP(r)) {
+			httpError(w, "service administration is not allowed from this network", http.StatusForbidden)
+		} else if clientCerts != nil && clientCerts.Certificate(r) == nil {
+			httpError(w, "client certificate is required for service administration", http.StatusForbidden)
+		} else {
+			handler(w, r, ps)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks([]string{"10.1.0.0/16", "192.168.1.10", "fd00::1"})
+	if assert.NoError(t, err) && assert.Len(t, networks, 3) {
+		assert.Equal(t, "10.1.0.0/16", networks[0].String(), "wrong network")
+		assert.Equal(t, "192.168.1.10/32", networks[1].String(), "single address is expected")
+		assert.Equal(t, "fd00::1/128", networks[2].String(), "single address is expected")
+	}
+
+	_, err = parseNetworks([]string{"10.1.0.0/99"})
+	assert.EqualError(t, err, "invalid CIDR: 10.1.0.0/99")
+	_, err = parseNetworks([]string{"example.com"})
+	assert.Error(t, err, "host name is not expected")
+}
+
+func TestContainsIP(t *testing.T) {
+	networks, _ := parseNetworks([]string{"10.1.0.0/16"})
+	assert.True(t, containsIP(networks, net.ParseIP("10.1.2.3")), "address of network is expected")
+	assert.False(t, containsIP(networks, net.ParseIP("10.2.0.1")), "other address is not expected")
+	assert.False(t, containsIP(networks, nil), "unknown address is not expected")
+}
+
+func TestAdminAllowed(t *testing.T) {
+	basket := "allowlist01"
+	_, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { adminNetworks = nil }()
+	adminNetworks, _ = parseNetworks([]string{"10.1.0.0/16"})
+
+	call := func(method string, path string, addr string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(""))
+		r.RemoteAddr = addr
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, path := range []string{"/api/stats", "/api/v2/baskets/" + basket, "/baskets/" + basket, "/web", "/web/" + basket,
+		"/api/openapi.json"} {
+		assert.Equal(t, 200, call("GET", path, "10.1.2.3:43210").Code, "allowed client is expected: %s", path)
+		w := call("GET", path, "203.0.113.5:43210")
+		assert.Equal(t, 403, w.Code, "other client is not expected: %s", path)
+		assert.Contains(t, w.Body.String(), `"code":"forbidden"`, "JSON error is expected: %s", path)
+	}
+
+	// baskets collect requests of any client
+	assert.Equal(t, 200, call("POST", "/"+basket, "203.0.113.5:43210").Code, "request is expected to be collected")
+	if b := basketsDb.Get(basket); assert.NotNil(t, b) {
+		assert.Equal(t, 1, b.Size(), "collected request is expected")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyPrefix = "rbk_" // helps secret scanners to recognize leaked keys
+	maxAPIKeys   = 100
+)
+
+// apiKeyUsageInterval defines how often the last use of API key is recorded, so the keys file is not rewritten
+// on every request
+const apiKeyUsageInterval = time.Minute
+
+var apiKeys *apiKeyDirectory
+
+// APIKey describes named long-lived API key of the service that grants the same access as the master token,
+// the key itself is reported once it is created.
+type APIKey struct {
+	Name     string `json:"name"`
+	Key      string `json:"key,omitempty"`
+	Created  int64  `json:"created"`
+	LastUsed int64  `json:"last_used,omitempty"`
+}
+
+// apiKeyRecord describes stored API key, only the hash of key is stored
+type apiKeyRecord struct {
+	Name     string `json:"name"`
+	Hash     string `json:"hash"`
+	Created  int64  `json:"created"`
+	LastUsed int64  `json:"last_used,omitempty"`
+}
+
+// apiKeyDirectory keeps hashes of API keys, the keys are stored in JSON file if the file is configured,
+// otherwise keys are kept in memory only
+type apiKeyDirectory struct {
+	sync.Mutex
+	file string
+	keys map[string]*apiKeyRecord // by hash of key
+}
+
+// newAPIKeyDirectory creates directory of API keys and loads keys from the file
+func newAPIKeyDirectory(file string) (*apiKeyDirectory, error) {
+	d := &apiKeyDirectory{file: file, keys: make(map[string]*apiKeyRecord)}
+	if len(file) == 0 {
+		return d, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		log.Printf("[info] API keys file is not found, it is created once a key is created: %s", file)
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %s - %s", file, err)
+	}
+
+	records := []*apiKeyRecord{}
+	if err = json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys file: %s - %s", file, err)
+	}
+	for _, record := range records {
+		if !validTokenName.MatchString(record.Name) || len(record.Hash) != 2*sha256.Size {
+			return nil, fmt.Errorf("invalid API key in keys file: %s", record.Name)
+		}
+		d.keys[record.Hash] = record
+	}
+	log.Printf("[info] loaded %d API keys from file: %s", len(records), file)
+
+	return d, nil
+}
+
+// Create creates a new API key with unique name
+func (d *apiKeyDirectory) Create(name string) (APIKey, error) {
+	key := APIKey{Name: name, Created: time.Now().UnixNano() / toMs}
+	value, err := GenerateToken()
+	if err != nil {
+		return key, fmt.Errorf("failed to generate API key: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.keys) >= maxAPIKeys {
+		return key, fmt.Errorf("number of API keys may not be greater than %d", maxAPIKeys)
+	}
+	for _, record := range d.keys {
+		if record.Name == name {
+			return key, fmt.Errorf("API key with name '%s' already exists", name)
+		}
+	}
+
+	key.Key = apiKeyPrefix + value
+	hash := hashAPIKey(key.Key)
+	d.keys[hash] = &apiKeyRecord{Name: name, Hash: hash, Created: key.Created}
+	d.save()
+	return key, nil
+}
+
+// List returns API keys sorted by name without the keys themselves
+func (d *apiKeyDirectory) List() []APIKey {
+	d.Lock()
+	defer d.Unlock()
+
+	list := make([]APIKey, 0, len(d.keys))
+	for _, record := range d.keys {
+		list = append(list, APIKey{Name: record.Name, Created: record.Created, LastUsed: record.LastUsed})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Revoke revokes API key by its name, false is returned if key is not found
+func (d *apiKeyDirectory) Revoke(name string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	for hash, record := range d.keys {
+		if record.Name == name {
+			delete(d.keys, hash)
+			d.save()
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate checks if token is a known API key and records the use of key
+func (d *apiKeyDirectory) Authenticate(token string) bool {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	record, exists := d.keys[hashAPIKey(token)]
+	if !exists {
+		return false
+	}
+
+	now := time.Now().UnixNano() / toMs
+	if now-record.LastUsed >= int64(apiKeyUsageInterval/time.Millisecond) {
+		record.LastUsed = now
+		d.save()
+	}
+	return true
+}
+
+// Lookup returns name of API key without recording the use of key, e.g. to identify the actor of request
+func (d *apiKeyDirectory) Lookup(token string) (string, bool) {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return "", false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if record, exists := d.keys[hashAPIKey(token)]; exists {
+		return record.Name, true
+	}
+	return "", false
+}
+
+// save writes API keys to the file if the file is configured, the file is replaced at once
+func (d *apiKeyDirectory) save() {
+	if len(d.file) == 0 {
+		return
+	}
+
+	records := make([]*apiKeyRecord, 0, len(d.keys))
+	for _, record := range d.keys {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err == nil {
+		if err = ioutil.WriteFile(d.file+".tmp", data, 0600); err == nil {
+			err = os.Rename(d.file+".tmp", d.file)
+		}
+	}
+	if err != nil {
+		log.Printf("[error] failed to save API keys file: %s - %s", d.file, err)
+	}
+}
+
+// hashAPIKey returns hex encoded SHA-256 hash of API key, the keys are random so a fast hash is sufficient
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
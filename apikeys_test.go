@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyDirectory(t *testing.T) {
+	d, err := newAPIKeyDirectory("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	key, err := d.Create("ci01")
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(key.Key, apiKeyPrefix), "key is expected to have prefix")
+		assert.NotZero(t, key.Created, "time of creation is expected")
+	}
+	_, err = d.Create("ci01")
+	assert.EqualError(t, err, "API key with name 'ci01' already exists")
+
+	// keys are only stored as hashes
+	for hash := range d.keys {
+		assert.Equal(t, hashAPIKey(key.Key), hash, "hash of key is expected")
+		assert.NotContains(t, hash, key.Key[len(apiKeyPrefix):], "key is not expected to be stored")
+	}
+
+	assert.True(t, d.Authenticate(key.Key), "key is expected to be accepted")
+	assert.False(t, d.Authenticate(key.Key[len(apiKeyPrefix):]), "key without prefix is not expected")
+	assert.False(t, d.Authenticate(apiKeyPrefix+"wrong"), "unknown key is not expected")
+	assert.False(t, d.Authenticate(""), "empty key is not expected")
+
+	if list := d.List(); assert.Len(t, list, 1) {
+		assert.Equal(t, "ci01", list[0].Name, "wrong key name")
+		assert.Empty(t, list[0].Key, "key is not expected to be reported")
+		assert.NotZero(t, list[0].LastUsed, "time of last use is expected")
+	}
+
+	assert.False(t, d.Revoke("ci02"), "unknown key is not expected to be revoked")
+	assert.True(t, d.Revoke("ci01"))
+	assert.False(t, d.Authenticate(key.Key), "revoked key is not expected")
+}
+
+func TestAPIKeyDirectory_File(t *testing.T) {
+	file := "apikeys01.json"
+	defer os.Remove(file)
+
+	d, err := newAPIKeyDirectory(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, _ := d.Create("ci01")
+	d.Authenticate(key.Key)
+
+	data, _ := ioutil.ReadFile(file)
+	assert.NotContains(t, string(data), key.Key, "key is not expected in file")
+	records := []apiKeyRecord{}
+	if assert.NoError(t, json.Unmarshal(data, &records)) && assert.Len(t, records, 1) {
+		assert.NotZero(t, records[0].LastUsed, "time of last use is expected to be stored")
+	}
+
+	restored, err := newAPIKeyDirectory(file)
+	if assert.NoError(t, err) {
+		assert.True(t, restored.Authenticate(key.Key), "key is expected to be restored")
+	}
+
+	ioutil.WriteFile(file, []byte("{"), 0600)
+	_, err = newAPIKeyDirectory(file)
+	assert.Error(t, err, "invalid file is not expected")
+	ioutil.WriteFile(file, []byte(`[{"name": "ci01", "hash": "abc"}]`), 0600)
+	_, err = newAPIKeyDirectory(file)
+	assert.Error(t, err, "invalid hash is not expected")
+}
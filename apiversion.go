@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Versions of service API, version 1 is served at the API root and version 2 at its "v2" sub-path
+const (
+	apiV1 = 1
+	apiV2 = 2
+)
+
+type apiVersionKey struct{}
+
+// RequestDataV2 describes collected request in API v2, results of handling the request are grouped by their origin.
+type RequestDataV2 struct {
+	ID             int            `json:"id"`
+	Date           int64          `json:"date"`
+	Header         http.Header    `json:"headers"`
+	ContentLength  int64          `json:"content_length"`
+	Body           string         `json:"body"`
+	Method         string         `json:"method"`
+	Path           string         `json:"path"`
+	Query          string         `json:"query"`
+	ResponseStatus int            `json:"response_status"`
+	Forward        *ForwardResult `json:"forward,omitempty"` // absent if request is not forwarded yet
+	Script         *ScriptResult  `json:"script,omitempty"`  // absent if trigger script produced no output
+}
+
+// ForwardResult describes result of forwarding collected request.
+type ForwardResult struct {
+	Status  int    `json:"status,omitempty"`
+	Latency int64  `json:"latency"` // milliseconds
+	Error   string `json:"error,omitempty"`
+}
+
+// ScriptResult describes output of trigger script executed for collected request.
+type ScriptResult struct {
+	Log   string `json:"log"`
+	Error string `json:"error,omitempty"`
+}
+
+// RequestsPageV2 describes a page with collected requests in API v2.
+type RequestsPageV2 struct {
+	Requests   []*RequestDataV2 `json:"requests"`
+	Count      int              `json:"count"`
+	TotalCount int              `json:"total_count"`
+	HasMore    bool             `json:"has_more"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// RequestsQueryPageV2 describes a page of found requests in API v2.
+type RequestsQueryPageV2 struct {
+	Requests   []*RequestDataV2  `json:"requests"`
+	HasMore    bool              `json:"has_more"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Highlights [][]*RequestMatch `json:"highlights,omitempty"`
+}
+
+// BasketRequestsQueryPageV2 describes requests of a single basket found by search across all baskets in API v2.
+type BasketRequestsQueryPageV2 struct {
+	Basket   string           `json:"basket"`
+	Requests []*RequestDataV2 `json:"requests"`
+	HasMore  bool             `json:"has_more"`
+}
+
+// SearchResultsPageV2 describes a page of baskets with found requests in API v2.
+type SearchResultsPageV2 struct {
+	Baskets []*BasketRequestsQueryPageV2 `json:"baskets"`
+	HasMore bool                         `json:"has_more"`
+}
+
+// AssertionResultV2 describes result of asserting requests of a basket in API v2.
+type AssertionResultV2 struct {
+	Passed   bool             `json:"passed"`
+	Expected string           `json:"expected"`
+	Count    int              `json:"count"`
+	Requests []*RequestDataV2 `json:"requests"`
+}
+
+// V2 converts collected request into API v2 data model
+func (req *RequestData) V2() *RequestDataV2 {
+	data := &RequestDataV2{
+		ID:             req.ID,
+		Date:           req.Date,
+		Header:         req.Header,
+		ContentLength:  req.ContentLength,
+		Body:           req.Body,
+		Method:         req.Method,
+		Path:           req.Path,
+		Query:          req.Query,
+		ResponseStatus: req.ResponseStatus}
+
+	if req.ForwardStatus > 0 || len(req.ForwardError) > 0 {
+		data.Forward = &ForwardResult{Status: req.ForwardStatus, Latency: req.ForwardLatency, Error: req.ForwardError}
+	}
+	if len(req.ScriptLog) > 0 || len(req.ScriptError) > 0 {
+		data.Script = &ScriptResult{Log: req.ScriptLog, Error: req.ScriptError}
+	}
+
+	return data
+}
+
+func requestsV2(requests []*RequestData) []*RequestDataV2 {
+	converted := make([]*RequestDataV2, len(requests))
+	for i, req := range requests {
+		converted[i] = req.V2()
+	}
+	return converted
+}
+
+// toAPIVersion converts response body into data model of given API version, API v1 data model is kept stable,
+// so the body is converted only if it is changed by later versions
+func toAPIVersion(version int, body interface{}) interface{} {
+	if version < apiV2 {
+		return body
+	}
+
+	switch v := body.(type) {
+	case RequestData:
+		return v.V2()
+	case *RequestData:
+		return v.V2()
+	case RequestsPage:
+		return RequestsPageV2{requestsV2(v.Requests), v.Count, v.TotalCount, v.HasMore, v.NextCursor}
+	case RequestsQueryPage:
+		return RequestsQueryPageV2{requestsV2(v.Requests), v.HasMore, v.NextCursor, v.Highlights}
+	case SearchResultsPage:
+		page := SearchResultsPageV2{make([]*BasketRequestsQueryPageV2, len(v.Baskets)), v.HasMore}
+		for i, b := range v.Baskets {
+			page.Baskets[i] = &BasketRequestsQueryPageV2{b.Basket, requestsV2(b.Requests), b.HasMore}
+		}
+		return page
+	case AssertionResult:
+		return AssertionResultV2{v.Passed, v.Expected, v.Count, requestsV2(v.Requests)}
+	default:
+		return body
+	}
+}
+
+// getAPIVersion returns API version of HTTP request, requests that are not marked otherwise belong to API v1
+func getAPIVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return apiV1
+}
+
+// withAPIVersion marks HTTP requests passed to the handler with API version
+func withAPIVersion(handler httprouter.Handle, version int) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		handler(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)), ps)
+	}
+}
+
+// deprecatedAPI announces deprecation of API operation with Deprecation, Sunset (if retirement date is configured)
+// and Link headers; the link refers to successor operation: request path with root replaced by successor root
+func deprecatedAPI(handler httprouter.Handle, root string, successorRoot string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Header().Set("Deprecation", "true")
+		if len(serverConfig.APISunset) > 0 {
+			w.Header().Set("Sunset", serverConfig.APISunset)
+		}
+		w.Header().Set("Link", "<"+successorRoot+strings.TrimPrefix(r.URL.Path, root)+">; rel=\"successor-version\"")
+		handler(w, r, ps)
+	}
+}
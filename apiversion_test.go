@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestData_V2(t *testing.T) {
+	req := &RequestData{ID: 7, Date: 1600000000000, Method: "POST", Path: "/v2test/hooks", Body: "hello",
+		ResponseStatus: 200, ForwardStatus: 502, ForwardLatency: 15, ScriptLog: "done"}
+	data := req.V2()
+	assert.Equal(t, 7, data.ID, "wrong request ID")
+	assert.Equal(t, "/v2test/hooks", data.Path, "wrong request path")
+	assert.Equal(t, 200, data.ResponseStatus, "wrong response status")
+	assert.Equal(t, &ForwardResult{Status: 502, Latency: 15}, data.Forward, "wrong forward result")
+	assert.Equal(t, &ScriptResult{Log: "done"}, data.Script, "wrong script result")
+
+	// not forwarded request without trigger output
+	data = (&RequestData{ID: 8, Method: "GET"}).V2()
+	assert.Nil(t, data.Forward, "forward result is not expected")
+	assert.Nil(t, data.Script, "script result is not expected")
+
+	encoded, err := json.Marshal(data)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(encoded), "\"response_status\":0", "response status is always expected")
+		assert.NotContains(t, string(encoded), "forward", "forward result is not expected")
+	}
+}
+
+func TestToAPIVersion(t *testing.T) {
+	requests := []*RequestData{{ID: 2, ForwardError: "timeout"}, {ID: 1}}
+
+	// API v1 data model is not changed
+	page := RequestsPage{Requests: requests, Count: 2, TotalCount: 5, HasMore: true, NextCursor: "abc"}
+	assert.Equal(t, page, toAPIVersion(apiV1, page))
+
+	if v2, ok := toAPIVersion(apiV2, page).(RequestsPageV2); assert.True(t, ok, "API v2 page is expected") {
+		assert.Len(t, v2.Requests, 2, "wrong number of requests")
+		assert.Equal(t, "timeout", v2.Requests[0].Forward.Error, "wrong forward error")
+		assert.Equal(t, 5, v2.TotalCount, "wrong total count")
+		assert.Equal(t, "abc", v2.NextCursor, "wrong cursor")
+	}
+
+	query := RequestsQueryPage{Requests: requests, HasMore: true}
+	if v2, ok := toAPIVersion(apiV2, query).(RequestsQueryPageV2); assert.True(t, ok, "API v2 query page is expected") {
+		assert.Equal(t, 1, v2.Requests[1].ID, "wrong request ID")
+		assert.True(t, v2.HasMore, "more requests are expected")
+	}
+
+	search := SearchResultsPage{Baskets: []*BasketRequestsQueryPage{{"v2test", requests, false}}}
+	if v2, ok := toAPIVersion(apiV2, search).(SearchResultsPageV2); assert.True(t, ok, "API v2 search page is expected") {
+		assert.Equal(t, "v2test", v2.Baskets[0].Basket, "wrong basket name")
+		assert.Len(t, v2.Baskets[0].Requests, 2, "wrong number of requests")
+	}
+
+	assert.IsType(t, &RequestDataV2{}, toAPIVersion(apiV2, requests[0]))
+	// other types are the same in all versions
+	assert.Equal(t, BasketConfig{Capacity: 10}, toAPIVersion(apiV2, BasketConfig{Capacity: 10}))
+}
+
+func TestWithAPIVersion(t *testing.T) {
+	versions := make([]int, 0)
+	handler := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		versions = append(versions, getAPIVersion(r))
+	}
+
+	r := httptest.NewRequest("GET", "http://localhost:55555/api/version", strings.NewReader(""))
+	handler(httptest.NewRecorder(), r, nil)
+	withAPIVersion(handler, apiV2)(httptest.NewRecorder(), r, nil)
+	assert.Equal(t, []int{apiV1, apiV2}, versions, "wrong API versions")
+}
+
+func TestDeprecatedAPI(t *testing.T) {
+	defer func(sunset string) { serverConfig.APISunset = sunset }(serverConfig.APISunset)
+	handler := deprecatedAPI(GetVersion, "/prefix/api", "/prefix/api/v2")
+
+	serverConfig.APISunset = ""
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "http://localhost:55555/prefix/api/version", strings.NewReader("")), nil)
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "true", w.Header().Get("Deprecation"), "deprecation header is expected")
+	assert.Empty(t, w.Header().Get("Sunset"), "sunset header is not expected")
+	assert.Equal(t, "</prefix/api/v2/version>; rel=\"successor-version\"", w.Header().Get("Link"), "wrong successor link")
+
+	serverConfig.APISunset = "Wed, 30 Jun 2027 00:00:00 GMT"
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "http://localhost:55555/prefix/api/version", strings.NewReader("")), nil)
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"), "sunset header is expected")
+}
+
+func TestAPIVersionRouting(t *testing.T) {
+	basket := "v2test01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		data := basketsDb.Get(basket).Add(createTestPOSTRequest("http://localhost/"+basket, "hello", "text/plain"))
+		basketsDb.Get(basket).UpdateRequest(data.ID, func(req *RequestData) { req.ForwardError = "refused" })
+
+		get := func(path string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:55555"+path, strings.NewReader(""))
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			testServer.Handler.ServeHTTP(w, r)
+			return w
+		}
+
+		// API v1 is deprecated and keeps flat data model
+		w := get("/api/baskets/" + basket + "/requests")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "true", w.Header().Get("Deprecation"), "deprecation header is expected")
+		assert.Equal(t, "</api/v2/baskets/"+basket+"/requests>; rel=\"successor-version\"", w.Header().Get("Link"))
+		assert.Contains(t, w.Body.String(), "\"forward_error\":\"refused\"", "flat forward result is expected")
+
+		// API v2 groups results
+		w = get("/api/v2/baskets/" + basket + "/requests")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Empty(t, w.Header().Get("Deprecation"), "deprecation header is not expected")
+		page := new(RequestsPageV2)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page)) && assert.Len(t, page.Requests, 1) {
+			if assert.NotNil(t, page.Requests[0].Forward, "forward result is expected") {
+				assert.Equal(t, "refused", page.Requests[0].Forward.Error, "wrong forward error")
+			}
+		}
+
+		// old API is deprecated too
+		w = get("/baskets/" + basket)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "</api/v2/baskets/"+basket+">; rel=\"successor-version\"", w.Header().Get("Link"))
+
+		// OpenAPI specification of every version
+		w = get("/api/v2/openapi.json")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "\"/api/v2/baskets/{basket}/requests\"", "API v2 paths are expected")
+		assert.Contains(t, w.Body.String(), "RequestsPageV2", "API v2 schemas are expected")
+		assert.NotContains(t, w.Body.String(), "\"deprecated\"", "API v2 is not deprecated")
+	}
+}
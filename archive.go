@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ArchiveReasonCapacity  = "capacity"
+	ArchiveReasonRetention = "retention"
+	ArchiveReasonQuota     = "quota"
+	ArchiveReasonCleanup   = "cleanup"
+)
+
+const (
+	archiveQueueSize   = 1000
+	archiveBatchSize   = 500
+	archiveInterval    = time.Second
+	archiveAttempts    = 3
+	archiveTimeout     = 30 * time.Second
+	archiveS3Service   = "s3"
+	archiveS3Region    = "us-east-1"
+	archiveS3Algorithm = "AWS4-HMAC-SHA256"
+)
+
+// archive keeps requests that are evicted from baskets, nil if evicted requests are not archived
+var archive *requestArchive
+
+// ArchivedRequest describes a record of archive: request evicted from a basket by capacity, retention period,
+// storage quota of the service or cleanup policy.
+type ArchivedRequest struct {
+	Basket  string       `json:"basket"`
+	Reason  string       `json:"reason"`
+	Evicted int64        `json:"evicted"`
+	Request *RequestData `json:"request"`
+}
+
+// archiveSink writes a batch of archive records encoded as newline delimited JSON
+type archiveSink interface {
+	Write(records []byte) error
+}
+
+// requestArchive writes requests evicted from baskets to archive sink in batches, requests are queued,
+// so collecting of requests is only delayed if the sink does not keep up
+type requestArchive struct {
+	sink  archiveSink
+	queue chan *ArchivedRequest
+}
+
+func newRequestArchive(sink archiveSink) *requestArchive {
+	return &requestArchive{sink: sink, queue: make(chan *ArchivedRequest, archiveQueueSize)}
+}
+
+// Start launches background routine that writes queued records once a batch is full or every archiveInterval
+func (a *requestArchive) Start() {
+	go func() {
+		ticker := time.NewTicker(archiveInterval)
+		defer ticker.Stop()
+
+		batch := make([]*ArchivedRequest, 0, archiveBatchSize)
+		for {
+			select {
+			case record := <-a.queue:
+				if batch = append(batch, record); len(batch) < archiveBatchSize {
+					continue
+				}
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+			}
+			a.write(batch)
+			batch = batch[:0]
+		}
+	}()
+}
+
+// Archive queues requests of basket for archiving, requests are given from newest to oldest like they are
+// returned by basket and are archived from oldest to newest
+func (a *requestArchive) Archive(name string, reason string, requests []*RequestData) {
+	evicted := time.Now().UnixNano() / toMs
+	for i := len(requests) - 1; i >= 0; i-- {
+		a.queue <- &ArchivedRequest{Basket: name, Reason: reason, Evicted: evicted, Request: requests[i]}
+	}
+}
+
+// Overflow archives requests of basket that do not fit into capacity, they are evicted by the next change
+func (a *requestArchive) Overflow(name string, basket Basket, capacity int) {
+	if size := basket.Size(); size > capacity {
+		a.Archive(name, ArchiveReasonCapacity, basket.GetRequests(size-capacity, capacity).Requests)
+	}
+}
+
+// write encodes batch of records and writes it to sink, failed writes are retried
+func (a *requestArchive) write(batch []*ArchivedRequest) error {
+	var records bytes.Buffer
+	encoder := json.NewEncoder(&records)
+	for _, record := range batch {
+		if err := encoder.Encode(record); err != nil {
+			log.Printf("[error] failed to encode archived request %d of basket: %s - %s", record.Request.ID, record.Basket, err)
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= archiveAttempts; attempt++ {
+		if err = a.sink.Write(records.Bytes()); err == nil {
+			return nil
+		}
+		if attempt < archiveAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	log.Printf("[error] failed to archive %d evicted requests - %s", len(batch), err)
+	return err
+}
+
+// collectRequest adds request to basket; requests evicted to make room for the new request are archived
+// and the new request is linked into hash chain if it is configured
+func collectRequest(name string, basket Basket, config BasketConfig, data *RequestData) *RequestData {
+	if archive == nil && !config.HashChain {
+		return basket.AddRequest(data)
+	}
+
+	lock := collectLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if archive != nil {
+		archive.Overflow(name, basket, config.Capacity-1)
+	}
+	if config.HashChain {
+		linkRequest(basket, data)
+	}
+	return basket.AddRequest(data)
+}
+
+// newArchiveSink creates archive sink by its target: "-" for standard output, http or https URL to stream
+// records, s3://bucket/prefix to upload every batch as S3 object or location of a file to append records
+func newArchiveSink(target string) (archiveSink, error) {
+	switch {
+	case target == "-":
+		return &streamSink{w: os.Stdout}, nil
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		if _, err := url.ParseRequestURI(target); err != nil {
+			return nil, fmt.Errorf("invalid archive URL: %s - %s", target, err)
+		}
+		return &httpSink{url: target, client: &http.Client{Timeout: archiveTimeout}}, nil
+	case strings.HasPrefix(target, "s3://"):
+		return newS3Sink(target)
+	default:
+		sink := &fileSink{path: strings.TrimPrefix(target, "file://")}
+		// make sure the file is writable at startup
+		return sink, sink.Write(nil)
+	}
+}
+
+// streamSink writes records to a stream, e.g. standard output
+type streamSink struct {
+	w io.Writer
+}
+
+func (s *streamSink) Write(records []byte) error {
+	_, err := s.w.Write(records)
+	return err
+}
+
+// fileSink appends records to a file
+type fileSink struct {
+	path string
+}
+
+func (s *fileSink) Write(records []byte) error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(records); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// httpSink posts every batch of records to HTTP endpoint
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Write(records []byte) error {
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(records))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status of archive: %s", resp.Status)
+	}
+	return nil
+}
+
+// s3Sink uploads every batch of records as an object of S3 bucket, credentials and region are taken from
+// the standard AWS environment variables; AWS_ENDPOINT_URL selects S3 compatible storage, e.g. MinIO
+type s3Sink struct {
+	endpoint  string // URL of bucket
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+	client    *http.Client
+}
+
+func newS3Sink(target string) (*s3Sink, error) {
+	location, err := url.Parse(target)
+	if err != nil || len(location.Host) == 0 {
+		return nil, fmt.Errorf("invalid archive S3 location: %s, expected s3://bucket/prefix", target)
+	}
+
+	sink := &s3Sink{
+		prefix:    strings.Trim(location.Path, "/"),
+		region:    os.Getenv("AWS_REGION"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: archiveTimeout}}
+	if len(sink.accessKey) == 0 || len(sink.secretKey) == 0 {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to archive to S3")
+	}
+	if len(sink.region) == 0 {
+		if sink.region = os.Getenv("AWS_DEFAULT_REGION"); len(sink.region) == 0 {
+			sink.region = archiveS3Region
+		}
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); len(endpoint) > 0 {
+		sink.endpoint = strings.TrimRight(endpoint, "/") + "/" + location.Host
+	} else {
+		sink.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", location.Host, sink.region)
+	}
+	return sink, nil
+}
+
+func (s *s3Sink) Write(records []byte) error {
+	now := time.Now().UTC()
+	suffix, _ := GenerateToken()
+	key := now.Format("2006/01/02/150405.000") + "-" + suffix[:8] + ".ndjson"
+	if len(s.prefix) > 0 {
+		key = s.prefix + "/" + key
+	}
+
+	req, err := http.NewRequest("PUT", s.endpoint+"/"+key, bytes.NewReader(records))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	s.sign(req, records, now)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to upload archive object: %s - %s", key, resp.Status)
+	}
+	return nil
+}
+
+// sign signs S3 request with AWS signature version 4
+func (s *s3Sink) sign(req *http.Request, payload []byte, now time.Time) {
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	payloadHash := sha256.Sum256(payload)
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if len(s.token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if len(s.token) > 0 {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(),
+		strings.Join(signed, ";"), hex.EncodeToString(payloadHash[:])}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + s.region + "/" + archiveS3Service + "/aws4_request"
+	toSign := archiveS3Algorithm + "\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, archiveS3Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		archiveS3Algorithm, s.accessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
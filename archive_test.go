@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySink keeps archive records in memory
+type memorySink struct {
+	sync.Mutex
+	records []*ArchivedRequest
+}
+
+func (s *memorySink) Write(records []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(records))
+	for scanner.Scan() {
+		record := new(ArchivedRequest)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return err
+		}
+		s.records = append(s.records, record)
+	}
+	return nil
+}
+
+func (s *memorySink) Records() []*ArchivedRequest {
+	s.Lock()
+	defer s.Unlock()
+	return s.records
+}
+
+func TestRequestArchive_Overflow(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	name := "archive01"
+	db.Create(name, BasketConfig{Capacity: 3})
+	basket := db.Get(name)
+	for _, body := range []string{"one", "two", "three"} {
+		basket.AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: body})
+	}
+
+	a := newRequestArchive(&memorySink{})
+	a.Overflow(name, basket, 3)
+	assert.Len(t, a.queue, 0, "requests fitting into capacity are not expected to be archived")
+
+	a.Overflow(name, basket, 1)
+	if assert.Len(t, a.queue, 2, "wrong number of archived requests") {
+		first, second := <-a.queue, <-a.queue
+		assert.Equal(t, "one", first.Request.Body, "oldest request is expected to be archived first")
+		assert.Equal(t, "two", second.Request.Body, "wrong archived request")
+		assert.Equal(t, name, second.Basket, "wrong basket of archived request")
+		assert.Equal(t, ArchiveReasonCapacity, second.Reason, "wrong reason of archiving")
+	}
+}
+
+func TestCollectRequest_Archive(t *testing.T) {
+	defer func(current *requestArchive) { archive = current }(archive)
+	sink := &memorySink{}
+	archive = newRequestArchive(sink)
+	archive.Start()
+
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	name := "archive02"
+	config := BasketConfig{Capacity: 2, HashChain: true}
+	db.Create(name, config)
+	basket := db.Get(name)
+	for _, body := range []string{"one", "two", "three", "four"} {
+		collectRequest(name, basket, config, &RequestData{Date: 1000, Method: "POST", Path: "/", Body: body})
+	}
+	assert.Equal(t, 2, basket.Size(), "wrong basket size")
+	assert.True(t, verifyChain(basket).Verified, "chain is expected to be verified")
+
+	assert.Eventually(t, func() bool { return len(sink.Records()) == 2 }, 3*time.Second, 50*time.Millisecond,
+		"evicted requests are expected to be archived")
+	if records := sink.Records(); assert.Len(t, records, 2, "wrong number of archived requests") {
+		assert.Equal(t, "one", records[0].Request.Body, "wrong archived request")
+		assert.Equal(t, "two", records[1].Request.Body, "wrong archived request")
+		assert.NotEmpty(t, records[1].Request.Hash, "archived request is expected to keep its hash")
+	}
+}
+
+func TestRequestRetention_Archive(t *testing.T) {
+	defer func(current *requestArchive) { archive = current }(archive)
+	archive = newRequestArchive(&memorySink{})
+
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	name := "archive03"
+	now := time.Now()
+	db.Create(name, BasketConfig{Capacity: 20, Retention: 60})
+	db.Get(name).AddRequest(&RequestData{Date: now.Add(-time.Hour).UnixNano() / toMs, Method: "GET", Path: "/old"})
+	db.Get(name).AddRequest(&RequestData{Date: now.UnixNano() / toMs, Method: "GET", Path: "/new"})
+
+	assert.Equal(t, 1, newRequestRetention(db, 0).Purge(now), "wrong number of deleted requests")
+	if assert.Len(t, archive.queue, 1, "expired request is expected to be archived") {
+		record := <-archive.queue
+		assert.Equal(t, "/old", record.Request.Path, "wrong archived request")
+		assert.Equal(t, ArchiveReasonRetention, record.Reason, "wrong reason of archiving")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	file, err := ioutil.TempFile("", "archive*.ndjson")
+	if !assert.NoError(t, err) {
+		return
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	sink, err := newArchiveSink(file.Name())
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Write([]byte("{\"basket\":\"a\"}\n")))
+		assert.NoError(t, sink.Write([]byte("{\"basket\":\"b\"}\n")))
+
+		content, _ := ioutil.ReadFile(file.Name())
+		assert.Equal(t, "{\"basket\":\"a\"}\n{\"basket\":\"b\"}\n", string(content), "records are expected to be appended")
+	}
+
+	_, err = newArchiveSink("/unknown/directory/archive.ndjson")
+	assert.Error(t, err, "file is expected to be writable")
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received []byte
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	sink, err := newArchiveSink(ts.URL + "/archive")
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Write([]byte("{}\n")))
+		assert.Equal(t, "{}\n", string(received), "wrong archived records")
+		assert.Equal(t, "application/x-ndjson", contentType, "wrong content type")
+	}
+
+	sink, _ = newArchiveSink(ts.URL + "/fail")
+	assert.Error(t, sink.Write([]byte("{}\n")), "failed response is expected to be reported")
+}
+
+func TestS3Sink(t *testing.T) {
+	var path, auth, payloadHash string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, payloadHash = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("X-Amz-Content-Sha256")
+	}))
+	defer ts.Close()
+
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_REGION": "eu-west-1", "AWS_ENDPOINT_URL": ts.URL} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	sink, err := newArchiveSink("s3://archive-bucket/request-baskets")
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Write([]byte("{}\n")))
+		assert.True(t, strings.HasPrefix(path, "/archive-bucket/request-baskets/"), "wrong object key: %s", path)
+		assert.True(t, strings.HasSuffix(path, ".ndjson"), "wrong object key: %s", path)
+		assert.Contains(t, auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/", "request is expected to be signed")
+		assert.Contains(t, auth, "/eu-west-1/s3/aws4_request", "wrong scope of signature")
+		assert.Len(t, payloadHash, 64, "hash of payload is expected")
+	}
+
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	_, err = newArchiveSink("s3://archive-bucket")
+	assert.Error(t, err, "credentials are expected")
+}
+
+func TestS3Sink_Sign(t *testing.T) {
+	sink := &s3Sink{region: "us-east-1", accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	req, _ := http.NewRequest("PUT", "https://examplebucket.s3.amazonaws.com/test.ndjson", nil)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	sink.sign(req, []byte("{}\n"), time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "20130524T000000Z", req.Header.Get("X-Amz-Date"), "wrong signature date")
+	first := req.Header.Get("Authorization")
+	assert.Contains(t, first, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date,", "wrong signed headers")
+
+	// signature depends on payload
+	sink.sign(req, []byte("[]\n"), time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC))
+	assert.NotEqual(t, first, req.Header.Get("Authorization"), "signature is expected to cover payload")
+}
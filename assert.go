@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const maxAssertionSize = 64 * 1024
+
+// BasketAssertion describes expected requests of a basket, e.g. exactly one webhook with certain event type
+// collected within a minute. At least one matching request is expected if neither count nor bounds are specified.
+type BasketAssertion struct {
+	Filter   string `json:"filter,omitempty"`    // filter expression that matches expected requests
+	Query    string `json:"query,omitempty"`     // search criteria in URL query format, e.g. method=POST&path=/hooks/*
+	Count    *int   `json:"count,omitempty"`     // exact number of matching requests
+	MinCount *int   `json:"min_count,omitempty"` // minimal number of matching requests
+	MaxCount *int   `json:"max_count,omitempty"` // maximal number of matching requests
+	Timeout  string `json:"timeout,omitempty"`   // maximum time to wait for expected requests, 30s by default
+	After    int    `json:"after,omitempty"`     // only requests collected after request with given ID are considered
+}
+
+// AssertionResult describes result of asserting requests of a basket.
+type AssertionResult struct {
+	Passed   bool           `json:"passed"`
+	Expected string         `json:"expected"` // human readable expectation, e.g. "exactly 1"
+	Count    int            `json:"count"`    // number of matching requests
+	Requests []*RequestData `json:"requests"` // matching requests, the oldest first
+}
+
+// requestsExpectation defines expected number of matching requests, negative max stands for no upper bound
+type requestsExpectation struct {
+	min int
+	max int
+}
+
+// getRequestsExpectation validates expected number of requests of the assertion
+func getRequestsExpectation(assertion *BasketAssertion) (requestsExpectation, error) {
+	if assertion.Count != nil {
+		if assertion.MinCount != nil || assertion.MaxCount != nil {
+			return requestsExpectation{}, fmt.Errorf("'count' cannot be combined with 'min_count' or 'max_count'")
+		}
+		if *assertion.Count < 0 {
+			return requestsExpectation{}, fmt.Errorf("count may not be negative, but was %d", *assertion.Count)
+		}
+		return requestsExpectation{*assertion.Count, *assertion.Count}, nil
+	}
+
+	expected := requestsExpectation{1, -1}
+	if assertion.MinCount != nil || assertion.MaxCount != nil {
+		expected.min = 0
+	}
+	if assertion.MinCount != nil {
+		if *assertion.MinCount < 0 {
+			return expected, fmt.Errorf("min_count may not be negative, but was %d", *assertion.MinCount)
+		}
+		expected.min = *assertion.MinCount
+	}
+	if assertion.MaxCount != nil {
+		if *assertion.MaxCount < expected.min {
+			return expected, fmt.Errorf("max_count may not be less than %d, but was %d", expected.min, *assertion.MaxCount)
+		}
+		expected.max = *assertion.MaxCount
+	}
+	return expected, nil
+}
+
+// getAssertionQuery parses search criteria of the assertion, nil query matches any request
+func getAssertionQuery(assertion *BasketAssertion) (*RequestsQuery, error) {
+	values, err := url.ParseQuery(assertion.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %s", err)
+	}
+	if len(assertion.Filter) > 0 {
+		if len(values.Get("filter")) > 0 {
+			return nil, fmt.Errorf("filter is defined twice")
+		}
+		values.Set("filter", assertion.Filter)
+	}
+	return getRequestsQuery(values)
+}
+
+// String describes expectation in human readable form
+func (e requestsExpectation) String() string {
+	switch {
+	case e.min == e.max:
+		return fmt.Sprintf("exactly %d", e.min)
+	case e.max < 0:
+		return fmt.Sprintf("at least %d", e.min)
+	case e.min == 0:
+		return fmt.Sprintf("at most %d", e.max)
+	default:
+		return fmt.Sprintf("between %d and %d", e.min, e.max)
+	}
+}
+
+// Check verifies number of found requests, the check is final if more requests cannot change its result
+// before timeout: the upper bound is exceeded or the lower bound is reached. Expectations without lower bound
+// are final only upon timeout.
+func (e requestsExpectation) Check(found int) (passed bool, final bool) {
+	if e.max >= 0 && found > e.max {
+		return false, true
+	}
+	if found >= e.min {
+		return true, e.min > 0
+	}
+	return false, false
+}
+
+// findRequestsAfter finds requests with ID greater than given one that match the query, the oldest first
+func findRequestsAfter(basket Basket, after int, query *RequestsQuery) []*RequestData {
+	found := make([]*RequestData, 0)
+	for skip, more := 0, true; more; skip += waitPageSize {
+		page := basket.GetRequests(waitPageSize, skip)
+		more = page.HasMore
+		for _, request := range page.Requests {
+			if request.ID <= after {
+				more = false
+				break
+			}
+			if query == nil || request.Matches(query) {
+				found = append(found, request)
+			}
+		}
+	}
+
+	// requests are listed from newest to oldest
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+// assertRequests waits until number of requests collected by basket after given one that match the query meets
+// the expectation or the timeout expires, false is returned if waiting is cancelled by context
+func assertRequests(ctx context.Context, name string, basket Basket, query *RequestsQuery, expected requestsExpectation,
+	after int, timeout time.Duration) (AssertionResult, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	result := AssertionResult{Expected: expected.String(), Requests: make([]*RequestData, 0)}
+	for {
+		// start waiting before looking into collected requests, so no request is missed
+		waiter := arrivals.Wait(name, query)
+		found := findRequestsAfter(basket, after, query)
+		if len(found) > 0 {
+			result.Requests = append(result.Requests, found...)
+			after = found[len(found)-1].ID
+		}
+
+		passed, final := expected.Check(len(result.Requests))
+		result.Passed = passed
+		result.Count = len(result.Requests)
+		if final {
+			arrivals.Cancel(name, waiter)
+			return result, true
+		}
+
+		select {
+		case <-waiter.arrived:
+		case <-timer.C:
+			arrivals.Cancel(name, waiter)
+			return result, true
+		case <-ctx.Done():
+			arrivals.Cancel(name, waiter)
+			return result, false
+		}
+	}
+}
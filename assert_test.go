@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestGetRequestsExpectation(t *testing.T) {
+	expectations := []struct {
+		assertion BasketAssertion
+		expected  string
+	}{
+		{BasketAssertion{}, "at least 1"},
+		{BasketAssertion{Count: intPtr(2)}, "exactly 2"},
+		{BasketAssertion{MinCount: intPtr(3)}, "at least 3"},
+		{BasketAssertion{MaxCount: intPtr(4)}, "at most 4"},
+		{BasketAssertion{MinCount: intPtr(1), MaxCount: intPtr(5)}, "between 1 and 5"}}
+	for _, e := range expectations {
+		expected, err := getRequestsExpectation(&e.assertion)
+		if assert.NoError(t, err) {
+			assert.Equal(t, e.expected, expected.String(), "wrong expectation")
+		}
+	}
+
+	invalid := []BasketAssertion{
+		{Count: intPtr(-1)},
+		{Count: intPtr(1), MinCount: intPtr(1)},
+		{MinCount: intPtr(-2)},
+		{MinCount: intPtr(2), MaxCount: intPtr(1)}}
+	for _, assertion := range invalid {
+		_, err := getRequestsExpectation(&assertion)
+		assert.Error(t, err, "invalid expectation is not expected: %v", assertion)
+	}
+}
+
+func TestRequestsExpectation_Check(t *testing.T) {
+	exactlyOne := requestsExpectation{1, 1}
+	passed, final := exactlyOne.Check(0)
+	assert.False(t, passed || final, "not yet passed check is expected")
+	passed, final = exactlyOne.Check(1)
+	assert.True(t, passed && final, "final passed check is expected")
+	passed, final = exactlyOne.Check(2)
+	assert.True(t, !passed && final, "final failed check is expected")
+
+	none := requestsExpectation{0, 0}
+	passed, final = none.Check(0)
+	assert.True(t, passed && !final, "passed check is expected to be final only upon timeout")
+}
+
+func TestAssertRequests(t *testing.T) {
+	name := "assert02"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 300})
+	basket := db.Get(name)
+	for i := 1; i <= 150; i++ {
+		method := "GET"
+		if i%50 == 0 {
+			method = "POST"
+		}
+		basket.AddRequest(&RequestData{Method: method, Path: "/" + name})
+	}
+
+	posts := &RequestsQuery{Method: "POST"}
+	found := findRequestsAfter(basket, 0, posts)
+	if assert.Len(t, found, 3, "wrong number of found requests") {
+		assert.Equal(t, 50, found[0].ID, "the oldest request is expected first")
+		assert.Equal(t, 150, found[2].ID, "the newest request is expected last")
+	}
+	assert.Len(t, findRequestsAfter(basket, 100, posts), 1, "only requests after given one are expected")
+
+	// arriving request is awaited
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		request := &RequestData{Method: "POST", Path: "/" + name}
+		basket.AddRequest(request)
+		arrivals.Notify(name, request)
+	}()
+	result, ok := assertRequests(context.Background(), name, basket, posts, requestsExpectation{1, 1}, 150, 5*time.Second)
+	assert.True(t, ok, "completed assertion is expected")
+	assert.True(t, result.Passed, "assertion is expected to pass")
+	assert.Equal(t, 1, result.Count, "wrong number of requests")
+
+	// cancelled assertion
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = assertRequests(ctx, name, basket, posts, requestsExpectation{5, -1}, 0, 5*time.Second)
+	assert.False(t, ok, "cancelled assertion is expected")
+	assert.Empty(t, arrivals.waiters[name], "waiter is expected to be cancelled")
+}
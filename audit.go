@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions recorded in audit log, actions are grouped by the prefix before the dot
+const (
+	AuditBasketCreate      = "basket.create"
+	AuditBasketUpdate      = "basket.update"
+	AuditBasketDelete      = "basket.delete"
+	AuditBasketRename      = "basket.rename"
+	AuditBasketRestore     = "basket.restore"
+	AuditBasketClone       = "basket.clone"
+	AuditBasketSpec        = "basket.spec"
+	AuditBasketSnapshot    = "basket.snapshot"
+	AuditBasketACL         = "basket.acl"
+	AuditResponse          = "basket.response"
+	AuditTrigger           = "basket.trigger"
+	AuditSchedules         = "basket.schedules"
+	AuditWebhooks          = "basket.webhooks"
+	AuditSecretUpdate      = "secret.update"
+	AuditSecretDelete      = "secret.delete"
+	AuditTokenRotate       = "token.rotate"
+	AuditTokenRevoke       = "token.revoke"
+	AuditTokenShare        = "token.share"
+	AuditTokenUnshare      = "token.unshare"
+	AuditTokenSign         = "token.sign"
+	AuditViewPassword      = "token.view-password"
+	AuditAccessTokenIssue  = "token.access-issue"
+	AuditAccessTokenRevoke = "token.access-revoke"
+	AuditUserCreate        = "user.create"
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserSignOut       = "user.sign-out"
+	AuditRoleUpdate        = "role.update"
+	AuditRoleTokenIssue    = "role.token-issue"
+	AuditRoleTokenRevoke   = "role.token-revoke"
+	AuditAPIKeyCreate      = "apikey.create"
+	AuditAPIKeyRevoke      = "apikey.revoke"
+	AuditServiceWebhooks   = "service.webhooks"
+	AuditRequestPurge      = "request.purge"
+	AuditCleanupPolicies   = "service.cleanup"
+	AuditCleanupRun        = "service.cleanup-run"
+	AuditNamespacePolicies = "service.namespaces"
+	AuditAlertRules        = "service.alerts"
+)
+
+const (
+	maxAuditEntries  = 10000 // entries kept in memory, older entries are only kept in the file
+	maxAuditLineSize = 16 * 1024 * 1024
+	auditAnonymous   = "anonymous"
+	auditMasterToken = "master"
+)
+
+var audit *auditLog
+
+// AuditEntry describes configuration change recorded in audit log, secrets and tokens are never recorded
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	Date      int64           `json:"date"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Basket    string          `json:"basket,omitempty"`
+	Target    string          `json:"target,omitempty"` // e.g. HTTP method of response, name of token, secret or user
+	Changes   []string        `json:"changes,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// AuditPage describes a page of audit log entries, the latest entries come first
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	HasMore bool         `json:"has_more"`
+}
+
+// AuditQuery describes criteria of audit log entries, empty fields match any entry
+type AuditQuery struct {
+	Basket string
+	Actor  string
+	Action string // action or group of actions, e.g. "basket"
+	From   int64
+	To     int64
+}
+
+// auditLog keeps the latest entries of audit log in memory, all entries are appended to JSON lines file
+// if the file is configured
+type auditLog struct {
+	sync.RWMutex
+	file    string
+	entries []AuditEntry
+	lastID  int
+}
+
+// newAuditLog creates audit log and loads the latest entries from the file
+func newAuditLog(file string) (*auditLog, error) {
+	l := &auditLog{file: file, entries: make([]AuditEntry, 0)}
+	if len(file) == 0 {
+		return l, nil
+	}
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		log.Printf("[info] audit log file is not found, it is created once configuration is changed: %s", file)
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read audit log file: %s - %s", file, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLineSize)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log file: %s - %s", file, err)
+		}
+		l.append(entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log file: %s - %s", file, err)
+	}
+	log.Printf("[info] loaded %d entries of audit log from file: %s", len(l.entries), file)
+
+	return l, nil
+}
+
+// Record records configuration change, the values before and after the change are compared to list changed fields;
+// request ID is taken from HTTP response
+func (l *auditLog) Record(w http.ResponseWriter, entry AuditEntry, before interface{}, after interface{}) {
+	entry.Date = time.Now().UnixNano() / toMs
+	entry.RequestID = w.Header().Get(RequestIDHeader)
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.After, _ = json.Marshal(after)
+	}
+	entry.Changes = diffFields(entry.Before, entry.After)
+
+	l.Lock()
+	defer l.Unlock()
+
+	entry.ID = l.lastID + 1
+	l.append(entry)
+	if len(l.file) > 0 {
+		if err := appendJSONLine(l.file, entry); err != nil {
+			log.Printf("[error] failed to write audit log file: %s - %s", l.file, err)
+		}
+	}
+}
+
+// Find returns a page of entries that match query, the latest entries come first
+func (l *auditLog) Find(query AuditQuery, max int, skip int) AuditPage {
+	l.RLock()
+	defer l.RUnlock()
+
+	page := AuditPage{Entries: make([]AuditEntry, 0, max)}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if entry := l.entries[i]; query.Matches(entry) {
+			if skip > 0 {
+				skip--
+			} else if len(page.Entries) < max {
+				page.Entries = append(page.Entries, entry)
+			} else {
+				page.HasMore = true
+				break
+			}
+		}
+	}
+	return page
+}
+
+func (l *auditLog) append(entry AuditEntry) {
+	if len(l.entries) >= maxAuditEntries {
+		l.entries = append(l.entries[:0:0], l.entries[len(l.entries)-maxAuditEntries+1:]...)
+	}
+	l.entries = append(l.entries, entry)
+	if entry.ID > l.lastID {
+		l.lastID = entry.ID
+	}
+}
+
+// Matches checks if audit log entry matches query
+func (query AuditQuery) Matches(entry AuditEntry) bool {
+	return (len(query.Basket) == 0 || query.Basket == entry.Basket) &&
+		(len(query.Actor) == 0 || query.Actor == entry.Actor) &&
+		(len(query.Action) == 0 || query.Action == entry.Action || strings.HasPrefix(entry.Action, query.Action+".")) &&
+		(query.From == 0 || entry.Date >= query.From) &&
+		(query.To == 0 || entry.Date <= query.To)
+}
+
+// auditActor identifies the actor of HTTP request by its token, basket is used to recognize tokens of the basket,
+// so the actor must be identified before the tokens are changed
+func auditActor(r *http.Request, name string, basket Basket) string {
+	token := r.Header.Get("Authorization")
+	if claims, ok := r.Context().Value(jwtClaimsKey{}).(map[string]interface{}); ok {
+		if subject, ok := claims["sub"].(string); ok {
+			return "jwt:" + subject
+		}
+	}
+	if len(token) == 0 {
+		return auditAnonymous
+	}
+
+	if token == serverConfig.MasterToken {
+		return auditMasterToken
+	}
+	if key, exists := apiKeys.Lookup(token); exists {
+		return "apikey:" + key
+	}
+	if t, exists := roles.Lookup(token); exists {
+		return "role:" + t.Role + "/" + t.Name
+	}
+	if user := users.Authenticate(token); user != nil {
+		return "user:" + user.Name
+	}
+	if basket != nil {
+		if basket.Authorize(token) {
+			return "basket:" + name
+		}
+		for _, t := range basket.GetAccessTokens() {
+			if t.Token == token {
+				return "token:" + name + "/" + t.Name
+			}
+		}
+	}
+	return auditAnonymous
+}
+
+// diffFields returns names of top level fields that differ between two JSON objects, nil is returned
+// if any of the values is not an object
+func diffFields(before json.RawMessage, after json.RawMessage) []string {
+	a, b := make(map[string]json.RawMessage), make(map[string]json.RawMessage)
+	if json.Unmarshal(before, &a) != nil || json.Unmarshal(after, &b) != nil {
+		return nil
+	}
+
+	changes := make([]string, 0)
+	for field, value := range a {
+		if other, exists := b[field]; !exists || string(other) != string(value) {
+			changes = append(changes, field)
+		}
+	}
+	for field := range b {
+		if _, exists := a[field]; !exists {
+			changes = append(changes, field)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// appendJSONLine appends value as a line of JSON to the file, the file is created if it does not exist
+func appendJSONLine(file string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog_Find(t *testing.T) {
+	l, err := newAuditLog("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req01")
+	l.Record(w, AuditEntry{Actor: "master", Action: AuditBasketCreate, Basket: "audit01"}, nil, BasketConfig{Capacity: 20})
+	l.Record(w, AuditEntry{Actor: "basket:audit01", Action: AuditBasketUpdate, Basket: "audit01"},
+		BasketConfig{Capacity: 20}, BasketConfig{Capacity: 50, ExpandPath: true})
+	l.Record(w, AuditEntry{Actor: "master", Action: AuditUserCreate, Target: "user01"}, nil, nil)
+
+	page := l.Find(AuditQuery{}, 10, 0)
+	if assert.Len(t, page.Entries, 3) {
+		assert.Equal(t, 3, page.Entries[0].ID, "the latest entry is expected first")
+		assert.Equal(t, "req01", page.Entries[0].RequestID, "request ID is expected")
+		assert.Equal(t, []string{"capacity", "expand_path"}, page.Entries[1].Changes, "wrong changes")
+		assert.JSONEq(t, `{"forward_url": "", "proxy_response": false, "insecure_tls": false, "expand_path": false, "capacity": 20}`,
+			string(page.Entries[1].Before), "wrong value before change")
+	}
+	assert.False(t, page.HasMore, "no more entries are expected")
+
+	assert.Len(t, l.Find(AuditQuery{Basket: "audit01"}, 10, 0).Entries, 2, "entries of basket are expected")
+	assert.Len(t, l.Find(AuditQuery{Actor: "master"}, 10, 0).Entries, 2, "entries of actor are expected")
+	assert.Len(t, l.Find(AuditQuery{Action: "basket"}, 10, 0).Entries, 2, "group of actions is expected")
+	assert.Len(t, l.Find(AuditQuery{Action: "bask"}, 10, 0).Entries, 0, "part of action is not expected")
+	assert.Len(t, l.Find(AuditQuery{From: page.Entries[0].Date + 1}, 10, 0).Entries, 0, "no entries are expected")
+
+	page = l.Find(AuditQuery{}, 1, 1)
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, 2, page.Entries[0].ID, "wrong entry")
+		assert.True(t, page.HasMore, "more entries are expected")
+	}
+}
+
+func TestAuditLog_File(t *testing.T) {
+	file := "audit01.jsonl"
+	defer os.Remove(file)
+
+	l, err := newAuditLog(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := httptest.NewRecorder()
+	l.Record(w, AuditEntry{Actor: "master", Action: AuditBasketDelete, Basket: "audit02"}, BasketConfig{Capacity: 20}, nil)
+	l.Record(w, AuditEntry{Actor: "master", Action: AuditAPIKeyCreate, Target: "ci"}, nil, nil)
+
+	data, _ := ioutil.ReadFile(file)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"), "a line per entry is expected")
+
+	restored, err := newAuditLog(file)
+	if assert.NoError(t, err) {
+		assert.Len(t, restored.Find(AuditQuery{}, 10, 0).Entries, 2, "entries are expected to be restored")
+		restored.Record(w, AuditEntry{Actor: "master", Action: AuditAPIKeyRevoke, Target: "ci"}, nil, nil)
+		assert.Equal(t, 3, restored.Find(AuditQuery{}, 1, 0).Entries[0].ID, "IDs are expected to continue")
+	}
+
+	ioutil.WriteFile(file, []byte("{\n"), 0600)
+	_, err = newAuditLog(file)
+	assert.Error(t, err, "invalid file is not expected")
+}
+
+func TestDiffFields(t *testing.T) {
+	assert.Equal(t, []string{"a", "c", "d"}, diffFields(json.RawMessage(`{"a": 1, "b": 2, "c": 3}`),
+		json.RawMessage(`{"a": 2, "b": 2, "d": 4}`)))
+	assert.Empty(t, diffFields(json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 1}`)), "no changes are expected")
+	assert.Nil(t, diffFields(nil, json.RawMessage(`{"a": 1}`)), "changes are not expected without value")
+	assert.Nil(t, diffFields(json.RawMessage(`[1]`), json.RawMessage(`[2]`)), "changes are not expected for lists")
+}
+
+func TestAuditActions(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	find := func(query string) []AuditEntry {
+		w := call("GET", "/audit?"+query, serverConfig.MasterToken, "")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		page := AuditPage{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page.Entries
+	}
+
+	// actions are recorded with actors
+	w := call("POST", "/baskets/audit11", serverConfig.MasterToken, `{"capacity": 30}`)
+	auth := BasketAuth{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &auth))
+	assert.Equal(t, 204, call("PUT", "/baskets/audit11", auth.Token, `{"capacity": 40}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/baskets/audit11/responses/GET", auth.Token, `{"status": 201}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/baskets/audit11/secrets/api_key", auth.Token, "s3cr3t").Code, "wrong HTTP result code")
+	w = call("POST", "/baskets/audit11/token", auth.Token, "")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	rotated := BasketAuth{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+	assert.Equal(t, 204, call("DELETE", "/baskets/audit11", rotated.Token, "").Code, "wrong HTTP result code")
+
+	entries := find("basket=audit11")
+	if assert.Len(t, entries, 6, "wrong number of recorded actions") {
+		actions := []string{}
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+			assert.Equal(t, "audit11", entry.Basket, "wrong basket")
+		}
+		assert.Equal(t, []string{AuditBasketDelete, AuditTokenRotate, AuditSecretUpdate, AuditResponse, AuditBasketUpdate,
+			AuditBasketCreate}, actions, "wrong actions")
+
+		assert.Equal(t, "master", entries[5].Actor, "wrong actor")
+		assert.Equal(t, "basket:audit11", entries[4].Actor, "wrong actor")
+		assert.Equal(t, []string{"capacity"}, entries[4].Changes, "wrong changes")
+		assert.Equal(t, "GET", entries[3].Target, "wrong target")
+		assert.Equal(t, "api_key", entries[2].Target, "wrong target")
+		assert.Equal(t, "basket:audit11", entries[0].Actor, "actor is expected to be identified by the rotated token")
+		assert.NotEmpty(t, entries[0].RequestID, "request ID is expected")
+	}
+
+	// secrets and tokens are never recorded
+	w = call("GET", "/audit?basket=audit11", serverConfig.MasterToken, "")
+	assert.NotContains(t, w.Body.String(), "s3cr3t", "secret is not expected")
+	assert.NotContains(t, w.Body.String(), auth.Token, "token is not expected")
+	assert.NotContains(t, w.Body.String(), rotated.Token, "token is not expected")
+
+	// filters and authorization
+	assert.Len(t, find("basket=audit11&action=token"), 1, "group of actions is expected")
+	assert.Len(t, find("basket=audit11&actor=master"), 1, "actions of actor are expected")
+	assert.Equal(t, 400, call("GET", "/audit?from=yesterday", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/audit", rotated.Token, "").Code, "wrong HTTP result code")
+}
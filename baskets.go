@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,6 +19,36 @@ const toMs = int64(time.Millisecond) / int64(time.Nanosecond)
 // DoNotForwardHeader indicates whether request can (0) or cannot (1) be forwarded
 const DoNotForwardHeader = "X-Do-Not-Forward"
 
+// Supported types of requests search queries
+const (
+	QueryTypeSubstring = "substring"
+	QueryTypeRegex     = "regex"
+	QueryTypeJSONPath  = "jsonpath"
+)
+
+// searchNamesChunk defines number of basket names fetched at once while searching requests across all baskets
+const searchNamesChunk = 100
+
+// Supported sort orders of collected requests
+const (
+	SortNewest         = "newest"
+	SortOldest         = "oldest"
+	SortContentLength  = "content_length"
+	SortForwardLatency = "forward_latency"
+)
+
+// httpMethods lists HTTP methods that may have configured basket response
+var httpMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace}
+
 // BasketConfig describes single basket configuration.
 type BasketConfig struct {
 	ForwardURL    string `json:"forward_url"`
@@ -22,6 +56,18 @@ type BasketConfig struct {
 	InsecureTLS   bool   `json:"insecure_tls"`
 	ExpandPath    bool   `json:"expand_path"`
 	Capacity      int    `json:"capacity"`
+
+	RateLimit      *CaptureLimit   `json:"rate_limit,omitempty"`      // rate limit of collected requests, nil - unlimited
+	BodyLimit      *BodyLimit      `json:"body_limit,omitempty"`      // size limit of bodies of collected requests, nil - limit of service
+	Redaction      []RedactionRule `json:"redaction,omitempty"`       // rules to redact sensitive data of collected requests
+	Signature      *SignatureCheck `json:"signature,omitempty"`       // verification of webhook signatures, nil - not verified
+	ForwardHeaders *ForwardHeaders `json:"forward_headers,omitempty"` // handling of headers of forwarded requests
+	Encryption     *EncryptionKey  `json:"encryption,omitempty"`      // public key to encrypt bodies, nil - not encrypted
+	Ignore         []string        `json:"ignore,omitempty"`          // filter expressions of requests dropped without collecting
+	Retention      int             `json:"retention,omitempty"`       // maximum age of collected requests in seconds, 0 - retention of service
+	HashChain      bool            `json:"hash_chain,omitempty"`      // collected requests are linked into tamper-evident hash chain
+	ExpiresAfter   int             `json:"expires_after,omitempty"`   // basket is deleted after being idle for seconds, 0 - never
+	Pause          *BasketPause    `json:"pause,omitempty"`           // new requests are rejected while paused, nil - not paused
 }
 
 // ResponseConfig describes response that is generates by service upon HTTP request sent to a basket.
@@ -33,20 +79,92 @@ type ResponseConfig struct {
 	IsScript   bool        `json:"is_script"`
 }
 
+// TriggerConfig describes script that is executed asynchronously after HTTP request is collected by basket.
+type TriggerConfig struct {
+	Script string `json:"script"`
+}
+
+// ScheduleConfig describes script that is executed by basket according to cron schedule.
+type ScheduleConfig struct {
+	Name   string `json:"name"`
+	Cron   string `json:"cron"`
+	Script string `json:"script"`
+}
+
+// WebhookConfig describes subscription of external URL to basket events.
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // empty list subscribes to all events
+	Secret string   `json:"secret,omitempty"` // key to sign payload, see WebhookSignatureHeader
+}
+
 // BasketAuth describes basket authentication response that is sent when new basket is created.
 type BasketAuth struct {
 	Token string `json:"token"`
 }
 
+// BasketRename describes request to rename a basket.
+type BasketRename struct {
+	Name string `json:"name"`
+}
+
+// BasketClone describes request to clone a basket.
+type BasketClone struct {
+	Name            string `json:"name"`
+	IncludeRequests bool   `json:"include_requests"`
+}
+
 // RequestData describes collected request data.
 type RequestData struct {
-	Date          int64       `json:"date"`
-	Header        http.Header `json:"headers"`
-	ContentLength int64       `json:"content_length"`
-	Body          string      `json:"body"`
-	Method        string      `json:"method"`
-	Path          string      `json:"path"`
-	Query         string      `json:"query"`
+	ID             int             `json:"id,omitempty"`
+	Date           int64           `json:"date"`
+	Header         http.Header     `json:"headers"`
+	ContentLength  int64           `json:"content_length"`
+	Body           string          `json:"body"`
+	Method         string          `json:"method"`
+	Path           string          `json:"path"`
+	Query          string          `json:"query"`
+	ForwardLatency int64           `json:"forward_latency,omitempty"` // milliseconds
+	ForwardStatus  int             `json:"forward_status,omitempty"`
+	ForwardError   string          `json:"forward_error,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	ScriptLog      string          `json:"script_log,omitempty"` // output of trigger script
+	ScriptError    string          `json:"script_error,omitempty"`
+	BodyTruncated  bool            `json:"body_truncated,omitempty"` // body is cut to the size limit of basket
+	Redacted       bool            `json:"redacted,omitempty"`       // sensitive data is replaced by redaction rules
+	Signature      string          `json:"signature,omitempty"`      // verified or unverified webhook signature
+	SignatureError string          `json:"signature_error,omitempty"`
+	BodyEncryption *BodyEncryption `json:"body_encryption,omitempty"` // body is encrypted with public key of basket
+	Subdomain      bool            `json:"subdomain,omitempty"`       // basket is selected by host, path has no basket name
+	Hash           string          `json:"hash,omitempty"`            // hash of request linked to previous request, see verifyChain
+	PrevHash       string          `json:"prev_hash,omitempty"`
+}
+
+// RequestsQuery describes search criteria of collected requests.
+type RequestsQuery struct {
+	Text  string
+	In    string
+	Type  string
+	From  int64 // inclusive, milliseconds since epoch, 0 - no lower bound
+	To    int64 // inclusive, milliseconds since epoch, 0 - no upper bound
+	regex *regexp.Regexp
+	json  *jsonPathQuery
+
+	IgnoreCase bool // case-insensitive matching of query text
+	WholeWord  bool // query text must match at word boundaries
+
+	Method string
+	Path   string // glob pattern, e.g. /callback/*
+	path   *regexp.Regexp
+
+	Headers http.Header // each header must be present and contain every listed value, empty value checks presence only
+
+	filter     filterExpr
+	filterText string
+
+	Sort string // one of Sort* constants, empty value stands for newest first
+
+	Before int // ID of request to find older requests only (cursor), 0 - no limit
 }
 
 // RequestsPage describes a page with collected requests.
@@ -55,19 +173,51 @@ type RequestsPage struct {
 	Count      int            `json:"count"`
 	TotalCount int            `json:"total_count"`
 	HasMore    bool           `json:"has_more"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// maxPreallocatedRequests limits capacity preallocated for a page of requests, so a page with large maximum,
+// e.g. all requests of basket, does not reserve memory for requests that are not there
+const maxPreallocatedRequests = 100
+
+// pageCapacity returns capacity to preallocate for a page of up to max requests
+func pageCapacity(max int) int {
+	if max > maxPreallocatedRequests {
+		return maxPreallocatedRequests
+	}
+	if max < 0 {
+		return 0
+	}
+	return max
 }
 
 // RequestsQueryPage describes a page of found requests if search filter is applied.
 type RequestsQueryPage struct {
+	Requests   []*RequestData    `json:"requests"`
+	HasMore    bool              `json:"has_more"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Highlights [][]*RequestMatch `json:"highlights,omitempty"` // matches of each found request, see Highlight
+}
+
+// BasketRequestsQueryPage describes requests of a single basket found by search across all baskets.
+type BasketRequestsQueryPage struct {
+	Basket   string         `json:"basket"`
 	Requests []*RequestData `json:"requests"`
 	HasMore  bool           `json:"has_more"`
 }
 
+// SearchResultsPage describes a page of baskets with requests found by search across all baskets.
+type SearchResultsPage struct {
+	Baskets []*BasketRequestsQueryPage `json:"baskets"`
+	HasMore bool                       `json:"has_more"`
+}
+
 // BasketNamesPage describes a page with basket names managed by service.
 type BasketNamesPage struct {
-	Names   []string `json:"names"`
-	Count   int      `json:"count"`
-	HasMore bool     `json:"has_more"`
+	Names      []string `json:"names"`
+	Count      int      `json:"count"`
+	HasMore    bool     `json:"has_more"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 // BasketNamesQueryPage describes a page with found basket names if search filter is applied.
@@ -76,6 +226,16 @@ type BasketNamesQueryPage struct {
 	HasMore bool     `json:"has_more"`
 }
 
+// RequestsDeletion describes result of deleting selected requests of a basket.
+type RequestsDeletion struct {
+	Deleted int `json:"deleted"`
+}
+
+// RequestsImport describes result of importing requests into a basket.
+type RequestsImport struct {
+	Imported int `json:"imported"`
+}
+
 // DatabaseStats describes collected statistics of a baskets database
 type DatabaseStats struct {
 	BasketsCount       int           `json:"baskets_count"`
@@ -86,6 +246,25 @@ type DatabaseStats struct {
 	AvgBasketSize      int           `json:"avg_basket_size"`
 	TopBasketsBySize   []*BasketInfo `json:"top_baskets_size"`
 	TopBasketsByDate   []*BasketInfo `json:"top_baskets_recent"`
+
+	// counters of the service are kept in memory since service start
+	RequestsLastHour      int   `json:"requests_last_hour"`
+	RequestsLastDay       int   `json:"requests_last_day"`
+	ForwardsCount         int64 `json:"forwards_count"`
+	ForwardErrorsCount    int64 `json:"forward_errors_count"`
+	ForwardErrorsLastHour int   `json:"forward_errors_last_hour"`
+	ForwardErrorsLastDay  int   `json:"forward_errors_last_day"`
+
+	StorageBackend string `json:"storage_backend"`         // type of database: mem, bolt or sql
+	StorageBytes   int64  `json:"storage_bytes,omitempty"` // bytes used by database, 0 - unknown
+
+	TopScriptsByLatency []*ScriptStats `json:"top_scripts_latency,omitempty"`
+	TopScriptsByErrors  []*ScriptStats `json:"top_scripts_errors,omitempty"`
+
+	TopSlowOperations []*SlowOperationStats `json:"top_slow_operations,omitempty"`
+
+	StoredBytes    int64 `json:"stored_bytes,omitempty"`     // approximate bytes stored in all baskets if storage is limited
+	MaxStoredBytes int64 `json:"max_stored_bytes,omitempty"` // storage quota of the service
 }
 
 // BasketInfo describes shorlty a basket for database statistics
@@ -101,16 +280,47 @@ type Basket interface {
 	Config() BasketConfig
 	Update(config BasketConfig)
 	Authorize(token string) bool
+	SetToken(token string)
+	GetShareToken() string
+	SetShareToken(token string)
+	GetViewPassword() string
+	SetViewPassword(hash string)
+	GetAccessTokens() []AccessToken
+	SetAccessTokens(tokens []AccessToken)
+	GetACL() []ACLEntry
+	SetACL(entries []ACLEntry)
 
 	GetResponse(method string) *ResponseConfig
 	SetResponse(method string, response ResponseConfig)
 
+	GetTrigger() *TriggerConfig
+	SetTrigger(trigger TriggerConfig)
+
+	GetSchedules() []ScheduleConfig
+	SetSchedules(schedules []ScheduleConfig)
+
+	GetSecrets() map[string]string
+	SetSecret(name string, value string)
+	DeleteSecret(name string)
+
+	GetWebhooks() []WebhookConfig
+	SetWebhooks(webhooks []WebhookConfig)
+	GetWebhookDeliveries() []WebhookDelivery
+	SetWebhookDeliveries(deliveries []WebhookDelivery)
+
 	Add(req *http.Request) *RequestData
+	AddRequest(data *RequestData) *RequestData
+	UpdateRequest(id int, update func(data *RequestData))
+	DeleteRequests(ids []int) int
+	DeleteRequestsBefore(date int64) int
 	Clear()
 
 	Size() int
+	LastModified() int64
+	GetRequest(id int) *RequestData
 	GetRequests(max int, skip int) RequestsPage
-	FindRequests(query string, in string, max int, skip int) RequestsQueryPage
+	GetRequestsBefore(id int, max int) RequestsPage
+	FindRequests(query *RequestsQuery, max int, skip int) RequestsQueryPage
 }
 
 // BasketsDatabase is an interface that represent database to manage collection of request baskets
@@ -118,12 +328,16 @@ type BasketsDatabase interface {
 	Create(name string, config BasketConfig) (BasketAuth, error)
 	Get(name string) Basket
 	Delete(name string)
+	Rename(name string, newName string) error
 
 	Size() int
 	GetNames(max int, skip int) BasketNamesPage
+	GetNamesAfter(position string, max int) BasketNamesPage
 	FindNames(query string, max int, skip int) BasketNamesQueryPage
 
 	GetStats(max int) DatabaseStats
+	Compact() error
+	Ping() error
 
 	Release()
 }
@@ -157,7 +371,9 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 	}
 
 	// expand path
-	if config.ExpandPath && len(req.Path) > len(basket)+1 {
+	if config.ExpandPath && req.Subdomain {
+		forwardURL.Path = strings.TrimSuffix(forwardURL.Path, "/") + req.Path
+	} else if config.ExpandPath && len(req.Path) > len(basket)+1 {
 		forwardURL.Path = expandURL(forwardURL.Path, req.Path, basket)
 	}
 
@@ -182,7 +398,7 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 		}
 	}
 	// headers cleanup
-	forwardHeadersCleanup(forwardReq)
+	forwardHeadersCleanup(forwardReq, config.ForwardHeaders)
 	// set do not forward header
 	forwardReq.Header.Set(DoNotForwardHeader, "1")
 
@@ -203,51 +419,64 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 	return response, nil
 }
 
-// forwardHeadersCleanup removes headers that may corrupt the underlying connection when forwarding request
-func forwardHeadersCleanup(req *http.Request) {
-	// Must not be used in HTTP/2
-	req.Header.Del("Connection")
-	req.Header.Del("Upgrade")
-	req.Header.Del("TE") // only "trailers" supported in HTTP/2
-
-	// TODO: find out what else may break or corrupt the forwarding
-}
-
 func expandURL(url string, original string, basket string) string {
 	return strings.TrimSuffix(url, "/") + strings.TrimPrefix(original, "/"+basket)
 }
 
 // Matches checks if RequestData matches the search criterea.
-func (req *RequestData) Matches(query string, in string) bool {
-	// detect where to search
-	inBody := false
-	inQuery := false
-	inHeaders := false
-	switch in {
-	case "body":
-		inBody = true
-	case "query":
-		inQuery = true
-	case "headers":
-		inHeaders = true
-	default:
-		inBody = true
-		inQuery = true
-		inHeaders = true
+func (req *RequestData) Matches(query *RequestsQuery) bool {
+	if !query.InRange(req.Date) {
+		return false
+	}
+
+	if query.Before > 0 && req.ID >= query.Before {
+		return false
+	}
+
+	if len(query.Method) > 0 && !strings.EqualFold(query.Method, req.Method) {
+		return false
+	}
+
+	if query.path != nil && !query.path.MatchString(req.Path) && !query.path.MatchString(relativePath(req.Path)) {
+		return false
+	}
+
+	if !query.matchHeaders(req.Header) {
+		return false
 	}
 
-	if inBody && strings.Contains(req.Body, query) {
+	if query.filter != nil && !query.filter.Match(req) {
+		return false
+	}
+
+	// JSONPath queries are applied to request body only
+	if query.json != nil {
+		return query.json.Match(req.Body)
+	}
+
+	if len(query.Text) == 0 {
 		return true
 	}
 
-	if inQuery && strings.Contains(req.Query, query) {
+	return req.matchesIn(query.In, query.match)
+}
+
+// matchesIn checks if any of request parts (body, query or headers) is matched by given function
+func (req *RequestData) matchesIn(in string, match func(string) bool) bool {
+	inBody, inQuery, inHeaders := searchFields(in)
+
+	if inBody && match(req.Body) {
+		return true
+	}
+
+	if inQuery && match(req.Query) {
 		return true
 	}
 
 	if inHeaders {
 		for _, vals := range req.Header {
 			for _, val := range vals {
-				if strings.Contains(val, query) {
+				if match(val) {
 					return true
 				}
 			}
@@ -257,6 +486,366 @@ func (req *RequestData) Matches(query string, in string) bool {
 	return false
 }
 
+// searchFields detects where to search: in request body, query or headers
+func searchFields(in string) (inBody bool, inQuery bool, inHeaders bool) {
+	switch in {
+	case "body":
+		return true, false, false
+	case "query":
+		return false, true, false
+	case "headers":
+		return false, false, true
+	default:
+		return true, true, true
+	}
+}
+
+// NewTextQuery creates a query to search collected requests by substring
+func NewTextQuery(text string, in string) *RequestsQuery {
+	return &RequestsQuery{Text: text, In: in, Type: QueryTypeSubstring}
+}
+
+// NewRequestsQuery creates a query to search collected requests, the query text is interpreted according to query type
+func NewRequestsQuery(text string, in string, queryType string) (*RequestsQuery, error) {
+	if len(text) == 0 {
+		return NewTextQuery(text, in), nil
+	}
+
+	switch queryType {
+	case "", QueryTypeSubstring:
+		return NewTextQuery(text, in), nil
+	case QueryTypeRegex:
+		regex, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %s", err)
+		}
+		return &RequestsQuery{Text: text, In: in, Type: QueryTypeRegex, regex: regex}, nil
+	case QueryTypeJSONPath:
+		json, err := parseJSONPath(text)
+		if err != nil {
+			return nil, err
+		}
+		return &RequestsQuery{Text: text, In: "body", Type: QueryTypeJSONPath, json: json}, nil
+	default:
+		return nil, fmt.Errorf("unknown query type: %s", queryType)
+	}
+}
+
+// SetOptions enables case-insensitive and whole-word matching of query text and text conditions of filter expression,
+// the options are ignored by JSONPath queries
+func (query *RequestsQuery) SetOptions(ignoreCase bool, wholeWord bool) {
+	query.IgnoreCase = ignoreCase
+	query.WholeWord = wholeWord
+	if query.filter != nil {
+		// filter expression is already validated, so it is parsed again without errors
+		if expr, err := parseFilterOptions(query.filterText, textOptions{ignoreCase, wholeWord}); err == nil {
+			query.filter = expr
+		}
+	}
+	if len(query.Text) == 0 || query.Type == QueryTypeJSONPath {
+		return
+	}
+
+	expr := query.Text
+	if query.Type == QueryTypeSubstring {
+		if !ignoreCase && !wholeWord {
+			query.regex = nil
+			return
+		}
+		expr = regexp.QuoteMeta(expr)
+	}
+	// original expression is already validated, so the extended one is valid too
+	query.regex = regexp.MustCompile(textExpr(expr, ignoreCase, wholeWord))
+}
+
+// textExpr extends regular expression with case-insensitive and whole-word matching
+func textExpr(expr string, ignoreCase bool, wholeWord bool) string {
+	if wholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return expr
+}
+
+// SetPath sets glob pattern to filter requests by path, "*" matches any sequence of characters including "/",
+// "?" matches a single character; the pattern is matched against request path either with or without basket name
+func (query *RequestsQuery) SetPath(pattern string) {
+	query.Path = pattern
+	if len(pattern) == 0 {
+		query.path = nil
+		return
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	query.path = regexp.MustCompile("^" + expr + "$")
+}
+
+// AddHeader adds header filter given in format "Name:value" or "Name" to check header presence only
+func (query *RequestsQuery) AddHeader(filter string) error {
+	parts := strings.SplitN(filter, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(name) == 0 {
+		return fmt.Errorf("invalid header filter: '%s', expected format is Name:value", filter)
+	}
+
+	value := ""
+	if len(parts) > 1 {
+		value = strings.TrimSpace(parts[1])
+	}
+
+	if query.Headers == nil {
+		query.Headers = make(http.Header)
+	}
+	query.Headers.Add(name, value)
+
+	return nil
+}
+
+func (query *RequestsQuery) matchHeaders(header http.Header) bool {
+	for name, expected := range query.Headers {
+		actual, exists := header[name]
+		if !exists {
+			return false
+		}
+		for _, value := range expected {
+			if !containsValue(actual, value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if strings.Contains(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath strips basket name from request path, e.g. "/basket/callback/1" -> "/callback/1"
+func relativePath(path string) string {
+	if i := strings.Index(strings.TrimPrefix(path, "/"), "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return "/"
+}
+
+// SetSort sets sort order of found requests
+func (query *RequestsQuery) SetSort(order string) error {
+	switch order {
+	case "", SortNewest, SortOldest, SortContentLength, SortForwardLatency:
+		query.Sort = order
+		return nil
+	default:
+		return fmt.Errorf("unknown sort order: %s", order)
+	}
+}
+
+// IsSorted checks if query requires other order of found requests than newest first,
+// which is the natural order of requests in a basket
+func (query *RequestsQuery) IsSorted() bool {
+	return len(query.Sort) > 0 && query.Sort != SortNewest
+}
+
+// InRange checks if date of request is within date range of the query
+func (query *RequestsQuery) InRange(date int64) bool {
+	return (query.From == 0 || date >= query.From) && (query.To == 0 || date <= query.To)
+}
+
+func (query *RequestsQuery) match(value string) bool {
+	if query.regex != nil {
+		return query.regex.MatchString(value)
+	}
+	return strings.Contains(value, query.Text)
+}
+
+// FindSortedRequests finds requests of a basket that match the query and returns a page of requests sorted
+// according to query sort order; custom orders require all matching requests to be fetched from the basket
+func FindSortedRequests(basket Basket, query *RequestsQuery, max int, skip int) RequestsQueryPage {
+	if !query.IsSorted() {
+		return basket.FindRequests(query, max, skip)
+	}
+
+	size := basket.Size()
+	if size <= 0 || max <= 0 {
+		return RequestsQueryPage{Requests: make([]*RequestData, 0), HasMore: size > 0}
+	}
+
+	unsorted := *query
+	unsorted.Sort = ""
+	requests := basket.FindRequests(&unsorted, size, 0).Requests
+
+	// requests are found from newest to oldest, stable sorting keeps newer requests first for equal keys
+	switch query.Sort {
+	case SortOldest:
+		for i, j := 0, len(requests)-1; i < j; i, j = i+1, j-1 {
+			requests[i], requests[j] = requests[j], requests[i]
+		}
+	case SortContentLength:
+		sort.SliceStable(requests, func(i, j int) bool { return requests[i].ContentLength > requests[j].ContentLength })
+	case SortForwardLatency:
+		sort.SliceStable(requests, func(i, j int) bool { return requests[i].ForwardLatency > requests[j].ForwardLatency })
+	}
+
+	page := RequestsQueryPage{Requests: make([]*RequestData, 0, pageCapacity(max))}
+	if skip < len(requests) {
+		last := skip + max
+		if last > len(requests) {
+			last = len(requests)
+		}
+		page.Requests = append(page.Requests, requests[skip:last]...)
+		page.HasMore = last < len(requests)
+	}
+
+	return page
+}
+
+// FindRequestsInBaskets searches requests across all baskets of database and returns a page of baskets
+// with found requests grouped by basket; max and skip define the page of baskets that have matching requests,
+// maxRequests limits number of requests returned per basket
+func FindRequestsInBaskets(db BasketsDatabase, query *RequestsQuery, max int, skip int, maxRequests int) SearchResultsPage {
+	page := SearchResultsPage{Baskets: make([]*BasketRequestsQueryPage, 0, max)}
+
+	skipped := 0
+	for offset := 0; ; offset += searchNamesChunk {
+		names := db.GetNames(searchNamesChunk, offset)
+		for _, name := range names.Names {
+			basket := db.Get(name)
+			if basket == nil {
+				// basket is deleted in the meantime
+				continue
+			}
+
+			found := FindSortedRequests(basket, query, maxRequests, 0)
+			if len(found.Requests) == 0 {
+				continue
+			}
+
+			if skipped < skip {
+				skipped++
+			} else if len(page.Baskets) < max {
+				page.Baskets = append(page.Baskets, &BasketRequestsQueryPage{name, found.Requests, found.HasMore})
+			} else {
+				page.HasMore = true
+				return page
+			}
+		}
+
+		if !names.HasMore {
+			return page
+		}
+	}
+}
+
+// CopyBasket creates new basket with configuration, responses, scripts, secrets and webhooks of the source basket,
+// collected requests are copied as well if requested; the new basket gets its own token
+func CopyBasket(db BasketsDatabase, source Basket, name string, withRequests bool) (BasketAuth, error) {
+	auth, err := db.Create(name, source.Config())
+	if err != nil {
+		return auth, err
+	}
+
+	basket := db.Get(name)
+	if basket == nil {
+		return auth, fmt.Errorf("failed to locate cloned basket: %s", name)
+	}
+
+	for _, method := range httpMethods {
+		if response := source.GetResponse(method); response != nil {
+			basket.SetResponse(method, *response)
+		}
+	}
+	if trigger := source.GetTrigger(); trigger != nil {
+		basket.SetTrigger(*trigger)
+	}
+	if schedules := source.GetSchedules(); len(schedules) > 0 {
+		basket.SetSchedules(schedules)
+	}
+	for secret, value := range source.GetSecrets() {
+		basket.SetSecret(secret, value)
+	}
+	if subscriptions := source.GetWebhooks(); len(subscriptions) > 0 {
+		basket.SetWebhooks(subscriptions)
+	}
+
+	if withRequests {
+		// copy requests from oldest to newest, so they keep their order in the new basket
+		requests := source.GetRequests(source.Size(), 0).Requests
+		for i := len(requests) - 1; i >= 0; i-- {
+			data := *requests[i]
+			basket.AddRequest(&data)
+		}
+	}
+
+	return auth, nil
+}
+
+// DeleteSelectedRequests deletes requests of a basket with given IDs or requests matching the query,
+// if both IDs and query are specified only requests with given IDs that also match the query are deleted;
+// returns number of deleted requests
+func DeleteSelectedRequests(basket Basket, ids []int, query *RequestsQuery) int {
+	if query != nil {
+		selected := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			selected[id] = true
+		}
+
+		found := basket.FindRequests(query, basket.Size(), 0).Requests
+		ids = make([]int, 0, len(found))
+		for _, request := range found {
+			if len(selected) == 0 || selected[request.ID] {
+				ids = append(ids, request.ID)
+			}
+		}
+	}
+
+	return basket.DeleteRequests(ids)
+}
+
+// encodeCursor converts backend specific position of the last item on a page into opaque cursor token
+func encodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// DecodeCursor converts cursor token into backend specific position of the last item on a previous page
+func DecodeCursor(cursor string) (string, error) {
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(position) == 0 {
+		return "", fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return string(position), nil
+}
+
+// requestsCursor returns cursor token to get requests that are older than the last request of a page,
+// requests IDs are ascending within basket, so the cursor stays valid while new requests are collected
+func requestsCursor(requests []*RequestData) string {
+	if len(requests) == 0 {
+		return ""
+	}
+	return encodeCursor(strconv.Itoa(requests[len(requests)-1].ID))
+}
+
+// DecodeRequestsCursor converts cursor token into ID of the last request on a previous page
+func DecodeRequestsCursor(cursor string) (int, error) {
+	position, err := DecodeCursor(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(position)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+	return id, nil
+}
+
 // Collect collects information about basket and updates statistics
 func (stats *DatabaseStats) Collect(basket *BasketInfo, max int) {
 	stats.BasketsCount++
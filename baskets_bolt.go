@@ -1,11 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,17 +21,39 @@ const (
 	boltOptExpandPath = 1 << iota
 	boltOptInsecureTLS
 	boltOptProxyResponse
+	boltOptHashChain
 )
 
 var (
 	boltKeyToken      = []byte("token")
+	boltKeyShareToken = []byte("share")
+	boltKeyPassword   = []byte("password")
+	boltKeyTokens     = []byte("tokens")
+	boltKeyACL        = []byte("acl")
 	boltKeyForwardURL = []byte("url")
 	boltKeyOptions    = []byte("opts")
+	boltKeyRateLimit  = []byte("ratelimit")
+	boltKeyBodyLimit  = []byte("bodylimit")
+	boltKeyRedaction  = []byte("redaction")
+	boltKeySignature  = []byte("signature")
+	boltKeyFwdHeaders = []byte("fwdheaders")
+	boltKeyEncryption = []byte("encryption")
+	boltKeyIgnore     = []byte("ignore")
+	boltKeyRetention  = []byte("retention")
+	boltKeyExpires    = []byte("expires")
+	boltKeyPause      = []byte("pause")
 	boltKeyCapacity   = []byte("capacity")
 	boltKeyTotalCount = []byte("total")
 	boltKeyCount      = []byte("count")
 	boltKeyRequests   = []byte("requests")
 	boltKeyResponses  = []byte("responses")
+	boltKeyTrigger    = []byte("trigger")
+	boltKeySchedules  = []byte("schedules")
+	boltKeySecrets    = []byte("secrets")
+	boltKeyWebhooks   = []byte("webhooks")
+	boltKeyDeliveries = []byte("deliveries")
+	boltKeyIndex      = []byte("index")
+	boltKeyModified   = []byte("modified")
 )
 
 func itob(i int) []byte {
@@ -42,6 +66,13 @@ func btoi(b []byte) int {
 	return int(binary.BigEndian.Uint32(b))
 }
 
+// touch records the time of change of collected requests
+func touch(b *bolt.Bucket) error {
+	modified := make([]byte, 8)
+	binary.BigEndian.PutUint64(modified, uint64(time.Now().UnixNano()/toMs))
+	return b.Put(boltKeyModified, modified)
+}
+
 func toOpts(config BasketConfig) []byte {
 	opts := byte(0)
 	if config.ExpandPath {
@@ -53,6 +84,9 @@ func toOpts(config BasketConfig) []byte {
 	if config.ProxyResponse {
 		opts |= boltOptProxyResponse
 	}
+	if config.HashChain {
+		opts |= boltOptHashChain
+	}
 
 	return []byte{opts}
 }
@@ -62,6 +96,7 @@ func fromOpts(opts []byte, config *BasketConfig) {
 		config.ExpandPath = opts[0]&boltOptExpandPath != 0
 		config.InsecureTLS = opts[0]&boltOptInsecureTLS != 0
 		config.ProxyResponse = opts[0]&boltOptProxyResponse != 0
+		config.HashChain = opts[0]&boltOptHashChain != 0
 	} else {
 		config.ExpandPath = false
 		config.InsecureTLS = false
@@ -69,6 +104,116 @@ func fromOpts(opts []byte, config *BasketConfig) {
 	}
 }
 
+func putRateLimit(b *bolt.Bucket, limit *CaptureLimit) error {
+	if limit == nil {
+		return b.Delete(boltKeyRateLimit)
+	}
+
+	limitj, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyRateLimit, limitj)
+}
+
+func putBodyLimit(b *bolt.Bucket, limit *BodyLimit) error {
+	if limit == nil {
+		return b.Delete(boltKeyBodyLimit)
+	}
+
+	limitj, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyBodyLimit, limitj)
+}
+
+func putRedaction(b *bolt.Bucket, rules []RedactionRule) error {
+	if len(rules) == 0 {
+		return b.Delete(boltKeyRedaction)
+	}
+
+	rulesj, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyRedaction, rulesj)
+}
+
+func putSignature(b *bolt.Bucket, check *SignatureCheck) error {
+	if check == nil {
+		return b.Delete(boltKeySignature)
+	}
+
+	checkj, err := json.Marshal(check)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeySignature, checkj)
+}
+
+func putForwardHeaders(b *bolt.Bucket, headers *ForwardHeaders) error {
+	if headers == nil {
+		return b.Delete(boltKeyFwdHeaders)
+	}
+
+	headersj, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyFwdHeaders, headersj)
+}
+
+func putEncryption(b *bolt.Bucket, encryption *EncryptionKey) error {
+	if encryption == nil {
+		return b.Delete(boltKeyEncryption)
+	}
+
+	encryptionj, err := json.Marshal(encryption)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyEncryption, encryptionj)
+}
+
+func putIgnore(b *bolt.Bucket, filters []string) error {
+	if len(filters) == 0 {
+		return b.Delete(boltKeyIgnore)
+	}
+
+	filtersj, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyIgnore, filtersj)
+}
+
+func putPause(b *bolt.Bucket, pause *BasketPause) error {
+	if pause == nil {
+		return b.Delete(boltKeyPause)
+	}
+
+	pausej, err := json.Marshal(pause)
+	if err != nil {
+		return err
+	}
+	return b.Put(boltKeyPause, pausej)
+}
+
+func putRetention(b *bolt.Bucket, retention int) error {
+	if retention <= 0 {
+		return b.Delete(boltKeyRetention)
+	}
+	return b.Put(boltKeyRetention, itob(retention))
+}
+
+func putExpiresAfter(b *bolt.Bucket, expiresAfter int) error {
+	if expiresAfter <= 0 {
+		return b.Delete(boltKeyExpires)
+	}
+	return b.Put(boltKeyExpires, itob(expiresAfter))
+}
+
 /// Basket interface ///
 
 type boltBasket struct {
@@ -112,8 +257,52 @@ func (basket *boltBasket) Config() BasketConfig {
 	basket.view(func(b *bolt.Bucket) error {
 		config.ForwardURL = string(b.Get(boltKeyForwardURL))
 		config.Capacity = btoi(b.Get(boltKeyCapacity))
+		if retention := b.Get(boltKeyRetention); retention != nil {
+			config.Retention = btoi(retention)
+		}
+		if expires := b.Get(boltKeyExpires); expires != nil {
+			config.ExpiresAfter = btoi(expires)
+		}
 
 		fromOpts(b.Get(boltKeyOptions), &config)
+		if limitj := b.Get(boltKeyRateLimit); limitj != nil {
+			if err := json.Unmarshal(limitj, &config.RateLimit); err != nil {
+				return err
+			}
+		}
+		if limitj := b.Get(boltKeyBodyLimit); limitj != nil {
+			if err := json.Unmarshal(limitj, &config.BodyLimit); err != nil {
+				return err
+			}
+		}
+		if pausej := b.Get(boltKeyPause); pausej != nil {
+			if err := json.Unmarshal(pausej, &config.Pause); err != nil {
+				return err
+			}
+		}
+		if rulesj := b.Get(boltKeyRedaction); rulesj != nil {
+			if err := json.Unmarshal(rulesj, &config.Redaction); err != nil {
+				return err
+			}
+		}
+		if checkj := b.Get(boltKeySignature); checkj != nil {
+			if err := json.Unmarshal(checkj, &config.Signature); err != nil {
+				return err
+			}
+		}
+		if headersj := b.Get(boltKeyFwdHeaders); headersj != nil {
+			if err := json.Unmarshal(headersj, &config.ForwardHeaders); err != nil {
+				return err
+			}
+		}
+		if encryptionj := b.Get(boltKeyEncryption); encryptionj != nil {
+			if err := json.Unmarshal(encryptionj, &config.Encryption); err != nil {
+				return err
+			}
+		}
+		if filtersj := b.Get(boltKeyIgnore); filtersj != nil {
+			return json.Unmarshal(filtersj, &config.Ignore)
+		}
 
 		return nil
 	})
@@ -129,35 +318,157 @@ func (basket *boltBasket) Update(config BasketConfig) {
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		putRateLimit(b, config.RateLimit)
+		putBodyLimit(b, config.BodyLimit)
+		putRedaction(b, config.Redaction)
+		putSignature(b, config.Signature)
+		putForwardHeaders(b, config.ForwardHeaders)
+		putEncryption(b, config.Encryption)
+		putIgnore(b, config.Ignore)
+		putRetention(b, config.Retention)
+		putExpiresAfter(b, config.ExpiresAfter)
+		putPause(b, config.Pause)
 
 		if oldCap != config.Capacity && curCount > config.Capacity {
 			// remove overflow requests
 			remCount := curCount - config.Capacity
 
 			reqsCur := b.Bucket(boltKeyRequests).Cursor()
-			reqsCur.First()
 			for i := 0; i < remCount; i++ {
+				key, val := reqsCur.First()
+				if key == nil {
+					break
+				}
+				unindexRequest(b, key, val)
 				reqsCur.Delete()
-				reqsCur.Next()
 			}
 
 			// update count
 			b.Put(boltKeyCount, itob(config.Capacity))
 		}
 
-		return nil
+		return touch(b)
 	})
 }
 
 func (basket *boltBasket) Authorize(token string) bool {
-	result := false
+	var stored string
 
 	basket.view(func(b *bolt.Bucket) error {
-		result = string(b.Get(boltKeyToken)) == token
+		stored = string(b.Get(boltKeyToken))
 		return nil
 	})
 
-	return result
+	valid, outdated := verifyBasketToken(stored, token)
+	if outdated {
+		// replace token stored by previous versions with its hash
+		hash := hashBasketToken(token)
+		basket.update(func(b *bolt.Bucket) error {
+			if string(b.Get(boltKeyToken)) == stored {
+				return b.Put(boltKeyToken, []byte(hash))
+			}
+			return nil
+		})
+	}
+
+	return valid
+}
+
+func (basket *boltBasket) SetToken(token string) {
+	hash := hashBasketToken(token)
+	basket.update(func(b *bolt.Bucket) error {
+		return b.Put(boltKeyToken, []byte(hash))
+	})
+}
+
+func (basket *boltBasket) GetShareToken() string {
+	var token string
+
+	basket.view(func(b *bolt.Bucket) error {
+		token = string(b.Get(boltKeyShareToken))
+		return nil
+	})
+
+	return token
+}
+
+func (basket *boltBasket) SetShareToken(token string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if len(token) == 0 {
+			return b.Delete(boltKeyShareToken)
+		}
+		return b.Put(boltKeyShareToken, []byte(token))
+	})
+}
+
+func (basket *boltBasket) GetViewPassword() string {
+	var hash string
+
+	basket.view(func(b *bolt.Bucket) error {
+		hash = string(b.Get(boltKeyPassword))
+		return nil
+	})
+
+	return hash
+}
+
+func (basket *boltBasket) SetViewPassword(hash string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if len(hash) == 0 {
+			return b.Delete(boltKeyPassword)
+		}
+		return b.Put(boltKeyPassword, []byte(hash))
+	})
+}
+
+func (basket *boltBasket) GetAccessTokens() []AccessToken {
+	var tokens []AccessToken
+
+	basket.view(func(b *bolt.Bucket) error {
+		if tokensj := b.Get(boltKeyTokens); tokensj != nil {
+			return json.Unmarshal(tokensj, &tokens)
+		}
+
+		return nil
+	})
+
+	return tokens
+}
+
+func (basket *boltBasket) SetAccessTokens(tokens []AccessToken) {
+	basket.update(func(b *bolt.Bucket) error {
+		tokensj, err := json.Marshal(tokens)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(boltKeyTokens, tokensj)
+	})
+}
+
+func (basket *boltBasket) GetACL() []ACLEntry {
+	var entries []ACLEntry
+
+	basket.view(func(b *bolt.Bucket) error {
+		if aclj := b.Get(boltKeyACL); aclj != nil {
+			return json.Unmarshal(aclj, &entries)
+		}
+
+		return nil
+	})
+
+	return entries
+}
+
+func (basket *boltBasket) SetACL(entries []ACLEntry) {
+	basket.update(func(b *bolt.Bucket) error {
+		aclj, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(boltKeyACL, aclj)
+	})
 }
 
 func (basket *boltBasket) GetResponse(method string) *ResponseConfig {
@@ -200,19 +511,176 @@ func (basket *boltBasket) SetResponse(method string, response ResponseConfig) {
 	})
 }
 
+func (basket *boltBasket) GetTrigger() *TriggerConfig {
+	var trigger *TriggerConfig
+
+	basket.view(func(b *bolt.Bucket) error {
+		if trig := b.Get(boltKeyTrigger); trig != nil {
+			// parse trigger configuration
+			trigger = new(TriggerConfig)
+			if err := json.Unmarshal(trig, trigger); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return trigger
+}
+
+func (basket *boltBasket) SetTrigger(trigger TriggerConfig) {
+	basket.update(func(b *bolt.Bucket) error {
+		trigj, err := json.Marshal(trigger)
+		if err != nil {
+			return err
+		}
+
+		// save configuration
+		return b.Put(boltKeyTrigger, trigj)
+	})
+}
+
+func (basket *boltBasket) GetSchedules() []ScheduleConfig {
+	var schedules []ScheduleConfig
+
+	basket.view(func(b *bolt.Bucket) error {
+		if scheds := b.Get(boltKeySchedules); scheds != nil {
+			return json.Unmarshal(scheds, &schedules)
+		}
+
+		return nil
+	})
+
+	return schedules
+}
+
+func (basket *boltBasket) SetSchedules(schedules []ScheduleConfig) {
+	basket.update(func(b *bolt.Bucket) error {
+		schedj, err := json.Marshal(schedules)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(boltKeySchedules, schedj)
+	})
+}
+
+func (basket *boltBasket) GetSecrets() map[string]string {
+	secrets := make(map[string]string)
+
+	basket.view(func(b *bolt.Bucket) error {
+		if secs := b.Bucket(boltKeySecrets); secs != nil {
+			return secs.ForEach(func(k, v []byte) error {
+				secrets[string(k)] = string(v)
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return secrets
+}
+
+func (basket *boltBasket) SetSecret(name string, value string) {
+	basket.update(func(b *bolt.Bucket) error {
+		secs, err := b.CreateBucketIfNotExists(boltKeySecrets)
+		if err != nil {
+			return err
+		}
+
+		return secs.Put([]byte(name), []byte(value))
+	})
+}
+
+func (basket *boltBasket) DeleteSecret(name string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if secs := b.Bucket(boltKeySecrets); secs != nil {
+			return secs.Delete([]byte(name))
+		}
+
+		return nil
+	})
+}
+
+func (basket *boltBasket) GetWebhooks() []WebhookConfig {
+	var webhooks []WebhookConfig
+
+	basket.view(func(b *bolt.Bucket) error {
+		if hooks := b.Get(boltKeyWebhooks); hooks != nil {
+			return json.Unmarshal(hooks, &webhooks)
+		}
+
+		return nil
+	})
+
+	return webhooks
+}
+
+func (basket *boltBasket) SetWebhooks(webhooks []WebhookConfig) {
+	basket.update(func(b *bolt.Bucket) error {
+		hooksj, err := json.Marshal(webhooks)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(boltKeyWebhooks, hooksj)
+	})
+}
+
+func (basket *boltBasket) GetWebhookDeliveries() []WebhookDelivery {
+	var deliveries []WebhookDelivery
+
+	basket.view(func(b *bolt.Bucket) error {
+		if deliveriesj := b.Get(boltKeyDeliveries); deliveriesj != nil {
+			return json.Unmarshal(deliveriesj, &deliveries)
+		}
+
+		return nil
+	})
+
+	return deliveries
+}
+
+func (basket *boltBasket) SetWebhookDeliveries(deliveries []WebhookDelivery) {
+	basket.update(func(b *bolt.Bucket) error {
+		deliveriesj, err := json.Marshal(deliveries)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(boltKeyDeliveries, deliveriesj)
+	})
+}
+
 func (basket *boltBasket) Add(req *http.Request) *RequestData {
-	data := ToRequestData(req)
+	return basket.AddRequest(ToRequestData(req))
+}
 
+func (basket *boltBasket) AddRequest(data *RequestData) *RequestData {
 	basket.update(func(b *bolt.Bucket) error {
 		reqs := b.Bucket(boltKeyRequests)
 
-		dataj, err := json.Marshal(data)
+		// total counter is never reset, so it gives unique and ascending request IDs within basket
+		data.ID = btoi(b.Get(boltKeyTotalCount)) + 1
+		dataj, err := encodeStoredRequest(data)
 		if err != nil {
 			return err
 		}
 
-		key, _ := reqs.NextSequence()
-		err = reqs.Put(itob(int(key)), dataj)
+		key := itob(data.ID)
+		err = reqs.Put(key, dataj)
+		if err != nil {
+			return err
+		}
+
+		// update token index, build it if missing (e.g. basket is created by older version)
+		if b.Bucket(boltKeyIndex) == nil {
+			err = buildIndex(b)
+		} else {
+			err = indexRequest(b, key, data)
+		}
 		if err != nil {
 			return err
 		}
@@ -233,7 +701,8 @@ func (basket *boltBasket) Add(req *http.Request) *RequestData {
 		} else {
 			// do not increase counter, just remove 1 entry
 			cur := reqs.Cursor()
-			cur.First()
+			key, val := cur.First()
+			unindexRequest(b, key, val)
 			cur.Delete()
 
 			if count > cap {
@@ -242,12 +711,120 @@ func (basket *boltBasket) Add(req *http.Request) *RequestData {
 			}
 		}
 
-		return nil
+		return touch(b)
 	})
 
 	return data
 }
 
+func (basket *boltBasket) UpdateRequest(id int, update func(data *RequestData)) {
+	basket.update(func(b *bolt.Bucket) error {
+		reqs := b.Bucket(boltKeyRequests)
+		key := itob(id)
+		val := reqs.Get(key)
+		if val == nil {
+			// request is already evicted
+			return nil
+		}
+
+		data, err := toRequestData(key, val)
+		if err != nil {
+			return err
+		}
+		update(data)
+		dataj, err := encodeStoredRequest(data)
+		if err != nil {
+			return err
+		}
+
+		unindexRequest(b, key, val)
+		if err = reqs.Put(key, dataj); err != nil {
+			return err
+		}
+		if err = touch(b); err != nil {
+			return err
+		}
+
+		// missing token index is built by the next collected request
+		if b.Bucket(boltKeyIndex) == nil {
+			return nil
+		}
+		return indexRequest(b, key, data)
+	})
+}
+
+func (basket *boltBasket) DeleteRequests(ids []int) int {
+	deleted := 0
+
+	basket.update(func(b *bolt.Bucket) error {
+		deleted = 0
+		reqs := b.Bucket(boltKeyRequests)
+		for _, id := range ids {
+			key := itob(id)
+			val := reqs.Get(key)
+			if val == nil {
+				// unknown or already evicted request
+				continue
+			}
+
+			unindexRequest(b, key, val)
+			if err := reqs.Delete(key); err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		if deleted > 0 {
+			if err := touch(b); err != nil {
+				return err
+			}
+			return b.Put(boltKeyCount, itob(btoi(b.Get(boltKeyCount))-deleted))
+		}
+		return nil
+	})
+
+	return deleted
+}
+
+func (basket *boltBasket) DeleteRequestsBefore(date int64) int {
+	deleted := 0
+
+	basket.update(func(b *bolt.Bucket) error {
+		deleted = 0
+		reqs := b.Bucket(boltKeyRequests)
+
+		// requests may be copied from other baskets with older dates, so all requests are checked
+		expired := make([][]byte, 0)
+		reqs.ForEach(func(key []byte, val []byte) error {
+			data := struct {
+				Date int64 `json:"date"`
+			}{}
+			if err := json.Unmarshal(val, &data); err == nil && data.Date < date {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+
+		for _, key := range expired {
+			unindexRequest(b, key, reqs.Get(key))
+			if err := reqs.Delete(key); err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		if deleted > 0 {
+			if err := touch(b); err != nil {
+				return err
+			}
+			return b.Put(boltKeyCount, itob(btoi(b.Get(boltKeyCount))-deleted))
+		}
+		return nil
+	})
+
+	return deleted
+}
+
 func (basket *boltBasket) Clear() {
 	basket.update(func(b *bolt.Bucket) error {
 		err := b.DeleteBucket(boltKeyRequests)
@@ -259,7 +836,13 @@ func (basket *boltBasket) Clear() {
 		b.Put(boltKeyCount, itob(0))
 		b.CreateBucket(boltKeyRequests)
 
-		return nil
+		// reset token index
+		if b.Bucket(boltKeyIndex) != nil {
+			b.DeleteBucket(boltKeyIndex)
+		}
+		b.CreateBucket(boltKeyIndex)
+
+		return touch(b)
 	})
 }
 
@@ -275,9 +858,39 @@ func (basket *boltBasket) Size() int {
 	return result
 }
 
+func (basket *boltBasket) LastModified() int64 {
+	var modified int64
+
+	basket.view(func(b *bolt.Bucket) error {
+		if val := b.Get(boltKeyModified); len(val) == 8 {
+			modified = int64(binary.BigEndian.Uint64(val))
+		}
+
+		return nil
+	})
+
+	return modified
+}
+
+func (basket *boltBasket) GetRequest(id int) *RequestData {
+	var request *RequestData
+
+	basket.view(func(b *bolt.Bucket) error {
+		key := itob(id)
+		if val := b.Bucket(boltKeyRequests).Get(key); val != nil {
+			var err error
+			request, err = toRequestData(key, val)
+			return err
+		}
+		return nil
+	})
+
+	return request
+}
+
 func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 	last := skip + max
-	page := RequestsPage{make([]*RequestData, 0, max), 0, 0, false}
+	page := RequestsPage{make([]*RequestData, 0, pageCapacity(max)), 0, 0, false, ""}
 
 	basket.view(func(b *bolt.Bucket) error {
 		page.TotalCount = btoi(b.Get(boltKeyTotalCount))
@@ -287,8 +900,8 @@ func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 		index := 0
 		for key, val := cur.Last(); key != nil; key, val = cur.Prev() {
 			if index >= skip && index < last {
-				request := new(RequestData)
-				if err := json.Unmarshal(val, request); err != nil {
+				request, err := toRequestData(key, val)
+				if err != nil {
 					return err
 				}
 				page.Requests = append(page.Requests, request)
@@ -302,23 +915,73 @@ func (basket *boltBasket) GetRequests(max int, skip int) RequestsPage {
 		return nil
 	})
 
+	if page.HasMore {
+		page.NextCursor = requestsCursor(page.Requests)
+	}
+
+	return page
+}
+
+func (basket *boltBasket) GetRequestsBefore(id int, max int) RequestsPage {
+	page := RequestsPage{make([]*RequestData, 0, pageCapacity(max)), 0, 0, false, ""}
+
+	basket.view(func(b *bolt.Bucket) error {
+		page.TotalCount = btoi(b.Get(boltKeyTotalCount))
+		page.Count = btoi(b.Get(boltKeyCount))
+
+		cur := b.Bucket(boltKeyRequests).Cursor()
+		for key, val := seekBefore(cur, id); key != nil; key, val = cur.Prev() {
+			if len(page.Requests) >= max {
+				page.HasMore = true
+				break
+			}
+			request, err := toRequestData(key, val)
+			if err != nil {
+				return err
+			}
+			page.Requests = append(page.Requests, request)
+		}
+
+		return nil
+	})
+
+	if page.HasMore {
+		page.NextCursor = requestsCursor(page.Requests)
+	}
+
 	return page
 }
 
-func (basket *boltBasket) FindRequests(query string, in string, max int, skip int) RequestsQueryPage {
-	page := RequestsQueryPage{make([]*RequestData, 0, max), false}
+// seekBefore moves cursor of requests to the newest request with ID less than given one, 0 - no limit
+func seekBefore(cur *bolt.Cursor, id int) ([]byte, []byte) {
+	if id <= 0 {
+		return cur.Last()
+	}
+	if key, _ := cur.Seek(itob(id)); key == nil {
+		return cur.Last()
+	}
+	return cur.Prev()
+}
+
+func (basket *boltBasket) FindRequests(query *RequestsQuery, max int, skip int) RequestsQueryPage {
+	page := RequestsQueryPage{make([]*RequestData, 0, pageCapacity(max)), false, "", nil}
 
 	basket.view(func(b *bolt.Bucket) error {
+		// narrow down the search with token index if possible
+		if tokens, ok := query.IndexTokens(); ok && b.Bucket(boltKeyIndex) != nil {
+			return findIndexedRequests(b, query, tokens, max, skip, &page)
+		}
+
 		cur := b.Bucket(boltKeyRequests).Cursor()
 		skipped := 0
-		for key, val := cur.Last(); key != nil; key, val = cur.Prev() {
-			request := new(RequestData)
-			if err := json.Unmarshal(val, request); err != nil {
+		for key, val := seekBefore(cur, query.Before); key != nil; key, val = cur.Prev() {
+			request, err := toRequestData(key, val)
+			if err != nil {
 				return err
 			}
 
-			// filter
-			if request.Matches(query, in) {
+			// filter, all requests are checked since imported and restored requests keep their original dates
+			if request.Matches(query) {
 				if skipped < skip {
 					skipped++
 				} else {
@@ -338,9 +1001,152 @@ func (basket *boltBasket) FindRequests(query string, in string, max int, skip in
 		return nil
 	})
 
+	if page.HasMore {
+		page.NextCursor = requestsCursor(page.Requests)
+	}
+
 	return page
 }
 
+// findIndexedRequests finds requests among candidates selected by token index
+func findIndexedRequests(b *bolt.Bucket, query *RequestsQuery, tokens []string, max int, skip int, page *RequestsQueryPage) error {
+	reqs := b.Bucket(boltKeyRequests)
+	candidates := indexCandidates(b.Bucket(boltKeyIndex), tokens)
+
+	skipped := 0
+	for i, key := range candidates {
+		val := reqs.Get(key)
+		if val == nil {
+			continue
+		}
+
+		request, err := toRequestData(key, val)
+		if err != nil {
+			return err
+		}
+
+		// filter
+		if request.Matches(query) {
+			if skipped < skip {
+				skipped++
+			} else {
+				page.Requests = append(page.Requests, request)
+			}
+		}
+
+		// early exit
+		if len(page.Requests) == max {
+			page.HasMore = i < len(candidates)-1
+			break
+		}
+	}
+
+	return nil
+}
+
+// indexCandidates returns keys of requests that contain every query token as a substring of some of their tokens,
+// keys are sorted from newest to oldest request
+func indexCandidates(idx *bolt.Bucket, tokens []string) [][]byte {
+	var result map[string]bool
+	for _, token := range uniqueStrings(tokens) {
+		found := make(map[string]bool)
+		idx.ForEach(func(indexed []byte, _ []byte) error {
+			if strings.Contains(string(indexed), token) {
+				idx.Bucket(indexed).ForEach(func(key []byte, _ []byte) error {
+					if result == nil || result[string(key)] {
+						found[string(key)] = true
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+
+		result = found
+		if len(result) == 0 {
+			break
+		}
+	}
+
+	keys := make([][]byte, 0, len(result))
+	for key := range result {
+		keys = append(keys, []byte(key))
+	}
+	// keys are big-endian sequence numbers
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) > 0 })
+
+	return keys
+}
+
+// indexRequest adds request to token index of basket
+func indexRequest(b *bolt.Bucket, key []byte, data *RequestData) error {
+	idx, err := b.CreateBucketIfNotExists(boltKeyIndex)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range data.IndexTokens() {
+		posting, err := idx.CreateBucketIfNotExists([]byte(token))
+		if err != nil {
+			return err
+		}
+		if err = posting.Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toRequestData parses stored request, requests collected by older versions get their IDs from keys
+func toRequestData(key []byte, val []byte) (*RequestData, error) {
+	request := new(RequestData)
+	if err := decodeStoredRequest(val, request); err != nil {
+		return nil, err
+	}
+	if request.ID == 0 {
+		request.ID = btoi(key)
+	}
+	return request, nil
+}
+
+// unindexRequest removes request from token index of basket
+func unindexRequest(b *bolt.Bucket, key []byte, val []byte) {
+	idx := b.Bucket(boltKeyIndex)
+	if idx == nil || key == nil {
+		return
+	}
+
+	data := new(RequestData)
+	if err := decodeStoredRequest(val, data); err != nil {
+		return
+	}
+
+	for _, token := range data.IndexTokens() {
+		if posting := idx.Bucket([]byte(token)); posting != nil {
+			posting.Delete(key)
+			if k, _ := posting.Cursor().First(); k == nil {
+				idx.DeleteBucket([]byte(token))
+			}
+		}
+	}
+}
+
+// buildIndex builds token index of all requests collected by basket
+func buildIndex(b *bolt.Bucket) error {
+	if _, err := b.CreateBucketIfNotExists(boltKeyIndex); err != nil {
+		return err
+	}
+
+	return b.Bucket(boltKeyRequests).ForEach(func(key []byte, val []byte) error {
+		data := new(RequestData)
+		if err := decodeStoredRequest(val, data); err != nil {
+			return err
+		}
+		return indexRequest(b, key, data)
+	})
+}
+
 /// BasketsDatabase interface ///
 
 type boltDatabase struct {
@@ -353,6 +1159,7 @@ func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, e
 	if err != nil {
 		return auth, fmt.Errorf("failed to generate token: %s", err)
 	}
+	hash := hashBasketToken(token)
 
 	err = bdb.db.Update(func(tx *bolt.Tx) error {
 		b, cerr := tx.CreateBucket([]byte(name))
@@ -361,13 +1168,24 @@ func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, e
 		}
 
 		// initialize basket bucket (assuming that no issues arose)
-		b.Put(boltKeyToken, []byte(token))
+		b.Put(boltKeyToken, []byte(hash))
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		putRateLimit(b, config.RateLimit)
+		putBodyLimit(b, config.BodyLimit)
+		putRedaction(b, config.Redaction)
+		putSignature(b, config.Signature)
+		putForwardHeaders(b, config.ForwardHeaders)
+		putEncryption(b, config.Encryption)
+		putIgnore(b, config.Ignore)
+		putRetention(b, config.Retention)
+		putExpiresAfter(b, config.ExpiresAfter)
+		putPause(b, config.Pause)
 		b.Put(boltKeyTotalCount, itob(0))
 		b.Put(boltKeyCount, itob(0))
 		b.CreateBucket(boltKeyRequests)
+		b.CreateBucket(boltKeyIndex)
 
 		return nil
 	})
@@ -408,6 +1226,42 @@ func (bdb *boltDatabase) Delete(name string) {
 	}
 }
 
+func (bdb *boltDatabase) Rename(name string, newName string) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(name))
+		if b == nil {
+			return fmt.Errorf("failed to locate basket: %s", name)
+		}
+
+		// bolt does not support renaming of buckets, so the content of basket is copied into the new bucket
+		nb, err := tx.CreateBucket([]byte(newName))
+		if err != nil {
+			return fmt.Errorf("failed to create basket: %s - %s", newName, err)
+		}
+		if err = copyBucket(b, nb); err != nil {
+			return fmt.Errorf("failed to copy basket: %s - %s", name, err)
+		}
+
+		return tx.DeleteBucket([]byte(name))
+	})
+}
+
+// copyBucket copies all keys and nested buckets of a bucket into the destination bucket
+func copyBucket(src *bolt.Bucket, dst *bolt.Bucket) error {
+	return src.ForEach(func(key []byte, val []byte) error {
+		if val != nil {
+			return dst.Put(key, val)
+		}
+
+		// nil value denotes nested bucket
+		nested, err := dst.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(key), nested)
+	})
+}
+
 func (bdb *boltDatabase) Size() int {
 	// TODO : introduce bucket with statistics (e.g. "/stats", or ".stats"), see https://github.com/boltdb/bolt/issues/276
 	size := 0
@@ -425,7 +1279,7 @@ func (bdb *boltDatabase) Size() int {
 
 func (bdb *boltDatabase) GetNames(max int, skip int) BasketNamesPage {
 	last := skip + max
-	page := BasketNamesPage{make([]string, 0, max), 0, false}
+	page := BasketNamesPage{make([]string, 0, max), 0, false, ""}
 
 	bdb.db.View(func(tx *bolt.Tx) error {
 		cur := tx.Cursor()
@@ -441,6 +1295,37 @@ func (bdb *boltDatabase) GetNames(max int, skip int) BasketNamesPage {
 		return nil
 	})
 
+	if page.HasMore && len(page.Names) > 0 {
+		page.NextCursor = encodeCursor(page.Names[len(page.Names)-1])
+	}
+
+	return page
+}
+
+func (bdb *boltDatabase) GetNamesAfter(position string, max int) BasketNamesPage {
+	page := BasketNamesPage{make([]string, 0, max), bdb.Size(), false, ""}
+
+	// position is the last name on previous page, names are sorted
+	bdb.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Cursor()
+		key, _ := cur.Seek([]byte(position))
+		if key != nil && string(key) == position {
+			key, _ = cur.Next()
+		}
+		for ; key != nil; key, _ = cur.Next() {
+			if len(page.Names) >= max {
+				page.HasMore = true
+				break
+			}
+			page.Names = append(page.Names, string(key))
+		}
+		return nil
+	})
+
+	if page.HasMore && len(page.Names) > 0 {
+		page.NextCursor = encodeCursor(page.Names[len(page.Names)-1])
+	}
+
 	return page
 }
 
@@ -497,13 +1382,25 @@ func (bdb *boltDatabase) GetStats(max int) DatabaseStats {
 					LastRequestDate:    lastRequestDate}, max)
 			}
 		}
+		stats.StorageBytes = tx.Size()
 		return nil
 	})
 
+	stats.StorageBackend = DbTypeBolt
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (bdb *boltDatabase) Compact() error {
+	// baskets keep reference to open database, so the file may only be compacted offline
+	return fmt.Errorf("Bolt database reuses free pages, its file may only be compacted offline with 'bbolt compact'")
+}
+
+func (bdb *boltDatabase) Ping() error {
+	// read transaction fails if database file is closed
+	return bdb.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
 func (bdb *boltDatabase) Release() {
 	log.Print("[info] closing Bolt database")
 	err := bdb.db.Close()
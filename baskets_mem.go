@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // DbTypeMemory defines name of in-memory database storage
@@ -16,19 +19,113 @@ const DbTypeMemory = "mem"
 type memoryBasket struct {
 	sync.RWMutex
 	token      string
+	shareToken string // read-only token, empty if basket is not shared
+	password   string // hash of password of read-only web view, empty if view is not protected
+	tokens     []AccessToken
+	acl        []ACLEntry
 	config     BasketConfig
-	requests   []*RequestData
+	requests   *requestsRing
+	index      *tokenIndex
 	totalCount int
+	seq        int   // creation order of basket within database
+	modified   int64 // time of the last change of collected requests, milliseconds since epoch
 	responses  map[string]*ResponseConfig
+	trigger    *TriggerConfig
+	schedules  []ScheduleConfig
+	secrets    map[string]string
+	webhooks   []WebhookConfig
+	deliveries []WebhookDelivery
+}
+
+// requestsRing keeps collected requests in a ring buffer of basket capacity, so a new request is added and the oldest
+// one is evicted without moving other requests; requests are accessed from the newest to the oldest
+type requestsRing struct {
+	items []*RequestData
+	first int // position of the newest request
+	size  int
+}
+
+func newRequestsRing(capacity int) *requestsRing {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &requestsRing{items: make([]*RequestData, capacity)}
+}
+
+// Len returns number of requests in ring
+func (r *requestsRing) Len() int {
+	return r.size
+}
+
+// At returns i-th request counting from the newest one
+func (r *requestsRing) At(i int) *RequestData {
+	return r.items[(r.first+i)%len(r.items)]
+}
+
+// Set replaces i-th request counting from the newest one
+func (r *requestsRing) Set(i int, req *RequestData) {
+	r.items[(r.first+i)%len(r.items)] = req
+}
+
+// Push adds the newest request, returns the oldest request evicted to make room for it or nil
+func (r *requestsRing) Push(req *RequestData) *RequestData {
+	if len(r.items) == 0 {
+		return req
+	}
+
+	r.first = (r.first + len(r.items) - 1) % len(r.items)
+	var evicted *RequestData
+	if r.size == len(r.items) {
+		evicted = r.items[r.first]
+	} else {
+		r.size++
+	}
+	r.items[r.first] = req
+	return evicted
+}
+
+// Slice returns copy of requests within range counting from the newest one, so the page is not changed
+// by requests collected later
+func (r *requestsRing) Slice(from int, to int) []*RequestData {
+	page := make([]*RequestData, 0, to-from)
+	for i := from; i < to; i++ {
+		page = append(page, r.At(i))
+	}
+	return page
+}
+
+// Rebuild replaces ring with a ring of given capacity that keeps accepted requests, the newest requests
+// are kept if they do not fit; returns dropped requests
+func (r *requestsRing) Rebuild(capacity int, accept func(req *RequestData) bool) []*RequestData {
+	rebuilt := newRequestsRing(capacity)
+	dropped := make([]*RequestData, 0)
+	for i := 0; i < r.size; i++ {
+		req := r.At(i)
+		if rebuilt.size < len(rebuilt.items) && (accept == nil || accept(req)) {
+			rebuilt.items[rebuilt.size] = req
+			rebuilt.size++
+		} else {
+			dropped = append(dropped, req)
+		}
+	}
+	*r = *rebuilt
+	return dropped
 }
 
 func (basket *memoryBasket) applyLimit() {
 	// Keep requests up to specified capacity
-	if len(basket.requests) > basket.config.Capacity {
-		basket.requests = basket.requests[:basket.config.Capacity]
+	if basket.requests.Len() > basket.config.Capacity || len(basket.requests.items) != basket.config.Capacity {
+		for _, evicted := range basket.requests.Rebuild(basket.config.Capacity, nil) {
+			basket.index.Remove(evicted)
+		}
 	}
 }
 
+// touch records the time of change, the lock must be held by caller
+func (basket *memoryBasket) touch() {
+	basket.modified = time.Now().UnixNano() / toMs
+}
+
 func (basket *memoryBasket) Config() BasketConfig {
 	return basket.config
 }
@@ -39,10 +136,90 @@ func (basket *memoryBasket) Update(config BasketConfig) {
 
 	basket.config = config
 	basket.applyLimit()
+	basket.touch()
 }
 
 func (basket *memoryBasket) Authorize(token string) bool {
-	return token == basket.token
+	basket.RLock()
+	stored := basket.token
+	basket.RUnlock()
+
+	valid, outdated := verifyBasketToken(stored, token)
+	if outdated {
+		hash := hashBasketToken(token)
+		basket.Lock()
+		if basket.token == stored {
+			basket.token = hash
+		}
+		basket.Unlock()
+	}
+
+	return valid
+}
+
+func (basket *memoryBasket) SetToken(token string) {
+	hash := hashBasketToken(token)
+
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.token = hash
+}
+
+func (basket *memoryBasket) GetShareToken() string {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.shareToken
+}
+
+func (basket *memoryBasket) SetShareToken(token string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.shareToken = token
+}
+
+func (basket *memoryBasket) GetViewPassword() string {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.password
+}
+
+func (basket *memoryBasket) SetViewPassword(hash string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.password = hash
+}
+
+func (basket *memoryBasket) GetAccessTokens() []AccessToken {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.tokens
+}
+
+func (basket *memoryBasket) SetAccessTokens(tokens []AccessToken) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.tokens = tokens
+}
+
+func (basket *memoryBasket) GetACL() []ACLEntry {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.acl
+}
+
+func (basket *memoryBasket) SetACL(entries []ACLEntry) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.acl = entries
 }
 
 func (basket *memoryBasket) GetResponse(method string) *ResponseConfig {
@@ -63,40 +240,222 @@ func (basket *memoryBasket) SetResponse(method string, response ResponseConfig)
 	basket.responses[method] = &response
 }
 
+func (basket *memoryBasket) GetTrigger() *TriggerConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.trigger
+}
+
+func (basket *memoryBasket) SetTrigger(trigger TriggerConfig) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.trigger = &trigger
+}
+
+func (basket *memoryBasket) GetSchedules() []ScheduleConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.schedules
+}
+
+func (basket *memoryBasket) SetSchedules(schedules []ScheduleConfig) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.schedules = schedules
+}
+
+func (basket *memoryBasket) GetSecrets() map[string]string {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	secrets := make(map[string]string, len(basket.secrets))
+	for k, v := range basket.secrets {
+		secrets[k] = v
+	}
+
+	return secrets
+}
+
+func (basket *memoryBasket) SetSecret(name string, value string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.secrets[name] = value
+}
+
+func (basket *memoryBasket) DeleteSecret(name string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	delete(basket.secrets, name)
+}
+
+func (basket *memoryBasket) GetWebhooks() []WebhookConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.webhooks
+}
+
+func (basket *memoryBasket) SetWebhooks(webhooks []WebhookConfig) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.webhooks = webhooks
+}
+
+func (basket *memoryBasket) GetWebhookDeliveries() []WebhookDelivery {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.deliveries
+}
+
+func (basket *memoryBasket) SetWebhookDeliveries(deliveries []WebhookDelivery) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.deliveries = deliveries
+}
+
 func (basket *memoryBasket) Add(req *http.Request) *RequestData {
+	return basket.AddRequest(ToRequestData(req))
+}
+
+func (basket *memoryBasket) AddRequest(data *RequestData) *RequestData {
 	basket.Lock()
 	defer basket.Unlock()
 
-	data := ToRequestData(req)
-	// insert in front of collection
-	basket.requests = append([]*RequestData{data}, basket.requests...)
+	data.ID = basket.totalCount + 1
+	// insert in front of collection, the oldest request is evicted once capacity is reached
+	basket.index.Add(data)
+	if evicted := basket.requests.Push(data); evicted != nil {
+		basket.index.Remove(evicted)
+	}
 
 	// keep total number of all collected requests
 	basket.totalCount++
-	// apply limits according to basket capacity
-	basket.applyLimit()
+	basket.touch()
 
 	return data
 }
 
+func (basket *memoryBasket) UpdateRequest(id int, update func(data *RequestData)) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if i := basket.search(id); i >= 0 {
+		// replace stored request with updated copy, it may still be referenced by concurrent readers
+		request := basket.requests.At(i)
+		data := *request
+		update(&data)
+		basket.requests.Set(i, &data)
+		basket.index.Replace(request, &data)
+		basket.touch()
+	}
+}
+
+func (basket *memoryBasket) DeleteRequests(ids []int) int {
+	basket.Lock()
+	defer basket.Unlock()
+
+	selected := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	deleted := basket.requests.Rebuild(basket.config.Capacity, func(req *RequestData) bool { return !selected[req.ID] })
+	for _, request := range deleted {
+		basket.index.Remove(request)
+	}
+	if len(deleted) > 0 {
+		basket.touch()
+	}
+	return len(deleted)
+}
+
+func (basket *memoryBasket) DeleteRequestsBefore(date int64) int {
+	basket.Lock()
+	defer basket.Unlock()
+
+	deleted := basket.requests.Rebuild(basket.config.Capacity, func(req *RequestData) bool { return req.Date >= date })
+	for _, request := range deleted {
+		basket.index.Remove(request)
+	}
+	if len(deleted) > 0 {
+		basket.touch()
+	}
+	return len(deleted)
+}
+
 func (basket *memoryBasket) Clear() {
 	basket.Lock()
 	defer basket.Unlock()
 
 	// reset collected requests and total counter
-	basket.requests = make([]*RequestData, 0, basket.config.Capacity)
+	basket.requests = newRequestsRing(basket.config.Capacity)
+	basket.index = newTokenIndex()
 	// basket.totalCount = 0 // reset total stats
+	basket.touch()
 }
 
 func (basket *memoryBasket) Size() int {
-	return len(basket.requests)
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.requests.Len()
+}
+
+// storedBytes returns approximate number of bytes of collected requests
+func (basket *memoryBasket) storedBytes() int64 {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	bytes := int64(0)
+	for i := 0; i < basket.requests.Len(); i++ {
+		bytes += requestSize(basket.requests.At(i))
+	}
+	return bytes
+}
+
+func (basket *memoryBasket) LastModified() int64 {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.modified
+}
+
+// search returns position of request with given ID or -1, the lock must be held by caller
+func (basket *memoryBasket) search(id int) int {
+	// requests are sorted from newest to oldest, so IDs are descending
+	size := basket.requests.Len()
+	i := sort.Search(size, func(i int) bool { return basket.requests.At(i).ID <= id })
+	if i < size && basket.requests.At(i).ID == id {
+		return i
+	}
+	return -1
+}
+
+func (basket *memoryBasket) GetRequest(id int) *RequestData {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	if i := basket.search(id); i >= 0 {
+		return basket.requests.At(i)
+	}
+
+	return nil
 }
 
 func (basket *memoryBasket) GetRequests(max int, skip int) RequestsPage {
 	basket.RLock()
 	defer basket.RUnlock()
 
-	size := basket.Size()
+	size := basket.requests.Len()
 	last := skip + max
 
 	requestsPage := RequestsPage{
@@ -108,22 +467,57 @@ func (basket *memoryBasket) GetRequests(max int, skip int) RequestsPage {
 		if last > size {
 			last = size
 		}
-		requestsPage.Requests = basket.requests[skip:last]
+		requestsPage.Requests = basket.requests.Slice(skip, last)
+	}
+	if requestsPage.HasMore {
+		requestsPage.NextCursor = requestsCursor(requestsPage.Requests)
 	}
 
 	return requestsPage
 }
 
-func (basket *memoryBasket) FindRequests(query string, in string, max int, skip int) RequestsQueryPage {
+func (basket *memoryBasket) GetRequestsBefore(id int, max int) RequestsPage {
 	basket.RLock()
 	defer basket.RUnlock()
 
-	result := make([]*RequestData, 0, max)
+	// requests are sorted from newest to oldest, so IDs are descending
+	size := basket.requests.Len()
+	first := sort.Search(size, func(i int) bool { return basket.requests.At(i).ID < id })
+	last := first + max
+	if last > size {
+		last = size
+	}
+
+	requestsPage := RequestsPage{
+		Requests:   basket.requests.Slice(first, last),
+		Count:      size,
+		TotalCount: basket.totalCount,
+		HasMore:    last < size}
+	if requestsPage.HasMore {
+		requestsPage.NextCursor = requestsCursor(requestsPage.Requests)
+	}
+
+	return requestsPage
+}
+
+func (basket *memoryBasket) FindRequests(query *RequestsQuery, max int, skip int) RequestsQueryPage {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	// narrow down the search with token index if possible
+	size, at := basket.requests.Len(), basket.requests.At
+	if tokens, ok := query.IndexTokens(); ok {
+		candidates := basket.index.Candidates(tokens)
+		size, at = len(candidates), func(i int) *RequestData { return candidates[i] }
+	}
+
+	result := make([]*RequestData, 0, pageCapacity(max))
 	skipped := 0
 
-	for index, request := range basket.requests {
-		// filter
-		if request.Matches(query, in) {
+	for index := 0; index < size; index++ {
+		request := at(index)
+		// filter, all requests are checked since imported and restored requests keep their original dates
+		if request.Matches(query) {
 			if skipped < skip {
 				skipped++
 			} else {
@@ -133,7 +527,12 @@ func (basket *memoryBasket) FindRequests(query string, in string, max int, skip
 
 		// early exit
 		if len(result) == max {
-			return RequestsQueryPage{Requests: result, HasMore: index < len(basket.requests)-1}
+			hasMore := index < size-1
+			page := RequestsQueryPage{Requests: result, HasMore: hasMore}
+			if hasMore {
+				page.NextCursor = requestsCursor(result)
+			}
+			return page
 		}
 	}
 
@@ -143,10 +542,45 @@ func (basket *memoryBasket) FindRequests(query string, in string, max int, skip
 
 /// BasketsDatabase interface ///
 
-type memoryDatabase struct {
+// memoryShards defines number of shards of in-memory database, baskets of different shards are looked up, created
+// and deleted without contention, while requests of every basket are guarded by the lock of basket
+const memoryShards = 32
+
+type memoryShard struct {
 	sync.RWMutex
 	baskets map[string]*memoryBasket
-	names   []string
+}
+
+type memoryName struct {
+	name string
+	seq  int // creation order of basket within database
+}
+
+type memoryDatabase struct {
+	sync.RWMutex // guards names of baskets, acquired after locks of shards
+	shards       [memoryShards]*memoryShard
+	names        []memoryName
+	seq          int
+}
+
+// shard returns shard of basket by FNV-1a hash of its name
+func (db *memoryDatabase) shard(name string) *memoryShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		hash ^= uint32(name[i])
+		hash *= 16777619
+	}
+	return db.shards[hash%memoryShards]
+}
+
+// indexOf returns position of basket name in creation order or -1, the lock of names must be held by caller
+func (db *memoryDatabase) indexOf(name string) int {
+	for i, v := range db.names {
+		if v.name == name {
+			return i
+		}
+	}
+	return -1
 }
 
 func (db *memoryDatabase) Create(name string, config BasketConfig) (BasketAuth, error) {
@@ -155,34 +589,45 @@ func (db *memoryDatabase) Create(name string, config BasketConfig) (BasketAuth,
 	if err != nil {
 		return auth, fmt.Errorf("failed to generate token: %s", err)
 	}
+	hash := hashBasketToken(token)
 
-	db.Lock()
-	defer db.Unlock()
+	shard := db.shard(name)
+	shard.Lock()
+	defer shard.Unlock()
 
-	_, exists := db.baskets[name]
+	_, exists := shard.baskets[name]
 	if exists {
 		return auth, fmt.Errorf("Basket with name '%s' already exists", name)
 	}
 
 	basket := new(memoryBasket)
-	basket.token = token
+	basket.token = hash
 	basket.config = config
-	basket.requests = make([]*RequestData, 0, config.Capacity)
+	basket.requests = newRequestsRing(config.Capacity)
+	basket.index = newTokenIndex()
 	basket.totalCount = 0
 	basket.responses = make(map[string]*ResponseConfig)
+	basket.secrets = make(map[string]string)
 
-	db.baskets[name] = basket
-	db.names = append(db.names, name)
-	// Uncomment if sorting is expected
-	// sort.Strings(db.names)
+	db.Lock()
+	db.seq++
+	basket.seq = db.seq
+	db.names = append(db.names, memoryName{name, basket.seq})
+	db.Unlock()
 
+	shard.baskets[name] = basket
 	auth.Token = token
 
 	return auth, nil
 }
 
 func (db *memoryDatabase) Get(name string) Basket {
-	if basket, exists := db.baskets[name]; exists {
+	shard := db.shard(name)
+	shard.RLock()
+	basket, exists := shard.baskets[name]
+	shard.RUnlock()
+
+	if exists {
 		return basket
 	}
 
@@ -191,22 +636,82 @@ func (db *memoryDatabase) Get(name string) Basket {
 }
 
 func (db *memoryDatabase) Delete(name string) {
+	shard := db.shard(name)
+	shard.Lock()
+	defer shard.Unlock()
+
+	delete(shard.baskets, name)
+
 	db.Lock()
 	defer db.Unlock()
+	if i := db.indexOf(name); i >= 0 {
+		// build new collection, current one may still be referenced by concurrent readers
+		names := make([]memoryName, 0, len(db.names))
+		db.names = append(append(names, db.names[:i]...), db.names[i+1:]...)
+	}
+}
 
-	delete(db.baskets, name)
-	for i, v := range db.names {
-		if v == name {
-			db.names = append(db.names[:i], db.names[i+1:]...)
-			break
+func (db *memoryDatabase) Rename(name string, newName string) error {
+	// shards are locked in the same order to avoid deadlocks of concurrent renames
+	from, to := db.shard(name), db.shard(newName)
+	first, second := from, to
+	if db.shardIndex(first) > db.shardIndex(second) {
+		first, second = second, first
+	}
+	first.Lock()
+	defer first.Unlock()
+	if second != first {
+		second.Lock()
+		defer second.Unlock()
+	}
+
+	basket, exists := from.baskets[name]
+	if !exists {
+		return fmt.Errorf("failed to locate basket: %s", name)
+	}
+	if _, exists = to.baskets[newName]; exists {
+		return fmt.Errorf("Basket with name '%s' already exists", newName)
+	}
+
+	delete(from.baskets, name)
+	to.baskets[newName] = basket
+
+	// renamed basket keeps its creation order
+	db.Lock()
+	defer db.Unlock()
+	if i := db.indexOf(name); i >= 0 {
+		db.names[i].name = newName
+	}
+
+	return nil
+}
+
+// shardIndex returns position of shard within database
+func (db *memoryDatabase) shardIndex(shard *memoryShard) int {
+	for i, s := range db.shards {
+		if s == shard {
+			return i
 		}
 	}
+	return -1
 }
 
 func (db *memoryDatabase) Size() int {
+	db.RLock()
+	defer db.RUnlock()
+
 	return len(db.names)
 }
 
+// namesOf returns names of baskets in creation order
+func namesOf(entries []memoryName) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.name
+	}
+	return names
+}
+
 func (db *memoryDatabase) GetNames(max int, skip int) BasketNamesPage {
 	db.RLock()
 	defer db.RUnlock()
@@ -223,12 +728,51 @@ func (db *memoryDatabase) GetNames(max int, skip int) BasketNamesPage {
 			last = size
 		}
 
-		namesPage.Names = db.names[skip:last]
+		namesPage.Names = namesOf(db.names[skip:last])
+		if namesPage.HasMore {
+			namesPage.NextCursor = namesCursor(db.names[skip:last])
+		}
+	}
+
+	return namesPage
+}
+
+func (db *memoryDatabase) GetNamesAfter(position string, max int) BasketNamesPage {
+	db.RLock()
+	defer db.RUnlock()
+
+	// position is a creation order of the last basket on previous page, names are sorted in creation order
+	seq, err := strconv.Atoi(position)
+	if err != nil {
+		log.Printf("[warn] invalid position of basket names: %s", position)
+	}
+
+	size := len(db.names)
+	first := sort.Search(size, func(i int) bool { return db.names[i].seq > seq })
+	last := first + max
+	if last > size {
+		last = size
+	}
+
+	namesPage := BasketNamesPage{
+		Names:   namesOf(db.names[first:last]),
+		Count:   size,
+		HasMore: last < size}
+	if namesPage.HasMore {
+		namesPage.NextCursor = namesCursor(db.names[first:last])
 	}
 
 	return namesPage
 }
 
+// namesCursor returns cursor token pointing after the last name on a page
+func namesCursor(names []memoryName) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return encodeCursor(strconv.Itoa(names[len(names)-1].seq))
+}
+
 func (db *memoryDatabase) FindNames(query string, max int, skip int) BasketNamesQueryPage {
 	db.RLock()
 	defer db.RUnlock()
@@ -236,13 +780,13 @@ func (db *memoryDatabase) FindNames(query string, max int, skip int) BasketNames
 	result := make([]string, 0, max)
 	skipped := 0
 
-	for index, name := range db.names {
+	for index, entry := range db.names {
 		// filter
-		if strings.Contains(name, query) {
+		if strings.Contains(entry.name, query) {
 			if skipped < skip {
 				skipped++
 			} else {
-				result = append(result, name)
+				result = append(result, entry.name)
 			}
 		}
 
@@ -258,29 +802,49 @@ func (db *memoryDatabase) FindNames(query string, max int, skip int) BasketNames
 
 func (db *memoryDatabase) GetStats(max int) DatabaseStats {
 	db.RLock()
-	defer db.RUnlock()
+	names := namesOf(db.names)
+	db.RUnlock()
 
 	stats := DatabaseStats{}
 
-	for _, name := range db.names {
-		if basket, exists := db.baskets[name]; exists {
+	// baskets are collected one by one, so collection of requests is not blocked
+	for _, name := range names {
+		shard := db.shard(name)
+		shard.RLock()
+		basket, exists := shard.baskets[name]
+		shard.RUnlock()
+
+		if exists {
+			page := basket.GetRequests(1, 0)
 			var lastRequestDate int64
-			if basket.Size() > 0 {
-				lastRequestDate = basket.GetRequests(1, 0).Requests[0].Date
+			if len(page.Requests) > 0 {
+				lastRequestDate = page.Requests[0].Date
 			}
 
 			stats.Collect(&BasketInfo{
 				Name:               name,
-				RequestsCount:      basket.Size(),
-				RequestsTotalCount: basket.totalCount,
+				RequestsCount:      page.Count,
+				RequestsTotalCount: page.TotalCount,
 				LastRequestDate:    lastRequestDate}, max)
+			stats.StorageBytes += basket.storedBytes()
 		}
 	}
 
+	stats.StorageBackend = DbTypeMemory
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (db *memoryDatabase) Compact() error {
+	// collections of deleted requests are released by garbage collector
+	return nil
+}
+
+func (db *memoryDatabase) Ping() error {
+	// in-memory database is always available
+	return nil
+}
+
 func (db *memoryDatabase) Release() {
 	log.Print("[info] releasing in-memory database resources")
 }
@@ -288,5 +852,9 @@ func (db *memoryDatabase) Release() {
 // NewMemoryDatabase creates an instance of in-memory Baskets Database
 func NewMemoryDatabase() BasketsDatabase {
 	log.Print("[info] using in-memory database to store baskets")
-	return &memoryDatabase{baskets: make(map[string]*memoryBasket), names: make([]string, 0)}
+	db := &memoryDatabase{names: make([]memoryName, 0)}
+	for i := range db.shards {
+		db.shards[i] = &memoryShard{baskets: make(map[string]*memoryBasket)}
+	}
+	return db
 }
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -88,6 +90,56 @@ func TestMemoryDatabase_Delete(t *testing.T) {
 	assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
 }
 
+func TestMemoryDatabase_Rename(t *testing.T) {
+	name := "test5r"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	newName := name + "_renamed"
+	defer db.Delete(name)
+	defer db.Delete(newName)
+
+	auth, _ := db.Create(name, BasketConfig{Capacity: 10, ForwardURL: "http://localhost:12345/test"})
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestPOSTRequest("http://localhost/"+name, "req1", "text/plain"))
+		basket.Add(createTestPOSTRequest("http://localhost/"+name, "req2", "text/plain"))
+		basket.SetResponse("GET", ResponseConfig{Status: 202, Body: "accepted"})
+		basket.SetSecret("API_KEY", "s3cr3t")
+
+		if assert.NoError(t, db.Rename(name, newName)) {
+			assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
+
+			renamed := db.Get(newName)
+			if assert.NotNil(t, renamed, "basket with name: %v is expected", newName) {
+				assert.True(t, renamed.Authorize(auth.Token), "basket token is expected to be preserved")
+				assert.Equal(t, "http://localhost:12345/test", renamed.Config().ForwardURL, "wrong forward URL")
+				assert.Equal(t, 2, renamed.Size(), "wrong number of requests")
+				page := renamed.GetRequests(10, 0)
+				assert.Equal(t, 2, page.TotalCount, "wrong total count of requests")
+				if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+					assert.Equal(t, "req2", page.Requests[0].Body, "wrong request body")
+				}
+				assert.Len(t, renamed.FindRequests(NewTextQuery("req1", "body"), 10, 0).Requests, 1, "wrong number of found requests")
+				if response := renamed.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+					assert.Equal(t, "accepted", response.Body, "wrong response body")
+				}
+				assert.Equal(t, "s3cr3t", renamed.GetSecrets()["API_KEY"], "secret is expected to be preserved")
+
+				// request IDs continue
+				data := renamed.Add(createTestPOSTRequest("http://localhost/"+newName, "req3", "text/plain"))
+				assert.Equal(t, 3, data.ID, "wrong request ID")
+			}
+		}
+
+		// unknown basket and name conflicts
+		assert.Error(t, db.Rename(name, name+"_other"), "unknown basket cannot be renamed")
+		db.Create(name, BasketConfig{Capacity: 10})
+		assert.Error(t, db.Rename(name, newName), "basket cannot be renamed to existing name")
+		assert.NotNil(t, db.Get(name), "basket with name: %v is expected", name)
+	}
+}
+
 func TestMemoryDatabase_Delete_Multi(t *testing.T) {
 	name := "test6"
 	db := NewMemoryDatabase()
@@ -147,6 +199,44 @@ func TestMemoryDatabase_GetNames(t *testing.T) {
 	assert.False(t, db.GetNames(5, 40).HasMore, "no more names are expected")
 }
 
+func TestMemoryDatabase_GetNamesAfter(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 45; i++ {
+		db.Create(fmt.Sprintf("test%v", i), config)
+	}
+
+	// iterate names with cursor while baskets are created and deleted
+	page := db.GetNames(10, 0)
+	seen := make(map[string]int)
+	for i := 0; ; i++ {
+		for _, n := range page.Names {
+			seen[n]++
+		}
+		if !page.HasMore {
+			break
+		}
+		if i == 1 {
+			// delete the last seen basket and create new basket
+			db.Delete(page.Names[len(page.Names)-1])
+			db.Create("test99", config)
+		}
+		if assert.NotEmpty(t, page.NextCursor, "cursor is expected") {
+			position, err := DecodeCursor(page.NextCursor)
+			if assert.NoError(t, err) {
+				page = db.GetNamesAfter(position, 10)
+			}
+		}
+	}
+
+	for i := 0; i < 45; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("test%v", i)], "basket name is expected exactly once: %v", fmt.Sprintf("test%v", i))
+	}
+	assert.Empty(t, page.NextCursor, "no cursor is expected on the last page")
+}
+
 func TestMemoryDatabase_FindNames(t *testing.T) {
 	db := NewMemoryDatabase()
 	defer db.Release()
@@ -241,6 +331,38 @@ func TestMemoryBasket_Clear(t *testing.T) {
 	}
 }
 
+func TestMemoryBasket_LastModified(t *testing.T) {
+	name := "test103"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Equal(t, int64(0), basket.LastModified(), "unchanged basket is not expected to have modification time")
+
+		// every change of collected requests is recorded
+		changes := []func(){
+			func() { basket.Add(createTestPOSTRequest("http://localhost/"+name, "test", "text/plain")) },
+			func() { basket.UpdateRequest(1, func(data *RequestData) { data.ResponseStatus = 201 }) },
+			func() { basket.DeleteRequests([]int{1}) },
+			func() { basket.Clear() }}
+		modified := int64(0)
+		for i, change := range changes {
+			time.Sleep(2 * time.Millisecond)
+			change()
+			assert.True(t, basket.LastModified() > modified, "modification time is expected to grow after change %d", i)
+			modified = basket.LastModified()
+		}
+
+		// unknown requests are not deleted
+		time.Sleep(2 * time.Millisecond)
+		basket.DeleteRequests([]int{10})
+		assert.Equal(t, modified, basket.LastModified(), "modification time is not expected to change")
+	}
+}
+
 func TestMemoryBasket_Update_Shrink(t *testing.T) {
 	name := "test104"
 	db := NewMemoryDatabase()
@@ -323,7 +445,7 @@ func TestMemoryBasket_FindRequests(t *testing.T) {
 		assert.Equal(t, 30, basket.Size(), "wrong basket size")
 
 		// search everywhere
-		s1 := basket.FindRequests("req1", "any", 30, 0)
+		s1 := basket.FindRequests(NewTextQuery("req1", "any"), 30, 0)
 		assert.False(t, s1.HasMore, "no more results are expected")
 		assert.Len(t, s1.Requests, 11, "wrong number of found requests")
 		for _, r := range s1.Requests {
@@ -331,25 +453,392 @@ func TestMemoryBasket_FindRequests(t *testing.T) {
 		}
 
 		// search everywhere (limited output)
-		s2 := basket.FindRequests("req2", "any", 5, 5)
+		s2 := basket.FindRequests(NewTextQuery("req2", "any"), 5, 5)
 		assert.True(t, s2.HasMore, "more results are expected")
 		assert.Len(t, s2.Requests, 5, "wrong number of found requests")
 
 		// search in body (positive)
-		assert.Len(t, basket.FindRequests("req3", "body", 100, 0).Requests, 2, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("req3", "body"), 100, 0).Requests, 2, "wrong number of found requests")
 		// search in body (negative)
-		assert.Empty(t, basket.FindRequests("yummy", "body", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("yummy", "body"), 100, 0).Requests, "found unexpected requests")
 
 		// search in headers (positive)
-		assert.Len(t, basket.FindRequests("yummy", "headers", 100, 0).Requests, 10, "wrong number of found requests")
-		assert.Len(t, basket.FindRequests("tasty", "headers", 100, 0).Requests, 20, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("yummy", "headers"), 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("tasty", "headers"), 100, 0).Requests, 20, "wrong number of found requests")
 		// search in headers (negative)
-		assert.Empty(t, basket.FindRequests("req1", "headers", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("req1", "headers"), 100, 0).Requests, "found unexpected requests")
 
 		// search in query (positive)
-		assert.Len(t, basket.FindRequests("id=1", "query", 100, 0).Requests, 11, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("id=1", "query"), 100, 0).Requests, 11, "wrong number of found requests")
 		// search in query (negative)
-		assert.Empty(t, basket.FindRequests("tasty", "query", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("tasty", "query"), 100, 0).Requests, "found unexpected requests")
+	}
+}
+
+func TestMemoryBasket_FindRequests_DateRange(t *testing.T) {
+	name := "test106d"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 100})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket with 3 groups of requests separated in time
+		bounds := make([]int64, 0, 2)
+		for g := 1; g <= 3; g++ {
+			for i := 1; i <= 5; i++ {
+				basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?group=%v", name, g), fmt.Sprintf("req%v-%v", g, i), "text/plain"))
+			}
+			if g < 3 {
+				time.Sleep(20 * time.Millisecond)
+				bounds = append(bounds, time.Now().UnixNano()/toMs)
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+
+		// requests from the middle group
+		query := NewTextQuery("", "any")
+		query.From, query.To = bounds[0], bounds[1]
+		page := basket.FindRequests(query, 100, 0)
+		if assert.Len(t, page.Requests, 5, "wrong number of found requests") {
+			for _, r := range page.Requests {
+				assert.Contains(t, r.Body, "req2-", "incorrect request among results")
+			}
+		}
+
+		// requests since the start of second group with text search
+		query = NewTextQuery("-1", "body")
+		query.From = bounds[0]
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 2, "wrong number of found requests")
+
+		// requests until the end of first group
+		query = NewTextQuery("", "any")
+		query.To = bounds[0]
+		page = basket.FindRequests(query, 3, 0)
+		assert.True(t, page.HasMore, "more results are expected")
+		if assert.Len(t, page.Requests, 3, "wrong number of found requests") {
+			assert.Equal(t, "req1-5", page.Requests[0].Body, "newest request is expected first")
+		}
+
+		// requests with older dates may be added later, e.g. imported requests
+		basket.AddRequest(&RequestData{Date: bounds[0] - 1000, Method: "GET", Body: "imported"})
+		query = NewTextQuery("", "any")
+		query.From, query.To = bounds[0]-1000, bounds[0]-1000
+		page = basket.FindRequests(query, 10, 0)
+		if assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, "imported", page.Requests[0].Body, "wrong found request")
+		}
+		query.From, query.To = bounds[1], 0
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 5, "wrong number of found requests")
+	}
+}
+
+func TestMemoryBasket_FindRequests_AfterEviction(t *testing.T) {
+	name := "test106e"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket over capacity
+		for i := 1; i <= 15; i++ {
+			r := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("{\"order_id\":\"ord-%v\"}", i), "application/json")
+			basket.Add(r)
+		}
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+
+		// evicted requests are not found
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord-5\"", "body"), 100, 0).Requests, "found unexpected requests")
+		page := basket.FindRequests(NewTextQuery("ord-1", "body"), 100, 0)
+		if assert.Len(t, page.Requests, 6, "wrong number of found requests") {
+			assert.Contains(t, page.Requests[0].Body, "ord-15", "newest request is expected first")
+		}
+		assert.Len(t, basket.FindRequests(NewTextQuery("order_id\":\"ord", "body"), 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("json", "headers"), 3, 0).Requests, 3, "wrong number of found requests")
+
+		// shrink basket
+		config := basket.Config()
+		config.Capacity = 5
+		basket.Update(config)
+		assert.Len(t, basket.FindRequests(NewTextQuery("ord-1", "body"), 100, 0).Requests, 5, "wrong number of found requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord-10\"", "body"), 100, 0).Requests, "found unexpected requests")
+
+		// clear basket
+		basket.Clear()
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord", "body"), 100, 0).Requests, "found unexpected requests")
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "{\"order_id\":\"ord-16\"}", "application/json"))
+		assert.Len(t, basket.FindRequests(NewTextQuery("ord", "body"), 100, 0).Requests, 1, "wrong number of found requests")
+	}
+}
+
+func TestMemoryBasket_FindRequests_Options(t *testing.T) {
+	name := "test106g"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i, body := range []string{"Token expired", "token=abc", "TOKENS: 3", "no credentials"} {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), body, "text/plain"))
+		}
+
+		query := NewTextQuery("token", "body")
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 1, "wrong number of found requests")
+		query.SetOptions(true, false)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 3, "wrong number of found requests")
+		query.SetOptions(true, true)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 2, "wrong number of found requests")
+		query.SetOptions(false, true)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 1, "wrong number of found requests")
+	}
+}
+
+func TestMemoryBasket_UpdateRequest(t *testing.T) {
+	name := "test106h"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			data := basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+			assert.Equal(t, i, data.ID, "wrong request ID")
+		}
+
+		page := basket.GetRequests(1, 0)
+		if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+			assert.Equal(t, 12, page.Requests[0].ID, "wrong request ID")
+			basket.UpdateRequest(12, func(data *RequestData) {
+				assert.Equal(t, "req12", data.Body, "stored request is expected")
+				data.ForwardLatency = 120
+			})
+
+			found := basket.FindRequests(NewTextQuery("req12", "body"), 10, 0)
+			if assert.Len(t, found.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, int64(120), found.Requests[0].ForwardLatency, "forward latency is not updated")
+				assert.Equal(t, 12, found.Requests[0].ID, "wrong request ID")
+			}
+			assert.Equal(t, 10, basket.Size(), "wrong basket size")
+		}
+
+		// evicted requests are not updated
+		basket.UpdateRequest(1, func(data *RequestData) { t.Error("evicted request is not expected to be updated") })
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+		assert.Len(t, basket.FindRequests(NewTextQuery("req1", "body"), 10, 0).Requests, 3, "wrong number of found requests")
+
+		// concurrent updates do not overwrite each other
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				basket.UpdateRequest(11, func(data *RequestData) { data.ScriptLog += "x" })
+			}()
+		}
+		wg.Wait()
+		if request := basket.GetRequest(11); assert.NotNil(t, request, "request is expected") {
+			assert.Len(t, request.ScriptLog, 20, "concurrent updates are expected to be preserved")
+		}
+
+		// IDs are not reused after clearing basket
+		basket.Clear()
+		data := basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "req13", "text/plain"))
+		assert.Equal(t, 13, data.ID, "wrong request ID")
+	}
+}
+
+func TestMemoryBasket_DeleteRequests(t *testing.T) {
+	name := "test106j"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		// evicted and unknown requests are ignored
+		assert.Equal(t, 2, basket.DeleteRequests([]int{1, 5, 12, 100}), "wrong number of deleted requests")
+		assert.Equal(t, 8, basket.Size(), "wrong basket size")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("req12", "body"), 10, 0).Requests, "deleted request is found")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 8, page.Count, "wrong number of requests")
+		assert.Equal(t, 12, page.TotalCount, "total count is not expected to change")
+		if assert.Len(t, page.Requests, 8, "wrong number of requests") {
+			assert.Equal(t, 11, page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, 3, page.Requests[7].ID, "wrong request ID")
+		}
+
+		assert.Equal(t, 0, basket.DeleteRequests([]int{}), "no requests are expected to be deleted")
+		assert.Equal(t, 0, basket.DeleteRequests([]int{5}), "request is already deleted")
+	}
+}
+
+func TestMemoryBasket_DeleteRequestsBefore(t *testing.T) {
+	name := "test106m"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		now := time.Now().UnixNano() / toMs
+		basket.AddRequest(&RequestData{Date: now - 7200000, Method: "POST", Path: "/", Body: "old"})
+		basket.AddRequest(&RequestData{Date: now - 600000, Method: "POST", Path: "/", Body: "recent"})
+		// request copied from another basket with older date
+		basket.AddRequest(&RequestData{Date: now - 9000000, Method: "POST", Path: "/", Body: "copied"})
+		basket.AddRequest(&RequestData{Date: now, Method: "POST", Path: "/", Body: "latest"})
+
+		assert.Equal(t, 2, basket.DeleteRequestsBefore(now-3600000), "wrong number of deleted requests")
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("old", "body"), 10, 0).Requests, "deleted request is found")
+		if page := basket.GetRequests(10, 0); assert.Len(t, page.Requests, 2, "wrong number of requests") {
+			assert.Equal(t, 4, page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, 2, page.Requests[1].ID, "wrong request ID")
+		}
+
+		assert.Equal(t, 0, basket.DeleteRequestsBefore(now-3600000), "no requests are expected to be deleted")
+	}
+}
+
+func TestMemoryBasket_GetRequest(t *testing.T) {
+	name := "test106k"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		request := basket.GetRequest(7)
+		if assert.NotNil(t, request, "request is expected") {
+			assert.Equal(t, 7, request.ID, "wrong request ID")
+			assert.Equal(t, "req7", request.Body, "wrong request body")
+			assert.Equal(t, "id=7", request.Query, "wrong request query")
+		}
+
+		// evicted and unknown requests are not found
+		assert.Nil(t, basket.GetRequest(2), "evicted request is not expected")
+		assert.Nil(t, basket.GetRequest(13), "unknown request is not expected")
+
+		// stored request reflects updates
+		basket.UpdateRequest(12, func(data *RequestData) { data.ScriptLog = "processed" })
+		basket.UpdateRequest(12, func(data *RequestData) { data.ResponseStatus = 202 })
+		request = basket.GetRequest(12)
+		if assert.NotNil(t, request, "request is expected") {
+			assert.Equal(t, "processed", request.ScriptLog, "wrong script log")
+			assert.Equal(t, 202, request.ResponseStatus, "wrong response status")
+		}
+	}
+}
+
+func TestMemoryBasket_AddRequest(t *testing.T) {
+	name := "test106l"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "req1", "text/plain"))
+
+		// copied request keeps its date, but gets a new ID
+		date := time.Now().Add(-time.Hour).UnixNano() / toMs
+		data := basket.AddRequest(&RequestData{ID: 42, Date: date, Method: "PUT", Path: "/" + name + "/copy", Body: "copied",
+			Header: map[string][]string{"Content-Type": {"text/plain"}}})
+		assert.Equal(t, 2, data.ID, "wrong request ID")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 2, page.Count, "wrong number of requests")
+		assert.Equal(t, 2, page.TotalCount, "wrong total count of requests")
+
+		copied := basket.GetRequest(2)
+		if assert.NotNil(t, copied, "request is expected") {
+			assert.Equal(t, date, copied.Date, "wrong request date")
+			assert.Equal(t, "copied", copied.Body, "wrong request body")
+			assert.Equal(t, "PUT", copied.Method, "wrong request method")
+		}
+
+		// date range queries respect date of copied request
+		query := NewTextQuery("", "any")
+		query.To = date + 1000
+		found := basket.FindRequests(query, 10, 0)
+		if assert.Len(t, found.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, "copied", found.Requests[0].Body, "wrong request body")
+		}
+	}
+}
+
+func TestMemoryBasket_GetRequestsBefore(t *testing.T) {
+	name := "test106i"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 8; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		page := basket.GetRequests(3, 0)
+		if assert.True(t, page.HasMore, "more requests are expected") && assert.NotEmpty(t, page.NextCursor, "cursor is expected") {
+			assert.Equal(t, "req6", page.Requests[2].Body, "wrong last request on a page")
+
+			// new requests do not shift the next page
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=9", name), "req9", "text/plain"))
+
+			before, err := DecodeRequestsCursor(page.NextCursor)
+			if assert.NoError(t, err) {
+				page = basket.GetRequestsBefore(before, 3)
+				if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+					assert.Equal(t, "req5", page.Requests[0].Body, "wrong first request on a page")
+					assert.Equal(t, 9, page.Count, "wrong number of requests in basket")
+				}
+				assert.True(t, page.HasMore, "more requests are expected")
+
+				before, err = DecodeRequestsCursor(page.NextCursor)
+				if assert.NoError(t, err) {
+					page = basket.GetRequestsBefore(before, 3)
+					assert.Len(t, page.Requests, 2, "wrong number of requests")
+					assert.False(t, page.HasMore, "no more requests are expected")
+					assert.Empty(t, page.NextCursor, "no cursor is expected on the last page")
+				}
+			}
+		}
+
+		// cursor with search query
+		query := NewTextQuery("req", "body")
+		found := basket.FindRequests(query, 4, 0)
+		if assert.True(t, found.HasMore, "more requests are expected") && assert.NotEmpty(t, found.NextCursor, "cursor is expected") {
+			before, err := DecodeRequestsCursor(found.NextCursor)
+			if assert.NoError(t, err) {
+				query.Before = before
+				found = basket.FindRequests(query, 10, 0)
+				if assert.Len(t, found.Requests, 5, "wrong number of found requests") {
+					assert.Equal(t, "req5", found.Requests[0].Body, "wrong first request on a page")
+				}
+				assert.False(t, found.HasMore, "no more requests are expected")
+			}
+		}
 	}
 }
 
@@ -402,6 +891,286 @@ func TestMemoryBasket_SetResponse_Update(t *testing.T) {
 	}
 }
 
+func TestMemoryBasket_SetTrigger(t *testing.T) {
+	name := "test109"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no trigger
+		assert.Nil(t, basket.GetTrigger())
+
+		// Set trigger
+		basket.SetTrigger(TriggerConfig{Script: "print(request['Path'])"})
+		// Get and validate
+		trigger := basket.GetTrigger()
+		if assert.NotNil(t, trigger, "trigger is expected") {
+			assert.Equal(t, "print(request['Path'])", trigger.Script, "wrong trigger script")
+		}
+
+		// Update trigger
+		basket.SetTrigger(TriggerConfig{Script: "print(request['Body'])"})
+		trigger = basket.GetTrigger()
+		if assert.NotNil(t, trigger, "trigger is expected") {
+			assert.Equal(t, "print(request['Body'])", trigger.Script, "wrong trigger script")
+		}
+	}
+}
+
+func TestMemoryBasket_SetSchedules(t *testing.T) {
+	name := "test110"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no schedules
+		assert.Empty(t, basket.GetSchedules())
+
+		// Set schedules
+		basket.SetSchedules([]ScheduleConfig{
+			{Name: "summary", Cron: "@hourly", Script: "print(basket.size())"},
+			{Name: "cleanup", Cron: "0 3 * * *", Script: "basket.clear()"}})
+		// Get and validate
+		schedules := basket.GetSchedules()
+		if assert.Len(t, schedules, 2, "wrong number of schedules") {
+			assert.Equal(t, "summary", schedules[0].Name, "wrong schedule name")
+			assert.Equal(t, "@hourly", schedules[0].Cron, "wrong schedule cron")
+			assert.Equal(t, "basket.clear()", schedules[1].Script, "wrong schedule script")
+		}
+
+		// Reset schedules
+		basket.SetSchedules([]ScheduleConfig{})
+		assert.Empty(t, basket.GetSchedules())
+	}
+}
+
+func TestMemoryBasket_SetSecrets(t *testing.T) {
+	name := "test111"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no secrets
+		assert.Empty(t, basket.GetSecrets())
+
+		// Set secrets
+		basket.SetSecret("API_KEY", "s3cr3t")
+		basket.SetSecret("TOKEN", "abc")
+		basket.SetSecret("TOKEN", "xyz")
+		// Get and validate
+		secrets := basket.GetSecrets()
+		assert.Len(t, secrets, 2, "wrong number of secrets")
+		assert.Equal(t, "s3cr3t", secrets["API_KEY"], "wrong secret value")
+		assert.Equal(t, "xyz", secrets["TOKEN"], "secret value is expected to be updated")
+
+		// Delete secrets
+		basket.DeleteSecret("TOKEN")
+		basket.DeleteSecret("UNKNOWN")
+		assert.Equal(t, map[string]string{"API_KEY": "s3cr3t"}, basket.GetSecrets())
+	}
+}
+
+func TestMemoryBasket_SetWebhooks(t *testing.T) {
+	name := "test111w"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no webhooks
+		assert.Empty(t, basket.GetWebhooks())
+
+		// Set webhooks
+		basket.SetWebhooks([]WebhookConfig{
+			{URL: "http://localhost:8080/events", Events: []string{EventRequestReceived}, Secret: "s3cr3t"},
+			{URL: "http://localhost:8080/all"}})
+		// Get and validate
+		subscriptions := basket.GetWebhooks()
+		if assert.Len(t, subscriptions, 2, "wrong number of webhooks") {
+			assert.Equal(t, "http://localhost:8080/events", subscriptions[0].URL, "wrong webhook URL")
+			assert.Equal(t, []string{EventRequestReceived}, subscriptions[0].Events, "wrong webhook events")
+			assert.Equal(t, "s3cr3t", subscriptions[0].Secret, "wrong webhook secret")
+			assert.Empty(t, subscriptions[1].Events, "no webhook events are expected")
+		}
+
+		// Reset webhooks
+		basket.SetWebhooks([]WebhookConfig{})
+		assert.Empty(t, basket.GetWebhooks())
+	}
+}
+
+func TestMemoryBasket_SetWebhookDeliveries(t *testing.T) {
+	name := "test111d"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no deliveries
+		assert.Empty(t, basket.GetWebhookDeliveries())
+
+		// Set deliveries
+		basket.SetWebhookDeliveries([]WebhookDelivery{
+			{ID: "1", EventID: "e1", Event: EventRequestReceived, URL: "http://localhost:8080/events",
+				Status: DeliveryFailed, Attempts: 5, LastStatus: 503, Payload: json.RawMessage(`{"id":"e1"}`)},
+			{ID: "2", EventID: "e2", Event: EventForwardFailed, URL: "http://localhost:8080/events", Status: DeliveryPending}})
+		// Get and validate
+		deliveries := basket.GetWebhookDeliveries()
+		if assert.Len(t, deliveries, 2, "wrong number of deliveries") {
+			assert.Equal(t, DeliveryFailed, deliveries[0].Status, "wrong delivery status")
+			assert.Equal(t, 5, deliveries[0].Attempts, "wrong number of attempts")
+			assert.Equal(t, 503, deliveries[0].LastStatus, "wrong status of the last attempt")
+			assert.Equal(t, `{"id":"e1"}`, string(deliveries[0].Payload), "wrong payload")
+			assert.Equal(t, "e2", deliveries[1].EventID, "wrong event ID")
+		}
+
+		// Reset deliveries
+		basket.SetWebhookDeliveries([]WebhookDelivery{})
+		assert.Empty(t, basket.GetWebhookDeliveries())
+	}
+}
+
+func TestMemoryBasket_ShareToken(t *testing.T) {
+	name := "test111s"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure basket is not shared
+		assert.Empty(t, basket.GetShareToken(), "basket is not expected to be shared")
+
+		// Share basket
+		basket.SetShareToken("share_token_111")
+		assert.Equal(t, "share_token_111", basket.GetShareToken(), "wrong share token")
+		assert.False(t, basket.Authorize("share_token_111"), "share token is not expected to authorize basket management")
+
+		// Revoke share token
+		basket.SetShareToken("")
+		assert.Empty(t, basket.GetShareToken(), "share token is expected to be revoked")
+	}
+}
+
+func TestMemoryBasket_ViewPassword(t *testing.T) {
+	name := "test111p"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure view is not protected
+		assert.Empty(t, basket.GetViewPassword(), "view is not expected to be protected")
+
+		// Protect view
+		basket.SetViewPassword("password_hash_111")
+		assert.Equal(t, "password_hash_111", basket.GetViewPassword(), "wrong view password")
+
+		// Remove password
+		basket.SetViewPassword("")
+		assert.Empty(t, basket.GetViewPassword(), "view password is expected to be removed")
+	}
+}
+
+func TestMemoryBasket_SetToken(t *testing.T) {
+	name := "test111t"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+	assert.NoError(t, err)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.True(t, basket.Authorize(auth.Token), "basket token is expected to be accepted")
+
+		// Rotate token
+		basket.SetToken("new_token_111")
+		assert.False(t, basket.Authorize(auth.Token), "previous token is not expected to be accepted")
+		assert.True(t, basket.Authorize("new_token_111"), "new token is expected to be accepted")
+
+		// Revoke token
+		basket.SetToken("")
+		assert.False(t, basket.Authorize("new_token_111"), "revoked token is not expected to be accepted")
+		assert.False(t, basket.Authorize(""), "empty token is not expected to be accepted")
+	}
+}
+
+func TestMemoryBasket_SetAccessTokens(t *testing.T) {
+	name := "test111a"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no access tokens
+		assert.Empty(t, basket.GetAccessTokens())
+
+		// Set access tokens
+		basket.SetAccessTokens([]AccessToken{
+			{Name: "ci", Scopes: []string{ScopeRead, ScopeClear}, Token: "ci_token_111", Created: 1000},
+			{Name: "deploy", Scopes: []string{ScopeWriteConfig}, Token: "deploy_token_111", Created: 2000}})
+		// Get and validate
+		tokens := basket.GetAccessTokens()
+		if assert.Len(t, tokens, 2, "wrong number of access tokens") {
+			assert.Equal(t, "ci", tokens[0].Name, "wrong token name")
+			assert.Equal(t, []string{ScopeRead, ScopeClear}, tokens[0].Scopes, "wrong token scopes")
+			assert.Equal(t, "ci_token_111", tokens[0].Token, "wrong token")
+			assert.Equal(t, int64(2000), tokens[1].Created, "wrong creation time")
+		}
+		assert.False(t, basket.Authorize("ci_token_111"), "access token is not expected to authorize basket management")
+
+		// Reset access tokens
+		basket.SetAccessTokens([]AccessToken{})
+		assert.Empty(t, basket.GetAccessTokens())
+	}
+}
+
+func TestMemoryBasket_SetACL(t *testing.T) {
+	name := "test111b"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no ACL
+		assert.Empty(t, basket.GetACL())
+
+		// Set ACL
+		basket.SetACL([]ACLEntry{{User: "alice", Access: AccessManage}, {Group: "qa", Access: AccessView}})
+		// Get and validate
+		entries := basket.GetACL()
+		if assert.Len(t, entries, 2, "wrong number of ACL entries") {
+			assert.Equal(t, ACLEntry{User: "alice", Access: AccessManage}, entries[0], "wrong ACL entry")
+			assert.Equal(t, ACLEntry{Group: "qa", Access: AccessView}, entries[1], "wrong ACL entry")
+		}
+
+		// Reset ACL
+		basket.SetACL([]ACLEntry{})
+		assert.Empty(t, basket.GetACL())
+	}
+}
+
 func TestMemoryDatabase_GetStats(t *testing.T) {
 	name := "test130"
 	db := NewMemoryDatabase()
@@ -430,6 +1199,8 @@ func TestMemoryDatabase_GetStats(t *testing.T) {
 		assert.Equal(t, 35, stats.RequestsCount, "wrong RequestsCount stats")
 		assert.Equal(t, 45, stats.RequestsTotalCount, "wrong RequestsTotalCount stats")
 		assert.Equal(t, 5, stats.AvgBasketSize, "wrong AvgBasketSize stats")
+		assert.Equal(t, DbTypeMemory, stats.StorageBackend, "wrong StorageBackend stats")
+		assert.True(t, stats.StorageBytes > 0, "storage bytes are expected")
 
 		// top 3 by date
 		if assert.NotNil(t, stats.TopBasketsByDate, "top baskets by date are expected") {
@@ -448,3 +1219,89 @@ func TestMemoryDatabase_GetStats(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryDatabase_Compact(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	assert.NoError(t, db.Compact(), "in-memory database is not expected to fail compaction")
+}
+
+func TestMemoryDatabase_Ping(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	assert.NoError(t, db.Ping(), "in-memory database is expected to be available")
+}
+
+func TestRequestsRing(t *testing.T) {
+	ring := newRequestsRing(3)
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, ring.Push(&RequestData{ID: i}), "no request is expected to be evicted")
+	}
+	evicted := ring.Push(&RequestData{ID: 4})
+	if assert.NotNil(t, evicted, "the oldest request is expected to be evicted") {
+		assert.Equal(t, 1, evicted.ID, "wrong evicted request")
+	}
+
+	assert.Equal(t, 3, ring.Len(), "wrong size of ring")
+	page := ring.Slice(0, 3)
+	assert.Equal(t, []int{4, 3, 2}, []int{page[0].ID, page[1].ID, page[2].ID}, "requests are expected from newest")
+
+	// page is not changed by new requests
+	ring.Push(&RequestData{ID: 5})
+	assert.Equal(t, 4, page[0].ID, "page is not expected to change")
+	assert.Equal(t, 5, ring.At(0).ID, "wrong newest request")
+
+	dropped := ring.Rebuild(5, func(req *RequestData) bool { return req.ID != 4 })
+	if assert.Len(t, dropped, 1, "wrong number of dropped requests") {
+		assert.Equal(t, 4, dropped[0].ID, "wrong dropped request")
+	}
+	assert.Equal(t, 2, ring.Len(), "wrong size of ring")
+	ring.Push(&RequestData{ID: 6})
+	assert.Equal(t, []int{6, 5, 3}, []int{ring.At(0).ID, ring.At(1).ID, ring.At(2).ID}, "wrong requests")
+
+	// the newest requests are kept once capacity is reduced
+	dropped = ring.Rebuild(1, nil)
+	assert.Len(t, dropped, 2, "wrong number of dropped requests")
+	assert.Equal(t, 6, ring.At(0).ID, "the newest request is expected to be kept")
+
+	empty := newRequestsRing(0)
+	req := &RequestData{ID: 1}
+	assert.Equal(t, req, empty.Push(req), "request is expected to be evicted at once")
+	assert.Equal(t, 0, empty.Len(), "ring without capacity is expected to be empty")
+}
+
+func TestMemoryDatabase_Concurrent(t *testing.T) {
+	name := "test140"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bname := fmt.Sprintf("%s_%v", name, i)
+			db.Create(bname, BasketConfig{Capacity: 10})
+			basket := db.Get(bname)
+			for j := 0; j < 50; j++ {
+				basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", bname, j), "data", "text/plain"))
+				db.GetNames(5, 0)
+			}
+			db.Rename(bname, bname+"_renamed")
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8, db.Size(), "wrong number of baskets")
+	for i := 0; i < 8; i++ {
+		basket := db.Get(fmt.Sprintf("%s_%v_renamed", name, i))
+		if assert.NotNil(t, basket, "renamed basket is expected") {
+			page := basket.GetRequests(20, 0)
+			assert.Equal(t, 10, page.Count, "basket is expected to be full")
+			assert.Equal(t, 50, page.TotalCount, "wrong total count")
+			assert.Equal(t, 50, page.Requests[0].ID, "wrong newest request")
+		}
+	}
+}
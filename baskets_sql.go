@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -17,6 +18,9 @@ import (
 // DbTypeSQL defines name of SQL database storage
 const DbTypeSQL = "sql"
 
+// sqlPingTimeout limits time to check connectivity of SQL database
+const sqlPingTimeout = 5 * time.Second
+
 // List of DDL statements to create database schema for baskets
 var sqlSchema = []string{
 	`CREATE TABLE rb_baskets (
@@ -49,6 +53,112 @@ var sqlSchema = []string{
 	)`,
 	`INSERT INTO rb_version (version) VALUES (1)`}
 
+// List of DDL statements to upgrade database schema, element N upgrades schema from version N+1 to version N+2
+var sqlSchemaUpgrades = [][]string{
+	// version 2: trigger scripts
+	{
+		`CREATE TABLE rb_triggers (
+			basket_name varchar(250) PRIMARY KEY,
+			trigger_config text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 3: scheduled scripts
+	{
+		`CREATE TABLE rb_schedules (
+			basket_name varchar(250) PRIMARY KEY,
+			schedules text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 4: basket secrets
+	{
+		`CREATE TABLE rb_secrets (
+			basket_name varchar(250) NOT NULL,
+			secret_name varchar(100) NOT NULL,
+			secret_value text NOT NULL,
+			PRIMARY KEY (basket_name, secret_name),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 5: request IDs
+	{
+		`ALTER TABLE rb_requests ADD COLUMN request_id integer NOT NULL DEFAULT 0`,
+		`CREATE INDEX rb_requests_name_id_index ON rb_requests (basket_name, request_id)`},
+	// version 6: webhook subscriptions
+	{
+		`CREATE TABLE rb_webhooks (
+			basket_name varchar(250) PRIMARY KEY,
+			webhooks text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 7: time of the last change of collected requests
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN modified_at bigint NOT NULL DEFAULT 0`},
+	// version 8: state of webhook deliveries
+	{
+		`CREATE TABLE rb_webhook_deliveries (
+			basket_name varchar(250) PRIMARY KEY,
+			deliveries text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 9: read-only share tokens
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN share_token varchar(100) NOT NULL DEFAULT ''`},
+	// version 10: scoped access tokens
+	{
+		`CREATE TABLE rb_tokens (
+			basket_name varchar(250) PRIMARY KEY,
+			tokens text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 11: access control lists
+	{
+		`CREATE TABLE rb_acl (
+			basket_name varchar(250) PRIMARY KEY,
+			entries text NOT NULL,
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`},
+	// version 12: passwords of read-only web views
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN view_password varchar(250) NOT NULL DEFAULT ''`},
+	// version 13: capture rate limits
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN rate_limit varchar(250) NOT NULL DEFAULT ''`},
+	// version 14: body size limits
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN body_limit varchar(250) NOT NULL DEFAULT ''`},
+	// version 15: redaction rules
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN redaction varchar(4000) NOT NULL DEFAULT ''`},
+	// version 16: verification of webhook signatures
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN signature varchar(250) NOT NULL DEFAULT ''`},
+	// version 17: handling of headers of forwarded requests
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN forward_headers varchar(2000) NOT NULL DEFAULT ''`},
+	// version 18: public keys of body encryption
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN encryption varchar(2500) NOT NULL DEFAULT ''`},
+	// version 19: ignore filters
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN ignore_filters varchar(4000) NOT NULL DEFAULT ''`},
+	// version 20: retention period of collected requests
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN retention integer NOT NULL DEFAULT 0`},
+	// version 21: hash chain of collected requests
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN hash_chain boolean NOT NULL DEFAULT false`},
+	// version 22: expiration period of idle baskets
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN expires_after integer NOT NULL DEFAULT 0`},
+	// version 23: pause of basket
+	{
+		`ALTER TABLE rb_baskets ADD COLUMN pause varchar(250) NOT NULL DEFAULT ''`}}
+
+// Latest version of database schema for baskets
+var sqlSchemaVersion = len(sqlSchemaUpgrades) + 1
+
+// sqlDateRangeMargin defines tolerance in milliseconds between request date and creation time of database record
+const sqlDateRangeMargin = int64(1000)
+
 // Basket interface //
 type sqlBasket struct {
 	db     *sql.DB
@@ -105,12 +215,55 @@ func (basket *sqlBasket) getLastRequestDate() int64 {
 
 func (basket *sqlBasket) Config() BasketConfig {
 	config := BasketConfig{}
+	var ratej, bodyj, redactionj, signaturej, headersj, encryptionj, ignorej, pausej string
 
 	err := basket.db.QueryRow(
-		unifySQL(basket.dbType, "SELECT capacity, forward_url, proxy_response, insecure_tls, expand_path FROM rb_baskets WHERE basket_name = $1"),
-		basket.name).Scan(&config.Capacity, &config.ForwardURL, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath)
+		unifySQL(basket.dbType, "SELECT capacity, forward_url, proxy_response, insecure_tls, expand_path, rate_limit, body_limit, redaction, signature, forward_headers, encryption, ignore_filters, retention, hash_chain, expires_after, pause FROM rb_baskets WHERE basket_name = $1"),
+		basket.name).Scan(&config.Capacity, &config.ForwardURL, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath, &ratej, &bodyj,
+		&redactionj, &signaturej, &headersj, &encryptionj, &ignorej, &config.Retention, &config.HashChain, &config.ExpiresAfter, &pausej)
 	if err != nil {
 		log.Printf("[error] failed to get basket config: %s - %s", basket.name, err)
+		return config
+	}
+	if len(ratej) > 0 {
+		if err = json.Unmarshal([]byte(ratej), &config.RateLimit); err != nil {
+			log.Printf("[error] failed to parse rate limit of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(bodyj) > 0 {
+		if err = json.Unmarshal([]byte(bodyj), &config.BodyLimit); err != nil {
+			log.Printf("[error] failed to parse body size limit of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(redactionj) > 0 {
+		if err = json.Unmarshal([]byte(redactionj), &config.Redaction); err != nil {
+			log.Printf("[error] failed to parse redaction rules of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(signaturej) > 0 {
+		if err = json.Unmarshal([]byte(signaturej), &config.Signature); err != nil {
+			log.Printf("[error] failed to parse signature verification of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(headersj) > 0 {
+		if err = json.Unmarshal([]byte(headersj), &config.ForwardHeaders); err != nil {
+			log.Printf("[error] failed to parse forward headers of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(encryptionj) > 0 {
+		if err = json.Unmarshal([]byte(encryptionj), &config.Encryption); err != nil {
+			log.Printf("[error] failed to parse encryption key of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(ignorej) > 0 {
+		if err = json.Unmarshal([]byte(ignorej), &config.Ignore); err != nil {
+			log.Printf("[error] failed to parse ignore filters of basket: %s - %s", basket.name, err)
+		}
+	}
+	if len(pausej) > 0 {
+		if err = json.Unmarshal([]byte(pausej), &config.Pause); err != nil {
+			log.Printf("[error] failed to parse pause of basket: %s - %s", basket.name, err)
+		}
 	}
 
 	return config
@@ -118,28 +271,169 @@ func (basket *sqlBasket) Config() BasketConfig {
 
 func (basket *sqlBasket) Update(config BasketConfig) {
 	_, err := basket.db.Exec(
-		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, proxy_response = $3, insecure_tls = $4, expand_path = $5 WHERE basket_name = $6"),
-		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, basket.name)
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, proxy_response = $3, insecure_tls = $4, expand_path = $5, rate_limit = $6, body_limit = $7, redaction = $8, signature = $9, forward_headers = $10, encryption = $11, ignore_filters = $12, retention = $13, hash_chain = $14, expires_after = $15, pause = $16 WHERE basket_name = $17"),
+		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, toRateLimit(config.RateLimit),
+		toBodyLimit(config.BodyLimit), toRedaction(config.Redaction), toSignature(config.Signature), toForwardHeaders(config.ForwardHeaders),
+		toEncryption(config.Encryption), toIgnore(config.Ignore), config.Retention, config.HashChain, config.ExpiresAfter, toPause(config.Pause), basket.name)
 	if err != nil {
 		log.Printf("[error] failed to update basket config: %s - %s", basket.name, err)
 	} else {
 		// apply new basket limits
 		basket.applyLimit(config.Capacity)
+		basket.touch()
 	}
 }
 
 func (basket *sqlBasket) Authorize(token string) bool {
-	var found int
+	if len(token) == 0 {
+		return false
+	}
+
+	var stored string
 
 	err := basket.db.QueryRow(
-		unifySQL(basket.dbType, "SELECT COUNT(*) FROM rb_baskets WHERE basket_name = $1 AND token = $2"),
-		basket.name, token).Scan(&found)
+		unifySQL(basket.dbType, "SELECT token FROM rb_baskets WHERE basket_name = $1"), basket.name).Scan(&stored)
 	if err != nil {
-		log.Printf("[error] failed authorize access to basket: %s - %s", basket.name, err)
+		if err != sql.ErrNoRows {
+			log.Printf("[error] failed authorize access to basket: %s - %s", basket.name, err)
+		}
 		return false
 	}
 
-	return found > 0
+	valid, outdated := verifyBasketToken(stored, token)
+	if outdated {
+		// replace token stored by previous versions with its hash
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "UPDATE rb_baskets SET token = $1 WHERE basket_name = $2 AND token = $3"),
+			hashBasketToken(token), basket.name, stored)
+		if err != nil {
+			log.Printf("[error] failed to update token of basket: %s - %s", basket.name, err)
+		}
+	}
+
+	return valid
+}
+
+func (basket *sqlBasket) SetToken(token string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET token = $1 WHERE basket_name = $2"), hashBasketToken(token), basket.name)
+	if err != nil {
+		log.Printf("[error] failed to update token of basket: %s - %s", basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetShareToken() string {
+	var token string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT share_token FROM rb_baskets WHERE basket_name = $1"), basket.name).Scan(&token)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[error] failed to get share token of basket: %s - %s", basket.name, err)
+	}
+
+	return token
+}
+
+func (basket *sqlBasket) SetShareToken(token string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET share_token = $1 WHERE basket_name = $2"), token, basket.name)
+	if err != nil {
+		log.Printf("[error] failed to update share token of basket: %s - %s", basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetViewPassword() string {
+	var hash string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT view_password FROM rb_baskets WHERE basket_name = $1"), basket.name).Scan(&hash)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[error] failed to get view password of basket: %s - %s", basket.name, err)
+	}
+
+	return hash
+}
+
+func (basket *sqlBasket) SetViewPassword(hash string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET view_password = $1 WHERE basket_name = $2"), hash, basket.name)
+	if err != nil {
+		log.Printf("[error] failed to update view password of basket: %s - %s", basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetAccessTokens() []AccessToken {
+	var tokensj string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT tokens FROM rb_tokens WHERE basket_name = $1"), basket.name).Scan(&tokensj)
+	if err == sql.ErrNoRows {
+		// no access tokens for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get access tokens of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	var tokens []AccessToken
+	if err := json.Unmarshal([]byte(tokensj), &tokens); err != nil {
+		log.Printf("[error] failed to parse access tokens of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return tokens
+}
+
+func (basket *sqlBasket) SetAccessTokens(tokens []AccessToken) {
+	if tokensb, err := json.Marshal(tokens); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_tokens WHERE basket_name = $1"), basket.name)
+		// insert new tokens (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_tokens (basket_name, tokens) VALUES ($1, $2)"),
+			basket.name, string(tokensb))
+
+		if err != nil {
+			log.Printf("[error] failed to update access tokens of basket: %s - %s", basket.name, err)
+		}
+	}
+}
+
+func (basket *sqlBasket) GetACL() []ACLEntry {
+	var aclj string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT entries FROM rb_acl WHERE basket_name = $1"), basket.name).Scan(&aclj)
+	if err == sql.ErrNoRows {
+		// no ACL for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get ACL of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	var entries []ACLEntry
+	if err := json.Unmarshal([]byte(aclj), &entries); err != nil {
+		log.Printf("[error] failed to parse ACL of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return entries
+}
+
+func (basket *sqlBasket) SetACL(entries []ACLEntry) {
+	if aclb, err := json.Marshal(entries); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_acl WHERE basket_name = $1"), basket.name)
+		// insert new entries (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_acl (basket_name, entries) VALUES ($1, $2)"),
+			basket.name, string(aclb))
+
+		if err != nil {
+			log.Printf("[error] failed to update ACL of basket: %s - %s", basket.name, err)
+		}
+	}
 }
 
 func (basket *sqlBasket) GetResponse(method string) *ResponseConfig {
@@ -180,32 +474,352 @@ func (basket *sqlBasket) SetResponse(method string, response ResponseConfig) {
 	}
 }
 
-func (basket *sqlBasket) Add(req *http.Request) *RequestData {
-	data := ToRequestData(req)
-	if datab, err := json.Marshal(data); err == nil {
+func (basket *sqlBasket) GetTrigger() *TriggerConfig {
+	var trig string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT trigger_config FROM rb_triggers WHERE basket_name = $1"), basket.name).Scan(&trig)
+	if err == sql.ErrNoRows {
+		// no trigger for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get trigger of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	trigger := new(TriggerConfig)
+	if err := json.Unmarshal([]byte(trig), trigger); err != nil {
+		log.Printf("[error] failed to parse trigger of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return trigger
+}
+
+func (basket *sqlBasket) SetTrigger(trigger TriggerConfig) {
+	if trigb, err := json.Marshal(trigger); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_triggers WHERE basket_name = $1"), basket.name)
+		// insert new trigger (ignore concurrency)
 		_, err = basket.db.Exec(
-			unifySQL(basket.dbType, "INSERT INTO rb_requests (basket_name, request) VALUES ($1, $2)"), basket.name, string(datab))
+			unifySQL(basket.dbType, "INSERT INTO rb_triggers (basket_name, trigger_config) VALUES ($1, $2)"),
+			basket.name, string(trigb))
+
 		if err != nil {
-			log.Printf("[error] failed to collect incoming HTTP request in basket: %s - %s", basket.name, err)
-		} else {
-			// update global counter
-			_, err = basket.db.Exec(
-				unifySQL(basket.dbType, "UPDATE rb_baskets SET requests_count = requests_count + 1 WHERE basket_name = $1"), basket.name)
-			if err != nil {
-				log.Printf("[error] failed to update requests counter of basket: %s - %s", basket.name, err)
-			}
-			// apply limit if necessary
-			// TODO: replace 200 with serverConfig.InitCapacity
-			basket.applyLimit(basket.getInt("SELECT capacity FROM rb_baskets WHERE basket_name = $1", 200))
+			log.Printf("[error] failed to update trigger of basket: %s - %s", basket.name, err)
 		}
 	}
+}
+
+func (basket *sqlBasket) GetSchedules() []ScheduleConfig {
+	var scheds string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT schedules FROM rb_schedules WHERE basket_name = $1"), basket.name).Scan(&scheds)
+	if err == sql.ErrNoRows {
+		// no schedules for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get schedules of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	var schedules []ScheduleConfig
+	if err := json.Unmarshal([]byte(scheds), &schedules); err != nil {
+		log.Printf("[error] failed to parse schedules of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return schedules
+}
+
+func (basket *sqlBasket) SetSchedules(schedules []ScheduleConfig) {
+	if schedb, err := json.Marshal(schedules); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_schedules WHERE basket_name = $1"), basket.name)
+		// insert new schedules (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_schedules (basket_name, schedules) VALUES ($1, $2)"),
+			basket.name, string(schedb))
+
+		if err != nil {
+			log.Printf("[error] failed to update schedules of basket: %s - %s", basket.name, err)
+		}
+	}
+}
+
+func (basket *sqlBasket) GetSecrets() map[string]string {
+	secrets := make(map[string]string)
+
+	rows, err := basket.db.Query(
+		unifySQL(basket.dbType, "SELECT secret_name, secret_value FROM rb_secrets WHERE basket_name = $1"), basket.name)
+	if err != nil {
+		log.Printf("[error] failed to get secrets of basket: %s - %s", basket.name, err)
+		return secrets
+	}
+	defer rows.Close()
+
+	var name, value string
+	for rows.Next() {
+		if err = rows.Scan(&name, &value); err == nil {
+			secrets[name] = value
+		}
+	}
+
+	return secrets
+}
+
+func (basket *sqlBasket) SetSecret(name string, value string) {
+	// delete existing if present
+	basket.DeleteSecret(name)
+	// insert new secret (ignore concurrency)
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "INSERT INTO rb_secrets (basket_name, secret_name, secret_value) VALUES ($1, $2, $3)"),
+		basket.name, name, value)
+
+	if err != nil {
+		log.Printf("[error] failed to update secret %s of basket: %s - %s", name, basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) DeleteSecret(name string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_secrets WHERE basket_name = $1 AND secret_name = $2"), basket.name, name)
+	if err != nil {
+		log.Printf("[error] failed to delete secret %s of basket: %s - %s", name, basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetWebhooks() []WebhookConfig {
+	var hooks string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT webhooks FROM rb_webhooks WHERE basket_name = $1"), basket.name).Scan(&hooks)
+	if err == sql.ErrNoRows {
+		// no webhooks for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get webhooks of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	var webhooks []WebhookConfig
+	if err := json.Unmarshal([]byte(hooks), &webhooks); err != nil {
+		log.Printf("[error] failed to parse webhooks of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return webhooks
+}
+
+func (basket *sqlBasket) SetWebhooks(webhooks []WebhookConfig) {
+	if hooksb, err := json.Marshal(webhooks); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_webhooks WHERE basket_name = $1"), basket.name)
+		// insert new webhooks (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_webhooks (basket_name, webhooks) VALUES ($1, $2)"),
+			basket.name, string(hooksb))
+
+		if err != nil {
+			log.Printf("[error] failed to update webhooks of basket: %s - %s", basket.name, err)
+		}
+	}
+}
+
+func (basket *sqlBasket) GetWebhookDeliveries() []WebhookDelivery {
+	var deliveriesj string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT deliveries FROM rb_webhook_deliveries WHERE basket_name = $1"), basket.name).Scan(&deliveriesj)
+	if err == sql.ErrNoRows {
+		// no deliveries for this basket
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get webhook deliveries of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	var deliveries []WebhookDelivery
+	if err := json.Unmarshal([]byte(deliveriesj), &deliveries); err != nil {
+		log.Printf("[error] failed to parse webhook deliveries of basket: %s - %s", basket.name, err)
+		return nil
+	}
+
+	return deliveries
+}
+
+func (basket *sqlBasket) SetWebhookDeliveries(deliveries []WebhookDelivery) {
+	if deliveriesb, err := json.Marshal(deliveries); err == nil {
+		// delete existing if present
+		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_webhook_deliveries WHERE basket_name = $1"), basket.name)
+		// insert new deliveries (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_webhook_deliveries (basket_name, deliveries) VALUES ($1, $2)"),
+			basket.name, string(deliveriesb))
+
+		if err != nil {
+			log.Printf("[error] failed to update webhook deliveries of basket: %s - %s", basket.name, err)
+		}
+	}
+}
+
+func (basket *sqlBasket) Add(req *http.Request) *RequestData {
+	return basket.AddRequest(ToRequestData(req))
+}
+
+func (basket *sqlBasket) AddRequest(data *RequestData) *RequestData {
+	if err := basket.insertRequest(data); err != nil {
+		log.Printf("[error] failed to collect incoming HTTP request in basket: %s - %s", basket.name, err)
+	} else {
+		// apply limit if necessary
+		// TODO: replace 200 with serverConfig.InitCapacity
+		basket.applyLimit(basket.getInt("SELECT capacity FROM rb_baskets WHERE basket_name = $1", 200))
+	}
 
 	return data
 }
 
+// insertRequest stores request and updates global counter of basket, the updated counter is used as request ID
+func (basket *sqlBasket) insertRequest(data *RequestData) error {
+	tx, err := basket.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// update global counter
+	if _, err = tx.Exec(unifySQL(basket.dbType,
+		"UPDATE rb_baskets SET requests_count = requests_count + 1, modified_at = $1 WHERE basket_name = $2"),
+		time.Now().UnixNano()/toMs, basket.name); err != nil {
+		return fmt.Errorf("failed to update requests counter: %s", err)
+	}
+	if err = tx.QueryRow(unifySQL(basket.dbType,
+		"SELECT requests_count FROM rb_baskets WHERE basket_name = $1"), basket.name).Scan(&data.ID); err != nil {
+		return fmt.Errorf("failed to get requests counter: %s", err)
+	}
+
+	datab, err := encodeStoredRequest(data)
+	if err != nil {
+		return err
+	}
+	// creation time follows request date, which may be in the past if request is copied from another basket
+	if _, err = tx.Exec(unifySQL(basket.dbType,
+		"INSERT INTO rb_requests (basket_name, request_id, request, created_at) VALUES ($1, $2, $3, "+sqlFromUnixMs(basket.dbType, 4)+")"),
+		basket.name, data.ID, string(datab), data.Date); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (basket *sqlBasket) UpdateRequest(id int, update func(data *RequestData)) {
+	updated, err := basket.updateRequest(id, update)
+	if err != nil {
+		log.Printf("[error] failed to update HTTP request %d of basket: %s - %s", id, basket.name, err)
+	} else if updated {
+		basket.touch()
+	}
+}
+
+// updateRequest reads, changes and writes back stored request within a transaction, the row is locked until
+// the transaction is committed, so concurrent updates of the same request do not overwrite each other
+func (basket *sqlBasket) updateRequest(id int, update func(data *RequestData)) (bool, error) {
+	tx, err := basket.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var req string
+	err = tx.QueryRow(unifySQL(basket.dbType,
+		"SELECT request FROM rb_requests WHERE basket_name = $1 AND request_id = $2 FOR UPDATE"), basket.name, id).Scan(&req)
+	if err == sql.ErrNoRows {
+		// request is already evicted
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	data := new(RequestData)
+	if err = decodeStoredRequest([]byte(req), data); err != nil {
+		return false, fmt.Errorf("failed to parse request: %s", err)
+	}
+	update(data)
+	datab, err := encodeStoredRequest(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode request: %s", err)
+	}
+
+	if _, err = tx.Exec(unifySQL(basket.dbType,
+		"UPDATE rb_requests SET request = $1 WHERE basket_name = $2 AND request_id = $3"), string(datab), basket.name, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (basket *sqlBasket) DeleteRequests(ids []int) int {
+	if len(ids) == 0 {
+		return 0
+	}
+
+	args := []interface{}{basket.name}
+	params := make([]string, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+		params = append(params, fmt.Sprintf("$%d", len(args)))
+	}
+
+	result, err := basket.db.Exec(unifySQL(basket.dbType,
+		"DELETE FROM rb_requests WHERE basket_name = $1 AND request_id IN ("+strings.Join(params, ", ")+")"), args...)
+	if err != nil {
+		log.Printf("[error] failed to delete requests in basket: %s - %s", basket.name, err)
+		return 0
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[error] failed to get number of deleted requests in basket: %s - %s", basket.name, err)
+		return 0
+	}
+	if deleted > 0 {
+		basket.touch()
+	}
+	return int(deleted)
+}
+
+func (basket *sqlBasket) DeleteRequestsBefore(date int64) int {
+	result, err := basket.db.Exec(unifySQL(basket.dbType,
+		"DELETE FROM rb_requests WHERE basket_name = $1 AND created_at < "+sqlFromUnixMs(basket.dbType, 2)), basket.name, date)
+	if err != nil {
+		log.Printf("[error] failed to delete expired requests in basket: %s - %s", basket.name, err)
+		return 0
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[error] failed to get number of deleted requests in basket: %s - %s", basket.name, err)
+		return 0
+	}
+	if deleted > 0 {
+		basket.touch()
+	}
+	return int(deleted)
+}
+
 func (basket *sqlBasket) Clear() {
 	if _, err := basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_requests WHERE basket_name = $1"), basket.name); err != nil {
 		log.Printf("[error] failed to delete collected requests in basket: %s - %s", basket.name, err)
+	} else {
+		basket.touch()
+	}
+}
+
+// touch records the time of change of collected requests
+func (basket *sqlBasket) touch() {
+	if _, err := basket.db.Exec(unifySQL(basket.dbType, "UPDATE rb_baskets SET modified_at = $1 WHERE basket_name = $2"),
+		time.Now().UnixNano()/toMs, basket.name); err != nil {
+		log.Printf("[error] failed to update modification time of basket: %s - %s", basket.name, err)
 	}
 }
 
@@ -213,44 +827,137 @@ func (basket *sqlBasket) Size() int {
 	return basket.getInt("SELECT COUNT(*) FROM rb_requests WHERE basket_name = $1", 0)
 }
 
+func (basket *sqlBasket) LastModified() int64 {
+	var modified int64
+	if err := basket.db.QueryRow(unifySQL(basket.dbType,
+		"SELECT modified_at FROM rb_baskets WHERE basket_name = $1"), basket.name).Scan(&modified); err != nil {
+		log.Printf("[error] failed to get modification time of basket: %s - %s", basket.name, err)
+	}
+	return modified
+}
+
+func (basket *sqlBasket) GetRequest(id int) *RequestData {
+	var req string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 AND request_id = $2"),
+		basket.name, id).Scan(&req)
+	if err == sql.ErrNoRows {
+		// unknown or evicted request
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get HTTP request %d of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	request := new(RequestData)
+	if err := decodeStoredRequest([]byte(req), request); err != nil {
+		log.Printf("[error] failed to parse HTTP request %d of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	return request
+}
+
 func (basket *sqlBasket) GetRequests(max int, skip int) RequestsPage {
-	page := RequestsPage{make([]*RequestData, 0, max), basket.Size(), basket.getTotalRequestsCount(), false}
+	page := RequestsPage{make([]*RequestData, 0, pageCapacity(max)), basket.Size(), basket.getTotalRequestsCount(), false, ""}
 
 	if max > 0 {
-		requests, err := basket.db.Query(
-			unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3"),
+		basket.readRequests(&page, max,
+			"SELECT request FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3",
 			basket.name, max+1, skip)
-		if err != nil {
-			log.Printf("[error] failed to get requests of basket: %s - %s", basket.name, err)
-			return page
-		}
-		defer requests.Close()
+	} else {
+		page.HasMore = page.Count > skip
+	}
 
-		var req string
-		for len(page.Requests) < max && requests.Next() {
-			if err = requests.Scan(&req); err == nil {
-				request := new(RequestData)
-				if err = json.Unmarshal([]byte(req), request); err != nil {
-					log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
-				} else {
-					page.Requests = append(page.Requests, request)
-				}
-			}
-		}
+	return page
+}
 
-		page.HasMore = requests.Next()
+func (basket *sqlBasket) GetRequestsBefore(id int, max int) RequestsPage {
+	page := RequestsPage{make([]*RequestData, 0, pageCapacity(max)), basket.Size(), basket.getTotalRequestsCount(), false, ""}
+
+	if max > 0 {
+		basket.readRequests(&page, max,
+			"SELECT request FROM rb_requests WHERE basket_name = $1 AND request_id < $2 ORDER BY created_at DESC LIMIT $3",
+			basket.name, id, max+1)
 	} else {
-		page.HasMore = page.Count > skip
+		page.HasMore = page.Count > 0
 	}
 
 	return page
 }
 
-func (basket *sqlBasket) FindRequests(query string, in string, max int, skip int) RequestsQueryPage {
-	page := RequestsQueryPage{make([]*RequestData, 0, max), false}
+// readRequests reads up to max requests selected by SQL query into the page
+func (basket *sqlBasket) readRequests(page *RequestsPage, max int, sql string, args ...interface{}) {
+	requests, err := basket.db.Query(unifySQL(basket.dbType, sql), args...)
+	if err != nil {
+		log.Printf("[error] failed to get requests of basket: %s - %s", basket.name, err)
+		return
+	}
+	defer requests.Close()
+
+	var req string
+	for len(page.Requests) < max && requests.Next() {
+		if err = requests.Scan(&req); err == nil {
+			request := new(RequestData)
+			if err = decodeStoredRequest([]byte(req), request); err != nil {
+				log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
+			} else {
+				page.Requests = append(page.Requests, request)
+			}
+		}
+	}
+
+	page.HasMore = requests.Next()
+	if page.HasMore {
+		page.NextCursor = requestsCursor(page.Requests)
+	}
+}
+
+// findRequestsSQL builds SQL query to find requests, the date range of query is pushed down to database;
+// since creation time of database record may slightly differ from request date the range is widened
+// by sqlDateRangeMargin and the exact match is done later by RequestData.Matches
+func (basket *sqlBasket) findRequestsSQL(query *RequestsQuery) (string, []interface{}) {
+	sql := "SELECT request FROM rb_requests WHERE basket_name = $1"
+	args := []interface{}{basket.name}
+
+	if query.From > 0 {
+		args = append(args, query.From-sqlDateRangeMargin)
+		sql += " AND created_at >= " + sqlFromUnixMs(basket.dbType, len(args))
+	}
+	if query.To > 0 {
+		args = append(args, query.To+sqlDateRangeMargin)
+		sql += " AND created_at <= " + sqlFromUnixMs(basket.dbType, len(args))
+	}
+	if query.Before > 0 {
+		args = append(args, query.Before)
+		sql += fmt.Sprintf(" AND request_id < $%d", len(args))
+	}
+
+	// substring search is pushed down to database as a coarse filter over JSON representation of request
+	// (it can be backed by a text index of database), the exact match is done later by RequestData.Matches;
+	// requests with compressed body always pass the filter, since their bodies are searched once decompressed
+	if _, ok := query.IndexTokens(); ok {
+		if pattern, err := sqlLikePattern(query.Text); err == nil {
+			args = append(args, pattern, sqlCompressedBodyPattern)
+			if query.IgnoreCase {
+				sql += fmt.Sprintf(" AND (LOWER(request) LIKE LOWER($%d) ESCAPE '!' OR request LIKE $%d ESCAPE '!')",
+					len(args)-1, len(args))
+			} else {
+				sql += fmt.Sprintf(" AND (request LIKE $%d ESCAPE '!' OR request LIKE $%d ESCAPE '!')",
+					len(args)-1, len(args))
+			}
+		}
+	}
+
+	return sql + " ORDER BY created_at DESC", args
+}
+
+func (basket *sqlBasket) FindRequests(query *RequestsQuery, max int, skip int) RequestsQueryPage {
+	page := RequestsQueryPage{make([]*RequestData, 0, pageCapacity(max)), false, "", nil}
 	if max > 0 {
-		requests, err := basket.db.Query(
-			unifySQL(basket.dbType, "SELECT request FROM rb_requests WHERE basket_name = $1 ORDER BY created_at DESC"), basket.name)
+		sql, args := basket.findRequestsSQL(query)
+		requests, err := basket.db.Query(unifySQL(basket.dbType, sql), args...)
 		if err != nil {
 			log.Printf("[error] failed to find requests of basket: %s - %s", basket.name, err)
 			return page
@@ -262,11 +969,11 @@ func (basket *sqlBasket) FindRequests(query string, in string, max int, skip int
 		for len(page.Requests) < max && requests.Next() {
 			if err = requests.Scan(&req); err == nil {
 				request := new(RequestData)
-				if err = json.Unmarshal([]byte(req), request); err != nil {
+				if err = decodeStoredRequest([]byte(req), request); err != nil {
 					log.Printf("[error] failed to parse HTTP request data in basket: %s - %s", basket.name, err)
 				} else {
 					// filter
-					if request.Matches(query, in) {
+					if request.Matches(query) {
 						if skipped < skip {
 							skipped++
 						} else {
@@ -277,6 +984,9 @@ func (basket *sqlBasket) FindRequests(query string, in string, max int, skip int
 			}
 		}
 		page.HasMore = requests.Next()
+		if page.HasMore {
+			page.NextCursor = requestsCursor(page.Requests)
+		}
 	} else {
 		page.HasMore = true
 	}
@@ -301,6 +1011,21 @@ func (sdb *sqlDatabase) getInt(sql string, defaultValue int) int {
 	return value
 }
 
+// getStorageBytes returns bytes used by tables of baskets including indexes, 0 if database does not report them
+func (sdb *sqlDatabase) getStorageBytes() int64 {
+	switch sdb.dbType {
+	case "postgres":
+		return int64(sdb.getInt("SELECT COALESCE(SUM(pg_total_relation_size(c.oid)), 0) FROM pg_class c "+
+			"JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind = 'r' AND c.relname LIKE 'rb\\_%' "+
+			"AND n.nspname = current_schema()", 0))
+	case "mysql":
+		return int64(sdb.getInt("SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables "+
+			"WHERE table_schema = DATABASE() AND table_name LIKE 'rb\\_%'", 0))
+	default:
+		return 0
+	}
+}
+
 func (sdb *sqlDatabase) getTopBaskets(sql string, max int) []*BasketInfo {
 	top := make([]*BasketInfo, 0, max)
 	names, err := sdb.db.Query(unifySQL(sdb.dbType, sql), max)
@@ -340,8 +1065,10 @@ func (sdb *sqlDatabase) Create(name string, config BasketConfig) (BasketAuth, er
 	}
 
 	basket, err := sdb.db.Exec(
-		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path) VALUES($1, $2, $3, $4, $5, $6, $7)"),
-		name, token, config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath)
+		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path, rate_limit, body_limit, redaction, signature, forward_headers, encryption, ignore_filters, retention, hash_chain, expires_after, pause) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)"),
+		name, hashBasketToken(token), config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath,
+		toRateLimit(config.RateLimit), toBodyLimit(config.BodyLimit), toRedaction(config.Redaction), toSignature(config.Signature),
+		toForwardHeaders(config.ForwardHeaders), toEncryption(config.Encryption), toIgnore(config.Ignore), config.Retention, config.HashChain, config.ExpiresAfter, toPause(config.Pause))
 	if err != nil {
 		return auth, fmt.Errorf("failed to create basket: %s - %s", name, err)
 	}
@@ -375,17 +1102,67 @@ func (sdb *sqlDatabase) Delete(name string) {
 	}
 }
 
+func (sdb *sqlDatabase) Rename(name string, newName string) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// basket name is referenced by other tables, so basket record is copied under the new name first,
+	// then all related records are moved to it and the old record is deleted
+	result, err := tx.Exec(unifySQL(sdb.dbType,
+		`INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path, requests_count, created_at, modified_at, share_token, view_password, rate_limit, body_limit, redaction, signature, forward_headers, encryption, ignore_filters, retention, hash_chain, expires_after, pause)
+		SELECT $1, token, capacity, forward_url, proxy_response, insecure_tls, expand_path, requests_count, created_at, modified_at, share_token, view_password, rate_limit, body_limit, redaction, signature, forward_headers, encryption, ignore_filters, retention, hash_chain, expires_after, pause
+		FROM rb_baskets WHERE basket_name = $2`), newName, name)
+	if err != nil {
+		return fmt.Errorf("failed to create basket: %s - %s", newName, err)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return fmt.Errorf("failed to locate basket: %s", name)
+	}
+
+	for _, table := range []string{"rb_responses", "rb_requests", "rb_triggers", "rb_schedules", "rb_secrets", "rb_webhooks",
+		"rb_webhook_deliveries", "rb_tokens", "rb_acl"} {
+		if _, err = tx.Exec(unifySQL(sdb.dbType,
+			"UPDATE "+table+" SET basket_name = $1 WHERE basket_name = $2"), newName, name); err != nil {
+			return fmt.Errorf("failed to rename basket: %s - %s", name, err)
+		}
+	}
+	if _, err = tx.Exec(unifySQL(sdb.dbType, "DELETE FROM rb_baskets WHERE basket_name = $1"), name); err != nil {
+		return fmt.Errorf("failed to rename basket: %s - %s", name, err)
+	}
+
+	return tx.Commit()
+}
+
 func (sdb *sqlDatabase) Size() int {
 	return sdb.getInt("SELECT COUNT(*) FROM rb_baskets", 0)
 }
 
 func (sdb *sqlDatabase) GetNames(max int, skip int) BasketNamesPage {
-	page := BasketNamesPage{make([]string, 0, max), sdb.Size(), false}
+	page := BasketNamesPage{make([]string, 0, max), sdb.Size(), false, ""}
+	sdb.readNames(&page, max, "SELECT basket_name FROM rb_baskets ORDER BY basket_name LIMIT $1 OFFSET $2", max+1, skip)
+
+	return page
+}
+
+func (sdb *sqlDatabase) GetNamesAfter(position string, max int) BasketNamesPage {
+	// position is the last name on previous page, names are sorted
+	page := BasketNamesPage{make([]string, 0, max), sdb.Size(), false, ""}
+	sdb.readNames(&page, max, "SELECT basket_name FROM rb_baskets WHERE basket_name > $1 ORDER BY basket_name LIMIT $2", position, max+1)
 
-	names, err := sdb.db.Query(unifySQL(sdb.dbType, "SELECT basket_name FROM rb_baskets ORDER BY basket_name LIMIT $1 OFFSET $2"), max+1, skip)
+	return page
+}
+
+// readNames reads up to max basket names selected by SQL query into the page
+func (sdb *sqlDatabase) readNames(page *BasketNamesPage, max int, sql string, args ...interface{}) {
+	names, err := sdb.db.Query(unifySQL(sdb.dbType, sql), args...)
 	if err != nil {
 		log.Printf("[error] failed to get basket names: %s", err)
-		return page
+		return
 	}
 	defer names.Close()
 
@@ -397,8 +1174,9 @@ func (sdb *sqlDatabase) GetNames(max int, skip int) BasketNamesPage {
 	}
 
 	page.HasMore = names.Next()
-
-	return page
+	if page.HasMore && len(page.Names) > 0 {
+		page.NextCursor = encodeCursor(page.Names[len(page.Names)-1])
+	}
 }
 
 func (sdb *sqlDatabase) FindNames(query string, max int, skip int) BasketNamesQueryPage {
@@ -437,10 +1215,37 @@ func (sdb *sqlDatabase) GetStats(max int) DatabaseStats {
 	stats.TopBasketsBySize = sdb.getTopBaskets("SELECT basket_name FROM rb_baskets ORDER BY requests_count DESC LIMIT $1", max)
 	stats.TopBasketsByDate = sdb.getTopBaskets("SELECT basket_name FROM rb_requests GROUP BY basket_name ORDER BY MAX(created_at) DESC LIMIT $1", max)
 
+	stats.StorageBackend = DbTypeSQL
+	stats.StorageBytes = sdb.getStorageBytes()
 	stats.UpdateAvarage()
 	return stats
 }
 
+func (sdb *sqlDatabase) Compact() error {
+	var statements []string
+	switch sdb.dbType {
+	case "postgres":
+		statements = []string{"VACUUM ANALYZE rb_requests", "VACUUM ANALYZE rb_baskets"}
+	case "mysql":
+		statements = []string{"OPTIMIZE TABLE rb_requests, rb_baskets"}
+	default:
+		statements = []string{"VACUUM"}
+	}
+
+	for _, statement := range statements {
+		if _, err := sdb.db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to compact SQL database: %s", err)
+		}
+	}
+	return nil
+}
+
+func (sdb *sqlDatabase) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlPingTimeout)
+	defer cancel()
+	return sdb.db.PingContext(ctx)
+}
+
 func (sdb *sqlDatabase) Release() {
 	log.Printf("[info] closing SQL database, releasing any open resources")
 	sdb.db.Close()
@@ -476,6 +1281,108 @@ func NewSQLDatabase(connection string) BasketsDatabase {
 
 var pgParams = regexp.MustCompile(`\$\d+`)
 
+// toRateLimit encodes capture rate limit of basket for rate_limit column, empty string stands for no limit
+func toRateLimit(limit *CaptureLimit) string {
+	if limit == nil {
+		return ""
+	}
+	limitj, _ := json.Marshal(limit)
+	return string(limitj)
+}
+
+// toBodyLimit encodes body size limit of basket for body_limit column, empty string stands for the limit of service
+func toBodyLimit(limit *BodyLimit) string {
+	if limit == nil {
+		return ""
+	}
+	limitj, _ := json.Marshal(limit)
+	return string(limitj)
+}
+
+// toRedaction encodes redaction rules of basket for redaction column, empty string stands for no rules
+func toRedaction(rules []RedactionRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	rulesj, _ := json.Marshal(rules)
+	return string(rulesj)
+}
+
+// toSignature encodes signature verification of basket for signature column, empty string stands for no verification
+func toSignature(check *SignatureCheck) string {
+	if check == nil {
+		return ""
+	}
+	checkj, _ := json.Marshal(check)
+	return string(checkj)
+}
+
+// toForwardHeaders encodes handling of forwarded headers of basket for forward_headers column, empty string stands
+// for the defaults
+func toForwardHeaders(headers *ForwardHeaders) string {
+	if headers == nil {
+		return ""
+	}
+	headersj, _ := json.Marshal(headers)
+	return string(headersj)
+}
+
+// toEncryption encodes public key of basket for encryption column, empty string stands for no encryption
+func toEncryption(encryption *EncryptionKey) string {
+	if encryption == nil {
+		return ""
+	}
+	encryptionj, _ := json.Marshal(encryption)
+	return string(encryptionj)
+}
+
+// toPause encodes pause of basket for pause column, empty string stands for not paused basket
+func toPause(pause *BasketPause) string {
+	if pause == nil {
+		return ""
+	}
+	pausej, _ := json.Marshal(pause)
+	return string(pausej)
+}
+
+// toIgnore encodes ignore filters of basket for ignore_filters column, empty string stands for no filters
+func toIgnore(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	filtersj, _ := json.Marshal(filters)
+	return string(filtersj)
+}
+
+// sqlCompressedBodyPattern is LIKE pattern that matches JSON of requests stored with compressed body
+const sqlCompressedBodyPattern = `%"body!_compression":"gzip"%`
+
+// sqlLikePattern converts text into LIKE pattern that matches JSON representation of the text
+func sqlLikePattern(text string) (string, error) {
+	encoded, err := json.Marshal(text)
+	if err != nil {
+		return "", err
+	}
+
+	// strip quotes of JSON string
+	pattern := string(encoded[1 : len(encoded)-1])
+	pattern = strings.Replace(pattern, "!", "!!", -1)
+	pattern = strings.Replace(pattern, "%", "!%", -1)
+	pattern = strings.Replace(pattern, "_", "!_", -1)
+
+	return "%" + pattern + "%", nil
+}
+
+// sqlFromUnixMs returns SQL expression to convert n-th query parameter from milliseconds since epoch into timestamp
+func sqlFromUnixMs(dbType string, n int) string {
+	switch dbType {
+	case "mysql":
+		return fmt.Sprintf("FROM_UNIXTIME($%d / 1000)", n)
+	default:
+		return fmt.Sprintf("CAST(TO_TIMESTAMP($%d / 1000.0) AS timestamp)", n)
+	}
+}
+
 func unifySQL(dbType string, sql string) string {
 	switch dbType {
 	case "mysql", "sqlite3":
@@ -510,10 +1417,15 @@ func parseConnection(connection string) (string, string) {
 }
 
 func initSchema(db *sql.DB) error {
-	switch version := getSchemaVersion(db); version {
-	case 0:
-		return createSchema(db)
-	case 1:
+	switch version := getSchemaVersion(db); {
+	case version == 0:
+		if err := createSchema(db); err != nil {
+			return err
+		}
+		return upgradeSchema(db, 1)
+	case version < sqlSchemaVersion:
+		return upgradeSchema(db, version)
+	case version == sqlSchemaVersion:
 		log.Printf("[info] database schema already exists, version: %v", version)
 		return nil
 	default:
@@ -541,3 +1453,20 @@ func createSchema(db *sql.DB) error {
 	log.Printf("[info] database is created, version: %v", getSchemaVersion(db))
 	return nil
 }
+
+func upgradeSchema(db *sql.DB, version int) error {
+	for ; version < sqlSchemaVersion; version++ {
+		log.Printf("[info] upgrading database schema to version: %v", version+1)
+		for idx, stmt := range sqlSchemaUpgrades[version-1] {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("error in SQL statement #%v of schema upgrade to version %v - %s", idx, version+1, err)
+			}
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("UPDATE rb_version SET version = %d", version+1)); err != nil {
+			return fmt.Errorf("failed to update database schema version - %s", err)
+		}
+	}
+
+	return nil
+}
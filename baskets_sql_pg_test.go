@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -79,6 +82,56 @@ func TestPgSQLDatabase_Delete(t *testing.T) {
 	assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
 }
 
+func TestPgSQLDatabase_Rename(t *testing.T) {
+	name := "test5r"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	newName := name + "_renamed"
+	defer db.Delete(name)
+	defer db.Delete(newName)
+
+	auth, _ := db.Create(name, BasketConfig{Capacity: 10, ForwardURL: "http://localhost:12345/test"})
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestPOSTRequest("http://localhost/"+name, "req1", "text/plain"))
+		basket.Add(createTestPOSTRequest("http://localhost/"+name, "req2", "text/plain"))
+		basket.SetResponse("GET", ResponseConfig{Status: 202, Body: "accepted"})
+		basket.SetSecret("API_KEY", "s3cr3t")
+
+		if assert.NoError(t, db.Rename(name, newName)) {
+			assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
+
+			renamed := db.Get(newName)
+			if assert.NotNil(t, renamed, "basket with name: %v is expected", newName) {
+				assert.True(t, renamed.Authorize(auth.Token), "basket token is expected to be preserved")
+				assert.Equal(t, "http://localhost:12345/test", renamed.Config().ForwardURL, "wrong forward URL")
+				assert.Equal(t, 2, renamed.Size(), "wrong number of requests")
+				page := renamed.GetRequests(10, 0)
+				assert.Equal(t, 2, page.TotalCount, "wrong total count of requests")
+				if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+					assert.Equal(t, "req2", page.Requests[0].Body, "wrong request body")
+				}
+				assert.Len(t, renamed.FindRequests(NewTextQuery("req1", "body"), 10, 0).Requests, 1, "wrong number of found requests")
+				if response := renamed.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+					assert.Equal(t, "accepted", response.Body, "wrong response body")
+				}
+				assert.Equal(t, "s3cr3t", renamed.GetSecrets()["API_KEY"], "secret is expected to be preserved")
+
+				// request IDs continue
+				data := renamed.Add(createTestPOSTRequest("http://localhost/"+newName, "req3", "text/plain"))
+				assert.Equal(t, 3, data.ID, "wrong request ID")
+			}
+		}
+
+		// unknown basket and name conflicts
+		assert.Error(t, db.Rename(name, name+"_other"), "unknown basket cannot be renamed")
+		db.Create(name, BasketConfig{Capacity: 10})
+		assert.Error(t, db.Rename(name, newName), "basket cannot be renamed to existing name")
+		assert.NotNil(t, db.Get(name), "basket with name: %v is expected", name)
+	}
+}
+
 func TestPgSQLDatabase_Delete_Multi(t *testing.T) {
 	name := "test6"
 	db := NewSQLDatabase(pgTestConnection)
@@ -148,6 +201,47 @@ func TestPgSQLDatabase_GetNames(t *testing.T) {
 	assert.False(t, db.GetNames(5, 40).HasMore, "no more names are expected")
 }
 
+func TestPgSQLDatabase_GetNamesAfter(t *testing.T) {
+	name := "test8c"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 45; i++ {
+		db.Create(fmt.Sprintf("%s_%v", name, i), config)
+		defer db.Delete(fmt.Sprintf("%s_%v", name, i))
+	}
+
+	// iterate names with cursor while baskets are created and deleted
+	page := db.GetNames(10, 0)
+	seen := make(map[string]int)
+	for i := 0; ; i++ {
+		for _, n := range page.Names {
+			seen[n]++
+		}
+		if !page.HasMore {
+			break
+		}
+		if i == 1 {
+			// delete the last seen basket and create new basket
+			db.Delete(page.Names[len(page.Names)-1])
+			db.Create(name+"_99", config)
+			defer db.Delete(name + "_99")
+		}
+		if assert.NotEmpty(t, page.NextCursor, "cursor is expected") {
+			position, err := DecodeCursor(page.NextCursor)
+			if assert.NoError(t, err) {
+				page = db.GetNamesAfter(position, 10)
+			}
+		}
+	}
+
+	for i := 0; i < 45; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("%s_%v", name, i)], "basket name is expected exactly once: %v", fmt.Sprintf("%s_%v", name, i))
+	}
+	assert.Empty(t, page.NextCursor, "no cursor is expected on the last page")
+}
+
 func TestPgSQLDatabase_FindNames(t *testing.T) {
 	name := "test9"
 	db := NewSQLDatabase(pgTestConnection)
@@ -248,6 +342,39 @@ func TestPgSQLBasket_Clear(t *testing.T) {
 	}
 }
 
+func TestPgSQLBasket_LastModified(t *testing.T) {
+	name := "test103"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Equal(t, int64(0), basket.LastModified(), "unchanged basket is not expected to have modification time")
+
+		// every change of collected requests is recorded
+		changes := []func(){
+			func() { basket.Add(createTestPOSTRequest("http://localhost/"+name, "test", "text/plain")) },
+			func() { basket.UpdateRequest(1, func(data *RequestData) { data.ResponseStatus = 201 }) },
+			func() { basket.DeleteRequests([]int{1}) },
+			func() { basket.Clear() }}
+		modified := int64(0)
+		for i, change := range changes {
+			time.Sleep(2 * time.Millisecond)
+			change()
+			assert.True(t, basket.LastModified() > modified, "modification time is expected to grow after change %d", i)
+			modified = basket.LastModified()
+		}
+
+		// unknown requests are not deleted
+		time.Sleep(2 * time.Millisecond)
+		basket.DeleteRequests([]int{10})
+		assert.Equal(t, modified, basket.LastModified(), "modification time is not expected to change")
+	}
+}
+
 func TestPgSQLBasket_Update_Shrink(t *testing.T) {
 	name := "test104"
 	db := NewSQLDatabase(pgTestConnection)
@@ -341,7 +468,7 @@ func TestPgSQLBasket_FindRequests(t *testing.T) {
 		assert.Equal(t, 30, basket.Size(), "wrong basket size")
 
 		// search everywhere
-		s1 := basket.FindRequests("req1", "any", 30, 0)
+		s1 := basket.FindRequests(NewTextQuery("req1", "any"), 30, 0)
 		assert.False(t, s1.HasMore, "no more results are expected")
 		assert.Len(t, s1.Requests, 11, "wrong number of found requests")
 		for _, r := range s1.Requests {
@@ -349,28 +476,393 @@ func TestPgSQLBasket_FindRequests(t *testing.T) {
 		}
 
 		// search everywhere (limited output)
-		s2 := basket.FindRequests("req2", "any", 5, 5)
+		s2 := basket.FindRequests(NewTextQuery("req2", "any"), 5, 5)
 		assert.True(t, s2.HasMore, "more results are expected")
 		assert.Len(t, s2.Requests, 5, "wrong number of found requests")
 
 		// search everywhere with max = 0
-		assert.Empty(t, basket.FindRequests("req2", "any", 0, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("req2", "any"), 0, 0).Requests, "found unexpected requests")
 
 		// search in body (positive)
-		assert.Len(t, basket.FindRequests("req3", "body", 100, 0).Requests, 2, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("req3", "body"), 100, 0).Requests, 2, "wrong number of found requests")
 		// search in body (negative)
-		assert.Empty(t, basket.FindRequests("yummy", "body", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("yummy", "body"), 100, 0).Requests, "found unexpected requests")
 
 		// search in headers (positive)
-		assert.Len(t, basket.FindRequests("yummy", "headers", 100, 0).Requests, 10, "wrong number of found requests")
-		assert.Len(t, basket.FindRequests("tasty", "headers", 100, 0).Requests, 20, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("yummy", "headers"), 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("tasty", "headers"), 100, 0).Requests, 20, "wrong number of found requests")
 		// search in headers (negative)
-		assert.Empty(t, basket.FindRequests("req1", "headers", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("req1", "headers"), 100, 0).Requests, "found unexpected requests")
 
 		// search in query (positive)
-		assert.Len(t, basket.FindRequests("id=1", "query", 100, 0).Requests, 11, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("id=1", "query"), 100, 0).Requests, 11, "wrong number of found requests")
 		// search in query (negative)
-		assert.Empty(t, basket.FindRequests("tasty", "query", 100, 0).Requests, "found unexpected requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("tasty", "query"), 100, 0).Requests, "found unexpected requests")
+	}
+}
+
+func TestPgSQLBasket_FindRequests_DateRange(t *testing.T) {
+	name := "test106d"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 100})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket with 3 groups of requests separated in time
+		bounds := make([]int64, 0, 2)
+		for g := 1; g <= 3; g++ {
+			for i := 1; i <= 5; i++ {
+				basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?group=%v", name, g), fmt.Sprintf("req%v-%v", g, i), "text/plain"))
+			}
+			if g < 3 {
+				time.Sleep(20 * time.Millisecond)
+				bounds = append(bounds, time.Now().UnixNano()/toMs)
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+
+		// requests from the middle group
+		query := NewTextQuery("", "any")
+		query.From, query.To = bounds[0], bounds[1]
+		page := basket.FindRequests(query, 100, 0)
+		if assert.Len(t, page.Requests, 5, "wrong number of found requests") {
+			for _, r := range page.Requests {
+				assert.Contains(t, r.Body, "req2-", "incorrect request among results")
+			}
+		}
+
+		// requests since the start of second group with text search
+		query = NewTextQuery("-1", "body")
+		query.From = bounds[0]
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 2, "wrong number of found requests")
+
+		// requests until the end of first group
+		query = NewTextQuery("", "any")
+		query.To = bounds[0]
+		page = basket.FindRequests(query, 3, 0)
+		assert.True(t, page.HasMore, "more results are expected")
+		if assert.Len(t, page.Requests, 3, "wrong number of found requests") {
+			assert.Equal(t, "req1-5", page.Requests[0].Body, "newest request is expected first")
+		}
+	}
+}
+
+func TestPgSQLBasket_FindRequests_AfterEviction(t *testing.T) {
+	name := "test106e"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket over capacity
+		for i := 1; i <= 15; i++ {
+			r := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("{\"order_id\":\"ord-%v\"}", i), "application/json")
+			basket.Add(r)
+		}
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+
+		// evicted requests are not found
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord-5\"", "body"), 100, 0).Requests, "found unexpected requests")
+		page := basket.FindRequests(NewTextQuery("ord-1", "body"), 100, 0)
+		if assert.Len(t, page.Requests, 6, "wrong number of found requests") {
+			assert.Contains(t, page.Requests[0].Body, "ord-15", "newest request is expected first")
+		}
+		assert.Len(t, basket.FindRequests(NewTextQuery("order_id\":\"ord", "body"), 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests(NewTextQuery("json", "headers"), 3, 0).Requests, 3, "wrong number of found requests")
+
+		// shrink basket
+		config := basket.Config()
+		config.Capacity = 5
+		basket.Update(config)
+		assert.Len(t, basket.FindRequests(NewTextQuery("ord-1", "body"), 100, 0).Requests, 5, "wrong number of found requests")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord-10\"", "body"), 100, 0).Requests, "found unexpected requests")
+
+		// clear basket
+		basket.Clear()
+		assert.Empty(t, basket.FindRequests(NewTextQuery("ord", "body"), 100, 0).Requests, "found unexpected requests")
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "{\"order_id\":\"ord-16\"}", "application/json"))
+		assert.Len(t, basket.FindRequests(NewTextQuery("ord", "body"), 100, 0).Requests, 1, "wrong number of found requests")
+	}
+}
+
+func TestPgSQLBasket_FindRequests_Options(t *testing.T) {
+	name := "test106g"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i, body := range []string{"Token expired", "token=abc", "TOKENS: 3", "no credentials"} {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), body, "text/plain"))
+		}
+
+		query := NewTextQuery("token", "body")
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 1, "wrong number of found requests")
+		query.SetOptions(true, false)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 3, "wrong number of found requests")
+		query.SetOptions(true, true)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 2, "wrong number of found requests")
+		query.SetOptions(false, true)
+		assert.Len(t, basket.FindRequests(query, 100, 0).Requests, 1, "wrong number of found requests")
+	}
+}
+
+func TestPgSQLBasket_UpdateRequest(t *testing.T) {
+	name := "test106h"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			data := basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+			assert.Equal(t, i, data.ID, "wrong request ID")
+		}
+
+		page := basket.GetRequests(1, 0)
+		if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+			assert.Equal(t, 12, page.Requests[0].ID, "wrong request ID")
+			basket.UpdateRequest(12, func(data *RequestData) {
+				assert.Equal(t, "req12", data.Body, "stored request is expected")
+				data.ForwardLatency = 120
+			})
+
+			found := basket.FindRequests(NewTextQuery("req12", "body"), 10, 0)
+			if assert.Len(t, found.Requests, 1, "wrong number of found requests") {
+				assert.Equal(t, int64(120), found.Requests[0].ForwardLatency, "forward latency is not updated")
+				assert.Equal(t, 12, found.Requests[0].ID, "wrong request ID")
+			}
+			assert.Equal(t, 10, basket.Size(), "wrong basket size")
+		}
+
+		// evicted requests are not updated
+		basket.UpdateRequest(1, func(data *RequestData) { t.Error("evicted request is not expected to be updated") })
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+		assert.Len(t, basket.FindRequests(NewTextQuery("req1", "body"), 10, 0).Requests, 3, "wrong number of found requests")
+
+		// concurrent updates do not overwrite each other
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				basket.UpdateRequest(11, func(data *RequestData) { data.ScriptLog += "x" })
+			}()
+		}
+		wg.Wait()
+		if request := basket.GetRequest(11); assert.NotNil(t, request, "request is expected") {
+			assert.Len(t, request.ScriptLog, 20, "concurrent updates are expected to be preserved")
+		}
+
+		// IDs are not reused after clearing basket
+		basket.Clear()
+		data := basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "req13", "text/plain"))
+		assert.Equal(t, 13, data.ID, "wrong request ID")
+	}
+}
+
+func TestPgSQLBasket_DeleteRequests(t *testing.T) {
+	name := "test106j"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		// evicted and unknown requests are ignored
+		assert.Equal(t, 2, basket.DeleteRequests([]int{1, 5, 12, 100}), "wrong number of deleted requests")
+		assert.Equal(t, 8, basket.Size(), "wrong basket size")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("req12", "body"), 10, 0).Requests, "deleted request is found")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 8, page.Count, "wrong number of requests")
+		assert.Equal(t, 12, page.TotalCount, "total count is not expected to change")
+		if assert.Len(t, page.Requests, 8, "wrong number of requests") {
+			assert.Equal(t, 11, page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, 3, page.Requests[7].ID, "wrong request ID")
+		}
+
+		assert.Equal(t, 0, basket.DeleteRequests([]int{}), "no requests are expected to be deleted")
+		assert.Equal(t, 0, basket.DeleteRequests([]int{5}), "request is already deleted")
+	}
+}
+
+func TestPgSQLBasket_DeleteRequestsBefore(t *testing.T) {
+	name := "test106m"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		now := time.Now().UnixNano() / toMs
+		basket.AddRequest(&RequestData{Date: now - 7200000, Method: "POST", Path: "/", Body: "old"})
+		basket.AddRequest(&RequestData{Date: now - 600000, Method: "POST", Path: "/", Body: "recent"})
+		// request copied from another basket with older date
+		basket.AddRequest(&RequestData{Date: now - 9000000, Method: "POST", Path: "/", Body: "copied"})
+		basket.AddRequest(&RequestData{Date: now, Method: "POST", Path: "/", Body: "latest"})
+
+		assert.Equal(t, 2, basket.DeleteRequestsBefore(now-3600000), "wrong number of deleted requests")
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+		assert.Empty(t, basket.FindRequests(NewTextQuery("old", "body"), 10, 0).Requests, "deleted request is found")
+		if page := basket.GetRequests(10, 0); assert.Len(t, page.Requests, 2, "wrong number of requests") {
+			assert.Equal(t, 4, page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, 2, page.Requests[1].ID, "wrong request ID")
+		}
+
+		assert.Equal(t, 0, basket.DeleteRequestsBefore(now-3600000), "no requests are expected to be deleted")
+	}
+}
+
+func TestPgSQLBasket_GetRequest(t *testing.T) {
+	name := "test106k"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 12; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		request := basket.GetRequest(7)
+		if assert.NotNil(t, request, "request is expected") {
+			assert.Equal(t, 7, request.ID, "wrong request ID")
+			assert.Equal(t, "req7", request.Body, "wrong request body")
+			assert.Equal(t, "id=7", request.Query, "wrong request query")
+		}
+
+		// evicted and unknown requests are not found
+		assert.Nil(t, basket.GetRequest(2), "evicted request is not expected")
+		assert.Nil(t, basket.GetRequest(13), "unknown request is not expected")
+
+		// stored request reflects updates
+		basket.UpdateRequest(12, func(data *RequestData) { data.ScriptLog = "processed" })
+		basket.UpdateRequest(12, func(data *RequestData) { data.ResponseStatus = 202 })
+		request = basket.GetRequest(12)
+		if assert.NotNil(t, request, "request is expected") {
+			assert.Equal(t, "processed", request.ScriptLog, "wrong script log")
+			assert.Equal(t, 202, request.ResponseStatus, "wrong response status")
+		}
+	}
+}
+
+func TestPgSQLBasket_AddRequest(t *testing.T) {
+	name := "test106l"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), "req1", "text/plain"))
+
+		// copied request keeps its date, but gets a new ID
+		date := time.Now().Add(-time.Hour).UnixNano() / toMs
+		data := basket.AddRequest(&RequestData{ID: 42, Date: date, Method: "PUT", Path: "/" + name + "/copy", Body: "copied",
+			Header: map[string][]string{"Content-Type": {"text/plain"}}})
+		assert.Equal(t, 2, data.ID, "wrong request ID")
+
+		page := basket.GetRequests(10, 0)
+		assert.Equal(t, 2, page.Count, "wrong number of requests")
+		assert.Equal(t, 2, page.TotalCount, "wrong total count of requests")
+
+		copied := basket.GetRequest(2)
+		if assert.NotNil(t, copied, "request is expected") {
+			assert.Equal(t, date, copied.Date, "wrong request date")
+			assert.Equal(t, "copied", copied.Body, "wrong request body")
+			assert.Equal(t, "PUT", copied.Method, "wrong request method")
+		}
+
+		// date range queries respect date of copied request
+		query := NewTextQuery("", "any")
+		query.To = date + 1000
+		found := basket.FindRequests(query, 10, 0)
+		if assert.Len(t, found.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, "copied", found.Requests[0].Body, "wrong request body")
+		}
+	}
+}
+
+func TestPgSQLBasket_GetRequestsBefore(t *testing.T) {
+	name := "test106i"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		for i := 1; i <= 8; i++ {
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		page := basket.GetRequests(3, 0)
+		if assert.True(t, page.HasMore, "more requests are expected") && assert.NotEmpty(t, page.NextCursor, "cursor is expected") {
+			assert.Equal(t, "req6", page.Requests[2].Body, "wrong last request on a page")
+
+			// new requests do not shift the next page
+			basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=9", name), "req9", "text/plain"))
+
+			before, err := DecodeRequestsCursor(page.NextCursor)
+			if assert.NoError(t, err) {
+				page = basket.GetRequestsBefore(before, 3)
+				if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+					assert.Equal(t, "req5", page.Requests[0].Body, "wrong first request on a page")
+					assert.Equal(t, 9, page.Count, "wrong number of requests in basket")
+				}
+				assert.True(t, page.HasMore, "more requests are expected")
+
+				before, err = DecodeRequestsCursor(page.NextCursor)
+				if assert.NoError(t, err) {
+					page = basket.GetRequestsBefore(before, 3)
+					assert.Len(t, page.Requests, 2, "wrong number of requests")
+					assert.False(t, page.HasMore, "no more requests are expected")
+					assert.Empty(t, page.NextCursor, "no cursor is expected on the last page")
+				}
+			}
+		}
+
+		// cursor with search query
+		query := NewTextQuery("req", "body")
+		found := basket.FindRequests(query, 4, 0)
+		if assert.True(t, found.HasMore, "more requests are expected") && assert.NotEmpty(t, found.NextCursor, "cursor is expected") {
+			before, err := DecodeRequestsCursor(found.NextCursor)
+			if assert.NoError(t, err) {
+				query.Before = before
+				found = basket.FindRequests(query, 10, 0)
+				if assert.Len(t, found.Requests, 5, "wrong number of found requests") {
+					assert.Equal(t, "req5", found.Requests[0].Body, "wrong first request on a page")
+				}
+				assert.False(t, found.HasMore, "no more requests are expected")
+			}
+		}
 	}
 }
 
@@ -425,6 +917,296 @@ func TestPgSQLBasket_SetResponse_Update(t *testing.T) {
 	}
 }
 
+func TestPgSQLBasket_SetTrigger(t *testing.T) {
+	name := "test109"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no trigger
+		assert.Nil(t, basket.GetTrigger())
+
+		// Set trigger
+		basket.SetTrigger(TriggerConfig{Script: "print(request['Path'])"})
+		// Get and validate
+		trigger := basket.GetTrigger()
+		if assert.NotNil(t, trigger, "trigger is expected") {
+			assert.Equal(t, "print(request['Path'])", trigger.Script, "wrong trigger script")
+		}
+
+		// Update trigger
+		basket.SetTrigger(TriggerConfig{Script: "print(request['Body'])"})
+		trigger = basket.GetTrigger()
+		if assert.NotNil(t, trigger, "trigger is expected") {
+			assert.Equal(t, "print(request['Body'])", trigger.Script, "wrong trigger script")
+		}
+	}
+}
+
+func TestPgSQLBasket_SetSchedules(t *testing.T) {
+	name := "test110"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no schedules
+		assert.Empty(t, basket.GetSchedules())
+
+		// Set schedules
+		basket.SetSchedules([]ScheduleConfig{
+			{Name: "summary", Cron: "@hourly", Script: "print(basket.size())"},
+			{Name: "cleanup", Cron: "0 3 * * *", Script: "basket.clear()"}})
+		// Get and validate
+		schedules := basket.GetSchedules()
+		if assert.Len(t, schedules, 2, "wrong number of schedules") {
+			assert.Equal(t, "summary", schedules[0].Name, "wrong schedule name")
+			assert.Equal(t, "@hourly", schedules[0].Cron, "wrong schedule cron")
+			assert.Equal(t, "basket.clear()", schedules[1].Script, "wrong schedule script")
+		}
+
+		// Reset schedules
+		basket.SetSchedules([]ScheduleConfig{})
+		assert.Empty(t, basket.GetSchedules())
+	}
+}
+
+func TestPgSQLBasket_SetSecrets(t *testing.T) {
+	name := "test111"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no secrets
+		assert.Empty(t, basket.GetSecrets())
+
+		// Set secrets
+		basket.SetSecret("API_KEY", "s3cr3t")
+		basket.SetSecret("TOKEN", "abc")
+		basket.SetSecret("TOKEN", "xyz")
+		// Get and validate
+		secrets := basket.GetSecrets()
+		assert.Len(t, secrets, 2, "wrong number of secrets")
+		assert.Equal(t, "s3cr3t", secrets["API_KEY"], "wrong secret value")
+		assert.Equal(t, "xyz", secrets["TOKEN"], "secret value is expected to be updated")
+
+		// Delete secrets
+		basket.DeleteSecret("TOKEN")
+		basket.DeleteSecret("UNKNOWN")
+		assert.Equal(t, map[string]string{"API_KEY": "s3cr3t"}, basket.GetSecrets())
+	}
+}
+
+func TestPgSQLBasket_SetWebhooks(t *testing.T) {
+	name := "test111w"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no webhooks
+		assert.Empty(t, basket.GetWebhooks())
+
+		// Set webhooks
+		basket.SetWebhooks([]WebhookConfig{
+			{URL: "http://localhost:8080/events", Events: []string{EventRequestReceived}, Secret: "s3cr3t"},
+			{URL: "http://localhost:8080/all"}})
+		// Get and validate
+		subscriptions := basket.GetWebhooks()
+		if assert.Len(t, subscriptions, 2, "wrong number of webhooks") {
+			assert.Equal(t, "http://localhost:8080/events", subscriptions[0].URL, "wrong webhook URL")
+			assert.Equal(t, []string{EventRequestReceived}, subscriptions[0].Events, "wrong webhook events")
+			assert.Equal(t, "s3cr3t", subscriptions[0].Secret, "wrong webhook secret")
+			assert.Empty(t, subscriptions[1].Events, "no webhook events are expected")
+		}
+
+		// Reset webhooks
+		basket.SetWebhooks([]WebhookConfig{})
+		assert.Empty(t, basket.GetWebhooks())
+	}
+}
+
+func TestPgSQLBasket_SetWebhookDeliveries(t *testing.T) {
+	name := "test111d"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no deliveries
+		assert.Empty(t, basket.GetWebhookDeliveries())
+
+		// Set deliveries
+		basket.SetWebhookDeliveries([]WebhookDelivery{
+			{ID: "1", EventID: "e1", Event: EventRequestReceived, URL: "http://localhost:8080/events",
+				Status: DeliveryFailed, Attempts: 5, LastStatus: 503, Payload: json.RawMessage(`{"id":"e1"}`)},
+			{ID: "2", EventID: "e2", Event: EventForwardFailed, URL: "http://localhost:8080/events", Status: DeliveryPending}})
+		// Get and validate
+		deliveries := basket.GetWebhookDeliveries()
+		if assert.Len(t, deliveries, 2, "wrong number of deliveries") {
+			assert.Equal(t, DeliveryFailed, deliveries[0].Status, "wrong delivery status")
+			assert.Equal(t, 5, deliveries[0].Attempts, "wrong number of attempts")
+			assert.Equal(t, 503, deliveries[0].LastStatus, "wrong status of the last attempt")
+			assert.Equal(t, `{"id":"e1"}`, string(deliveries[0].Payload), "wrong payload")
+			assert.Equal(t, "e2", deliveries[1].EventID, "wrong event ID")
+		}
+
+		// Reset deliveries
+		basket.SetWebhookDeliveries([]WebhookDelivery{})
+		assert.Empty(t, basket.GetWebhookDeliveries())
+	}
+}
+
+func TestPgSQLBasket_ShareToken(t *testing.T) {
+	name := "test111s"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure basket is not shared
+		assert.Empty(t, basket.GetShareToken(), "basket is not expected to be shared")
+
+		// Share basket
+		basket.SetShareToken("share_token_111")
+		assert.Equal(t, "share_token_111", basket.GetShareToken(), "wrong share token")
+		assert.False(t, basket.Authorize("share_token_111"), "share token is not expected to authorize basket management")
+
+		// Revoke share token
+		basket.SetShareToken("")
+		assert.Empty(t, basket.GetShareToken(), "share token is expected to be revoked")
+	}
+}
+
+func TestPgSQLBasket_ViewPassword(t *testing.T) {
+	name := "test111p"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure view is not protected
+		assert.Empty(t, basket.GetViewPassword(), "view is not expected to be protected")
+
+		// Protect view
+		basket.SetViewPassword("password_hash_111")
+		assert.Equal(t, "password_hash_111", basket.GetViewPassword(), "wrong view password")
+
+		// Remove password
+		basket.SetViewPassword("")
+		assert.Empty(t, basket.GetViewPassword(), "view password is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_SetToken(t *testing.T) {
+	name := "test111t"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+	assert.NoError(t, err)
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.True(t, basket.Authorize(auth.Token), "basket token is expected to be accepted")
+
+		// Rotate token
+		basket.SetToken("new_token_111")
+		assert.False(t, basket.Authorize(auth.Token), "previous token is not expected to be accepted")
+		assert.True(t, basket.Authorize("new_token_111"), "new token is expected to be accepted")
+
+		// Revoke token
+		basket.SetToken("")
+		assert.False(t, basket.Authorize("new_token_111"), "revoked token is not expected to be accepted")
+		assert.False(t, basket.Authorize(""), "empty token is not expected to be accepted")
+	}
+}
+
+func TestPgSQLBasket_SetAccessTokens(t *testing.T) {
+	name := "test111a"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no access tokens
+		assert.Empty(t, basket.GetAccessTokens())
+
+		// Set access tokens
+		basket.SetAccessTokens([]AccessToken{
+			{Name: "ci", Scopes: []string{ScopeRead, ScopeClear}, Token: "ci_token_111", Created: 1000},
+			{Name: "deploy", Scopes: []string{ScopeWriteConfig}, Token: "deploy_token_111", Created: 2000}})
+		// Get and validate
+		tokens := basket.GetAccessTokens()
+		if assert.Len(t, tokens, 2, "wrong number of access tokens") {
+			assert.Equal(t, "ci", tokens[0].Name, "wrong token name")
+			assert.Equal(t, []string{ScopeRead, ScopeClear}, tokens[0].Scopes, "wrong token scopes")
+			assert.Equal(t, "ci_token_111", tokens[0].Token, "wrong token")
+			assert.Equal(t, int64(2000), tokens[1].Created, "wrong creation time")
+		}
+		assert.False(t, basket.Authorize("ci_token_111"), "access token is not expected to authorize basket management")
+
+		// Reset access tokens
+		basket.SetAccessTokens([]AccessToken{})
+		assert.Empty(t, basket.GetAccessTokens())
+	}
+}
+
+func TestPgSQLBasket_SetACL(t *testing.T) {
+	name := "test111b"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no ACL
+		assert.Empty(t, basket.GetACL())
+
+		// Set ACL
+		basket.SetACL([]ACLEntry{{User: "alice", Access: AccessManage}, {Group: "qa", Access: AccessView}})
+		// Get and validate
+		entries := basket.GetACL()
+		if assert.Len(t, entries, 2, "wrong number of ACL entries") {
+			assert.Equal(t, ACLEntry{User: "alice", Access: AccessManage}, entries[0], "wrong ACL entry")
+			assert.Equal(t, ACLEntry{Group: "qa", Access: AccessView}, entries[1], "wrong ACL entry")
+		}
+
+		// Reset ACL
+		basket.SetACL([]ACLEntry{})
+		assert.Empty(t, basket.GetACL())
+	}
+}
+
 func TestPgSQLBasket_Config_Error(t *testing.T) {
 	name := "test120"
 	db := NewSQLDatabase(pgTestConnection)
@@ -513,3 +1295,292 @@ func TestPgSQLDatabase_GetStats(t *testing.T) {
 		}
 	}
 }
+
+func TestPgSQLDatabase_Compact(t *testing.T) {
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	assert.NoError(t, db.Compact(), "SQL database is expected to be compacted")
+}
+
+func TestPgSQLDatabase_Ping(t *testing.T) {
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	assert.NoError(t, db.Ping(), "SQL database is expected to be available")
+}
+
+func TestPgSQLBasket_RateLimit(t *testing.T) {
+	name := "test111r"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, RateLimit: &CaptureLimit{Rate: 5, Burst: 10, Action: CaptureDrop}})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure rate limit is stored
+		config := basket.Config()
+		if assert.NotNil(t, config.RateLimit, "rate limit is expected") {
+			assert.Equal(t, CaptureLimit{Rate: 5, Burst: 10, Action: CaptureDrop}, *config.RateLimit, "wrong rate limit")
+		}
+
+		// Remove rate limit
+		config.RateLimit = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().RateLimit, "rate limit is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_BodyLimit(t *testing.T) {
+	name := "test111b"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, BodyLimit: &BodyLimit{MaxSize: 1024, Policy: BodyTruncate}})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure body size limit is stored
+		config := basket.Config()
+		if assert.NotNil(t, config.BodyLimit, "body size limit is expected") {
+			assert.Equal(t, BodyLimit{MaxSize: 1024, Policy: BodyTruncate}, *config.BodyLimit, "wrong body size limit")
+		}
+
+		// Remove body size limit
+		config.BodyLimit = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().BodyLimit, "body size limit is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Redaction(t *testing.T) {
+	name := "test111c"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	rules := []RedactionRule{{Header: "X-Api-Key"}, {Preset: RedactCardNumber}}
+	db.Create(name, BasketConfig{Capacity: 20, Redaction: rules})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure redaction rules are stored
+		config := basket.Config()
+		assert.Equal(t, rules, config.Redaction, "wrong redaction rules")
+
+		// Remove redaction rules
+		config.Redaction = nil
+		basket.Update(config)
+		assert.Empty(t, basket.Config().Redaction, "redaction rules are expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Signature(t *testing.T) {
+	name := "test111d"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	check := &SignatureCheck{Provider: SignatureGitHub, Secret: "HOOK_SECRET", Reject: true}
+	db.Create(name, BasketConfig{Capacity: 20, Signature: check})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure signature verification is stored
+		config := basket.Config()
+		assert.Equal(t, check, config.Signature, "wrong signature verification")
+
+		// Remove signature verification
+		config.Signature = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().Signature, "signature verification is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_ForwardHeaders(t *testing.T) {
+	name := "test111e"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	headers := &ForwardHeaders{Strip: []string{"X-Internal-Trace"}, Keep: []string{"Proxy-Authorization"}}
+	db.Create(name, BasketConfig{Capacity: 20, ForwardHeaders: headers})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure handling of forwarded headers is stored
+		config := basket.Config()
+		assert.Equal(t, headers, config.ForwardHeaders, "wrong forward headers")
+
+		// Remove handling of forwarded headers
+		config.ForwardHeaders = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().ForwardHeaders, "forward headers are expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Encryption(t *testing.T) {
+	name := "test111f"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	encryption := &EncryptionKey{PublicKey: "-----BEGIN PUBLIC KEY-----\nMIIB\n-----END PUBLIC KEY-----\n", KeyID: "abc"}
+	db.Create(name, BasketConfig{Capacity: 20, Encryption: encryption})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure public key is stored
+		config := basket.Config()
+		assert.Equal(t, encryption, config.Encryption, "wrong encryption key")
+
+		// Remove public key
+		config.Encryption = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().Encryption, "encryption key is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Ignore(t *testing.T) {
+	name := "test111g"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	filters := []string{"header:User-Agent:kube-probe", "path:/health"}
+	db.Create(name, BasketConfig{Capacity: 20, Ignore: filters})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure ignore filters are stored
+		config := basket.Config()
+		assert.Equal(t, filters, config.Ignore, "wrong ignore filters")
+
+		// Remove ignore filters
+		config.Ignore = nil
+		basket.Update(config)
+		assert.Empty(t, basket.Config().Ignore, "ignore filters are expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Retention(t *testing.T) {
+	name := "test111h"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, Retention: 3600})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure retention period is stored
+		config := basket.Config()
+		assert.Equal(t, 3600, config.Retention, "wrong retention period")
+
+		// Remove retention period
+		config.Retention = 0
+		basket.Update(config)
+		assert.Equal(t, 0, basket.Config().Retention, "retention period is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_HashChain(t *testing.T) {
+	name := "test111i"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, HashChain: true})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure hash chain option is stored
+		config := basket.Config()
+		assert.True(t, config.HashChain, "hash chain is expected to be enabled")
+
+		// Ensure chained requests keep their hashes
+		added := addChainedRequest(name, basket, &RequestData{Date: 1000, Method: "POST", Path: "/", Body: "data"})
+		assert.Equal(t, added.Hash, basket.GetRequest(added.ID).Hash, "wrong hash of stored request")
+		assert.True(t, verifyChain(basket).Verified, "chain is expected to be verified")
+
+		// Disable hash chain
+		config.HashChain = false
+		basket.Update(config)
+		assert.False(t, basket.Config().HashChain, "hash chain is expected to be disabled")
+	}
+}
+
+func TestPgSQLBasket_ExpiresAfter(t *testing.T) {
+	name := "test111j"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, ExpiresAfter: 86400})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure expiration period is stored
+		config := basket.Config()
+		assert.Equal(t, 86400, config.ExpiresAfter, "wrong expiration period")
+
+		// Remove expiration period
+		config.ExpiresAfter = 0
+		basket.Update(config)
+		assert.Equal(t, 0, basket.Config().ExpiresAfter, "expiration period is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_Pause(t *testing.T) {
+	name := "test111k"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, Pause: &BasketPause{Status: 410, Until: 1700000000000}})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure pause is stored
+		config := basket.Config()
+		if assert.NotNil(t, config.Pause, "pause is expected") {
+			assert.Equal(t, 410, config.Pause.Status, "wrong status of paused basket")
+			assert.Equal(t, int64(1700000000000), config.Pause.Until, "wrong end of pause")
+		}
+
+		// Resume basket
+		config.Pause = nil
+		basket.Update(config)
+		assert.Nil(t, basket.Config().Pause, "pause is expected to be removed")
+	}
+}
+
+func TestPgSQLBasket_CompressedBody(t *testing.T) {
+	name := "test111l"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+	defer func(current string) { bodyCompression = current }(bodyCompression)
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		body := strings.Repeat(`{"event": "Order.Created", "status": "pending"}`, 100)
+		basket.AddRequest(&RequestData{Date: 1000, Method: "POST", Body: body})
+		bodyCompression = BodyCompressionGzip
+		basket.AddRequest(&RequestData{Date: 2000, Method: "POST", Body: body})
+		basket.AddRequest(&RequestData{Date: 3000, Method: "POST", Body: strings.Repeat("no events", 100)})
+
+		// text search finds compressed and uncompressed bodies
+		query := NewTextQuery("Order.Created", "body")
+		assert.Len(t, basket.FindRequests(query, 10, 0).Requests, 2, "compressed body is expected to be searchable")
+		query = NewTextQuery("order.created", "body")
+		assert.Empty(t, basket.FindRequests(query, 10, 0).Requests, "case sensitive search is expected")
+		query.SetOptions(true, false)
+		assert.Len(t, basket.FindRequests(query, 10, 0).Requests, 2, "compressed body is expected to be searchable")
+	}
+}
@@ -90,7 +90,7 @@ func TestSQLBasket_InvalidBasket(t *testing.T) {
 	assert.Equal(t, 1, page.Count, "wrong Count of requests in page")
 	assert.Equal(t, 0, len(page.Requests), "wrong number of Requests in page")
 
-	findPage := basket.FindRequests("", "any", 10, 0)
+	findPage := basket.FindRequests(NewTextQuery("", "any"), 10, 0)
 	assert.NotNil(t, findPage, "requests page is expected")
 	assert.Equal(t, 0, len(findPage.Requests), "wrong number of Requests in page")
 }
@@ -157,7 +157,7 @@ func TestSQLBasket_FindRequests_SQLError(t *testing.T) {
 	sqldb.Close()
 
 	basket := sqlBasket{db: sqldb, dbType: "postgres", name: "anybasket"}
-	page := basket.FindRequests("q", "any", 10, 0)
+	page := basket.FindRequests(NewTextQuery("q", "any"), 10, 0)
 	if assert.NotNil(t, page, "page object with requests is expected") {
 		assert.False(t, page.HasMore)
 		assert.Empty(t, page.Requests)
@@ -198,3 +198,10 @@ func TestSQLBasket_applyLimit_SQLError(t *testing.T) {
 	basket.applyLimit(-1)
 	// TODO: find out how to capture the log output for validation
 }
+
+func TestSQLLikePattern(t *testing.T) {
+	pattern, err := sqlLikePattern("50% of user_id! <\"x\">")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "%50!% of user!_id!! \\u003c\\\"x\\\"\\u003e%", pattern)
+	}
+}
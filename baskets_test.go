@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -141,6 +142,198 @@ func TestExpandURL(t *testing.T) {
 	assert.Equal(t, "/receive/notification/test/", expandURL("/receive/notification/", "/basket/test/", "basket"))
 }
 
+func TestRequestData_Matches(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Header.Add("X-Request-Id", "6f1c2b9e-8d4a-4f7e-9b1a-3c5d7e9f0a12")
+	data.Body = "{ \"order_id\" : 1542, \"status\" : \"paid\" }"
+	data.Query = "id=15"
+
+	assert.True(t, data.Matches(NewTextQuery("paid", "any")))
+	assert.True(t, data.Matches(NewTextQuery("paid", "body")))
+	assert.False(t, data.Matches(NewTextQuery("paid", "headers")))
+	assert.False(t, data.Matches(NewTextQuery("[0-9]+", "any")))
+
+	uuid, err := NewRequestsQuery("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$", "headers", QueryTypeRegex)
+	if assert.NoError(t, err) {
+		assert.True(t, data.Matches(uuid))
+	}
+
+	orders, err := NewRequestsQuery(`"order_id" : 15[0-4][0-9]\b`, "body", QueryTypeRegex)
+	if assert.NoError(t, err) {
+		assert.True(t, data.Matches(orders))
+	}
+
+	ids, err := NewRequestsQuery(`^id=1[6-9]$`, "query", QueryTypeRegex)
+	if assert.NoError(t, err) {
+		assert.False(t, data.Matches(ids))
+	}
+}
+
+func TestRequestData_Matches_MethodAndPath(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Method = "POST"
+	data.Path = "/demo/callback/orders/15"
+
+	query := NewTextQuery("", "any")
+	query.Method = "POST"
+	assert.True(t, data.Matches(query))
+	query.Method = "get"
+	assert.False(t, data.Matches(query))
+
+	query = NewTextQuery("", "any")
+	for pattern, expected := range map[string]bool{
+		"/callback/*":               true,
+		"/demo/callback/*":          true,
+		"/callback/orders/1?":       true,
+		"/callback":                 false,
+		"/callback/orders/1":        false,
+		"/*/orders/*":               true,
+		"/hooks/*":                  false,
+		"/demo/callback/(orders)/*": false,
+	} {
+		query.SetPath(pattern)
+		assert.Equal(t, expected, data.Matches(query), "wrong match of path pattern: %s", pattern)
+	}
+
+	query.SetPath("")
+	assert.True(t, data.Matches(query))
+}
+
+func TestRequestData_Matches_Header(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Header.Add("Content-Type", "application/json; charset=UTF-8")
+	data.Header.Add("Accept", "text/plain")
+	data.Header.Add("Accept", "application/xml")
+
+	for filter, expected := range map[string]bool{
+		"Content-Type:application/json":  true,
+		"content-type: application/json": true,
+		"Content-Type":                   true,
+		"Content-Type:text/plain":        false,
+		"Accept:application/xml":         true,
+		"Authorization":                  false,
+	} {
+		query := NewTextQuery("", "any")
+		if assert.NoError(t, query.AddHeader(filter)) {
+			assert.Equal(t, expected, data.Matches(query), "wrong match of header filter: %s", filter)
+		}
+	}
+
+	// all header filters must match
+	query := NewTextQuery("", "any")
+	query.AddHeader("Accept:text/plain")
+	query.AddHeader("Accept:application/xml")
+	assert.True(t, data.Matches(query))
+	query.AddHeader("Content-Type:text/xml")
+	assert.False(t, data.Matches(query))
+
+	assert.Error(t, NewTextQuery("", "any").AddHeader(":application/json"))
+}
+
+func TestRequestData_Matches_Options(t *testing.T) {
+	data := new(RequestData)
+	data.Body = "Access Token expired; tokens=3"
+
+	for _, tc := range []struct {
+		text       string
+		queryType  string
+		ignoreCase bool
+		wholeWord  bool
+		expected   bool
+	}{
+		{"token", QueryTypeSubstring, false, false, true},
+		{"TOKEN", QueryTypeSubstring, false, false, false},
+		{"TOKEN", QueryTypeSubstring, true, false, true},
+		{"token", QueryTypeSubstring, false, true, false},
+		{"Token", QueryTypeSubstring, false, true, true},
+		{"access token", QueryTypeSubstring, true, true, true},
+		{"expire", QueryTypeSubstring, true, true, false},
+		{"tok.n", QueryTypeSubstring, true, false, false},
+		{"TOK.N", QueryTypeRegex, true, false, true},
+		{"tok.n", QueryTypeRegex, false, true, false},
+		{"tok.ns", QueryTypeRegex, false, true, true},
+	} {
+		query, err := NewRequestsQuery(tc.text, "body", tc.queryType)
+		if assert.NoError(t, err) {
+			query.SetOptions(tc.ignoreCase, tc.wholeWord)
+			assert.Equal(t, tc.expected, data.Matches(query), "wrong match of %s query: %s, ignore case: %v, whole word: %v",
+				tc.queryType, tc.text, tc.ignoreCase, tc.wholeWord)
+		}
+	}
+
+	// options can be reset
+	query := NewTextQuery("TOKEN", "body")
+	query.SetOptions(true, false)
+	assert.True(t, data.Matches(query))
+	query.SetOptions(false, false)
+	assert.False(t, data.Matches(query))
+}
+
+func TestRelativePath(t *testing.T) {
+	assert.Equal(t, "/callback/1", relativePath("/demo/callback/1"))
+	assert.Equal(t, "/", relativePath("/demo"))
+	assert.Equal(t, "/", relativePath("/demo/"))
+}
+
+func TestNewRequestsQuery_Invalid(t *testing.T) {
+	_, err := NewRequestsQuery("[0-9", "any", QueryTypeRegex)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid regular expression")
+	}
+
+	_, err = NewRequestsQuery("test", "any", "glob")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown query type: glob")
+	}
+}
+
+func TestFindSortedRequests(t *testing.T) {
+	name := "sorted01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i, body := range []string{"aaa", "a", "aaaaa", "aa", "aaaa"} {
+		data := basket.Add(createTestPOSTRequest("http://localhost/"+name, body, "text/plain"))
+		basket.UpdateRequest(data.ID, func(data *RequestData) { data.ForwardLatency = int64(10 * ((i + 2) % 5)) })
+	}
+
+	bodies := func(page RequestsQueryPage) []string {
+		result := make([]string, 0, len(page.Requests))
+		for _, req := range page.Requests {
+			result = append(result, req.Body)
+		}
+		return result
+	}
+
+	query := NewTextQuery("", "any")
+	assert.Equal(t, []string{"aaaa", "aa", "aaaaa", "a", "aaa"}, bodies(FindSortedRequests(basket, query, 10, 0)))
+
+	assert.NoError(t, query.SetSort(SortOldest))
+	assert.Equal(t, []string{"aaa", "a", "aaaaa", "aa", "aaaa"}, bodies(FindSortedRequests(basket, query, 10, 0)))
+
+	assert.NoError(t, query.SetSort(SortContentLength))
+	page := FindSortedRequests(basket, query, 2, 1)
+	assert.Equal(t, []string{"aaaa", "aaa"}, bodies(page))
+	assert.True(t, page.HasMore)
+
+	assert.NoError(t, query.SetSort(SortForwardLatency))
+	page = FindSortedRequests(basket, query, 3, 2)
+	assert.Equal(t, []string{"aaa", "aaaa", "aa"}, bodies(page))
+	assert.False(t, page.HasMore)
+
+	// sorting is applied to found requests only
+	query = NewTextQuery("aaa", "body")
+	assert.NoError(t, query.SetSort(SortOldest))
+	assert.Equal(t, []string{"aaa", "aaaaa", "aaaa"}, bodies(FindSortedRequests(basket, query, 10, 0)))
+
+	assert.Error(t, query.SetSort("size"))
+}
+
 func TestDatabaseStats_Collect(t *testing.T) {
 	stats := new(DatabaseStats)
 	stats.Collect(&BasketInfo{"a", 5, 10, 100}, 3)
@@ -194,3 +387,74 @@ func test_validateBasketStats(t *testing.T, info *BasketInfo, name string, count
 	assert.Equal(t, totalCount, info.RequestsTotalCount, "unexpected requests total count for basket: "+name)
 	assert.NotEqual(t, int64(0), info.LastRequestDate, "last request date is expected for basket: "+name)
 }
+
+func TestDeleteSelectedRequests(t *testing.T) {
+	name := "delete01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i := 1; i <= 6; i++ {
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i%2), "text/plain"))
+	}
+
+	assert.Equal(t, 2, DeleteSelectedRequests(basket, []int{1, 2}, nil), "wrong number of deleted requests")
+	// only found requests with given IDs are deleted
+	assert.Equal(t, 1, DeleteSelectedRequests(basket, []int{3, 4}, NewTextQuery("req1", "body")), "wrong number of deleted requests")
+	// all found requests are deleted
+	assert.Equal(t, 2, DeleteSelectedRequests(basket, nil, NewTextQuery("req0", "body")), "wrong number of deleted requests")
+
+	page := basket.GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+		assert.Equal(t, 5, page.Requests[0].ID, "wrong request ID")
+	}
+}
+
+func TestCopyBasket(t *testing.T) {
+	name := "copy01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, ForwardURL: "http://localhost:12345"})
+	source := db.Get(name)
+	source.SetResponse("GET", ResponseConfig{Status: 202, Body: "ok"})
+	source.SetTrigger(TriggerConfig{Script: "print('hi')"})
+	source.SetSchedules([]ScheduleConfig{{Name: "hourly", Cron: "0 * * * *", Script: "print('tick')"}})
+	source.SetSecret("API_KEY", "s3cr3t")
+	for i := 1; i <= 3; i++ {
+		source.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v", name), fmt.Sprintf("req%v", i), "text/plain"))
+	}
+
+	// settings only
+	auth, err := CopyBasket(db, source, "copy01a", false)
+	if assert.NoError(t, err) && assert.NotEmpty(t, auth.Token, "token is expected") {
+		basket := db.Get("copy01a")
+		assert.False(t, basket.Authorize(""), "new token is expected")
+		assert.True(t, basket.Authorize(auth.Token), "new token is expected")
+		assert.Equal(t, source.Config(), basket.Config(), "wrong basket config")
+		if response := basket.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+			assert.Equal(t, "ok", response.Body, "wrong response body")
+		}
+		assert.Nil(t, basket.GetResponse("POST"), "response is not expected")
+		assert.Equal(t, "print('hi')", basket.GetTrigger().Script, "wrong trigger script")
+		assert.Len(t, basket.GetSchedules(), 1, "wrong number of schedules")
+		assert.Equal(t, "s3cr3t", basket.GetSecrets()["API_KEY"], "wrong secret value")
+		assert.Equal(t, 0, basket.Size(), "requests are not expected")
+	}
+
+	// with requests
+	_, err = CopyBasket(db, source, "copy01b", true)
+	if assert.NoError(t, err) {
+		page := db.Get("copy01b").GetRequests(10, 0)
+		if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+			assert.Equal(t, "req3", page.Requests[0].Body, "wrong order of requests")
+			assert.Equal(t, 3, page.Requests[0].ID, "wrong request ID")
+			assert.Equal(t, "req1", page.Requests[2].Body, "wrong order of requests")
+		}
+	}
+
+	// name conflict
+	_, err = CopyBasket(db, source, "copy01a", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Operations supported by batch request
+const (
+	BatchCreateBasket   = "create_basket"
+	BatchSetResponse    = "set_response"
+	BatchDeleteRequests = "delete_requests"
+)
+
+const (
+	maxBatchOperations = 100
+	maxBatchSize       = 1024 * 1024
+)
+
+// BatchOperation describes single operation of batch request, operations on baskets created by the same batch
+// are authorized with the token of created basket.
+type BatchOperation struct {
+	Op       string          `json:"op"`
+	Basket   string          `json:"basket"`
+	Config   *BasketConfig   `json:"config,omitempty"`   // create_basket: basket settings, defaults are used if absent
+	Method   string          `json:"method,omitempty"`   // set_response: HTTP method of response
+	Response *ResponseConfig `json:"response,omitempty"` // set_response: response settings
+	IDs      []int           `json:"ids,omitempty"`      // delete_requests: IDs of requests to delete
+	Query    string          `json:"query,omitempty"`    // delete_requests: search criteria in URL query format, e.g. q=abc&in=body
+}
+
+// BatchRequest describes a list of operations that are executed in order.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperationResult describes result of single operation of batch request.
+type BatchOperationResult struct {
+	Status     int    `json:"status"`
+	Code       string `json:"code,omitempty"` // error code of failed operation
+	Error      string `json:"error,omitempty"`
+	Token      string `json:"token,omitempty"`       // token of created basket
+	Deleted    int    `json:"deleted,omitempty"`     // number of deleted requests if they are selected
+	RolledBack bool   `json:"rolled_back,omitempty"` // operation is reverted due to failure of later operation
+}
+
+// BatchResult describes results of executed operations of batch request, execution stops at the first failed operation.
+type BatchResult struct {
+	Results   []*BatchOperationResult `json:"results"`
+	Completed bool                    `json:"completed"`
+}
+
+// batchResponseWriter records response of API handler that executes batch operation
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// batchUndo reverts successful batch operation
+type batchUndo func()
+
+// validateBatchOperation checks operation before any operation of the batch is executed
+func validateBatchOperation(op *BatchOperation) error {
+	if !validBasketName.MatchString(op.Basket) {
+		return fmt.Errorf("invalid basket name; the name does not match pattern: %s", validBasketName.String())
+	}
+
+	switch op.Op {
+	case BatchCreateBasket:
+		_, err := validateNewBasketName(op.Basket)
+		return err
+	case BatchSetResponse:
+		if op.Response == nil {
+			return fmt.Errorf("response is expected")
+		}
+		_, err := getValidMethod(httprouter.Params{{Key: "method", Value: op.Method}})
+		return err
+	case BatchDeleteRequests:
+		values, err := url.ParseQuery(op.Query)
+		if err == nil {
+			_, err = getRequestsQuery(values)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown operation: %s", op.Op)
+	}
+}
+
+// ExecuteBatch handles HTTP request to execute several operations at once, operations are executed in order by
+// the same handlers as individual API operations; if an operation fails, previously created baskets are deleted
+// and changed responses are restored, but deleted requests cannot be recovered
+func ExecuteBatch(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBatchSize))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	batch := BatchRequest{}
+	if err = json.Unmarshal(body, &batch); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Operations) == 0 || len(batch.Operations) > maxBatchOperations {
+		httpError(w, fmt.Sprintf("batch should contain from 1 to %d operations", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+	for i := range batch.Operations {
+		if err = validateBatchOperation(&batch.Operations[i]); err != nil {
+			writeError(w, http.StatusBadRequest, ErrorBadRequest, fmt.Sprintf("invalid operation %d: %s", i, err),
+				map[string]int{"operation": i})
+			return
+		}
+	}
+
+	result := BatchResult{Results: make([]*BatchOperationResult, 0, len(batch.Operations))}
+	tokens := make(map[string]string)
+	undo := make([]batchUndo, 0, len(batch.Operations))
+	status := http.StatusOK
+
+	for i := range batch.Operations {
+		op := &batch.Operations[i]
+		opResult, opUndo := executeBatchOperation(r, op, tokens)
+		result.Results = append(result.Results, opResult)
+
+		if opResult.Status >= 300 {
+			log.Printf("[warn] batch operation %d (%s) failed for basket: %s - %d %s",
+				i, op.Op, op.Basket, opResult.Status, opResult.Error)
+			status = opResult.Status
+			break
+		}
+		undo = append(undo, opUndo)
+	}
+
+	if status == http.StatusOK {
+		result.Completed = true
+	} else {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undo[i] != nil {
+				undo[i]()
+				result.Results[i].RolledBack = true
+			}
+		}
+	}
+
+	json, err := json.Marshal(result)
+	writeJSON(w, status, json, err)
+}
+
+// executeBatchOperation executes operation with API handler and returns the way to revert it, nil if not possible
+func executeBatchOperation(r *http.Request, op *BatchOperation, tokens map[string]string) (*BatchOperationResult, batchUndo) {
+	token := r.Header.Get("Authorization")
+	if created, ok := tokens[op.Basket]; ok {
+		token = created
+	}
+
+	var handler httprouter.Handle
+	var payload interface{}
+	var undo batchUndo
+	method := http.MethodPut
+	query := ""
+	ps := httprouter.Params{{Key: "basket", Value: op.Basket}}
+
+	switch op.Op {
+	case BatchCreateBasket:
+		handler, method = CreateBasket, http.MethodPost
+		if op.Config != nil {
+			config := *op.Config
+			if config.Capacity == 0 {
+				config.Capacity = serverConfig.InitCapacity
+			}
+			payload = config
+		}
+		undo = func() {
+			log.Printf("[info] reverting creation of basket: %s", op.Basket)
+			deleteBasket(op.Basket)
+		}
+	case BatchSetResponse:
+		handler = UpdateBasketResponse
+		response := *op.Response
+		if response.Status == 0 {
+			response.Status = defaultResponse.Status
+		}
+		payload = response
+		ps = append(ps, httprouter.Param{Key: "method", Value: op.Method})
+
+		responseMethod := strings.ToUpper(op.Method)
+		if basket := basketsDb.Get(op.Basket); basket != nil {
+			previous := defaultResponse
+			if current := basket.GetResponse(responseMethod); current != nil {
+				previous = *current
+			}
+			undo = func() {
+				if _, created := tokens[op.Basket]; !created {
+					basket.SetResponse(responseMethod, previous)
+				}
+			}
+		}
+	case BatchDeleteRequests:
+		handler, method = ClearBasket, http.MethodDelete
+		values, _ := url.ParseQuery(op.Query)
+		for _, id := range op.IDs {
+			values.Add("id", strconv.Itoa(id))
+		}
+		query = values.Encode()
+	}
+
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	req, err := http.NewRequest(method, r.URL.Path+"?"+query, bytes.NewReader(body))
+	if err != nil {
+		return &BatchOperationResult{Status: http.StatusInternalServerError, Error: err.Error()}, nil
+	}
+	req.Header.Set("Authorization", token)
+	// anonymous operations are subject to anti-abuse controls of the batch client
+	req.RemoteAddr = r.RemoteAddr
+	req.Header.Set(ChallengeHeader, r.Header.Get(ChallengeHeader))
+	req.Header.Set(ChallengeNonceHeader, r.Header.Get(ChallengeNonceHeader))
+
+	w := &batchResponseWriter{header: make(http.Header)}
+	handler(w, req, ps)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	result := &BatchOperationResult{Status: w.status}
+	if result.Status >= 300 {
+		failure := ErrorResponse{}
+		if err = json.Unmarshal(w.body.Bytes(), &failure); err == nil {
+			result.Code, result.Error = failure.Code, failure.Message
+		} else {
+			result.Code, result.Error = errorCode(result.Status), strings.TrimSpace(w.body.String())
+		}
+		return result, nil
+	}
+
+	switch op.Op {
+	case BatchCreateBasket:
+		auth := BasketAuth{}
+		if err = json.Unmarshal(w.body.Bytes(), &auth); err == nil {
+			result.Token = auth.Token
+			tokens[op.Basket] = auth.Token
+		}
+	case BatchDeleteRequests:
+		deletion := RequestsDeletion{}
+		if err = json.Unmarshal(w.body.Bytes(), &deletion); err == nil {
+			result.Deleted = deletion.Deleted
+		}
+	}
+
+	return result, undo
+}
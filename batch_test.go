@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func executeTestBatch(t *testing.T, token string, body string) (*httptest.ResponseRecorder, *BatchResult) {
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/batch", strings.NewReader(body))
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	if len(token) > 0 {
+		r.Header.Add("Authorization", token)
+	}
+
+	w := httptest.NewRecorder()
+	ExecuteBatch(w, r, nil)
+
+	// invalid batch is rejected without executing any operation
+	if w.Code == http.StatusBadRequest {
+		return w, nil
+	}
+	result := new(BatchResult)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result), "failed to parse batch result")
+	return w, result
+}
+
+func TestExecuteBatch(t *testing.T) {
+	auth, err := basketsDb.Create("batch01", BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basket := basketsDb.Get("batch01")
+	for i := 0; i < 3; i++ {
+		basket.Add(createTestPOSTRequest("http://localhost:55555/batch01", "data", "text/plain"))
+	}
+
+	w, result := executeTestBatch(t, auth.Token, `{"operations": [
+		{"op": "create_basket", "basket": "batch02", "config": {"capacity": 50}},
+		{"op": "set_response", "basket": "batch02", "method": "get", "response": {"status": 202, "body": "ok"}},
+		{"op": "set_response", "basket": "batch01", "method": "POST", "response": {"body": "created"}},
+		{"op": "delete_requests", "basket": "batch01", "ids": [1, 2]}]}`)
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	if assert.NotNil(t, result) && assert.Len(t, result.Results, 4, "wrong number of results") {
+		assert.True(t, result.Completed, "batch is expected to be completed")
+		assert.Equal(t, 201, result.Results[0].Status, "wrong status of basket creation")
+		assert.NotEmpty(t, result.Results[0].Token, "token of created basket is expected")
+		assert.Equal(t, 204, result.Results[1].Status, "wrong status of response update")
+		assert.Equal(t, 204, result.Results[2].Status, "wrong status of response update")
+		assert.Equal(t, 200, result.Results[3].Status, "wrong status of requests deletion")
+		assert.Equal(t, 2, result.Results[3].Deleted, "wrong number of deleted requests")
+	}
+
+	created := basketsDb.Get("batch02")
+	if assert.NotNil(t, created, "basket is expected to be created") {
+		assert.Equal(t, 50, created.Config().Capacity, "wrong capacity")
+		if response := created.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+			assert.Equal(t, 202, response.Status, "wrong response status")
+		}
+	}
+	if response := basket.GetResponse("POST"); assert.NotNil(t, response, "response is expected") {
+		assert.Equal(t, 200, response.Status, "default response status is expected")
+		assert.Equal(t, "created", response.Body, "wrong response body")
+	}
+	assert.Equal(t, 1, basket.Size(), "wrong number of remaining requests")
+}
+
+func TestExecuteBatch_Rollback(t *testing.T) {
+	auth, err := basketsDb.Create("batch03", BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basket := basketsDb.Get("batch03")
+	basket.SetResponse("GET", ResponseConfig{Status: 200, Body: "original"})
+
+	// the last operation fails, because the basket does not exist
+	w, result := executeTestBatch(t, auth.Token, `{"operations": [
+		{"op": "create_basket", "basket": "batch04"},
+		{"op": "set_response", "basket": "batch03", "method": "GET", "response": {"status": 500}},
+		{"op": "set_response", "basket": "batch03", "method": "PUT", "response": {"status": 201}},
+		{"op": "set_response", "basket": "batch01x", "method": "GET", "response": {"status": 200}}]}`)
+	assert.Equal(t, 404, w.Code, "status of failed operation is expected")
+	if assert.NotNil(t, result) && assert.Len(t, result.Results, 4, "wrong number of results") {
+		assert.False(t, result.Completed, "batch is not expected to be completed")
+		assert.True(t, result.Results[0].RolledBack, "basket creation is expected to be reverted")
+		assert.True(t, result.Results[1].RolledBack, "response update is expected to be reverted")
+		assert.Equal(t, 404, result.Results[3].Status, "wrong status of failed operation")
+		assert.Equal(t, ErrorBasketNotFound, result.Results[3].Code, "wrong error code of failed operation")
+		assert.False(t, result.Results[3].RolledBack, "failed operation is not expected to be reverted")
+	}
+
+	assert.Nil(t, basketsDb.Get("batch04"), "created basket is expected to be deleted")
+	if response := basket.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+		assert.Equal(t, "original", response.Body, "original response is expected to be restored")
+	}
+	if response := basket.GetResponse("PUT"); response != nil {
+		assert.Equal(t, defaultResponse.Status, response.Status, "default response is expected to be restored")
+	}
+}
+
+func TestExecuteBatch_InvalidOperations(t *testing.T) {
+	w, _ := executeTestBatch(t, "", `{"operations": []}`)
+	assert.Equal(t, 400, w.Code, "empty batch is not expected")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [`)
+	assert.Equal(t, 400, w.Code, "invalid JSON is not expected")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [{"op": "create_basket", "basket": "batch05"}, {"op": "drop", "basket": "batch05"}]}`)
+	assert.Equal(t, 400, w.Code, "unknown operation is not expected")
+	assert.Contains(t, errorMessage(t, w), "invalid operation 1", "index of invalid operation is expected")
+	assert.Nil(t, basketsDb.Get("batch05"), "no operation is expected to be executed")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [{"op": "set_response", "basket": "batch05", "method": "FETCH", "response": {}}]}`)
+	assert.Equal(t, 400, w.Code, "unknown HTTP method is not expected")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [{"op": "set_response", "basket": "batch05", "method": "GET"}]}`)
+	assert.Equal(t, 400, w.Code, "response is expected")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [{"op": "create_basket", "basket": "api"}]}`)
+	assert.Equal(t, 400, w.Code, "reserved basket name is not expected")
+
+	w, _ = executeTestBatch(t, "", `{"operations": [{"op": "delete_requests", "basket": "batch05", "query": "query_type=fuzzy&q=x"}]}`)
+	assert.Equal(t, 400, w.Code, "invalid search criteria are not expected")
+}
+
+func TestExecuteBatch_InvalidConfig(t *testing.T) {
+	w, result := executeTestBatch(t, "", `{"operations": [
+		{"op": "create_basket", "basket": "batch06"},
+		{"op": "create_basket", "basket": "batch07", "config": {"capacity": 100000}}]}`)
+	assert.Equal(t, 422, w.Code, "status of failed operation is expected")
+	if assert.NotNil(t, result) && assert.Len(t, result.Results, 2, "wrong number of results") {
+		assert.Contains(t, result.Results[1].Error, "capacity", "error of failed operation is expected")
+	}
+	assert.Nil(t, basketsDb.Get("batch06"), "created basket is expected to be deleted")
+	assert.Nil(t, basketsDb.Get("batch07"), "basket is not expected to be created")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Policies on bodies of collected requests that exceed the size limit
+const (
+	BodyReject   = "reject"   // respond with 413 status without collecting the request
+	BodyTruncate = "truncate" // collect the request with body cut to the size limit
+)
+
+const defaultMaxBodySize = 10 * 1024 * 1024
+
+// BodyLimit describes size limit of bodies of requests collected by basket, the limit of the service applies
+// if basket has no own limit
+type BodyLimit struct {
+	MaxSize int64  `json:"max_size,omitempty"` // bytes, defaults to the limit of the service
+	Policy  string `json:"policy,omitempty"`   // reject or truncate, defaults to the policy of the service
+}
+
+// validateBodyPolicy validates policy on bodies that exceed the size limit, empty policy stands for reject
+func validateBodyPolicy(policy string) error {
+	if len(policy) > 0 && policy != BodyReject && policy != BodyTruncate {
+		return fmt.Errorf("unknown body size policy: %s, expected %s or %s", policy, BodyReject, BodyTruncate)
+	}
+	return nil
+}
+
+// validateBodyLimit validates body size limit of basket, the limit of basket may not exceed the limit of the service
+func validateBodyLimit(limit *BodyLimit) error {
+	if limit.MaxSize < 0 {
+		return fmt.Errorf("body size limit should not be negative, but was %d", limit.MaxSize)
+	}
+	if serverConfig.MaxBodySize > 0 && limit.MaxSize > serverConfig.MaxBodySize {
+		return fmt.Errorf("body size limit may not be greater than %d", serverConfig.MaxBodySize)
+	}
+	return validateBodyPolicy(limit.Policy)
+}
+
+// effectiveBodyLimit returns size limit of bodies collected by basket and the policy on bodies over the limit,
+// settings of basket take precedence over settings of the service; 0 stands for unlimited size
+func effectiveBodyLimit(limit *BodyLimit) (int64, string) {
+	size, policy := serverConfig.MaxBodySize, serverConfig.BodyPolicy
+	if limit != nil {
+		if limit.MaxSize > 0 {
+			size = limit.MaxSize
+		}
+		if len(limit.Policy) > 0 {
+			policy = limit.Policy
+		}
+	}
+	return size, policy
+}
+
+// readLimitedBody reads body of request collected by basket up to the size limit and replaces the body
+// of request with the read bytes, so the body is never read into memory beyond the limit; the body that
+// exceeds the limit is rejected with 413 status or truncated according to the policy, returns true if
+// the body is truncated; writes HTTP response and returns false in case of failure
+func readLimitedBody(w http.ResponseWriter, r *http.Request, limit *BodyLimit) (truncated bool, ok bool) {
+	size, policy := effectiveBodyLimit(limit)
+	if size <= 0 {
+		return false, true
+	}
+
+	tooLarge := fmt.Sprintf("request body exceeds the limit of %d bytes", size)
+	if r.ContentLength > size && policy != BodyTruncate {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return false, false
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, size+1))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, "failed to read request body: "+err.Error(), http.StatusBadRequest)
+		return false, false
+	}
+	if int64(len(body)) > size {
+		if policy != BodyTruncate {
+			http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+			return false, false
+		}
+		body, truncated = body[:size], true
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return truncated, true
+}
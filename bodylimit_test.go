@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBodyLimit(t *testing.T) {
+	assert.NoError(t, validateBodyLimit(&BodyLimit{}))
+	assert.NoError(t, validateBodyLimit(&BodyLimit{MaxSize: 1024, Policy: BodyTruncate}))
+	assert.Error(t, validateBodyLimit(&BodyLimit{MaxSize: -1}), "negative limit is not expected")
+	assert.Error(t, validateBodyLimit(&BodyLimit{MaxSize: serverConfig.MaxBodySize + 1}),
+		"limit over the limit of service is not expected")
+	assert.Error(t, validateBodyLimit(&BodyLimit{Policy: "ignore"}), "unknown policy is not expected")
+	assert.NoError(t, validateBodyPolicy(""), "empty policy is expected to stand for reject")
+}
+
+func TestEffectiveBodyLimit(t *testing.T) {
+	size, policy := effectiveBodyLimit(nil)
+	assert.Equal(t, serverConfig.MaxBodySize, size, "limit of service is expected")
+	assert.Equal(t, serverConfig.BodyPolicy, policy, "policy of service is expected")
+
+	size, policy = effectiveBodyLimit(&BodyLimit{MaxSize: 10})
+	assert.Equal(t, int64(10), size, "limit of basket is expected")
+	assert.Equal(t, serverConfig.BodyPolicy, policy, "policy of service is expected")
+
+	size, policy = effectiveBodyLimit(&BodyLimit{Policy: BodyTruncate})
+	assert.Equal(t, serverConfig.MaxBodySize, size, "limit of service is expected")
+	assert.Equal(t, BodyTruncate, policy, "policy of basket is expected")
+}
+
+func TestAcceptBasketRequests_BodyLimit(t *testing.T) {
+	call := func(method string, path string, token string, body string, chunked bool) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		if chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "bodylimit01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "body_limit": {"max_size": -1}}`, false).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "body_limit": {"max_size": 5}}`, false).Code, "wrong HTTP result code")
+
+	// bodies over the limit are rejected, whether their length is announced or not
+	assert.Equal(t, 200, call("POST", "/"+basket, "", "12345", false).Code, "wrong HTTP result code")
+	assert.Equal(t, 413, call("POST", "/"+basket, "", "123456", false).Code, "wrong HTTP result code")
+	assert.Equal(t, 413, call("POST", "/"+basket, "", "123456", true).Code, "wrong HTTP result code")
+	assert.Equal(t, 1, basketsDb.Get(basket).Size(), "rejected requests are not expected to be collected")
+
+	// bodies over the limit are truncated
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "body_limit": {"max_size": 5, "policy": "truncate"}}`, false).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/"+basket, "", "1234567890", false).Code, "wrong HTTP result code")
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "truncated request is expected to be collected") {
+		assert.Equal(t, "12345", page.Requests[0].Body, "wrong truncated body")
+		assert.Equal(t, int64(10), page.Requests[0].ContentLength, "original content length is expected")
+		assert.True(t, page.Requests[0].BodyTruncated, "truncated body is expected to be marked")
+	}
+	assert.Equal(t, 200, call("POST", "/"+basket, "", "123", false).Code, "wrong HTTP result code")
+	page = basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.False(t, page.Requests[0].BodyTruncated, "body is not expected to be truncated")
+	}
+}
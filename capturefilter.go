@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	maxIgnoreFilters  = 20
+	maxIgnoreSettings = 4000 // size of JSON encoded filters, so the filters fit into SQL column
+)
+
+// validateIgnoreFilters validates filter expressions of requests that are dropped by basket without collecting,
+// see filter.go for syntax of the expressions
+func validateIgnoreFilters(filters []string) error {
+	if len(filters) > maxIgnoreFilters {
+		return fmt.Errorf("number of ignore filters may not be greater than %d", maxIgnoreFilters)
+	}
+	for _, filter := range filters {
+		if _, err := parseFilter(filter); err != nil {
+			return err
+		}
+	}
+	if filtersj, _ := json.Marshal(filters); len(filtersj) > maxIgnoreSettings {
+		return fmt.Errorf("ignore filters may not be longer than %d characters", maxIgnoreSettings)
+	}
+	return nil
+}
+
+// checkIgnoreFilters checks if request to basket matches any of the ignore filters of the basket, e.g. health
+// probes or requests of a noisy client; matching request is answered with 200 status without collecting and
+// counted in capture statistics of basket; writes HTTP response and returns false if request is ignored
+func checkIgnoreFilters(w http.ResponseWriter, name string, filters []string, data *RequestData) bool {
+	for _, filter := range filters {
+		expr, err := parseFilter(filter)
+		if err != nil || !expr.Match(data) {
+			continue
+		}
+		captureLimits.Ignore(name, time.Now())
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+	return true
+}
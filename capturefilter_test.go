@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIgnoreFilters(t *testing.T) {
+	assert.NoError(t, validateIgnoreFilters(nil))
+	assert.NoError(t, validateIgnoreFilters([]string{"header:User-Agent:kube-probe", "method:GET AND path:/health"}))
+	assert.Error(t, validateIgnoreFilters([]string{"method:GET AND ("}), "invalid filter is not expected")
+	assert.Error(t, validateIgnoreFilters([]string{""}), "empty filter is not expected")
+	assert.Error(t, validateIgnoreFilters(make([]string, maxIgnoreFilters+1)), "too many filters are not expected")
+	assert.Error(t, validateIgnoreFilters([]string{strings.Repeat("x", maxIgnoreSettings)}),
+		"too long filters are not expected")
+}
+
+func TestCaptureLimitRegistry_Ignore(t *testing.T) {
+	l := newCaptureLimitRegistry()
+	now := time.Now()
+
+	l.Ignore("ignore01", now)
+	l.Ignore("ignore01", now)
+	stats := l.Get("ignore01")
+	assert.Equal(t, int64(2), stats.Ignored, "wrong number of ignored requests")
+	assert.Equal(t, now.UnixNano()/toMs, stats.LastIgnored, "wrong time of the last ignored request")
+
+	// ignored requests do not drain rate limit of basket
+	allowed, _ := l.Allow("ignore01", &CaptureLimit{Rate: 1}, now)
+	assert.True(t, allowed, "request is expected to be allowed")
+}
+
+func TestAcceptBasketRequests_Ignore(t *testing.T) {
+	call := func(method string, path string, token string, body string, userAgent string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		r.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "ignore02"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "ignore": ["color:red"]}`, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "ignore": ["header:User-Agent:kube-probe", "body~\"ping\""]}`, "").Code,
+		"wrong HTTP result code")
+
+	assert.Equal(t, 200, call("GET", "/"+basket+"/healthz", "", "", "kube-probe/1.27").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/"+basket, "", `{"type": "ping"}`, "curl/8.0").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/"+basket, "", `{"type": "order"}`, "curl/8.0").Code, "wrong HTTP result code")
+	assert.Equal(t, 1, basketsDb.Get(basket).Size(), "ignored requests are not expected to be collected")
+
+	w := call("GET", "/api/baskets/"+basket+"/rate-limit", auth.Token, "", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"ignored":2`, "ignored requests are expected to be counted")
+	}
+}
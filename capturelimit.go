@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Actions on requests that exceed capture rate limit of basket
+const (
+	CaptureReject = "reject" // respond with 429 status
+	CaptureDrop   = "drop"   // respond with 200 status without collecting the request
+)
+
+const maxCaptureRate = 100000 // requests per second
+
+var captureLimits = newCaptureLimitRegistry()
+
+// CaptureLimit describes rate limit of requests collected by basket, so a misconfigured sender cannot
+// blow through the capacity of basket in seconds; the limit follows token bucket algorithm
+type CaptureLimit struct {
+	Rate   float64 `json:"rate"`             // requests per second
+	Burst  int     `json:"burst,omitempty"`  // requests accepted at once, defaults to the rate rounded up
+	Action string  `json:"action,omitempty"` // reject or drop, reject by default
+}
+
+// CaptureStats describes requests of basket that were not collected since service start, because they
+// exceeded capture rate limit or matched ignore filters of basket
+type CaptureStats struct {
+	Rejected    int64 `json:"rejected"`
+	Dropped     int64 `json:"dropped"`
+	LastLimited int64 `json:"last_limited,omitempty"`
+	Ignored     int64 `json:"ignored"`
+	LastIgnored int64 `json:"last_ignored,omitempty"`
+}
+
+type captureBucket struct {
+	tokens float64
+	last   time.Time
+	stats  CaptureStats
+}
+
+// captureLimitRegistry keeps in-memory token buckets of baskets with capture rate limit and statistics
+// of requests that were not collected, the buckets and statistics are not persisted and are reset on service restart
+type captureLimitRegistry struct {
+	sync.Mutex
+	buckets map[string]*captureBucket
+}
+
+func newCaptureLimitRegistry() *captureLimitRegistry {
+	return &captureLimitRegistry{buckets: make(map[string]*captureBucket)}
+}
+
+// Allow takes a token from the bucket of basket and reports whether the request is within the limit,
+// the request that exceeds the limit is counted and time until the next token is available is returned
+func (l *captureLimitRegistry) Allow(basket string, limit *CaptureLimit, now time.Time) (bool, time.Duration) {
+	burst := float64(limit.burst())
+
+	l.Lock()
+	defer l.Unlock()
+
+	bucket, exists := l.buckets[basket]
+	if !exists {
+		bucket = &captureBucket{}
+		l.buckets[basket] = bucket
+	}
+	if bucket.last.IsZero() {
+		// bucket of basket that was never limited yet is full
+		bucket.tokens, bucket.last = burst, now
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed.Seconds()*limit.Rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	if limit.Action == CaptureDrop {
+		bucket.stats.Dropped++
+	} else {
+		bucket.stats.Rejected++
+	}
+	bucket.stats.LastLimited = now.UnixNano() / toMs
+	return false, time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+}
+
+// Ignore counts request of basket that matched ignore filters of basket
+func (l *captureLimitRegistry) Ignore(basket string, now time.Time) {
+	l.Lock()
+	defer l.Unlock()
+
+	bucket, exists := l.buckets[basket]
+	if !exists {
+		bucket = &captureBucket{}
+		l.buckets[basket] = bucket
+	}
+	bucket.stats.Ignored++
+	bucket.stats.LastIgnored = now.UnixNano() / toMs
+}
+
+// Get returns statistics of requests of basket that were not collected
+func (l *captureLimitRegistry) Get(basket string) CaptureStats {
+	l.Lock()
+	defer l.Unlock()
+
+	if bucket, exists := l.buckets[basket]; exists {
+		return bucket.stats
+	}
+	return CaptureStats{}
+}
+
+// Remove drops token bucket and statistics of basket
+func (l *captureLimitRegistry) Remove(basket string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.buckets, basket)
+}
+
+// Rename moves token bucket and statistics of basket under the new basket name
+func (l *captureLimitRegistry) Rename(basket string, newName string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if bucket, exists := l.buckets[basket]; exists {
+		delete(l.buckets, basket)
+		l.buckets[newName] = bucket
+	}
+}
+
+// burst returns number of requests accepted at once
+func (limit *CaptureLimit) burst() int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return int(math.Ceil(limit.Rate))
+}
+
+// validateCaptureLimit validates capture rate limit of basket
+func validateCaptureLimit(limit *CaptureLimit) error {
+	if limit.Rate <= 0 || limit.Rate > maxCaptureRate {
+		return fmt.Errorf("rate limit should be between 0 and %d requests per second, but was %v", maxCaptureRate,
+			limit.Rate)
+	}
+	if limit.Burst < 0 || limit.Burst > maxCaptureRate {
+		return fmt.Errorf("burst of rate limit should be between 0 and %d requests, but was %d", maxCaptureRate,
+			limit.Burst)
+	}
+	if len(limit.Action) > 0 && limit.Action != CaptureReject && limit.Action != CaptureDrop {
+		return fmt.Errorf("unknown action of rate limit: %s, expected %s or %s", limit.Action, CaptureReject, CaptureDrop)
+	}
+	return nil
+}
+
+// checkCaptureLimit checks if request to basket is within capture rate limit of the basket, the request that
+// exceeds the limit is either rejected with 429 status or silently dropped; writes HTTP response and returns
+// false in case of failure
+func checkCaptureLimit(w http.ResponseWriter, name string, limit *CaptureLimit) bool {
+	if limit == nil {
+		return true
+	}
+
+	allowed, retry := captureLimits.Allow(name, limit, time.Now())
+	if allowed {
+		return true
+	}
+	if limit.Action == CaptureDrop {
+		w.WriteHeader(http.StatusOK)
+		return false
+	}
+
+	seconds := strconv.Itoa(int((retry + time.Second - 1) / time.Second))
+	w.Header().Set("Retry-After", seconds)
+	http.Error(w, "rate limit of basket is exceeded, retry in "+seconds+" seconds", http.StatusTooManyRequests)
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCaptureLimit(t *testing.T) {
+	assert.NoError(t, validateCaptureLimit(&CaptureLimit{Rate: 0.5}))
+	assert.NoError(t, validateCaptureLimit(&CaptureLimit{Rate: 10, Burst: 50, Action: CaptureDrop}))
+	assert.Error(t, validateCaptureLimit(&CaptureLimit{}), "rate is expected")
+	assert.Error(t, validateCaptureLimit(&CaptureLimit{Rate: maxCaptureRate + 1}), "rate is expected to be limited")
+	assert.Error(t, validateCaptureLimit(&CaptureLimit{Rate: 1, Burst: -1}), "negative burst is not expected")
+	assert.Error(t, validateCaptureLimit(&CaptureLimit{Rate: 1, Action: "ignore"}), "unknown action is not expected")
+}
+
+func TestCaptureLimitRegistry_Allow(t *testing.T) {
+	l := newCaptureLimitRegistry()
+	limit := &CaptureLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+
+	// burst is accepted at once
+	for i := 0; i < 3; i++ {
+		allowed, _ := l.Allow("limit01", limit, now)
+		assert.True(t, allowed, "request within burst is expected to be allowed")
+	}
+	allowed, retry := l.Allow("limit01", limit, now)
+	assert.False(t, allowed, "request over burst is not expected to be allowed")
+	assert.Equal(t, 500*time.Millisecond, retry, "wrong time until the next token")
+
+	// tokens are restored with the rate
+	allowed, _ = l.Allow("limit01", limit, now.Add(500*time.Millisecond))
+	assert.True(t, allowed, "restored token is expected")
+	allowed, _ = l.Allow("limit01", limit, now.Add(500*time.Millisecond))
+	assert.False(t, allowed, "request over rate is not expected to be allowed")
+	assert.Equal(t, int64(2), l.Get("limit01").Rejected, "wrong number of rejected requests")
+
+	// dropped requests are counted separately
+	drop := &CaptureLimit{Rate: 1, Action: CaptureDrop}
+	l.Allow("limit02", drop, now)
+	l.Allow("limit02", drop, now)
+	stats := l.Get("limit02")
+	assert.Equal(t, int64(1), stats.Dropped, "wrong number of dropped requests")
+	assert.Equal(t, int64(0), stats.Rejected, "rejected requests are not expected")
+	assert.Equal(t, now.UnixNano()/toMs, stats.LastLimited, "wrong time of the last limited request")
+
+	l.Rename("limit02", "limit03")
+	assert.Equal(t, int64(1), l.Get("limit03").Dropped, "statistics are expected under the new name")
+	l.Remove("limit03")
+	assert.Equal(t, CaptureStats{}, l.Get("limit03"), "statistics are expected to be removed")
+}
+
+func TestAcceptBasketRequests_RateLimit(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	auth, err := basketsDb.Create("ratelimit01", BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/ratelimit01", auth.Token,
+		`{"capacity": 20, "rate_limit": {"rate": 1, "action": "ignore"}}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/api/baskets/ratelimit01", auth.Token,
+		`{"capacity": 20, "rate_limit": {"rate": 0.01, "burst": 2}}`).Code, "wrong HTTP result code")
+
+	// requests over the limit are rejected
+	assert.Equal(t, 200, call("POST", "/ratelimit01", "", "data").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/ratelimit01", "", "data").Code, "wrong HTTP result code")
+	w := call("POST", "/ratelimit01", "", "data")
+	if assert.Equal(t, 429, w.Code, "wrong HTTP result code") {
+		assert.NotEmpty(t, w.Header().Get("Retry-After"), "Retry-After header is expected")
+	}
+	assert.Equal(t, 2, basketsDb.Get("ratelimit01").Size(), "rejected request is not expected to be collected")
+
+	// requests over the limit are dropped silently
+	assert.Equal(t, 204, call("PUT", "/api/baskets/ratelimit01", auth.Token,
+		`{"capacity": 20, "rate_limit": {"rate": 0.01, "burst": 2, "action": "drop"}}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/ratelimit01", "", "data").Code, "wrong HTTP result code")
+	assert.Equal(t, 2, basketsDb.Get("ratelimit01").Size(), "dropped request is not expected to be collected")
+
+	w = call("GET", "/api/baskets/ratelimit01/rate-limit", auth.Token, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"rejected":1,"dropped":1`, "wrong statistics")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+// Actions of cleanup policies
+const (
+	CleanupExpireIdle     = "expire_idle"     // delete baskets without collected requests or changes for a number of days
+	CleanupClearOversized = "clear_oversized" // clear requests of baskets that store more bytes than allowed
+	CleanupCompact        = "compact"         // reclaim space of deleted data in storage of database
+)
+
+const (
+	maxCleanupPolicies = 20
+	maxCleanupReports  = 100 // the latest reports that are kept in memory
+)
+
+var cleanup *cleanupScheduler
+
+// CleanupPolicy describes cleanup action that runs on cron schedule, dry run only reports what would be cleaned up.
+type CleanupPolicy struct {
+	Name     string `json:"name"`
+	Cron     string `json:"cron"` // e.g. "0 3 * * *" or "@daily"
+	Action   string `json:"action"`
+	IdleDays int    `json:"idle_days,omitempty"` // expire_idle: days without collected requests or changes
+	MaxBytes int64  `json:"max_bytes,omitempty"` // clear_oversized: bytes stored by basket
+	DryRun   bool   `json:"dry_run,omitempty"`
+}
+
+// CleanupReport describes result of a single run of cleanup policy.
+type CleanupReport struct {
+	ID       string   `json:"id"`
+	Policy   string   `json:"policy"`
+	Action   string   `json:"action"`
+	Date     int64    `json:"date"`
+	DryRun   bool     `json:"dry_run"`
+	Baskets  []string `json:"baskets"`  // deleted or cleared baskets
+	Requests int      `json:"requests"` // deleted requests
+	Bytes    int64    `json:"bytes"`    // approximate freed bytes
+	Error    string   `json:"error,omitempty"`
+}
+
+type cleanupJob struct {
+	policy   CleanupPolicy
+	schedule *cronSchedule
+}
+
+// cleanupScheduler runs cleanup policies of the service according to their cron schedules, policies and reports
+// are kept in memory
+type cleanupScheduler struct {
+	sync.Mutex
+	db      BasketsDatabase
+	remove  func(name string)
+	jobs    []*cleanupJob
+	reports []*CleanupReport
+}
+
+func newCleanupScheduler(db BasketsDatabase, remove func(name string)) *cleanupScheduler {
+	return &cleanupScheduler{db: db, remove: remove, jobs: make([]*cleanupJob, 0), reports: make([]*CleanupReport, 0)}
+}
+
+// loadCleanupPolicies reads cleanup policies from JSON file
+func loadCleanupPolicies(file string) ([]CleanupPolicy, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cleanup policies: %s", err)
+	}
+
+	policies := []CleanupPolicy{}
+	if err = json.Unmarshal(content, &policies); err != nil {
+		return nil, fmt.Errorf("invalid cleanup policies: %s - %s", file, err)
+	}
+	return policies, validateCleanupPolicies(policies)
+}
+
+// validateCleanupPolicies validates cleanup policies, names of policies must be unique
+func validateCleanupPolicies(policies []CleanupPolicy) error {
+	if len(policies) > maxCleanupPolicies {
+		return fmt.Errorf("too many cleanup policies: %d, maximum is %d", len(policies), maxCleanupPolicies)
+	}
+
+	names := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		if !validBasketName.MatchString(policy.Name) {
+			return fmt.Errorf("invalid name of cleanup policy: %s, expected: %s", policy.Name, validBasketName.String())
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("duplicate cleanup policy: %s", policy.Name)
+		}
+		names[policy.Name] = true
+
+		if _, err := parseCron(policy.Cron); err != nil {
+			return fmt.Errorf("invalid schedule of cleanup policy: %s - %s", policy.Name, err)
+		}
+		switch policy.Action {
+		case CleanupExpireIdle:
+			if policy.IdleDays <= 0 {
+				return fmt.Errorf("cleanup policy: %s requires positive number of idle days", policy.Name)
+			}
+		case CleanupClearOversized:
+			if policy.MaxBytes <= 0 {
+				return fmt.Errorf("cleanup policy: %s requires positive number of max bytes", policy.Name)
+			}
+		case CleanupCompact:
+		default:
+			return fmt.Errorf("unknown action of cleanup policy: %s, expected %s, %s or %s",
+				policy.Action, CleanupExpireIdle, CleanupClearOversized, CleanupCompact)
+		}
+	}
+	return nil
+}
+
+// Policies returns cleanup policies
+func (c *cleanupScheduler) Policies() []CleanupPolicy {
+	c.Lock()
+	defer c.Unlock()
+
+	policies := make([]CleanupPolicy, len(c.jobs))
+	for i, job := range c.jobs {
+		policies[i] = job.policy
+	}
+	return policies
+}
+
+// SetPolicies replaces cleanup policies, policies are expected to be validated
+func (c *cleanupScheduler) SetPolicies(policies []CleanupPolicy) {
+	jobs := make([]*cleanupJob, 0, len(policies))
+	for _, policy := range policies {
+		schedule, _ := parseCron(policy.Cron)
+		jobs = append(jobs, &cleanupJob{policy, schedule})
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.jobs = jobs
+}
+
+// Policy returns cleanup policy by name
+func (c *cleanupScheduler) Policy(name string) (CleanupPolicy, bool) {
+	for _, policy := range c.Policies() {
+		if policy.Name == name {
+			return policy, true
+		}
+	}
+	return CleanupPolicy{}, false
+}
+
+// Reports returns reports of the latest runs of cleanup policies, the latest reports go first
+func (c *cleanupScheduler) Reports() []*CleanupReport {
+	c.Lock()
+	defer c.Unlock()
+
+	reports := make([]*CleanupReport, len(c.reports))
+	for i, report := range c.reports {
+		reports[len(c.reports)-1-i] = report
+	}
+	return reports
+}
+
+// Start launches background routine that checks schedules at the beginning of every minute
+func (c *cleanupScheduler) Start() {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(next.Sub(now))
+			c.RunDue(next)
+		}
+	}()
+}
+
+// RunDue runs all cleanup policies that are due at given time
+func (c *cleanupScheduler) RunDue(t time.Time) {
+	c.Lock()
+	due := make([]CleanupPolicy, 0)
+	for _, job := range c.jobs {
+		if job.schedule.Matches(t) {
+			due = append(due, job.policy)
+		}
+	}
+	c.Unlock()
+
+	for _, policy := range due {
+		c.Run(policy, policy.DryRun, t)
+	}
+}
+
+// Run runs cleanup policy at given time and keeps the report of the run, dry run only reports affected baskets
+func (c *cleanupScheduler) Run(policy CleanupPolicy, dryRun bool, now time.Time) *CleanupReport {
+	id, _ := GenerateToken()
+	report := &CleanupReport{ID: id, Policy: policy.Name, Action: policy.Action, Date: now.UnixNano() / toMs,
+		DryRun: dryRun, Baskets: make([]string, 0)}
+
+	switch policy.Action {
+	case CleanupExpireIdle:
+		c.expireIdle(report, time.Duration(policy.IdleDays)*24*time.Hour, now)
+	case CleanupClearOversized:
+		c.clearOversized(report, policy.MaxBytes)
+	case CleanupCompact:
+		if !dryRun {
+			if err := c.db.Compact(); err != nil {
+				report.Error = err.Error()
+			}
+		}
+	}
+
+	if len(report.Error) > 0 {
+		log.Printf("[warn] cleanup policy: %s failed - %s", policy.Name, report.Error)
+	} else if !dryRun {
+		log.Printf("[info] cleanup policy: %s affected %d baskets, deleted %d requests", policy.Name,
+			len(report.Baskets), report.Requests)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.reports = append(c.reports, report); len(c.reports) > maxCleanupReports {
+		c.reports = c.reports[len(c.reports)-maxCleanupReports:]
+	}
+	return report
+}
+
+// expireIdle deletes baskets that have no collected requests or changes for given period, baskets that were
+// never changed are skipped since their idle time is unknown
+func (c *cleanupScheduler) expireIdle(report *CleanupReport, idle time.Duration, now time.Time) {
+	cutoff := now.Add(-idle).UnixNano() / toMs
+	forEachBasket(c.db, func(name string, basket Basket) {
+		if modified := basket.LastModified(); modified > 0 && modified < cutoff {
+			report.Baskets = append(report.Baskets, name)
+			report.Requests += basket.Size()
+			report.Bytes += storage.Bytes(name, basket)
+		}
+	})
+
+	// baskets are deleted after iteration, so pages of basket names are not shifted
+	if !report.DryRun {
+		for _, name := range report.Baskets {
+			c.remove(name)
+		}
+	}
+}
+
+// clearOversized clears requests of baskets that store more bytes than allowed, cleared requests are archived
+// if archive is configured
+func (c *cleanupScheduler) clearOversized(report *CleanupReport, maxBytes int64) {
+	forEachBasket(c.db, func(name string, basket Basket) {
+		bytes := storage.Bytes(name, basket)
+		if bytes <= maxBytes {
+			return
+		}
+
+		report.Baskets = append(report.Baskets, name)
+		report.Requests += basket.Size()
+		report.Bytes += bytes
+		if !report.DryRun {
+			if archive != nil {
+				StreamRequests(basket, nil, exportPageSize, func(page []*RequestData) error {
+					archive.Archive(name, ArchiveReasonCleanup, page)
+					return nil
+				})
+			}
+			basket.Clear()
+			storage.Remove(name)
+		}
+	})
+}
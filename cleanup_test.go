@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCleanupPolicies(t *testing.T) {
+	assert.NoError(t, validateCleanupPolicies([]CleanupPolicy{
+		{Name: "idle", Cron: "@daily", Action: CleanupExpireIdle, IdleDays: 30},
+		{Name: "oversized", Cron: "0 * * * *", Action: CleanupClearOversized, MaxBytes: 1024},
+		{Name: "compact", Cron: "0 3 * * 0", Action: CleanupCompact}}))
+
+	assert.Error(t, validateCleanupPolicies([]CleanupPolicy{{Name: "idle", Cron: "@daily", Action: CleanupExpireIdle}}),
+		"idle days are expected")
+	assert.Error(t, validateCleanupPolicies([]CleanupPolicy{{Name: "big", Cron: "@daily", Action: CleanupClearOversized}}),
+		"max bytes are expected")
+	assert.Error(t, validateCleanupPolicies([]CleanupPolicy{{Name: "x", Cron: "daily", Action: CleanupCompact}}),
+		"invalid schedule is not expected")
+	assert.Error(t, validateCleanupPolicies([]CleanupPolicy{{Name: "x", Cron: "@daily", Action: "drop"}}),
+		"unknown action is not expected")
+	assert.Error(t, validateCleanupPolicies([]CleanupPolicy{{Name: "x", Cron: "@daily", Action: CleanupCompact},
+		{Name: "x", Cron: "@hourly", Action: CleanupCompact}}), "duplicate names are not expected")
+}
+
+func TestCleanupScheduler_ExpireIdle(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("cleanup01", BasketConfig{Capacity: 20})
+	db.Create("cleanup02", BasketConfig{Capacity: 20})
+	db.Get("cleanup01").AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+
+	c := newCleanupScheduler(db, db.Delete)
+	policy := CleanupPolicy{Name: "idle", Cron: "@daily", Action: CleanupExpireIdle, IdleDays: 7}
+
+	// dry run
+	report := c.Run(policy, true, time.Now().Add(8*24*time.Hour))
+	assert.True(t, report.DryRun, "dry run is expected")
+	assert.Equal(t, []string{"cleanup01"}, report.Baskets, "basket without changes is not expected to be idle")
+	assert.Equal(t, 1, report.Requests, "wrong number of requests")
+	assert.NotNil(t, db.Get("cleanup01"), "basket is not expected to be deleted by dry run")
+
+	assert.Empty(t, c.Run(policy, false, time.Now().Add(24*time.Hour)).Baskets, "active baskets are not expected to expire")
+
+	report = c.Run(policy, false, time.Now().Add(8*24*time.Hour))
+	assert.Equal(t, []string{"cleanup01"}, report.Baskets, "wrong expired baskets")
+	assert.Nil(t, db.Get("cleanup01"), "idle basket is expected to be deleted")
+	assert.NotNil(t, db.Get("cleanup02"), "basket is not expected to be deleted")
+
+	reports := c.Reports()
+	if assert.Len(t, reports, 3, "wrong number of reports") {
+		assert.Equal(t, report.ID, reports[0].ID, "the latest report is expected first")
+	}
+}
+
+func TestCleanupScheduler_ClearOversized(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("cleanup03", BasketConfig{Capacity: 20})
+	db.Create("cleanup04", BasketConfig{Capacity: 20})
+	db.Get("cleanup03").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: strings.Repeat("a", 1000)})
+	db.Get("cleanup04").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: "small"})
+
+	c := newCleanupScheduler(db, db.Delete)
+	report := c.Run(CleanupPolicy{Name: "oversized", Cron: "@daily", Action: CleanupClearOversized, MaxBytes: 500}, false, time.Now())
+	assert.Equal(t, []string{"cleanup03"}, report.Baskets, "wrong cleared baskets")
+	assert.Equal(t, int64(1005), report.Bytes, "wrong number of freed bytes")
+	assert.Equal(t, 0, db.Get("cleanup03").Size(), "oversized basket is expected to be cleared")
+	assert.Equal(t, 1, db.Get("cleanup04").Size(), "basket is not expected to be cleared")
+}
+
+func TestCleanupScheduler_RunDue(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	c := newCleanupScheduler(db, db.Delete)
+	c.SetPolicies([]CleanupPolicy{{Name: "compact", Cron: "0 3 * * *", Action: CleanupCompact}})
+
+	c.RunDue(time.Date(2024, 1, 1, 2, 0, 0, 0, time.Local))
+	assert.Empty(t, c.Reports(), "policy is not expected to be due")
+	c.RunDue(time.Date(2024, 1, 1, 3, 0, 0, 0, time.Local))
+	if reports := c.Reports(); assert.Len(t, reports, 1, "policy is expected to run") {
+		assert.Equal(t, "compact", reports[0].Policy, "wrong policy")
+		assert.Empty(t, reports[0].Error, "compaction is not expected to fail")
+	}
+}
+
+func TestLoadCleanupPolicies(t *testing.T) {
+	file, _ := ioutil.TempFile("", "cleanup*.json")
+	file.WriteString(`[{"name": "idle", "cron": "@daily", "action": "expire_idle", "idle_days": 30}]`)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	policies, err := loadCleanupPolicies(file.Name())
+	if assert.NoError(t, err) && assert.Len(t, policies, 1, "wrong number of policies") {
+		assert.Equal(t, 30, policies[0].IdleDays, "wrong idle days")
+	}
+
+	_, err = loadCleanupPolicies(file.Name() + ".unknown")
+	assert.Error(t, err, "missing file is not expected")
+}
+
+func TestCleanupPolicies(t *testing.T) {
+	defer cleanup.SetPolicies(nil)
+	call := func(method string, path string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api/cleanup"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "cleanup05"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: strings.Repeat("b", 2000)})
+
+	assert.Equal(t, 422, call("PUT", "/policies", `[{"name": "x", "cron": "@daily", "action": "drop"}]`).Code,
+		"invalid policy is not expected")
+	assert.Equal(t, 204, call("PUT", "/policies",
+		`[{"name": "oversized", "cron": "@daily", "action": "clear_oversized", "max_bytes": 1500}]`).Code, "wrong HTTP result code")
+
+	w := call("GET", "/policies", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"name":"oversized"`, "policy is expected")
+	}
+
+	assert.Equal(t, 404, call("POST", "/policies/unknown/run", "").Code, "wrong HTTP result code")
+
+	w = call("POST", "/policies/oversized/run?dry_run=true", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		report := CleanupReport{}
+		json.Unmarshal(w.Body.Bytes(), &report)
+		assert.True(t, report.DryRun, "dry run is expected")
+		assert.Contains(t, report.Baskets, basket, "oversized basket is expected to be reported")
+		assert.Equal(t, 1, basketsDb.Get(basket).Size(), "basket is not expected to be cleared by dry run")
+	}
+
+	assert.Equal(t, 200, call("POST", "/policies/oversized/run", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 0, basketsDb.Get(basket).Size(), "oversized basket is expected to be cleared")
+
+	w = call("GET", "/reports", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		reports := []*CleanupReport{}
+		json.Unmarshal(w.Body.Bytes(), &reports)
+		if assert.True(t, len(reports) >= 2, "reports are expected") {
+			assert.False(t, reports[0].DryRun, "the latest run is expected first")
+		}
+	}
+}
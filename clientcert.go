@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var clientCerts *clientCertAuthenticator
+
+// clientCertRule maps identity of client certificate to service role
+type clientCertRule struct {
+	identity string // common name or subject alternative name, "*" matches any identity
+	role     string
+}
+
+// clientCertAuthenticator requires client certificates issued by trusted CAs to access service API and web UI,
+// identities of certificates are mapped to service roles; baskets collect requests without certificates
+type clientCertAuthenticator struct {
+	pool  *x509.CertPool
+	rules []clientCertRule
+}
+
+// newClientCertAuthenticator creates authenticator of client certificates from server configuration
+func newClientCertAuthenticator(config *ServerConfig) (*clientCertAuthenticator, error) {
+	if (len(config.TLSCert) == 0 || len(config.TLSKey) == 0) && len(config.ACMEDomains) == 0 {
+		return nil, fmt.Errorf("client certificates require TLS certificate and key of the service or ACME domains")
+	}
+
+	data, err := ioutil.ReadFile(config.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %s - %s", config.ClientCA, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates are found in client CA file: %s", config.ClientCA)
+	}
+
+	a := &clientCertAuthenticator{pool: pool}
+	for _, rule := range config.ClientRoles {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || !isServiceRole(parts[1]) {
+			return nil, fmt.Errorf("invalid role of client certificate: %s, expected <identity>=<role>", rule)
+		}
+		a.rules = append(a.rules, clientCertRule{identity: parts[0], role: parts[1]})
+	}
+	return a, nil
+}
+
+// TLSConfig returns TLS configuration of the service that verifies client certificates if they are presented,
+// so clients of baskets may connect without certificates
+func (a *clientCertAuthenticator) TLSConfig() *tls.Config {
+	return &tls.Config{ClientCAs: a.pool, ClientAuth: tls.VerifyClientCertIfGiven}
+}
+
+// Certificate returns verified client certificate of request, nil if request has no verified certificate
+func (a *clientCertAuthenticator) Certificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// Identify maps verified client certificate of request to the token of service role, empty token is returned
+// if request has no certificate or identity of certificate is not mapped to a role
+func (a *clientCertAuthenticator) Identify(r *http.Request) (string, error) {
+	cert := a.Certificate(r)
+	if cert == nil {
+		return "", nil
+	}
+
+	identities := certIdentities(cert)
+	for _, rule := range a.rules {
+		for _, identity := range identities {
+			if rule.identity == "*" || rule.identity == identity {
+				return roles.Session("cert:"+identities[0], rule.role)
+			}
+		}
+	}
+	return "", nil
+}
+
+// certIdentities lists identities of certificate: common name followed by DNS names, email addresses and URIs
+func certIdentities(cert *x509.Certificate) []string {
+	identities := []string{}
+	if len(cert.Subject.CommonName) > 0 {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if len(identities) == 0 {
+		identities = append(identities, cert.SerialNumber.String())
+	}
+	return identities
+}
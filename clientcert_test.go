@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificate creates certificate signed by parent, self-signed certificate is created if parent is nil
+func testCertificate(t *testing.T, name string, dnsNames []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// testClientCertConfig writes CA certificate to a file and returns server configuration that requires client certificates
+func testClientCertConfig(t *testing.T, ca *x509.Certificate, rules ...string) *ServerConfig {
+	file, err := ioutil.TempFile("", "client-ca")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pem.Encode(file, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	file.Close()
+	return &ServerConfig{TLSCert: "cert.pem", TLSKey: "key.pem", ClientCA: file.Name(), ClientRoles: rules}
+}
+
+// withClientCert presents verified client certificate with HTTP request
+func withClientCert(r *http.Request, cert *x509.Certificate, ca *x509.Certificate) *http.Request {
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains: [][]*x509.Certificate{{cert, ca}}}
+	return r
+}
+
+func TestNewClientCertAuthenticator(t *testing.T) {
+	ca, _ := testCertificate(t, "Test CA", nil, nil, nil)
+	config := testClientCertConfig(t, ca, "ci.example.com=operator", "*=viewer")
+	defer os.Remove(config.ClientCA)
+
+	a, err := newClientCertAuthenticator(config)
+	if assert.NoError(t, err) {
+		assert.Len(t, a.rules, 2, "wrong number of rules")
+		tlsConfig := a.TLSConfig()
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth, "clients without certificates are expected")
+		assert.NotNil(t, tlsConfig.ClientCAs, "client CAs are expected")
+	}
+
+	_, err = newClientCertAuthenticator(&ServerConfig{ClientCA: config.ClientCA})
+	assert.Error(t, err, "TLS certificate of service is expected")
+	_, err = newClientCertAuthenticator(&ServerConfig{TLSCert: "cert.pem", TLSKey: "key.pem", ClientCA: "missing.pem"})
+	assert.Error(t, err, "missing CA file is not expected")
+	config.ClientRoles = []string{"ci.example.com=unknown"}
+	_, err = newClientCertAuthenticator(config)
+	assert.Error(t, err, "unknown role is not expected")
+	config.ClientRoles = []string{"operator"}
+	_, err = newClientCertAuthenticator(config)
+	assert.Error(t, err, "invalid rule is not expected")
+}
+
+func TestClientCertAuthenticator_Identify(t *testing.T) {
+	ca, caKey := testCertificate(t, "Test CA", nil, nil, nil)
+	config := testClientCertConfig(t, ca, "ci.example.com=operator")
+	defer os.Remove(config.ClientCA)
+	a, err := newClientCertAuthenticator(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r, _ := http.NewRequest("GET", "https://localhost:55555/api/stats", nil)
+	assert.Nil(t, a.Certificate(r), "certificate is not expected without TLS")
+	token, err := a.Identify(r)
+	assert.NoError(t, err)
+	assert.Empty(t, token, "token is not expected without certificate")
+
+	// identity is matched with alternative names
+	cert, _ := testCertificate(t, "ci-runner", []string{"ci.example.com"}, ca, caKey)
+	token, err = a.Identify(withClientCert(r, cert, ca))
+	if assert.NoError(t, err) && assert.NotEmpty(t, token, "token is expected") {
+		if role, ok := roles.Lookup(token); assert.True(t, ok, "session token of role is expected") {
+			assert.Equal(t, RoleOperator, role.Role, "wrong role")
+			assert.Equal(t, "cert:ci-runner", role.Name, "wrong name")
+		}
+		again, _ := a.Identify(r)
+		assert.Equal(t, token, again, "the same token is expected")
+	}
+
+	// certificate without role
+	other, _ := testCertificate(t, "other", []string{"other.example.com"}, ca, caKey)
+	token, err = a.Identify(withClientCert(r, other, ca))
+	assert.NoError(t, err)
+	assert.Empty(t, token, "token is not expected for identity without role")
+
+	// presented certificate that is not verified
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	assert.Nil(t, a.Certificate(r), "unverified certificate is not expected")
+}
+
+func TestClientCerts(t *testing.T) {
+	ca, caKey := testCertificate(t, "Test CA", nil, nil, nil)
+	config := testClientCertConfig(t, ca, "viewer.example.com=viewer")
+	defer os.Remove(config.ClientCA)
+	a, err := newClientCertAuthenticator(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	clientCerts = a
+	defer func() { clientCerts = nil }()
+
+	viewer, _ := testCertificate(t, "viewer.example.com", nil, ca, caKey)
+	other, _ := testCertificate(t, "other.example.com", nil, ca, caKey)
+	call := func(method string, path string, token string, cert *x509.Certificate) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "https://localhost:55555"+path, strings.NewReader(""))
+		r.Header.Add("Authorization", token)
+		if cert != nil {
+			withClientCert(r, cert, ca)
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// service API and web UI require client certificate
+	w := call("GET", "/api/stats", serverConfig.MasterToken, nil)
+	if assert.Equal(t, 403, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), "client certificate is required", "wrong error message")
+	}
+	assert.Equal(t, 403, call("GET", "/web", "", nil).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/web", "", other).Code, "wrong HTTP result code")
+
+	// certificate does not replace token unless it is mapped to a role
+	assert.Equal(t, 200, call("GET", "/api/stats", serverConfig.MasterToken, other).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/api/stats", "", other).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/api/stats", "", viewer).Code, "role of certificate is expected")
+	assert.Equal(t, 401, call("GET", "/api/users", "", viewer).Code, "permissions of role are expected")
+
+	// baskets collect requests without certificates
+	basket := "clientcert01"
+	if _, err = basketsDb.Create(basket, BasketConfig{Capacity: 20}); assert.NoError(t, err) {
+		assert.Equal(t, 200, call("POST", "/"+basket, "", nil).Code, "wrong HTTP result code")
+	}
+}
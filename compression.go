@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Compression of bodies of requests stored by persistent databases
+const (
+	BodyCompressionNone = "none"
+	BodyCompressionGzip = "gzip"
+)
+
+// minCompressedBodySize defines size of body in bytes below which bodies are stored as is,
+// since compression of small bodies does not pay off
+const minCompressedBodySize = 512
+
+// bodyCompression is compression of bodies of requests stored by Bolt and SQL databases, stored requests are
+// decompressed on read regardless of the setting, so it may be changed at any time
+var bodyCompression = BodyCompressionNone
+
+// storedRequest is the form of collected request in persistent databases, compressed body is stored base64 encoded
+// instead of the original body
+type storedRequest struct {
+	*RequestData
+	Body            *string `json:"body,omitempty"` // hides the original body if compressed body is stored
+	BodyCompression string  `json:"body_compression,omitempty"`
+	CompressedBody  string  `json:"compressed_body,omitempty"`
+}
+
+// validateBodyCompression validates compression of stored bodies, empty compression stands for none
+func validateBodyCompression(compression string) error {
+	if compression != "" && compression != BodyCompressionNone && compression != BodyCompressionGzip {
+		return fmt.Errorf("unknown body compression: %s, expected %s or %s", compression, BodyCompressionNone,
+			BodyCompressionGzip)
+	}
+	return nil
+}
+
+// encodeStoredRequest encodes request for persistent database, the body is compressed if it is large enough and
+// the compression makes it smaller
+func encodeStoredRequest(data *RequestData) ([]byte, error) {
+	if bodyCompression != BodyCompressionGzip || len(data.Body) < minCompressedBodySize {
+		return json.Marshal(data)
+	}
+
+	compressed := new(bytes.Buffer)
+	zw := gzip.NewWriter(compressed)
+	if _, err := zw.Write([]byte(data.Body)); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(compressed.Bytes())
+	if len(encoded) >= len(data.Body) {
+		return json.Marshal(data)
+	}
+	return json.Marshal(storedRequest{RequestData: data, BodyCompression: BodyCompressionGzip, CompressedBody: encoded})
+}
+
+// decodeStoredRequest decodes request stored by persistent database, compressed body is decompressed
+func decodeStoredRequest(val []byte, data *RequestData) error {
+	stored := struct {
+		*RequestData
+		BodyCompression string `json:"body_compression"`
+		CompressedBody  string `json:"compressed_body"`
+	}{RequestData: data}
+	if err := json.Unmarshal(val, &stored); err != nil {
+		return err
+	}
+
+	switch stored.BodyCompression {
+	case "":
+		return nil
+	case BodyCompressionGzip:
+		compressed, err := base64.StdEncoding.DecodeString(stored.CompressedBody)
+		if err != nil {
+			return fmt.Errorf("invalid compressed body: %s", err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return fmt.Errorf("invalid compressed body: %s", err)
+		}
+		body, err := ioutil.ReadAll(zr)
+		if err != nil {
+			return fmt.Errorf("invalid compressed body: %s", err)
+		}
+		data.Body = string(body)
+		return nil
+	default:
+		return fmt.Errorf("unknown compression of stored body: %s", stored.BodyCompression)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeStoredRequest(t *testing.T) {
+	defer func(current string) { bodyCompression = current }(bodyCompression)
+	bodyCompression = BodyCompressionGzip
+
+	body := strings.Repeat("<item><name>test</name></item>", 100)
+	data := &RequestData{ID: 7, Date: 1000, Method: "POST", Body: body}
+	encoded, err := encodeStoredRequest(data)
+	if assert.NoError(t, err) {
+		assert.True(t, len(encoded) < len(body)/2, "body is expected to be compressed")
+		assert.NotContains(t, string(encoded), "item", "original body is not expected to be stored")
+		assert.Equal(t, body, data.Body, "request is not expected to change")
+
+		decoded := new(RequestData)
+		if assert.NoError(t, decodeStoredRequest(encoded, decoded)) {
+			assert.Equal(t, data, decoded, "wrong decoded request")
+		}
+	}
+
+	// small and incompressible bodies are stored as is
+	for _, body := range []string{"small", strings.Repeat("x", minCompressedBodySize-1)} {
+		encoded, err = encodeStoredRequest(&RequestData{Body: body})
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(encoded), `"body":"`+body+`"`, "body is not expected to be compressed")
+		}
+	}
+
+	bodyCompression = BodyCompressionNone
+	encoded, _ = encodeStoredRequest(data)
+	assert.NotContains(t, string(encoded), "compressed_body", "body is not expected to be compressed")
+}
+
+func TestDecodeStoredRequest_Invalid(t *testing.T) {
+	data := new(RequestData)
+	assert.Error(t, decodeStoredRequest([]byte(`{"body_compression": "gzip", "compressed_body": "%%%"}`), data),
+		"invalid base64 is expected to fail")
+	assert.Error(t, decodeStoredRequest([]byte(`{"body_compression": "gzip", "compressed_body": "YWJj"}`), data),
+		"invalid gzip is expected to fail")
+	assert.Error(t, decodeStoredRequest([]byte(`{"body_compression": "zstd", "compressed_body": "YWJj"}`), data),
+		"unknown compression is expected to fail")
+}
+
+func TestValidateBodyCompression(t *testing.T) {
+	assert.NoError(t, validateBodyCompression(""), "empty compression is expected to stand for none")
+	assert.NoError(t, validateBodyCompression(BodyCompressionGzip))
+	assert.Error(t, validateBodyCompression("zstd"), "unknown compression is expected to be rejected")
+}
@@ -36,3 +36,9 @@ func TestNormalizePrefix(t *testing.T) {
 	assert.Equal(t, "/services/baskets", normalizePrefix("services/baskets"), "unexpected result of normalization")
 	assert.Equal(t, "/abc/def/ghi", normalizePrefix("/abc/def/ghi"), "unexpected result of normalization")
 }
+
+func TestToHTTPDate(t *testing.T) {
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", toHTTPDate("2027-06-30"), "unexpected HTTP date")
+	assert.Empty(t, toHTTPDate(""), "date is not expected")
+	assert.Empty(t, toHTTPDate("30/06/2027"), "invalid date is expected to be ignored")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Names of service counters
+const (
+	CounterRequests      = "requests"
+	CounterForwards      = "forwards"
+	CounterForwardErrors = "forward_errors"
+)
+
+// countersWindow defines number of minutes service counters are kept for, so counts of the last day are available
+const countersWindow = 24 * 60
+
+var serviceCounters = newServiceCountersRegistry()
+
+type minuteCount struct {
+	minute int64 // minutes since epoch
+	count  int
+}
+
+// serviceCountersRegistry counts events of the service per minute within the last countersWindow minutes along with
+// totals since service start; counters are kept in memory, so counts of the last day are partial after restart
+type serviceCountersRegistry struct {
+	sync.Mutex
+	totals  map[string]int64
+	minutes map[string]*[countersWindow]minuteCount
+}
+
+func newServiceCountersRegistry() *serviceCountersRegistry {
+	return &serviceCountersRegistry{totals: make(map[string]int64), minutes: make(map[string]*[countersWindow]minuteCount)}
+}
+
+// Count registers an event at given time
+func (c *serviceCountersRegistry) Count(name string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	ring, exists := c.minutes[name]
+	if !exists {
+		ring = new([countersWindow]minuteCount)
+		c.minutes[name] = ring
+	}
+
+	minute := now.Unix() / 60
+	bucket := &ring[minute%countersWindow]
+	if bucket.minute != minute {
+		bucket.minute, bucket.count = minute, 0
+	}
+	bucket.count++
+	c.totals[name]++
+}
+
+// Total returns number of events since service start
+func (c *serviceCountersRegistry) Total(name string) int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.totals[name]
+}
+
+// Last returns number of events within given period before the time, the period is limited by countersWindow
+func (c *serviceCountersRegistry) Last(name string, period time.Duration, now time.Time) int {
+	c.Lock()
+	defer c.Unlock()
+
+	ring, exists := c.minutes[name]
+	if !exists {
+		return 0
+	}
+
+	minute := now.Unix() / 60
+	from := minute - int64(period/time.Minute)
+	count := 0
+	for _, bucket := range ring {
+		if bucket.minute > from && bucket.minute <= minute {
+			count += bucket.count
+		}
+	}
+	return count
+}
+
+// CollectTo adds counts of recent requests and forwards to database statistics
+func (c *serviceCountersRegistry) CollectTo(stats *DatabaseStats, now time.Time) {
+	stats.RequestsLastHour = c.Last(CounterRequests, time.Hour, now)
+	stats.RequestsLastDay = c.Last(CounterRequests, 24*time.Hour, now)
+	stats.ForwardsCount = c.Total(CounterForwards)
+	stats.ForwardErrorsCount = c.Total(CounterForwardErrors)
+	stats.ForwardErrorsLastHour = c.Last(CounterForwardErrors, time.Hour, now)
+	stats.ForwardErrorsLastDay = c.Last(CounterForwardErrors, 24*time.Hour, now)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceCountersRegistry_Last(t *testing.T) {
+	c := newServiceCountersRegistry()
+	now := time.Now()
+	c.Count(CounterRequests, now.Add(-25*time.Hour))
+	c.Count(CounterRequests, now.Add(-2*time.Hour))
+	c.Count(CounterRequests, now.Add(-10*time.Minute))
+	c.Count(CounterRequests, now)
+	c.Count(CounterForwardErrors, now)
+
+	assert.Equal(t, 2, c.Last(CounterRequests, time.Hour, now), "wrong count of the last hour")
+	assert.Equal(t, 3, c.Last(CounterRequests, 24*time.Hour, now), "wrong count of the last day")
+	assert.Equal(t, int64(4), c.Total(CounterRequests), "wrong total count")
+	assert.Equal(t, 0, c.Last(CounterForwards, time.Hour, now), "no forwards are expected")
+
+	stats := DatabaseStats{}
+	c.CollectTo(&stats, now)
+	assert.Equal(t, 2, stats.RequestsLastHour, "wrong requests of the last hour")
+	assert.Equal(t, int64(1), stats.ForwardErrorsCount, "wrong forward errors")
+	assert.Equal(t, 1, stats.ForwardErrorsLastDay, "wrong forward errors of the last day")
+
+	// counts of the same minute a day later replace outdated counts
+	c.Count(CounterRequests, now.Add(24*time.Hour))
+	assert.Equal(t, 1, c.Last(CounterRequests, time.Hour, now.Add(24*time.Hour)), "outdated counts are not expected")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	schedule, err := parseCron("*/15 9-18 * * 1-5")
+	if assert.NoError(t, err) {
+		// Monday, 2024-01-15
+		assert.True(t, schedule.Matches(time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)))
+		assert.True(t, schedule.Matches(time.Date(2024, 1, 15, 18, 45, 0, 0, time.UTC)))
+		assert.False(t, schedule.Matches(time.Date(2024, 1, 15, 9, 10, 0, 0, time.UTC)))
+		assert.False(t, schedule.Matches(time.Date(2024, 1, 15, 19, 0, 0, 0, time.UTC)))
+		// Sunday
+		assert.False(t, schedule.Matches(time.Date(2024, 1, 14, 9, 0, 0, 0, time.UTC)))
+	}
+}
+
+func TestParseCron_Macros(t *testing.T) {
+	hourly, err := parseCron("@hourly")
+	if assert.NoError(t, err) {
+		assert.True(t, hourly.Matches(time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)))
+		assert.False(t, hourly.Matches(time.Date(2024, 3, 1, 7, 1, 0, 0, time.UTC)))
+	}
+
+	weekly, err := parseCron("@weekly")
+	if assert.NoError(t, err) {
+		// Sunday midnight
+		assert.True(t, weekly.Matches(time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)))
+		assert.False(t, weekly.Matches(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)))
+	}
+}
+
+func TestParseCron_DayFields(t *testing.T) {
+	// 1st day of month or any Friday
+	schedule, err := parseCron("0 12 1 * 5")
+	if assert.NoError(t, err) {
+		assert.True(t, schedule.Matches(time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)))
+		assert.True(t, schedule.Matches(time.Date(2024, 2, 9, 12, 0, 0, 0, time.UTC)))
+		assert.False(t, schedule.Matches(time.Date(2024, 2, 8, 12, 0, 0, 0, time.UTC)))
+	}
+
+	// odd days of month that are Mondays, step of day field does not restrict it
+	odd, err := parseCron("0 12 */2 * 1")
+	if assert.NoError(t, err) {
+		assert.True(t, odd.Matches(time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC)))
+		assert.False(t, odd.Matches(time.Date(2024, 2, 12, 12, 0, 0, 0, time.UTC)), "even Monday is not expected")
+		assert.False(t, odd.Matches(time.Date(2024, 2, 7, 12, 0, 0, 0, time.UTC)), "odd Wednesday is not expected")
+	}
+
+	// Sunday as 7
+	sunday, err := parseCron("30 6 * * 7")
+	if assert.NoError(t, err) {
+		assert.True(t, sunday.Matches(time.Date(2024, 1, 14, 6, 30, 0, 0, time.UTC)))
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, "cron expression '%s' should be invalid", expr)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// serviceStarted is the time the service is started, used to report uptime
+var serviceStarted = time.Now()
+
+// RuntimeStats describes runtime statistics of the service process.
+type RuntimeStats struct {
+	GoVersion     string  `json:"go_version"`
+	CPUs          int     `json:"cpus"`
+	Uptime        int64   `json:"uptime"` // seconds
+	Goroutines    int     `json:"goroutines"`
+	HeapAlloc     uint64  `json:"heap_alloc"` // bytes of allocated heap objects
+	HeapInuse     uint64  `json:"heap_inuse"`
+	HeapIdle      uint64  `json:"heap_idle"`
+	HeapReleased  uint64  `json:"heap_released"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	Sys           uint64  `json:"sys"` // bytes obtained from the OS
+	TotalAlloc    uint64  `json:"total_alloc"`
+	NextGC        uint64  `json:"next_gc"` // heap size of the next GC cycle
+	NumGC         uint32  `json:"num_gc"`
+	LastGC        int64   `json:"last_gc,omitempty"` // date of the last GC cycle in milliseconds
+	PauseTotal    int64   `json:"pause_total"`       // total GC pause in milliseconds
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+}
+
+// ProfileInfo describes a runtime profile available for download.
+type ProfileInfo struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// authorizeDebug authorizes requests to debug end-points, only the master token, admin role tokens and API keys
+// are accepted, since profiles expose internals of the service
+func authorizeDebug(w http.ResponseWriter, r *http.Request) bool {
+	if isAdminToken(r.Header.Get("Authorization")) {
+		return true
+	}
+	httpError(w, "", http.StatusUnauthorized)
+	return false
+}
+
+// collectRuntimeStats reads runtime statistics of the process, reading memory statistics briefly stops the world
+func collectRuntimeStats() *RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := &RuntimeStats{GoVersion: runtime.Version(), CPUs: runtime.NumCPU(),
+		Uptime: int64(time.Since(serviceStarted).Seconds()), Goroutines: runtime.NumGoroutine(),
+		HeapAlloc: mem.HeapAlloc, HeapInuse: mem.HeapInuse, HeapIdle: mem.HeapIdle, HeapReleased: mem.HeapReleased,
+		HeapObjects: mem.HeapObjects, Sys: mem.Sys, TotalAlloc: mem.TotalAlloc, NextGC: mem.NextGC, NumGC: mem.NumGC,
+		PauseTotal: int64(mem.PauseTotalNs) / toMs, GCCPUFraction: mem.GCCPUFraction}
+	if mem.LastGC > 0 {
+		stats.LastGC = int64(mem.LastGC) / toMs
+	}
+	return stats
+}
+
+// GetRuntimeStats handles HTTP request to get runtime statistics of the service: goroutines, heap and GC
+func GetRuntimeStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeDebug(w, r) {
+		json, err := json.Marshal(collectRuntimeStats())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetProfiles handles HTTP request to list runtime profiles, CPU profile and execution trace are available as well
+func GetProfiles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeDebug(w, r) {
+		profiles := make([]ProfileInfo, 0)
+		for _, profile := range rpprof.Profiles() {
+			profiles = append(profiles, ProfileInfo{Name: profile.Name(), Count: profile.Count()})
+		}
+		sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+		json, err := json.Marshal(profiles)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetProfile handles HTTP request to download runtime profile in the format of net/http/pprof, e.g.
+// "heap", "goroutine?debug=2", "profile?seconds=30" (CPU) or "trace?seconds=5"
+func GetProfile(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !authorizeDebug(w, r) {
+		return
+	}
+
+	switch name := ps.ByName("profile"); name {
+	case "profile":
+		pprof.Profile(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	default:
+		if rpprof.Lookup(name) == nil {
+			httpError(w, "unknown profile: "+name, http.StatusNotFound)
+			return
+		}
+		pprof.Handler(name).ServeHTTP(w, r)
+	}
+}
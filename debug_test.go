@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	call := func(path string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api"+path, nil)
+		if len(token) > 0 {
+			r.Header.Add("Authorization", token)
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, path := range []string{"/debug/runtime", "/debug/pprof", "/debug/pprof/heap"} {
+		assert.Equal(t, 401, call(path, "").Code, "debug end-point is expected to require token: %s", path)
+		assert.Equal(t, 401, call(path, "wrong").Code, "debug end-point is expected to require token: %s", path)
+	}
+
+	w := call("/debug/runtime", serverConfig.MasterToken)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		stats := RuntimeStats{}
+		json.Unmarshal(w.Body.Bytes(), &stats)
+		assert.True(t, stats.Goroutines > 0, "goroutines are expected")
+		assert.True(t, stats.HeapAlloc > 0, "heap allocations are expected")
+		assert.NotEmpty(t, stats.GoVersion, "version of Go is expected")
+	}
+
+	w = call("/debug/pprof", serverConfig.MasterToken)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		profiles := []ProfileInfo{}
+		json.Unmarshal(w.Body.Bytes(), &profiles)
+		names := make([]string, 0, len(profiles))
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
+		assert.Contains(t, names, "goroutine", "goroutine profile is expected")
+		assert.Contains(t, names, "heap", "heap profile is expected")
+	}
+
+	assert.Equal(t, 200, call("/debug/pprof/heap", serverConfig.MasterToken).Code, "wrong HTTP result code")
+	w = call("/debug/pprof/goroutine?debug=1", serverConfig.MasterToken)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.True(t, strings.HasPrefix(w.Body.String(), "goroutine profile:"), "text profile is expected")
+	}
+	assert.Equal(t, 404, call("/debug/pprof/unknown", serverConfig.MasterToken).Code, "wrong HTTP result code")
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package main
+
+import "errors"
+
+var errDiskSpaceUnknown = errors.New("free disk space is unknown on this platform")
+
+// freeDiskSpace is not supported on this platform, the volume is never reported as down
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errDiskSpaceUnknown
+}
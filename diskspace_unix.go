@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errDiskSpaceUnknown = errors.New("free disk space is unknown")
+
+// freeDiskSpace returns bytes available to unprivileged users on the volume of given directory
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -34,10 +34,34 @@ if [ -n "$MAXSIZE" ]; then
     args="$args -maxsize $MAXSIZE"
 fi
 
+if [ -n "$MAX_BODY" ]; then
+    args="$args -max-body $MAX_BODY"
+fi
+
+if [ -n "$BODY_POLICY" ]; then
+    args="$args -body-policy $BODY_POLICY"
+fi
+
+if [ -n "$BODY_COMPRESSION" ]; then
+    args="$args -body-compression $BODY_COMPRESSION"
+fi
+
+if [ -n "$MAX_STORAGE" ]; then
+    args="$args -max-storage $MAX_STORAGE"
+fi
+
+if [ -n "$STORAGE_POLICY" ]; then
+    args="$args -storage-policy $STORAGE_POLICY"
+fi
+
 if [ -n "$TOKEN" ]; then
     args="$args -token $TOKEN"
 fi
 
+if [ -n "$TOKEN_PEPPER" ]; then
+    args="$args -token-pepper $TOKEN_PEPPER"
+fi
+
 if [ -n "$BASKET" ]; then
     args="$args -basket $BASKET"
 fi
@@ -54,6 +78,344 @@ if [ -n "$THEME" ]; then
     args="$args -theme $THEME"
 fi
 
+if [ -n "$SUNSET" ]; then
+    args="$args -sunset $SUNSET"
+fi
+
+if [ -n "$RATELIMIT" ]; then
+    args="$args -ratelimit $RATELIMIT"
+fi
+
+if [ -n "$CREATE_LIMIT" ]; then
+    args="$args -create-limit $CREATE_LIMIT"
+fi
+
+if [ -n "$CREATE_POW" ]; then
+    args="$args -create-pow $CREATE_POW"
+fi
+
+if [ -n "$MQTT" ]; then
+    args="$args -mqtt $MQTT"
+fi
+
+if [ -n "$MQTT_TOPIC" ]; then
+    args="$args -mqtt-topic $MQTT_TOPIC"
+fi
+
+if [ -n "$PUSH_KEY" ]; then
+    args="$args -push-key $PUSH_KEY"
+fi
+
+if [ -n "$PUSH_SUBJECT" ]; then
+    args="$args -push-subject $PUSH_SUBJECT"
+fi
+
+if [ -n "$USERS" ]; then
+    args="$args -users $USERS"
+fi
+
+if [ -n "$USER_BASKETS" ]; then
+    args="$args -user-baskets $USER_BASKETS"
+fi
+
+if [ -n "$USER_CAPACITY" ]; then
+    args="$args -user-capacity $USER_CAPACITY"
+fi
+
+if [ -n "$USER_BYTES" ]; then
+    args="$args -user-bytes $USER_BYTES"
+fi
+
+if [ -n "$ROLES" ]; then
+    args="$args -roles $ROLES"
+fi
+
+if [ -n "$API_KEYS" ]; then
+    args="$args -api-keys $API_KEYS"
+fi
+
+if [ -n "$AUDIT" ]; then
+    args="$args -audit $AUDIT"
+fi
+
+if [ -n "$OIDC_ISSUER" ]; then
+    args="$args -oidc-issuer $OIDC_ISSUER"
+fi
+
+if [ -n "$OIDC_CLIENT_ID" ]; then
+    args="$args -oidc-client-id $OIDC_CLIENT_ID"
+fi
+
+if [ -n "$OIDC_CLIENT_SECRET" ]; then
+    args="$args -oidc-client-secret $OIDC_CLIENT_SECRET"
+fi
+
+if [ -n "$OIDC_REDIRECT" ]; then
+    args="$args -oidc-redirect $OIDC_REDIRECT"
+fi
+
+if [ -n "$OIDC_USER_CLAIM" ]; then
+    args="$args -oidc-user-claim $OIDC_USER_CLAIM"
+fi
+
+# space separated list of claim rules
+for rule in $OIDC_ADMIN; do
+    args="$args -oidc-admin $rule"
+done
+
+# space separated list of claim rules
+for rule in $OIDC_ALLOW; do
+    args="$args -oidc-allow $rule"
+done
+
+if [ -n "$LDAP" ]; then
+    args="$args -ldap $LDAP"
+fi
+
+if [ -n "$LDAP_BIND_DN" ]; then
+    args="$args -ldap-bind-dn $LDAP_BIND_DN"
+fi
+
+if [ -n "$LDAP_BIND_PASSWORD" ]; then
+    args="$args -ldap-bind-password $LDAP_BIND_PASSWORD"
+fi
+
+if [ -n "$LDAP_BASE_DN" ]; then
+    args="$args -ldap-base-dn $LDAP_BASE_DN"
+fi
+
+if [ -n "$LDAP_USER_FILTER" ]; then
+    args="$args -ldap-user-filter $LDAP_USER_FILTER"
+fi
+
+if [ -n "$LDAP_ADMIN_GROUP" ]; then
+    args="$args -ldap-admin-group $LDAP_ADMIN_GROUP"
+fi
+
+if [ -n "$LDAP_READER_GROUP" ]; then
+    args="$args -ldap-reader-group $LDAP_READER_GROUP"
+fi
+
+if [ -n "$JWT_ISSUER" ]; then
+    args="$args -jwt-issuer $JWT_ISSUER"
+fi
+
+if [ -n "$JWT_JWKS" ]; then
+    args="$args -jwt-jwks $JWT_JWKS"
+fi
+
+if [ -n "$JWT_AUDIENCE" ]; then
+    args="$args -jwt-audience $JWT_AUDIENCE"
+fi
+
+if [ -n "$JWT_BASKETS_CLAIM" ]; then
+    args="$args -jwt-baskets-claim $JWT_BASKETS_CLAIM"
+fi
+
+# space separated list of claim rules
+for rule in $JWT_ADMIN; do
+    args="$args -jwt-admin $rule"
+done
+
+# space separated list of networks
+for network in $PROXY_TRUSTED; do
+    args="$args -proxy-trusted $network"
+done
+
+if [ -n "$PROXY_USER_HEADER" ]; then
+    args="$args -proxy-user-header $PROXY_USER_HEADER"
+fi
+
+if [ -n "$PROXY_GROUPS_HEADER" ]; then
+    args="$args -proxy-groups-header $PROXY_GROUPS_HEADER"
+fi
+
+# space separated list of groups
+for group in $PROXY_ADMIN_GROUP; do
+    args="$args -proxy-admin-group $group"
+done
+
+# space separated list of networks
+for network in $ADMIN_ALLOW; do
+    args="$args -admin-allow $network"
+done
+
+for scheme in $FORWARD_SCHEMES; do
+    args="$args -forward-scheme $scheme"
+done
+
+for network in $FORWARD_DENY; do
+    args="$args -forward-deny $network"
+done
+
+for network in $FORWARD_ALLOW; do
+    args="$args -forward-allow $network"
+done
+
+for header in $FORWARD_STRIP; do
+    args="$args -forward-strip $header"
+done
+
+if [ -n "$FORWARD_IDLE_CONNS" ]; then
+    args="$args -forward-idle-conns $FORWARD_IDLE_CONNS"
+fi
+
+if [ -n "$FORWARD_IDLE_TIMEOUT" ]; then
+    args="$args -forward-idle-timeout $FORWARD_IDLE_TIMEOUT"
+fi
+
+if [ -n "$FORWARD_TLS_TIMEOUT" ]; then
+    args="$args -forward-tls-timeout $FORWARD_TLS_TIMEOUT"
+fi
+
+if [ -n "$FORWARD_KEEP_ALIVE" ]; then
+    args="$args -forward-keep-alive $FORWARD_KEEP_ALIVE"
+fi
+
+if [ -n "$BASKET_DOMAIN" ]; then
+    args="$args -basket-domain $BASKET_DOMAIN"
+fi
+
+if [ -n "$MAX_CAPTURES" ]; then
+    args="$args -max-captures $MAX_CAPTURES"
+fi
+
+if [ -n "$BASKET_CAPTURES" ]; then
+    args="$args -basket-captures $BASKET_CAPTURES"
+fi
+
+if [ -n "$NAME_MIN_LENGTH" ]; then
+    args="$args -name-min-length $NAME_MIN_LENGTH"
+fi
+
+for pattern in $NAME_RESERVED; do
+    args="$args -name-reserved $pattern"
+done
+
+if [ -n "$NAME_BLOCKLIST" ]; then
+    args="$args -name-blocklist $NAME_BLOCKLIST"
+fi
+
+if [ -n "$RETENTION" ]; then
+    args="$args -retention $RETENTION"
+fi
+
+if [ -n "$ARCHIVE" ]; then
+    args="$args -archive $ARCHIVE"
+fi
+
+if [ -n "$CLEANUP_POLICIES" ]; then
+    args="$args -cleanup-policies $CLEANUP_POLICIES"
+fi
+
+if [ -n "$NAMESPACE_POLICIES" ]; then
+    args="$args -namespace-policies $NAMESPACE_POLICIES"
+fi
+
+if [ -n "$TRASH_PERIOD" ]; then
+    args="$args -trash-period $TRASH_PERIOD"
+fi
+
+if [ -n "$REAP_AFTER" ]; then
+    args="$args -reap-after $REAP_AFTER"
+fi
+
+if [ -n "$REAP_NOTICE" ]; then
+    args="$args -reap-notice $REAP_NOTICE"
+fi
+
+if [ -n "$OTLP_ENDPOINT" ]; then
+    args="$args -otlp-endpoint $OTLP_ENDPOINT"
+fi
+
+if [ -n "$STATSD" ]; then
+    args="$args -statsd $STATSD"
+fi
+
+if [ -n "$STATSD_PREFIX" ]; then
+    args="$args -statsd-prefix $STATSD_PREFIX"
+fi
+
+if [ -n "$STATSD_TAGS" ]; then
+    args="$args -statsd-tags $STATSD_TAGS"
+fi
+
+if [ -n "$STATSD_INTERVAL" ]; then
+    args="$args -statsd-interval $STATSD_INTERVAL"
+fi
+
+if [ -n "$ALERT_RULES" ]; then
+    args="$args -alert-rules $ALERT_RULES"
+fi
+
+if [ -n "$SMTP" ]; then
+    args="$args -smtp $SMTP"
+fi
+
+if [ -n "$SMTP_FROM" ]; then
+    args="$args -smtp-from $SMTP_FROM"
+fi
+
+if [ -n "$SLOW_FORWARD" ]; then
+    args="$args -slow-forward $SLOW_FORWARD"
+fi
+
+if [ -n "$SLOW_SCRIPT" ]; then
+    args="$args -slow-script $SLOW_SCRIPT"
+fi
+
+if [ -n "$SLOW_STORAGE" ]; then
+    args="$args -slow-storage $SLOW_STORAGE"
+fi
+
+if [ -n "$TLS_CERT" ]; then
+    args="$args -tls-cert $TLS_CERT"
+fi
+
+if [ -n "$TLS_KEY" ]; then
+    args="$args -tls-key $TLS_KEY"
+fi
+
+if [ -n "$CLIENT_CA" ]; then
+    args="$args -client-ca $CLIENT_CA"
+fi
+
+for rule in $CLIENT_ROLE; do
+    args="$args -client-role $rule"
+done
+
+if [ -n "$TLS_MIN_VERSION" ]; then
+    args="$args -tls-min-version $TLS_MIN_VERSION"
+fi
+
+for cipher in $TLS_CIPHER; do
+    args="$args -tls-cipher $cipher"
+done
+
+if [ -n "$HTTP2" ]; then
+    args="$args -http2=$HTTP2"
+fi
+
+for domain in $ACME_DOMAIN; do
+    args="$args -acme-domain $domain"
+done
+
+if [ -n "$ACME_EMAIL" ]; then
+    args="$args -acme-email $ACME_EMAIL"
+fi
+
+if [ -n "$ACME_CACHE" ]; then
+    args="$args -acme-cache $ACME_CACHE"
+fi
+
+if [ -n "$ACME_DIRECTORY" ]; then
+    args="$args -acme-directory $ACME_DIRECTORY"
+fi
+
+if [ -n "$ACME_HTTP" ]; then
+    args="$args -acme-http $ACME_HTTP"
+fi
+
 cmd="/bin/rbaskets $args"
 echo "Executing: $cmd"
 exec $cmd
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// BodyEncryptionAlgorithm is hybrid encryption of bodies: body is encrypted with random AES-256-GCM key,
+// the key is encrypted with RSA-OAEP (SHA-256) public key of basket
+const BodyEncryptionAlgorithm = "RSA-OAEP-256+A256GCM"
+
+const (
+	minEncryptionKeyBits   = 2048
+	maxEncryptionKeyBits   = 8192
+	maxEncryptionKeyLength = 2000 // PEM encoded public key
+)
+
+// EncryptionKey describes public key of basket, bodies of requests collected by basket are encrypted with
+// the key before storage, so only the holder of private key can decrypt them
+type EncryptionKey struct {
+	PublicKey string `json:"public_key"`       // PEM encoded RSA public key
+	KeyID     string `json:"key_id,omitempty"` // SHA-256 fingerprint of the key, assigned by service
+}
+
+// BodyEncryption describes encryption of body of collected request, the body itself holds base64 encoded
+// ciphertext with authentication tag
+type BodyEncryption struct {
+	Algorithm    string `json:"alg"`
+	KeyID        string `json:"key_id"`
+	EncryptedKey string `json:"encrypted_key"` // base64 encoded AES key encrypted with public key of basket
+	Nonce        string `json:"nonce"`         // base64 encoded nonce of AES-GCM
+}
+
+// parseEncryptionKey parses PEM encoded RSA public key in PKIX or PKCS #1 format
+func parseEncryptionKey(publicKey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, fmt.Errorf("public key is expected in PEM format")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block of public key: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("RSA public key is expected")
+	}
+	return rsaKey, nil
+}
+
+// encryptionKeyID returns SHA-256 fingerprint of DER encoded public key
+func encryptionKeyID(key *rsa.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// validateEncryptionKey validates public key of basket and assigns ID of the key
+func validateEncryptionKey(encryption *EncryptionKey) error {
+	if len(encryption.PublicKey) > maxEncryptionKeyLength {
+		return fmt.Errorf("public key may not be longer than %d characters", maxEncryptionKeyLength)
+	}
+	key, err := parseEncryptionKey(encryption.PublicKey)
+	if err != nil {
+		return err
+	}
+	if bits := key.N.BitLen(); bits < minEncryptionKeyBits || bits > maxEncryptionKeyBits {
+		return fmt.Errorf("size of public key should be between %d and %d bits, but was %d", minEncryptionKeyBits,
+			maxEncryptionKeyBits, bits)
+	}
+	encryption.KeyID = encryptionKeyID(key)
+	return nil
+}
+
+// encryptBody encrypts body of collected request with public key of basket, the request is never modified,
+// instead a copy with encrypted body is returned; requests without body or with encrypted body, e.g. restored
+// from snapshot, are returned as is
+func encryptBody(data *RequestData, encryption *EncryptionKey) (*RequestData, error) {
+	if encryption == nil || len(data.Body) == 0 || data.BodyEncryption != nil {
+		return data, nil
+	}
+
+	key, err := parseEncryptionKey(encryption.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %s", err)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %s", err)
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, secret, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt body key: %s", err)
+	}
+
+	encrypted := *data
+	encrypted.Body = base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, []byte(data.Body), nil))
+	encrypted.BodyEncryption = &BodyEncryption{
+		Algorithm:    BodyEncryptionAlgorithm,
+		KeyID:        encryptionKeyID(key),
+		EncryptedKey: base64.StdEncoding.EncodeToString(encryptedKey),
+		Nonce:        base64.StdEncoding.EncodeToString(nonce)}
+	return &encrypted, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publicKeyPEM(key interface{}) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// decryptBody decrypts body of collected request the way a holder of private key does
+func decryptBody(key *rsa.PrivateKey, data *RequestData) (string, error) {
+	encryptedKey, _ := base64.StdEncoding.DecodeString(data.BodyEncryption.EncryptedKey)
+	nonce, _ := base64.StdEncoding.DecodeString(data.BodyEncryption.Nonce)
+	ciphertext, _ := base64.StdEncoding.DecodeString(data.Body)
+
+	secret, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, encryptedKey, nil)
+	if err != nil {
+		return "", err
+	}
+	block, _ := aes.NewCipher(secret)
+	gcm, _ := cipher.NewGCM(block)
+	body, err := gcm.Open(nil, nonce, ciphertext, nil)
+	return string(body), err
+}
+
+func TestValidateEncryptionKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	encryption := &EncryptionKey{PublicKey: publicKeyPEM(&key.PublicKey), KeyID: "fake"}
+	if assert.NoError(t, validateEncryptionKey(encryption)) {
+		assert.Equal(t, encryptionKeyID(&key.PublicKey), encryption.KeyID, "key ID is expected to be assigned")
+		assert.Len(t, encryption.KeyID, 64, "wrong key ID")
+	}
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}))
+	assert.NoError(t, validateEncryptionKey(&EncryptionKey{PublicKey: pkcs1}), "PKCS #1 key is expected")
+
+	assert.Error(t, validateEncryptionKey(&EncryptionKey{PublicKey: "key"}), "PEM is expected")
+	assert.Error(t, validateEncryptionKey(&EncryptionKey{PublicKey: strings.Repeat("x", maxEncryptionKeyLength+1)}),
+		"long key is not expected")
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Error(t, validateEncryptionKey(&EncryptionKey{PublicKey: publicKeyPEM(&ecKey.PublicKey)}),
+		"RSA key is expected")
+	smallKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Error(t, validateEncryptionKey(&EncryptionKey{PublicKey: publicKeyPEM(&smallKey.PublicKey)}),
+		"small key is not expected")
+}
+
+func TestEncryptBody(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	encryption := &EncryptionKey{PublicKey: publicKeyPEM(&key.PublicKey)}
+
+	data := &RequestData{Method: "POST", Body: `{"card": "4111"}`}
+	encrypted, err := encryptBody(data, encryption)
+	if assert.NoError(t, err) && assert.NotNil(t, encrypted.BodyEncryption, "encryption is expected") {
+		assert.Equal(t, BodyEncryptionAlgorithm, encrypted.BodyEncryption.Algorithm, "wrong algorithm")
+		assert.Equal(t, encryptionKeyID(&key.PublicKey), encrypted.BodyEncryption.KeyID, "wrong key ID")
+		assert.NotContains(t, encrypted.Body, "4111", "plain body is not expected")
+		body, err := decryptBody(key, encrypted)
+		if assert.NoError(t, err) {
+			assert.Equal(t, data.Body, body, "wrong decrypted body")
+		}
+	}
+	assert.Equal(t, `{"card": "4111"}`, data.Body, "original request is not expected to be modified")
+
+	empty := &RequestData{Method: "GET"}
+	encrypted, err = encryptBody(empty, encryption)
+	assert.NoError(t, err)
+	assert.Equal(t, empty, encrypted, "request without body is not expected to be encrypted")
+}
+
+func TestAcceptBasketRequests_Encryption(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "encryption01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "encryption": {"public_key": "key"}}`).Code, "wrong HTTP result code")
+	publicKey, _ := json.Marshal(publicKeyPEM(&key.PublicKey))
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "encryption": {"public_key": `+string(publicKey)+`}}`).Code, "wrong HTTP result code")
+	assert.Equal(t, encryptionKeyID(&key.PublicKey), basketsDb.Get(basket).Config().Encryption.KeyID,
+		"key ID is expected to be assigned")
+
+	assert.Equal(t, 200, call("POST", "/"+basket, "", "secret payload").Code, "wrong HTTP result code")
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.NotNil(t, page.Requests[0].BodyEncryption, "body is expected to be encrypted")
+		body, err := decryptBody(key, page.Requests[0])
+		if assert.NoError(t, err) {
+			assert.Equal(t, "secret payload", body, "wrong decrypted body")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_EncryptionScript(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	basket := "encryption02"
+	_, err = basketsDb.Create(basket, BasketConfig{Capacity: 20,
+		Encryption: &EncryptionKey{PublicKey: publicKeyPEM(&key.PublicKey), KeyID: encryptionKeyID(&key.PublicKey)}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	b := basketsDb.Get(basket)
+	b.SetResponse("POST", ResponseConfig{IsScript: true, Body: "print(request['Body'])"})
+	b.SetTrigger(TriggerConfig{Script: "print(request['Body'] == 'secret payload')"})
+
+	// scripts get the plain body of request as received
+	r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("secret payload"))
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, 202, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "secret payload\n", w.Body.String(), "plain body is expected in response script")
+
+	page := b.GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		id := page.Requests[0].ID
+		assert.NotNil(t, page.Requests[0].BodyEncryption, "stored body is expected to be encrypted")
+		assert.Eventually(t, func() bool { return b.GetRequest(id).ScriptLog == "True\n" }, 5*time.Second,
+			10*time.Millisecond, "plain body is expected in trigger script")
+	}
+}
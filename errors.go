@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RequestIDHeader identifies HTTP request to service API, client may provide its own ID
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// Error codes of service API, errors without specific code are reported with code derived from HTTP status
+const (
+	ErrorBadRequest        = "bad_request"
+	ErrorUnauthorized      = "unauthorized"
+	ErrorForbidden         = "forbidden"
+	ErrorNotFound          = "not_found"
+	ErrorConflict          = "conflict"
+	ErrorValidationFailed  = "validation_failed"
+	ErrorRateLimited       = "rate_limited"
+	ErrorInternal          = "internal_error"
+	ErrorInvalidBasketName = "invalid_basket_name"
+	ErrorBasketNotFound    = "basket_not_found"
+	ErrorBasketExists      = "basket_exists"
+	ErrorUserNotFound      = "user_not_found"
+	ErrorUserExists        = "user_exists"
+	ErrorQuotaExceeded     = "quota_exceeded"
+	ErrorChallengeRequired = "challenge_required"
+)
+
+// ErrorResponse describes error returned by service API.
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// errorCode returns error code that corresponds to HTTP status
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorBadRequest
+	case http.StatusUnauthorized:
+		return ErrorUnauthorized
+	case http.StatusForbidden:
+		return ErrorForbidden
+	case http.StatusNotFound:
+		return ErrorNotFound
+	case http.StatusConflict:
+		return ErrorConflict
+	case http.StatusUnprocessableEntity:
+		return ErrorValidationFailed
+	case http.StatusTooManyRequests:
+		return ErrorRateLimited
+	case http.StatusInternalServerError:
+		return ErrorInternal
+	default:
+		return strings.ToLower(strings.Replace(http.StatusText(status), " ", "_", -1))
+	}
+}
+
+// httpError replies to API request with JSON error, the same way as http.Error replies with plain text
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, errorCode(status), message, nil)
+}
+
+// writeError replies to API request with JSON error of given code, the message defaults to HTTP status text
+func writeError(w http.ResponseWriter, status int, code string, message string, details interface{}) {
+	if len(message) == 0 {
+		message = http.StatusText(status)
+	}
+
+	requestID := w.Header().Get(RequestIDHeader)
+	if len(requestID) == 0 {
+		requestID, _ = GenerateToken()
+		w.Header().Set(RequestIDHeader, requestID)
+	}
+
+	body, _ := json.Marshal(ErrorResponse{Code: code, Message: message, Details: details, RequestID: requestID})
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// validRequestID checks if request ID provided by client may be echoed back
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c > unicode.MaxASCII || !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID assigns ID to API request and reports it with response header, client provided ID is kept
+func withRequestID(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id, _ = GenerateToken()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		handler(w, r, ps)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+// errorMessage returns message of JSON error written by API handler
+func errorMessage(t *testing.T, w *httptest.ResponseRecorder) string {
+	failure := new(ErrorResponse)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), failure), "JSON error is expected") {
+		return failure.Message
+	}
+	return ""
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, ErrorBadRequest, errorCode(http.StatusBadRequest))
+	assert.Equal(t, ErrorValidationFailed, errorCode(http.StatusUnprocessableEntity))
+	assert.Equal(t, ErrorRateLimited, errorCode(http.StatusTooManyRequests))
+	assert.Equal(t, "request_entity_too_large", errorCode(http.StatusRequestEntityTooLarge))
+}
+
+func TestHTTPError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req-1")
+	httpError(w, "capacity should be a positive number, but was 0", http.StatusUnprocessableEntity)
+
+	assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+	failure := new(ErrorResponse)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), failure)) {
+		assert.Equal(t, ErrorValidationFailed, failure.Code, "wrong error code")
+		assert.Equal(t, "capacity should be a positive number, but was 0", failure.Message, "wrong error message")
+		assert.Equal(t, "req-1", failure.RequestID, "wrong request ID")
+		assert.Nil(t, failure.Details, "details are not expected")
+	}
+
+	// request ID is generated if request is not marked
+	w = httptest.NewRecorder()
+	writeError(w, http.StatusNotFound, ErrorBasketNotFound, "", map[string]string{"basket": "abc"})
+	failure = new(ErrorResponse)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), failure)) {
+		assert.Equal(t, ErrorBasketNotFound, failure.Code, "wrong error code")
+		assert.Equal(t, "Not Found", failure.Message, "status text is expected by default")
+		assert.NotEmpty(t, failure.RequestID, "request ID is expected")
+		assert.Equal(t, failure.RequestID, w.Header().Get(RequestIDHeader), "request ID header is expected")
+		assert.Equal(t, map[string]interface{}{"basket": "abc"}, failure.Details, "wrong details")
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	handler := withRequestID(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		httpError(w, "", http.StatusUnauthorized)
+	})
+
+	r := httptest.NewRequest("GET", "http://localhost:55555/api/baskets", nil)
+	r.Header.Set(RequestIDHeader, "client-id-42")
+	w := httptest.NewRecorder()
+	handler(w, r, nil)
+	assert.Equal(t, "client-id-42", w.Header().Get(RequestIDHeader), "client request ID is expected to be kept")
+	assert.Contains(t, w.Body.String(), "\"request_id\":\"client-id-42\"", "request ID is expected in error")
+	assert.Contains(t, w.Body.String(), "\"code\":\"unauthorized\"", "error code is expected")
+
+	// invalid IDs are replaced
+	r.Header.Set(RequestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+	w = httptest.NewRecorder()
+	handler(w, r, nil)
+	assert.Len(t, w.Header().Get(RequestIDHeader), 44, "generated request ID is expected")
+
+	r.Header.Set(RequestIDHeader, "bad\x01id")
+	w = httptest.NewRecorder()
+	handler(w, r, nil)
+	assert.NotEqual(t, "bad\x01id", w.Header().Get(RequestIDHeader), "invalid request ID is not expected")
+}
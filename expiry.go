@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var expiry *basketExpiry
+
+// basketExpiry deletes baskets that are idle for longer than their expiration period, a basket is idle
+// since the latest collected request or change; baskets that were never changed are idle since they are
+// first checked by the running service
+type basketExpiry struct {
+	sync.Mutex
+	db     BasketsDatabase
+	remove func(name string)
+	seen   map[string]int64 // first check of unchanged baskets in milliseconds
+}
+
+func newBasketExpiry(db BasketsDatabase, remove func(name string)) *basketExpiry {
+	return &basketExpiry{db: db, remove: remove, seen: make(map[string]int64)}
+}
+
+// Start launches background routine that deletes expired baskets every retentionInterval
+func (be *basketExpiry) Start() {
+	go func() {
+		for {
+			time.Sleep(retentionInterval)
+			be.Expire(time.Now())
+		}
+	}()
+}
+
+// Expire deletes baskets that are expired at given time, returns names of deleted baskets; only global
+// webhook subscribers are notified, subscriptions of basket are deleted along with the basket
+func (be *basketExpiry) Expire(now time.Time) []string {
+	be.Lock()
+	defer be.Unlock()
+
+	nowMs := now.UnixNano() / toMs
+	seen := make(map[string]int64, len(be.seen))
+	expired := make([]string, 0)
+	forEachBasket(be.db, func(name string, basket Basket) {
+		ttl := basket.Config().ExpiresAfter
+		if policy := namespaces.Match(name); policy != nil {
+			ttl = shortestPeriod(ttl, policy.ExpiresAfter)
+		}
+		if ttl <= 0 {
+			return
+		}
+
+		idleSince := basket.LastModified()
+		if idleSince == 0 {
+			if idleSince = be.seen[name]; idleSince == 0 {
+				idleSince = nowMs
+			}
+			seen[name] = idleSince
+		}
+		if nowMs-idleSince >= int64(ttl)*1000 {
+			expired = append(expired, name)
+		}
+	})
+	be.seen = seen
+
+	// baskets are deleted after iteration, so pages of basket names are not shifted
+	for _, name := range expired {
+		log.Printf("[info] basket: %s is expired", name)
+		delete(be.seen, name)
+		webhooks.Publish(nil, WebhookEvent{Event: EventBasketExpired, Basket: name})
+		be.remove(name)
+	}
+	return expired
+}
+
+// validateExpiresAfter validates expiration period of basket in seconds
+func validateExpiresAfter(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("expiration period should be a positive number of seconds, but was %d", seconds)
+	}
+	return nil
+}
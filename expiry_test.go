@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasketExpiry_Expire(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("expiry01", BasketConfig{Capacity: 20, ExpiresAfter: 60})
+	db.Create("expiry02", BasketConfig{Capacity: 20, ExpiresAfter: 3600})
+	db.Create("expiry03", BasketConfig{Capacity: 20})
+	for _, name := range []string{"expiry01", "expiry02", "expiry03"} {
+		db.Get(name).AddRequest(&RequestData{Date: time.Now().UnixNano() / toMs, Method: "GET", Path: "/"})
+	}
+
+	be := newBasketExpiry(db, db.Delete)
+	assert.Empty(t, be.Expire(time.Now()), "active baskets are not expected to expire")
+
+	expired := be.Expire(time.Now().Add(10 * time.Minute))
+	assert.Equal(t, []string{"expiry01"}, expired, "wrong expired baskets")
+	assert.Nil(t, db.Get("expiry01"), "expired basket is expected to be deleted")
+	assert.NotNil(t, db.Get("expiry02"), "basket is not expected to expire yet")
+
+	assert.Equal(t, []string{"expiry02"}, be.Expire(time.Now().Add(2*time.Hour)), "wrong expired baskets")
+	assert.NotNil(t, db.Get("expiry03"), "basket without expiration period is not expected to expire")
+}
+
+func TestBasketExpiry_Unchanged(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	// basket without collected requests or changes
+	db.Create("expiry04", BasketConfig{Capacity: 20, ExpiresAfter: 60})
+
+	now := time.Now()
+	be := newBasketExpiry(db, db.Delete)
+	assert.Empty(t, be.Expire(now.Add(time.Hour)), "basket is expected to be idle since the first check")
+	assert.Empty(t, be.Expire(now.Add(time.Hour+30*time.Second)), "basket is not expected to expire yet")
+	assert.Equal(t, []string{"expiry04"}, be.Expire(now.Add(time.Hour+time.Minute)), "wrong expired baskets")
+	assert.Empty(t, be.seen, "expired basket is not expected to be tracked")
+}
+
+func TestValidateExpiresAfter(t *testing.T) {
+	assert.NoError(t, validateExpiresAfter(0))
+	assert.NoError(t, validateExpiresAfter(86400))
+	assert.Error(t, validateExpiresAfter(-1), "negative expiration period is not expected")
+}
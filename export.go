@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Supported export formats of collected requests
+const (
+	ExportFormatHAR     = "har"
+	ExportFormatPostman = "postman"
+	ExportFormatCurl    = "curl"
+	ExportFormatNDJSON  = "ndjson"
+)
+
+// exportPageSize defines number of requests fetched at once while streaming export
+const exportPageSize = 100
+
+// importMaxSize defines maximum size of imported archive in bytes
+const importMaxSize = 32 * 1024 * 1024
+
+// ExportOptions describes how collected requests are rendered by exporter.
+type ExportOptions struct {
+	Basket        string // name of exported basket
+	BaseURL       string // scheme and host that are prepended to request paths, e.g. http://localhost:55555
+	RelativePaths bool   // strip basket name from request paths, e.g. if requests are exported for another target
+}
+
+// requestsExporter renders collected requests in a specific format, requests are given from newest to oldest;
+// exporter either renders all requests at once or, if the format allows it, renders requests one by one
+// while they are streamed from basket
+type requestsExporter struct {
+	ContentType string
+	Extension   string
+	Export      func(w io.Writer, requests []*RequestData, options ExportOptions) error
+	Stream      func(w io.Writer, request *RequestData, options ExportOptions) error
+}
+
+var requestsExporters = map[string]*requestsExporter{
+	ExportFormatHAR:     {"application/json; charset=UTF-8", "har", exportHAR, nil},
+	ExportFormatPostman: {"application/json; charset=UTF-8", "postman_collection.json", exportPostman, nil},
+	ExportFormatCurl:    {"text/plain; charset=UTF-8", "sh", exportCurl, nil},
+	ExportFormatNDJSON:  {"application/x-ndjson", "ndjson", nil, streamNDJSON},
+}
+
+// getRequestsExporter returns exporter of requested format
+func getRequestsExporter(format string) (*requestsExporter, error) {
+	if exporter, exists := requestsExporters[format]; exists {
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
+
+// StreamRequests iterates requests of a basket from newest to oldest fetching them page by page, so the whole
+// basket is never loaded at once; only requests matching the query are iterated if query is not nil, custom sort
+// order of the query is ignored; the query itself is not modified
+func StreamRequests(basket Basket, query *RequestsQuery, pageSize int, fn func(page []*RequestData) error) error {
+	var paged RequestsQuery
+	if query != nil {
+		paged = *query
+	}
+
+	before := 0
+	for {
+		var requests []*RequestData
+		var hasMore bool
+		if query != nil {
+			paged.Before = before
+			page := basket.FindRequests(&paged, pageSize, 0)
+			requests, hasMore = page.Requests, page.HasMore
+		} else if before > 0 {
+			page := basket.GetRequestsBefore(before, pageSize)
+			requests, hasMore = page.Requests, page.HasMore
+		} else {
+			page := basket.GetRequests(pageSize, 0)
+			requests, hasMore = page.Requests, page.HasMore
+		}
+
+		if len(requests) > 0 {
+			if err := fn(requests); err != nil {
+				return err
+			}
+		}
+		if !hasMore || len(requests) == 0 {
+			return nil
+		}
+		before = requests[len(requests)-1].ID
+	}
+}
+
+// ValidateTarget checks that target URL is suitable as base URL of exported requests, i.e. it has scheme and host
+func ValidateTarget(target string) error {
+	u, err := url.ParseRequestURI(target)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("invalid target URL: %s", target)
+	}
+	return nil
+}
+
+// requestPath returns path of exported request
+func (options ExportOptions) requestPath(req *RequestData) string {
+	if options.RelativePaths {
+		return relativePath(req.Path)
+	}
+	return req.Path
+}
+
+// requestURL builds absolute URL of exported request
+func (options ExportOptions) requestURL(req *RequestData, baseURL string) string {
+	u := strings.TrimSuffix(baseURL, "/") + options.requestPath(req)
+	if len(req.Query) > 0 {
+		u += "?" + req.Query
+	}
+	return u
+}
+
+// sortedKeys returns names of headers or query parameters in alphabetical order, so exports are stable
+func sortedKeys(values map[string][]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/// HAR 1.2, see http://www.softwareishard.com/blog/har-12-spec/ ///
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harArchive struct {
+	Log harLog `json:"log"`
+}
+
+// exportHAR renders requests as HAR archive, entries are ordered from oldest to newest; responses are only
+// described by recorded status since response content is not stored, the time of an entry is the forward latency
+func exportHAR(w io.Writer, requests []*RequestData, options ExportOptions) error {
+	archive := harArchive{harLog{
+		Version: "1.2",
+		Creator: harCreator{serviceName, version.Version},
+		Entries: make([]*harEntry, 0, len(requests))}}
+
+	for i := len(requests) - 1; i >= 0; i-- {
+		archive.Log.Entries = append(archive.Log.Entries, toHAREntry(requests[i], options))
+	}
+
+	return json.NewEncoder(w).Encode(archive)
+}
+
+func toHAREntry(req *RequestData, options ExportOptions) *harEntry {
+	entry := &harEntry{
+		StartedDateTime: time.Unix(0, req.Date*toMs).UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            req.ForwardLatency,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         options.requestURL(req, options.BaseURL),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     make([]harNameValue, 0),
+			Headers:     make([]harNameValue, 0, len(req.Header)),
+			QueryString: make([]harNameValue, 0),
+			HeadersSize: -1,
+			BodySize:    int64(len(req.Body))},
+		Response: harResponse{
+			Status:      req.ResponseStatus,
+			StatusText:  http.StatusText(req.ResponseStatus),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     make([]harNameValue, 0),
+			Headers:     make([]harNameValue, 0),
+			HeadersSize: -1,
+			BodySize:    -1},
+		Timings: harTimings{Send: 0, Wait: req.ForwardLatency, Receive: 0},
+		Comment: fmt.Sprintf("request %d of basket %s", req.ID, options.Basket)}
+
+	for _, name := range sortedKeys(req.Header) {
+		for _, value := range req.Header[name] {
+			entry.Request.Headers = append(entry.Request.Headers, harNameValue{name, value})
+		}
+	}
+
+	if values, err := url.ParseQuery(req.Query); err == nil {
+		for _, name := range sortedKeys(values) {
+			for _, value := range values[name] {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{name, value})
+			}
+		}
+	}
+
+	if len(req.Body) > 0 {
+		entry.Request.PostData = &harPostData{req.Header.Get("Content-Type"), req.Body}
+	}
+
+	return entry
+}
+
+// ImportHAR reads HAR archive and adds its entries to basket as collected requests preserving the request dates,
+// entries are added from oldest to newest; returns number of imported requests, import stops at the first
+// request that cannot be stored, e.g. once storage quota is exhausted
+func ImportHAR(basket Basket, name string, r io.Reader) (int, error) {
+	archive := new(harArchive)
+	if err := json.NewDecoder(r).Decode(archive); err != nil {
+		return 0, fmt.Errorf("invalid HAR archive: %s", err)
+	}
+
+	requests := make([]*RequestData, 0, len(archive.Log.Entries))
+	for i, entry := range archive.Log.Entries {
+		req, err := fromHAREntry(entry, name)
+		if err != nil {
+			return 0, fmt.Errorf("invalid HAR entry %d: %s", i, err)
+		}
+		requests = append(requests, req)
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].Date < requests[j].Date })
+	config := basket.Config()
+	for i, req := range requests {
+		if _, err := importRequest(name, basket, config, req); err != nil {
+			return i, err
+		}
+	}
+
+	return len(requests), nil
+}
+
+// importRequest stores request recorded elsewhere the same way as collected request: sensitive data is redacted,
+// body is encrypted and the request is accounted in storage quotas, archived once evicted and linked into hash chain
+// according to the basket configuration
+func importRequest(name string, basket Basket, config BasketConfig, data *RequestData) (*RequestData, error) {
+	stored, err := encryptBody(redactRequest(data, config.Redaction), config.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt request body: %s", err)
+	}
+	size := requestSize(stored)
+	if err = reserveStorage(name, size); err != nil {
+		return nil, err
+	}
+	request := collectRequest(name, basket, config, stored)
+	storage.Add(name, size)
+	return request, nil
+}
+
+// fromHAREntry converts HAR entry into request collected by basket, request path is prefixed with basket name
+// unless it already belongs to the basket
+func fromHAREntry(entry *harEntry, name string) (*RequestData, error) {
+	date, err := time.Parse(time.RFC3339, entry.StartedDateTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time: %s", entry.StartedDateTime)
+	}
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %s", entry.Request.URL)
+	}
+	if len(entry.Request.Method) == 0 {
+		return nil, fmt.Errorf("missing method")
+	}
+
+	req := &RequestData{
+		Date:           date.UnixNano() / toMs,
+		Header:         make(http.Header),
+		Method:         strings.ToUpper(entry.Request.Method),
+		Path:           u.Path,
+		Query:          u.RawQuery,
+		ResponseStatus: entry.Response.Status}
+
+	basketPath := "/" + name
+	if req.Path != basketPath && !strings.HasPrefix(req.Path, basketPath+"/") {
+		req.Path = basketPath + "/" + strings.TrimPrefix(req.Path, "/")
+	}
+	for _, header := range entry.Request.Headers {
+		// HTTP/2 pseudo headers, e.g. ":authority", are not real headers
+		if !strings.HasPrefix(header.Name, ":") {
+			req.Header.Add(header.Name, header.Value)
+		}
+	}
+	if entry.Request.PostData != nil {
+		req.Body = entry.Request.PostData.Text
+		req.ContentLength = int64(len(req.Body))
+	}
+
+	return req, nil
+}
+
+/// Postman collection v2.1, see https://schema.getpostman.com/json/collection/v2.1.0/collection.json ///
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanBaseURL is a name of collection variable that holds scheme and host of requests
+const postmanBaseURL = "baseUrl"
+
+type postmanKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanURL struct {
+	Raw   string            `json:"raw"`
+	Host  []string          `json:"host"`
+	Path  []string          `json:"path"`
+	Query []postmanKeyValue `json:"query,omitempty"`
+}
+
+type postmanBody struct {
+	Mode string `json:"mode"`
+	Raw  string `json:"raw"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	Body   *postmanBody      `json:"body,omitempty"`
+	URL    postmanURL        `json:"url"`
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+}
+
+type postmanInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Schema      string `json:"schema"`
+}
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []*postmanItem    `json:"item"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+// exportPostman renders requests as Postman collection, items are ordered from oldest to newest;
+// scheme and host of requests are kept in a collection variable, so requests can be easily sent elsewhere
+func exportPostman(w io.Writer, requests []*RequestData, options ExportOptions) error {
+	collection := postmanCollection{
+		Info: postmanInfo{
+			Name:        options.Basket,
+			Description: fmt.Sprintf("Requests collected by basket %s of %s", options.Basket, serviceName),
+			Schema:      postmanSchema},
+		Item:     make([]*postmanItem, 0, len(requests)),
+		Variable: []postmanKeyValue{{postmanBaseURL, strings.TrimSuffix(options.BaseURL, "/")}}}
+
+	for i := len(requests) - 1; i >= 0; i-- {
+		collection.Item = append(collection.Item, toPostmanItem(requests[i], options))
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
+
+func toPostmanItem(req *RequestData, options ExportOptions) *postmanItem {
+	host := "{{" + postmanBaseURL + "}}"
+	item := &postmanItem{
+		Name: fmt.Sprintf("%s %s #%d", req.Method, req.Path, req.ID),
+		Request: postmanRequest{
+			Method: req.Method,
+			Header: make([]postmanKeyValue, 0, len(req.Header)),
+			URL: postmanURL{
+				Raw:  options.requestURL(req, host),
+				Host: []string{host},
+				Path: strings.Split(strings.TrimPrefix(options.requestPath(req), "/"), "/")}}}
+
+	for _, name := range sortedKeys(req.Header) {
+		for _, value := range req.Header[name] {
+			item.Request.Header = append(item.Request.Header, postmanKeyValue{name, value})
+		}
+	}
+
+	// keep original order of query parameters
+	if len(req.Query) > 0 {
+		for _, param := range strings.Split(req.Query, "&") {
+			key, value := param, ""
+			if i := strings.Index(param, "="); i >= 0 {
+				key, value = param[:i], param[i+1:]
+			}
+			item.Request.URL.Query = append(item.Request.URL.Query, postmanKeyValue{key, value})
+		}
+	}
+
+	if len(req.Body) > 0 {
+		item.Request.Body = &postmanBody{Mode: "raw", Raw: req.Body}
+	}
+
+	return item
+}
+
+/// curl commands ///
+
+// curlSkippedHeaders lists headers that are calculated by curl itself
+var curlSkippedHeaders = map[string]bool{"Content-Length": true}
+
+// exportCurl renders requests as shell script with curl command per request, commands are ordered from oldest
+// to newest
+func exportCurl(w io.Writer, requests []*RequestData, options ExportOptions) error {
+	if _, err := fmt.Fprintf(w, "#!/bin/sh\n# requests collected by basket %s of %s\n", options.Basket, serviceName); err != nil {
+		return err
+	}
+
+	for i := len(requests) - 1; i >= 0; i-- {
+		if _, err := io.WriteString(w, "\n"+toCurlCommand(requests[i], options)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func toCurlCommand(req *RequestData, options ExportOptions) string {
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "# request %d collected at %s\n", req.ID, time.Unix(0, req.Date*toMs).UTC().Format(time.RFC3339))
+	fmt.Fprintf(&cmd, "curl -X %s %s", shellQuote(req.Method), shellQuote(options.requestURL(req, options.BaseURL)))
+
+	for _, name := range sortedKeys(req.Header) {
+		if curlSkippedHeaders[name] {
+			continue
+		}
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(&cmd, " \\\n  -H %s", shellQuote(name+": "+value))
+		}
+	}
+
+	if len(req.Body) > 0 {
+		fmt.Fprintf(&cmd, " \\\n  --data-binary %s", shellQuote(req.Body))
+	}
+	cmd.WriteString("\n")
+
+	return cmd.String()
+}
+
+// shellQuote quotes value for POSIX shell, single quotes within value are escaped
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+/// newline delimited JSON ///
+
+// streamNDJSON renders request as a single line JSON object
+func streamNDJSON(w io.Writer, request *RequestData, options ExportOptions) error {
+	return json.NewEncoder(w).Encode(request)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createExportTestRequests() []*RequestData {
+	// requests are given from newest to oldest
+	return []*RequestData{
+		{ID: 2, Date: 1600000001000, Method: "GET", Path: "/export01/status", Query: "b=2&a=1",
+			Header: http.Header{"Accept": {"*/*"}}, ResponseStatus: 200},
+		{ID: 1, Date: 1600000000000, Method: "POST", Path: "/export01/hooks", Body: "{\"event\":\"created\"}",
+			Header: http.Header{"Content-Type": {"application/json"}, "X-Token": {"abc"}}, ContentLength: 19,
+			ForwardLatency: 15, ResponseStatus: 201}}
+}
+
+func TestGetRequestsExporter(t *testing.T) {
+	exporter, err := getRequestsExporter(ExportFormatHAR)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "har", exporter.Extension, "wrong file extension")
+	}
+
+	_, err = getRequestsExporter("xml")
+	if assert.Error(t, err) {
+		assert.Equal(t, "unknown export format: xml", err.Error(), "wrong error message")
+	}
+}
+
+func TestExportHAR(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := exportHAR(buf, createExportTestRequests(), ExportOptions{Basket: "export01", BaseURL: "http://localhost:55555"})
+	if assert.NoError(t, err) {
+		archive := new(harArchive)
+		if assert.NoError(t, json.Unmarshal(buf.Bytes(), archive)) {
+			assert.Equal(t, "1.2", archive.Log.Version, "wrong HAR version")
+			assert.Equal(t, serviceName, archive.Log.Creator.Name, "wrong HAR creator")
+			if assert.Len(t, archive.Log.Entries, 2, "wrong number of entries") {
+				// oldest request goes first
+				entry := archive.Log.Entries[0]
+				assert.Equal(t, "2020-09-13T12:26:40.000Z", entry.StartedDateTime, "wrong start time")
+				assert.Equal(t, int64(15), entry.Time, "wrong entry time")
+				assert.Equal(t, "POST", entry.Request.Method, "wrong request method")
+				assert.Equal(t, "http://localhost:55555/export01/hooks", entry.Request.URL, "wrong request URL")
+				assert.Equal(t, []harNameValue{{"Content-Type", "application/json"}, {"X-Token", "abc"}}, entry.Request.Headers)
+				if assert.NotNil(t, entry.Request.PostData, "post data is expected") {
+					assert.Equal(t, "application/json", entry.Request.PostData.MimeType, "wrong post data MIME type")
+					assert.Equal(t, "{\"event\":\"created\"}", entry.Request.PostData.Text, "wrong post data")
+				}
+				assert.Equal(t, 201, entry.Response.Status, "wrong response status")
+				assert.Equal(t, "Created", entry.Response.StatusText, "wrong response status text")
+
+				entry = archive.Log.Entries[1]
+				assert.Equal(t, "http://localhost:55555/export01/status?b=2&a=1", entry.Request.URL, "wrong request URL")
+				assert.Equal(t, []harNameValue{{"a", "1"}, {"b", "2"}}, entry.Request.QueryString)
+				assert.Nil(t, entry.Request.PostData, "post data is not expected")
+			}
+		}
+	}
+}
+
+func TestExportPostman(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := exportPostman(buf, createExportTestRequests(), ExportOptions{Basket: "export01", BaseURL: "http://localhost:55555/"})
+	if assert.NoError(t, err) {
+		collection := new(postmanCollection)
+		if assert.NoError(t, json.Unmarshal(buf.Bytes(), collection)) {
+			assert.Equal(t, "export01", collection.Info.Name, "wrong collection name")
+			assert.Equal(t, postmanSchema, collection.Info.Schema, "wrong collection schema")
+			assert.Equal(t, []postmanKeyValue{{"baseUrl", "http://localhost:55555"}}, collection.Variable, "wrong collection variables")
+
+			if assert.Len(t, collection.Item, 2, "wrong number of items") {
+				// oldest request goes first
+				item := collection.Item[0]
+				assert.Equal(t, "POST /export01/hooks #1", item.Name, "wrong item name")
+				assert.Equal(t, "POST", item.Request.Method, "wrong request method")
+				assert.Equal(t, "{{baseUrl}}/export01/hooks", item.Request.URL.Raw, "wrong request URL")
+				assert.Equal(t, []string{"export01", "hooks"}, item.Request.URL.Path, "wrong request path")
+				assert.Empty(t, item.Request.URL.Query, "query is not expected")
+				assert.Equal(t, []postmanKeyValue{{"Content-Type", "application/json"}, {"X-Token", "abc"}}, item.Request.Header)
+				if assert.NotNil(t, item.Request.Body, "body is expected") {
+					assert.Equal(t, "raw", item.Request.Body.Mode, "wrong body mode")
+					assert.Equal(t, "{\"event\":\"created\"}", item.Request.Body.Raw, "wrong body")
+				}
+
+				item = collection.Item[1]
+				assert.Equal(t, "{{baseUrl}}/export01/status?b=2&a=1", item.Request.URL.Raw, "wrong request URL")
+				assert.Equal(t, []postmanKeyValue{{"b", "2"}, {"a", "1"}}, item.Request.URL.Query, "wrong query parameters")
+				assert.Nil(t, item.Request.Body, "body is not expected")
+			}
+		}
+	}
+}
+
+func TestExportCurl(t *testing.T) {
+	requests := createExportTestRequests()
+	requests[1].Header.Set("Content-Length", "19")
+	requests[1].Header.Set("X-Quote", "it's")
+
+	buf := new(bytes.Buffer)
+	err := exportCurl(buf, requests, ExportOptions{Basket: "export01", BaseURL: "http://localhost:8080", RelativePaths: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "#!/bin/sh\n# requests collected by basket export01 of "+serviceName+"\n"+
+			"\n# request 1 collected at 2020-09-13T12:26:40Z\n"+
+			"curl -X 'POST' 'http://localhost:8080/hooks' \\\n"+
+			"  -H 'Content-Type: application/json' \\\n"+
+			"  -H 'X-Quote: it'\\''s' \\\n"+
+			"  -H 'X-Token: abc' \\\n"+
+			"  --data-binary '{\"event\":\"created\"}'\n"+
+			"\n# request 2 collected at 2020-09-13T12:26:41Z\n"+
+			"curl -X 'GET' 'http://localhost:8080/status?b=2&a=1' \\\n"+
+			"  -H 'Accept: */*'\n", buf.String())
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	assert.NoError(t, ValidateTarget("http://localhost:8080"))
+	assert.NoError(t, ValidateTarget("https://example.com/hooks"))
+	assert.Error(t, ValidateTarget("localhost:8080"))
+	assert.Error(t, ValidateTarget("/hooks"))
+}
+
+func TestExportOptions_RequestURL(t *testing.T) {
+	req := &RequestData{Path: "/export02/hooks/1", Query: "a=1"}
+	assert.Equal(t, "http://localhost:55555/export02/hooks/1?a=1", ExportOptions{}.requestURL(req, "http://localhost:55555/"))
+	assert.Equal(t, "http://localhost:8080/hooks/1?a=1", ExportOptions{RelativePaths: true}.requestURL(req, "http://localhost:8080"))
+
+	// request to the basket root
+	req = &RequestData{Path: "/export02"}
+	assert.Equal(t, "http://localhost:8080/", ExportOptions{RelativePaths: true}.requestURL(req, "http://localhost:8080"))
+}
+
+func TestStreamRequests(t *testing.T) {
+	name := "export03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i := 1; i <= 7; i++ {
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i%2), "text/plain"))
+	}
+
+	stream := func(query *RequestsQuery) ([]int, []int) {
+		ids, sizes := make([]int, 0), make([]int, 0)
+		err := StreamRequests(basket, query, 3, func(page []*RequestData) error {
+			sizes = append(sizes, len(page))
+			for _, req := range page {
+				ids = append(ids, req.ID)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		return ids, sizes
+	}
+
+	ids, sizes := stream(nil)
+	assert.Equal(t, []int{7, 6, 5, 4, 3, 2, 1}, ids, "wrong streamed requests")
+	assert.Equal(t, []int{3, 3, 1}, sizes, "wrong pages")
+
+	ids, _ = stream(NewTextQuery("req1", "body"))
+	assert.Equal(t, []int{7, 5, 3, 1}, ids, "wrong streamed requests")
+
+	// query of caller is left intact
+	query := NewTextQuery("req1", "body")
+	ids, _ = stream(query)
+	assert.Equal(t, []int{7, 5, 3, 1}, ids, "wrong streamed requests")
+	assert.Equal(t, 0, query.Before, "query is not expected to be modified")
+	ids, _ = stream(query)
+	assert.Equal(t, []int{7, 5, 3, 1}, ids, "query is expected to be reusable")
+
+	// iteration stops on error
+	calls := 0
+	err := StreamRequests(basket, nil, 3, func(page []*RequestData) error {
+		calls++
+		return fmt.Errorf("broken")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "iteration is expected to stop")
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	for _, req := range createExportTestRequests() {
+		assert.NoError(t, streamNDJSON(buf, req, ExportOptions{}))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 2, "wrong number of lines") {
+		req := new(RequestData)
+		if assert.NoError(t, json.Unmarshal([]byte(lines[1]), req)) {
+			assert.Equal(t, 1, req.ID, "wrong request ID")
+			assert.Equal(t, "{\"event\":\"created\"}", req.Body, "wrong request body")
+		}
+	}
+}
+
+func TestImportHAR(t *testing.T) {
+	name := "import01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.Add(createTestPOSTRequest("http://localhost/"+name, "existing", "text/plain"))
+
+	// export of another basket and entries recorded elsewhere
+	buf := new(bytes.Buffer)
+	assert.NoError(t, exportHAR(buf, createExportTestRequests(), ExportOptions{Basket: "export01", BaseURL: "http://localhost:55555"}))
+	imported, err := ImportHAR(basket, name, buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, imported, "wrong number of imported requests")
+	}
+
+	page := basket.GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 3, "wrong number of requests") {
+		// imported requests get new IDs, the newest is added last
+		req := page.Requests[0]
+		assert.Equal(t, 3, req.ID, "wrong request ID")
+		assert.Equal(t, int64(1600000001000), req.Date, "request date is expected to be preserved")
+		assert.Equal(t, "GET", req.Method, "wrong request method")
+		assert.Equal(t, "/import01/export01/status", req.Path, "request path is expected within basket")
+		assert.Equal(t, "b=2&a=1", req.Query, "wrong request query")
+		assert.Equal(t, "*/*", req.Header.Get("Accept"), "wrong request header")
+		assert.Equal(t, 200, req.ResponseStatus, "wrong response status")
+
+		req = page.Requests[1]
+		assert.Equal(t, 2, req.ID, "wrong request ID")
+		assert.Equal(t, "{\"event\":\"created\"}", req.Body, "wrong request body")
+		assert.Equal(t, int64(19), req.ContentLength, "wrong content length")
+	}
+
+	// paths within basket are kept
+	har := `{"log":{"version":"1.2","entries":[{"startedDateTime":"2020-09-13T12:26:40.123+02:00",` +
+		`"request":{"method":"put","url":"https://example.com/import01/hooks?x=1","headers":[{"name":":authority","value":"example.com"}]},` +
+		`"response":{"status":0}}]}}`
+	imported, err = ImportHAR(basket, name, strings.NewReader(har))
+	if assert.NoError(t, err) && assert.Equal(t, 1, imported, "wrong number of imported requests") {
+		req := basket.GetRequest(4)
+		assert.Equal(t, "PUT", req.Method, "wrong request method")
+		assert.Equal(t, "/import01/hooks", req.Path, "wrong request path")
+		assert.Equal(t, int64(1599992800123), req.Date, "wrong request date")
+		assert.Empty(t, req.Header, "pseudo headers are not expected")
+	}
+
+	// invalid archives
+	_, err = ImportHAR(basket, name, strings.NewReader("{"))
+	assert.Error(t, err)
+	_, err = ImportHAR(basket, name, strings.NewReader(`{"log":{"entries":[{"startedDateTime":"yesterday","request":{"method":"GET","url":"/"}}]}}`))
+	if assert.Error(t, err) {
+		assert.Equal(t, "invalid HAR entry 0: invalid start time: yesterday", err.Error(), "wrong error message")
+	}
+	assert.Equal(t, 4, basket.Size(), "invalid archive is not expected to be imported")
+}
+
+func TestImportHAR_Pipeline(t *testing.T) {
+	name := "import02"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, HashChain: true, Redaction: []RedactionRule{{Header: "X-Token"}}})
+	basket := db.Get(name)
+	basket.Add(createTestPOSTRequest("http://localhost/"+name, "existing", "text/plain"))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, exportHAR(buf, createExportTestRequests(), ExportOptions{Basket: "export01", BaseURL: "http://localhost:55555"}))
+	imported, err := ImportHAR(basket, name, buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, imported, "wrong number of imported requests")
+	}
+
+	// imported requests are redacted and linked into hash chain
+	req := basket.GetRequest(2)
+	if assert.NotNil(t, req, "imported request is expected") {
+		assert.Equal(t, redactedValue, req.Header.Get("X-Token"), "imported request is expected to be redacted")
+		assert.True(t, req.Redacted, "imported request is expected to be marked as redacted")
+		assert.NotEmpty(t, req.Hash, "imported request is expected to be linked into hash chain")
+	}
+	assert.True(t, verifyChain(basket).Verified, "hash chain is expected to be verified")
+
+	// imported requests are found by date although they are older than requests collected before
+	query := &RequestsQuery{From: 1600000000000, To: 1600000001000}
+	assert.Len(t, basket.FindRequests(query, 10, 0).Requests, 2, "imported requests are expected to be found by date")
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter expressions are a small query language to search collected requests, e.g.:
+//
+//	method:POST AND path:/hooks/* AND body~"order_id"
+//	(header:X-Event:created OR header:X-Event:updated) AND NOT query:test
+//	date>=2024-01-01T00:00:00Z size>1024 "payment failed"
+//
+// Every term has a form of <field><operator><value>, a term without field and operator searches everywhere.
+// Terms are combined with AND, OR and NOT (in order of precedence: NOT, AND, OR), terms separated by space
+// are combined with AND. Values containing spaces or parentheses must be quoted. Conditions of text fields follow
+// case-insensitive and whole-word options of the query, see RequestsQuery.SetOptions.
+//
+// Supported fields and operators:
+//
+//	any, body, query, headers  ':' contains, '~' regular expression
+//	method                     ':' equals (case-insensitive), '~' regular expression
+//	path                       ':' glob pattern, see RequestsQuery.SetPath, '~' regular expression
+//	header                     ':' Name:value (value is contained) or Name (present), '~' Name:regular expression
+//	json                       ':' JSONPath expression applied to body, e.g. json:"$.event.type == 'created'"
+//	date                       '=', '>', '>=', '<', '<=' with milliseconds since epoch or RFC 3339 date
+//	size                       '=', '>', '>=', '<', '<=' with content length in bytes
+
+// filterExpr is a node of parsed filter expression
+type filterExpr interface {
+	Match(req *RequestData) bool
+}
+
+type andExpr []filterExpr
+type orExpr []filterExpr
+type notExpr struct{ expr filterExpr }
+
+// termExpr is a single filter condition
+type termExpr struct {
+	field string
+	op    string
+	value string
+	match func(req *RequestData) bool
+}
+
+func (e andExpr) Match(req *RequestData) bool {
+	for _, item := range e {
+		if !item.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e orExpr) Match(req *RequestData) bool {
+	for _, item := range e {
+		if item.Match(req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e notExpr) Match(req *RequestData) bool {
+	return !e.expr.Match(req)
+}
+
+func (e *termExpr) Match(req *RequestData) bool {
+	return e.match(req)
+}
+
+// NewFilterQuery creates a query to search collected requests by filter expression, conditions of the expression
+// that can be handled by backends natively (date range, text search, method, etc.) are translated into query fields
+func NewFilterQuery(filter string) (*RequestsQuery, error) {
+	expr, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := NewTextQuery("", "any")
+	query.filter = expr
+	query.filterText = filter
+	translateFilter(expr, query)
+
+	return query, nil
+}
+
+// translateFilter sets query fields according to conditions that must be met by every matching request,
+// fields are used by backends to narrow down the search while the exact match is always done by the expression
+func translateFilter(expr filterExpr, query *RequestsQuery) {
+	switch e := expr.(type) {
+	case andExpr:
+		for _, item := range e {
+			translateFilter(item, query)
+		}
+	case *termExpr:
+		switch {
+		case e.op == ":" && (e.field == "any" || e.field == "body" || e.field == "query" || e.field == "headers"):
+			if len(query.Text) == 0 {
+				query.Text = e.value
+				query.In = e.field
+			}
+		case e.op == ":" && e.field == "method":
+			if len(query.Method) == 0 {
+				query.Method = strings.ToUpper(e.value)
+			}
+		case e.op == ":" && e.field == "path":
+			if len(query.Path) == 0 {
+				query.SetPath(e.value)
+			}
+		case e.op == ":" && e.field == "header":
+			query.AddHeader(e.value)
+		case e.field == "date":
+			date, _ := parseTimestamp(e.value)
+			if (e.op == ">=" || e.op == "=" || e.op == ":") && date > query.From {
+				query.From = date
+			} else if e.op == ">" && date+1 > query.From {
+				query.From = date + 1
+			}
+			if (e.op == "<=" || e.op == "=" || e.op == ":") && (query.To == 0 || date < query.To) {
+				query.To = date
+			} else if e.op == "<" && (query.To == 0 || date-1 < query.To) {
+				query.To = date - 1
+			}
+		}
+	}
+}
+
+// textOptions controls matching of text values by filter conditions, see RequestsQuery.SetOptions
+type textOptions struct {
+	ignoreCase bool
+	wholeWord  bool
+}
+
+// filterParser is a recursive descent parser of filter expressions
+type filterParser struct {
+	input   string
+	pos     int
+	options textOptions
+}
+
+func parseFilter(filter string) (filterExpr, error) {
+	return parseFilterOptions(filter, textOptions{})
+}
+
+// parseFilterOptions parses filter expression, text conditions of the expression are matched according to options
+func parseFilterOptions(filter string, options textOptions) (filterExpr, error) {
+	p := &filterParser{input: filter, options: options}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %s", err)
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("invalid filter: unexpected '%s' at position %d", p.input[p.pos:p.pos+1], p.pos)
+	}
+	if expr == nil {
+		return nil, fmt.Errorf("invalid filter: empty expression")
+	}
+
+	return expr, nil
+}
+
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// keyword checks if the next word is given keyword and consumes it
+func (p *filterParser) keyword(keyword string) bool {
+	p.skipSpaces()
+	end := p.pos + len(keyword)
+	if end <= len(p.input) && p.input[p.pos:end] == keyword &&
+		(end == len(p.input) || unicode.IsSpace(rune(p.input[end])) || p.input[end] == '(') {
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	items := make(orExpr, 0, 1)
+	for {
+		item, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if !p.keyword("OR") {
+			break
+		}
+	}
+
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return items, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	items := make(andExpr, 0, 1)
+	for {
+		item, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		// terms separated by space are combined with AND
+		explicit := p.keyword("AND")
+		p.skipSpaces()
+		if !explicit && (p.pos >= len(p.input) || p.input[p.pos] == ')' || p.peekKeyword("OR")) {
+			break
+		}
+	}
+
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return items, nil
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	pos := p.pos
+	found := p.keyword(keyword)
+	p.pos = pos
+	return found
+}
+
+func (p *filterParser) parseNot() (filterExpr, error) {
+	if p.keyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if p.input[p.pos] == '(' {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ')' at position %d", p.pos)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parseTerm()
+}
+
+func (p *filterParser) parseTerm() (filterExpr, error) {
+	start := p.pos
+
+	// quoted text without field
+	if p.input[p.pos] == '"' {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return newTermExpr("any", ":", value, p.options)
+	}
+
+	// field name
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || p.input[p.pos] == '_') {
+		p.pos++
+	}
+	field := strings.ToLower(p.input[start:p.pos])
+
+	op := ""
+	if len(field) > 0 {
+		for _, candidate := range []string{">=", "<=", ":", "~", "=", ">", "<"} {
+			if strings.HasPrefix(p.input[p.pos:], candidate) {
+				op = candidate
+				break
+			}
+		}
+	}
+
+	if len(op) == 0 {
+		// bare text without field
+		p.pos = start
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if len(value) == 0 {
+			return nil, fmt.Errorf("unexpected '%s' at position %d", p.input[p.pos:p.pos+1], p.pos)
+		}
+		return newTermExpr("any", ":", value, p.options)
+	}
+
+	p.pos += len(op)
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return newTermExpr(field, op, value, p.options)
+}
+
+// parseValue parses quoted string or bare word that ends with space or ')'
+func (p *filterParser) parseValue() (string, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		end := p.pos + 1
+		for end < len(p.input) && p.input[end] != '"' {
+			if p.input[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(p.input) {
+			return "", fmt.Errorf("unterminated string at position %d", p.pos)
+		}
+
+		value, err := strconv.Unquote(p.input[p.pos : end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid string at position %d", p.pos)
+		}
+		p.pos = end + 1
+		return value, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos])) && p.input[p.pos] != ')' && p.input[p.pos] != '(' {
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+// newTermExpr creates filter condition, validates and pre-compiles its value
+func newTermExpr(field string, op string, value string, options textOptions) (*termExpr, error) {
+	term := &termExpr{field: field, op: op, value: value}
+
+	var err error
+	switch field {
+	case "any", "body", "query", "headers":
+		var matchValue func(string) bool
+		if matchValue, err = textMatcher(op, value, options); err == nil {
+			term.match = func(req *RequestData) bool { return req.matchesIn(field, matchValue) }
+		}
+	case "method":
+		var matchValue func(string) bool
+		if op == ":" {
+			matchValue = func(method string) bool { return strings.EqualFold(method, value) }
+		} else {
+			matchValue, err = textMatcher(op, value, options)
+		}
+		term.match = func(req *RequestData) bool { return matchValue(req.Method) }
+	case "path":
+		var regex *regexp.Regexp
+		if op == ":" {
+			query := new(RequestsQuery)
+			query.SetPath(value)
+			regex = query.path
+		} else if op == "~" {
+			regex, err = regexp.Compile(value)
+		} else {
+			err = fmt.Errorf("operator '%s' is not supported by field '%s'", op, field)
+		}
+		term.match = func(req *RequestData) bool {
+			return regex.MatchString(req.Path) || regex.MatchString(relativePath(req.Path))
+		}
+	case "header":
+		term.match, err = headerMatcher(op, value)
+	case "json":
+		var json *jsonPathQuery
+		if op != ":" {
+			err = fmt.Errorf("operator '%s' is not supported by field '%s'", op, field)
+		} else if json, err = parseJSONPath(value); err == nil {
+			term.match = func(req *RequestData) bool { return json.Match(req.Body) }
+		}
+	case "date":
+		var date int64
+		if date, err = parseTimestamp(value); err == nil {
+			var compare func(int64) bool
+			if compare, err = numberComparator(op, date); err == nil {
+				term.match = func(req *RequestData) bool { return compare(req.Date) }
+			}
+		}
+	case "size":
+		var size int64
+		if size, err = strconv.ParseInt(value, 10, 64); err == nil {
+			var compare func(int64) bool
+			if compare, err = numberComparator(op, size); err == nil {
+				term.match = func(req *RequestData) bool { return compare(req.ContentLength) }
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown field '%s'", field)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s%s%s - %s", field, op, value, err)
+	}
+	return term, nil
+}
+
+func textMatcher(op string, value string, options textOptions) (func(string) bool, error) {
+	switch op {
+	case ":":
+		if !options.ignoreCase && !options.wholeWord {
+			return func(text string) bool { return strings.Contains(text, value) }, nil
+		}
+		return regexp.MustCompile(textExpr(regexp.QuoteMeta(value), options.ignoreCase, options.wholeWord)).MatchString, nil
+	case "~":
+		regex, err := regexp.Compile(textExpr(value, options.ignoreCase, options.wholeWord))
+		if err != nil {
+			return nil, err
+		}
+		return regex.MatchString, nil
+	default:
+		return nil, fmt.Errorf("operator '%s' is not supported for text", op)
+	}
+}
+
+func headerMatcher(op string, value string) (func(req *RequestData) bool, error) {
+	parts := strings.SplitN(value, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(name) == 0 {
+		return nil, fmt.Errorf("header name is expected, e.g. Name:value")
+	}
+
+	pattern := ""
+	if len(parts) > 1 {
+		pattern = strings.TrimSpace(parts[1])
+	}
+	matchValue, err := textMatcher(op, pattern, textOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	name = http.CanonicalHeaderKey(name)
+	return func(req *RequestData) bool {
+		for _, val := range req.Header[name] {
+			if matchValue(val) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func numberComparator(op string, value int64) (func(int64) bool, error) {
+	switch op {
+	case "=", ":":
+		return func(n int64) bool { return n == value }, nil
+	case ">":
+		return func(n int64) bool { return n > value }, nil
+	case ">=":
+		return func(n int64) bool { return n >= value }, nil
+	case "<":
+		return func(n int64) bool { return n < value }, nil
+	case "<=":
+		return func(n int64) bool { return n <= value }, nil
+	default:
+		return nil, fmt.Errorf("operator '%s' is not supported for numbers", op)
+	}
+}
+
+// parseTimestamp parses timestamp given either as milliseconds since epoch or in RFC 3339 format,
+// returns milliseconds since epoch or 0 if value is empty
+func parseTimestamp(value string) (int64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected milliseconds since epoch or RFC 3339 date: %s", value)
+	}
+
+	return t.UnixNano() / toMs, nil
+}
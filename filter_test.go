@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createFilterTestRequest() *RequestData {
+	data := new(RequestData)
+	data.Date = 1700000000000
+	data.Header = make(http.Header)
+	data.Header.Add("Content-Type", "application/json")
+	data.Header.Add("X-Event", "order.created")
+	data.Method = "POST"
+	data.Path = "/demo/hooks/orders"
+	data.Query = "source=shop&test=1"
+	data.Body = "{\"event\":{\"type\":\"order.created\",\"order_id\":1542}}"
+	data.ContentLength = int64(len(data.Body))
+	return data
+}
+
+func TestFilter_Match(t *testing.T) {
+	data := createFilterTestRequest()
+
+	for filter, expected := range map[string]bool{
+		`method:POST AND path:/hooks/* AND body~"order_id"`:          true,
+		`method:post path:/hooks/*`:                                  true,
+		`method:GET OR path:/hooks/orders`:                           true,
+		`method:GET OR path:/hooks/payments`:                         false,
+		`NOT method:GET`:                                             true,
+		`NOT (method:POST AND query:test)`:                           false,
+		`order_id`:                                                   true,
+		`"order.created" "source=shop"`:                              true,
+		`body~"order_id\":15[0-9]{2}"`:                               true,
+		`headers:json`:                                               true,
+		`header:X-Event:order.created`:                               true,
+		`header:x-event:order.paid`:                                  false,
+		`header:Authorization`:                                       false,
+		`header~"X-Event:^order\\."`:                                 true,
+		`json:"$.event.order_id > 1000"`:                             true,
+		`json:"$.event.type == 'order.paid'"`:                        false,
+		`date>=1700000000000 date<1700000000001`:                     true,
+		`date>2023-11-14T22:13:20Z`:                                  false,
+		`size>10 AND size<=1000`:                                     true,
+		`(method:PUT OR method:POST) AND (query:live OR query:test)`: true,
+		`method~"^(PUT|PATCH)$"`:                                     false,
+		`path~"^/demo/"`:                                             true,
+	} {
+		query, err := NewFilterQuery(filter)
+		if assert.NoError(t, err, "failed to parse filter: %s", filter) {
+			assert.Equal(t, expected, data.Matches(query), "wrong match of filter: %s", filter)
+		}
+	}
+}
+
+func TestFilter_Invalid(t *testing.T) {
+	for filter, message := range map[string]string{
+		``:                            "unexpected end of expression",
+		`method:POST AND`:             "unexpected end of expression",
+		`(method:POST`:                "missing ')'",
+		`method:POST)`:                "unexpected ')'",
+		`"order.created" source=shop`: "unknown field 'source'",
+		`status:200`:                  "unknown field 'status'",
+		`body~"[0-9"`:                 "missing closing ]",
+		`body>10`:                     "operator '>' is not supported",
+		`date>yesterday`:              "expected milliseconds since epoch or RFC 3339 date",
+		`json:"event.type"`:           "invalid JSONPath",
+		`header:":json"`:              "header name is expected",
+		`body:"unterminated`:          "unterminated string",
+		`size>large`:                  "invalid syntax",
+	} {
+		_, err := NewFilterQuery(filter)
+		if assert.Error(t, err, "error is expected for filter: %s", filter) {
+			assert.Contains(t, err.Error(), message, "wrong error for filter: %s", filter)
+		}
+	}
+}
+
+func TestFilter_Translate(t *testing.T) {
+	query, err := NewFilterQuery(`method:post path:/hooks/* body:order_id header:X-Event date>=1000 date<2000 (query:a OR query:b)`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "POST", query.Method, "method is expected to be translated")
+		assert.Equal(t, "/hooks/*", query.Path, "path is expected to be translated")
+		assert.Equal(t, "order_id", query.Text, "text is expected to be translated")
+		assert.Equal(t, "body", query.In, "text is expected to be translated")
+		assert.Equal(t, []string{""}, query.Headers["X-Event"], "header is expected to be translated")
+		assert.Equal(t, int64(1000), query.From, "date range is expected to be translated")
+		assert.Equal(t, int64(1999), query.To, "date range is expected to be translated")
+	}
+
+	// alternatives cannot be translated
+	query, err = NewFilterQuery(`method:POST OR body:order_id`)
+	if assert.NoError(t, err) {
+		assert.Empty(t, query.Method)
+		assert.Empty(t, query.Text)
+	}
+}
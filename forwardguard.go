@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultForwardSchemes lists schemes of forward URLs accepted if service has no own list
+var defaultForwardSchemes = []string{"http", "https"}
+
+// defaultForwardDenied lists networks that are never reached by forwarding unless allowed explicitly:
+// loopback, private networks (RFC 1918, RFC 6598, RFC 4193), link-local networks and cloud metadata endpoints;
+// IPv4-mapped IPv6 addresses, e.g. ::ffff:127.0.0.1, are checked as IPv4 addresses
+var defaultForwardDenied = []string{
+	"127.0.0.0/8", "::1/128", // loopback, includes the service itself
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", // RFC 1918
+	"100.64.0.0/10",               // carrier-grade NAT, RFC 6598
+	"169.254.0.0/16", "fe80::/10", // link-local, includes metadata endpoint 169.254.169.254
+	"fc00::/7",           // unique local addresses, includes metadata endpoint fd00:ec2::254
+	"100.100.100.200/32", // metadata endpoint of Alibaba Cloud
+	"0.0.0.0/8", "::/128"}
+
+// Defaults of connections of forwarding client, the same as defaults of Go HTTP client
+const (
+	defaultForwardIdleConns   = 2  // idle connections kept per host
+	defaultForwardIdleTimeout = 90 // seconds
+	defaultForwardTLSTimeout  = 10 // seconds
+	defaultForwardKeepAlive   = 30 // seconds
+)
+
+// forwardTuning describes connections of forwarding client, high-volume relays keep more idle connections per host
+// to avoid opening a new connection for every forwarded request
+type forwardTuning struct {
+	IdleConnsPerHost int           // idle connections kept per host, 0 - connections are not reused
+	IdleTimeout      time.Duration // time idle connection is kept before it is closed, 0 - no limit
+	TLSTimeout       time.Duration // time to wait for TLS handshake, 0 - no limit
+	KeepAlive        time.Duration // period of TCP keep-alive probes, 0 - probes are disabled
+}
+
+// defaultForwardTuning is tuning of forwarding client if service is not tuned otherwise
+var defaultForwardTuning = forwardTuning{
+	IdleConnsPerHost: defaultForwardIdleConns,
+	IdleTimeout:      defaultForwardIdleTimeout * time.Second,
+	TLSTimeout:       defaultForwardTLSTimeout * time.Second,
+	KeepAlive:        defaultForwardKeepAlive * time.Second}
+
+// newForwardTuning creates tuning of forwarding client from settings in seconds, negative values are not allowed
+func newForwardTuning(idleConns int, idleTimeout int, tlsTimeout int, keepAlive int) (forwardTuning, error) {
+	if idleConns < 0 || idleTimeout < 0 || tlsTimeout < 0 || keepAlive < 0 {
+		return forwardTuning{}, fmt.Errorf("settings of forwarding connections may not be negative")
+	}
+	return forwardTuning{IdleConnsPerHost: idleConns, IdleTimeout: time.Duration(idleTimeout) * time.Second,
+		TLSTimeout: time.Duration(tlsTimeout) * time.Second, KeepAlive: time.Duration(keepAlive) * time.Second}, nil
+}
+
+// forwardGuard restricts forwarding of collected requests to prevent server-side request forgery,
+// any user who configures forward URL of basket would otherwise reach internal services
+var forwardGuard *forwardPolicy
+
+// forwardPolicy describes schemes and networks that are allowed as targets of forwarding
+type forwardPolicy struct {
+	schemes map[string]bool
+	denied  []*net.IPNet
+	allowed []*net.IPNet // exceptions of denied networks
+}
+
+// newForwardPolicy creates policy of forwarding, denied networks are added to the default denylist
+// and allowed networks take precedence over denied ones
+func newForwardPolicy(schemes []string, denied []string, allowed []string) (*forwardPolicy, error) {
+	if len(schemes) == 0 {
+		schemes = defaultForwardSchemes
+	}
+	policy := &forwardPolicy{schemes: make(map[string]bool)}
+	for _, scheme := range schemes {
+		policy.schemes[strings.ToLower(scheme)] = true
+	}
+
+	var err error
+	if policy.denied, err = parseNetworks(append(append([]string{}, defaultForwardDenied...), denied...)); err != nil {
+		return nil, fmt.Errorf("invalid denied network of forwarding: %s", err)
+	}
+	if policy.allowed, err = parseNetworks(allowed); err != nil {
+		return nil, fmt.Errorf("invalid allowed network of forwarding: %s", err)
+	}
+	return policy, nil
+}
+
+// CheckIP checks if IP address may be reached by forwarding
+func (policy *forwardPolicy) CheckIP(ip net.IP) error {
+	if containsIP(policy.denied, ip) && !containsIP(policy.allowed, ip) {
+		return fmt.Errorf("forwarding to address %s is not allowed", ip)
+	}
+	return nil
+}
+
+// CheckURL checks scheme and IP address of forward URL, host names are checked once they are resolved
+// upon connection, so a name cannot be re-bound to a denied address after the check
+func (policy *forwardPolicy) CheckURL(forwardURL string) error {
+	u, err := url.ParseRequestURI(forwardURL)
+	if err != nil {
+		return err
+	}
+	if !policy.schemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("forwarding with scheme %q is not allowed", u.Scheme)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return policy.CheckIP(ip)
+	}
+	return nil
+}
+
+// control checks resolved address of every connection opened to forward requests
+func (policy *forwardPolicy) control(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	return policy.CheckIP(net.ParseIP(host))
+}
+
+// newForwardTransport creates HTTP transport of forwarding that never connects to denied addresses
+func newForwardTransport(policy *forwardPolicy, tuning forwardTuning) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: tuning.KeepAlive, Control: policy.control}
+	if tuning.KeepAlive == 0 {
+		dialer.KeepAlive = -1
+	}
+	transport.DialContext = dialer.DialContext
+
+	transport.MaxIdleConnsPerHost = tuning.IdleConnsPerHost
+	if tuning.IdleConnsPerHost > transport.MaxIdleConns {
+		transport.MaxIdleConns = tuning.IdleConnsPerHost
+	}
+	transport.DisableKeepAlives = tuning.IdleConnsPerHost == 0
+	transport.IdleConnTimeout = tuning.IdleTimeout
+	transport.TLSHandshakeTimeout = tuning.TLSTimeout
+	return transport
+}
+
+// newOutboundClient creates HTTP client of notifications, webhooks, push and alert deliveries, targets of these
+// calls are configured by users just as forward URLs, so the client never connects to addresses denied by forwarding
+func newOutboundClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: outboundControl}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// outboundControl checks resolved address of every outbound connection against policy of forwarding,
+// the policy is looked up upon connection since clients may be created before the service is configured
+func outboundControl(network string, address string, c syscall.RawConn) error {
+	if forwardGuard == nil {
+		return nil
+	}
+	return forwardGuard.control(network, address, c)
+}
+
+// checkOutboundURL checks scheme and IP address of outbound URL against policy of forwarding
+func checkOutboundURL(target string) error {
+	if forwardGuard == nil {
+		return nil
+	}
+	return forwardGuard.CheckURL(target)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewForwardPolicy(t *testing.T) {
+	_, err := newForwardPolicy(nil, []string{"10.0.0.0/33"}, nil)
+	assert.Error(t, err, "invalid denied network is not expected")
+	_, err = newForwardPolicy(nil, nil, []string{"abc"})
+	assert.Error(t, err, "invalid allowed network is not expected")
+}
+
+func TestForwardPolicy_CheckURL(t *testing.T) {
+	policy, err := newForwardPolicy(nil, []string{"203.0.113.0/24"}, []string{"10.1.2.0/24"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, policy.CheckURL("http://example.com/hook"))
+	assert.NoError(t, policy.CheckURL("HTTPS://192.0.2.1:8443/hook"))
+	assert.NoError(t, policy.CheckURL("http://10.1.2.3/hook"), "allowed network is expected to be reachable")
+	assert.Error(t, policy.CheckURL("ftp://example.com/hook"), "unknown scheme is not expected")
+	assert.Error(t, policy.CheckURL("file:///etc/passwd"), "file scheme is not expected")
+	assert.Error(t, policy.CheckURL("http://10.0.0.1/hook"), "private network is not expected")
+	assert.Error(t, policy.CheckURL("http://192.168.1.1/hook"), "private network is not expected")
+	assert.Error(t, policy.CheckURL("http://169.254.169.254/latest/meta-data/"), "metadata endpoint is not expected")
+	assert.Error(t, policy.CheckURL("http://[fd00:ec2::254]/latest/meta-data/"), "metadata endpoint is not expected")
+	assert.Error(t, policy.CheckURL("http://[::ffff:10.0.0.1]/hook"), "mapped private address is not expected")
+	assert.Error(t, policy.CheckURL("http://127.0.0.1:55555/api/baskets"), "loopback address is not expected")
+	assert.Error(t, policy.CheckURL("http://[::1]:55555/api/baskets"), "loopback address is not expected")
+	assert.Error(t, policy.CheckURL("http://[::ffff:127.0.0.1]:55555/api/baskets"), "mapped loopback address is not expected")
+	assert.Error(t, policy.CheckURL("http://100.64.0.1/hook"), "carrier-grade NAT address is not expected")
+	assert.Error(t, policy.CheckURL("http://203.0.113.7/hook"), "configured denied network is not expected")
+
+	policy, _ = newForwardPolicy([]string{"https"}, nil, nil)
+	assert.Error(t, policy.CheckURL("http://example.com/hook"), "scheme that is not listed is not expected")
+	assert.NoError(t, policy.CheckURL("https://example.com/hook"))
+}
+
+func TestForwardPolicy_Connection(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	// host names are checked once they are resolved
+	policy, _ := newForwardPolicy(nil, nil, nil)
+	client := &http.Client{Transport: newForwardTransport(policy, defaultForwardTuning)}
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(target.URL, "http://"))
+	_, err := client.Get("http://localhost:" + port)
+	if assert.Error(t, err, "connection to denied address is not expected") {
+		assert.Contains(t, err.Error(), "is not allowed", "wrong error")
+	}
+
+	policy, _ = newForwardPolicy(nil, nil, []string{"127.0.0.1"})
+	client = &http.Client{Transport: newForwardTransport(policy, defaultForwardTuning)}
+	response, err := client.Get(target.URL)
+	if assert.NoError(t, err, "connection to allowed address is expected") {
+		response.Body.Close()
+	}
+}
+
+func TestNewForwardTransport_Tuning(t *testing.T) {
+	_, err := newForwardTuning(-1, 90, 10, 30)
+	assert.Error(t, err, "negative number of idle connections is not expected")
+
+	policy, _ := newForwardPolicy(nil, nil, nil)
+	tuning, err := newForwardTuning(256, 120, 5, 15)
+	if !assert.NoError(t, err) {
+		return
+	}
+	transport := newForwardTransport(policy, tuning)
+	assert.Equal(t, 256, transport.MaxIdleConnsPerHost, "wrong idle connections per host")
+	assert.Equal(t, 256, transport.MaxIdleConns, "total idle connections are expected to cover connections per host")
+	assert.Equal(t, 120*time.Second, transport.IdleConnTimeout, "wrong idle timeout")
+	assert.Equal(t, 5*time.Second, transport.TLSHandshakeTimeout, "wrong TLS handshake timeout")
+	assert.False(t, transport.DisableKeepAlives, "reuse of connections is expected")
+
+	tuning, _ = newForwardTuning(0, 0, 0, 0)
+	transport = newForwardTransport(policy, tuning)
+	assert.True(t, transport.DisableKeepAlives, "reuse of connections is not expected")
+	assert.Equal(t, 100, transport.MaxIdleConns, "default total idle connections are expected")
+}
+
+func TestUpdateBasket_ForwardGuard(t *testing.T) {
+	basket := "forwardguard01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	update := func(forwardURL string) int {
+		r, _ := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket,
+			strings.NewReader(`{"capacity": 20, "forward_url": "`+forwardURL+`"}`))
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, 422, update("http://169.254.169.254/latest/meta-data/"), "wrong HTTP result code")
+	assert.Equal(t, 422, update("gopher://example.com/"), "wrong HTTP result code")
+	assert.Equal(t, 204, update("https://example.com/hook"), "wrong HTTP result code")
+}
+
+// denyLoopbackTargets restricts outbound calls with the default policy that denies loopback addresses of test servers,
+// which are allowed by configuration of tests; returned function restores the original policy
+func denyLoopbackTargets() func() {
+	original := forwardGuard
+	forwardGuard, _ = newForwardPolicy(nil, nil, nil)
+	return func() { forwardGuard = original }
+}
+
+func TestNewOutboundClient(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	defer denyLoopbackTargets()()
+
+	// host names are checked once they are resolved
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(target.URL, "http://"))
+	_, err := newOutboundClient(time.Second).Get("http://localhost:" + port)
+	if assert.Error(t, err, "connection to denied address is not expected") {
+		assert.Contains(t, err.Error(), "is not allowed", "wrong error")
+	}
+	assert.Error(t, checkOutboundURL(target.URL), "denied address is not expected")
+	assert.Error(t, checkOutboundURL("http://169.254.169.254/latest/meta-data/"), "metadata endpoint is not expected")
+}
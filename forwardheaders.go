@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxForwardHeaders      = 20 // header names listed by basket
+	maxForwardHeaderLength = 64
+)
+
+// defaultHopHeaders lists hop-by-hop headers that are never forwarded unless basket exempts them,
+// they describe the connection between sender and the service rather than the request itself
+var defaultHopHeaders = []string{"Connection", "Upgrade", "TE", "Keep-Alive", "Transfer-Encoding", "Trailer",
+	"Proxy-Authorization", "Proxy-Authenticate", "Proxy-Connection"}
+
+// connectionHeaders lists headers that may corrupt the underlying connection of forwarding (or must not
+// be used in HTTP/2), so they cannot be exempted by basket
+var connectionHeaders = map[string]bool{"Connection": true, "Upgrade": true, "Te": true, "Transfer-Encoding": true}
+
+var validHeaderName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// ForwardHeaders describes handling of headers of forwarded requests in addition to hop-by-hop headers
+// that are removed by default and headers removed by the service
+type ForwardHeaders struct {
+	Strip []string `json:"strip,omitempty"` // headers removed in addition to the defaults
+	Keep  []string `json:"keep,omitempty"`  // headers forwarded even if they are removed by default
+}
+
+// validateForwardHeaders validates handling of headers of forwarded requests
+func validateForwardHeaders(headers *ForwardHeaders) error {
+	if len(headers.Strip)+len(headers.Keep) > maxForwardHeaders {
+		return fmt.Errorf("number of forward headers may not be greater than %d", maxForwardHeaders)
+	}
+	for _, name := range append(append([]string{}, headers.Strip...), headers.Keep...) {
+		if len(name) > maxForwardHeaderLength || !validHeaderName.MatchString(name) {
+			return fmt.Errorf("invalid header name: %q", name)
+		}
+	}
+	for _, name := range headers.Keep {
+		if connectionHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s is never forwarded", http.CanonicalHeaderKey(name))
+		}
+	}
+	return nil
+}
+
+// forwardHeadersCleanup removes hop-by-hop headers, headers named by Connection header, headers removed
+// by the service and headers removed by basket; basket may exempt headers that do not corrupt the underlying
+// connection when forwarding request
+func forwardHeadersCleanup(req *http.Request, headers *ForwardHeaders) {
+	keep := make(map[string]bool)
+	strip := append(append([]string{}, defaultHopHeaders...), serverConfig.ForwardStripHeaders...)
+	if headers != nil {
+		for _, name := range headers.Keep {
+			keep[http.CanonicalHeaderKey(name)] = true
+		}
+		strip = append(strip, headers.Strip...)
+	}
+
+	// headers listed by Connection header are hop-by-hop as well
+	for _, value := range req.Header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				strip = append(strip, name)
+			}
+		}
+	}
+
+	for _, name := range strip {
+		if name = http.CanonicalHeaderKey(name); connectionHeaders[name] || !keep[name] {
+			req.Header.Del(name)
+		}
+	}
+}
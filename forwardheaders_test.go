@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateForwardHeaders(t *testing.T) {
+	assert.NoError(t, validateForwardHeaders(&ForwardHeaders{}))
+	assert.NoError(t, validateForwardHeaders(&ForwardHeaders{Strip: []string{"X-Internal-Trace"},
+		Keep: []string{"Proxy-Authorization", "keep-alive"}}))
+	assert.Error(t, validateForwardHeaders(&ForwardHeaders{Strip: []string{"X Trace"}}), "invalid name is not expected")
+	assert.Error(t, validateForwardHeaders(&ForwardHeaders{Strip: []string{strings.Repeat("X", maxForwardHeaderLength+1)}}),
+		"long name is not expected")
+	assert.Error(t, validateForwardHeaders(&ForwardHeaders{Keep: []string{"transfer-encoding"}}),
+		"connection header is not expected to be exempted")
+	assert.Error(t, validateForwardHeaders(&ForwardHeaders{Keep: []string{"TE"}}),
+		"connection header is not expected to be exempted")
+	assert.Error(t, validateForwardHeaders(&ForwardHeaders{Strip: make([]string, maxForwardHeaders+1)}),
+		"too many headers are not expected")
+}
+
+func TestForwardHeadersCleanup(t *testing.T) {
+	defer func(headers []string) { serverConfig.ForwardStripHeaders = headers }(serverConfig.ForwardStripHeaders)
+	serverConfig.ForwardStripHeaders = []string{"X-Forwarded-For"}
+
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest("POST", "http://localhost:12345/hook", nil)
+		for _, name := range []string{"Connection", "Upgrade", "Te", "Keep-Alive", "Transfer-Encoding",
+			"Proxy-Authorization", "X-Forwarded-For", "X-Internal-Trace", "X-Hop", "Content-Type"} {
+			r.Header.Set(name, "value")
+		}
+		r.Header.Set("Connection", "X-Hop")
+		return r
+	}
+
+	// hop-by-hop headers, headers named by Connection header and headers removed by service
+	r := newRequest()
+	forwardHeadersCleanup(r, nil)
+	assert.Equal(t, http.Header{"X-Internal-Trace": {"value"}, "Content-Type": {"value"}}, r.Header,
+		"wrong forwarded headers")
+
+	// headers removed and exempted by basket
+	r = newRequest()
+	forwardHeadersCleanup(r, &ForwardHeaders{Strip: []string{"x-internal-trace"},
+		Keep: []string{"proxy-authorization", "X-Forwarded-For", "Connection", "TE"}})
+	assert.Equal(t, http.Header{"Proxy-Authorization": {"value"}, "X-Forwarded-For": {"value"},
+		"Content-Type": {"value"}}, r.Header, "wrong forwarded headers")
+}
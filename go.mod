@@ -9,5 +9,6 @@ require (
 	github.com/stretchr/testify v1.8.1
 	go.etcd.io/bbolt v1.3.7
 	go.starlark.net v0.0.0-20240123142251-f86470692795
-	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/crypto v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"io"
 	"io/ioutil"
@@ -12,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -22,6 +25,7 @@ const (
 )
 
 var validBasketName = regexp.MustCompile(basketNamePattern)
+var validSecretName = regexp.MustCompile(secretNamePattern)
 var defaultResponse = ResponseConfig{Status: http.StatusOK, Headers: http.Header{}, IsTemplate: false}
 var indexPageTemplate = template.Must(template.New("index").Parse(indexPageContentTemplate))
 var basketPageTemplate = template.Must(template.New("basket").Parse(basketPageContentTemplate))
@@ -30,7 +34,7 @@ var basketsPageTemplate = template.Must(template.New("baskets").Parse(basketsPag
 // writeJSON writes JSON content to HTTP response
 func writeJSON(w http.ResponseWriter, status int, json []byte, err error) {
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 	} else {
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(status)
@@ -56,6 +60,17 @@ func parseInt(value string, min int, max int, defaultValue int) int {
 	return defaultValue
 }
 
+// parseBool parses boolean parameter from HTTP request query
+func parseBool(value string, defaultValue bool) bool {
+	if len(value) > 0 {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+
+	return defaultValue
+}
+
 // getPage retrieves page settings from HTTP request query params
 func getPage(values url.Values) (int, int) {
 	max := parseInt(values.Get("max"), 1, serverConfig.PageSize*10, serverConfig.PageSize)
@@ -64,24 +79,52 @@ func getPage(values url.Values) (int, int) {
 	return max, skip
 }
 
+// getRequestsCursor retrieves ID of the last request on previous page from cursor parameter, 0 - no cursor
+func getRequestsCursor(values url.Values) (int, error) {
+	if cursor := values.Get("cursor"); len(cursor) > 0 {
+		return DecodeRequestsCursor(cursor)
+	}
+	return 0, nil
+}
+
 // getAuthorizedBasket fetches basket details by name and authorizes the access to this basket, returns nil in case of failure
 func getAuthorizedBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params, config *ServerConfig) (string, Basket) {
 	name := ps.ByName("basket")
 	if !validBasketName.MatchString(name) {
-		http.Error(w, "invalid basket name; the name does not match pattern: "+validBasketName.String(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrorInvalidBasketName,
+			"invalid basket name; the name does not match pattern: "+validBasketName.String(), nil)
 	} else if basket := basketsDb.Get(name); basket != nil {
 		// maybe custom header, e.g. basket_key, basket_token
-		if token := r.Header.Get("Authorization"); basket.Authorize(token) || token == config.MasterToken {
+		token := r.Header.Get("Authorization")
+		// basket token is checked last among tokens, since its hash is expensive to verify
+		if token == config.MasterToken || roles.IsAdmin(token) || apiKeys.Authenticate(token) || users.Authorize(token, name) ||
+			authorizeACL(basket, token, "") || isReaderRequest(r) || isJWTAuthorized(r, name, "") || basket.Authorize(token) {
 			return name, basket
 		}
-		w.WriteHeader(http.StatusUnauthorized)
+		httpError(w, "", http.StatusUnauthorized)
 	} else {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrorBasketNotFound, "basket not found: "+name, nil)
 	}
 
 	return "", nil
 }
 
+// getScopedBasket retrieves basket by name from HTTP request path like getAuthorizedBasket does,
+// in addition it accepts access tokens of basket, tokens of users in basket ACL, service tokens, JSON web tokens
+// and signatures of signed URLs that grant the scope
+func getScopedBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params, scope string, config *ServerConfig) (string, Basket) {
+	name := ps.ByName("basket")
+	if validBasketName.MatchString(name) {
+		token := r.Header.Get("Authorization")
+		if basket := basketsDb.Get(name); basket != nil && (authorizeScope(basket, token, scope) || authorizeACL(basket, token, scope) ||
+			roles.Authorize(token, scope) || isJWTAuthorized(r, name, scope) || isSignatureAuthorized(r, name, scope)) {
+			return name, basket
+		}
+	}
+
+	return getAuthorizedBasket(w, r, ps, config)
+}
+
 // authorizeRequest helps to authorize requests for restricted end-points and returns true in case of successful authorization
 // publicAPI requires no authorization unless the server mode is set to "restricted"
 func authorizeRequest(w http.ResponseWriter, r *http.Request, publicAPI bool, config *ServerConfig) bool {
@@ -89,14 +132,81 @@ func authorizeRequest(w http.ResponseWriter, r *http.Request, publicAPI bool, co
 		return true
 	}
 
-	if r.Header.Get("Authorization") == serverConfig.MasterToken {
+	if isAdminToken(r.Header.Get("Authorization")) || isReaderRequest(r) {
+		return true
+	}
+
+	httpError(w, "", http.StatusUnauthorized)
+	return false
+}
+
+// authorizePermission helps to authorize requests for end-points that are available to service roles with the permission
+// in addition to the master token, returns true in case of successful authorization
+func authorizePermission(w http.ResponseWriter, r *http.Request, permission string, config *ServerConfig) bool {
+	if roles.Authorize(r.Header.Get("Authorization"), permission) {
+		return true
+	}
+
+	return authorizeRequest(w, r, false, config)
+}
+
+// authorizeBasketCreation helps to authorize creation of a new basket and returns the user who will own the basket,
+// a user token makes the user the owner, otherwise the basket is owned by inherited owner if any; the master token
+// is required if the server mode is set to "restricted" unless a user token is provided, anonymous clients are subject
+// to anti-abuse controls otherwise
+func authorizeBasketCreation(w http.ResponseWriter, r *http.Request, inherited string) (string, bool) {
+	token := r.Header.Get("Authorization")
+	if user := users.Authenticate(token); user != nil {
+		return user.Name, true
+	}
+	if isAdminToken(token) {
+		return inherited, true
+	}
+	if serverConfig.Mode != ModeRestricted {
+		return inherited, checkAnonymousCreation(w, r)
+	}
+
+	httpError(w, "", http.StatusUnauthorized)
+	return "", false
+}
+
+// claimNewBasket assigns a new basket to its owner within the quota of the owner, baskets without owner
+// are not claimed; writes HTTP response and returns false in case of failure
+func claimNewBasket(w http.ResponseWriter, owner string, name string) bool {
+	if len(owner) == 0 {
 		return true
 	}
 
-	w.WriteHeader(http.StatusUnauthorized)
+	status, err := users.Claim(owner, name)
+	switch status {
+	case http.StatusOK:
+		return true
+	case http.StatusForbidden:
+		writeError(w, status, ErrorQuotaExceeded, err.Error(), nil)
+	case http.StatusConflict:
+		writeError(w, status, ErrorBasketExists, err.Error(), nil)
+	default:
+		httpError(w, err.Error(), status)
+	}
 	return false
 }
 
+// validateNewBasketName validates name of a basket that is about to be created, returns HTTP status for invalid name
+func validateNewBasketName(name string) (int, error) {
+	if name == serviceOldAPIPath || name == serviceAPIPath || name == serviceUIPath || name == serviceHealthPath ||
+		name == serviceReadyPath {
+		return http.StatusForbidden, fmt.Errorf("This basket name conflicts with reserved system path: %s", name)
+	}
+	if !validBasketName.MatchString(name) {
+		return http.StatusBadRequest, fmt.Errorf("invalid basket name; the name does not match pattern: %s", validBasketName.String())
+	}
+	if err := namePolicy.Check(name); err != nil {
+		return http.StatusForbidden, err
+	}
+
+	return http.StatusOK, nil
+}
+
 // validateBasketConfig validates basket configuration
 func validateBasketConfig(config *BasketConfig) error {
 	// validate Capacity
@@ -110,11 +220,70 @@ func validateBasketConfig(config *BasketConfig) error {
 
 	// validate URL
 	if len(config.ForwardURL) > 0 {
-		if _, err := url.ParseRequestURI(config.ForwardURL); err != nil {
+		if err := forwardGuard.CheckURL(config.ForwardURL); err != nil {
+			return err
+		}
+	}
+
+	// validate rate limit
+	if config.RateLimit != nil {
+		if err := validateCaptureLimit(config.RateLimit); err != nil {
+			return err
+		}
+	}
+
+	// validate body size limit
+	if config.BodyLimit != nil {
+		if err := validateBodyLimit(config.BodyLimit); err != nil {
+			return err
+		}
+	}
+
+	// validate redaction rules
+	if err := validateRedaction(config.Redaction); err != nil {
+		return err
+	}
+
+	// validate signature verification
+	if config.Signature != nil {
+		if err := validateSignatureCheck(config.Signature); err != nil {
+			return err
+		}
+	}
+
+	// validate handling of forwarded headers
+	if config.ForwardHeaders != nil {
+		if err := validateForwardHeaders(config.ForwardHeaders); err != nil {
+			return err
+		}
+	}
+
+	// validate public key of body encryption
+	if config.Encryption != nil {
+		if err := validateEncryptionKey(config.Encryption); err != nil {
 			return err
 		}
 	}
 
+	// validate ignore filters
+	if err := validateIgnoreFilters(config.Ignore); err != nil {
+		return err
+	}
+
+	// validate retention period
+	if err := validateRetention(config.Retention); err != nil {
+		return err
+	}
+
+	// validate expiration period
+	if err := validateExpiresAfter(config.ExpiresAfter); err != nil {
+		return err
+	}
+
+	// validate pause
+	if config.Pause != nil {
+		return validatePause(config.Pause)
+	}
 	return nil
 }
 
@@ -127,257 +296,2797 @@ func validateResponseConfig(config *ResponseConfig) error {
 
 	// validate template
 	if config.IsTemplate && len(config.Body) > 0 {
-		if _, err := template.New("body").Parse(config.Body); err != nil {
+		if _, err := template.New("body").Funcs(templateFuncs(nil)).Parse(config.Body); err != nil {
 			return fmt.Errorf("error in body %s", err)
 		}
 	}
 
-	return nil
+	return nil
+}
+
+// validateTriggerConfig validates basket trigger configuration
+func validateTriggerConfig(config *TriggerConfig) error {
+	if len(config.Script) > 0 {
+		if err := validateScript("trigger.star", config.Script); err != nil {
+			return fmt.Errorf("error in script %s", err)
+		}
+	}
+
+	return nil
+}
+
+// validateSchedules validates configuration of basket scheduled scripts
+func validateSchedules(schedules []ScheduleConfig) error {
+	if len(schedules) > maxBasketSchedules {
+		return fmt.Errorf("number of schedules may not be greater than %d", maxBasketSchedules)
+	}
+
+	names := make(map[string]bool)
+	for _, config := range schedules {
+		if len(config.Name) == 0 {
+			return fmt.Errorf("schedule name is required")
+		}
+		if names[config.Name] {
+			return fmt.Errorf("duplicate schedule name: %s", config.Name)
+		}
+		names[config.Name] = true
+
+		if _, err := parseCron(config.Cron); err != nil {
+			return fmt.Errorf("schedule '%s': %s", config.Name, err)
+		}
+		if err := validateScript("schedule.star", config.Script); err != nil {
+			return fmt.Errorf("schedule '%s': error in script %s", config.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateWebhooks validates webhook subscriptions
+func validateWebhooks(subscriptions []WebhookConfig) error {
+	if len(subscriptions) > maxBasketWebhooks {
+		return fmt.Errorf("number of webhooks may not be greater than %d", maxBasketWebhooks)
+	}
+
+	for _, config := range subscriptions {
+		target, err := url.ParseRequestURI(config.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || len(target.Host) == 0 {
+			return fmt.Errorf("invalid webhook URL: %s", config.URL)
+		}
+		if err := checkOutboundURL(config.URL); err != nil {
+			return err
+		}
+
+		for _, event := range config.Events {
+			known := false
+			for _, e := range webhookEvents {
+				known = known || e == event
+			}
+			if !known {
+				return fmt.Errorf("unknown webhook event: %s", event)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getRequestsQuery retrieves search criteria of requests from HTTP request query params,
+// returns nil if no criteria is specified
+func getRequestsQuery(values url.Values) (*RequestsQuery, error) {
+	text := values.Get("q")
+	filter := values.Get("filter")
+	if len(text) > 0 && len(filter) > 0 {
+		return nil, fmt.Errorf("'filter' and 'q' parameters cannot be combined")
+	}
+
+	from, errf := parseTimestamp(values.Get("from"))
+	if errf != nil {
+		return nil, fmt.Errorf("invalid 'from' parameter: %s", errf)
+	}
+	to, errt := parseTimestamp(values.Get("to"))
+	if errt != nil {
+		return nil, fmt.Errorf("invalid 'to' parameter: %s", errt)
+	}
+
+	method := strings.ToUpper(values.Get("method"))
+	path := values.Get("path")
+
+	headers := values["header"]
+	order := values.Get("sort")
+
+	if len(text) == 0 && len(filter) == 0 && from == 0 && to == 0 && len(method) == 0 && len(path) == 0 && len(headers) == 0 &&
+		(len(order) == 0 || order == SortNewest) {
+		return nil, nil
+	}
+
+	var query *RequestsQuery
+	var err error
+	if len(filter) > 0 {
+		query, err = NewFilterQuery(filter)
+	} else {
+		query, err = NewRequestsQuery(text, values.Get("in"), values.Get("query_type"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	query.SetOptions(parseBool(values.Get("ignore_case"), false), parseBool(values.Get("whole_word"), false))
+
+	// explicit parameters are combined with filter expression
+	if from > 0 {
+		query.From = from
+	}
+	if to > 0 {
+		query.To = to
+	}
+	if len(method) > 0 {
+		query.Method = method
+	}
+	if len(path) > 0 {
+		query.SetPath(path)
+	}
+	for _, header := range headers {
+		if err = query.AddHeader(header); err != nil {
+			return nil, err
+		}
+	}
+	if err = query.SetSort(order); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+// getRequestIDs retrieves IDs of requests from 'id' query parameters, each parameter may hold
+// a comma separated list of IDs
+func getRequestIDs(values url.Values) ([]int, error) {
+	ids := make([]int, 0)
+	for _, value := range values["id"] {
+		for _, item := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid request ID: %s", item)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// getValidSecretName retrieves secret name from HTTP request path and validates it
+func getValidSecretName(ps httprouter.Params) (string, error) {
+	name := ps.ByName("secret")
+	if !validSecretName.MatchString(name) {
+		return name, fmt.Errorf("invalid secret name; the name does not match pattern: %s", validSecretName.String())
+	}
+
+	return name, nil
+}
+
+// getValidMethod retrieves mathod name from HTTP request path and validates it
+func getValidMethod(ps httprouter.Params) (string, error) {
+	method := strings.ToUpper(ps.ByName("method"))
+
+	// valid HTTP methods
+	for _, valid := range httpMethods {
+		if method == valid {
+			return method, nil
+		}
+	}
+
+	return method, fmt.Errorf("unknown HTTP method: %s", method)
+}
+
+// basketNames lists names of baskets, either all baskets of database or baskets owned by user
+type basketNames interface {
+	GetNames(max int, skip int) BasketNamesPage
+	GetNamesAfter(position string, max int) BasketNamesPage
+	FindNames(query string, max int, skip int) BasketNamesQueryPage
+}
+
+// GetBaskets handles HTTP request to get registered baskets, a user token limits baskets to the baskets owned by user
+func GetBaskets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var names basketNames
+	if user := users.Authenticate(r.Header.Get("Authorization")); user != nil {
+		names = user
+	} else if authorizePermission(w, r, PermissionList, serverConfig) {
+		names = basketsDb
+	}
+
+	if names != nil {
+		values := r.URL.Query()
+		if query := values.Get("q"); len(query) > 0 {
+			// find names
+			max, skip := getPage(values)
+			json, err := json.Marshal(names.FindNames(query, max, skip))
+			writeJSON(w, http.StatusOK, json, err)
+		} else if cursor := values.Get("cursor"); len(cursor) > 0 {
+			// get basket names page after cursor
+			position, err := DecodeCursor(cursor)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			max, _ := getPage(values)
+			json, err := json.Marshal(names.GetNamesAfter(position, max))
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			// get basket names page
+			json, err := json.Marshal(names.GetNames(getPage(values)))
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// GetUsers handles HTTP request to get user accounts
+func GetUsers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(users.List())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// getAuthorizedUser retrieves user account by name from HTTP request path and authorizes access to the account,
+// the account is accessible with its own token or the master token; writes HTTP response in case of failure
+func getAuthorizedUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) *User {
+	name := ps.ByName("user")
+	if !validUserName.MatchString(name) {
+		httpError(w, "invalid user name; the name does not match pattern: "+validUserName.String(), http.StatusBadRequest)
+	} else if user := users.Get(name); user != nil {
+		token := r.Header.Get("Authorization")
+		if isAdminToken(token) {
+			return user
+		}
+		if owner := users.Authenticate(token); owner != nil && owner.Name == name {
+			return user
+		}
+		httpError(w, "", http.StatusUnauthorized)
+	} else {
+		writeError(w, http.StatusNotFound, ErrorUserNotFound, "user not found: "+name, nil)
+	}
+
+	return nil
+}
+
+// readUserConfig reads and validates settings of user account sent with HTTP request;
+// writes HTTP response and returns false in case of failure
+func readUserConfig(w http.ResponseWriter, r *http.Request, config *UserConfig) bool {
+	// read config (max 2 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+
+	if err = json.Unmarshal(body, config); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if config.MaxBaskets < 0 || config.MaxCapacity < 0 || config.MaxBytes < 0 {
+		httpError(w, "quotas of user should not be negative", http.StatusUnprocessableEntity)
+		return false
+	}
+	for _, group := range config.Groups {
+		if !validUserName.MatchString(group) {
+			httpError(w, "invalid group name; the name does not match pattern: "+validUserName.String(),
+				http.StatusUnprocessableEntity)
+			return false
+		}
+	}
+	return true
+}
+
+// CreateUser handles HTTP request to sign up a new user, the master token is required if service runs
+// in restricted mode; settings of the account are only accepted with the master token
+func CreateUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !authorizeRequest(w, r, true, serverConfig) {
+		return
+	}
+
+	name := ps.ByName("user")
+	if !validUserName.MatchString(name) {
+		httpError(w, "invalid user name; the name does not match pattern: "+validUserName.String(), http.StatusBadRequest)
+		return
+	}
+
+	config := defaultUserConfig()
+	if isAdminToken(r.Header.Get("Authorization")) {
+		if !readUserConfig(w, r, &config) {
+			return
+		}
+	} else if !checkAnonymousCreation(w, r) {
+		return
+	}
+
+	log.Printf("[info] creating user: %s", name)
+	auth, err := users.Create(name, config)
+	if err != nil {
+		writeError(w, http.StatusConflict, ErrorUserExists, err.Error(), nil)
+		return
+	}
+	audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditUserCreate, Target: name}, nil, config)
+
+	json, err := json.Marshal(auth)
+	writeJSON(w, http.StatusCreated, json, err)
+}
+
+// GetUser handles HTTP request to get user account with names of owned baskets
+func GetUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if user := getAuthorizedUser(w, r, ps); user != nil {
+		json, err := json.Marshal(user)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateUser handles HTTP request to update settings of user account, e.g. the quota of owned baskets
+func UpdateUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !authorizeRequest(w, r, false, serverConfig) {
+		return
+	}
+
+	name := ps.ByName("user")
+	user := users.Get(name)
+	if user == nil {
+		writeError(w, http.StatusNotFound, ErrorUserNotFound, "user not found: "+name, nil)
+		return
+	}
+
+	before := UserConfig{MaxBaskets: user.MaxBaskets, MaxCapacity: user.MaxCapacity, MaxBytes: user.MaxBytes,
+		Groups: user.Groups}
+	config := before
+	if readUserConfig(w, r, &config) {
+		if err := users.Update(name, config); err != nil {
+			writeError(w, http.StatusNotFound, ErrorUserNotFound, err.Error(), nil)
+			return
+		}
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditUserUpdate, Target: name}, before, config)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteUser handles HTTP request to delete user account along with all owned baskets
+func DeleteUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if user := getAuthorizedUser(w, r, ps); user != nil {
+		log.Printf("[info] deleting user: %s", user.Name)
+		actor := auditActor(r, "", nil)
+		if owned, found := users.Delete(user.Name); found {
+			for _, name := range owned {
+				// baskets in trash are purged once their grace period is over
+				if isTrashName(name) {
+					continue
+				}
+				if err := removeBasket(name); err != nil {
+					log.Printf("[error] %s", err)
+				}
+			}
+			audit.Record(w, AuditEntry{Actor: actor, Action: AuditUserDelete, Target: user.Name}, user, nil)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetUserSessions handles HTTP request to get sessions of user started with sign in to web UI
+func GetUserSessions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if user := getAuthorizedUser(w, r, ps); user != nil {
+		json, err := json.Marshal(users.Sessions(user.Name, r.Header.Get("Authorization")))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// DeleteUserSession handles HTTP request to revoke session of user, e.g. the session of lost device
+func DeleteUserSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if user := getAuthorizedUser(w, r, ps); user != nil {
+		id := ps.ByName("session")
+		if !users.SignOut(user.Name, id) {
+			httpError(w, "session not found: "+id, http.StatusNotFound)
+			return
+		}
+		log.Printf("[info] revoked session of user: %s", user.Name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditUserSignOut, Target: user.Name},
+			map[string]string{"session": id}, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SignOutUser handles HTTP request to sign out user everywhere: all sessions of user are revoked, the token of
+// user account is replaced and outstanding tokens of owned baskets are revoked, so a compromised device loses
+// access at once; the new token of user account is returned
+func SignOutUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if user := getAuthorizedUser(w, r, ps); user != nil {
+		actor := auditActor(r, "", nil)
+		auth, err := users.SignOutAll(user.Name)
+		if err != nil {
+			writeError(w, http.StatusNotFound, ErrorUserNotFound, err.Error(), nil)
+			return
+		}
+
+		log.Printf("[info] signed out user everywhere: %s", user.Name)
+		for _, name := range user.Baskets {
+			if basket := basketsDb.Get(name); basket != nil {
+				basket.SetToken("")
+				basket.SetShareToken("")
+				basket.SetAccessTokens([]AccessToken{})
+			}
+		}
+		audit.Record(w, AuditEntry{Actor: actor, Action: AuditUserSignOut, Target: user.Name},
+			map[string][]string{"baskets": user.Baskets}, nil)
+
+		json, err := json.Marshal(auth)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetRoles handles HTTP request to get service roles along with their permissions
+func GetRoles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(roles.List())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateRole handles HTTP request to change permissions of service role, permissions of admin role cannot be changed
+func UpdateRole(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		role := ps.ByName("role")
+		if role == RoleAdmin {
+			httpError(w, "permissions of admin role cannot be changed", http.StatusForbidden)
+			return
+		}
+
+		// read role settings (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		config := RoleConfig{}
+		if err = json.Unmarshal(body, &config); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validatePermissions(config.Permissions); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if config.Permissions == nil {
+			config.Permissions = []string{}
+		}
+
+		before := RoleConfig{}
+		for _, existing := range roles.List() {
+			if existing.Name == role {
+				before.Permissions = existing.Permissions
+			}
+		}
+		if roles.Update(role, config) {
+			log.Printf("[info] updated permissions of role: %s", role)
+			audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditRoleUpdate, Target: role}, before, config)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			httpError(w, "role not found: "+role, http.StatusNotFound)
+		}
+	}
+}
+
+// GetRoleTokens handles HTTP request to get service tokens of role, the tokens themselves are not reported
+func GetRoleTokens(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		if role := ps.ByName("role"); isServiceRole(role) {
+			json, err := json.Marshal(roles.Tokens(role))
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			httpError(w, "role not found: "+role, http.StatusNotFound)
+		}
+	}
+}
+
+// IssueRoleToken handles HTTP request to issue a named service token of role
+func IssueRoleToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		role := ps.ByName("role")
+		if !isServiceRole(role) {
+			httpError(w, "role not found: "+role, http.StatusNotFound)
+			return
+		}
+
+		// read token settings (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		token := RoleToken{}
+		if err = json.Unmarshal(body, &token); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validTokenName.MatchString(token.Name) {
+			httpError(w, "invalid token name; the name does not match pattern: "+tokenNamePattern, http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.Printf("[info] issuing service token %s of role: %s", token.Name, role)
+		if token, err = roles.Issue(token.Name, role); err != nil {
+			httpError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditRoleTokenIssue, Target: role + "/" + token.Name},
+			nil, nil)
+
+		json, err := json.Marshal(token)
+		writeJSON(w, http.StatusCreated, json, err)
+	}
+}
+
+// RevokeRoleToken handles HTTP request to revoke service token of role by its name
+func RevokeRoleToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		role, name := ps.ByName("role"), ps.ByName("token")
+		actor := auditActor(r, "", nil)
+		if roles.Revoke(role, name) {
+			log.Printf("[info] revoked service token %s of role: %s", name, role)
+			audit.Record(w, AuditEntry{Actor: actor, Action: AuditRoleTokenRevoke, Target: role + "/" + name}, nil, nil)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			httpError(w, "service token not found: "+name, http.StatusNotFound)
+		}
+	}
+}
+
+// GetAPIKeys handles HTTP request to get API keys, the keys themselves are not reported
+func GetAPIKeys(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(apiKeys.List())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// CreateAPIKey handles HTTP request to create a named API key
+func CreateAPIKey(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		// read key settings (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		key := APIKey{}
+		if err = json.Unmarshal(body, &key); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validTokenName.MatchString(key.Name) {
+			httpError(w, "invalid key name; the name does not match pattern: "+tokenNamePattern, http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.Printf("[info] creating API key: %s", key.Name)
+		if key, err = apiKeys.Create(key.Name); err != nil {
+			httpError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditAPIKeyCreate, Target: key.Name}, nil, nil)
+
+		json, err := json.Marshal(key)
+		writeJSON(w, http.StatusCreated, json, err)
+	}
+}
+
+// RevokeAPIKey handles HTTP request to revoke API key by its name
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		name := ps.ByName("key")
+		actor := auditActor(r, "", nil)
+		if apiKeys.Revoke(name) {
+			log.Printf("[info] revoked API key: %s", name)
+			audit.Record(w, AuditEntry{Actor: actor, Action: AuditAPIKeyRevoke, Target: name}, nil, nil)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			httpError(w, "API key not found: "+name, http.StatusNotFound)
+		}
+	}
+}
+
+// GetAuditLog handles HTTP request to find entries of audit log, the latest entries come first
+func GetAuditLog(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		values := r.URL.Query()
+		from, errf := parseTimestamp(values.Get("from"))
+		if errf != nil {
+			httpError(w, "invalid 'from' parameter: "+errf.Error(), http.StatusBadRequest)
+			return
+		}
+		to, errt := parseTimestamp(values.Get("to"))
+		if errt != nil {
+			httpError(w, "invalid 'to' parameter: "+errt.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := AuditQuery{Basket: values.Get("basket"), Actor: values.Get("actor"), Action: values.Get("action"),
+			From: from, To: to}
+		max := parseInt(values.Get("max"), 1, serverConfig.PageSize*10, serverConfig.PageSize)
+		skip := parseInt(values.Get("skip"), 0, maxAuditEntries, 0)
+
+		json, err := json.Marshal(audit.Find(query, max, skip))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetStats handles HTTP request to get database statistics
+func GetStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizePermission(w, r, PermissionStats, serverConfig) {
+		// get database stats
+		max := parseInt(r.URL.Query().Get("max"), 1, 100, 5)
+		stats := basketsDb.GetStats(max)
+		scriptMetrics.CollectTo(&stats, max)
+		slowOps.CollectTo(&stats, max)
+		serviceCounters.CollectTo(&stats, time.Now())
+		if serviceQuota != nil {
+			serviceQuota.CollectTo(&stats)
+		}
+		json, err := json.Marshal(stats)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// SearchRequests handles HTTP request to search collected requests across all baskets
+func SearchRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizePermission(w, r, ScopeRead, serverConfig) {
+		values := r.URL.Query()
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+		} else if query == nil {
+			httpError(w, "search criteria are not specified", http.StatusBadRequest)
+		} else {
+			max, skip := getPage(values)
+			maxRequests := parseInt(values.Get("max_requests"), 1, serverConfig.PageSize*10, serverConfig.PageSize)
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), FindRequestsInBaskets(basketsDb, query, max, skip, maxRequests)))
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// PurgeRequests handles HTTP request to delete requests matching search criteria in all baskets, e.g. all requests
+// that contain email address of a person, the receipt of deletion is recorded in audit log
+func PurgeRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizePermission(w, r, ScopeClear, serverConfig) {
+		values := r.URL.Query()
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+		} else if query == nil {
+			httpError(w, "purge criteria are not specified", http.StatusBadRequest)
+		} else {
+			actor := auditActor(r, "", nil)
+			receipt := purgeRequests(basketsDb, query, values)
+			log.Printf("[info] purged %d requests of %d baskets, receipt: %s", receipt.Deleted, len(receipt.Baskets), receipt.ID)
+			audit.Record(w, AuditEntry{Actor: actor, Action: AuditRequestPurge, Target: receipt.ID}, nil, receipt)
+
+			json, err := json.Marshal(receipt)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// GetCleanupPolicies handles HTTP request to get cleanup policies of the service
+func GetCleanupPolicies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(cleanup.Policies())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateCleanupPolicies handles HTTP request to replace cleanup policies of the service
+func UpdateCleanupPolicies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		// read policies (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		policies := []CleanupPolicy{}
+		if err = json.Unmarshal(body, &policies); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateCleanupPolicies(policies); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditCleanupPolicies}, cleanup.Policies(), policies)
+		cleanup.SetPolicies(policies)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetNamespacePolicies handles HTTP request to get namespace policies along with storage used by namespaces
+func GetNamespacePolicies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(namespaces.Status())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateNamespacePolicies handles HTTP request to replace namespace policies of the service
+func UpdateNamespacePolicies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		// read policies (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		policies := []NamespacePolicy{}
+		if err = json.Unmarshal(body, &policies); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateNamespacePolicies(policies); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditNamespacePolicies}, namespaces.Policies(), policies)
+		namespaces.SetPolicies(policies)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetAlertRules handles HTTP request to get alert rules of the service
+func GetAlertRules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(alerts.Rules())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateAlertRules handles HTTP request to replace alert rules of the service
+func UpdateAlertRules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		// read rules (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rules := []AlertRule{}
+		if err = json.Unmarshal(body, &rules); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateAlertRules(rules); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditAlertRules}, alerts.Rules(), rules)
+		alerts.SetRules(rules)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetAlerts handles HTTP request to get alerts that are firing at the moment
+func GetAlerts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(alerts.Alerts())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// RunCleanupPolicy handles HTTP request to run cleanup policy immediately, dry run only reports affected baskets
+func RunCleanupPolicy(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		policy, found := cleanup.Policy(ps.ByName("policy"))
+		if !found {
+			httpError(w, "cleanup policy is not found", http.StatusNotFound)
+			return
+		}
+
+		report := cleanup.Run(policy, parseBool(r.URL.Query().Get("dry_run"), policy.DryRun), time.Now())
+		if !report.DryRun {
+			audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditCleanupRun, Target: policy.Name}, nil, report)
+		}
+		json, err := json.Marshal(report)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetCleanupReports handles HTTP request to get reports of the latest runs of cleanup policies
+func GetCleanupReports(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(cleanup.Reports())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetTrash handles HTTP request to get deleted baskets that are kept in trash, the latest deleted baskets go first
+func GetTrash(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(trash.List())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// RestoreBasket handles HTTP request to restore deleted basket from trash, the latest deletion of basket is
+// restored unless the date of deletion is given; restored basket has no owner
+func RestoreBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		name := ps.ByName("basket")
+		if basketsDb.Get(name) != nil {
+			writeError(w, http.StatusConflict, ErrorBasketExists, "basket with name: "+name+" already exists", nil)
+			return
+		}
+
+		deleted, _ := strconv.ParseInt(r.URL.Query().Get("deleted"), 10, 64)
+		restored, err := trash.Restore(name, deleted)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if restored == nil {
+			writeError(w, http.StatusNotFound, ErrorBasketNotFound, "basket is not found in trash: "+name, nil)
+			return
+		}
+
+		log.Printf("[info] restored basket: %s from trash", name)
+		if basket := basketsDb.Get(name); basket != nil {
+			scheduler.Register(name, basket.GetSchedules())
+		}
+		audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditBasketRestore, Basket: name}, nil, restored)
+		json, err := json.Marshal(restored)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetPurgeReceipts handles HTTP request to get receipts of purged requests, the latest purges come first
+func GetPurgeReceipts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizePermission(w, r, ScopeClear, serverConfig) {
+		values := r.URL.Query()
+		max := parseInt(values.Get("max"), 1, serverConfig.PageSize*10, serverConfig.PageSize)
+		skip := parseInt(values.Get("skip"), 0, maxAuditEntries, 0)
+
+		json, err := json.Marshal(findPurgeReceipts(max, skip))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetPurgeReceipt handles HTTP request to get receipt of purged requests; if search criteria are provided,
+// they are verified against the receipt and requests that match them are counted again
+func GetPurgeReceipt(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizePermission(w, r, ScopeClear, serverConfig) {
+		receipt := findPurgeReceipt(ps.ByName("purge"))
+		if receipt == nil {
+			httpError(w, "purge receipt is not found", http.StatusNotFound)
+			return
+		}
+
+		values := r.URL.Query()
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query != nil {
+			if criteriaDigest(values) != receipt.Criteria {
+				httpError(w, "criteria do not match the purge receipt", http.StatusUnprocessableEntity)
+				return
+			}
+			receipt.Remaining = countMatchingRequests(basketsDb, query)
+			receipt.Verified = time.Now().UnixNano() / toMs
+		}
+
+		json, err := json.Marshal(receipt)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetVersion handles HTTP request to get service version details
+func GetVersion(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// get database stats
+	json, err := json.Marshal(version)
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// GetChallenge handles HTTP request to get proof-of-work challenge that anonymous clients solve to create a basket
+func GetChallenge(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if challenges == nil {
+		httpError(w, "proof-of-work challenge is not required", http.StatusNotFound)
+		return
+	}
+
+	challenge, err := challenges.Issue(time.Now())
+	if err != nil {
+		log.Printf("[error] failed to issue challenge: %s", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	json, err := json.Marshal(challenge)
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// GetBasket handles HTTP request to get basket configuration
+func GetBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		json, err := json.Marshal(basket.Config())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// CreateBasket handles HTTP request to create a new basket
+func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	owner, ok := authorizeBasketCreation(w, r, "")
+	if !ok {
+		return
+	}
+
+	name := ps.ByName("basket")
+	if status, err := validateNewBasketName(name); err != nil {
+		writeError(w, status, ErrorInvalidBasketName, err.Error(), nil)
+		return
+	}
+
+	log.Printf("[info] creating basket: %s", name)
+
+	// read config (max 2 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// default config
+	config := BasketConfig{ForwardURL: "", Capacity: userCapacity(owner)}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &config); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateBasketConfig(&config); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if !checkUserCapacity(w, owner, config.Capacity) || !claimNewBasket(w, owner, name) {
+		return
+	}
+
+	auth, err := basketsDb.Create(name, config)
+	if err != nil {
+		users.Release(name)
+		writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+	} else {
+		webhooks.Publish(nil, WebhookEvent{Event: EventBasketCreated, Basket: name})
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, nil), Action: AuditBasketCreate, Basket: name}, nil, config)
+		json, err := json.Marshal(auth)
+		writeJSON(w, http.StatusCreated, json, err)
+	}
+}
+
+// UpdateBasket handles HTTP request to update basket configuration, the basket is created if it does not exist,
+// so the same request can be repeated safely; the token is only returned upon creation
+func UpdateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name := ps.ByName("basket"); validBasketName.MatchString(name) && basketsDb.Get(name) == nil {
+		CreateBasket(w, r, ps)
+		return
+	}
+
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		// read config (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > 0 {
+			// get current config
+			before := basket.Config()
+			config := before
+			if err = json.Unmarshal(body, &config); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err = validateBasketConfig(&config); err != nil {
+				httpError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			if !checkUserCapacity(w, users.Owner(name), config.Capacity) {
+				return
+			}
+
+			if archive != nil {
+				archive.Overflow(name, basket, config.Capacity)
+			}
+			basket.Update(config)
+			audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketUpdate, Basket: name},
+				before, config)
+
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+}
+
+// DeleteBasket handles HTTP request to delete basket
+func DeleteBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeDelete, serverConfig); basket != nil {
+		entry, config := AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketDelete, Basket: name}, basket.Config()
+		if err := removeBasket(name); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		audit.Record(w, entry, config, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// removeBasket moves basket to trash if deleted baskets are kept for a grace period, otherwise deletes it;
+// scheduled scripts, statistics and subscriptions of basket are dropped at once in both cases, while the owner
+// keeps basket in trash
+func removeBasket(name string) error {
+	if trash.Period() <= 0 {
+		deleteBasket(name)
+		return nil
+	}
+
+	log.Printf("[info] moving basket: %s to trash", name)
+	if err := trash.Add(name, time.Now()); err != nil {
+		return err
+	}
+	releaseBasket(name)
+	return nil
+}
+
+// deleteBasket deletes basket along with its scheduled scripts, statistics, subscriptions and ownership
+func deleteBasket(name string) {
+	log.Printf("[info] deleting basket: %s", name)
+
+	basketsDb.Delete(name)
+	releaseBasket(name)
+	users.Release(name)
+}
+
+// releaseBasket drops scheduled scripts, statistics and subscriptions of deleted basket
+func releaseBasket(name string) {
+	scheduler.Register(name, nil)
+	scriptMetrics.Remove(name)
+	slowOps.Remove(name)
+	captureLimits.Remove(name)
+	pushes.Remove(name)
+	storage.Remove(name)
+}
+
+// RenameBasket handles HTTP request to rename basket, collected requests, configuration and token are preserved
+func RenameBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read new name (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rename := BasketRename{}
+		if err = json.Unmarshal(body, &rename); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if status, err := validateNewBasketName(rename.Name); err != nil {
+			writeError(w, status, ErrorInvalidBasketName, err.Error(), nil)
+			return
+		}
+
+		log.Printf("[info] renaming basket: %s to %s", name, rename.Name)
+		entry := AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketRename, Basket: name, Target: rename.Name}
+		if err = basketsDb.Rename(name, rename.Name); err != nil {
+			writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+			return
+		}
+
+		// move scheduled scripts and statistics under the new name
+		scheduler.Register(name, nil)
+		if renamed := basketsDb.Get(rename.Name); renamed != nil {
+			scheduler.Register(rename.Name, renamed.GetSchedules())
+		}
+		scriptMetrics.Rename(name, rename.Name)
+		slowOps.Rename(name, rename.Name)
+		captureLimits.Rename(name, rename.Name)
+		pushes.Rename(name, rename.Name)
+		storage.Remove(name)
+		users.Rename(name, rename.Name)
+		audit.Record(w, entry, BasketRename{Name: name}, rename)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CloneBasket handles HTTP request to create a copy of basket under new name, new basket is as public
+// as creation of basket, so the master token or a user token is required if service runs in restricted mode;
+// the copy is owned by the user whose token is provided, otherwise by the owner of the basket
+func CloneBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		owner, ok := authorizeBasketCreation(w, r, users.Owner(name))
+		if !ok {
+			return
+		}
+
+		// read clone settings (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		clone := BasketClone{}
+		if err = json.Unmarshal(body, &clone); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if status, err := validateNewBasketName(clone.Name); err != nil {
+			writeError(w, status, ErrorInvalidBasketName, err.Error(), nil)
+			return
+		}
+
+		if !checkUserCapacity(w, owner, basket.Config().Capacity) || !claimNewBasket(w, owner, clone.Name) {
+			return
+		}
+
+		log.Printf("[info] cloning basket: %s to %s", name, clone.Name)
+		auth, err := CopyBasket(basketsDb, basket, clone.Name, clone.IncludeRequests)
+		if err != nil {
+			users.Release(clone.Name)
+			writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+			return
+		}
+		if cloned := basketsDb.Get(clone.Name); cloned != nil {
+			scheduler.Register(clone.Name, cloned.GetSchedules())
+			webhooks.Publish(cloned, WebhookEvent{Event: EventBasketCreated, Basket: clone.Name})
+		}
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketClone, Basket: clone.Name,
+			Target: name}, nil, basket.Config())
+
+		json, err := json.Marshal(auth)
+		writeJSON(w, http.StatusCreated, json, err)
+	}
+}
+
+// GetBasketShare handles HTTP request to get read-only share token of basket
+func GetBasketShare(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if token := basket.GetShareToken(); len(token) > 0 {
+			json, err := json.Marshal(BasketAuth{Token: token})
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			httpError(w, "basket is not shared", http.StatusNotFound)
+		}
+	}
+}
+
+// ShareBasket handles HTTP request to issue a new read-only share token of basket, the token permits viewing
+// of collected requests only; a previously issued share token is revoked
+func ShareBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		token, err := GenerateToken()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[info] sharing basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTokenShare, Basket: name}, nil, nil)
+		basket.SetShareToken(token)
+
+		json, err := json.Marshal(BasketAuth{Token: token})
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UnshareBasket handles HTTP request to revoke read-only share token of basket
+func UnshareBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		log.Printf("[info] revoking share token of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTokenUnshare, Basket: name}, nil, nil)
+		basket.SetShareToken("")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SignBasketURL handles HTTP request to issue time-limited signed URLs that grant read-only access to basket
+// without its token, e.g. to share collected requests outside the team
+func SignBasketURL(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read URL settings (max 2 kB), empty body stands for default settings
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		request := SignedURLRequest{}
+		if len(body) > 0 {
+			if err = json.Unmarshal(body, &request); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		ttl, err := validateSignedURLRequest(request)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		expires := time.Now().Add(ttl).UnixNano() / toMs
+		signed := newSignedURL(r, name, expires)
+
+		log.Printf("[info] signing URL of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTokenSign, Basket: name}, nil,
+			map[string]int64{"expires": expires})
+
+		json, err := json.Marshal(signed)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketViewPassword handles HTTP request to protect read-only web view of basket with password, so the view
+// can be shared without tokens; only the hash of password is stored
+func UpdateBasketViewPassword(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read password (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		password := ViewPassword{}
+		if err = json.Unmarshal(body, &password); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateViewPassword(password); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.Printf("[info] protecting web view of basket with password: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditViewPassword, Basket: name},
+			map[string]bool{"protected": len(basket.GetViewPassword()) > 0}, map[string]bool{"protected": true})
+		// view password is hashed the same way as basket token
+		basket.SetViewPassword(hashBasketToken(password.Password))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteBasketViewPassword handles HTTP request to remove password of read-only web view of basket
+func DeleteBasketViewPassword(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		log.Printf("[info] removing password of web view of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditViewPassword, Basket: name},
+			map[string]bool{"protected": len(basket.GetViewPassword()) > 0}, map[string]bool{"protected": false})
+		basket.SetViewPassword("")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ViewBasketLogin handles HTTP request to exchange password of read-only web view of basket for signed URLs
+// of the basket that expire in a while
+func ViewBasketLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := ps.ByName("basket")
+	if !validBasketName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, ErrorInvalidBasketName,
+			"invalid basket name; the name does not match pattern: "+validBasketName.String(), nil)
+		return
+	}
+	basket := basketsDb.Get(name)
+	if basket == nil {
+		writeError(w, http.StatusNotFound, ErrorBasketNotFound, "basket not found: "+name, nil)
+		return
+	}
+	stored := basket.GetViewPassword()
+	if len(stored) == 0 {
+		httpError(w, "web view of basket is not protected with password", http.StatusNotFound)
+		return
+	}
+
+	client := "view:" + name + ":" + r.RemoteAddr
+	if ip := remoteIP(r); ip != nil {
+		client = "view:" + name + ":" + ip.String()
+	}
+	if allowed, _, reset := viewLoginLimiter.Allow(client, time.Now()); !allowed {
+		seconds := strconv.Itoa(int((reset + time.Second - 1) / time.Second))
+		w.Header().Set("Retry-After", seconds)
+		httpError(w, "too many attempts to enter view password, retry in "+seconds+" seconds", http.StatusTooManyRequests)
+		return
+	}
+
+	// read password (max 2 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	password := ViewPassword{}
+	if err = json.Unmarshal(body, &password); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	valid, outdated := verifyBasketToken(stored, password.Password)
+	if !valid {
+		httpError(w, "invalid view password", http.StatusUnauthorized)
+		return
+	}
+	if outdated {
+		basket.SetViewPassword(hashBasketToken(password.Password))
+	}
+
+	json, err := json.Marshal(newSignedURL(r, name, time.Now().Add(viewSessionTTL).UnixNano()/toMs))
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// RotateBasketToken handles HTTP request to replace the token of basket with a new one, the previous token
+// is no longer accepted; read-only share token is not affected
+func RotateBasketToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		token, err := GenerateToken()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[info] rotating token of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTokenRotate, Basket: name}, nil, nil)
+		basket.SetToken(token)
+
+		json, err := json.Marshal(BasketAuth{Token: token})
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// RevokeBasketTokens handles HTTP request to revoke outstanding tokens of basket: the basket token, read-only
+// share token and access tokens; afterwards the basket is accessible with the master token or token of the basket
+// owner only, which are allowed to issue a new basket token
+func RevokeBasketTokens(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		log.Printf("[info] revoking tokens of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTokenRevoke, Basket: name}, nil, nil)
+		basket.SetToken("")
+		basket.SetShareToken("")
+		basket.SetAccessTokens([]AccessToken{})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketAccessTokens handles HTTP request to get access tokens of basket, the tokens themselves are not reported
+func GetBasketAccessTokens(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		tokens := basket.GetAccessTokens()
+		result := make([]AccessToken, 0, len(tokens))
+		for _, token := range tokens {
+			token.Token = ""
+			result = append(result, token)
+		}
+
+		json, err := json.Marshal(result)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// CreateBasketAccessToken handles HTTP request to issue a named access token of basket with limited scopes
+func CreateBasketAccessToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read token settings (max 2 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		token := AccessToken{}
+		if err = json.Unmarshal(body, &token); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateAccessToken(token); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		tokens := basket.GetAccessTokens()
+		for _, t := range tokens {
+			if t.Name == token.Name {
+				httpError(w, fmt.Sprintf("Token with name '%s' already exists", token.Name), http.StatusConflict)
+				return
+			}
+		}
+		if len(tokens) >= maxAccessTokens {
+			httpError(w, fmt.Sprintf("number of access tokens may not be greater than %d", maxAccessTokens),
+				http.StatusUnprocessableEntity)
+			return
+		}
+
+		if token.Token, err = GenerateToken(); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		token.Created = time.Now().UnixNano() / toMs
+
+		log.Printf("[info] issuing access token %s of basket: %s", token.Name, name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditAccessTokenIssue, Basket: name,
+			Target: token.Name}, nil, AccessToken{Name: token.Name, Scopes: token.Scopes, Created: token.Created})
+		basket.SetAccessTokens(append(tokens, token))
+
+		json, err := json.Marshal(token)
+		writeJSON(w, http.StatusCreated, json, err)
+	}
+}
+
+// RevokeBasketAccessToken handles HTTP request to revoke access token of basket by its name
+func RevokeBasketAccessToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		tokenName := ps.ByName("token")
+		tokens := basket.GetAccessTokens()
+		for i, token := range tokens {
+			if token.Name == tokenName {
+				log.Printf("[info] revoking access token %s of basket: %s", tokenName, name)
+				audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditAccessTokenRevoke, Basket: name,
+					Target: tokenName}, nil, nil)
+				basket.SetAccessTokens(append(tokens[:i:i], tokens[i+1:]...))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		httpError(w, "access token not found: "+tokenName, http.StatusNotFound)
+	}
+}
+
+// GetBasketACL handles HTTP request to get access control list of basket
+func GetBasketACL(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		entries := basket.GetACL()
+		if entries == nil {
+			entries = []ACLEntry{}
+		}
+
+		json, err := json.Marshal(entries)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketACL handles HTTP request to replace access control list of basket
+func UpdateBasketACL(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		// read ACL (max 16 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 16*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		entries := []ACLEntry{}
+		if err = json.Unmarshal(body, &entries); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateACL(entries); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.Printf("[info] updating ACL of basket: %s", name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketACL, Basket: name},
+			basket.GetACL(), entries)
+		basket.SetACL(entries)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketResponse handles HTTP request to get basket response configuration
+func GetBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			httpError(w, errm.Error(), http.StatusBadRequest)
+		} else {
+			response := basket.GetResponse(method)
+			if response == nil {
+				response = &defaultResponse
+			}
+
+			json, err := json.Marshal(response)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// UpdateBasketResponse handles HTTP request to update basket response configuration
+func UpdateBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			httpError(w, errm.Error(), http.StatusBadRequest)
+		} else {
+			// read response (max 64 kB)
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+			r.Body.Close()
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+			} else if len(body) > 0 {
+				// get current config
+				response := ResponseConfig{Status: defaultResponse.Status, IsTemplate: false}
+				if err = json.Unmarshal(body, &response); err != nil {
+					httpError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if err = validateResponseConfig(&response); err != nil {
+					httpError(w, err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+
+				before := basket.GetResponse(method)
+				if before == nil {
+					before = &defaultResponse
+				}
+				basket.SetResponse(method, response)
+				audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditResponse, Basket: name,
+					Target: method}, before, response)
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				w.WriteHeader(http.StatusNotModified)
+			}
+		}
+	}
+}
+
+// GetBasketTrigger handles HTTP request to get basket trigger configuration
+func GetBasketTrigger(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		trigger := basket.GetTrigger()
+		if trigger == nil {
+			trigger = &TriggerConfig{}
+		}
+
+		json, err := json.Marshal(trigger)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketTrigger handles HTTP request to update basket trigger configuration
+func UpdateBasketTrigger(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		// read trigger (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > 0 {
+			trigger := TriggerConfig{}
+			if err = json.Unmarshal(body, &trigger); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err = validateTriggerConfig(&trigger); err != nil {
+				httpError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
+			before := basket.GetTrigger()
+			basket.SetTrigger(trigger)
+			audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditTrigger, Basket: name},
+				before, trigger)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+}
+
+// GetBasketSchedules handles HTTP request to get scheduled scripts of basket
+func GetBasketSchedules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		schedules := basket.GetSchedules()
+		if schedules == nil {
+			schedules = []ScheduleConfig{}
+		}
+
+		json, err := json.Marshal(schedules)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketSchedules handles HTTP request to replace scheduled scripts of basket
+func UpdateBasketSchedules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		// read schedules (max 256 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 256*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > 0 {
+			var schedules []ScheduleConfig
+			if err = json.Unmarshal(body, &schedules); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err = validateSchedules(schedules); err != nil {
+				httpError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
+			before := basket.GetSchedules()
+			basket.SetSchedules(schedules)
+			scheduler.Register(name, schedules)
+			audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditSchedules, Basket: name},
+				before, schedules)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+}
+
+// GetBasketSecrets handles HTTP request to get secrets of basket, secret values are never exposed
+func GetBasketSecrets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		secrets := basket.GetSecrets()
+		for name := range secrets {
+			secrets[name] = secretMask
+		}
+
+		json, err := json.Marshal(secrets)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketSecret handles HTTP request to set a secret of basket, request body is the secret value
+func UpdateBasketSecret(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if basketName, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		name, errn := getValidSecretName(ps)
+		if errn != nil {
+			httpError(w, errn.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// read secret value (max 4 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 4*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > 0 {
+			basket.SetSecret(name, string(body))
+			audit.Record(w, AuditEntry{Actor: auditActor(r, basketName, basket), Action: AuditSecretUpdate, Basket: basketName,
+				Target: name}, nil, nil)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			httpError(w, "secret value may not be empty", http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+// DeleteBasketSecret handles HTTP request to delete a secret of basket
+func DeleteBasketSecret(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if basketName, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		name, errn := getValidSecretName(ps)
+		if errn != nil {
+			httpError(w, errn.Error(), http.StatusBadRequest)
+			return
+		}
+
+		basket.DeleteSecret(name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, basketName, basket), Action: AuditSecretDelete, Basket: basketName,
+			Target: name}, nil, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketWebhooks handles HTTP request to get webhook subscriptions of basket, secrets are never exposed
+func GetBasketWebhooks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		json, err := json.Marshal(maskWebhookSecrets(basket.GetWebhooks()))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketWebhooks handles HTTP request to replace webhook subscriptions of basket
+func UpdateBasketWebhooks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		if subscriptions, ok := readWebhooks(w, r, basket.GetWebhooks()); ok {
+			audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditWebhooks, Basket: name},
+				maskWebhookSecrets(basket.GetWebhooks()), maskWebhookSecrets(subscriptions))
+			basket.SetWebhooks(subscriptions)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// GetWebhooks handles HTTP request to get global webhook subscriptions to events of all baskets
+func GetWebhooks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		json, err := json.Marshal(maskWebhookSecrets(webhooks.GetGlobal()))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateWebhooks handles HTTP request to replace global webhook subscriptions
+func UpdateWebhooks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		if subscriptions, ok := readWebhooks(w, r, webhooks.GetGlobal()); ok {
+			audit.Record(w, AuditEntry{Actor: auditActor(r, "", nil), Action: AuditServiceWebhooks},
+				maskWebhookSecrets(webhooks.GetGlobal()), maskWebhookSecrets(subscriptions))
+			webhooks.SetGlobal(subscriptions)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// GetBasketWebhookDeliveries handles HTTP request to get the latest deliveries to webhook subscribers of basket
+func GetBasketWebhookDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		writeWebhookDeliveries(w, r, basket)
+	}
+}
+
+// RedriveBasketWebhookDeliveries handles HTTP request to redrive failed deliveries to webhook subscribers of basket
+func RedriveBasketWebhookDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		redriveWebhookDeliveries(w, r, basket)
+	}
+}
+
+// GetWebhookDeliveries handles HTTP request to get the latest deliveries to global webhook subscribers
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		writeWebhookDeliveries(w, r, nil)
+	}
+}
+
+// RedriveWebhookDeliveries handles HTTP request to redrive failed deliveries to global webhook subscribers
+func RedriveWebhookDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		redriveWebhookDeliveries(w, r, nil)
+	}
+}
+
+// writeWebhookDeliveries writes deliveries of basket or global deliveries if basket is nil, optionally filtered
+// by status; event payloads are omitted unless requested
+func writeWebhookDeliveries(w http.ResponseWriter, r *http.Request, basket Basket) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryFailed:
+	default:
+		httpError(w, "invalid delivery status: "+status, http.StatusBadRequest)
+		return
+	}
+
+	deliveries := webhooks.GetDeliveries(basket, status)
+	if r.URL.Query().Get("payload") != "true" {
+		for i := range deliveries {
+			deliveries[i].Payload = nil
+		}
+	}
+
+	json, err := json.Marshal(deliveries)
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// redriveWebhookDeliveries redrives failed deliveries of basket or global deliveries if basket is nil
+func redriveWebhookDeliveries(w http.ResponseWriter, r *http.Request, basket Basket) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redrive := WebhookRedrive{}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &redrive); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries := webhooks.Redrive(basket, redrive.IDs)
+	for i := range deliveries {
+		deliveries[i].Payload = nil
+	}
+
+	json, err := json.Marshal(deliveries)
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// readWebhooks reads and validates webhook subscriptions sent with HTTP request, masked secrets are
+// replaced by secrets of existing subscriptions; writes HTTP response and returns false in case of failure
+func readWebhooks(w http.ResponseWriter, r *http.Request, existing []WebhookConfig) ([]WebhookConfig, bool) {
+	// read webhooks (max 64 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNotModified)
+		return nil, false
+	}
+
+	subscriptions := []WebhookConfig{}
+	if err = json.Unmarshal(body, &subscriptions); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err = validateWebhooks(subscriptions); err != nil {
+		httpError(w, err.Error(), http.StatusUnprocessableEntity)
+		return nil, false
+	}
+
+	keepWebhookSecrets(subscriptions, existing)
+	return subscriptions, true
+}
+
+// GetBasketScripts handles HTTP request to get execution statistics of basket scripts
+func GetBasketScripts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		json, err := json.Marshal(scriptMetrics.Get(name))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetBasketRateLimit handles HTTP request to get statistics of requests that were not collected by basket, because
+// they exceeded capture rate limit or matched ignore filters of basket
+func GetBasketRateLimit(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		json, err := json.Marshal(captureLimits.Get(name))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetBasketSpec handles HTTP request to export basket setup as specification in JSON or YAML format
+func GetBasketSpec(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		writeSpec(w, r, ExportBasketSpec(name, basket))
+	}
+}
+
+// UpdateBasketSpec handles HTTP request to apply basket specification, the basket is created if it does not exist
+// and its token is returned; the master token or a user token is required to create basket if service runs
+// in restricted mode
+func UpdateBasketSpec(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name, basket, owner, ok := getSpecBasket(w, r, ps)
+	if !ok {
+		return
+	}
+
+	spec := BasketSpec{}
+	if !readSpec(w, r, maxBasketSpecSize, &spec) || !checkBasketSpec(w, owner, &spec) {
+		return
+	}
+
+	if basket != nil {
+		before := ExportBasketSpec(name, basket)
+		ApplyBasketSpec(name, basket, spec)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketSpec, Basket: name},
+			before, ExportBasketSpec(name, basket))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !claimNewBasket(w, owner, name) {
+		return
+	}
+
+	log.Printf("[info] creating basket from spec: %s", name)
+	auth, err := createBasketFromSpec(name, spec)
+	if err != nil {
+		users.Release(name)
+		writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+		return
+	}
+	if created := basketsDb.Get(name); created != nil {
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, nil), Action: AuditBasketCreate, Basket: name},
+			nil, ExportBasketSpec(name, created))
+	}
+
+	json, err := json.Marshal(auth)
+	writeJSON(w, http.StatusCreated, json, err)
+}
+
+// GetBasketsSpec handles HTTP request to export setup of all baskets as specification in JSON or YAML format
+func GetBasketsSpec(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if authorizeRequest(w, r, false, serverConfig) {
+		writeSpec(w, r, ExportBasketsSpec(basketsDb))
+	}
+}
+
+// UpdateBasketsSpec handles HTTP request to apply specification of several baskets, missing baskets are created;
+// all specifications are validated before any basket is changed
+func UpdateBasketsSpec(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !authorizeRequest(w, r, false, serverConfig) {
+		return
+	}
+
+	spec := BasketsSpec{}
+	if !readSpec(w, r, maxBasketsSpecSize, &spec) {
+		return
+	}
+
+	names := make(map[string]bool)
+	for i := range spec.Baskets {
+		name := spec.Baskets[i].Name
+		if status, err := validateNewBasketName(name); err != nil {
+			writeError(w, status, ErrorInvalidBasketName, err.Error(), map[string]int{"basket": i})
+			return
+		}
+		if names[name] {
+			httpError(w, "duplicate basket name: "+name, http.StatusUnprocessableEntity)
+			return
+		}
+		names[name] = true
+
+		if err := validateBasketSpec(&spec.Baskets[i]); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, ErrorValidationFailed, "basket '"+name+"': "+err.Error(),
+				map[string]int{"basket": i})
+			return
+		}
+	}
+
+	actor := auditActor(r, "", nil)
+	result := BasketsSpecResult{Created: make(map[string]string), Updated: make([]string, 0)}
+	for _, basketSpec := range spec.Baskets {
+		name := basketSpec.Name
+		if basket := basketsDb.Get(name); basket != nil {
+			before := ExportBasketSpec(name, basket)
+			ApplyBasketSpec(name, basket, basketSpec)
+			audit.Record(w, AuditEntry{Actor: actor, Action: AuditBasketSpec, Basket: name}, before, ExportBasketSpec(name, basket))
+			result.Updated = append(result.Updated, name)
+		} else if auth, err := createBasketFromSpec(name, basketSpec); err == nil {
+			if created := basketsDb.Get(name); created != nil {
+				audit.Record(w, AuditEntry{Actor: actor, Action: AuditBasketCreate, Basket: name}, nil, ExportBasketSpec(name, created))
+			}
+			result.Created[name] = auth.Token
+		} else {
+			writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+			return
+		}
+	}
+
+	log.Printf("[info] applied spec of %d baskets, created: %d", len(spec.Baskets), len(result.Created))
+	json, err := json.Marshal(result)
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// getSpecBasket helps to find basket that is set up from specification or snapshot, a missing basket is going
+// to be created, so the creation is authorized instead; returns name of basket, existing basket or nil and owner
+// of basket; writes HTTP response and returns false in case of failure
+func getSpecBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (string, Basket, string, bool) {
+	name := ps.ByName("basket")
+	if validBasketName.MatchString(name) && basketsDb.Get(name) == nil {
+		owner, ok := authorizeBasketCreation(w, r, "")
+		if !ok {
+			return name, nil, "", false
+		}
+		if status, err := validateNewBasketName(name); err != nil {
+			writeError(w, status, ErrorInvalidBasketName, err.Error(), nil)
+			return name, nil, "", false
+		}
+		return name, nil, owner, true
+	}
+
+	name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig)
+	if basket == nil {
+		return name, nil, "", false
+	}
+	return name, basket, users.Owner(name), true
+}
+
+// checkBasketSpec validates basket specification against the quota of basket owner, capacity of the owner
+// is applied if specification does not define it; writes HTTP response and returns false in case of failure
+func checkBasketSpec(w http.ResponseWriter, owner string, spec *BasketSpec) bool {
+	if spec.Config.Capacity == 0 {
+		spec.Config.Capacity = userCapacity(owner)
+	}
+	if err := validateBasketSpec(spec); err != nil {
+		httpError(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	return checkUserCapacity(w, owner, spec.Config.Capacity)
+}
+
+// createBasketFromSpec creates basket and applies validated specification to it
+func createBasketFromSpec(name string, spec BasketSpec) (BasketAuth, error) {
+	auth, err := basketsDb.Create(name, spec.Config)
+	if err != nil {
+		return auth, err
+	}
+
+	if basket := basketsDb.Get(name); basket != nil {
+		ApplyBasketSpec(name, basket, spec)
+		webhooks.Publish(basket, WebhookEvent{Event: EventBasketCreated, Basket: name})
+	}
+	return auth, nil
+}
+
+// readSpec reads specification in JSON or YAML format sent with HTTP request;
+// writes HTTP response and returns false in case of failure
+func readSpec(w http.ResponseWriter, r *http.Request, maxSize int64, spec interface{}) bool {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	if err = decodeSpec(body, spec); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// GetBasketRequests handles HTTP request to get requests collected by basket
+func GetBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		reaper.Viewed(name)
+		values := r.URL.Query()
+		query, errq := getRequestsQuery(values)
+		before, errc := getRequestsCursor(values)
+		if errq != nil {
+			httpError(w, errq.Error(), http.StatusBadRequest)
+		} else if errc != nil {
+			httpError(w, errc.Error(), http.StatusBadRequest)
+		} else if notModified(w, r, basket) {
+			// collected requests are not changed since the client got them
+			w.WriteHeader(http.StatusNotModified)
+		} else if query != nil {
+			if before > 0 && query.IsSorted() {
+				httpError(w, "'cursor' parameter cannot be combined with custom sort order", http.StatusBadRequest)
+				return
+			}
+			// find requests
+			query.Before = before
+			max, skip := getPage(values)
+			page := FindSortedRequests(basket, query, max, skip)
+			if parseBool(values.Get("highlight"), false) {
+				page.Highlight(query)
+			}
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), page))
+			writeJSON(w, http.StatusOK, json, err)
+		} else if before > 0 {
+			// get requests page after cursor
+			max, _ := getPage(values)
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), basket.GetRequestsBefore(before, max)))
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			// get requests page
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), basket.GetRequests(getPage(values))))
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// notModified sets ETag and Last-Modified headers of collected requests listing and checks if the listing
+// known by client is still valid; the tag is derived from modification time of basket and listing parameters,
+// so no requests are read to validate the listing
+func notModified(w http.ResponseWriter, r *http.Request, basket Basket) bool {
+	modified := basket.LastModified()
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d?%s", getAPIVersion(r), r.URL.RawQuery)
+	etag := fmt.Sprintf("\"%x-%x\"", modified, hash.Sum64())
+
+	w.Header().Set("ETag", etag)
+	if modified > 0 {
+		w.Header().Set("Last-Modified", time.Unix(0, modified*toMs).UTC().Format(http.TimeFormat))
+	}
+
+	return matchesETag(r.Header.Get("If-None-Match"), etag)
+}
+
+// matchesETag checks if entity tag is listed by If-None-Match header, weak comparison is applied
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBasketRequest handles HTTP request to get a single request collected by basket with all recorded details
+func GetBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// router does not allow static path segment next to a parameter
+	if ps.ByName("id") == "next" {
+		WaitBasketRequest(w, r, ps)
+		return
+	}
+
+	if _, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		id, err := strconv.Atoi(ps.ByName("id"))
+		if err != nil || id <= 0 {
+			httpError(w, "invalid request ID: "+ps.ByName("id"), http.StatusBadRequest)
+		} else if request := basket.GetRequest(id); request != nil {
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), request))
+			writeJSON(w, http.StatusOK, json, err)
+		} else {
+			httpError(w, "request not found: "+ps.ByName("id"), http.StatusNotFound)
+		}
+	}
+}
+
+// WaitBasketRequest handles HTTP request to wait for the next request collected by basket that matches optional
+// search criteria; the request is returned as soon as it arrives or no content is returned upon timeout, requests
+// collected before are considered only if the ID of the last seen request is provided with 'after' parameter
+func WaitBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		values := r.URL.Query()
+		timeout, err := parseWaitTimeout(values.Get("timeout"))
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		after := -1
+		if value := values.Get("after"); len(value) > 0 {
+			if after, err = strconv.Atoi(value); err != nil || after < 0 {
+				httpError(w, "invalid request ID: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// start waiting before looking into collected requests, so no request is missed
+		waiter := arrivals.Wait(name, query)
+		defer arrivals.Cancel(name, waiter)
+
+		var request *RequestData
+		if after >= 0 {
+			request = findRequestAfter(basket, after, query)
+		}
+		if request == nil {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			select {
+			case request = <-waiter.arrived:
+				// report the latest state of the request, e.g. response status
+				if stored := basket.GetRequest(request.ID); stored != nil {
+					request = stored
+				}
+			case <-timer.C:
+				w.WriteHeader(http.StatusNoContent)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		json, err := json.Marshal(toAPIVersion(getAPIVersion(r), request))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// TailBasketRequests handles HTTP request to follow requests collected by basket, recently collected requests and
+// requests collected afterwards are streamed as text lines until client disconnects, e.g. with "curl -N"
+func TailBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		values := r.URL.Query()
+		last := defaultTailLines
+		if value := values.Get("last"); len(value) > 0 {
+			var err error
+			if last, err = strconv.Atoi(value); err != nil || last < 0 || last > maxTailLines {
+				httpError(w, fmt.Sprintf("invalid number of last requests, expected 0 to %d: %s", maxTailLines, value),
+					http.StatusBadRequest)
+				return
+			}
+		}
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		options := tailOptions{
+			Headers: parseBool(values.Get("headers"), false),
+			Body:    parseBool(values.Get("body"), false)}
+
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		// prevents browsers from buffering the stream to sniff its content type
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+
+		flush := func() {
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		if err = tailRequests(r.Context(), w, flush, basketsDb, name, query, last, options); err != nil {
+			log.Printf("[info] stopped tailing basket: %s - %s", name, err)
+		}
+	}
+}
+
+// AssertBasketRequests handles HTTP request to assert number of requests collected by basket that match search
+// criteria, the assertion waits for expected requests until its timeout expires
+func AssertBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAssertionSize))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		assertion := BasketAssertion{}
+		if len(body) > 0 {
+			if err = json.Unmarshal(body, &assertion); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if assertion.After < 0 {
+			httpError(w, fmt.Sprintf("invalid request ID: %d", assertion.After), http.StatusBadRequest)
+			return
+		}
+		timeout, err := parseWaitTimeout(assertion.Timeout)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := getAssertionQuery(&assertion)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		expected, err := getRequestsExpectation(&assertion)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if result, ok := assertRequests(r.Context(), name, basket, query, expected, assertion.After, timeout); ok {
+			json, err := json.Marshal(toAPIVersion(getAPIVersion(r), result))
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// ExportBasketRequests handles HTTP request to export requests collected by basket in one of supported formats,
+// only found requests are exported if search criteria are specified
+func ExportBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		values := r.URL.Query()
+		format := values.Get("format")
+		if len(format) == 0 {
+			format = ExportFormatHAR
+		}
+		exporter, err := getRequestsExporter(format)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		options := ExportOptions{Basket: name, BaseURL: getBaseURL(r)}
+		if target := values.Get("target"); len(target) > 0 {
+			if err = ValidateTarget(target); err != nil {
+				httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// requests are exported to be sent elsewhere, e.g. to local development server, so the paths
+			// are relative to basket similar to forwarding with expanded path
+			options.BaseURL = target
+			options.RelativePaths = true
+		}
+
+		if exporter.Stream != nil && query != nil && query.IsSorted() {
+			httpError(w, "custom sort order is not supported by streaming export format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", exporter.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, exporter.Extension))
+		w.WriteHeader(http.StatusOK)
+
+		if exporter.Stream != nil {
+			err = StreamRequests(basket, query, exportPageSize, func(page []*RequestData) error {
+				for _, request := range page {
+					if err := exporter.Stream(w, request, options); err != nil {
+						return err
+					}
+				}
+				// deliver exported page to the client without waiting for the rest of basket
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
+				return nil
+			})
+		} else {
+			var requests []*RequestData
+			if query != nil {
+				requests = FindSortedRequests(basket, query, basket.Size(), 0).Requests
+			} else {
+				requests = basket.GetRequests(basket.Size(), 0).Requests
+			}
+			err = exporter.Export(w, requests, options)
+		}
+		if err != nil {
+			log.Printf("[error] failed to export requests of basket: %s - %s", name, err)
+		}
+	}
+}
+
+// ImportBasketRequests handles HTTP request to import requests recorded elsewhere into basket, HAR format is supported
+func ImportBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if format := r.URL.Query().Get("format"); len(format) > 0 && format != ExportFormatHAR {
+			httpError(w, "unsupported import format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		body := http.MaxBytesReader(w, r.Body, importMaxSize)
+		defer body.Close()
+
+		imported, err := ImportHAR(basket, name, body)
+		if errors.Is(err, errStorageQuota) {
+			writeError(w, http.StatusInsufficientStorage, ErrorQuotaExceeded, err.Error(), RequestsImport{imported})
+			return
+		} else if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("[info] imported %d requests into basket: %s", imported, name)
+		json, err := json.Marshal(RequestsImport{imported})
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// GetBasketSnapshot handles HTTP request to download snapshot of basket setup and collected requests
+func GetBasketSnapshot(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeWriteConfig, serverConfig); basket != nil {
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.snapshot.gz\"",
+			name, now.UTC().Format("20060102150405")))
+		w.WriteHeader(http.StatusOK)
+
+		if err := WriteBasketSnapshot(w, name, basket, now); err != nil {
+			log.Printf("[error] failed to write snapshot of basket: %s - %s", name, err)
+		}
+	}
+}
+
+// RestoreBasketSnapshot handles HTTP request to restore basket from snapshot, setup and collected requests
+// of the basket are replaced; the basket is created if it does not exist and its token is returned, the same
+// way as basket is created from specification
+func RestoreBasketSnapshot(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name, basket, owner, ok := getSpecBasket(w, r, ps)
+	if !ok {
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, snapshotMaxSize)
+	defer body.Close()
+	snapshot, requests, err := ReadBasketSnapshot(body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !checkBasketSpec(w, owner, &snapshot.Spec) {
+		return
+	}
+
+	result := BasketSnapshotRestore{}
+	if basket != nil {
+		log.Printf("[info] restoring basket: %s from snapshot of basket: %s", name, snapshot.Name)
+		before := ExportBasketSpec(name, basket)
+		ApplyBasketSpec(name, basket, snapshot.Spec)
+		result.Requests, err = RestoreBasketRequests(name, basket, requests)
+		storage.Remove(name)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, basket), Action: AuditBasketSnapshot, Basket: name,
+			Target: snapshot.Name}, before, ExportBasketSpec(name, basket))
+		if err != nil {
+			writeRestoreError(w, name, result, err)
+			return
+		}
+
+		json, err := json.Marshal(result)
+		writeJSON(w, http.StatusOK, json, err)
+		return
+	}
+
+	if !claimNewBasket(w, owner, name) {
+		return
+	}
+
+	log.Printf("[info] creating basket: %s from snapshot of basket: %s", name, snapshot.Name)
+	auth, err := createBasketFromSpec(name, snapshot.Spec)
+	if err != nil {
+		users.Release(name)
+		writeError(w, http.StatusConflict, ErrorBasketExists, err.Error(), nil)
+		return
+	}
+	result.Token = auth.Token
+	if created := basketsDb.Get(name); created != nil {
+		result.Requests, err = RestoreBasketRequests(name, created, requests)
+		audit.Record(w, AuditEntry{Actor: auditActor(r, name, nil), Action: AuditBasketCreate, Basket: name,
+			Target: snapshot.Name}, nil, ExportBasketSpec(name, created))
+		if err != nil {
+			writeRestoreError(w, name, result, err)
+			return
+		}
+	}
+
+	json, err := json.Marshal(result)
+	writeJSON(w, http.StatusCreated, json, err)
+}
+
+// writeRestoreError replies with error of restoring requests from snapshot, the result of partial restore is given
+// in details of the error, so the token of created basket is not lost
+func writeRestoreError(w http.ResponseWriter, name string, result BasketSnapshotRestore, err error) {
+	log.Printf("[warn] restored %d requests of basket: %s from snapshot - %s", result.Requests, name, err)
+	if errors.Is(err, errStorageQuota) {
+		writeError(w, http.StatusInsufficientStorage, ErrorQuotaExceeded, err.Error(), result)
+	} else {
+		writeError(w, http.StatusInternalServerError, ErrorInternal, err.Error(), result)
+	}
+}
+
+// getBaseURL returns scheme and host of the service as seen by the client
+func getBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// GetBasketAggregation handles HTTP request to get counts of collected requests grouped by a request attribute
+func GetBasketAggregation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		values := r.URL.Query()
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		aggregation, err := AggregateRequests(basket, values.Get("by"), query)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+		} else {
+			json, err := json.Marshal(aggregation)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// GetBasketStats handles HTTP request to get statistics of requests collected by basket
+func GetBasketStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		values := r.URL.Query()
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stats, err := CollectBasketStats(basket, values.Get("interval"), query)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+		} else {
+			json, err := json.Marshal(stats)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// GetBasketTimeline handles HTTP request to get numbers of requests collected by basket over time
+func GetBasketTimeline(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		timeline, err := CollectBasketTimeline(basket, r.URL.Query().Get("range"), time.Now())
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+		} else {
+			json, err := json.Marshal(timeline)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// VerifyBasketRequests handles HTTP request to verify hash chain of requests collected by basket
+func VerifyBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getScopedBasket(w, r, ps, ScopeRead, serverConfig); basket != nil {
+		json, err := json.Marshal(verifyChain(basket))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// ClearBasket handles HTTP request to delete requests collected by basket, all requests are deleted
+// unless request IDs or search criteria are specified
+func ClearBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getScopedBasket(w, r, ps, ScopeClear, serverConfig); basket != nil {
+		values := r.URL.Query()
+		ids, err := getRequestIDs(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := getRequestsQuery(values)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		storage.Remove(name)
+		if len(ids) == 0 && query == nil {
+			basket.Clear()
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			json, err := json.Marshal(RequestsDeletion{DeleteSelectedRequests(basket, ids, query)})
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// ForwardToWeb handels HTTP forwarding to /web
+func ForwardToWeb(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	http.Redirect(w, r, serverConfig.PathPrefix+"/"+serviceUIPath, http.StatusFound)
+}
+
+type TemplateData struct {
+	Prefix     string
+	Version    *Version
+	ThemeCSS   template.HTML
+	Basket     string
+	SSO        bool // sign in with OpenID Connect provider is available
+	LDAP       bool // sign in with credentials of LDAP directory users is available
+	Proxy      bool // sign in with identity reported by authentication proxy is available
+	NameLength int  // minimum length of names of new baskets
+	Data       interface{}
+}
+
+// GetPushKey handles HTTP request to get public key of the service that browsers use to subscribe
+// to push notifications
+func GetPushKey(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	json, err := json.Marshal(pushes.PublicKey())
+	writeJSON(w, http.StatusOK, json, err)
+}
+
+// PushServiceWorker handles HTTP request to get service worker script that displays push notifications
+func PushServiceWorker(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(pushWorkerScript))
 }
 
-// getValidMethod retrieves mathod name from HTTP request path and validates it
-func getValidMethod(ps httprouter.Params) (string, error) {
-	method := strings.ToUpper(ps.ByName("method"))
+// SubscribeBasketPush handles HTTP request to subscribe browser to push notifications about requests collected
+// by basket after it was idle for a while
+func SubscribeBasketPush(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 8*1024))
+		r.Body.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	// valid HTTP methods
-	switch method {
-	case http.MethodGet,
-		http.MethodHead,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodPatch,
-		http.MethodDelete,
-		http.MethodConnect,
-		http.MethodOptions,
-		http.MethodTrace:
-		return method, nil
+		subscription := PushSubscription{}
+		if err = json.Unmarshal(body, &subscription); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = pushes.Subscribe(name, subscription); err != nil {
+			httpError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
 	}
-
-	return method, fmt.Errorf("unknown HTTP method: %s", method)
 }
 
-// GetBaskets handles HTTP request to get registered baskets
-func GetBaskets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if authorizeRequest(w, r, false, serverConfig) {
-		values := r.URL.Query()
-		if query := values.Get("q"); len(query) > 0 {
-			// find names
-			max, skip := getPage(values)
-			json, err := json.Marshal(basketsDb.FindNames(query, max, skip))
-			writeJSON(w, http.StatusOK, json, err)
+// UnsubscribeBasketPush handles HTTP request to cancel push subscription of browser identified by its endpoint
+func UnsubscribeBasketPush(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		endpoint := r.URL.Query().Get("endpoint")
+		if len(endpoint) == 0 {
+			httpError(w, "push endpoint is not specified", http.StatusBadRequest)
+		} else if pushes.Unsubscribe(name, endpoint) {
+			w.WriteHeader(http.StatusNoContent)
 		} else {
-			// get basket names page
-			json, err := json.Marshal(basketsDb.GetNames(getPage(values)))
-			writeJSON(w, http.StatusOK, json, err)
+			httpError(w, "push subscription not found", http.StatusNotFound)
 		}
 	}
 }
 
-// GetStats handles HTTP request to get database statistics
-func GetStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if authorizeRequest(w, r, false, serverConfig) {
-		// get database stats
-		max := parseInt(r.URL.Query().Get("max"), 1, 100, 5)
-		json, err := json.Marshal(basketsDb.GetStats(max))
-		writeJSON(w, http.StatusOK, json, err)
+// OIDCLogin handles HTTP request to sign in to web UI with OpenID Connect provider, the browser is redirected
+// to authorization page of the provider
+func OIDCLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if oidc == nil {
+		http.Error(w, "Sign in with OpenID Connect provider is not configured", http.StatusNotFound)
+		return
 	}
-}
-
-// GetVersion handles HTTP request to get service version details
-func GetVersion(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// get database stats
-	json, err := json.Marshal(version)
-	writeJSON(w, http.StatusOK, json, err)
-}
 
-// GetBasket handles HTTP request to get basket configuration
-func GetBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		json, err := json.Marshal(basket.Config())
-		writeJSON(w, http.StatusOK, json, err)
+	redirect := r.URL.Query().Get("redirect")
+	if !isWebRedirect(redirect) {
+		redirect = serverConfig.PathPrefix + "/" + serviceUIPath
+	}
+	login, location, err := oidc.Login(redirect)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
 	}
+
+	http.SetCookie(w, oidc.Cookie(login))
+	http.Redirect(w, r, location, http.StatusFound)
 }
 
-// CreateBasket handles HTTP request to create a new basket
-func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if !authorizeRequest(w, r, true, serverConfig) {
+// OIDCCallback handles HTTP request of OpenID Connect provider that completes sign in, the token of signed in
+// user is stored in browser session and the browser returns to web UI
+func OIDCCallback(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if oidc == nil {
+		http.Error(w, "Sign in with OpenID Connect provider is not configured", http.StatusNotFound)
 		return
 	}
 
-	name := ps.ByName("basket")
-	if name == serviceOldAPIPath || name == serviceAPIPath || name == serviceUIPath {
-		http.Error(w, "This basket name conflicts with reserved system path: "+name, http.StatusForbidden)
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		http.Error(w, "Login is not started or expired, please try again", http.StatusBadRequest)
 		return
 	}
-	if !validBasketName.MatchString(name) {
-		http.Error(w, "invalid basket name; the name does not match pattern: "+validBasketName.String(), http.StatusBadRequest)
+	login, err := oidc.ParseCookie(cookie)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	log.Printf("[info] creating basket: %s", name)
-
-	// read config (max 2 kB)
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
-	r.Body.Close()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// login state is used once
+	expired := oidc.Cookie(login)
+	expired.Value = ""
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
+
+	query := r.URL.Query()
+	if failure := query.Get("error"); len(failure) > 0 {
+		http.Error(w, "Sign in failed: "+failure+" "+query.Get("error_description"), http.StatusUnauthorized)
 		return
 	}
-
-	// default config
-	config := BasketConfig{ForwardURL: "", Capacity: serverConfig.InitCapacity}
-	if len(body) > 0 {
-		if err = json.Unmarshal(body, &config); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if err = validateBasketConfig(&config); err != nil {
-			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-			return
-		}
+	if query.Get("state") != login.State {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
 	}
 
-	auth, err := basketsDb.Create(name, config)
+	token, err := oidc.SignIn(query.Get("code"), login)
+	if err == nil {
+		token, err = users.SignIn(token, "oidc", r)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
-	} else {
-		json, err := json.Marshal(auth)
-		writeJSON(w, http.StatusCreated, json, err)
+		log.Printf("[warn] failed to sign in with OpenID Connect provider: %s", err)
+		http.Error(w, "Sign in failed: "+err.Error(), http.StatusUnauthorized)
+		return
 	}
-}
-
-// UpdateBasket handles HTTP request to update basket configuration
-func UpdateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		// read config (max 2 kB)
-		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
-		r.Body.Close()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		} else if len(body) > 0 {
-			// get current config
-			config := basket.Config()
-			if err = json.Unmarshal(body, &config); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			if err = validateBasketConfig(&config); err != nil {
-				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-				return
-			}
-
-			basket.Update(config)
 
-			w.WriteHeader(http.StatusNoContent)
-		} else {
-			w.WriteHeader(http.StatusNotModified)
-		}
-	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	oidcCallbackTemplate.Execute(w, struct{ Token, Redirect string }{token, login.Redirect})
 }
 
-// DeleteBasket handles HTTP request to delete basket
-func DeleteBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		log.Printf("[info] deleting basket: %s", name)
-
-		basketsDb.Delete(name)
-		w.WriteHeader(http.StatusNoContent)
+// ProxyLogin handles HTTP request to sign in to web UI with identity reported by trusted authentication proxy,
+// the token of signed in user is stored in browser session and the browser returns to web UI
+func ProxyLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if proxyAuth == nil {
+		http.Error(w, "Sign in with authentication proxy is not configured", http.StatusNotFound)
+		return
+	}
+	if !proxyAuth.IsTrusted(r) {
+		http.Error(w, "Request does not come from trusted authentication proxy", http.StatusForbidden)
+		return
 	}
-}
 
-// GetBasketResponse handles HTTP request to get basket response configuration
-func GetBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		method, errm := getValidMethod(ps)
-		if errm != nil {
-			http.Error(w, errm.Error(), http.StatusBadRequest)
-		} else {
-			response := basket.GetResponse(method)
-			if response == nil {
-				response = &defaultResponse
-			}
+	token, status, err := proxyAuth.Identify(r)
+	if err == nil && len(token) == 0 {
+		status, err = http.StatusUnauthorized, fmt.Errorf("identity is not reported by authentication proxy")
+	}
+	if err == nil {
+		status = http.StatusInternalServerError
+		token, err = users.SignIn(token, "proxy", r)
+	}
+	if err != nil {
+		log.Printf("[warn] failed to sign in with authentication proxy: %s", err)
+		http.Error(w, err.Error(), status)
+		return
+	}
 
-			json, err := json.Marshal(response)
-			writeJSON(w, http.StatusOK, json, err)
-		}
+	redirect := r.URL.Query().Get("redirect")
+	if !isWebRedirect(redirect) {
+		redirect = serverConfig.PathPrefix + "/" + serviceUIPath
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	oidcCallbackTemplate.Execute(w, struct{ Token, Redirect string }{token, redirect})
 }
 
-// UpdateBasketResponse handles HTTP request to update basket response configuration
-func UpdateBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		method, errm := getValidMethod(ps)
-		if errm != nil {
-			http.Error(w, errm.Error(), http.StatusBadRequest)
-		} else {
-			// read response (max 64 kB)
-			body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
-			r.Body.Close()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			} else if len(body) > 0 {
-				// get current config
-				response := ResponseConfig{Status: defaultResponse.Status, IsTemplate: false}
-				if err = json.Unmarshal(body, &response); err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return
-				}
-				if err = validateResponseConfig(&response); err != nil {
-					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-					return
-				}
-
-				basket.SetResponse(method, response)
-				w.WriteHeader(http.StatusNoContent)
-			} else {
-				w.WriteHeader(http.StatusNotModified)
-			}
-		}
+// LDAPLogin handles HTTP request to sign in with credentials of LDAP directory user, the returned token grants
+// access according to the role of the user
+func LDAPLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if ldap == nil {
+		httpError(w, "LDAP authentication is not configured", http.StatusNotFound)
+		return
 	}
-}
 
-// GetBasketRequests handles HTTP request to get requests collected by basket
-func GetBasketRequests(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		values := r.URL.Query()
-		if query := values.Get("q"); len(query) > 0 {
-			// find requests
-			max, skip := getPage(values)
-			json, err := json.Marshal(basket.FindRequests(query, values.Get("in"), max, skip))
-			writeJSON(w, http.StatusOK, json, err)
-		} else {
-			// get requests page
-			json, err := json.Marshal(basket.GetRequests(getPage(values)))
-			writeJSON(w, http.StatusOK, json, err)
-		}
+	// read credentials (max 2 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	r.Body.Close()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	credentials := LDAPCredentials{}
+	if err = json.Unmarshal(body, &credentials); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-}
 
-// ClearBasket handles HTTP request to delete all requests collected by basket
-func ClearBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		basket.Clear()
-		w.WriteHeader(http.StatusNoContent)
+	session, status, err := ldap.Login(credentials.Username, credentials.Password)
+	if err != nil {
+		log.Printf("[warn] failed to sign in LDAP user: %s - %s", credentials.Username, err)
+		httpError(w, err.Error(), status)
+		return
 	}
-}
 
-// ForwardToWeb handels HTTP forwarding to /web
-func ForwardToWeb(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	http.Redirect(w, r, serverConfig.PathPrefix+"/"+serviceUIPath, http.StatusFound)
-}
+	if session.Token, err = users.SignIn(session.Token, "ldap", r); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-type TemplateData struct {
-	Prefix   string
-	Version  *Version
-	ThemeCSS template.HTML
-	Basket   string
-	Data     interface{}
+	log.Printf("[info] LDAP user signed in: %s (%s)", credentials.Username, session.Role)
+	json, err := json.Marshal(session)
+	writeJSON(w, http.StatusOK, json, err)
 }
 
 // WebIndexPage handles HTTP request to render index page
 func WebIndexPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	indexPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS})
+	indexPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS,
+		SSO: oidc != nil, LDAP: ldap != nil, Proxy: proxyAuth != nil, NameLength: namePolicy.minLength})
 }
 
 // WebBasketPage handles HTTP request to render basket details page
@@ -387,7 +3096,8 @@ func WebBasketPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 		case serviceOldAPIPath:
 			// admin page to access all baskets
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			basketsPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS})
+			basketsPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS,
+				SSO: oidc != nil, LDAP: ldap != nil, Proxy: proxyAuth != nil})
 		default:
 			basketPageTemplate.Execute(w, TemplateData{Prefix: serverConfig.PathPrefix, Version: version, ThemeCSS: serverConfig.ThemeCSS, Basket: name})
 		}
@@ -402,26 +3112,108 @@ func AcceptBasketRequests(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("[error] %s", err)
 		http.Error(w, publicErr, http.StatusBadRequest)
-	} else if basket := basketsDb.Get(name); basket != nil {
-		request := basket.Add(r)
+	} else {
+		acceptBasketRequest(w, r, name, false)
+	}
+}
+
+// acceptBasketRequest collects HTTP request of basket, the request is either passed to basket by path or
+// by subdomain, in the latter case the entire path belongs to the request
+func acceptBasketRequest(w http.ResponseWriter, r *http.Request, name string, subdomain bool) {
+	span := startServerSpan(r, "capture")
+	span.SetAttribute("basket", name)
+	defer span.End()
+
+	if basket := getTracedBasket(span, name); basket != nil {
+		if !acquireCapture(w, name) {
+			return
+		}
+		defer captures.Release(name)
 
-		// forward request if configured and it's a first forwarding
 		config := basket.Config()
-		if len(config.ForwardURL) > 0 && r.Header.Get(DoNotForwardHeader) != "1" {
-			if config.ProxyResponse {
-				forwardAndProxyResponse(w, request, config, name)
-				return
-			}
+		if !checkPause(w, config.Pause) || !checkCaptureLimit(w, name, config.RateLimit) || !checkUserStorage(w, name) {
+			return
+		}
+		truncated, ok := readLimitedBody(w, r, config.BodyLimit)
+		if !ok {
+			return
+		}
+		data := ToRequestData(r)
+		data.BodyTruncated = truncated
+		data.Subdomain = subdomain
+		if !checkIgnoreFilters(w, name, config.Ignore, data) {
+			return
+		}
+		if !checkSignature(w, basket, config.Signature, data) {
+			return
+		}
+		// sensitive data is redacted and body is encrypted before storage, while the request is forwarded as received
+		stored, err := encryptBody(redactRequest(data, config.Redaction), config.Encryption)
+		if err != nil {
+			log.Printf("[error] failed to encrypt request body for basket: %s - %s", name, err)
+			http.Error(w, "failed to encrypt request body", http.StatusInternalServerError)
+			return
+		}
+		size := requestSize(stored)
+		if !checkNamespaceStorage(w, name, size) || !checkServiceStorage(w, size) {
+			return
+		}
+		store, started := span.Child("storage.add", spanKindInternal), time.Now()
+		request := collectRequest(name, basket, config, stored)
+		store.End()
+		slowOps.Observe(SlowStorage, name, "add request", time.Since(started))
+		storage.Add(name, size)
+		metrics.Count("requests.collected", 1, "basket:"+name)
+		serviceCounters.Count(CounterRequests, time.Now())
+		metrics.Count("requests.bytes", size, "basket:"+name)
+		span.SetAttribute("request.id", request.ID)
+		// responses and scripts get the request as received, redaction and encryption apply to the stored copy only
+		data.ID = request.ID
+		// waiting clients are notified once the response is recorded
+		defer arrivals.Notify(name, request)
+
+		// webhook deliveries may be collected by baskets, but they never produce new events to avoid loops
+		if len(r.Header.Get(WebhookEventHeader)) == 0 {
+			webhooks.Publish(basket, WebhookEvent{Event: EventRequestReceived, Basket: name, Request: request})
+		}
+		pushes.Notify(name, request)
 
-			go forwardAndForget(request, config, name)
+		// run trigger script in background, it should never delay the response
+		if trigger := basket.GetTrigger(); trigger != nil && len(trigger.Script) > 0 {
+			go runTrigger(name, basket, trigger, data, span)
 		}
 
-		writeBasketResponse(w, request, name, basket)
+		// forward request if configured and it's a first forwarding
+		forwarding := len(config.ForwardURL) > 0 && r.Header.Get(DoNotForwardHeader) != "1"
+		if forwarding && config.ProxyResponse {
+			forwardAndProxyResponse(w, request, data, config, name, basket, span)
+			return
+		}
+
+		// record response status with collected request
+		status := writeBasketResponse(w, data, name, basket, span)
+		span.SetAttribute("http.status_code", status)
+		store, started = span.Child("storage.update", spanKindInternal), time.Now()
+		basket.UpdateRequest(request.ID, func(data *RequestData) { data.ResponseStatus = status })
+		store.End()
+		slowOps.Observe(SlowStorage, name, "update request", time.Since(started))
+
+		if forwarding {
+			go forwardAndForget(request, data, config, name, basket, span)
+		}
 	} else {
+		span.SetAttribute("http.status_code", http.StatusNotFound)
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
+// getTracedBasket gets basket by name within a span of storage operation
+func getTracedBasket(span *traceSpan, name string) Basket {
+	store := span.Child("storage.get", spanKindInternal)
+	defer store.End()
+	return basketsDb.Get(name)
+}
+
 func getBasketNameOfAcceptedRequest(r *http.Request, prefix string) (string, string, error) {
 	path := r.URL.Path
 	if len(prefix) > 0 {
@@ -442,9 +3234,10 @@ func getBasketNameOfAcceptedRequest(r *http.Request, prefix string) (string, str
 	return name, "", nil
 }
 
-func forwardAndForget(request *RequestData, config BasketConfig, name string) {
+func forwardAndForget(request *RequestData, original *RequestData, config BasketConfig, name string, basket Basket,
+	span *traceSpan) {
 	// forward request and discard the response
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
+	response, err := forward(request, original, config, name, basket, false, span)
 	if err != nil {
 		log.Printf("[warn] failed to forward request for basket: %s - %s", name, err)
 	} else {
@@ -453,9 +3246,10 @@ func forwardAndForget(request *RequestData, config BasketConfig, name string) {
 	}
 }
 
-func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, config BasketConfig, name string) {
+func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, original *RequestData, config BasketConfig,
+	name string, basket Basket, span *traceSpan) {
 	// forward request in a full proxy mode
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
+	response, err := forward(request, original, config, name, basket, true, span)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	} else {
@@ -465,6 +3259,7 @@ func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, config
 		}
 
 		// status
+		span.SetAttribute("http.status_code", response.StatusCode)
 		w.WriteHeader(response.StatusCode)
 
 		// body
@@ -477,7 +3272,68 @@ func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, config
 	}
 }
 
-func writeBasketResponse(w http.ResponseWriter, r *RequestData, name string, basket Basket) {
+// forward forwards collected request and records the result of forwarding with the request in basket,
+// the status is recorded as response status as well if forward response is proxied back to the client;
+// the original request is forwarded, while only the stored (possibly redacted) request is published with events;
+// the forwarded request continues the trace of given span
+func forward(request *RequestData, original *RequestData, config BasketConfig, name string, basket Basket,
+	proxy bool, span *traceSpan) (*http.Response, error) {
+	client := span.Child("forward", spanKindClient)
+	client.SetAttribute("basket", name)
+	client.SetAttribute("http.method", original.Method)
+	start := time.Now()
+	response, err := client.Propagate(original).Forward(getHTTPClient(config.InsecureTLS), config, name)
+	latency := time.Since(start).Nanoseconds() / toMs
+	if err != nil {
+		client.SetError(err)
+		metrics.Count("forwards.errors", 1, "basket:"+name)
+	} else {
+		client.SetAttribute("http.status_code", response.StatusCode)
+		if response.StatusCode >= http.StatusInternalServerError {
+			client.SetError(fmt.Errorf("forward response status: %d", response.StatusCode))
+			metrics.Count("forwards.errors", 1, "basket:"+name)
+		}
+		metrics.Count("forwards", 1, "basket:"+name, "status:"+strconv.Itoa(response.StatusCode))
+		metrics.Timing("forwards.latency", time.Since(start), "basket:"+name)
+	}
+	client.End()
+	serviceCounters.Count(CounterForwards, start)
+	if err != nil || response.StatusCode >= http.StatusInternalServerError {
+		serviceCounters.Count(CounterForwardErrors, start)
+	}
+	slowOps.Observe(SlowForward, name, original.Method+" "+original.Path, time.Since(start))
+
+	if err != nil {
+		webhooks.Publish(basket, WebhookEvent{Event: EventForwardFailed, Basket: name, Request: request, Error: err.Error()})
+	} else if response.StatusCode >= http.StatusInternalServerError {
+		webhooks.Publish(basket, WebhookEvent{Event: EventForwardFailed, Basket: name, Request: request,
+			Error: fmt.Sprintf("forward response status: %d", response.StatusCode)})
+	}
+
+	store, started := span.Child("storage.update", spanKindInternal), time.Now()
+	basket.UpdateRequest(request.ID, func(data *RequestData) {
+		if err != nil {
+			data.ForwardError = err.Error()
+			if proxy {
+				data.ResponseStatus = http.StatusInternalServerError
+			}
+			return
+		}
+		data.ForwardLatency = latency
+		data.ForwardStatus = response.StatusCode
+		if proxy {
+			data.ResponseStatus = response.StatusCode
+		}
+	})
+	store.End()
+	slowOps.Observe(SlowStorage, name, "update forward result", time.Since(started))
+
+	return response, err
+}
+
+// writeBasketResponse writes configured response of basket and returns HTTP status of the response, response script
+// is traced within given span
+func writeBasketResponse(w http.ResponseWriter, r *RequestData, name string, basket Basket, span *traceSpan) int {
 	response := basket.GetResponse(r.Method)
 	if response == nil {
 		response = &defaultResponse
@@ -489,33 +3345,47 @@ func writeBasketResponse(w http.ResponseWriter, r *RequestData, name string, bas
 	}
 
 	// body
-	if response.IsTemplate && len(response.Body) == 0 {
+	if response.IsTemplate && len(response.Body) > 0 {
 		// template
-		t, err := template.New(name + "-" + r.Method).Parse(response.Body)
+		t, err := template.New(name + "-" + r.Method).Funcs(templateFuncs(basket.GetSecrets())).Parse(response.Body)
 		if err != nil {
 			// invalid template
 			http.Error(w, "Error in "+err.Error(), http.StatusInternalServerError)
-		} else {
-			// status
-			w.WriteHeader(response.Status)
-			// templated body
-			q, _ := url.ParseQuery(r.Query)
-			t.Execute(w, q)
+			return http.StatusInternalServerError
 		}
+		// status
+		w.WriteHeader(response.Status)
+		// templated body
+		q, _ := url.ParseQuery(r.Query)
+		t.Execute(w, q)
 	} else if response.IsScript && len(response.Body) > 0 {
-		res, err := scriptResponse(name, response.Body, r)
+		script := span.Child("script.response", spanKindInternal)
+		res, err := scriptResponse(name, response.Body, r, basket.GetSecrets())
+		script.SetError(err)
+		script.End()
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintln(w, err)
+			return http.StatusBadRequest
 		}
 		w.WriteHeader(http.StatusAccepted)
 		w.Write([]byte(res))
+		return http.StatusAccepted
 	} else {
 		// status
 		w.WriteHeader(response.Status)
 		// plain body
 		w.Write([]byte(response.Body))
 	}
+
+	return response.Status
+}
+
+// templateFuncs declares functions available in response templates, e.g. {{env "API_KEY"}}
+func templateFuncs(env map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string) string { return env[name] },
+	}
 }
 
 func sanitizeForLog(raw string) string {
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"testing/iotest"
@@ -28,8 +30,8 @@ func TestWriteJSON_Error(t *testing.T) {
 	w := httptest.NewRecorder()
 	writeJSON(w, http.StatusOK, nil, fmt.Errorf("Failed to generate JSON: whatever reason"))
 	assert.Equal(t, 500, w.Code, "wrong HTTP response code")
-	assert.Equal(t, "Failed to generate JSON: whatever reason\n", w.Body.String(), "wrong HTTP response body")
-	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+	assert.Equal(t, "Failed to generate JSON: whatever reason", errorMessage(t, w), "wrong HTTP response body")
+	assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
 }
 
 func TestParseInt(t *testing.T) {
@@ -107,7 +109,7 @@ func TestCreateBasket_Forbidden(t *testing.T) {
 
 		// validate response: 403 - forbidden
 		assert.Equal(t, 403, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "This basket name conflicts with reserved system path: "+basket+"\n", w.Body.String(), "wrong error message")
+		assert.Equal(t, "This basket name conflicts with reserved system path: "+basket, errorMessage(t, w), "wrong error message")
 		// validate database
 		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
 	}
@@ -124,7 +126,7 @@ func TestCreateBasket_InvalidName(t *testing.T) {
 
 		// validate response: 400 - Bad Request
 		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+		assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String(), errorMessage(t, w),
 			"wrong error message")
 		// validate database
 		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
@@ -278,7 +280,7 @@ func TestCreateBasket_ReadTimeout(t *testing.T) {
 
 		// validate response: 500 - Internal Server Error
 		assert.Equal(t, 500, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "timeout\n", "error message is incomplete")
+		assert.Contains(t, errorMessage(t, w), "timeout", "error message is incomplete")
 		// validate database
 		assert.Nil(t, basketsDb.Get(basket), "basket '%v' should not be created", basket)
 	}
@@ -433,7 +435,7 @@ func TestGetBasket_BadRequest(t *testing.T) {
 
 		// validate response: 400 - Bad Request
 		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+		assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String(), errorMessage(t, w),
 			"wrong error message")
 	}
 }
@@ -622,6 +624,59 @@ func TestUpdateBasket_InvalidConfig(t *testing.T) {
 	}
 }
 
+func TestUpdateBasket_CreateMissing(t *testing.T) {
+	basket := "update06"
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket, strings.NewReader("{\"capacity\":30}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasket(w, r, ps)
+
+		// validate response: 201 - Created
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse UpdateBasket response") && assert.NotNil(t, basketsDb.Get(basket)) {
+			assert.NotEmpty(t, auth.Token, "basket token is expected")
+			assert.Equal(t, 30, basketsDb.Get(basket).Config().Capacity, "wrong basket capacity")
+
+			// repeated request updates basket, token is required
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket, strings.NewReader("{\"capacity\":40}"))
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				UpdateBasket(w, r, ps)
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			}
+
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket, strings.NewReader("{\"capacity\":40}"))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				UpdateBasket(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				assert.Empty(t, w.Body.String(), "token is not expected")
+				assert.Equal(t, 40, basketsDb.Get(basket).Config().Capacity, "wrong basket capacity")
+			}
+		}
+	}
+}
+
+func TestUpdateBasket_CreateInvalidName(t *testing.T) {
+	for name, status := range map[string]int{"api": 403, "bad name": 400} {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: name})
+		r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+url.PathEscape(name), strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w := httptest.NewRecorder()
+			UpdateBasket(w, r, ps)
+			assert.Equal(t, status, w.Code, "wrong HTTP result code for name: %s", name)
+			assert.Nil(t, basketsDb.Get(name), "basket is not expected")
+		}
+	}
+}
+
 func TestDeleteBasket(t *testing.T) {
 	basket := "delete01"
 
@@ -698,6 +753,88 @@ func TestDeleteBasket_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestRenameBasket(t *testing.T) {
+	basket := "rename01"
+	newName := "rename01new"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket, "data", "text/plain"))
+		basketsDb.Create("rename02", BasketConfig{Capacity: 20})
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+		rename := func(body string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/rename", strings.NewReader(body))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			RenameBasket(w, r, ps)
+			return w
+		}
+
+		// HTTP 409 - conflict
+		assert.Equal(t, 409, rename(`{"name":"rename02"}`).Code, "wrong HTTP result code")
+		// HTTP 403 - forbidden
+		assert.Equal(t, 403, rename(`{"name":"`+serviceAPIPath+`"}`).Code, "wrong HTTP result code")
+		// HTTP 400 - bad request
+		assert.Equal(t, 400, rename(`{"name":"bad name"}`).Code, "wrong HTTP result code")
+		assert.Equal(t, 400, rename(`{"name":`).Code, "wrong HTTP result code")
+		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
+
+		// HTTP 204 - no content
+		assert.Equal(t, 204, rename(`{"name":"`+newName+`"}`).Code, "wrong HTTP result code")
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' is not expected", basket)
+		if renamed := basketsDb.Get(newName); assert.NotNil(t, renamed, "basket '%v' is expected", newName) {
+			assert.True(t, renamed.Authorize(auth.Token), "basket token is expected to be preserved")
+			assert.Equal(t, 1, renamed.Size(), "collected requests are expected to be preserved")
+		}
+	}
+}
+
+func TestCloneBasket(t *testing.T) {
+	basket := "clone01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		basketsDb.Get(basket).SetResponse("POST", ResponseConfig{Status: 201, Body: "created"})
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket, "data", "text/plain"))
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+		clone := func(body string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/clone", strings.NewReader(body))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			CloneBasket(w, r, ps)
+			return w
+		}
+
+		// HTTP 201 - created
+		w := clone(`{"name":"clone01a","include_requests":true}`)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		cloneAuth := new(BasketAuth)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), cloneAuth)) {
+			assert.NotEqual(t, auth.Token, cloneAuth.Token, "clone is expected to have own token")
+			if cloned := basketsDb.Get("clone01a"); assert.NotNil(t, cloned, "cloned basket is expected") {
+				assert.True(t, cloned.Authorize(cloneAuth.Token), "wrong token of cloned basket")
+				assert.Equal(t, 1, cloned.Size(), "collected requests are expected to be copied")
+				assert.NotNil(t, cloned.GetResponse("POST"), "response is expected to be copied")
+			}
+		}
+
+		// without requests
+		assert.Equal(t, 201, clone(`{"name":"clone01b"}`).Code, "wrong HTTP result code")
+		assert.Equal(t, 0, basketsDb.Get("clone01b").Size(), "requests are not expected")
+
+		// HTTP 409 - conflict
+		assert.Equal(t, 409, clone(`{"name":"clone01a"}`).Code, "wrong HTTP result code")
+		// HTTP 400 - bad request
+		assert.Equal(t, 400, clone(`{"name":"bad name"}`).Code, "wrong HTTP result code")
+	}
+}
+
 func TestGetBaskets(t *testing.T) {
 	// create 5 baskets
 	for i := 0; i < 5; i++ {
@@ -776,8 +913,100 @@ func TestGetStats(t *testing.T) {
 			assert.NotEmpty(t, stats.TopBasketsBySize, "top baskets are expected")
 			assert.True(t, stats.BasketsCount > 0, "baskets count should be greater than 0")
 			assert.True(t, stats.EmptyBasketsCount > 0, "empty baskets count should be greater than 0")
+			assert.Equal(t, DbTypeMemory, stats.StorageBackend, "wrong storage backend")
+			assert.True(t, stats.RequestsLastDay >= stats.RequestsLastHour, "wrong counts of recent requests")
+		}
+	}
+}
+
+func TestSearchRequests(t *testing.T) {
+	// create 3 baskets and collect some requests
+	for i := 0; i < 3; i++ {
+		basket := fmt.Sprintf("forsearch0%v", i)
+		r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w := httptest.NewRecorder()
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+			CreateBasket(w, r, ps)
+			assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+			for j := 0; j <= i; j++ {
+				AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket,
+					fmt.Sprintf("{\"payment_id\":\"pay-x7%v%v\"}", i, j), "application/json"))
+			}
+		}
+	}
+
+	for query, expected := range map[string][]string{
+		"q=pay-x7":              {"forsearch00", "forsearch01", "forsearch02"},
+		"q=pay-x71":             {"forsearch01"},
+		"q=pay-x7&max=1&skip=1": {"forsearch01"},
+		"filter=" + url.QueryEscape(`body:"pay-x72" AND body:"x721"`): {"forsearch02"},
+		"q=pay-x79": {},
+	} {
+		r, err := http.NewRequest("GET", "http://localhost:55555/api/search?"+query, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", serverConfig.MasterToken)
+			w := httptest.NewRecorder()
+			SearchRequests(w, r, make(httprouter.Params, 0))
+			// HTTP 200 - OK
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+			results := new(SearchResultsPage)
+			err = json.Unmarshal(w.Body.Bytes(), results)
+			if assert.NoError(t, err) {
+				names := make([]string, 0)
+				for _, basket := range results.Baskets {
+					assert.NotEmpty(t, basket.Requests, "found requests are expected")
+					names = append(names, basket.Basket)
+				}
+				assert.Equal(t, expected, names, "wrong baskets found for query: %s", query)
+			}
+		}
+	}
+
+	// requests per basket are limited
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/search?q=pay-x72&max_requests=2", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		results := new(SearchResultsPage)
+		err = json.Unmarshal(w.Body.Bytes(), results)
+		if assert.NoError(t, err) && assert.Len(t, results.Baskets, 1, "wrong number of found baskets") {
+			assert.Len(t, results.Baskets[0].Requests, 2, "wrong number of found requests")
+			assert.True(t, results.Baskets[0].HasMore, "more requests are expected")
+			assert.False(t, results.HasMore, "no more baskets are expected")
 		}
 	}
+
+	// search criteria are required
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/search", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		// HTTP 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	}
+}
+
+func TestSearchRequests_Unauthorized(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/search?q=test", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		// no authorization at all: 401 - unauthorized
+		w := httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+
+		// invalid master token: 401 - unauthorized
+		r.Header.Add("Authorization", "123-wrong-token")
+		w = httptest.NewRecorder()
+		SearchRequests(w, r, make(httprouter.Params, 0))
+		assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+	}
 }
 
 func TestGetStats_Unauthorized(t *testing.T) {
@@ -818,6 +1047,26 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestGetOpenAPISpec(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/openapi.json", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		GetOpenAPISpec(w, r, make(httprouter.Params, 0))
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+
+		spec := make(map[string]interface{})
+		err = json.Unmarshal(w.Body.Bytes(), &spec)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "3.0.3", spec["openapi"], "wrong OpenAPI version")
+			paths, _ := spec["paths"].(map[string]interface{})
+			assert.Contains(t, paths, "/api/baskets/{basket}/requests", "requests operations are expected")
+			assert.Contains(t, paths, "/api/version", "version operation is expected")
+		}
+	}
+}
+
 func TestGetBaskets_Query(t *testing.T) {
 	// create 10 baskets
 	for i := 0; i < 10; i++ {
@@ -885,6 +1134,63 @@ func TestGetBaskets_Page(t *testing.T) {
 	}
 }
 
+func TestGetBaskets_Cursor(t *testing.T) {
+	// create 3 baskets
+	for i := 0; i < 3; i++ {
+		basket := fmt.Sprintf("names3%v", i)
+		r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w := httptest.NewRecorder()
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+			CreateBasket(w, r, ps)
+			assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		}
+	}
+
+	// iterate all names with cursor
+	seen := make(map[string]int)
+	query := "max=2"
+	for len(query) > 0 {
+		r, err := http.NewRequest("GET", "http://localhost:55555/api/baskets?"+query, strings.NewReader(""))
+		if !assert.NoError(t, err) {
+			break
+		}
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		GetBaskets(w, r, make(httprouter.Params, 0))
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		names := new(BasketNamesPage)
+		err = json.Unmarshal(w.Body.Bytes(), names)
+		if !assert.NoError(t, err) {
+			break
+		}
+		for _, name := range names.Names {
+			seen[name]++
+		}
+		query = ""
+		if names.HasMore {
+			query = "max=2&cursor=" + names.NextCursor
+		}
+	}
+
+	assert.Len(t, seen, basketsDb.Size(), "all baskets are expected")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("names3%v", i)], "basket is expected exactly once")
+	}
+
+	// invalid cursor
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/baskets?cursor=abc!", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		GetBaskets(w, r, make(httprouter.Params, 0))
+		// HTTP 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	}
+}
+
 func TestGetBasketRequests(t *testing.T) {
 	basket := "getreq01"
 
@@ -980,8 +1286,8 @@ func TestGetBasketRequests_Query(t *testing.T) {
 	}
 }
 
-func TestGetBasketRequests_Page(t *testing.T) {
-	basket := "getreq03"
+func TestGetBasketRequests_QueryRegex(t *testing.T) {
+	basket := "getreq04"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -990,21 +1296,20 @@ func TestGetBasketRequests_Page(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
 			// collect some HTTP requests
-			for i := 1; i <= 300; i++ {
+			for i := 1; i <= 25; i++ {
 				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
 					fmt.Sprintf("req%v data ...", i), "text/plain")
 				AcceptBasketRequests(httptest.NewRecorder(), req)
 			}
 
-			// get requests
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?max=5&skip=5", strings.NewReader(""))
+			// find requests with body "req10 ..." - "req19 ..."
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?q="+url.QueryEscape("^req1[0-9] ")+"&in=body&query_type=regex", strings.NewReader(""))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
 				w = httptest.NewRecorder()
@@ -1012,25 +1317,30 @@ func TestGetBasketRequests_Page(t *testing.T) {
 				// HTTP 200 - OK
 				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-				requests := new(RequestsPage)
+				requests := new(RequestsQueryPage)
 				err = json.Unmarshal(w.Body.Bytes(), requests)
 				if assert.NoError(t, err) {
-					// validate response
-					assert.NotEmpty(t, requests.Requests, "requests are expected")
-					assert.Len(t, requests.Requests, 5, "unexpected number of returned requests")
-					assert.Equal(t, requests.Count, 200, "wrong count of requests")
-					assert.Equal(t, requests.TotalCount, 300, "wrong total count of requests")
-					assert.True(t, requests.HasMore, "more requests are expected")
-
-					assert.Contains(t, requests.Requests[0].Body, "req295", "wrong request")
+					assert.Len(t, requests.Requests, 10, "unexpected number of returned requests")
+					assert.False(t, requests.HasMore, "no more requests are expected")
 				}
 			}
+
+			// invalid regular expression
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?q="+url.QueryEscape("req[")+"&query_type=regex", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid regular expression", "wrong error message")
+			}
 		}
 	}
 }
 
-func TestClearBasket(t *testing.T) {
-	basket := "clear01"
+func TestGetBasketRequests_DateRange(t *testing.T) {
+	basket := "getreq05"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1039,30 +1349,26 @@ func TestClearBasket(t *testing.T) {
 
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
 
 		// get auth token
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
 			// collect some HTTP requests
-			for i := 1; i <= 25; i++ {
+			for i := 1; i <= 10; i++ {
 				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
 					fmt.Sprintf("req%v data ...", i), "text/plain")
 				AcceptBasketRequests(httptest.NewRecorder(), req)
 			}
 
-			// clear basket
-			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				w = httptest.NewRecorder()
-				ClearBasket(w, r, ps)
-				// HTTP 204 - no content
-				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
-
-				// get requests
-				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+			hourAgo := time.Now().Add(-time.Hour)
+			for query, expected := range map[string]int{
+				"from=" + url.QueryEscape(hourAgo.Format(time.RFC3339)):                                          10,
+				"to=" + url.QueryEscape(hourAgo.Format(time.RFC3339)):                                            0,
+				fmt.Sprintf("from=%d&q=req1", hourAgo.UnixNano()/toMs):                                           2,
+				fmt.Sprintf("from=%d&to=%d", hourAgo.UnixNano()/toMs, time.Now().Add(time.Hour).UnixNano()/toMs): 10,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
 				if assert.NoError(t, err) {
 					r.Header.Add("Authorization", auth.Token)
 					w = httptest.NewRecorder()
@@ -1070,113 +1376,1443 @@ func TestClearBasket(t *testing.T) {
 					// HTTP 200 - OK
 					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
 
-					requests := new(RequestsPage)
+					requests := new(RequestsQueryPage)
 					err = json.Unmarshal(w.Body.Bytes(), requests)
 					if assert.NoError(t, err) {
-						// validate response
-						assert.Empty(t, requests.Requests, "requests are not expected")
-						assert.Equal(t, 0, requests.Count, "wrong count of requests")
-						assert.Equal(t, 25, requests.TotalCount, "wrong total count of requests")
-						assert.False(t, requests.HasMore, "no more requests are expected")
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for query: %s", query)
 					}
 				}
 			}
+
+			// invalid date
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?from=yesterday", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid 'from' parameter", "wrong error message")
+			}
 		}
 	}
 }
 
-func TestAcceptBasketRequests_NotFound(t *testing.T) {
-	basket := "accept02"
-	req := createTestPOSTRequest("http://localhost:55555/"+basket, "super-data", "text/plain")
-	w := httptest.NewRecorder()
-	AcceptBasketRequests(w, req)
-	// HTTP 404 - not found
-	assert.Equal(t, 404, w.Code, "wrong HTTP result code")
-}
-
-func TestAcceptBasketRequests_BadRequest(t *testing.T) {
-	basket := "accept03%20"
-	req := createTestPOSTRequest("http://localhost:55555/"+basket, "my data", "text/plain")
-	w := httptest.NewRecorder()
-	AcceptBasketRequests(w, req)
-	// HTTP 400 - Bad Request
-	assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-	assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
-		"wrong error message")
-}
-
-func TestForwardToWeb(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/", strings.NewReader(""))
-	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
-		ForwardToWeb(w, r, make(httprouter.Params, 0))
-
-		// validate response: 302 - Found
-		assert.Equal(t, 302, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "/"+serviceUIPath, w.Header().Get("Location"), "wrong Location header")
-	}
-}
+func TestGetBasketRequests_MethodAndPath(t *testing.T) {
+	basket := "getreq06"
 
-func TestWebIndexPage(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/web", strings.NewReader(""))
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
-		WebIndexPage(w, r, make(httprouter.Params, 0))
 
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Baskets</title>", "HTML index page with baskets is expected")
-	}
-}
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-func TestWebBasketPage(t *testing.T) {
-	basket := "test"
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 12; i++ {
+				path := "/data"
+				if i%3 == 0 {
+					path = fmt.Sprintf("/callback/%v", i)
+				}
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v%v", basket, path),
+					fmt.Sprintf("req%v data ...", i), "text/plain")
+				if i%2 == 0 {
+					req.Method = "PUT"
+				}
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			for query, expected := range map[string]int{
+				"method=put":                                   6,
+				"path=" + url.QueryEscape("/callback/*"):       4,
+				"method=POST&path=" + url.QueryEscape("/c*/*"): 2,
+				"method=DELETE":                                0,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for query: %s", query)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_Header(t *testing.T) {
+	basket := "getreq07"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 10; i++ {
+				contentType := "text/plain"
+				if i <= 4 {
+					contentType = "application/json"
+				}
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("req%v data ...", i), contentType)
+				if i%2 == 0 {
+					req.Header.Add("X-Trace", "json")
+				}
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			for query, expected := range map[string]int{
+				"header=" + url.QueryEscape("Content-Type:application/json"): 4,
+				"header=X-Trace": 5,
+				"header=" + url.QueryEscape("Content-Type:application/json") + "&header=X-Trace":    2,
+				"header=" + url.QueryEscape("Content-Type:application/json") + "&q=json&in=headers": 4,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for query: %s", query)
+					}
+				}
+			}
+
+			// invalid header filter
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?header=:json", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_QueryJSONPath(t *testing.T) {
+	basket := "getreq08"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 10; i++ {
+				event := "payment.succeeded"
+				if i%5 == 0 {
+					event = "payment.failed"
+				}
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/hooks", basket),
+					fmt.Sprintf("{\"event\":{\"type\":\"%v\",\"id\":%v}}", event, i), "application/json")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+			// not a JSON
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hooks",
+				"payment.failed", "text/plain"))
+
+			for expr, expected := range map[string]int{
+				`$.event.type == "payment.failed"`: 2,
+				`$.event.id > 7`:                   3,
+				`$.event`:                          10,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?query_type=jsonpath&q="+url.QueryEscape(expr), strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for expression: %s", expr)
+					}
+				}
+			}
+
+			// invalid expression
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?query_type=jsonpath&q=event.type", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid JSONPath", "wrong error message")
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_Filter(t *testing.T) {
+	basket := "getreq09"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 12; i++ {
+				path := "/data"
+				if i%3 == 0 {
+					path = "/hooks/orders"
+				}
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v%v", basket, path),
+					fmt.Sprintf("{\"order_id\":%v}", i), "application/json")
+				if i%2 == 0 {
+					req.Method = "PUT"
+				}
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			for query, expected := range map[string]int{
+				"filter=" + url.QueryEscape(`method:POST AND path:/hooks/* AND body~"order_id"`):    2,
+				"filter=" + url.QueryEscape(`path:/hooks/* OR json:"$.order_id == 1"`):              5,
+				"filter=" + url.QueryEscape(`NOT method:PUT`) + "&path=" + url.QueryEscape("/data"): 4,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for query: %s", query)
+					}
+				}
+			}
+
+			for query, message := range map[string]string{
+				"filter=" + url.QueryEscape("method:POST AND"): "invalid filter",
+				"filter=method:POST&q=test":                    "cannot be combined",
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 400 - Bad Request
+					assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+					assert.Contains(t, w.Body.String(), message, "wrong error message")
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_QueryOptions(t *testing.T) {
+	basket := "getreq10"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for _, body := range []string{"Token expired", "token=abc", "TOKENS: 3", "no credentials"} {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v", basket), body, "text/plain"))
+			}
+
+			for query, expected := range map[string]int{
+				"q=token&in=body":                                   1,
+				"q=token&in=body&ignore_case=true":                  3,
+				"q=token&in=body&ignore_case=1&whole_word=true":     2,
+				"q=token&in=body&whole_word=true":                   1,
+				"q=tok.n&in=body&query_type=regex&ignore_case=true": 3,
+				"filter=body:token":                                 1,
+				"filter=body:Token&ignore_case=true":                3,
+				"filter=body:token&ignore_case=1&whole_word=true":   2,
+				"filter=body~tok.n&ignore_case=true":                3,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						assert.Len(t, requests.Requests, expected, "unexpected number of returned requests for query: %s", query)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_Sort(t *testing.T) {
+	basket := "getreq11"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for _, body := range []string{"first", "the longest", "last"} {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v", basket), body, "text/plain"))
+			}
+
+			for query, expected := range map[string]string{
+				"sort=newest":                        "last",
+				"sort=oldest":                        "first",
+				"sort=content_length":                "the longest",
+				"sort=oldest&q=last":                 "last",
+				"sort=content_length&skip=1&max=1":   "first",
+				"sort=forward_latency&method=DELETE": "",
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						if len(expected) == 0 {
+							assert.Empty(t, requests.Requests, "unexpected requests for query: %s", query)
+						} else if assert.NotEmpty(t, requests.Requests, "requests are expected for query: %s", query) {
+							assert.Equal(t, expected, requests.Requests[0].Body, "wrong first request for query: %s", query)
+						}
+					}
+				}
+			}
+
+			// invalid sort order
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?sort=random", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "unknown sort order", "wrong error message")
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_ETag(t *testing.T) {
+	basket := "getreq18"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		AcceptBasketRequests(httptest.NewRecorder(),
+			createTestPOSTRequest("http://localhost:55555/"+basket, "first", "text/plain"))
+
+		get := func(query string, ifNoneMatch string) *httptest.ResponseRecorder {
+			r, _ := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests"+query, nil)
+			r.Header.Add("Authorization", auth.Token)
+			if len(ifNoneMatch) > 0 {
+				r.Header.Add("If-None-Match", ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			GetBasketRequests(w, r, ps)
+			return w
+		}
+
+		w := get("", "")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(t, etag, "ETag is expected")
+		assert.NotEmpty(t, w.Header().Get("Last-Modified"), "Last-Modified is expected")
+
+		// unchanged basket
+		w = get("", etag)
+		assert.Equal(t, 304, w.Code, "wrong HTTP result code")
+		assert.Empty(t, w.Body.String(), "no content is expected")
+		assert.Equal(t, etag, w.Header().Get("ETag"), "the same ETag is expected")
+		assert.Equal(t, 304, get("", "\"other\", W/"+etag).Code, "weak ETag in a list is expected to match")
+		assert.Equal(t, 304, get("", "*").Code, "any ETag is expected to match")
+
+		// different query
+		w = get("?max=1", etag)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.NotEqual(t, etag, w.Header().Get("ETag"), "different ETag is expected for different query")
+
+		// collected request changes the listing
+		time.Sleep(2 * time.Millisecond)
+		AcceptBasketRequests(httptest.NewRecorder(),
+			createTestPOSTRequest("http://localhost:55555/"+basket, "second", "text/plain"))
+		w = get("", etag)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.NotEqual(t, etag, w.Header().Get("ETag"), "new ETag is expected")
+	}
+}
+
+func TestGetBasketAggregation(t *testing.T) {
+	basket := "getreq12"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// configure response for PUT requests
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/PUT",
+				strings.NewReader("{\"status\":409}"))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				psr := append(ps, httprouter.Param{Key: "method", Value: "PUT"})
+				w = httptest.NewRecorder()
+				UpdateBasketResponse(w, r, psr)
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			}
+
+			// collect some HTTP requests
+			for i := 1; i <= 5; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/items", basket), "test", "text/plain")
+				if i%2 == 0 {
+					req.Method = "PUT"
+				}
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			for query, expected := range map[string]map[string]int{
+				"by=method":                 {"POST": 3, "PUT": 2},
+				"by=status":                 {"200": 3, "409": 2},
+				"by=path":                   {"/items": 5},
+				"by=status&method=PUT":      {"409": 2},
+				"by=path&q=nothing+matches": {},
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/aggregate?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketAggregation(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					aggregation := new(RequestsAggregation)
+					err = json.Unmarshal(w.Body.Bytes(), aggregation)
+					if assert.NoError(t, err) {
+						groups := make(map[string]int)
+						for _, group := range aggregation.Groups {
+							groups[group.Key] = group.Count
+						}
+						assert.Equal(t, expected, groups, "wrong groups for query: %s", query)
+					}
+				}
+			}
+
+			// unknown aggregation
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/aggregate?by=size", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketAggregation(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestGetBasketStats(t *testing.T) {
+	basket := "getreq19"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 3; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/items", basket), "test", "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/stats?interval=minute", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketStats(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				stats := new(BasketRequestsStats)
+				err = json.Unmarshal(w.Body.Bytes(), stats)
+				if assert.NoError(t, err) {
+					assert.Equal(t, 3, stats.Count, "wrong number of requests")
+					assert.Equal(t, "minute", stats.Interval, "wrong interval")
+					assert.Equal(t, []*RequestsGroup{{Key: "POST", Count: 3}}, stats.Methods, "wrong method breakdown")
+					assert.Equal(t, []*RequestsGroup{{Key: "200", Count: 3}}, stats.Statuses, "wrong status breakdown")
+					assert.Equal(t, float64(4), stats.AvgBodySize, "wrong average body size")
+				}
+			}
+
+			// unknown interval
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/stats?interval=week", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketStats(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+
+			// unauthorized
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/stats", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				w = httptest.NewRecorder()
+				GetBasketStats(w, r, ps)
+				// HTTP 401 - Unauthorized
+				assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestGetBasketTimeline(t *testing.T) {
+	basket := "getreq20"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 2; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/items", basket), "test", "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/timeline?range=hour", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketTimeline(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				timeline := new(RequestsTimeline)
+				err = json.Unmarshal(w.Body.Bytes(), timeline)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "hour", timeline.Range, "wrong range")
+					assert.Len(t, timeline.Counts, 60, "wrong number of buckets")
+					assert.Equal(t, 2, timeline.Total, "wrong number of requests")
+				}
+			}
+
+			// unknown range
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/timeline?range=year", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketTimeline(w, r, ps)
+				// HTTP 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_Cursor(t *testing.T) {
+	basket := "getreq13"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 5; i++ {
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v", basket), fmt.Sprintf("req%v", i), "text/plain"))
+			}
+
+			// iterate requests with cursor while new requests are collected
+			bodies := make([]string, 0)
+			query := "max=2"
+			for i := 0; i < 5 && len(query) > 0; i++ {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					page := new(RequestsPage)
+					err = json.Unmarshal(w.Body.Bytes(), page)
+					if assert.NoError(t, err) {
+						for _, req := range page.Requests {
+							bodies = append(bodies, req.Body)
+						}
+						query = ""
+						if page.HasMore {
+							query = "max=2&cursor=" + page.NextCursor
+						}
+					}
+				}
+
+				AcceptBasketRequests(httptest.NewRecorder(),
+					createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v", basket), "new", "text/plain"))
+			}
+			assert.Equal(t, []string{"req5", "req4", "req3", "req2", "req1"}, bodies, "wrong requests iterated with cursor")
+
+			// invalid cursor
+			for _, query := range []string{"cursor=abc!", "cursor=" + encodeCursor("x"), "cursor=" + encodeCursor("3") + "&sort=oldest"} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 400 - Bad Request
+					assert.Equal(t, 400, w.Code, "wrong HTTP result code for query: %s", query)
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketRequests_Highlight(t *testing.T) {
+	basket := "getreq14"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			AcceptBasketRequests(httptest.NewRecorder(),
+				createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v", basket), "status: payment failed", "text/plain"))
+
+			for query, expected := range map[string]int{
+				"q=failed&in=body&highlight=true": 1,
+				"q=failed&in=body":                0,
+			} {
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests?"+query, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					page := new(RequestsQueryPage)
+					err = json.Unmarshal(w.Body.Bytes(), page)
+					if assert.NoError(t, err) && assert.Len(t, page.Requests, 1, "wrong number of found requests") {
+						if assert.Len(t, page.Highlights, expected, "wrong highlights for query: %s", query) && expected > 0 {
+							if assert.Len(t, page.Highlights[0], 1, "wrong number of matches") {
+								match := page.Highlights[0][0]
+								assert.Equal(t, "body", match.Field)
+								assert.Equal(t, "failed", page.Requests[0].Body[match.Start:match.End])
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketRequest(t *testing.T) {
+	basket := "getreq15"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		for i := 1; i <= 3; i++ {
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest(
+				fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		getRequest := func(id string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/"+id, strings.NewReader(""))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			ps := append(make(httprouter.Params, 0),
+				httprouter.Param{Key: "basket", Value: basket}, httprouter.Param{Key: "id", Value: id})
+			w := httptest.NewRecorder()
+			GetBasketRequest(w, r, ps)
+			return w
+		}
+
+		w := getRequest("2")
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		request := new(RequestData)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+			assert.Equal(t, 2, request.ID, "wrong request ID")
+			assert.Equal(t, "req2", request.Body, "wrong request body")
+			assert.Equal(t, 200, request.ResponseStatus, "wrong response status")
+		}
+
+		// HTTP 404 - not found
+		assert.Equal(t, 404, getRequest("42").Code, "wrong HTTP result code")
+		// HTTP 400 - bad request
+		w = getRequest("abc")
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "invalid request ID: abc", errorMessage(t, w), "wrong error message")
+	}
+}
+
+func TestExportBasketRequests(t *testing.T) {
+	basket := "getreq16"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		for i := 1; i <= 3; i++ {
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest(
+				fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+
+		export := func(query string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/export?"+query, strings.NewReader(""))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+			w := httptest.NewRecorder()
+			ExportBasketRequests(w, r, ps)
+			return w
+		}
+
+		w := export("format=har")
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "attachment; filename=\""+basket+".har\"", w.Header().Get("Content-Disposition"), "wrong Content-Disposition")
+		archive := new(harArchive)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), archive)) && assert.Len(t, archive.Log.Entries, 3, "wrong number of entries") {
+			assert.Equal(t, "http://localhost:55555/"+basket+"/data?id=1", archive.Log.Entries[0].Request.URL, "wrong request URL")
+			assert.Equal(t, 200, archive.Log.Entries[0].Response.Status, "wrong response status")
+		}
+
+		w = export("format=postman")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "attachment; filename=\""+basket+".postman_collection.json\"", w.Header().Get("Content-Disposition"),
+			"wrong Content-Disposition")
+		collection := new(postmanCollection)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), collection)) {
+			assert.Len(t, collection.Item, 3, "wrong number of items")
+		}
+
+		// requests may be exported for another target
+		w = export("format=curl&target=" + url.QueryEscape("http://localhost:8080"))
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "text/plain; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+		assert.Contains(t, w.Body.String(), "curl -X 'POST' 'http://localhost:8080/data?id=3'", "wrong curl command")
+		assert.Equal(t, 3, strings.Count(w.Body.String(), "curl -X"), "wrong number of curl commands")
+
+		w = export("format=curl&target=localhost")
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "invalid target URL: localhost", errorMessage(t, w), "wrong error message")
+
+		// requests are streamed as newline delimited JSON from newest to oldest
+		w = export("format=ndjson&path=/data")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"), "wrong Content-Type")
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		if assert.Len(t, lines, 3, "wrong number of lines") {
+			assert.Contains(t, lines[0], `"body":"req3"`, "newest request is expected first")
+		}
+		assert.Equal(t, 400, export("format=ndjson&sort=oldest").Code, "wrong HTTP result code")
+
+		// only found requests are exported
+		w = export("q=req2")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		archive = new(harArchive)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), archive)) && assert.Len(t, archive.Log.Entries, 1, "wrong number of entries") {
+			assert.Equal(t, "req2", archive.Log.Entries[0].Request.PostData.Text, "wrong request body")
+		}
+
+		// HTTP 400 - bad request
+		w = export("format=xml")
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "unknown export format: xml", errorMessage(t, w), "wrong error message")
+	}
+}
+
+func TestImportBasketRequests(t *testing.T) {
+	basket := "getreq17"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		importRequests := func(query string, body string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/import?"+query, strings.NewReader(body))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+			w := httptest.NewRecorder()
+			ImportBasketRequests(w, r, ps)
+			return w
+		}
+
+		har := `{"log":{"version":"1.2","entries":[` +
+			`{"startedDateTime":"2020-09-13T12:26:40Z","request":{"method":"POST","url":"http://example.com/hooks","postData":{"mimeType":"text/plain","text":"one"}}},` +
+			`{"startedDateTime":"2020-09-13T12:26:41Z","request":{"method":"POST","url":"http://example.com/hooks","postData":{"mimeType":"text/plain","text":"two"}}}]}}`
+		w := importRequests("format=har", har)
+		// HTTP 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.JSONEq(t, `{"imported":2}`, w.Body.String(), "wrong import result")
+
+		// imported requests can be searched
+		found := basketsDb.Get(basket).FindRequests(NewTextQuery("two", "body"), 10, 0)
+		if assert.Len(t, found.Requests, 1, "wrong number of found requests") {
+			assert.Equal(t, "/"+basket+"/hooks", found.Requests[0].Path, "wrong request path")
+		}
+
+		// HTTP 400 - bad request
+		assert.Equal(t, 400, importRequests("", "not a HAR").Code, "wrong HTTP result code")
+		assert.Equal(t, 400, importRequests("format=postman", har).Code, "wrong HTTP result code")
+		assert.Equal(t, 2, basketsDb.Get(basket).Size(), "wrong basket size")
+	}
+}
+
+func TestGetBasketRequests_Page(t *testing.T) {
+	basket := "getreq03"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 300; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("req%v data ...", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			// get requests
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"?max=5&skip=5", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketRequests(w, r, ps)
+				// HTTP 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+				requests := new(RequestsPage)
+				err = json.Unmarshal(w.Body.Bytes(), requests)
+				if assert.NoError(t, err) {
+					// validate response
+					assert.NotEmpty(t, requests.Requests, "requests are expected")
+					assert.Len(t, requests.Requests, 5, "unexpected number of returned requests")
+					assert.Equal(t, requests.Count, 200, "wrong count of requests")
+					assert.Equal(t, requests.TotalCount, 300, "wrong total count of requests")
+					assert.True(t, requests.HasMore, "more requests are expected")
+
+					assert.Contains(t, requests.Requests[0].Body, "req295", "wrong request")
+				}
+			}
+		}
+	}
+}
+
+func TestClearBasket(t *testing.T) {
+	basket := "clear01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.NotNil(t, basketsDb.Get(basket), "basket '%v' is expected", basket)
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			// collect some HTTP requests
+			for i := 1; i <= 25; i++ {
+				req := createTestPOSTRequest(fmt.Sprintf("http://localhost:55555/%v/data?id=%v", basket, i),
+					fmt.Sprintf("req%v data ...", i), "text/plain")
+				AcceptBasketRequests(httptest.NewRecorder(), req)
+			}
+
+			// clear basket
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				ClearBasket(w, r, ps)
+				// HTTP 204 - no content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				// get requests
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketRequests(w, r, ps)
+					// HTTP 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+					requests := new(RequestsPage)
+					err = json.Unmarshal(w.Body.Bytes(), requests)
+					if assert.NoError(t, err) {
+						// validate response
+						assert.Empty(t, requests.Requests, "requests are not expected")
+						assert.Equal(t, 0, requests.Count, "wrong count of requests")
+						assert.Equal(t, 25, requests.TotalCount, "wrong total count of requests")
+						assert.False(t, requests.HasMore, "no more requests are expected")
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestClearBasket_Selected(t *testing.T) {
+	basket := "clear02"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		for i := 1; i <= 10; i++ {
+			path := "/data"
+			if i%2 == 0 {
+				path = "/health"
+			}
+			AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest(
+				fmt.Sprintf("http://localhost:55555/%v%v?id=%v", basket, path, i), fmt.Sprintf("req%v", i), "text/plain"))
+		}
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+		deleteRequests := func(query string) *httptest.ResponseRecorder {
+			r, err := http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/requests?"+query, strings.NewReader(""))
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			ClearBasket(w, r, ps)
+			return w
+		}
+
+		// delete by IDs
+		w := deleteRequests("id=1,2&id=3&id=42")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.JSONEq(t, `{"deleted":3}`, w.Body.String(), "wrong deletion result")
+		assert.Equal(t, 7, basketsDb.Get(basket).Size(), "wrong basket size")
+
+		// delete by filter
+		w = deleteRequests("path=/health")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.JSONEq(t, `{"deleted":4}`, w.Body.String(), "wrong deletion result")
+
+		// delete by IDs matching filter only
+		w = deleteRequests("id=5,7&filter=" + url.QueryEscape("body:req5"))
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.JSONEq(t, `{"deleted":1}`, w.Body.String(), "wrong deletion result")
+		page := basketsDb.Get(basket).GetRequests(10, 0)
+		if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+			assert.Equal(t, "req9", page.Requests[0].Body)
+			assert.Equal(t, "req7", page.Requests[1].Body)
+		}
+
+		// invalid IDs
+		w = deleteRequests("id=1,abc")
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "invalid request ID: abc", errorMessage(t, w), "wrong error message")
+		assert.Equal(t, 2, basketsDb.Get(basket).Size(), "requests are not expected to be deleted")
+	}
+}
+
+func TestAcceptBasketRequests_NotFound(t *testing.T) {
+	basket := "accept02"
+	req := createTestPOSTRequest("http://localhost:55555/"+basket, "super-data", "text/plain")
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, req)
+	// HTTP 404 - not found
+	assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+}
+
+func TestAcceptBasketRequests_BadRequest(t *testing.T) {
+	basket := "accept03%20"
+	req := createTestPOSTRequest("http://localhost:55555/"+basket, "my data", "text/plain")
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, req)
+	// HTTP 400 - Bad Request
+	assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "invalid basket name; the name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+		"wrong error message")
+}
+
+func TestForwardToWeb(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ForwardToWeb(w, r, make(httprouter.Params, 0))
+
+		// validate response: 302 - Found
+		assert.Equal(t, 302, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "/"+serviceUIPath, w.Header().Get("Location"), "wrong Location header")
+	}
+}
+
+func TestWebIndexPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/web", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		WebIndexPage(w, r, make(httprouter.Params, 0))
+
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Baskets</title>", "HTML index page with baskets is expected")
+	}
+}
+
+func TestWebBasketPage(t *testing.T) {
+	basket := "test"
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		WebBasketPage(w, r, ps)
+
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Basket: "+basket+"</title>",
+			"HTML page to display basket is expected")
+	}
+}
+
+func TestWebBasketsPage(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+serviceOldAPIPath, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: serviceOldAPIPath})
+		WebBasketPage(w, r, ps)
+
+		// validate response: 200 - OK
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "<title>Request Baskets - Administration</title>",
+			"HTML index page with baskets is expected")
+	}
+}
+
+func TestWebBasketPage_InvalidName(t *testing.T) {
+	basket := ">>>"
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		WebBasketPage(w, r, ps)
+
+		// validate response: 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "Basket name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
+			"wrong error message")
+	}
+}
+
+func TestGetBasketResponse(t *testing.T) {
+	basket := "response01"
+	method := "GET"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+				GetBasketResponse(w, r, ps)
+
+				// validate response: 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+
+				// Default response: HTTP 200 - empty content
+				response := new(ResponseConfig)
+				err = json.Unmarshal(w.Body.Bytes(), response)
+				if assert.NoError(t, err, "Failed to parse GetBasketResponse response") {
+					assert.Equal(t, 200, response.Status, "wrong basket default response status")
+					assert.Empty(t, response.Body, "empty response is expected")
+					assert.Empty(t, response.Headers, "empty headers are expected")
+					assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+				}
+			}
+		}
+	}
+}
+
+func TestGetBasketResponse_InvalidMethod(t *testing.T) {
+	basket := "response02"
+	method := "DEMO"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+				GetBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, errorMessage(t, w), "unknown HTTP method: "+method, "wrong response message")
+			}
+		}
+	}
+}
+
+func TestGetBasketResponse_Unauthorized(t *testing.T) {
+	basket := "response03"
+	method := "POST"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+				httprouter.Param{Key: "method", Value: method})
+			w = httptest.NewRecorder()
+			GetBasketResponse(w, r, ps)
+
+			// validate response: 401 - unauthorized
+			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+		}
+	}
+}
+
+func TestUpdateBasketResponse(t *testing.T) {
+	basket := "response04"
+	method := "DELETE"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":404,\"body\":\"<error><code>404</code><message>Not Found</message></error>\",\"headers\":{\"Content-Type\":[\"application/xml\"]}}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				// validate database update
+				response := basketsDb.Get(basket).GetResponse(method)
+				assert.Equal(t, 404, response.Status, "wrong response status")
+				assert.Equal(t, "application/xml", response.Headers["Content-Type"][0], "wrong Content-Type header")
+				assert.Equal(t, "<error><code>404</code><message>Not Found</message></error>", response.Body, "wrong response body")
+				assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+			}
+		}
+	}
+}
+
+func TestUpdateBasketResponse_InvalidMethod(t *testing.T) {
+	basket := "response05"
+	method := "WRONG"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":201,\"body\":\"{}\",\"headers\":{\"Content-Type\":[\"application/json\"]}}"))
 
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, errorMessage(t, w), "unknown HTTP method: "+method, "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
+	}
+}
+
+func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
+	basket := "response06"
+	method := "PUT"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		WebBasketPage(w, r, ps)
+		w := httptest.NewRecorder()
 
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Basket: "+basket+"</title>",
-			"HTML page to display basket is expected")
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("<config><status>204</status></config>"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, errorMessage(t, w), "invalid character '<'", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
 	}
 }
 
-func TestWebBasketsPage(t *testing.T) {
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+serviceOldAPIPath, strings.NewReader(""))
+func TestUpdateBasketResponse_InvalidConfig(t *testing.T) {
+	basket := "response07"
+	method := "OPTIONS"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: serviceOldAPIPath})
-		WebBasketPage(w, r, ps)
 
-		// validate response: 200 - OK
-		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-		assert.Contains(t, w.Body.String(), "<title>Request Baskets - Administration</title>",
-			"HTML index page with baskets is expected")
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":20}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 422 - Unprocessable Entity
+				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, errorMessage(t, w), "invalid HTTP status of response: 20", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
 	}
 }
 
-func TestWebBasketPage_InvalidName(t *testing.T) {
-	basket := ">>>"
+func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
+	basket := "response08"
+	method := "GET"
 
-	r, err := http.NewRequest("GET", "http://localhost:55555/web/"+basket, strings.NewReader(""))
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
-		w := httptest.NewRecorder()
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		WebBasketPage(w, r, ps)
+		w := httptest.NewRecorder()
 
-		// validate response: 400 - Bad Request
-		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-		assert.Equal(t, "Basket name does not match pattern: "+validBasketName.String()+"\n", w.Body.String(),
-			"wrong error message")
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				strings.NewReader("{\"status\":200,\"body\":\"data: {{data}}\",\"is_template\":true}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 422 - Unprocessable Entity
+				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Contains(t, errorMessage(t, w), "error in body template: body:1: function \"data\" not defined", "wrong response message")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
 	}
 }
 
-func TestGetBasketResponse(t *testing.T) {
-	basket := "response01"
+func TestUpdateBasketResponse_NotModified(t *testing.T) {
+	basket := "response09"
 	method := "GET"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
@@ -1191,36 +2827,140 @@ func TestGetBasketResponse(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
 
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
 				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
 					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-				GetBasketResponse(w, r, ps)
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 304 - Not Modified
+				assert.Equal(t, 304, w.Code, "wrong HTTP result code")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
+	}
+}
+
+func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
+	basket := "response10"
+	method := "PATCH"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+				iotest.TimeoutReader(strings.NewReader("object is patched")))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+					httprouter.Param{Key: "method", Value: method})
+				w = httptest.NewRecorder()
+
+				UpdateBasketResponse(w, r, ps)
+
+				// validate response: 500 - Internal Server Error
+				assert.Equal(t, 500, w.Code, "wrong HTTP result code")
+
+				// validate database is not updated
+				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+			}
+		}
+	}
+}
+
+func TestGetBasketTrigger(t *testing.T) {
+	basket := "trigger01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/trigger", strings.NewReader(""))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketTrigger(w, r, ps)
 
 				// validate response: 200 - OK
 				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+				assert.Equal(t, "{\"script\":\"\"}", w.Body.String(), "empty trigger is expected")
+			}
+		}
+	}
+}
 
-				// Default response: HTTP 200 - empty content
-				response := new(ResponseConfig)
-				err = json.Unmarshal(w.Body.Bytes(), response)
-				if assert.NoError(t, err, "Failed to parse GetBasketResponse response") {
-					assert.Equal(t, 200, response.Status, "wrong basket default response status")
-					assert.Empty(t, response.Body, "empty response is expected")
-					assert.Empty(t, response.Headers, "empty headers are expected")
-					assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+func TestUpdateBasketTrigger(t *testing.T) {
+	basket := "trigger02"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		// get auth token
+		auth := new(BasketAuth)
+		err = json.Unmarshal(w.Body.Bytes(), auth)
+		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/trigger",
+				strings.NewReader("{\"script\":\"print(request['Body'])\"}"))
+
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				UpdateBasketTrigger(w, r, ps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+				// validate database update
+				trigger := basketsDb.Get(basket).GetTrigger()
+				if assert.NotNil(t, trigger, "trigger is expected") {
+					assert.Equal(t, "print(request['Body'])", trigger.Script, "wrong trigger script")
+				}
+
+				// collect request, trigger should not change the basket response
+				r, err = http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("ping"))
+				if assert.NoError(t, err) {
+					w = httptest.NewRecorder()
+					AcceptBasketRequests(w, r)
+					assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+					assert.Empty(t, w.Body.String(), "empty response body is expected")
 				}
 			}
 		}
 	}
 }
 
-func TestGetBasketResponse_InvalidMethod(t *testing.T) {
-	basket := "response02"
-	method := "DEMO"
+func TestUpdateBasketTrigger_InvalidScript(t *testing.T) {
+	basket := "trigger03"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1234,52 +2974,25 @@ func TestGetBasketResponse_InvalidMethod(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/trigger",
+				strings.NewReader("{\"script\":\"print(request['Body']\"}"))
 
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-				GetBasketResponse(w, r, ps)
+				UpdateBasketTrigger(w, r, ps)
 
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+				// validate response: 422 - Unprocessable Entity
+				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "error in script", "wrong error message")
+				assert.Nil(t, basketsDb.Get(basket).GetTrigger(), "trigger is not expected")
 			}
 		}
 	}
 }
 
-func TestGetBasketResponse_Unauthorized(t *testing.T) {
-	basket := "response03"
-	method := "POST"
-
-	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
-	if assert.NoError(t, err) {
-		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
-		w := httptest.NewRecorder()
-
-		CreateBasket(w, r, ps)
-		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
-
-		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
-		if assert.NoError(t, err) {
-			ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-				httprouter.Param{Key: "method", Value: method})
-			w = httptest.NewRecorder()
-			GetBasketResponse(w, r, ps)
-
-			// validate response: 401 - unauthorized
-			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
-		}
-	}
-}
-
-func TestUpdateBasketResponse(t *testing.T) {
-	basket := "response04"
-	method := "DELETE"
+func TestUpdateBasketSchedules(t *testing.T) {
+	basket := "schedules01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1293,34 +3006,47 @@ func TestUpdateBasketResponse(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":404,\"body\":\"<error><code>404</code><message>Not Found</message></error>\",\"headers\":{\"Content-Type\":[\"application/xml\"]}}"))
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/schedules",
+				strings.NewReader("[{\"name\":\"nightly\",\"cron\":\"0 2 * * *\",\"script\":\"basket.clear()\"}]"))
 
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
+				UpdateBasketSchedules(w, r, ps)
 
 				// validate response: 204 - No Content
 				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				assert.Len(t, scheduler.jobs[basket], 1, "scheduled job is expected")
 
-				// validate database update
-				response := basketsDb.Get(basket).GetResponse(method)
-				assert.Equal(t, 404, response.Status, "wrong response status")
-				assert.Equal(t, "application/xml", response.Headers["Content-Type"][0], "wrong Content-Type header")
-				assert.Equal(t, "<error><code>404</code><message>Not Found</message></error>", response.Body, "wrong response body")
-				assert.False(t, response.IsTemplate, "wrong value of IsTemplate flag")
+				// get schedules
+				r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/schedules", strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					GetBasketSchedules(w, r, ps)
+
+					// validate response: 200 - OK
+					assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+					assert.Equal(t, "[{\"name\":\"nightly\",\"cron\":\"0 2 * * *\",\"script\":\"basket.clear()\"}]",
+						w.Body.String(), "wrong schedules")
+				}
+
+				// delete basket, scheduled jobs are removed
+				r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					DeleteBasket(w, r, ps)
+					assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+					assert.Empty(t, scheduler.jobs[basket], "scheduled jobs are not expected")
+				}
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_InvalidMethod(t *testing.T) {
-	basket := "response05"
-	method := "WRONG"
+func TestUpdateBasketSchedules_InvalidConfig(t *testing.T) {
+	basket := "schedules02"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1334,32 +3060,30 @@ func TestUpdateBasketResponse_InvalidMethod(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":201,\"body\":\"{}\",\"headers\":{\"Content-Type\":[\"application/json\"]}}"))
-
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "unknown HTTP method: "+method, "wrong response message")
+			for body, message := range map[string]string{
+				"[{\"name\":\"\",\"cron\":\"@daily\",\"script\":\"\"}]":                                                       "schedule name is required",
+				"[{\"name\":\"a\",\"cron\":\"@daily\",\"script\":\"\"},{\"name\":\"a\",\"cron\":\"@daily\",\"script\":\"\"}]": "duplicate schedule name: a",
+				"[{\"name\":\"a\",\"cron\":\"* * *\",\"script\":\"\"}]":                                                       "invalid cron expression",
+				"[{\"name\":\"a\",\"cron\":\"@daily\",\"script\":\"print(\"}]":                                                "error in script",
+			} {
+				r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/schedules", strings.NewReader(body))
+				if assert.NoError(t, err) {
+					r.Header.Add("Authorization", auth.Token)
+					w = httptest.NewRecorder()
+					UpdateBasketSchedules(w, r, ps)
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+					// validate response: 422 - Unprocessable Entity
+					assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+					assert.Contains(t, w.Body.String(), message, "wrong error message")
+				}
 			}
+			assert.Empty(t, basketsDb.Get(basket).GetSchedules(), "schedules are not expected")
 		}
 	}
 }
 
-func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
-	basket := "response06"
-	method := "PUT"
+func TestBasketSecrets(t *testing.T) {
+	basket := "secrets01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1373,32 +3097,51 @@ func TestUpdateBasketResponse_BrokenJson(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("<config><status>204</status></config>"))
+			sps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+				httprouter.Param{Key: "secret", Value: "API_KEY"})
 
+			// set secret
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/secrets/API_KEY", strings.NewReader("s3cr3t"))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
 				w = httptest.NewRecorder()
+				UpdateBasketSecret(w, r, sps)
 
-				UpdateBasketResponse(w, r, ps)
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "s3cr3t", basketsDb.Get(basket).GetSecrets()["API_KEY"], "wrong secret value")
+			}
 
-				// validate response: 400 - Bad Request
-				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "invalid character '<'", "wrong response message")
+			// get secrets, values are masked
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/secrets", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketSecrets(w, r, ps)
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// validate response: 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				assert.Equal(t, "{\"API_KEY\":\"********\"}", w.Body.String(), "wrong secrets")
+				assert.NotContains(t, w.Body.String(), "s3cr3t", "secret value is exposed")
+			}
+
+			// delete secret
+			r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/secrets/API_KEY", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				DeleteBasketSecret(w, r, sps)
+
+				// validate response: 204 - No Content
+				assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+				assert.Empty(t, basketsDb.Get(basket).GetSecrets(), "secrets are not expected")
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_InvalidConfig(t *testing.T) {
-	basket := "response07"
-	method := "OPTIONS"
+func TestUpdateBasketSecret_InvalidName(t *testing.T) {
+	basket := "secrets02"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1412,31 +3155,24 @@ func TestUpdateBasketResponse_InvalidConfig(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":20}"))
-
+			r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/secrets/1-key", strings.NewReader("value"))
 			if assert.NoError(t, err) {
 				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
+				ps = append(ps, httprouter.Param{Key: "secret", Value: "1-key"})
 				w = httptest.NewRecorder()
+				UpdateBasketSecret(w, r, ps)
 
-				UpdateBasketResponse(w, r, ps)
-
-				// validate response: 422 - Unprocessable Entity
-				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "invalid HTTP status of response: 20", "wrong response message")
-
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// validate response: 400 - Bad Request
+				assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+				assert.Contains(t, w.Body.String(), "invalid secret name", "wrong error message")
+				assert.Empty(t, basketsDb.Get(basket).GetSecrets(), "secrets are not expected")
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
-	basket := "response08"
+func TestAcceptBasketRequests_TemplateResponseWithSecret(t *testing.T) {
+	basket := "secrets03"
 	method := "GET"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
@@ -1447,72 +3183,223 @@ func TestUpdateBasketResponse_InvalidTemplate(t *testing.T) {
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
-		auth := new(BasketAuth)
-		err = json.Unmarshal(w.Body.Bytes(), auth)
-		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				strings.NewReader("{\"status\":200,\"body\":\"data: {{data}}\",\"is_template\":true}"))
+		basketsDb.Get(basket).SetSecret("GREETING", "hello")
+		basketsDb.Get(basket).SetResponse(method, ResponseConfig{Status: 200, Body: "{{env \"GREETING\"}} world", IsTemplate: true})
 
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
+		r, err = http.NewRequest(method, "http://localhost:55555/"+basket, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
 
-				UpdateBasketResponse(w, r, ps)
+			// validate expected response
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			assert.Equal(t, "hello world", w.Body.String(), "wrong HTTP response body")
+		}
+	}
+}
 
-				// validate response: 422 - Unprocessable Entity
-				assert.Equal(t, 422, w.Code, "wrong HTTP result code")
-				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
-				assert.Contains(t, w.Body.String(), "error in body template: body:1: function \"data\" not defined", "wrong response message")
+func TestAcceptBasketRequests_TemplateResponseBody(t *testing.T) {
+	basket := "secrets04"
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+	_, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		// template with body is rendered, template without body gives empty response
+		basketsDb.Get(basket).SetResponse("POST", ResponseConfig{Status: 201, Body: "id={{index .id 0}}", IsTemplate: true})
+		basketsDb.Get(basket).SetResponse("PUT", ResponseConfig{Status: 202, IsTemplate: true})
+
+		r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket+"?id=42", strings.NewReader("test"))
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 201, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "id=42", w.Body.String(), "template is expected to be rendered")
+
+		r, _ = http.NewRequest("PUT", "http://localhost:55555/"+basket, strings.NewReader("test"))
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+		assert.Empty(t, w.Body.String(), "empty response body is expected")
+	}
+}
+
+func TestBasketWebhooks(t *testing.T) {
+	basket := "webhooks10"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+		// set webhooks
+		r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/webhooks",
+			strings.NewReader("[{\"url\":\"http://localhost:8080/events\",\"events\":[\"request_received\"],\"secret\":\"s3cr3t\"}]"))
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			UpdateBasketWebhooks(w, r, ps)
+
+			// validate response: 204 - No Content
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			if subscriptions := basketsDb.Get(basket).GetWebhooks(); assert.Len(t, subscriptions, 1) {
+				assert.Equal(t, "s3cr3t", subscriptions[0].Secret, "wrong webhook secret")
+			}
+		}
+
+		// get webhooks, secrets are masked
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/webhooks", strings.NewReader(""))
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			GetBasketWebhooks(w, r, ps)
+
+			// validate response: 200 - OK
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			assert.Equal(t, "[{\"url\":\"http://localhost:8080/events\",\"events\":[\"request_received\"],\"secret\":\"********\"}]",
+				w.Body.String(), "wrong webhooks")
+		}
+
+		// update webhooks with masked secret, the secret is kept
+		r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/webhooks",
+			strings.NewReader("[{\"url\":\"http://localhost:8080/events\",\"secret\":\"********\"}]"))
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			UpdateBasketWebhooks(w, r, ps)
+
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			if subscriptions := basketsDb.Get(basket).GetWebhooks(); assert.Len(t, subscriptions, 1) {
+				assert.Equal(t, "s3cr3t", subscriptions[0].Secret, "webhook secret is expected to be kept")
+				assert.Empty(t, subscriptions[0].Events, "webhook events are expected to be reset")
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_NotModified(t *testing.T) {
-	basket := "response09"
-	method := "GET"
+func TestUpdateBasketWebhooks_InvalidConfig(t *testing.T) {
+	basket := "webhooks11"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		update := func(body string) int {
+			r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/webhooks", strings.NewReader(body))
+			if !assert.NoError(t, err) {
+				return 0
+			}
+			r.Header.Add("Authorization", auth.Token)
+			w := httptest.NewRecorder()
+			UpdateBasketWebhooks(w, r, ps)
+			return w.Code
+		}
+
+		assert.Equal(t, 400, update("{"), "wrong HTTP result code")
+		assert.Equal(t, 422, update("[{\"url\":\"/events\"}]"), "wrong HTTP result code")
+		assert.Equal(t, 422, update("[{\"url\":\"ftp://localhost/events\"}]"), "wrong HTTP result code")
+		assert.Equal(t, 422, update("[{\"url\":\"http://localhost/events\",\"events\":[\"basket_deleted\"]}]"), "wrong HTTP result code")
+		assert.Equal(t, 304, update(""), "wrong HTTP result code")
+		assert.Empty(t, basketsDb.Get(basket).GetWebhooks(), "webhooks are not expected")
+	}
+}
+
+func TestGlobalWebhooks(t *testing.T) {
+	defer webhooks.SetGlobal([]WebhookConfig{})
+
+	// master token is required
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/webhooks",
+		strings.NewReader("[{\"url\":\"http://localhost:8080/events\",\"events\":[\"basket_created\"]}]"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", "abc")
+		w := httptest.NewRecorder()
+		UpdateWebhooks(w, r, nil)
+		assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+		assert.Empty(t, webhooks.GetGlobal(), "global webhooks are not expected")
+	}
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/webhooks",
+		strings.NewReader("[{\"url\":\"http://localhost:8080/events\",\"events\":[\"basket_created\"]}]"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		UpdateWebhooks(w, r, nil)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+	}
+
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/webhooks", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		GetWebhooks(w, r, nil)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "[{\"url\":\"http://localhost:8080/events\",\"events\":[\"basket_created\"]}]", w.Body.String(), "wrong webhooks")
+	}
+}
+
+func TestAcceptBasketRequests_Webhooks(t *testing.T) {
+	basket := "webhooks12"
+	server, deliveries := newWebhookTestServer()
+	defer server.Close()
+
+	webhooks.SetGlobal([]WebhookConfig{{URL: server.URL, Events: []string{EventBasketCreated}}})
+	defer webhooks.SetGlobal([]WebhookConfig{})
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
 		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
 		w := httptest.NewRecorder()
-
 		CreateBasket(w, r, ps)
 		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
 
-		// get auth token
-		auth := new(BasketAuth)
-		err = json.Unmarshal(w.Body.Bytes(), auth)
-		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, strings.NewReader(""))
+		// global subscriber is notified about new basket
+		if delivery := receiveWebhook(t, deliveries); delivery != nil {
+			assert.Equal(t, EventBasketCreated, delivery.header.Get(WebhookEventHeader), "wrong event header")
+			assert.Contains(t, string(delivery.body), "\"basket\":\""+basket+"\"", "wrong event payload")
+		}
 
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
+		// basket subscriber is notified about collected request and failed forwarding
+		basketsDb.Get(basket).Update(BasketConfig{Capacity: 20, ForwardURL: "http://localhost:1/unreachable"})
+		basketsDb.Get(basket).SetWebhooks([]WebhookConfig{
+			{URL: server.URL, Events: []string{EventRequestReceived, EventForwardFailed}, Secret: "s3cr3t"}})
 
-				UpdateBasketResponse(w, r, ps)
+		r, err = http.NewRequest("POST", "http://localhost:55555/"+basket+"/orders", strings.NewReader("order"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
 
-				// validate response: 304 - Not Modified
-				assert.Equal(t, 304, w.Code, "wrong HTTP result code")
+			events := make(map[string]*WebhookEvent)
+			for i := 0; i < 2; i++ {
+				if delivery := receiveWebhook(t, deliveries); delivery != nil {
+					assert.Equal(t, SignWebhookPayload("s3cr3t", delivery.body), delivery.header.Get(WebhookSignatureHeader))
+					event := new(WebhookEvent)
+					if assert.NoError(t, json.Unmarshal(delivery.body, event)) {
+						events[event.Event] = event
+					}
+				}
+			}
+			if event, exists := events[EventRequestReceived]; assert.True(t, exists, "request event is expected") {
+				assert.Equal(t, "/"+basket+"/orders", event.Request.Path, "wrong request path")
+			}
+			if event, exists := events[EventForwardFailed]; assert.True(t, exists, "forward event is expected") {
+				assert.NotEmpty(t, event.Error, "forward error is expected")
+			}
+		}
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+		// webhook deliveries collected by basket produce no events
+		r, err = http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("{}"))
+		if assert.NoError(t, err) {
+			r.Header.Set(WebhookEventHeader, EventRequestReceived)
+			r.Header.Set(DoNotForwardHeader, "1")
+			w = httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+
+			select {
+			case <-deliveries:
+				assert.Fail(t, "no deliveries are expected")
+			case <-time.After(100 * time.Millisecond):
 			}
 		}
 	}
 }
 
-func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
-	basket := "response10"
-	method := "PATCH"
+func TestGetBasketScripts(t *testing.T) {
+	basket := "scripts01"
 
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
 	if assert.NoError(t, err) {
@@ -1526,22 +3413,24 @@ func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
 		auth := new(BasketAuth)
 		err = json.Unmarshal(w.Body.Bytes(), auth)
 		if assert.NoError(t, err, "Failed to parse CreateBasket response") {
-			r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
-				iotest.TimeoutReader(strings.NewReader("object is patched")))
-
-			if assert.NoError(t, err) {
-				r.Header.Add("Authorization", auth.Token)
-				ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
-					httprouter.Param{Key: "method", Value: method})
-				w = httptest.NewRecorder()
-
-				UpdateBasketResponse(w, r, ps)
+			scriptTrigger(basket, "print('ok')", new(RequestData), nil)
+			scriptTrigger(basket, "fail('broken')", new(RequestData), nil)
 
-				// validate response: 500 - Internal Server Error
-				assert.Equal(t, 500, w.Code, "wrong HTTP result code")
+			r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/scripts", strings.NewReader(""))
+			if assert.NoError(t, err) {
+				r.Header.Add("Authorization", auth.Token)
+				w = httptest.NewRecorder()
+				GetBasketScripts(w, r, ps)
 
-				// validate database is not updated
-				assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response configuration is not expected")
+				// validate response: 200 - OK
+				assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+				stats := make([]*ScriptStats, 0)
+				if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats)) && assert.Len(t, stats, 1) {
+					assert.Equal(t, "trigger", stats[0].Script, "wrong script name")
+					assert.Equal(t, 2, stats[0].ExecutionsCount, "wrong executions count")
+					assert.Equal(t, 1, stats[0].ErrorsCount, "wrong errors count")
+					assert.Contains(t, stats[0].LastError, "broken", "wrong last error")
+				}
 			}
 		}
 	}
@@ -1770,6 +3659,37 @@ func TestAcceptBasketRequests_WithProxyResponse(t *testing.T) {
 	}
 }
 
+func TestAcceptBasketRequests_WithProxyResponse_Latency(t *testing.T) {
+	basket := "accept07l"
+
+	// Test HTTP server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"forward_url\":\""+ts.URL+"\",\"capacity\":200,\"proxy_response\":true}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		w := httptest.NewRecorder()
+
+		CreateBasket(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		w = httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket, "test", "text/plain"))
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+
+		// forward latency is recorded with collected request
+		page := basketsDb.Get(basket).GetRequests(1, 0)
+		if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+			assert.True(t, page.Requests[0].ForwardLatency >= 20, "forward latency is not recorded")
+		}
+	}
+}
+
 func TestAcceptBasketRequests_WithForward_BadGateway(t *testing.T) {
 	basket := "accept08"
 	method := "GET"
@@ -1826,6 +3746,13 @@ func TestAcceptBasketRequests_WithProxyResponse_BadGateway(t *testing.T) {
 			assert.Contains(t, w.Body.String(), "Failed to forward request", "wrong HTTP response body")
 			assert.Contains(t, w.Body.String(), forwardURL, "wrong HTTP response body")
 			assert.Contains(t, w.Body.String(), "connection refused", "wrong HTTP response body")
+
+			// forward result is recorded with collected request
+			page := basketsDb.Get(basket).GetRequests(1, 0)
+			if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+				assert.Equal(t, 502, page.Requests[0].ForwardStatus, "wrong forward status")
+				assert.Equal(t, 502, page.Requests[0].ResponseStatus, "wrong response status")
+			}
 		}
 	}
 }
@@ -1885,6 +3812,13 @@ func TestAcceptBasketRequests_WithProxyResponse_InternalServerError(t *testing.T
 			assert.Equal(t, 500, w.Code, "wrong HTTP response code")
 			assert.Contains(t, w.Body.String(), "invalid forward URL: qwert", "wrong HTTP response body")
 			assert.Contains(t, w.Body.String(), "invalid URI for request", "wrong HTTP response body")
+
+			// forward error is recorded with collected request
+			page := basketsDb.Get(basket).GetRequests(1, 0)
+			if assert.Len(t, page.Requests, 1, "wrong number of requests") {
+				assert.Contains(t, page.Requests[0].ForwardError, "invalid forward URL: qwert", "wrong forward error")
+				assert.Equal(t, 500, page.Requests[0].ResponseStatus, "wrong response status")
+			}
 		}
 	}
 }
@@ -1963,3 +3897,996 @@ func TestSanitizeForLog(t *testing.T) {
 	assert.Equal(t, "multi-^n^r^n^r^rmulti-^nmulti-^r^nlines", sanitizeForLog("multi-\n\r\n\r\rmulti-\nmulti-\r\nlines"),
 		"unexpected result of sanitizing")
 }
+
+func TestBasketSpec(t *testing.T) {
+	basket := "spec05"
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	// missing basket is created from spec
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec",
+		strings.NewReader("config:\n  capacity: 25\nresponses:\n  GET:\n    status: 202\n    body: hello\n"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketSpec(w, r, ps)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+
+		auth := new(BasketAuth)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), auth)) && assert.NotNil(t, basketsDb.Get(basket)) {
+			assert.Equal(t, 25, basketsDb.Get(basket).Config().Capacity, "wrong capacity")
+			assert.Equal(t, 202, basketsDb.Get(basket).GetResponse("GET").Status, "wrong response status")
+
+			// export
+			r, _ = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/spec?format=yaml", nil)
+			r.Header.Add("Authorization", auth.Token)
+			w = httptest.NewRecorder()
+			GetBasketSpec(w, r, ps)
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			assert.Equal(t, "application/yaml; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+			assert.Contains(t, w.Body.String(), "capacity: 25", "basket config is expected")
+			exported := w.Body.String()
+
+			r, _ = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/spec?format=xml", nil)
+			r.Header.Add("Authorization", auth.Token)
+			w = httptest.NewRecorder()
+			GetBasketSpec(w, r, ps)
+			assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+
+			// update existing basket requires its token
+			r, _ = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec",
+				strings.NewReader(`{"config": {"capacity": 35}}`))
+			w = httptest.NewRecorder()
+			UpdateBasketSpec(w, r, ps)
+			assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+
+			r, _ = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec",
+				strings.NewReader(`{"config": {"capacity": 35}}`))
+			r.Header.Add("Authorization", auth.Token)
+			w = httptest.NewRecorder()
+			UpdateBasketSpec(w, r, ps)
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			assert.Equal(t, 35, basketsDb.Get(basket).Config().Capacity, "wrong capacity")
+			assert.Empty(t, basketsDb.Get(basket).GetResponse("GET").Body, "response is expected to be reset")
+
+			// exported spec restores the setup
+			r, _ = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec", strings.NewReader(exported))
+			r.Header.Add("Authorization", auth.Token)
+			w = httptest.NewRecorder()
+			UpdateBasketSpec(w, r, ps)
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			assert.Equal(t, 25, basketsDb.Get(basket).Config().Capacity, "wrong capacity")
+			assert.Equal(t, "hello", basketsDb.Get(basket).GetResponse("GET").Body, "wrong response body")
+		}
+	}
+}
+
+func TestUpdateBasketSpec_Invalid(t *testing.T) {
+	basket := "spec06"
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec",
+		strings.NewReader("config:\n  capacity: 100000\n"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketSpec(w, r, ps)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Nil(t, basketsDb.Get(basket), "basket is not expected to be created")
+	}
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/spec",
+		strings.NewReader("config:\n  size: 10\n"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketSpec(w, r, ps)
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Contains(t, errorMessage(t, w), "unknown field", "wrong error message")
+	}
+}
+
+func TestBasketsSpec(t *testing.T) {
+	basketsDb.Create("spec07", BasketConfig{Capacity: 20})
+
+	spec := `{"baskets": [{"name": "spec07", "config": {"capacity": 45}}, {"name": "spec08"}]}`
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/spec", strings.NewReader(spec))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketsSpec(w, r, nil)
+		assert.Equal(t, 401, w.Code, "wrong HTTP result code")
+
+		r, _ = http.NewRequest("PUT", "http://localhost:55555/api/spec", strings.NewReader(spec))
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w = httptest.NewRecorder()
+		UpdateBasketsSpec(w, r, nil)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		result := new(BasketsSpecResult)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+			assert.Equal(t, []string{"spec07"}, result.Updated, "wrong updated baskets")
+			assert.NotEmpty(t, result.Created["spec08"], "token of created basket is expected")
+		}
+		assert.Equal(t, 45, basketsDb.Get("spec07").Config().Capacity, "wrong capacity")
+		assert.NotNil(t, basketsDb.Get("spec08"), "basket is expected to be created")
+
+		// all specs are validated before any change
+		r, _ = http.NewRequest("PUT", "http://localhost:55555/api/spec",
+			strings.NewReader(`{"baskets": [{"name": "spec09"}, {"name": "spec07", "config": {"capacity": -1}}]}`))
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w = httptest.NewRecorder()
+		UpdateBasketsSpec(w, r, nil)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Nil(t, basketsDb.Get("spec09"), "basket is not expected to be created")
+
+		r, _ = http.NewRequest("PUT", "http://localhost:55555/api/spec",
+			strings.NewReader(`{"baskets": [{"name": "spec09"}, {"name": "spec09"}]}`))
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w = httptest.NewRecorder()
+		UpdateBasketsSpec(w, r, nil)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+
+		// export
+		r, _ = http.NewRequest("GET", "http://localhost:55555/api/spec", nil)
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w = httptest.NewRecorder()
+		GetBasketsSpec(w, r, nil)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		all := new(BasketsSpec)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), all)) {
+			names := make(map[string]bool)
+			for _, b := range all.Baskets {
+				names[b.Name] = true
+			}
+			assert.True(t, names["spec07"] && names["spec08"], "specs of baskets are expected")
+		}
+	}
+}
+
+func TestWaitBasketRequest(t *testing.T) {
+	basket := "wait04"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	wait := func(query string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests/next"+query, nil)
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// timeout
+	w := wait("?timeout=50ms")
+	assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+	// request that arrives later, not matching requests are skipped
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/skipped", "", "text/plain"))
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook", "done", "text/plain"))
+	}()
+	w = wait("?timeout=5s&path=/" + basket + "/hook")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	request := new(RequestData)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+		assert.Equal(t, 2, request.ID, "wrong request ID")
+		assert.Equal(t, "done", request.Body, "wrong request body")
+		assert.Equal(t, 200, request.ResponseStatus, "response status is expected")
+	}
+
+	// requests collected before are returned immediately if the last seen request is specified
+	w = wait("?timeout=50ms&after=0")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), request)) {
+		assert.Equal(t, 1, request.ID, "the oldest request is expected")
+	}
+	assert.Equal(t, 204, wait("?timeout=50ms&after=2").Code, "wrong HTTP result code")
+
+	// invalid parameters
+	assert.Equal(t, 400, wait("?timeout=1h").Code, "wrong HTTP result code")
+	assert.Equal(t, 400, wait("?after=last").Code, "wrong HTTP result code")
+	assert.Equal(t, 400, wait("?query_type=fuzzy&q=x").Code, "wrong HTTP result code")
+}
+
+func TestAssertBasketRequests(t *testing.T) {
+	basket := "assert01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	check := func(assertion string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/assert", strings.NewReader(assertion))
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// expected request arrives later, not matching requests are skipped
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook",
+			`{"type": "payment.failed"}`, "application/json"))
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/hook",
+			`{"type": "payment.succeeded"}`, "application/json"))
+	}()
+	w := check(`{"filter": "json:\"$.type == 'payment.succeeded'\"", "count": 1, "timeout": "5s"}`)
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	result := new(AssertionResult)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+		assert.True(t, result.Passed, "assertion is expected to pass")
+		assert.Equal(t, "exactly 1", result.Expected, "wrong expectation")
+		if assert.Len(t, result.Requests, 1, "matching request is expected") {
+			assert.Equal(t, 2, result.Requests[0].ID, "wrong request ID")
+		}
+	}
+
+	// upper bound is exceeded
+	result = new(AssertionResult)
+	w = check(`{"query": "path=/` + basket + `/hook", "max_count": 1, "timeout": "5s"}`)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+		assert.False(t, result.Passed, "assertion is expected to fail")
+		assert.Equal(t, 2, result.Count, "wrong number of requests")
+		assert.Equal(t, 1, result.Requests[0].ID, "the oldest request is expected first")
+	}
+
+	// no requests after the last seen one until timeout
+	result = new(AssertionResult)
+	w = check(`{"count": 0, "after": 2, "timeout": "50ms"}`)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+		assert.True(t, result.Passed, "assertion is expected to pass")
+		assert.Empty(t, result.Requests, "no requests are expected")
+	}
+
+	result = new(AssertionResult)
+	w = check(`{"min_count": 3, "timeout": "50ms"}`)
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), result)) {
+		assert.False(t, result.Passed, "assertion is expected to fail")
+		assert.Equal(t, "at least 3", result.Expected, "wrong expectation")
+	}
+
+	// invalid assertions
+	assert.Equal(t, 400, check(`{"count": "one"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, check(`{"timeout": "1h"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, check(`{"filter": "(method:GET"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, check(`{"filter": "method:GET", "query": "filter=path:/x"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 422, check(`{"count": 1, "max_count": 2}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 422, check(`{"min_count": 3, "max_count": 2}`).Code, "wrong HTTP result code")
+}
+
+func TestTailBasketRequests(t *testing.T) {
+	basket := "tail04"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/old", "old", "text/plain"))
+
+	tail := func(query string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/tail"+query, nil)
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		testServer.Handler.ServeHTTP(w, r.WithContext(ctx))
+		return w
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/new", "new", "text/plain"))
+	}()
+	w := tail("?body=true")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "text/plain; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if assert.Len(t, lines, 4, "two requests with body are expected") {
+		assert.Contains(t, lines[0], "#1 POST /"+basket+"/old 200 3B", "wrong recent request")
+		assert.Equal(t, "  old", lines[1], "wrong body")
+		assert.Contains(t, lines[2], "#2 POST /"+basket+"/new 200 3B", "wrong collected request")
+	}
+
+	// recent requests are not printed
+	assert.Empty(t, tail("?last=0").Body.String(), "no requests are expected")
+
+	// invalid parameters
+	assert.Equal(t, 400, tail("?last=all").Code, "wrong HTTP result code")
+	assert.Equal(t, 400, tail("?last=5000").Code, "wrong HTTP result code")
+	assert.Equal(t, 400, tail("?query_type=fuzzy&q=x").Code, "wrong HTTP result code")
+}
+
+func TestBasketPush(t *testing.T) {
+	basket := "push06"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	call := func(method string, path string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// public key and service worker
+	w := call("GET", "/push/key", "")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Contains(t, w.Body.String(), pushes.PublicKey().PublicKey, "public key is expected")
+	w = call("GET", "/push/worker.js", "")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "application/javascript; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+
+	// subscribe
+	_, subscription := testPushBrowser(t, "https://push.example.com/send/"+basket)
+	data, _ := json.Marshal(subscription)
+	assert.Equal(t, 204, call("POST", "/baskets/"+basket+"/push", string(data)).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", "/baskets/"+basket+"/push", "{").Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", "/baskets/"+basket+"/push", `{"endpoint": "https://push.example.com"}`).Code,
+		"wrong HTTP result code")
+
+	// unsubscribe
+	endpoint := "?endpoint=" + url.QueryEscape(subscription.Endpoint)
+	assert.Equal(t, 400, call("DELETE", "/baskets/"+basket+"/push", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("DELETE", "/baskets/"+basket+"/push"+endpoint, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("DELETE", "/baskets/"+basket+"/push"+endpoint, "").Code, "wrong HTTP result code")
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	basket := "webhooks13"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server, deliveries := newWebhookTestServer()
+	defer server.Close()
+
+	basketsDb.Get(basket).SetWebhooks([]WebhookConfig{{URL: server.URL}})
+	basketsDb.Get(basket).SetWebhookDeliveries([]WebhookDelivery{
+		{ID: "1", EventID: "e1", Event: EventRequestReceived, URL: server.URL, Status: DeliveryDelivered,
+			Attempts: 1, Payload: json.RawMessage(`{"id":"e1"}`)},
+		{ID: "2", EventID: "e2", Event: EventRequestReceived, URL: server.URL, Status: DeliveryFailed,
+			Attempts: 5, Payload: json.RawMessage(`{"id":"e2"}`)}})
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	list := func(w *httptest.ResponseRecorder) []WebhookDelivery {
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		result := []WebhookDelivery{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	// list deliveries
+	path := "/baskets/" + basket + "/webhooks/deliveries"
+	if result := list(call("GET", path, auth.Token, "")); assert.Len(t, result, 2, "wrong number of deliveries") {
+		assert.Equal(t, "2", result[0].ID, "the latest delivery is expected first")
+		assert.Empty(t, result[0].Payload, "payload is not expected")
+	}
+	if result := list(call("GET", path+"?status=failed&payload=true", auth.Token, "")); assert.Len(t, result, 1) {
+		assert.Equal(t, `{"id":"e2"}`, string(result[0].Payload), "payload is expected")
+	}
+	assert.Equal(t, 400, call("GET", path+"?status=lost", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path, "wrong", "").Code, "wrong HTTP result code")
+
+	// redrive failed delivery
+	assert.Equal(t, 400, call("POST", path+"/redrive", auth.Token, "{").Code, "wrong HTTP result code")
+	if result := list(call("POST", path+"/redrive", auth.Token, `{"ids": ["2"]}`)); assert.Len(t, result, 1) {
+		assert.Equal(t, "2", result[0].ID, "wrong delivery is redriven")
+		assert.Equal(t, DeliveryPending, result[0].Status, "redriven delivery is expected to be pending")
+	}
+	if delivery := receiveWebhook(t, deliveries); delivery != nil {
+		assert.Equal(t, "e2", delivery.header.Get(WebhookDeliveryHeader), "wrong event ID")
+	}
+	assert.Empty(t, list(call("POST", path+"/redrive", auth.Token, "")), "no failed deliveries are expected")
+
+	// deliveries to global subscribers require master token
+	assert.Equal(t, 401, call("GET", "/webhooks/deliveries", auth.Token, "").Code, "wrong HTTP result code")
+	list(call("GET", "/webhooks/deliveries", serverConfig.MasterToken, ""))
+	list(call("POST", "/webhooks/deliveries/redrive", serverConfig.MasterToken, ""))
+}
+
+func TestUsers(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	signup := func(name string, token string, body string) string {
+		w := call("POST", "/users/"+name, token, body)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		auth := UserAuth{}
+		json.Unmarshal(w.Body.Bytes(), &auth)
+		return auth.Token
+	}
+
+	// sign up
+	alice := signup("alice01", "", `{"max_baskets": 100}`)
+	bob := signup("bob01", serverConfig.MasterToken, `{"max_baskets": 1}`)
+	assert.Equal(t, serverConfig.UserBaskets, users.Get("alice01").MaxBaskets, "settings are only expected with master token")
+	assert.Equal(t, 409, call("POST", "/users/alice01", "", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", "/users/alice%2001", "", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", "/users/carol01", serverConfig.MasterToken, `{"max_baskets": -1}`).Code,
+		"wrong HTTP result code")
+
+	// baskets created with user token belong to the user
+	assert.Equal(t, 201, call("POST", "/baskets/users01", alice, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 201, call("POST", "/baskets/users02", bob, "").Code, "wrong HTTP result code")
+	w := call("POST", "/baskets/users03", bob, "")
+	assert.Equal(t, 403, w.Code, "quota is expected to be exceeded")
+	assert.Contains(t, w.Body.String(), ErrorQuotaExceeded, "wrong error code")
+	assert.Nil(t, basketsDb.Get("users03"), "basket over quota is not expected")
+	assert.Equal(t, 409, call("POST", "/baskets/users01", alice, "").Code, "wrong HTTP result code")
+
+	// owner token authorizes access to owned baskets only
+	assert.Equal(t, 200, call("GET", "/baskets/users01", alice, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/baskets/users02", alice, "").Code, "wrong HTTP result code")
+	w = call("GET", "/baskets", alice, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		page := BasketNamesPage{}
+		json.Unmarshal(w.Body.Bytes(), &page)
+		assert.Equal(t, []string{"users01"}, page.Names, "only owned baskets are expected")
+	}
+	assert.Equal(t, 401, call("GET", "/users", alice, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/users", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+
+	// renamed and cloned baskets keep the owner
+	assert.Equal(t, 204, call("POST", "/baskets/users01/rename", alice, `{"name": "users04"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 201, call("POST", "/baskets/users04/clone", alice, `{"name": "users05"}`).Code, "wrong HTTP result code")
+	w = call("GET", "/users/alice01", alice, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		user := User{}
+		json.Unmarshal(w.Body.Bytes(), &user)
+		assert.Equal(t, []string{"users04", "users05"}, user.Baskets, "wrong owned baskets")
+	}
+	assert.Equal(t, 401, call("GET", "/users/alice01", bob, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("GET", "/users/carol01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+
+	// quota is managed with master token
+	assert.Equal(t, 401, call("PUT", "/users/bob01", bob, `{"max_baskets": 5}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/users/bob01", serverConfig.MasterToken, `{"max_baskets": 5}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 201, call("POST", "/baskets/users03", bob, "").Code, "wrong HTTP result code")
+
+	// deleted basket stays owned in trash, deleted user takes owned baskets along
+	assert.Equal(t, 204, call("DELETE", "/baskets/users03", bob, "").Code, "wrong HTTP result code")
+	owned := users.Get("bob01").Baskets
+	if assert.Len(t, owned, 2, "basket in trash is expected to stay owned") {
+		assert.Equal(t, "users02", owned[0], "wrong owned basket")
+		assert.True(t, isTrashName(owned[1]), "basket in trash is expected")
+	}
+	assert.Equal(t, 204, call("DELETE", "/users/alice01", alice, "").Code, "wrong HTTP result code")
+	assert.Nil(t, basketsDb.Get("users04"), "basket of deleted user is not expected")
+	assert.Nil(t, basketsDb.Get("users05"), "basket of deleted user is not expected")
+	assert.Equal(t, 204, call("DELETE", "/users/bob01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+	assert.Nil(t, basketsDb.Get("users02"), "basket of deleted user is not expected")
+}
+
+func TestUserSessions(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := call("POST", "/users/sessions01", "", "")
+	if !assert.Equal(t, 201, w.Code, "wrong HTTP result code") {
+		return
+	}
+	auth := UserAuth{}
+	json.Unmarshal(w.Body.Bytes(), &auth)
+	assert.Equal(t, 201, call("POST", "/baskets/sessions01", auth.Token, "").Code, "wrong HTTP result code")
+	basketToken := "basket-token-sessions01"
+	basketsDb.Get("sessions01").SetToken(basketToken)
+
+	r, _ := http.NewRequest("GET", "http://localhost:55555/web", nil)
+	laptop, _ := users.SignIn(auth.Token, "oidc", r)
+	phone, _ := users.SignIn(auth.Token, "ldap", r)
+
+	// sessions are listed with the token of user or the master token
+	assert.Equal(t, 401, call("GET", "/users/sessions01/sessions", "wrong", "").Code, "wrong HTTP result code")
+	w = call("GET", "/users/sessions01/sessions", phone, "")
+	sessions := []UserSession{}
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		json.Unmarshal(w.Body.Bytes(), &sessions)
+		if assert.Len(t, sessions, 2, "sessions are expected") {
+			assert.False(t, sessions[0].Current, "wrong current session")
+			assert.True(t, sessions[1].Current, "wrong current session")
+		}
+	}
+	assert.NotContains(t, w.Body.String(), laptop, "tokens of sessions are not expected")
+
+	// lost laptop is signed out
+	if len(sessions) == 2 {
+		assert.Equal(t, 204, call("DELETE", "/users/sessions01/sessions/"+sessions[0].ID, phone, "").Code,
+			"wrong HTTP result code")
+		assert.Equal(t, 404, call("DELETE", "/users/sessions01/sessions/"+sessions[0].ID, phone, "").Code,
+			"wrong HTTP result code")
+	}
+	assert.Equal(t, 401, call("GET", "/baskets/sessions01", laptop, "").Code, "revoked session is not expected")
+	assert.Equal(t, 200, call("GET", "/baskets/sessions01", phone, "").Code, "wrong HTTP result code")
+
+	// sign out everywhere revokes sessions, token of user and tokens of owned baskets
+	w = call("POST", "/users/sessions01/sign-out", phone, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		renewed := UserAuth{}
+		json.Unmarshal(w.Body.Bytes(), &renewed)
+		assert.Equal(t, 401, call("GET", "/baskets/sessions01", phone, "").Code, "revoked session is not expected")
+		assert.Equal(t, 401, call("GET", "/baskets/sessions01", auth.Token, "").Code, "old token is not expected")
+		assert.Equal(t, 401, call("GET", "/baskets/sessions01", basketToken, "").Code, "basket token is not expected")
+		assert.Equal(t, 200, call("GET", "/baskets/sessions01", renewed.Token, "").Code, "new token is expected")
+	}
+	page := audit.Find(AuditQuery{Action: AuditUserSignOut}, 10, 0)
+	assert.Len(t, page.Entries, 2, "audit entries are expected")
+
+	assert.Equal(t, 204, call("DELETE", "/users/sessions01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+}
+
+func TestUserQuotas(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	collect := func(basket string, body string) int {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, createTestPOSTRequest("http://localhost:55555/"+basket, body, "text/plain"))
+		return w.Code
+	}
+
+	auth, err := users.Create("quotas01", UserConfig{MaxCapacity: 10, MaxBytes: 1000})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/users/quotas01", serverConfig.MasterToken, `{"max_bytes": -1}`).Code,
+		"wrong HTTP result code")
+
+	// default capacity is reduced to the quota of user
+	assert.Equal(t, 201, call("POST", "/baskets/quotas01", auth.Token, "").Code, "wrong HTTP result code")
+	if basket := basketsDb.Get("quotas01"); assert.NotNil(t, basket, "basket is expected") {
+		assert.Equal(t, 10, basket.Config().Capacity, "wrong capacity")
+	}
+
+	// capacity over quota
+	w := call("POST", "/baskets/quotas02", auth.Token, `{"capacity": 20}`)
+	assert.Equal(t, 403, w.Code, "quota is expected to be exceeded")
+	assert.Contains(t, w.Body.String(), ErrorQuotaExceeded, "wrong error code")
+	assert.Nil(t, basketsDb.Get("quotas02"), "basket over quota is not expected")
+	assert.Equal(t, 403, call("PUT", "/baskets/quotas01", auth.Token, `{"capacity": 20}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/baskets/quotas01", auth.Token, `{"capacity": 5}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 403, call("PUT", "/baskets/quotas03/spec", auth.Token, `{"config": {"capacity": 20}}`).Code,
+		"wrong HTTP result code")
+
+	// stored bytes over quota
+	body := strings.Repeat("x", 600)
+	assert.Equal(t, 200, collect("quotas01", body), "request within quota is expected to be collected")
+	assert.Equal(t, 200, collect("quotas01", body), "request within quota is expected to be collected")
+	assert.Equal(t, 507, collect("quotas01", body), "storage quota is expected to be exhausted")
+	assert.Equal(t, 2, basketsDb.Get("quotas01").Size(), "request over quota is not expected to be collected")
+
+	// cleared basket frees the storage, quota is overridden with master token
+	assert.Equal(t, 204, call("DELETE", "/baskets/quotas01/requests", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, collect("quotas01", body), "request within quota is expected to be collected")
+	assert.Equal(t, 204, call("PUT", "/users/quotas01", serverConfig.MasterToken, `{"max_capacity": 0, "max_bytes": 0}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/baskets/quotas01", auth.Token, `{"capacity": 20}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, collect("quotas01", body), "request without quota is expected to be collected")
+
+	assert.Equal(t, 204, call("DELETE", "/users/quotas01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+}
+
+func TestBasketShare(t *testing.T) {
+	basket := "share01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/path", "data", "text/plain"))
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	share := func(w *httptest.ResponseRecorder) string {
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		result := BasketAuth{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result.Token
+	}
+
+	// basket is not shared yet
+	path := "/baskets/" + basket
+	assert.Equal(t, 404, call("GET", path+"/share", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", path+"/share", "wrong", "").Code, "wrong HTTP result code")
+
+	// share basket
+	token := share(call("POST", path+"/share", auth.Token, ""))
+	if !assert.NotEmpty(t, token, "share token is expected") {
+		return
+	}
+	assert.NotEqual(t, auth.Token, token, "share token must differ from basket token")
+	assert.Equal(t, token, share(call("GET", path+"/share", serverConfig.MasterToken, "")), "wrong share token")
+
+	// share token permits viewing of collected requests
+	w := call("GET", path+"/requests", token, "")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Contains(t, w.Body.String(), "/"+basket+"/path", "collected request is expected")
+	assert.Equal(t, 200, call("GET", path+"/requests/1", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/export?format=curl", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/aggregate?by=method", token, "").Code, "wrong HTTP result code")
+
+	// share token does not permit changes or access to settings
+	assert.Equal(t, 401, call("GET", path, token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("PUT", path, token, `{"capacity":10}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path+"/responses/GET", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", path+"/requests", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", path+"/share", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", path, token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 1, basketsDb.Get(basket).Size(), "collected request is not expected to be deleted")
+
+	// share token survives rename of basket
+	assert.Equal(t, 204, call("POST", path+"/rename", auth.Token, `{"name":"share02"}`).Code, "wrong HTTP result code")
+	path = "/baskets/share02"
+	assert.Equal(t, 200, call("GET", path+"/requests", token, "").Code, "wrong HTTP result code")
+
+	// a new share token revokes the previous one
+	renewed := share(call("POST", path+"/share", auth.Token, ""))
+	assert.NotEqual(t, token, renewed, "new share token is expected")
+	assert.Equal(t, 401, call("GET", path+"/requests", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/requests", renewed, "").Code, "wrong HTTP result code")
+
+	// revoke share token
+	assert.Equal(t, 204, call("DELETE", path+"/share", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path+"/requests", renewed, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("GET", path+"/share", auth.Token, "").Code, "wrong HTTP result code")
+}
+
+func TestBasketToken(t *testing.T) {
+	basket := "token01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).SetShareToken("token01_share")
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	rotate := func(token string) string {
+		w := call("POST", "/baskets/"+basket+"/token", token, "")
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		result := BasketAuth{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result.Token
+	}
+
+	// rotate token
+	path := "/baskets/" + basket
+	assert.Equal(t, 401, call("POST", path+"/token", "wrong", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", path+"/token", "token01_share", "").Code, "wrong HTTP result code")
+	token := rotate(auth.Token)
+	if !assert.NotEmpty(t, token, "new token is expected") {
+		return
+	}
+	assert.NotEqual(t, auth.Token, token, "new token is expected")
+	assert.Equal(t, 401, call("GET", path, auth.Token, "").Code, "previous token is not expected to be accepted")
+	assert.Equal(t, 200, call("GET", path, token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/requests", "token01_share", "").Code, "share token is expected to be kept")
+
+	// revoke tokens
+	assert.Equal(t, 204, call("DELETE", path+"/token", token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path, token, "").Code, "revoked token is not expected to be accepted")
+	assert.Equal(t, 401, call("GET", path, "", "").Code, "empty token is not expected to be accepted")
+	assert.Equal(t, 401, call("GET", path+"/requests", "token01_share", "").Code, "share token is expected to be revoked")
+
+	// master token issues a new token
+	token = rotate(serverConfig.MasterToken)
+	assert.Equal(t, 200, call("GET", path, token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("POST", "/baskets/token02/token", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+}
+
+func TestBasketAccessTokens(t *testing.T) {
+	basket := "tokens01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/path", "data", "text/plain"))
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	issue := func(body string) string {
+		w := call("POST", "/baskets/"+basket+"/tokens", auth.Token, body)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		result := AccessToken{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.NotEmpty(t, result.Created, "creation time is expected")
+		return result.Token
+	}
+
+	// issue tokens
+	path := "/baskets/" + basket
+	reader := issue(`{"name": "reader", "scopes": ["read"]}`)
+	config := issue(`{"name": "config", "scopes": ["write-config"]}`)
+	cleaner := issue(`{"name": "cleaner", "scopes": ["read", "clear", "delete"]}`)
+	assert.Equal(t, 409, call("POST", path+"/tokens", auth.Token, `{"name": "reader", "scopes": ["read"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", path+"/tokens", auth.Token, `{"name": "admin", "scopes": ["all"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", path+"/tokens", auth.Token, `{"name": "none"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", path+"/tokens", auth.Token, `{"name": "a b", "scopes": ["read"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", path+"/tokens", auth.Token, "{").Code, "wrong HTTP result code")
+
+	// access tokens are listed without the tokens themselves
+	w := call("GET", path+"/tokens", auth.Token, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		tokens := []AccessToken{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		if assert.Len(t, tokens, 3, "wrong number of access tokens") {
+			assert.Equal(t, "reader", tokens[0].Name, "wrong token name")
+			assert.Equal(t, []string{ScopeRead}, tokens[0].Scopes, "wrong token scopes")
+		}
+		assert.NotContains(t, w.Body.String(), reader, "token is not expected to be reported")
+	}
+
+	// read scope
+	assert.Equal(t, 200, call("GET", path+"/requests", reader, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path, reader, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", path+"/requests", reader, "").Code, "wrong HTTP result code")
+
+	// write-config scope
+	assert.Equal(t, 200, call("GET", path, config, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", path, config, `{"capacity": 30}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", path+"/responses/GET", config, `{"status": 201}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path+"/requests", config, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path+"/tokens", config, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", path+"/token", config, "").Code, "wrong HTTP result code")
+
+	// clear and delete scopes
+	assert.Equal(t, 204, call("DELETE", path+"/requests", cleaner, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 0, basketsDb.Get(basket).Size(), "collected requests are expected to be deleted")
+
+	// revoke token
+	assert.Equal(t, 401, call("DELETE", path+"/tokens/reader", reader, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("DELETE", path+"/tokens/reader", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("DELETE", path+"/tokens/reader", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path+"/requests", reader, "").Code, "revoked token is not expected to be accepted")
+	assert.Equal(t, 200, call("GET", path+"/requests", cleaner, "").Code, "wrong HTTP result code")
+
+	// delete basket
+	assert.Equal(t, 204, call("DELETE", path, cleaner, "").Code, "wrong HTTP result code")
+	assert.Nil(t, basketsDb.Get(basket), "basket is expected to be deleted")
+}
+
+func TestBasketACL(t *testing.T) {
+	basket := "acl01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	manager, err := users.Create("acl_manager", UserConfig{})
+	assert.NoError(t, err)
+	viewer, err := users.Create("acl_viewer", UserConfig{Groups: []string{"acl_qa"}})
+	assert.NoError(t, err)
+	stranger, err := users.Create("acl_stranger", UserConfig{Groups: []string{"acl_dev"}})
+	assert.NoError(t, err)
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// no access before ACL is configured
+	path := "/baskets/" + basket
+	assert.Equal(t, 401, call("GET", path+"/requests", viewer.Token, "").Code, "wrong HTTP result code")
+	w := call("GET", path+"/acl", auth.Token, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Equal(t, "[]", w.Body.String(), "empty ACL is expected")
+	}
+
+	// invalid ACL
+	for _, body := range []string{`[{"access": "view"}]`, `[{"user": "a", "group": "b", "access": "view"}]`,
+		`[{"user": "a b", "access": "view"}]`, `[{"group": "qa", "access": "owner"}]`} {
+		assert.Equal(t, 422, call("PUT", path+"/acl", auth.Token, body).Code, "wrong HTTP result code: %s", body)
+	}
+	assert.Equal(t, 400, call("PUT", path+"/acl", auth.Token, "{").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("PUT", path+"/acl", viewer.Token, "[]").Code, "wrong HTTP result code")
+
+	acl := `[{"user": "acl_manager", "access": "manage"}, {"group": "acl_qa", "access": "view"}]`
+	assert.Equal(t, 204, call("PUT", path+"/acl", auth.Token, acl).Code, "wrong HTTP result code")
+	w = call("GET", path+"/acl", auth.Token, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		entries := []ACLEntry{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		assert.Equal(t, []ACLEntry{{User: "acl_manager", Access: AccessManage}, {Group: "acl_qa", Access: AccessView}},
+			entries, "wrong ACL")
+	}
+
+	// members of group with view access
+	assert.Equal(t, 200, call("GET", path+"/requests", viewer.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", path, viewer.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", path+"/requests", viewer.Token, "").Code, "wrong HTTP result code")
+
+	// user with manage access
+	assert.Equal(t, 200, call("GET", path, manager.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("DELETE", path+"/requests", manager.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/acl", manager.Token, "").Code, "wrong HTTP result code")
+
+	// users out of ACL
+	assert.Equal(t, 401, call("GET", path+"/requests", stranger.Token, "").Code, "wrong HTTP result code")
+
+	// group membership is managed with the master token
+	assert.Equal(t, 422, call("PUT", "/users/acl_stranger", serverConfig.MasterToken, `{"groups": ["a b"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/users/acl_stranger", serverConfig.MasterToken, `{"groups": ["acl_qa"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", path+"/requests", stranger.Token, "").Code, "wrong HTTP result code")
+
+	// manager may delete basket
+	assert.Equal(t, 204, call("DELETE", path, manager.Token, "").Code, "wrong HTTP result code")
+}
+
+func TestRoles(t *testing.T) {
+	basket := "roles01"
+	_, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	issue := func(role string, name string) string {
+		w := call("POST", "/roles/"+role+"/tokens", serverConfig.MasterToken, `{"name": "`+name+`"}`)
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		result := RoleToken{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, role, result.Role, "wrong role")
+		return result.Token
+	}
+
+	// issue service tokens
+	viewer := issue(RoleViewer, "viewer02")
+	operator := issue(RoleOperator, "operator02")
+	admin := issue(RoleAdmin, "admin02")
+	assert.Equal(t, 409, call("POST", "/roles/viewer/tokens", serverConfig.MasterToken, `{"name": "viewer02"}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 404, call("POST", "/roles/guest/tokens", serverConfig.MasterToken, `{"name": "guest02"}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", "/roles/viewer/tokens", serverConfig.MasterToken, `{"name": ""}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", "/roles/admin/tokens", operator, `{"name": "admin03"}`).Code,
+		"wrong HTTP result code")
+
+	// viewer
+	assert.Equal(t, 200, call("GET", "/stats", viewer, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/baskets", viewer, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/baskets/"+basket+"/requests", viewer, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", "/baskets/"+basket+"/requests", viewer, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/roles", viewer, "").Code, "wrong HTTP result code")
+
+	// operator
+	assert.Equal(t, 204, call("DELETE", "/baskets/"+basket+"/requests", operator, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("PUT", "/baskets/"+basket, operator, `{"capacity": 10}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/users", operator, "").Code, "wrong HTTP result code")
+
+	// admin is equivalent to the master token
+	assert.Equal(t, 200, call("GET", "/roles", admin, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/users", admin, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("POST", "/baskets/"+basket+"/rename", admin, `{"name": "roles02"}`).Code,
+		"wrong HTTP result code")
+	basket = "roles02"
+
+	// change permissions of role
+	assert.Equal(t, 403, call("PUT", "/roles/admin", admin, `{"permissions": []}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("PUT", "/roles/viewer", admin, `{"permissions": ["all"]}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("PUT", "/roles/guest", admin, `{"permissions": []}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/roles/operator", admin, `{"permissions": ["write-config"]}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/baskets/"+basket, operator, `{"capacity": 10}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("DELETE", "/baskets/"+basket, operator, "").Code, "wrong HTTP result code")
+	w := call("GET", "/roles", admin, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		list := []Role{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		if assert.Len(t, list, 3, "wrong number of roles") {
+			assert.Equal(t, []string{ScopeWriteConfig}, list[1].Permissions, "wrong permissions of operator")
+		}
+	}
+	roles.Update(RoleOperator, RoleConfig{Permissions: defaultRoles[RoleOperator]})
+
+	// list and revoke service tokens
+	w = call("GET", "/roles/viewer/tokens", serverConfig.MasterToken, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"name":"viewer02"`, "service token is expected")
+		assert.NotContains(t, w.Body.String(), viewer, "token is not expected to be reported")
+	}
+	assert.Equal(t, 204, call("DELETE", "/roles/viewer/tokens/viewer02", serverConfig.MasterToken, "").Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 404, call("DELETE", "/roles/viewer/tokens/viewer02", serverConfig.MasterToken, "").Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/stats", viewer, "").Code, "revoked token is not expected to be accepted")
+}
+
+func TestAPIKeys(t *testing.T) {
+	basket := "apikeys01"
+	_, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// create API key
+	w := call("POST", "/keys", serverConfig.MasterToken, `{"name": "automation01"}`)
+	assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+	key := APIKey{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+	assert.Equal(t, 409, call("POST", "/keys", serverConfig.MasterToken, `{"name": "automation01"}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", "/keys", serverConfig.MasterToken, `{"name": "a b"}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", "/keys", serverConfig.MasterToken, `{`).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", "/keys", "wrong", `{"name": "automation02"}`).Code, "wrong HTTP result code")
+
+	// API key is equivalent to the master token
+	assert.Equal(t, 200, call("GET", "/stats", key.Key, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/users", key.Key, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("GET", "/baskets/"+basket, key.Key, "").Code, "wrong HTTP result code")
+
+	// list keys with time of last use
+	w = call("GET", "/keys", serverConfig.MasterToken, "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		list := []APIKey{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		if assert.Len(t, list, 1, "wrong number of keys") {
+			assert.Equal(t, "automation01", list[0].Name, "wrong key name")
+			assert.Empty(t, list[0].Key, "key is not expected to be reported")
+			assert.NotZero(t, list[0].LastUsed, "time of last use is expected")
+		}
+	}
+
+	// revoke key
+	assert.Equal(t, 204, call("DELETE", "/keys/automation01", key.Key, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("DELETE", "/keys/automation01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("GET", "/stats", key.Key, "").Code, "revoked key is not expected to be accepted")
+	assert.Equal(t, 401, call("GET", "/baskets/"+basket, key.Key, "").Code, "revoked key is not expected to be accepted")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sync"
+)
+
+// collectLocks serialize collecting of requests into baskets that depend on previously collected requests,
+// e.g. every request of hash chained basket is linked to the request collected before it; baskets share
+// a fixed number of locks
+var collectLocks [64]sync.Mutex
+
+// ChainVerification describes result of verification of hash chain of collected requests.
+type ChainVerification struct {
+	Verified  bool   `json:"verified"`
+	Requests  int    `json:"requests"`            // verified chained requests
+	Unchained int    `json:"unchained"`           // requests collected without hash, e.g. before hash chain is enabled
+	First     int    `json:"first,omitempty"`     // ID of the oldest chained request, its predecessor may be evicted
+	Head      string `json:"head,omitempty"`      // hash of the latest chained request
+	BrokenAt  int    `json:"broken_at,omitempty"` // ID of the first request that does not match the chain
+	Error     string `json:"error,omitempty"`
+}
+
+// chainedContent describes content of collected request that is covered by hash, the results of handling
+// the request (e.g. forwarding) are recorded later and are not covered
+type chainedContent struct {
+	PrevHash       string          `json:"prev_hash"`
+	Date           int64           `json:"date"`
+	Header         http.Header     `json:"headers"`
+	ContentLength  int64           `json:"content_length"`
+	Body           string          `json:"body"`
+	Method         string          `json:"method"`
+	Path           string          `json:"path"`
+	Query          string          `json:"query"`
+	BodyEncryption *BodyEncryption `json:"body_encryption,omitempty"`
+}
+
+// chainHash calculates hex encoded SHA-256 hash of collected request linked to the hash of previous request
+func chainHash(data *RequestData) string {
+	content, _ := json.Marshal(chainedContent{data.PrevHash, data.Date, data.Header, data.ContentLength, data.Body,
+		data.Method, data.Path, data.Query, data.BodyEncryption})
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func collectLock(name string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return &collectLocks[h.Sum32()%uint32(len(collectLocks))]
+}
+
+// addChainedRequest adds request to basket and links it to the latest request of basket with hash
+func addChainedRequest(name string, basket Basket, data *RequestData) *RequestData {
+	lock := collectLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	linkRequest(basket, data)
+	return basket.AddRequest(data)
+}
+
+// linkRequest links request to the latest request of basket with hash, basket is expected to be locked
+func linkRequest(basket Basket, data *RequestData) {
+	data.PrevHash = ""
+	if latest := basket.GetRequests(1, 0).Requests; len(latest) > 0 {
+		data.PrevHash = latest[0].Hash
+	}
+	data.Hash = chainHash(data)
+}
+
+// chainLink describes hash chain link of collected request
+type chainLink struct {
+	ID       int
+	Hash     string
+	PrevHash string
+	Matches  bool // request matches its hash
+}
+
+// verifyChain verifies hash chain of collected requests from the oldest to the latest request: every chained
+// request must match its hash and be linked to the previous request, the oldest chained request is trusted
+// to be linked to evicted request
+func verifyChain(basket Basket) ChainVerification {
+	result := ChainVerification{Verified: true}
+
+	// requests are streamed from the latest one, only their links are kept to verify the chain from the oldest one
+	links := make([]chainLink, 0)
+	StreamRequests(basket, nil, exportPageSize, func(page []*RequestData) error {
+		for _, data := range page {
+			links = append(links, chainLink{data.ID, data.Hash, data.PrevHash,
+				len(data.Hash) == 0 || data.Hash == chainHash(data)})
+		}
+		return nil
+	})
+
+	prev := ""
+	for i := len(links) - 1; i >= 0; i-- {
+		data := links[i]
+		if len(data.Hash) == 0 {
+			result.Unchained++
+			prev = ""
+			continue
+		}
+
+		if !data.Matches {
+			result.Verified, result.BrokenAt, result.Error = false, data.ID, "request does not match its hash"
+			return result
+		}
+		if (result.Requests > 0 || result.Unchained > 0) && data.PrevHash != prev {
+			result.Verified, result.BrokenAt, result.Error = false, data.ID, "request is not linked to previous request"
+			return result
+		}
+		if result.Requests == 0 {
+			result.First = data.ID
+		}
+
+		result.Requests++
+		result.Head = data.Hash
+		prev = data.Hash
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChain(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	name := "chain01"
+	db.Create(name, BasketConfig{Capacity: 20, HashChain: true})
+	basket := db.Get(name)
+
+	// requests collected before hash chain is enabled
+	basket.AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+	for i, body := range []string{"first", "second", "third"} {
+		added := addChainedRequest(name, basket, &RequestData{Date: int64(2000 + i), Method: "POST", Path: "/", Body: body})
+		assert.Len(t, added.Hash, 64, "hash is expected")
+	}
+
+	result := verifyChain(basket)
+	assert.True(t, result.Verified, "chain is expected to be verified: %s", result.Error)
+	assert.Equal(t, 3, result.Requests, "wrong number of chained requests")
+	assert.Equal(t, 1, result.Unchained, "wrong number of unchained requests")
+	assert.Equal(t, 2, result.First, "wrong ID of the oldest chained request")
+	assert.Equal(t, basket.GetRequest(4).Hash, result.Head, "wrong head of chain")
+	assert.Equal(t, basket.GetRequest(3).Hash, basket.GetRequest(4).PrevHash, "request is expected to be linked")
+
+	// results of handling are not covered by hash
+	basket.UpdateRequest(3, func(data *RequestData) { data.ForwardStatus = 200 })
+	assert.True(t, verifyChain(basket).Verified, "chain is expected to be verified")
+
+	// modified request
+	basket.UpdateRequest(3, func(data *RequestData) { data.Body = "changed" })
+	result = verifyChain(basket)
+	assert.False(t, result.Verified, "modified request is expected to break chain")
+	assert.Equal(t, 3, result.BrokenAt, "wrong ID of modified request")
+
+	// removed request
+	basket.UpdateRequest(3, func(data *RequestData) { data.Body = "second" })
+	assert.True(t, verifyChain(basket).Verified, "restored request is expected to be verified")
+	basket.DeleteRequests([]int{3})
+	result = verifyChain(basket)
+	assert.False(t, result.Verified, "removed request is expected to break chain")
+	assert.Equal(t, 4, result.BrokenAt, "wrong ID of request after removed request")
+
+	// evicted requests are not required
+	basket.DeleteRequests([]int{1, 2})
+	assert.True(t, verifyChain(basket).Verified, "chain is expected to be verified from the oldest request")
+}
+
+func TestVerifyBasketRequests(t *testing.T) {
+	basket := "chain02"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20, HashChain: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, body := range []string{"one", "two"} {
+		r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	}
+
+	r, _ := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/verify", nil)
+	r.Header.Add("Authorization", auth.Token)
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		result := ChainVerification{}
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result)) {
+			assert.True(t, result.Verified, "chain is expected to be verified")
+			assert.Equal(t, 2, result.Requests, "wrong number of chained requests")
+			assert.Equal(t, basketsDb.Get(basket).GetRequest(2).Hash, result.Head, "wrong head of chain")
+		}
+	}
+}
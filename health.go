@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Statuses of service components
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+const (
+	healthBacklogRatio = 0.9               // share of queue capacity that is reported as backlog
+	healthMinDiskSpace = 100 * 1024 * 1024 // free bytes of database volume below which storage is reported as down
+)
+
+// ComponentHealth describes status of a service component.
+type ComponentHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"` // the service is not alive if critical component is down
+	Message  string `json:"message,omitempty"`
+	Latency  int64  `json:"latency,omitempty"` // milliseconds to check the component
+}
+
+// HealthStatus describes status of the service along with statuses of its components.
+type HealthStatus struct {
+	Status     string             `json:"status"`
+	Components []*ComponentHealth `json:"components"`
+}
+
+// checkHealth checks connectivity of storage, backlogs of event and archive queues and free disk space
+// of database file
+func checkHealth(db BasketsDatabase, config *ServerConfig) []*ComponentHealth {
+	components := make([]*ComponentHealth, 0, 6)
+
+	start := time.Now()
+	storage := &ComponentHealth{Name: "storage", Status: HealthUp, Critical: true}
+	if err := db.Ping(); err != nil {
+		storage.Status, storage.Message = HealthDown, err.Error()
+	}
+	storage.Latency = time.Since(start).Nanoseconds() / toMs
+	components = append(components, storage)
+
+	if config.DbType == DbTypeBolt {
+		components = append(components, checkDiskSpace("disk", filepath.Dir(config.DbFile)))
+	}
+
+	if webhooks != nil {
+		components = append(components, checkQueue("webhooks", len(webhooks.queue), cap(webhooks.queue)))
+		if webhooks.mqtt != nil {
+			components = append(components, checkQueue("mqtt", len(webhooks.mqtt.queue), cap(webhooks.mqtt.queue)))
+		}
+	}
+	if archive != nil {
+		components = append(components, checkQueue("archive", len(archive.queue), cap(archive.queue)))
+	}
+	if tracer != nil {
+		components = append(components, checkQueue("tracing", len(tracer.queue), cap(tracer.queue)))
+	}
+	return components
+}
+
+// checkQueue reports queue as down once its backlog approaches the capacity, so new items would be delayed or dropped
+func checkQueue(name string, length int, capacity int) *ComponentHealth {
+	component := &ComponentHealth{Name: name, Status: HealthUp, Message: fmt.Sprintf("%d of %d queued", length, capacity)}
+	if float64(length) >= healthBacklogRatio*float64(capacity) {
+		component.Status = HealthDown
+	}
+	return component
+}
+
+// checkDiskSpace reports volume of given directory as down if it has less than healthMinDiskSpace bytes available
+func checkDiskSpace(name string, dir string) *ComponentHealth {
+	component := &ComponentHealth{Name: name, Status: HealthUp}
+	free, err := freeDiskSpace(dir)
+	if err == errDiskSpaceUnknown {
+		component.Message = err.Error()
+	} else if err != nil {
+		component.Status, component.Message = HealthDown, err.Error()
+	} else {
+		component.Message = fmt.Sprintf("%d bytes available", free)
+		if free < healthMinDiskSpace {
+			component.Status = HealthDown
+		}
+	}
+	return component
+}
+
+// writeHealth writes status of the service, the service is down if any of the components that matter is down
+func writeHealth(w http.ResponseWriter, components []*ComponentHealth, matters func(c *ComponentHealth) bool) {
+	health := HealthStatus{Status: HealthUp, Components: components}
+	status := http.StatusOK
+	for _, component := range components {
+		if component.Status == HealthDown && matters(component) {
+			health.Status = HealthDown
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	json, err := json.Marshal(health)
+	writeJSON(w, status, json, err)
+}
+
+// GetHealth handles HTTP request of liveness probe, the service is alive unless its storage is down
+func GetHealth(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	writeHealth(w, checkHealth(basketsDb, serverConfig), func(c *ComponentHealth) bool { return c.Critical })
+}
+
+// GetReadiness handles HTTP request of readiness probe, the service is ready to accept requests if all of its
+// components are up, i.e. storage is available, queues have no backlog and there is enough disk space
+func GetReadiness(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	writeHealth(w, checkHealth(basketsDb, serverConfig), func(c *ComponentHealth) bool { return true })
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenDatabase is a database which connectivity is lost
+type brokenDatabase struct {
+	BasketsDatabase
+}
+
+func (db *brokenDatabase) Ping() error {
+	return errors.New("connection refused")
+}
+
+func TestCheckQueue(t *testing.T) {
+	assert.Equal(t, HealthUp, checkQueue("webhooks", 10, 1000).Status, "queue is expected to be up")
+	component := checkQueue("webhooks", 950, 1000)
+	assert.Equal(t, HealthDown, component.Status, "queue with backlog is expected to be down")
+	assert.Equal(t, "950 of 1000 queued", component.Message, "wrong message")
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	component := checkDiskSpace("disk", os.TempDir())
+	assert.Equal(t, HealthUp, component.Status, "temporary directory is expected to have free space")
+	assert.NotEmpty(t, component.Message, "available space is expected to be reported")
+}
+
+func TestGetHealth(t *testing.T) {
+	probe := func(path string) (int, HealthStatus) {
+		r, _ := http.NewRequest("GET", "http://localhost:55555"+path, nil)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		health := HealthStatus{}
+		json.Unmarshal(w.Body.Bytes(), &health)
+		return w.Code, health
+	}
+
+	code, health := probe("/healthz")
+	assert.Equal(t, 200, code, "wrong HTTP result code")
+	assert.Equal(t, HealthUp, health.Status, "service is expected to be up")
+	if assert.NotEmpty(t, health.Components, "components are expected") {
+		assert.Equal(t, "storage", health.Components[0].Name, "storage is expected to be checked first")
+	}
+
+	code, health = probe("/readyz")
+	assert.Equal(t, 200, code, "wrong HTTP result code")
+	assert.Equal(t, HealthUp, health.Status, "service is expected to be ready")
+
+	// backlog of queue makes service not ready, but keeps it alive
+	defer func(current *webhookDispatcher) { webhooks = current }(webhooks)
+	webhooks = newWebhookDispatcher()
+	for i := 0; i < webhookQueueSize; i++ {
+		webhooks.queue <- nil
+	}
+	code, _ = probe("/healthz")
+	assert.Equal(t, 200, code, "service with backlog is expected to be alive")
+	code, health = probe("/readyz")
+	assert.Equal(t, 503, code, "service with backlog is not expected to be ready")
+	assert.Equal(t, HealthDown, health.Status, "service is expected to be down")
+}
+
+func TestGetHealth_StorageDown(t *testing.T) {
+	defer func(current BasketsDatabase) { basketsDb = current }(basketsDb)
+	basketsDb = &brokenDatabase{basketsDb}
+
+	r, _ := http.NewRequest("GET", "http://localhost:55555/healthz", nil)
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, 503, w.Code, "service without storage is not expected to be alive")
+
+	health := HealthStatus{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health)) && assert.NotEmpty(t, health.Components) {
+		assert.Equal(t, HealthDown, health.Components[0].Status, "storage is expected to be down")
+		assert.Equal(t, "connection refused", health.Components[0].Message, "wrong message")
+	}
+}
+
+func TestCreateBasket_HealthPath(t *testing.T) {
+	status, err := validateNewBasketName(serviceHealthPath)
+	assert.Equal(t, http.StatusForbidden, status, "name of health probe is expected to be reserved")
+	assert.Error(t, err)
+	status, _ = validateNewBasketName(serviceReadyPath)
+	assert.Equal(t, http.StatusForbidden, status, "name of readiness probe is expected to be reserved")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// highlightMaxMatches defines maximum number of reported matches within a single request field
+const highlightMaxMatches = 20
+
+// RequestMatch describes a part of request that is matched by search query, offsets are in bytes.
+type RequestMatch struct {
+	Field  string `json:"field"`            // body, query or headers
+	Header string `json:"header,omitempty"` // header name if matched in headers
+	Index  int    `json:"index,omitempty"`  // index of header value if header has multiple values
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+// Highlight fills in matches of query text within found requests; JSONPath queries and queries without
+// text report the whole body or no matches, since they do not match a particular substring
+func (page *RequestsQueryPage) Highlight(query *RequestsQuery) {
+	page.Highlights = make([][]*RequestMatch, len(page.Requests))
+	for i, req := range page.Requests {
+		page.Highlights[i] = query.Highlight(req)
+	}
+}
+
+// Highlight returns parts of request that are matched by query text
+func (query *RequestsQuery) Highlight(req *RequestData) []*RequestMatch {
+	matches := make([]*RequestMatch, 0)
+	if query.json != nil {
+		if len(req.Body) > 0 {
+			matches = append(matches, &RequestMatch{Field: "body", Start: 0, End: len(req.Body)})
+		}
+		return matches
+	}
+	if len(query.Text) == 0 {
+		return matches
+	}
+
+	inBody, inQuery, inHeaders := searchFields(query.In)
+	if inBody {
+		for _, loc := range query.find(req.Body, highlightMaxMatches) {
+			matches = append(matches, &RequestMatch{Field: "body", Start: loc[0], End: loc[1]})
+		}
+	}
+	if inQuery {
+		for _, loc := range query.find(req.Query, highlightMaxMatches) {
+			matches = append(matches, &RequestMatch{Field: "query", Start: loc[0], End: loc[1]})
+		}
+	}
+	if inHeaders {
+		// headers are reported in stable order
+		names := make([]string, 0, len(req.Header))
+		for name := range req.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for index, val := range req.Header[name] {
+				for _, loc := range query.find(val, highlightMaxMatches) {
+					matches = append(matches, &RequestMatch{Field: "headers", Header: name, Index: index, Start: loc[0], End: loc[1]})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// find returns locations of up to max non-overlapping matches of query text in value
+func (query *RequestsQuery) find(value string, max int) [][]int {
+	if query.regex != nil {
+		return query.regex.FindAllStringIndex(value, max)
+	}
+
+	locations := make([][]int, 0)
+	for offset := 0; len(locations) < max; {
+		i := strings.Index(value[offset:], query.Text)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		locations = append(locations, []int{start, start + len(query.Text)})
+		offset = start + len(query.Text)
+	}
+	return locations
+}
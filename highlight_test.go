@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestsQuery_Highlight(t *testing.T) {
+	data := new(RequestData)
+	data.Body = "{\"token\":\"abc\",\"Token\":\"xyz\"}"
+	data.Query = "token=abc"
+	data.Header = make(http.Header)
+	data.Header.Add("X-Token", "none")
+	data.Header.Add("Accept", "text/plain")
+	data.Header.Add("Accept", "abc/token")
+
+	query := NewTextQuery("token", "any")
+	// header names are not matched, so "X-Token" header is not reported
+	matches := query.Highlight(data)
+	if assert.Len(t, matches, 3, "wrong number of matches") {
+		assert.Equal(t, RequestMatch{Field: "body", Start: 2, End: 7}, *matches[0])
+		assert.Equal(t, RequestMatch{Field: "query", Start: 0, End: 5}, *matches[1])
+		assert.Equal(t, RequestMatch{Field: "headers", Header: "Accept", Index: 1, Start: 4, End: 9}, *matches[2])
+		assert.Equal(t, data.Body[matches[0].Start:matches[0].End], "token")
+	}
+	// case-insensitive matching
+	query = NewTextQuery("token", "body")
+	query.SetOptions(true, false)
+	matches = query.Highlight(data)
+	if assert.Len(t, matches, 2, "wrong number of matches") {
+		assert.Equal(t, "Token", data.Body[matches[1].Start:matches[1].End])
+	}
+
+	// regular expression
+	query, _ = NewRequestsQuery("[a-z]{3}\"", "body", QueryTypeRegex)
+	matches = query.Highlight(data)
+	if assert.Len(t, matches, 4, "wrong number of matches") {
+		assert.Equal(t, "xyz\"", data.Body[matches[3].Start:matches[3].End])
+	}
+
+	// JSONPath matches whole body
+	query, _ = NewRequestsQuery("$.token", "", QueryTypeJSONPath)
+	matches = query.Highlight(data)
+	if assert.Len(t, matches, 1, "wrong number of matches") {
+		assert.Equal(t, len(data.Body), matches[0].End)
+	}
+
+	// no text
+	assert.Empty(t, NewTextQuery("", "any").Highlight(data))
+}
+
+func TestRequestsQuery_Highlight_MaxMatches(t *testing.T) {
+	data := new(RequestData)
+	for i := 0; i < 30; i++ {
+		data.Body += "aa"
+	}
+
+	matches := NewTextQuery("aa", "body").Highlight(data)
+	if assert.Len(t, matches, highlightMaxMatches, "wrong number of matches") {
+		// matches do not overlap
+		assert.Equal(t, 2, matches[1].Start)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Token index is used to find candidate requests for substring search without scanning through the whole basket.
+// Request body, query and header values are split into lower-cased alphanumeric tokens; a substring query is split
+// the same way and every query token must be a substring of some token of the matching request. The index yields
+// a superset of matching requests, the final decision is always made by RequestData.Matches.
+const (
+	// indexTokenMaxLength defines maximum length of indexed token, longer tokens are indexed as overlapping chunks
+	indexTokenMaxLength = 64
+	// indexTokenChunkStep defines step between chunks of long tokens
+	indexTokenChunkStep = indexTokenMaxLength / 2
+	// indexQueryTokenMaxLength defines maximum length of query token that is guaranteed to be found within a chunk
+	indexQueryTokenMaxLength = indexTokenChunkStep
+)
+
+// tokenize splits text into lower-cased alphanumeric tokens
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// IndexTokens returns unique tokens of request body, query and header values for the token index
+func (req *RequestData) IndexTokens() []string {
+	unique := make(map[string]bool)
+	add := func(text string) {
+		for _, token := range tokenize(text) {
+			if len(token) <= indexTokenMaxLength {
+				unique[token] = true
+				continue
+			}
+			for i := 0; i < len(token)-indexTokenChunkStep; i += indexTokenChunkStep {
+				end := i + indexTokenMaxLength
+				if end > len(token) {
+					end = len(token)
+				}
+				unique[token[i:end]] = true
+			}
+		}
+	}
+
+	add(req.Body)
+	add(req.Query)
+	for _, vals := range req.Header {
+		for _, val := range vals {
+			add(val)
+		}
+	}
+
+	tokens := make([]string, 0, len(unique))
+	for token := range unique {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// IndexTokens returns tokens of the query that can be looked up in the token index,
+// returns false if the index cannot be used to answer this query
+func (query *RequestsQuery) IndexTokens() ([]string, bool) {
+	if query.Type != QueryTypeSubstring || len(query.Text) == 0 {
+		return nil, false
+	}
+
+	tokens := tokenize(query.Text)
+	if len(tokens) == 0 {
+		return nil, false
+	}
+	for _, token := range tokens {
+		if len(token) > indexQueryTokenMaxLength {
+			return nil, false
+		}
+	}
+
+	return tokens, true
+}
+
+// tokenIndex is an in-memory token index of requests
+type tokenIndex struct {
+	postings map[string]map[*RequestData]bool
+	order    map[*RequestData]int
+	next     int
+}
+
+func newTokenIndex() *tokenIndex {
+	return &tokenIndex{postings: make(map[string]map[*RequestData]bool), order: make(map[*RequestData]int)}
+}
+
+// Add adds request to the index, requests are expected to be added in chronological order
+func (idx *tokenIndex) Add(req *RequestData) {
+	idx.next++
+	idx.order[req] = idx.next
+	idx.addPostings(req)
+}
+
+// Remove removes request from the index
+func (idx *tokenIndex) Remove(req *RequestData) {
+	delete(idx.order, req)
+	for _, token := range req.IndexTokens() {
+		if posting, exists := idx.postings[token]; exists {
+			delete(posting, req)
+			if len(posting) == 0 {
+				delete(idx.postings, token)
+			}
+		}
+	}
+}
+
+// Replace replaces indexed request with its updated version keeping its position in chronological order
+func (idx *tokenIndex) Replace(old *RequestData, req *RequestData) {
+	order, exists := idx.order[old]
+	if !exists {
+		return
+	}
+
+	idx.Remove(old)
+	idx.order[req] = order
+	idx.addPostings(req)
+}
+
+func (idx *tokenIndex) addPostings(req *RequestData) {
+	for _, token := range req.IndexTokens() {
+		posting, exists := idx.postings[token]
+		if !exists {
+			posting = make(map[*RequestData]bool)
+			idx.postings[token] = posting
+		}
+		posting[req] = true
+	}
+}
+
+// Candidates returns requests that contain every query token as a substring of some of their tokens,
+// requests are sorted from newest to oldest
+func (idx *tokenIndex) Candidates(tokens []string) []*RequestData {
+	var result map[*RequestData]bool
+	for _, token := range uniqueStrings(tokens) {
+		found := make(map[*RequestData]bool)
+		for indexed, posting := range idx.postings {
+			if strings.Contains(indexed, token) {
+				for req := range posting {
+					if result == nil || result[req] {
+						found[req] = true
+					}
+				}
+			}
+		}
+
+		result = found
+		if len(result) == 0 {
+			break
+		}
+	}
+
+	candidates := make([]*RequestData, 0, len(result))
+	for req := range result {
+		candidates = append(candidates, req)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return idx.order[candidates[i]] > idx.order[candidates[j]] })
+
+	return candidates
+}
+
+func uniqueStrings(values []string) []string {
+	unique := make([]string, 0, len(values))
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	// look up longer (more selective) tokens first
+	sort.SliceStable(unique, func(i, j int) bool { return len(unique[i]) > len(unique[j]) })
+	return unique
+}
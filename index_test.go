@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"order", "id", "42", "état"}, tokenize("Order_ID: 42, État"))
+	assert.Empty(t, tokenize(" {}[]: "))
+}
+
+func TestRequestData_IndexTokens(t *testing.T) {
+	data := new(RequestData)
+	data.Header = http.Header{"Content-Type": []string{"application/json"}}
+	data.Body = "{\"event\":\"created\",\"event_id\":1}"
+	data.Query = "id=15"
+
+	tokens := data.IndexTokens()
+	assert.ElementsMatch(t, []string{"application", "json", "event", "created", "id", "1", "15"}, tokens)
+
+	// long tokens are indexed as overlapping chunks
+	data = new(RequestData)
+	data.Body = strings.Repeat("a", 40) + strings.Repeat("b", 40)
+	tokens = data.IndexTokens()
+	assert.Len(t, tokens, 2, "wrong number of chunks")
+	for _, token := range tokens {
+		assert.True(t, len(token) <= indexTokenMaxLength, "token is too long: %s", token)
+	}
+}
+
+func TestRequestsQuery_IndexTokens(t *testing.T) {
+	tokens, ok := NewTextQuery("Order-15", "any").IndexTokens()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"order", "15"}, tokens)
+
+	_, ok = NewTextQuery("{}", "any").IndexTokens()
+	assert.False(t, ok, "query without tokens may not use index")
+
+	_, ok = NewTextQuery(strings.Repeat("x", indexQueryTokenMaxLength+1), "any").IndexTokens()
+	assert.False(t, ok, "query with long tokens may not use index")
+
+	query, _ := NewRequestsQuery("[0-9]+", "any", QueryTypeRegex)
+	_, ok = query.IndexTokens()
+	assert.False(t, ok, "regex query may not use index")
+}
+
+func TestTokenIndex_Candidates(t *testing.T) {
+	idx := newTokenIndex()
+	reqs := make([]*RequestData, 0)
+	for _, body := range []string{"order created", "order paid", "invoice paid", strings.Repeat("z", 50) + "needle" + strings.Repeat("z", 50)} {
+		req := &RequestData{Body: body, Header: make(http.Header)}
+		idx.Add(req)
+		reqs = append(reqs, req)
+	}
+
+	assert.Equal(t, []*RequestData{reqs[1], reqs[0]}, idx.Candidates([]string{"order"}), "newest candidates are expected first")
+	assert.Equal(t, []*RequestData{reqs[1]}, idx.Candidates([]string{"ord", "aid"}))
+	assert.Equal(t, []*RequestData{reqs[3]}, idx.Candidates([]string{"needle"}), "token is expected to be found in long token")
+	assert.Empty(t, idx.Candidates([]string{"order", "invoice"}))
+
+	idx.Remove(reqs[1])
+	assert.Equal(t, []*RequestData{reqs[2]}, idx.Candidates([]string{"paid"}))
+	assert.NotNil(t, idx.postings["created"], "posting is expected")
+	idx.Remove(reqs[0])
+	assert.Nil(t, idx.postings["created"], "empty posting is expected to be removed")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultMaxCaptures       = 1000 // in-flight captures of all baskets
+	defaultMaxBasketCaptures = 100  // in-flight captures of a single basket
+)
+
+var captures = newCaptureSlots(defaultMaxCaptures, defaultMaxBasketCaptures)
+
+// captureSlots limits number of requests that are collected simultaneously by a basket and by the service
+// overall, so slow uploads to one basket cannot exhaust connections and file descriptors of the service;
+// 0 stands for no limit
+type captureSlots struct {
+	sync.Mutex
+	total     int
+	baskets   map[string]int
+	maxTotal  int
+	maxBasket int
+}
+
+func newCaptureSlots(maxTotal int, maxBasket int) *captureSlots {
+	return &captureSlots{baskets: make(map[string]int), maxTotal: maxTotal, maxBasket: maxBasket}
+}
+
+// Acquire takes a capture slot of basket, returns false if basket or the service has no free slots
+func (c *captureSlots) Acquire(basket string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if (c.maxTotal > 0 && c.total >= c.maxTotal) || (c.maxBasket > 0 && c.baskets[basket] >= c.maxBasket) {
+		return false
+	}
+	c.total++
+	c.baskets[basket]++
+	return true
+}
+
+// Release returns capture slot taken by basket
+func (c *captureSlots) Release(basket string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.total--
+	if c.baskets[basket]--; c.baskets[basket] <= 0 {
+		delete(c.baskets, basket)
+	}
+}
+
+// InFlight returns number of requests collected by basket at the moment
+func (c *captureSlots) InFlight(basket string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.baskets[basket]
+}
+
+// acquireCapture takes a capture slot of basket for the time of collecting request, the request beyond
+// the limits is rejected with 503 status; writes HTTP response and returns false in case of failure
+func acquireCapture(w http.ResponseWriter, name string) bool {
+	if captures.Acquire(name) {
+		return true
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "too many concurrent requests to basket, retry later", http.StatusServiceUnavailable)
+	return false
+}
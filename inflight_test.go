@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureSlots(t *testing.T) {
+	c := newCaptureSlots(3, 2)
+
+	assert.True(t, c.Acquire("slots01"))
+	assert.True(t, c.Acquire("slots01"))
+	assert.False(t, c.Acquire("slots01"), "limit of basket is expected")
+	assert.Equal(t, 2, c.InFlight("slots01"), "wrong number of in-flight captures")
+
+	assert.True(t, c.Acquire("slots02"))
+	assert.False(t, c.Acquire("slots03"), "limit of service is expected")
+
+	c.Release("slots01")
+	assert.True(t, c.Acquire("slots03"), "released slot is expected to be available")
+	c.Release("slots02")
+	assert.Equal(t, 0, c.InFlight("slots02"), "wrong number of in-flight captures")
+
+	unlimited := newCaptureSlots(0, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, unlimited.Acquire("slots01"), "unlimited captures are expected")
+	}
+}
+
+func TestAcceptBasketRequests_Captures(t *testing.T) {
+	defer func(c *captureSlots) { captures = c }(captures)
+	captures = newCaptureSlots(0, 1)
+
+	basket := "captures01"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+	send := func() *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("data"))
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// a slow upload takes the only slot of basket
+	captures.Acquire(basket)
+	w := send()
+	if assert.Equal(t, 503, w.Code, "wrong HTTP result code") {
+		assert.Equal(t, "1", w.Header().Get("Retry-After"), "Retry-After header is expected")
+	}
+	assert.Equal(t, 0, basketsDb.Get(basket).Size(), "rejected request is not expected to be collected")
+
+	captures.Release(basket)
+	assert.Equal(t, 200, send().Code, "wrong HTTP result code")
+	assert.Equal(t, 0, captures.InFlight(basket), "slot is expected to be released after capture")
+}
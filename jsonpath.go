@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a single step of JSONPath: object member, array index or wildcard
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPathQuery is a simplified JSONPath expression with optional comparison,
+// e.g. `$.event.type == "payment.failed"`, `$.items[*].price > 100` or `$.data.id` (presence check)
+type jsonPathQuery struct {
+	steps []jsonPathStep
+	op    string
+	value interface{}
+}
+
+var jsonPathOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// parseJSONPath parses JSONPath query expression
+func parseJSONPath(expr string) (*jsonPathQuery, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid JSONPath: '%s', expression must start with '$'", expr)
+	}
+
+	query := new(jsonPathQuery)
+	rest, err := query.parseSteps(expr[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath: '%s' - %s", expr, err)
+	}
+
+	rest = strings.TrimSpace(rest)
+	if len(rest) == 0 {
+		return query, nil
+	}
+
+	for _, op := range jsonPathOperators {
+		if strings.HasPrefix(rest, op) {
+			query.op = op
+			break
+		}
+	}
+	if len(query.op) == 0 {
+		return nil, fmt.Errorf("invalid JSONPath: '%s' - unknown operator: %s", expr, rest)
+	}
+
+	literal := strings.TrimSpace(rest[len(query.op):])
+	if len(literal) > 1 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		literal = strconv.Quote(literal[1 : len(literal)-1])
+	}
+	if err := json.Unmarshal([]byte(literal), &query.value); err != nil {
+		return nil, fmt.Errorf("invalid JSONPath: '%s' - invalid value: %s", expr, literal)
+	}
+
+	switch query.value.(type) {
+	case float64, string, bool, nil:
+		return query, nil
+	default:
+		return nil, fmt.Errorf("invalid JSONPath: '%s' - only strings, numbers, booleans and null can be compared", expr)
+	}
+}
+
+// parseSteps parses path steps and returns the remaining part of expression
+func (query *jsonPathQuery) parseSteps(path string) (string, error) {
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			end := 1
+			for end < len(path) && isJSONPathKeyChar(path[end]) {
+				end++
+			}
+			key := path[1:end]
+			switch key {
+			case "":
+				return path, fmt.Errorf("member name is expected at: %s", path)
+			case "*":
+				query.steps = append(query.steps, jsonPathStep{wildcard: true})
+			default:
+				query.steps = append(query.steps, jsonPathStep{key: key})
+			}
+			path = path[end:]
+		case '[':
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return path, fmt.Errorf("missing ']' at: %s", path)
+			}
+			selector := strings.TrimSpace(path[1:end])
+			if selector == "*" {
+				query.steps = append(query.steps, jsonPathStep{wildcard: true})
+			} else if len(selector) > 1 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				query.steps = append(query.steps, jsonPathStep{key: selector[1 : len(selector)-1]})
+			} else if index, err := strconv.Atoi(selector); err == nil {
+				query.steps = append(query.steps, jsonPathStep{index: index, isIndex: true})
+			} else {
+				return path, fmt.Errorf("invalid selector: [%s]", selector)
+			}
+			path = path[end+1:]
+		default:
+			return path, nil
+		}
+	}
+	return path, nil
+}
+
+func isJSONPathKeyChar(c byte) bool {
+	return c == '_' || c == '-' || c == '*' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Match checks if JSON document matches the query, non JSON documents never match
+func (query *jsonPathQuery) Match(document string) bool {
+	var root interface{}
+	if err := json.Unmarshal([]byte(document), &root); err != nil {
+		return false
+	}
+
+	for _, value := range query.Select(root) {
+		if len(query.op) == 0 || compareJSONValues(value, query.op, query.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns all values selected by query path
+func (query *jsonPathQuery) Select(root interface{}) []interface{} {
+	values := []interface{}{root}
+	for _, step := range query.steps {
+		next := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, item := range v {
+						next = append(next, item)
+					}
+				} else if item, exists := v[step.key]; exists && !step.isIndex {
+					next = append(next, item)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex {
+					index := step.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func compareJSONValues(actual interface{}, op string, expected interface{}) bool {
+	switch e := expected.(type) {
+	case float64:
+		if a, ok := actual.(float64); ok {
+			return compareOrdered(op, a < e, a == e)
+		}
+	case string:
+		if a, ok := actual.(string); ok {
+			return compareOrdered(op, a < e, a == e)
+		}
+	default:
+		// booleans and null support equality only
+		switch op {
+		case "==":
+			return actual == expected
+		case "!=":
+			return actual != expected
+		}
+		return false
+	}
+
+	// values of different types are never equal
+	return op == "!="
+}
+
+func compareOrdered(op string, less bool, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonPathTestDocument = `{
+	"event": { "type": "payment.failed", "attempt": 3, "retry": true, "reason": null },
+	"items": [ { "sku": "A-1", "price": 25.5 }, { "sku": "B-2", "price": 120 } ],
+	"meta-data": { "source": "stripe" }
+}`
+
+func TestJSONPath_Match(t *testing.T) {
+	for expr, expected := range map[string]bool{
+		`$.event.type == "payment.failed"`:  true,
+		`$.event.type == 'payment.failed'`:  true,
+		`$.event.type != "payment.failed"`:  false,
+		`$.event.type`:                      true,
+		`$.event.missing`:                   false,
+		`$.event.attempt >= 3`:              true,
+		`$.event.attempt > 3`:               false,
+		`$.event.attempt == "3"`:            false,
+		`$.event.retry == true`:             true,
+		`$.event.reason == null`:            true,
+		`$.items[1].sku == "B-2"`:           true,
+		`$.items[-1].price == 120`:          true,
+		`$.items[5].price`:                  false,
+		`$.items[*].price > 100`:            true,
+		`$.items[*].price > 200`:            false,
+		`$['meta-data'].source == "stripe"`: true,
+		`$.meta-data.source == "stripe"`:    true,
+		`$.*.source == "stripe"`:            true,
+		`$.event.type < "r"`:                true,
+	} {
+		query, err := parseJSONPath(expr)
+		if assert.NoError(t, err, "failed to parse: %s", expr) {
+			assert.Equal(t, expected, query.Match(jsonPathTestDocument), "wrong match of expression: %s", expr)
+		}
+	}
+}
+
+func TestJSONPath_Match_NotJSON(t *testing.T) {
+	query, err := parseJSONPath(`$.event`)
+	if assert.NoError(t, err) {
+		assert.False(t, query.Match("event=created"))
+	}
+}
+
+func TestJSONPath_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		`event.type == "created"`,
+		`$.`,
+		`$.items[1`,
+		`$.items[x]`,
+		`$.event.type = "created"`,
+		`$.event.type == created`,
+		`$.event == {}`,
+	} {
+		_, err := parseJSONPath(expr)
+		assert.Error(t, err, "error is expected for expression: %s", expr)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtClockSkew       = time.Minute
+	jwtKeysInterval    = time.Minute // minimum interval between fetching signing keys
+	jwtWellKnownKeySet = "/.well-known/jwks.json"
+)
+
+var jwt *jwtAuthenticator
+
+type jwtClaimsKey struct{}
+
+// jsonWebKeySet caches public keys of JWKS document, keys are fetched again once a token is signed with unknown key,
+// e.g. once the issuer rotates its keys
+type jsonWebKeySet struct {
+	sync.Mutex
+	client  *http.Client
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// jwtAuthenticator authorizes API calls with signed JSON web tokens of configured issuer, claims of the token
+// are mapped to access to baskets or to the master token if token matches admin rules
+type jwtAuthenticator struct {
+	issuer   string
+	keysURL  string
+	audience string
+	claim    string // claim that lists accessible baskets
+	admins   []claimRule
+	keySet   *jsonWebKeySet
+}
+
+// newJSONWebKeySet creates empty cache of public keys
+func newJSONWebKeySet(client *http.Client) *jsonWebKeySet {
+	return &jsonWebKeySet{client: client, keys: make(map[string]crypto.PublicKey)}
+}
+
+// Key returns public key by its ID, keys are fetched from JWKS document at URL if the key is unknown
+func (s *jsonWebKeySet) Key(keysURL string, kid string) (crypto.PublicKey, error) {
+	s.Lock()
+	key, found := s.keys[kid]
+	outdated := time.Since(s.fetched) >= jwtKeysInterval
+	s.Unlock()
+	if found {
+		return key, nil
+	}
+	if !outdated {
+		return nil, fmt.Errorf("token is signed with unknown key: %s", kid)
+	}
+
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := fetchJSON(s.client, keysURL, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if public, err := jwk.PublicKey(); err == nil && (len(jwk.Use) == 0 || jwk.Use == "sig") {
+			keys[jwk.Kid] = public
+		}
+	}
+
+	s.Lock()
+	s.keys = keys
+	s.fetched = time.Now()
+	s.Unlock()
+
+	if key, found = keys[kid]; !found {
+		return nil, fmt.Errorf("token is signed with unknown key: %s", kid)
+	}
+	return key, nil
+}
+
+// newJWTAuthenticator creates authenticator of JSON web tokens from server configuration
+func newJWTAuthenticator(config *ServerConfig) (*jwtAuthenticator, error) {
+	issuer := strings.TrimSuffix(config.JWTIssuer, "/")
+	keysURL := config.JWTKeys
+	if len(keysURL) == 0 {
+		keysURL = issuer + jwtWellKnownKeySet
+	}
+	if u, err := url.Parse(keysURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid URL of JWT signing keys: %s", keysURL)
+	}
+
+	a := &jwtAuthenticator{
+		issuer:   issuer,
+		keysURL:  keysURL,
+		audience: config.JWTAudience,
+		claim:    config.JWTBasketsClaim,
+		keySet:   newJSONWebKeySet(&http.Client{Timeout: 10 * time.Second})}
+	for _, rule := range config.JWTAdmins {
+		parsed, err := parseClaimRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		a.admins = append(a.admins, parsed)
+	}
+
+	return a, nil
+}
+
+// Verify checks signature, issuer, audience and validity time of token and returns its claims
+func (a *jwtAuthenticator) Verify(token string) (map[string]interface{}, error) {
+	claims, err := verifyTokenSignature(token, func(kid string) (crypto.PublicKey, error) {
+		return a.keySet.Key(a.keysURL, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims["iss"] != a.issuer {
+		return nil, fmt.Errorf("token is issued by unexpected issuer: %v", claims["iss"])
+	}
+	if len(a.audience) > 0 && !hasAudience(claims, a.audience) {
+		return nil, fmt.Errorf("token is issued for other audience")
+	}
+	if err = checkTokenTime(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// IsAdmin checks if claims match admin rules
+func (a *jwtAuthenticator) IsAdmin(claims map[string]interface{}) bool {
+	return matchesAny(a.admins, claims)
+}
+
+// Authorize checks if claims grant access to basket: the baskets claim lists the basket name for full access or
+// the name with scope, e.g. "basket01:read", for access within the scope; the claim is either a list or a string
+// of space separated values
+func (a *jwtAuthenticator) Authorize(claims map[string]interface{}, basket string, scope string) bool {
+	var values []string
+	switch claim := claims[a.claim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []interface{}:
+		for _, item := range claim {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+
+	for _, value := range values {
+		if value == basket || (len(scope) > 0 && value == basket+":"+scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTokenSignature checks RS256 or ES256 signature of JSON web token with public key resolved by its ID
+// and returns claims of the token, the claims are not validated
+func verifyTokenSignature(token string, key func(kid string) (crypto.PublicKey, error)) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+	public, err := key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	valid := false
+	switch header.Alg {
+	case "RS256":
+		if rsaKey, ok := public.(*rsa.PublicKey); ok {
+			valid = rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, hash[:], signature) == nil
+		}
+	case "ES256":
+		if ecKey, ok := public.(*ecdsa.PublicKey); ok && len(signature) == 64 {
+			valid = ecdsa.Verify(ecKey, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm: %s", header.Alg)
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	claims := make(map[string]interface{})
+	if err = decodeTokenPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkTokenTime checks that token is not expired and is already valid
+func checkTokenTime(claims map[string]interface{}) error {
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || now > exp+jwtClockSkew.Seconds() {
+		return fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf-jwtClockSkew.Seconds() {
+		return fmt.Errorf("token is not valid yet")
+	}
+	return nil
+}
+
+// hasAudience checks if token is issued for the audience
+func hasAudience(claims map[string]interface{}, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchJSON gets JSON document, e.g. discovery document or signing keys of token issuer
+func fetchJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %s", url, err)
+	}
+	return nil
+}
+
+// authenticateBearer verifies JSON web token presented as bearer token, tokens of configured JWT issuer authorize
+// access to baskets listed in their claims, identity tokens of OpenID Connect provider are mapped to user accounts;
+// returns request that handlers authorize as usual
+func authenticateBearer(r *http.Request, token string) (*http.Request, error) {
+	if jwt != nil {
+		claims, err := jwt.Verify(token)
+		if err == nil {
+			if jwt.IsAdmin(claims) {
+				r.Header.Set("Authorization", serverConfig.MasterToken)
+				return r, nil
+			}
+			return r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, claims)), nil
+		}
+		if oidc == nil {
+			return nil, err
+		}
+	}
+
+	claims, err := oidc.Verify(token, "")
+	if err == nil {
+		token, err = oidc.Identify(claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", token)
+	return r, nil
+}
+
+// isJWTAuthorized checks if request presents JSON web token that grants access to basket within the scope,
+// empty scope stands for full access to basket
+func isJWTAuthorized(r *http.Request, basket string, scope string) bool {
+	claims, ok := r.Context().Value(jwtClaimsKey{}).(map[string]interface{})
+	return ok && jwt != nil && jwt.Authorize(claims, basket, scope)
+}
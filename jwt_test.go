@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWTClaims returns valid claims of token issued by fake provider with access to baskets
+func testJWTClaims(server *testOIDCServer, baskets ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"iss": server.URL, "aud": "api01", "sub": "service01", "baskets": baskets,
+		"exp": time.Now().Add(time.Hour).Unix()}
+}
+
+// testJWTAuthenticator creates authenticator that accepts tokens of fake provider
+func testJWTAuthenticator(t *testing.T, server *testOIDCServer) *jwtAuthenticator {
+	a, err := newJWTAuthenticator(&ServerConfig{JWTIssuer: server.URL, JWTKeys: server.URL + "/keys",
+		JWTAudience: "api01", JWTBasketsClaim: defaultJWTClaim, JWTAdmins: []string{"scope=admin"}})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return a
+}
+
+func TestNewJWTAuthenticator(t *testing.T) {
+	a, err := newJWTAuthenticator(&ServerConfig{JWTIssuer: "https://auth.example.com/"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://auth.example.com", a.issuer, "wrong issuer")
+		assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", a.keysURL, "default keys URL is expected")
+	}
+
+	_, err = newJWTAuthenticator(&ServerConfig{JWTIssuer: "auth.example.com"})
+	assert.Error(t, err, "invalid URL of keys is not expected")
+	_, err = newJWTAuthenticator(&ServerConfig{JWTIssuer: "https://auth.example.com", JWTAdmins: []string{"admin"}})
+	assert.Error(t, err, "invalid admin rule is not expected")
+}
+
+func TestJWTAuthenticator_Verify(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	a := testJWTAuthenticator(t, server)
+
+	claims, err := a.Verify(server.Sign("key01", testJWTClaims(server, "jwt01")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "service01", claims["sub"], "wrong claims")
+	}
+
+	invalid := map[string]func(map[string]interface{}){
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://other.example.com" },
+		"audience": func(c map[string]interface{}) { c["aud"] = []string{"api02"} },
+		"expired":  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * jwtClockSkew).Unix() },
+		"early":    func(c map[string]interface{}) { c["nbf"] = time.Now().Add(2 * jwtClockSkew).Unix() }}
+	for name, modify := range invalid {
+		claims := testJWTClaims(server, "jwt01")
+		modify(claims)
+		_, err = a.Verify(server.Sign("key01", claims))
+		assert.Error(t, err, "invalid token is not expected: %s", name)
+	}
+
+	_, err = a.Verify(server.Sign("key02", testJWTClaims(server, "jwt01")))
+	assert.EqualError(t, err, "token is signed with unknown key: key02")
+}
+
+func TestJWTAuthenticator_Authorize(t *testing.T) {
+	a := &jwtAuthenticator{claim: defaultJWTClaim}
+
+	claims := map[string]interface{}{"baskets": []interface{}{"jwt01", "jwt02:read", 3}}
+	assert.True(t, a.Authorize(claims, "jwt01", ""), "full access is expected")
+	assert.True(t, a.Authorize(claims, "jwt01", ScopeDelete), "full access is expected")
+	assert.True(t, a.Authorize(claims, "jwt02", ScopeRead), "scoped access is expected")
+	assert.False(t, a.Authorize(claims, "jwt02", ScopeClear), "other scope is not expected")
+	assert.False(t, a.Authorize(claims, "jwt02", ""), "full access is not expected")
+	assert.False(t, a.Authorize(claims, "jwt03", ScopeRead), "other basket is not expected")
+
+	claims = map[string]interface{}{"baskets": "jwt01 jwt02:read"}
+	assert.True(t, a.Authorize(claims, "jwt01", ""), "full access is expected")
+	assert.True(t, a.Authorize(claims, "jwt02", ScopeRead), "scoped access is expected")
+	assert.False(t, a.Authorize(map[string]interface{}{}, "jwt01", ""), "access without claim is not expected")
+}
+
+func TestJWTBearerToken(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	jwt = testJWTAuthenticator(t, server)
+	defer func() { jwt = nil }()
+
+	for _, name := range []string{"jwt11", "jwt12"} {
+		if _, err := basketsDb.Create(name, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+			return
+		}
+	}
+	call := func(method string, path string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(""))
+		r.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	token := server.Sign("key01", testJWTClaims(server, "jwt11", "jwt12:read"))
+	assert.Equal(t, 200, call("GET", "/baskets/jwt11", token).Code, "full access is expected")
+	assert.Equal(t, 200, call("GET", "/baskets/jwt12/requests", token).Code, "read access is expected")
+	assert.Equal(t, 401, call("GET", "/baskets/jwt12", token).Code, "access to config is not expected")
+	assert.Equal(t, 401, call("DELETE", "/baskets/jwt12/requests", token).Code, "clear is not expected")
+	assert.Equal(t, 401, call("GET", "/stats", token).Code, "access to stats is not expected")
+
+	admin := testJWTClaims(server)
+	admin["scope"] = "admin"
+	assert.Equal(t, 200, call("GET", "/stats", server.Sign("key01", admin)).Code, "admin is expected to be authorized")
+
+	expired := testJWTClaims(server, "jwt11")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	w := call("GET", "/baskets/jwt11", server.Sign("key01", expired))
+	assert.Equal(t, 401, w.Code, "expired token is not expected")
+	assert.Contains(t, w.Body.String(), "Invalid identity token: token is expired", "wrong error")
+
+	assert.Equal(t, 204, call("DELETE", "/baskets/jwt11", token).Code, "full access is expected")
+}
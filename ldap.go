@@ -0,0 +1,704 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LDAP protocol operations, see RFC 4511
+const (
+	ldapBindRequest     = 0x60
+	ldapBindResponse    = 0x61
+	ldapUnbindRequest   = 0x42
+	ldapSearchRequest   = 0x63
+	ldapSearchEntry     = 0x64
+	ldapSearchDone      = 0x65
+	ldapSearchReference = 0x73
+)
+
+// BER universal types used by LDAP messages
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0A
+	berSequence    = 0x30
+	berSet         = 0x31
+)
+
+const (
+	ldapDialTimeout     = 10 * time.Second
+	ldapMaxMessageSize  = 1024 * 1024
+	ldapSessionTimeout  = 12 * time.Hour // validity of read-only sessions
+	ldapUserPlaceholder = "{user}"
+)
+
+// roles of directory users
+const (
+	RoleAdmin  = "admin"  // the master token is granted
+	RoleReader = "reader" // read-only access to all baskets
+	RoleUser   = "user"   // access to baskets owned by user account
+)
+
+var ldapResultCodes = map[int]string{
+	1:  "operations error",
+	4:  "size limit exceeded",
+	32: "no such object",
+	49: "invalid credentials",
+	50: "insufficient access rights",
+	51: "busy",
+	52: "unavailable"}
+
+var ldap *ldapAuthenticator
+
+// LDAPCredentials describes credentials of directory user who signs in
+type LDAPCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LDAPSession describes signed in directory user, the token is presented to the service API
+// the same way as the master token
+type LDAPSession struct {
+	Token string `json:"token"`
+	Role  string `json:"role"`           // admin, reader or user
+	User  string `json:"user,omitempty"` // user account of directory user with user role
+}
+
+// ldapEntry describes entry found in directory, names of attributes are in lower case
+type ldapEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// ldapAuthenticator signs in directory users, membership in directory groups maps users to roles:
+// admins are granted the master token, readers get read-only access to all baskets and other users
+// get user accounts that own baskets
+type ldapAuthenticator struct {
+	sync.Mutex
+	server       *url.URL
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	userFilter   string
+	adminGroups  []string             // normalized DNs
+	readerGroups []string             // normalized DNs
+	readers      map[string]time.Time // expiration of read-only sessions by token
+	tokens       map[string]string    // token of read-only session by DN of directory user
+}
+
+// ldapConn is connection to directory server
+type ldapConn struct {
+	net.Conn
+	reader    *bufio.Reader
+	messageID int
+}
+
+// newLDAPAuthenticator creates authenticator that signs in users of directory with URL like ldap://host:389,
+// ldaps:// scheme stands for connection over TLS
+func newLDAPAuthenticator(config *ServerConfig) (*ldapAuthenticator, error) {
+	u, err := url.Parse(config.LDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP server URL: %s", err)
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported scheme of LDAP server URL: %s", u.Scheme)
+	}
+	if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("LDAP server host is not defined: %s", config.LDAPURL)
+	}
+	if !strings.Contains(config.LDAPUserFilter, ldapUserPlaceholder) {
+		return nil, fmt.Errorf("LDAP user filter has no %s placeholder: %s", ldapUserPlaceholder, config.LDAPUserFilter)
+	}
+	if _, err = ldapFilter(strings.Replace(config.LDAPUserFilter, ldapUserPlaceholder, "test", -1)); err != nil {
+		return nil, fmt.Errorf("invalid LDAP user filter: %s", err)
+	}
+
+	a := &ldapAuthenticator{
+		server:       u,
+		bindDN:       config.LDAPBindDN,
+		bindPassword: config.LDAPBindPassword,
+		baseDN:       config.LDAPBaseDN,
+		userFilter:   config.LDAPUserFilter,
+		readers:      make(map[string]time.Time),
+		tokens:       make(map[string]string)}
+	for _, group := range config.LDAPAdminGroups {
+		a.adminGroups = append(a.adminGroups, normalizeDN(group))
+	}
+	for _, group := range config.LDAPReaderGroups {
+		a.readerGroups = append(a.readerGroups, normalizeDN(group))
+	}
+
+	return a, nil
+}
+
+// Login verifies credentials of directory user and returns session of the user according to the role,
+// HTTP status is returned for failed sign in
+func (a *ldapAuthenticator) Login(username string, password string) (*LDAPSession, int, error) {
+	// simple bind with empty password is unauthenticated bind that servers accept for any user
+	if len(username) == 0 || len(password) == 0 {
+		return nil, http.StatusUnauthorized, fmt.Errorf("username and password are required")
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("failed to connect to LDAP server: %s", err)
+	}
+	defer conn.Close()
+
+	if len(a.bindDN) > 0 {
+		if err = conn.Bind(a.bindDN, a.bindPassword); err != nil {
+			return nil, http.StatusBadGateway, fmt.Errorf("failed to bind to LDAP server: %s", err)
+		}
+	}
+	filter := strings.Replace(a.userFilter, ldapUserPlaceholder, escapeLDAPFilter(username), -1)
+	entries, err := conn.Search(a.baseDN, filter, []string{"memberOf"})
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("failed to search LDAP server: %s", err)
+	}
+	// do not reveal whether user exists
+	if len(entries) != 1 || conn.Bind(entries[0].dn, password) != nil {
+		return nil, http.StatusUnauthorized, fmt.Errorf("invalid username or password")
+	}
+
+	session := &LDAPSession{Role: a.role(entries[0].attributes["memberof"])}
+	switch session.Role {
+	case RoleAdmin:
+		session.Token = serverConfig.MasterToken
+	case RoleReader:
+		if session.Token, err = a.readerToken(entries[0].dn); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	default:
+		session.User = invalidIdentityName.ReplaceAllString(username, "_")
+		if len(session.User) > 100 {
+			session.User = session.User[:100]
+		}
+		auth, err := users.Provision(session.User, "ldap:"+normalizeDN(entries[0].dn),
+			defaultUserConfig())
+		if err != nil {
+			return nil, http.StatusConflict, err
+		}
+		session.Token = auth.Token
+	}
+	return session, http.StatusOK, nil
+}
+
+// IsReader checks if token belongs to read-only session
+func (a *ldapAuthenticator) IsReader(token string) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	expires, found := a.readers[token]
+	return found && time.Now().Before(expires)
+}
+
+// role maps groups of directory user to the role, admin role wins over reader role
+func (a *ldapAuthenticator) role(groups []string) string {
+	role := RoleUser
+	for _, group := range groups {
+		group = normalizeDN(group)
+		for _, admin := range a.adminGroups {
+			if group == admin {
+				return RoleAdmin
+			}
+		}
+		for _, reader := range a.readerGroups {
+			if group == reader {
+				role = RoleReader
+			}
+		}
+	}
+	return role
+}
+
+// readerToken returns token of read-only session of directory user, the same session is prolonged if it
+// has not expired yet
+func (a *ldapAuthenticator) readerToken(dn string) (string, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	now := time.Now()
+	for token, expires := range a.readers {
+		if now.After(expires) {
+			delete(a.readers, token)
+		}
+	}
+
+	dn = normalizeDN(dn)
+	token, found := a.tokens[dn]
+	if _, active := a.readers[token]; !found || !active {
+		var err error
+		if token, err = GenerateToken(); err != nil {
+			return "", fmt.Errorf("failed to generate token: %s", err)
+		}
+		a.tokens[dn] = token
+	}
+	a.readers[token] = now.Add(ldapSessionTimeout)
+	return token, nil
+}
+
+// connect opens connection to directory server
+func (a *ldapAuthenticator) connect() (*ldapConn, error) {
+	dialer := &net.Dialer{Timeout: ldapDialTimeout}
+	var conn net.Conn
+	var err error
+	if a.server.Scheme == "ldaps" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", mqttAddress(a.server, "636"), &tls.Config{ServerName: a.server.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", mqttAddress(a.server, "389"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(ldapDialTimeout))
+	return &ldapConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Close unbinds and closes connection
+func (c *ldapConn) Close() error {
+	c.send(berEncode(ldapUnbindRequest, nil))
+	return c.Conn.Close()
+}
+
+// Bind authenticates connection with simple bind
+func (c *ldapConn) Bind(dn string, password string) error {
+	id, err := c.send(berEncode(ldapBindRequest, berConcat(
+		berInt(berInteger, 3), berEncode(berOctetString, []byte(dn)), berEncode(0x80, []byte(password)))))
+	if err != nil {
+		return err
+	}
+
+	op, body, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op != ldapBindResponse {
+		return fmt.Errorf("unexpected response: %#x", op)
+	}
+	return ldapResult(body)
+}
+
+// Search finds entries within subtree of base DN that match filter
+func (c *ldapConn) Search(base string, filter string, attributes []string) ([]*ldapEntry, error) {
+	encodedFilter, err := ldapFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var names []byte
+	for _, name := range attributes {
+		names = append(names, berEncode(berOctetString, []byte(name))...)
+	}
+
+	// whole subtree, never dereference aliases, at most 2 entries (only a single entry is expected), 10 seconds
+	id, err := c.send(berEncode(ldapSearchRequest, berConcat(
+		berEncode(berOctetString, []byte(base)), berInt(berEnumerated, 2), berInt(berEnumerated, 0),
+		berInt(berInteger, 2), berInt(berInteger, int(ldapDialTimeout/time.Second)), berEncode(berBoolean, []byte{0}),
+		encodedFilter, berEncode(berSequence, names))))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*ldapEntry{}
+	for {
+		op, body, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case ldapSearchEntry:
+			entry, err := parseLDAPEntry(body)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case ldapSearchReference:
+			// referrals to other servers are not followed
+		case ldapSearchDone:
+			if err = ldapResult(body); err != nil && !strings.Contains(err.Error(), ldapResultCodes[4]) {
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("unexpected response: %#x", op)
+		}
+	}
+}
+
+// send writes request with the next message ID and returns the ID
+func (c *ldapConn) send(op []byte) (int, error) {
+	c.messageID++
+	_, err := c.Write(berEncode(berSequence, berConcat(berInt(berInteger, c.messageID), op)))
+	return c.messageID, err
+}
+
+// receive reads response to request with message ID and returns its protocol operation and body
+func (c *ldapConn) receive(id int) (byte, []byte, error) {
+	message, err := readLDAPMessage(c.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tag, value, rest, err := berRead(message)
+	if err != nil || tag != berInteger {
+		return 0, nil, fmt.Errorf("malformed message")
+	}
+	if berIntValue(value) != id {
+		return 0, nil, fmt.Errorf("unexpected message ID: %d", berIntValue(value))
+	}
+	op, body, _, err := berRead(rest)
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed message")
+	}
+	return op, body, nil
+}
+
+// readLDAPMessage reads LDAP message and returns content of its sequence
+func readLDAPMessage(reader io.ByteReader) ([]byte, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag != berSequence {
+		return nil, fmt.Errorf("unexpected message: %#x", tag)
+	}
+
+	length, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	size := int(length)
+	if length&0x80 != 0 {
+		if length&0x7F == 0 || length&0x7F > 4 {
+			return nil, fmt.Errorf("malformed message length")
+		}
+		size = 0
+		for i := 0; i < int(length&0x7F); i++ {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			size = size<<8 | int(b)
+		}
+	}
+	if size > ldapMaxMessageSize {
+		return nil, fmt.Errorf("message is too large: %d bytes", size)
+	}
+
+	message := make([]byte, size)
+	for i := range message {
+		if message[i], err = reader.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+// ldapResult converts result of operation into error unless the operation succeeded
+func ldapResult(body []byte) error {
+	tag, code, rest, err := berRead(body)
+	if err != nil || tag != berEnumerated {
+		return fmt.Errorf("malformed result")
+	}
+	if berIntValue(code) == 0 {
+		return nil
+	}
+
+	message := ""
+	if _, _, rest, err = berRead(rest); err == nil { // matched DN
+		if _, diagnostic, _, err := berRead(rest); err == nil {
+			message = string(diagnostic)
+		}
+	}
+	description := ldapResultCodes[berIntValue(code)]
+	if len(description) == 0 {
+		description = fmt.Sprintf("result code %d", berIntValue(code))
+	}
+	if len(message) > 0 {
+		description += " - " + message
+	}
+	return fmt.Errorf("%s", description)
+}
+
+// parseLDAPEntry parses search result entry
+func parseLDAPEntry(body []byte) (*ldapEntry, error) {
+	_, dn, rest, err := berRead(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed entry")
+	}
+	_, attributes, _, err := berRead(rest)
+	if err != nil {
+		return nil, fmt.Errorf("malformed entry")
+	}
+
+	entry := &ldapEntry{dn: string(dn), attributes: make(map[string][]string)}
+	for len(attributes) > 0 {
+		var attribute, name, values []byte
+		if _, attribute, attributes, err = berRead(attributes); err != nil {
+			return nil, fmt.Errorf("malformed entry attribute")
+		}
+		if _, name, values, err = berRead(attribute); err != nil {
+			return nil, fmt.Errorf("malformed entry attribute")
+		}
+		if _, values, _, err = berRead(values); err != nil {
+			return nil, fmt.Errorf("malformed entry attribute")
+		}
+		key := strings.ToLower(string(name))
+		for len(values) > 0 {
+			var value []byte
+			if _, value, values, err = berRead(values); err != nil {
+				return nil, fmt.Errorf("malformed entry attribute")
+			}
+			entry.attributes[key] = append(entry.attributes[key], string(value))
+		}
+	}
+	return entry, nil
+}
+
+// ldapFilter encodes search filter from its string representation, e.g. (&(objectClass=person)(uid=alice)),
+// see RFC 4515; extensible match is not supported
+func ldapFilter(filter string) ([]byte, error) {
+	encoded, rest, err := parseLDAPFilter(filter)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected text after filter: %s", rest)
+	}
+	return encoded, err
+}
+
+// parseLDAPFilter encodes filter at the beginning of text and returns the rest of text
+func parseLDAPFilter(filter string) ([]byte, string, error) {
+	if !strings.HasPrefix(filter, "(") || len(filter) < 2 {
+		return nil, "", fmt.Errorf("filter is expected to start with '(': %s", filter)
+	}
+	filter = filter[1:]
+
+	var encoded []byte
+	switch filter[0] {
+	case '&', '|':
+		tag := byte(0xA0)
+		if filter[0] == '|' {
+			tag = 0xA1
+		}
+		filter = filter[1:]
+		var items []byte
+		for strings.HasPrefix(filter, "(") {
+			item, rest, err := parseLDAPFilter(filter)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item...)
+			filter = rest
+		}
+		if len(items) == 0 {
+			return nil, "", fmt.Errorf("empty filter set")
+		}
+		encoded = berEncode(tag, items)
+	case '!':
+		item, rest, err := parseLDAPFilter(filter[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		encoded = berEncode(0xA2, item)
+		filter = rest
+	default:
+		end := strings.Index(filter, ")")
+		if end < 0 {
+			return nil, "", fmt.Errorf("filter is expected to end with ')'")
+		}
+		item, err := ldapItemFilter(filter[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		encoded = item
+		filter = filter[end:]
+	}
+
+	if !strings.HasPrefix(filter, ")") {
+		return nil, "", fmt.Errorf("filter is expected to end with ')'")
+	}
+	return encoded, filter[1:], nil
+}
+
+// ldapItemFilter encodes simple filter, e.g. uid=alice, cn=a*, mail=*
+func ldapItemFilter(item string) ([]byte, error) {
+	i := strings.Index(item, "=")
+	if i < 1 {
+		return nil, fmt.Errorf("invalid filter: %s", item)
+	}
+	attribute, value := item[:i], item[i+1:]
+
+	tag := byte(0xA3) // equality match
+	switch attribute[len(attribute)-1] {
+	case '>':
+		tag = 0xA5
+	case '<':
+		tag = 0xA6
+	case '~':
+		tag = 0xA8
+	}
+	if tag != 0xA3 {
+		attribute = attribute[:len(attribute)-1]
+	}
+	if len(attribute) == 0 || strings.ContainsAny(attribute, "()*\\ ") {
+		return nil, fmt.Errorf("invalid filter attribute: %s", item)
+	}
+
+	if tag == 0xA3 && value == "*" {
+		return berEncode(0x87, []byte(attribute)), nil // present
+	}
+	if tag == 0xA3 && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		var substrings []byte
+		for i, part := range parts {
+			if len(part) == 0 {
+				continue
+			}
+			unescaped, err := unescapeLDAPValue(part)
+			if err != nil {
+				return nil, err
+			}
+			position := byte(0x81) // any
+			if i == 0 {
+				position = 0x80 // initial
+			} else if i == len(parts)-1 {
+				position = 0x82 // final
+			}
+			substrings = append(substrings, berEncode(position, unescaped)...)
+		}
+		return berEncode(0xA4, berConcat(berEncode(berOctetString, []byte(attribute)), berEncode(berSequence, substrings))), nil
+	}
+
+	unescaped, err := unescapeLDAPValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return berEncode(tag, berConcat(berEncode(berOctetString, []byte(attribute)), berEncode(berOctetString, unescaped))), nil
+}
+
+// escapeLDAPFilter escapes value inserted into search filter
+func escapeLDAPFilter(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			escaped.WriteString("\\" + hex.EncodeToString([]byte{c}))
+		default:
+			escaped.WriteByte(c)
+		}
+	}
+	return escaped.String()
+}
+
+// unescapeLDAPValue decodes value of search filter with characters escaped as \XX
+func unescapeLDAPValue(value string) ([]byte, error) {
+	unescaped := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			unescaped = append(unescaped, value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return nil, fmt.Errorf("invalid escape sequence in filter value: %s", value)
+		}
+		b, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape sequence in filter value: %s", value)
+		}
+		unescaped = append(unescaped, b[0])
+		i += 2
+	}
+	return unescaped, nil
+}
+
+// normalizeDN converts distinguished name into canonical form to compare names
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
+
+// berEncode encodes BER element with definite length
+func berEncode(tag byte, content []byte) []byte {
+	length := len(content)
+	if length < 0x80 {
+		return append([]byte{tag, byte(length)}, content...)
+	}
+
+	var size []byte
+	for ; length > 0; length >>= 8 {
+		size = append([]byte{byte(length)}, size...)
+	}
+	return append(append([]byte{tag, 0x80 | byte(len(size))}, size...), content...)
+}
+
+// berInt encodes non-negative integer
+func berInt(tag byte, value int) []byte {
+	content := []byte{byte(value)}
+	for value >>= 8; value > 0; value >>= 8 {
+		content = append([]byte{byte(value)}, content...)
+	}
+	if content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return berEncode(tag, content)
+}
+
+// berIntValue decodes content of integer element
+func berIntValue(content []byte) int {
+	value := 0
+	for _, b := range content {
+		value = value<<8 | int(b)
+	}
+	return value
+}
+
+// berConcat concatenates encoded elements
+func berConcat(elements ...[]byte) []byte {
+	var result []byte
+	for _, element := range elements {
+		result = append(result, element...)
+	}
+	return result
+}
+
+// berRead reads BER element and returns its tag, content and data that follows the element
+func berRead(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("truncated element")
+	}
+
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7F
+		if n == 0 || n > 4 || len(data) < 2+n {
+			return 0, nil, nil, fmt.Errorf("malformed element length")
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if length > len(data)-offset {
+		return 0, nil, nil, fmt.Errorf("truncated element")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// isReaderRequest checks if HTTP request only reads data and presents token of read-only session
+func isReaderRequest(r *http.Request) bool {
+	return ldap != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		ldap.IsReader(r.Header.Get("Authorization"))
+}
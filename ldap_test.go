@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testLDAPUser describes user of fake directory
+type testLDAPUser struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+// testLDAPServer is fake directory server that supports simple bind and search of users by uid
+type testLDAPServer struct {
+	listener net.Listener
+	users    map[string]*testLDAPUser // by uid
+	filters  chan []byte              // encoded filters of searches
+}
+
+func newTestLDAPServer(t *testing.T) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	s := &testLDAPServer{listener: listener, filters: make(chan []byte, 10), users: map[string]*testLDAPUser{
+		"service": {"cn=service,dc=example,dc=com", "secret", nil},
+		"admin01": {"uid=admin01,ou=people,dc=example,dc=com", "pass01", []string{"CN=Admins, OU=Groups,DC=example,DC=com"}},
+		"reader01": {"uid=reader01,ou=people,dc=example,dc=com", "pass02",
+			[]string{"cn=staff,ou=groups,dc=example,dc=com", "cn=auditors,ou=groups,dc=example,dc=com"}},
+		"ldapuser01": {"uid=ldapuser01,ou=people,dc=example,dc=com", "pass03", []string{"cn=staff,ou=groups,dc=example,dc=com"}}}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// URL returns URL of the server
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *testLDAPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		message, err := readLDAPMessage(reader)
+		if err != nil {
+			return
+		}
+		_, id, rest, _ := berRead(message)
+		op, body, _, _ := berRead(rest)
+
+		var response []byte
+		switch op {
+		case ldapBindRequest:
+			_, _, rest, _ := berRead(body) // version
+			_, dn, rest, _ := berRead(rest)
+			_, password, _, _ := berRead(rest)
+			code := 49
+			for _, user := range s.users {
+				if user.dn == string(dn) && user.password == string(password) {
+					code = 0
+				}
+			}
+			response = testLDAPResult(ldapBindResponse, code)
+		case ldapSearchRequest:
+			// base, scope, aliases, size limit, time limit, types only, filter
+			for i := 0; i < 6; i++ {
+				_, _, body, _ = berRead(body)
+			}
+			tag, filter, _, _ := berRead(body)
+			s.filters <- berEncode(tag, filter)
+			if tag == 0xA3 {
+				_, _, value, _ := berRead(filter)
+				_, uid, _, _ := berRead(value)
+				if user, found := s.users[string(uid)]; found {
+					var groups []byte
+					for _, group := range user.groups {
+						groups = append(groups, berEncode(berOctetString, []byte(group))...)
+					}
+					entry := berEncode(ldapSearchEntry, berConcat(berEncode(berOctetString, []byte(user.dn)),
+						berEncode(berSequence, berEncode(berSequence, berConcat(
+							berEncode(berOctetString, []byte("memberOf")), berEncode(berSet, groups))))))
+					conn.Write(berEncode(berSequence, berConcat(berEncode(berInteger, id), entry)))
+				}
+			}
+			response = testLDAPResult(ldapSearchDone, 0)
+		default:
+			return
+		}
+		conn.Write(berEncode(berSequence, berConcat(berEncode(berInteger, id), response)))
+	}
+}
+
+func testLDAPResult(op byte, code int) []byte {
+	return berEncode(op, berConcat(berInt(berEnumerated, code), berEncode(berOctetString, nil),
+		berEncode(berOctetString, []byte("test"))))
+}
+
+func testLDAPConfig(server *testLDAPServer) *ServerConfig {
+	return &ServerConfig{LDAPURL: server.URL(), LDAPBindDN: "cn=service,dc=example,dc=com", LDAPBindPassword: "secret",
+		LDAPBaseDN: "ou=people,dc=example,dc=com", LDAPUserFilter: defaultLDAPFilter,
+		LDAPAdminGroups:  []string{"cn=admins,ou=groups,dc=example,dc=com"},
+		LDAPReaderGroups: []string{"cn=auditors,ou=groups,dc=example,dc=com"}}
+}
+
+func TestLDAPFilter(t *testing.T) {
+	octet := func(value string) []byte { return berEncode(berOctetString, []byte(value)) }
+	equal := func(attribute string, value string) []byte {
+		return berEncode(0xA3, berConcat(octet(attribute), octet(value)))
+	}
+
+	valid := map[string][]byte{
+		"(uid=alice)":        equal("uid", "alice"),
+		"(cn=\\2a\\28x\\29)": equal("cn", "*(x)"),
+		"(mail=*)":           berEncode(0x87, []byte("mail")),
+		"(cn=a*b*c)": berEncode(0xA4, berConcat(octet("cn"), berEncode(berSequence, berConcat(
+			berEncode(0x80, []byte("a")), berEncode(0x81, []byte("b")), berEncode(0x82, []byte("c")))))),
+		"(cn=*b*)": berEncode(0xA4, berConcat(octet("cn"), berEncode(berSequence, berEncode(0x81, []byte("b"))))),
+		"(&(objectClass=person)(|(age>=18)(!(age<=3))(cn~=bob)))": berEncode(0xA0, berConcat(equal("objectClass", "person"),
+			berEncode(0xA1, berConcat(berEncode(0xA5, berConcat(octet("age"), octet("18"))),
+				berEncode(0xA2, berEncode(0xA6, berConcat(octet("age"), octet("3")))),
+				berEncode(0xA8, berConcat(octet("cn"), octet("bob")))))))}
+	for filter, expected := range valid {
+		encoded, err := ldapFilter(filter)
+		if assert.NoError(t, err, "valid filter is expected: %s", filter) {
+			assert.Equal(t, expected, encoded, "wrong encoding of filter: %s", filter)
+		}
+	}
+
+	for _, filter := range []string{"uid=alice", "(uid=alice", "(=alice)", "(&)", "(uid=a\\zz)", "(uid=a\\2)",
+		"(uid=a)(cn=b)", "(u id=a)", "(!uid=a)"} {
+		_, err := ldapFilter(filter)
+		assert.Error(t, err, "invalid filter is not expected: %s", filter)
+	}
+
+	assert.Equal(t, "a\\2a\\28b\\29\\5c\\00", escapeLDAPFilter("a*(b)\\\x00"), "wrong escaping")
+}
+
+func TestBerEncode(t *testing.T) {
+	long := make([]byte, 300)
+	encoded := berEncode(berOctetString, long)
+	assert.Equal(t, []byte{berOctetString, 0x82, 0x01, 0x2C}, encoded[:4], "long form of length is expected")
+	tag, content, rest, err := berRead(append(encoded, 0x01))
+	if assert.NoError(t, err) {
+		assert.Equal(t, byte(berOctetString), tag, "wrong tag")
+		assert.Len(t, content, 300, "wrong content")
+		assert.Equal(t, []byte{0x01}, rest, "wrong rest")
+	}
+	_, _, _, err = berRead(encoded[:100])
+	assert.Error(t, err, "truncated element is not expected")
+
+	assert.Equal(t, []byte{berInteger, 1, 0x7F}, berInt(berInteger, 127), "wrong encoding of integer")
+	assert.Equal(t, []byte{berInteger, 2, 0x00, 0x80}, berInt(berInteger, 128), "positive integer is expected")
+	assert.Equal(t, 128, berIntValue([]byte{0x00, 0x80}), "wrong decoding of integer")
+}
+
+func TestNewLDAPAuthenticator(t *testing.T) {
+	valid := ServerConfig{LDAPURL: "ldaps://ldap.example.com", LDAPUserFilter: defaultLDAPFilter}
+	_, err := newLDAPAuthenticator(&valid)
+	assert.NoError(t, err)
+
+	invalid := []ServerConfig{valid, valid, valid, valid}
+	invalid[0].LDAPURL = "http://ldap.example.com"
+	invalid[1].LDAPURL = "ldap://"
+	invalid[2].LDAPUserFilter = "(uid=alice)"
+	invalid[3].LDAPUserFilter = "(uid={user}"
+	for _, config := range invalid {
+		_, err = newLDAPAuthenticator(&config)
+		assert.Error(t, err, "invalid configuration is not expected: %v", config)
+	}
+}
+
+func TestLDAPAuthenticator_Login(t *testing.T) {
+	server := newTestLDAPServer(t)
+	defer server.Close()
+	a, err := newLDAPAuthenticator(testLDAPConfig(server))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// roles are mapped from groups
+	session, _, err := a.Login("admin01", "pass01")
+	if assert.NoError(t, err) {
+		assert.Equal(t, RoleAdmin, session.Role, "wrong role")
+		assert.Equal(t, serverConfig.MasterToken, session.Token, "master token is expected")
+	}
+	assert.Equal(t, equalTestFilter("admin01"), <-server.filters, "wrong search filter")
+
+	session, _, err = a.Login("reader01", "pass02")
+	if assert.NoError(t, err) {
+		assert.Equal(t, RoleReader, session.Role, "wrong role")
+		assert.True(t, a.IsReader(session.Token), "read-only session is expected")
+		again, _, _ := a.Login("reader01", "pass02")
+		assert.Equal(t, session.Token, again.Token, "the same session is expected")
+	}
+
+	session, _, err = a.Login("ldapuser01", "pass03")
+	if assert.NoError(t, err) {
+		assert.Equal(t, RoleUser, session.Role, "wrong role")
+		assert.Equal(t, "ldapuser01", session.User, "wrong user")
+		if user := users.Authenticate(session.Token); assert.NotNil(t, user, "user account is expected") {
+			assert.Equal(t, "ldapuser01", user.Name, "wrong user account")
+		}
+		assert.False(t, a.IsReader(session.Token), "user is not expected to be reader")
+	}
+
+	// failed sign in
+	for _, credentials := range [][]string{{"ldapuser01", "wrong"}, {"unknown", "pass03"}, {"ldapuser01", ""}, {"*", "pass03"}} {
+		_, status, err := a.Login(credentials[0], credentials[1])
+		assert.Error(t, err, "sign in is not expected: %v", credentials)
+		assert.Equal(t, http.StatusUnauthorized, status, "wrong status")
+	}
+	for len(server.filters) > 0 {
+		if filter := <-server.filters; len(server.filters) == 0 {
+			assert.Equal(t, equalTestFilter("*"), filter, "username is expected to be escaped")
+		}
+	}
+
+	// directory is not available
+	config := testLDAPConfig(server)
+	config.LDAPBindPassword = "wrong"
+	a, _ = newLDAPAuthenticator(config)
+	_, status, err := a.Login("ldapuser01", "pass03")
+	assert.Contains(t, err.Error(), "failed to bind to LDAP server: invalid credentials - test", "wrong error")
+	assert.Equal(t, http.StatusBadGateway, status, "wrong status")
+	config.LDAPURL = "ldap://127.0.0.1:1"
+	a, _ = newLDAPAuthenticator(config)
+	_, status, _ = a.Login("ldapuser01", "pass03")
+	assert.Equal(t, http.StatusBadGateway, status, "wrong status")
+}
+
+func equalTestFilter(uid string) []byte {
+	return berEncode(0xA3, berConcat(berEncode(berOctetString, []byte("uid")), berEncode(berOctetString, []byte(uid))))
+}
+
+func TestLDAPLogin(t *testing.T) {
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, strings.NewReader(body))
+		if len(token) > 0 {
+			r.Header.Add("Authorization", token)
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+	login := `{"username": "reader01", "password": "pass02"}`
+	assert.Equal(t, 404, call("POST", "/ldap/login", "", login).Code, "sign in is not expected without LDAP")
+
+	server := newTestLDAPServer(t)
+	defer server.Close()
+	ldap, _ = newLDAPAuthenticator(testLDAPConfig(server))
+	defer func() { ldap = nil }()
+	assert.Equal(t, 201, call("POST", "/baskets/ldap01", serverConfig.MasterToken, "").Code, "wrong HTTP result code")
+
+	// readers have read-only access to all baskets
+	w := call("POST", "/ldap/login", "", login)
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	session := new(LDAPSession)
+	json.Unmarshal(w.Body.Bytes(), session)
+	assert.Equal(t, RoleReader, session.Role, "wrong role")
+	assert.Equal(t, 200, call("GET", "/baskets/ldap01", session.Token, "").Code, "reader is expected to read basket")
+	assert.Equal(t, 200, call("GET", "/baskets", session.Token, "").Code, "reader is expected to list baskets")
+	assert.Equal(t, 200, call("GET", "/stats", session.Token, "").Code, "reader is expected to read stats")
+	assert.Equal(t, 401, call("DELETE", "/baskets/ldap01", session.Token, "").Code, "reader is not expected to delete basket")
+	assert.Equal(t, 401, call("PUT", "/baskets/ldap01", session.Token, `{"capacity": 10}`).Code,
+		"reader is not expected to update basket")
+
+	// API accepts credentials with basic authentication
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin01:pass01"))
+	assert.Equal(t, 204, call("DELETE", "/baskets/ldap01", basic, "").Code, "admin is expected to delete basket")
+	basic = "Basic " + base64.StdEncoding.EncodeToString([]byte("admin01:wrong"))
+	assert.Equal(t, 401, call("GET", "/stats", basic, "").Code, "invalid credentials are not expected")
+
+	assert.Equal(t, 401, call("POST", "/ldap/login", "", `{"username": "reader01", "password": "wrong"}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", "/ldap/login", "", `{"username"`).Code, "wrong HTTP result code")
+}
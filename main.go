@@ -9,7 +9,18 @@ func main() {
 	serverConfig = CreateConfig()
 	// create & start server
 	if server := CreateServer(serverConfig); server != nil {
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if acmeManager != nil {
+			if len(serverConfig.ACMEHTTPAddr) > 0 {
+				go serveACMEChallenges(acmeManager, serverConfig.ACMEHTTPAddr)
+			}
+			err = server.ListenAndServeTLS("", "")
+		} else if len(serverConfig.TLSCert) > 0 {
+			err = server.ListenAndServeTLS(serverConfig.TLSCert, serverConfig.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.Fatal(err)
 		}
 	}
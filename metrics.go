@@ -0,0 +1,186 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptLatencySamples defines how many recent executions are used to calculate latency percentiles
+const scriptLatencySamples = 128
+
+var scriptMetrics = newScriptMetricsRegistry()
+
+// ScriptStats describes execution statistics of a basket script
+type ScriptStats struct {
+	Basket          string  `json:"basket"`
+	Script          string  `json:"script"`
+	ExecutionsCount int     `json:"executions_count"`
+	ErrorsCount     int     `json:"errors_count"`
+	LastError       string  `json:"last_error,omitempty"`
+	LastRunDate     int64   `json:"last_run_date"`
+	LatencyP50      float64 `json:"latency_p50_ms"`
+	LatencyP90      float64 `json:"latency_p90_ms"`
+	LatencyP99      float64 `json:"latency_p99_ms"`
+}
+
+type scriptMetricsEntry struct {
+	executions int
+	errors     int
+	lastError  string
+	lastRun    int64
+	samples    []time.Duration
+	next       int
+}
+
+// scriptMetricsRegistry collects in-memory execution statistics of basket scripts,
+// the statistics are not persisted and are reset on service restart
+type scriptMetricsRegistry struct {
+	sync.RWMutex
+	entries map[string]map[string]*scriptMetricsEntry
+}
+
+func newScriptMetricsRegistry() *scriptMetricsRegistry {
+	return &scriptMetricsRegistry{entries: make(map[string]map[string]*scriptMetricsEntry)}
+}
+
+// Record registers single script execution of a basket
+func (m *scriptMetricsRegistry) Record(basket string, script string, latency time.Duration, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	scripts, exists := m.entries[basket]
+	if !exists {
+		scripts = make(map[string]*scriptMetricsEntry)
+		m.entries[basket] = scripts
+	}
+
+	entry, exists := scripts[script]
+	if !exists {
+		entry = &scriptMetricsEntry{samples: make([]time.Duration, 0, scriptLatencySamples)}
+		scripts[script] = entry
+	}
+
+	entry.executions++
+	entry.lastRun = time.Now().UnixNano() / toMs
+	if err != nil {
+		entry.errors++
+		entry.lastError = err.Error()
+	}
+
+	if len(entry.samples) < scriptLatencySamples {
+		entry.samples = append(entry.samples, latency)
+	} else {
+		entry.samples[entry.next] = latency
+		entry.next = (entry.next + 1) % scriptLatencySamples
+	}
+}
+
+// Remove drops collected statistics of a basket
+func (m *scriptMetricsRegistry) Remove(basket string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.entries, basket)
+}
+
+// Rename moves collected statistics of a basket under the new basket name
+func (m *scriptMetricsRegistry) Rename(basket string, newName string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if scripts, exists := m.entries[basket]; exists {
+		m.entries[newName] = scripts
+		delete(m.entries, basket)
+	}
+}
+
+// Get returns statistics of all scripts of a basket ordered by script name
+func (m *scriptMetricsRegistry) Get(basket string) []*ScriptStats {
+	m.RLock()
+	defer m.RUnlock()
+
+	stats := make([]*ScriptStats, 0, len(m.entries[basket]))
+	for script, entry := range m.entries[basket] {
+		stats = append(stats, entry.toStats(basket, script))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Script < stats[j].Script })
+
+	return stats
+}
+
+// Collect returns statistics of all known scripts
+func (m *scriptMetricsRegistry) Collect() []*ScriptStats {
+	m.RLock()
+	defer m.RUnlock()
+
+	stats := make([]*ScriptStats, 0, len(m.entries))
+	for basket, scripts := range m.entries {
+		for script, entry := range scripts {
+			stats = append(stats, entry.toStats(basket, script))
+		}
+	}
+
+	return stats
+}
+
+// CollectTo updates database statistics with top slow and failing scripts
+func (m *scriptMetricsRegistry) CollectTo(stats *DatabaseStats, max int) {
+	scripts := m.Collect()
+
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].LatencyP99 > scripts[j].LatencyP99 })
+	stats.TopScriptsByLatency = topScripts(scripts, max, func(s *ScriptStats) bool { return true })
+
+	sort.SliceStable(scripts, func(i, j int) bool { return scripts[i].ErrorsCount > scripts[j].ErrorsCount })
+	stats.TopScriptsByErrors = topScripts(scripts, max, func(s *ScriptStats) bool { return s.ErrorsCount > 0 })
+}
+
+func topScripts(scripts []*ScriptStats, max int, accept func(s *ScriptStats) bool) []*ScriptStats {
+	top := make([]*ScriptStats, 0, max)
+	for _, s := range scripts {
+		if len(top) >= max {
+			break
+		}
+		if accept(s) {
+			top = append(top, s)
+		}
+	}
+	return top
+}
+
+func (e *scriptMetricsEntry) toStats(basket string, script string) *ScriptStats {
+	samples := make([]time.Duration, len(e.samples))
+	copy(samples, e.samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	return &ScriptStats{
+		Basket:          basket,
+		Script:          script,
+		ExecutionsCount: e.executions,
+		ErrorsCount:     e.errors,
+		LastError:       e.lastError,
+		LastRunDate:     e.lastRun,
+		LatencyP50:      percentile(samples, 50),
+		LatencyP90:      percentile(samples, 90),
+		LatencyP99:      percentile(samples, 99)}
+}
+
+// percentile calculates nearest-rank percentile of sorted samples in milliseconds
+func percentile(sorted []time.Duration, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return float64(sorted[rank-1].Microseconds()) / 1000
+}
+
+// scriptName derives script name from its file name, e.g. "trigger.star" -> "trigger"
+func scriptName(filename string) string {
+	return strings.TrimSuffix(filename, ".star")
+}
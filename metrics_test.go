@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptMetricsRegistry_Record(t *testing.T) {
+	m := newScriptMetricsRegistry()
+	for i := 1; i <= 100; i++ {
+		m.Record("metrics01", "trigger", time.Duration(i)*time.Millisecond, nil)
+	}
+	m.Record("metrics01", "response", time.Millisecond, errors.New("broken"))
+
+	stats := m.Get("metrics01")
+	if assert.Len(t, stats, 2, "wrong number of scripts") {
+		assert.Equal(t, "response", stats[0].Script, "wrong script name")
+		assert.Equal(t, 1, stats[0].ExecutionsCount, "wrong executions count")
+		assert.Equal(t, 1, stats[0].ErrorsCount, "wrong errors count")
+		assert.Equal(t, "broken", stats[0].LastError, "wrong last error")
+
+		assert.Equal(t, "trigger", stats[1].Script, "wrong script name")
+		assert.Equal(t, 100, stats[1].ExecutionsCount, "wrong executions count")
+		assert.Equal(t, 0, stats[1].ErrorsCount, "wrong errors count")
+		assert.Equal(t, 50.0, stats[1].LatencyP50, "wrong p50 latency")
+		assert.Equal(t, 90.0, stats[1].LatencyP90, "wrong p90 latency")
+		assert.Equal(t, 99.0, stats[1].LatencyP99, "wrong p99 latency")
+	}
+
+	m.Remove("metrics01")
+	assert.Empty(t, m.Get("metrics01"), "statistics are not expected after removal")
+}
+
+func TestScriptMetricsRegistry_Samples(t *testing.T) {
+	m := newScriptMetricsRegistry()
+	for i := 0; i < scriptLatencySamples; i++ {
+		m.Record("metrics02", "trigger", time.Second, nil)
+	}
+	// only recent executions are used for percentiles
+	for i := 0; i < scriptLatencySamples; i++ {
+		m.Record("metrics02", "trigger", time.Millisecond, nil)
+	}
+
+	stats := m.Get("metrics02")
+	if assert.Len(t, stats, 1, "wrong number of scripts") {
+		assert.Equal(t, 2*scriptLatencySamples, stats[0].ExecutionsCount, "wrong executions count")
+		assert.Equal(t, 1.0, stats[0].LatencyP99, "wrong p99 latency")
+	}
+}
+
+func TestScriptMetricsRegistry_Rename(t *testing.T) {
+	m := newScriptMetricsRegistry()
+	m.Record("metrics03", "trigger", time.Millisecond, nil)
+
+	m.Rename("metrics03", "metrics03new")
+	assert.Empty(t, m.Get("metrics03"), "statistics are not expected under old name")
+	stats := m.Get("metrics03new")
+	if assert.Len(t, stats, 1, "wrong number of scripts") {
+		assert.Equal(t, "metrics03new", stats[0].Basket, "wrong basket name")
+		assert.Equal(t, 1, stats[0].ExecutionsCount, "wrong executions count")
+	}
+}
+
+func TestScriptMetricsRegistry_CollectTo(t *testing.T) {
+	m := newScriptMetricsRegistry()
+	m.Record("metrics03", "trigger", 10*time.Millisecond, nil)
+	m.Record("metrics04", "trigger", 30*time.Millisecond, errors.New("failed"))
+	m.Record("metrics05", "response", 20*time.Millisecond, nil)
+
+	stats := new(DatabaseStats)
+	m.CollectTo(stats, 2)
+
+	if assert.Len(t, stats.TopScriptsByLatency, 2, "wrong number of top scripts by latency") {
+		assert.Equal(t, "metrics04", stats.TopScriptsByLatency[0].Basket, "wrong slowest script")
+		assert.Equal(t, "metrics05", stats.TopScriptsByLatency[1].Basket, "wrong second slowest script")
+	}
+	if assert.Len(t, stats.TopScriptsByErrors, 1, "wrong number of top failing scripts") {
+		assert.Equal(t, "metrics04", stats.TopScriptsByErrors[0].Basket, "wrong failing script")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// namePolicy enforces policy on names of new baskets, the names of existing baskets are never checked
+var namePolicy = &basketNamePolicy{}
+
+// basketNamePolicy restricts names of baskets that users may create on a shared service: too short names,
+// names reserved by operator and names that contain offensive words or impersonate known brands
+type basketNamePolicy struct {
+	minLength int
+	reserved  []*regexp.Regexp
+	blocked   []string // normalized words, see normalizeBasketName
+}
+
+// leetReplacer undoes common character substitutions used to bypass blocklists, e.g. "p4yp4l"
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b")
+
+// newBasketNamePolicy creates policy of basket names; reserved patterns are regular expressions matched against
+// entire name ignoring case, blocklist is a text file with a word per line, empty lines and lines starting with
+// '#' are skipped
+func newBasketNamePolicy(minLength int, reserved []string, blocklist string) (*basketNamePolicy, error) {
+	policy := &basketNamePolicy{minLength: minLength}
+	for _, pattern := range reserved {
+		re, err := regexp.Compile("(?i)^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid reserved basket name pattern: %s - %s", pattern, err)
+		}
+		policy.reserved = append(policy.reserved, re)
+	}
+
+	if len(blocklist) > 0 {
+		words, err := readBlocklist(blocklist)
+		if err != nil {
+			return nil, err
+		}
+		policy.blocked = words
+	}
+	return policy, nil
+}
+
+// readBlocklist reads normalized words of basket name blocklist from the file
+func readBlocklist(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read basket name blocklist: %s - %s", file, err)
+	}
+	defer f.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if word := normalizeBasketName(line); len(word) > 0 {
+			words = append(words, word)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read basket name blocklist: %s - %s", file, err)
+	}
+	return words, nil
+}
+
+// normalizeBasketName converts name to lower case, drops separators and undoes common character substitutions,
+// so "Pay-P4l" and "paypal" are the same name for blocklist
+func normalizeBasketName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("-", "", "_", "", ".", "", " ", "").Replace(name)
+	return leetReplacer.Replace(name)
+}
+
+// Check checks if a new basket may be created with the name
+func (policy *basketNamePolicy) Check(name string) error {
+	if len(name) < policy.minLength {
+		return fmt.Errorf("basket name should be at least %d characters long", policy.minLength)
+	}
+	for _, re := range policy.reserved {
+		if re.MatchString(name) {
+			return fmt.Errorf("basket name is reserved: %s", name)
+		}
+	}
+	if len(policy.blocked) > 0 {
+		normalized := normalizeBasketName(name)
+		for _, word := range policy.blocked {
+			if strings.Contains(normalized, word) {
+				return fmt.Errorf("basket name is not allowed: %s", name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBlocklist writes words to a temporary blocklist file and returns its location
+func testBlocklist(t *testing.T, words ...string) string {
+	file, err := ioutil.TempFile("", "blocklist")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	file.WriteString("# blocked words\n\n" + strings.Join(words, "\n") + "\n")
+	file.Close()
+	return file.Name()
+}
+
+func TestBasketNamePolicy(t *testing.T) {
+	blocklist := testBlocklist(t, "PayPal", "  darn  ")
+	defer os.Remove(blocklist)
+
+	policy, err := newBasketNamePolicy(5, []string{"admin.*", "www|status"}, blocklist)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, policy.Check("orders"))
+	assert.NoError(t, policy.Check("my-admin"), "reserved pattern is expected to match entire name")
+	assert.NoError(t, policy.Check("wwwhooks"), "reserved pattern is expected to match entire name")
+
+	assert.Error(t, policy.Check("demo"), "short name is not expected")
+	assert.Error(t, policy.Check("Admin-hooks"), "reserved name is not expected")
+	assert.Error(t, policy.Check("STATUS"), "reserved name is not expected")
+	assert.Error(t, policy.Check("paypal-login"), "blocked word is not expected")
+	assert.Error(t, policy.Check("pay_p4l.hooks"), "obfuscated blocked word is not expected")
+	assert.Error(t, policy.Check("dam-darn-it"), "blocked word is not expected")
+
+	// no restrictions by default
+	assert.NoError(t, (&basketNamePolicy{}).Check("a"))
+}
+
+func TestNewBasketNamePolicy_Invalid(t *testing.T) {
+	_, err := newBasketNamePolicy(0, []string{"admin("}, "")
+	assert.Error(t, err, "invalid pattern is not expected")
+
+	_, err = newBasketNamePolicy(0, nil, "unknown-blocklist.txt")
+	assert.Error(t, err, "missing blocklist is not expected")
+}
+
+func TestCreateBasket_NamePolicy(t *testing.T) {
+	defer func(p *basketNamePolicy) { namePolicy = p }(namePolicy)
+	policy, err := newBasketNamePolicy(0, []string{"billing"}, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	namePolicy = policy
+
+	basket := "billing"
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 403 - forbidden
+		assert.Equal(t, 403, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), ErrorInvalidBasketName, "wrong error code")
+		assert.Nil(t, basketsDb.Get(basket), "basket '%v' is not expected to be created", basket)
+	}
+}
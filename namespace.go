@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+const maxNamespacePolicies = 50
+
+var namespaces *namespacePolicies
+
+// NamespacePolicy describes retention and quota of baskets which names match the pattern, e.g. "ci-*" or
+// "prod-debug.*", so the baskets do not need to be configured one by one. Periods of namespace and basket do not
+// override each other, the shortest period applies.
+type NamespacePolicy struct {
+	Pattern      string `json:"pattern"`                 // shell pattern of basket names, see path.Match
+	Retention    int    `json:"retention,omitempty"`     // maximum age of collected requests in seconds
+	ExpiresAfter int    `json:"expires_after,omitempty"` // seconds without collected requests or changes before basket is deleted
+	MaxBytes     int64  `json:"max_bytes,omitempty"`     // bytes stored by all baskets of namespace
+}
+
+// NamespaceStatus describes namespace policy along with approximate number of bytes stored by baskets of namespace.
+type NamespaceStatus struct {
+	NamespacePolicy
+	StoredBytes int64 `json:"stored_bytes,omitempty"` // measured only if namespace has storage quota
+}
+
+// namespacePolicies keeps policies of namespaces and approximate number of bytes stored by baskets of every
+// namespace; a basket belongs to the namespace of the first policy that matches its name
+type namespacePolicies struct {
+	sync.RWMutex
+	db       BasketsDatabase
+	policies []NamespacePolicy
+	bytes    map[string]int64 // bytes stored by baskets of namespaces by pattern
+}
+
+func newNamespacePolicies(db BasketsDatabase) *namespacePolicies {
+	return &namespacePolicies{db: db, policies: make([]NamespacePolicy, 0), bytes: make(map[string]int64)}
+}
+
+// loadNamespacePolicies reads namespace policies from JSON file
+func loadNamespacePolicies(file string) ([]NamespacePolicy, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace policies: %s", err)
+	}
+
+	policies := []NamespacePolicy{}
+	if err = json.Unmarshal(content, &policies); err != nil {
+		return nil, fmt.Errorf("invalid namespace policies: %s - %s", file, err)
+	}
+	return policies, validateNamespacePolicies(policies)
+}
+
+// validateNamespacePolicies validates namespace policies, patterns of policies must be unique
+func validateNamespacePolicies(policies []NamespacePolicy) error {
+	if len(policies) > maxNamespacePolicies {
+		return fmt.Errorf("too many namespace policies: %d, maximum is %d", len(policies), maxNamespacePolicies)
+	}
+
+	patterns := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		if _, err := path.Match(policy.Pattern, ""); err != nil || len(policy.Pattern) == 0 {
+			return fmt.Errorf("invalid pattern of namespace policy: '%s'", policy.Pattern)
+		}
+		if patterns[policy.Pattern] {
+			return fmt.Errorf("duplicate namespace policy: %s", policy.Pattern)
+		}
+		patterns[policy.Pattern] = true
+
+		if policy.Retention == 0 && policy.ExpiresAfter == 0 && policy.MaxBytes == 0 {
+			return fmt.Errorf("namespace policy: %s defines neither retention, expiration nor storage quota", policy.Pattern)
+		}
+		if err := validateRetention(policy.Retention); err != nil {
+			return fmt.Errorf("namespace policy: %s - %s", policy.Pattern, err)
+		}
+		if err := validateExpiresAfter(policy.ExpiresAfter); err != nil {
+			return fmt.Errorf("namespace policy: %s - %s", policy.Pattern, err)
+		}
+		if policy.MaxBytes < 0 {
+			return fmt.Errorf("namespace policy: %s - storage quota should not be negative, but was %d", policy.Pattern,
+				policy.MaxBytes)
+		}
+	}
+	return nil
+}
+
+// shortestPeriod returns the shortest positive period, 0 if no period is defined
+func shortestPeriod(periods ...int) int {
+	shortest := 0
+	for _, period := range periods {
+		if period > 0 && (shortest == 0 || period < shortest) {
+			shortest = period
+		}
+	}
+	return shortest
+}
+
+// Policies returns namespace policies
+func (n *namespacePolicies) Policies() []NamespacePolicy {
+	n.RLock()
+	defer n.RUnlock()
+	return n.policies
+}
+
+// SetPolicies replaces namespace policies and measures storage of namespaces, policies are expected to be validated
+func (n *namespacePolicies) SetPolicies(policies []NamespacePolicy) {
+	n.Lock()
+	n.policies = policies
+	n.Unlock()
+	n.Measure()
+}
+
+// Match returns policy of namespace the basket belongs to, nil if basket does not belong to any namespace
+func (n *namespacePolicies) Match(name string) *NamespacePolicy {
+	n.RLock()
+	defer n.RUnlock()
+	return n.match(name)
+}
+
+func (n *namespacePolicies) match(name string) *NamespacePolicy {
+	for i, policy := range n.policies {
+		if matched, _ := path.Match(policy.Pattern, name); matched {
+			return &n.policies[i]
+		}
+	}
+	return nil
+}
+
+// Start launches background routine that measures storage of namespaces every storageMeasureInterval
+func (n *namespacePolicies) Start() {
+	go func() {
+		for {
+			time.Sleep(storageMeasureInterval)
+			n.Measure()
+		}
+	}()
+}
+
+// Measure counts bytes stored by baskets of namespaces with storage quota
+func (n *namespacePolicies) Measure() {
+	limited := false
+	for _, policy := range n.Policies() {
+		limited = limited || policy.MaxBytes > 0
+	}
+
+	bytes := make(map[string]int64)
+	if limited {
+		forEachBasket(n.db, func(name string, basket Basket) {
+			if policy := n.Match(name); policy != nil && policy.MaxBytes > 0 {
+				bytes[policy.Pattern] += storage.Bytes(name, basket)
+			}
+		})
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	n.bytes = bytes
+}
+
+// Status returns namespace policies along with bytes stored by baskets of namespaces with storage quota
+func (n *namespacePolicies) Status() []NamespaceStatus {
+	n.RLock()
+	defer n.RUnlock()
+
+	status := make([]NamespaceStatus, len(n.policies))
+	for i, policy := range n.policies {
+		status[i] = NamespaceStatus{policy, n.bytes[policy.Pattern]}
+	}
+	return status
+}
+
+// Reserve checks if request of given size fits into the storage quota of basket namespace and accounts it;
+// returns policy of namespace which quota is exhausted, nil if request fits
+func (n *namespacePolicies) Reserve(name string, bytes int64) *NamespacePolicy {
+	n.Lock()
+	defer n.Unlock()
+
+	policy := n.match(name)
+	if policy == nil || policy.MaxBytes <= 0 {
+		return nil
+	}
+	if n.bytes[policy.Pattern]+bytes > policy.MaxBytes {
+		return policy
+	}
+	n.bytes[policy.Pattern] += bytes
+	return nil
+}
+
+// checkNamespaceStorage checks if request of given size fits into the storage quota of basket namespace;
+// writes HTTP response and returns false if the quota is exhausted
+func checkNamespaceStorage(w http.ResponseWriter, name string, bytes int64) bool {
+	if policy := namespaces.Reserve(name, bytes); policy != nil {
+		http.Error(w, fmt.Sprintf("storage quota of namespace: %s is exhausted: %d bytes", policy.Pattern, policy.MaxBytes),
+			http.StatusInsufficientStorage)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNamespacePolicies(t *testing.T) {
+	assert.NoError(t, validateNamespacePolicies([]NamespacePolicy{
+		{Pattern: "ci-*", ExpiresAfter: 86400},
+		{Pattern: "prod-debug.*", Retention: 2592000, MaxBytes: 1024}}))
+
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "ci-[", Retention: 60}}),
+		"invalid pattern is not expected")
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "", Retention: 60}}),
+		"empty pattern is not expected")
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "ci-*"}}),
+		"policy without settings is not expected")
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "ci-*", Retention: -1}}),
+		"negative retention is not expected")
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "ci-*", MaxBytes: -1}}),
+		"negative quota is not expected")
+	assert.Error(t, validateNamespacePolicies([]NamespacePolicy{{Pattern: "ci-*", Retention: 60},
+		{Pattern: "ci-*", ExpiresAfter: 60}}), "duplicate patterns are not expected")
+}
+
+func TestShortestPeriod(t *testing.T) {
+	assert.Equal(t, 0, shortestPeriod(), "no period is expected")
+	assert.Equal(t, 0, shortestPeriod(0, 0), "no period is expected")
+	assert.Equal(t, 60, shortestPeriod(0, 3600, 60), "wrong shortest period")
+}
+
+func TestNamespacePolicies_Match(t *testing.T) {
+	n := newNamespacePolicies(nil)
+	n.policies = []NamespacePolicy{{Pattern: "ci-*", Retention: 60}, {Pattern: "*", Retention: 3600}}
+
+	if policy := n.Match("ci-build"); assert.NotNil(t, policy, "namespace is expected") {
+		assert.Equal(t, "ci-*", policy.Pattern, "the first matching namespace is expected")
+	}
+	if policy := n.Match("demo"); assert.NotNil(t, policy, "namespace is expected") {
+		assert.Equal(t, "*", policy.Pattern, "wrong namespace")
+	}
+	n.policies = n.policies[:1]
+	assert.Nil(t, n.Match("demo"), "basket is not expected to belong to namespace")
+}
+
+func TestNamespacePolicies_Reserve(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("ns01-a", BasketConfig{Capacity: 20})
+	db.Create("ns01-b", BasketConfig{Capacity: 20})
+	db.Get("ns01-a").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: strings.Repeat("a", 95)})
+	db.Get("ns01-b").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: strings.Repeat("b", 95)})
+
+	n := newNamespacePolicies(db)
+	n.SetPolicies([]NamespacePolicy{{Pattern: "ns01-*", MaxBytes: 250}})
+	if status := n.Status(); assert.Len(t, status, 1, "wrong number of namespaces") {
+		assert.Equal(t, int64(200), status[0].StoredBytes, "wrong number of stored bytes")
+	}
+
+	assert.Nil(t, n.Reserve("ns01-a", 50), "request is expected to fit into quota")
+	if policy := n.Reserve("ns01-b", 10); assert.NotNil(t, policy, "quota is expected to be exhausted") {
+		assert.Equal(t, "ns01-*", policy.Pattern, "wrong namespace")
+	}
+	assert.Nil(t, n.Reserve("ns02", 1000), "basket outside of namespace is not expected to be limited")
+}
+
+func TestRequestRetention_Namespace(t *testing.T) {
+	defer func(current *namespacePolicies) { namespaces = current }(namespaces)
+	namespaces = newNamespacePolicies(nil)
+	namespaces.policies = []NamespacePolicy{{Pattern: "ci-*", Retention: 60}}
+
+	rr := newRequestRetention(nil, 3600)
+	assert.Equal(t, 60, rr.MaxAge("ci-build", BasketConfig{}), "retention of namespace is expected")
+	assert.Equal(t, 30, rr.MaxAge("ci-build", BasketConfig{Retention: 30}), "shorter retention of basket is expected")
+	assert.Equal(t, 3600, rr.MaxAge("demo", BasketConfig{}), "retention of service is expected")
+}
+
+func TestBasketExpiry_Namespace(t *testing.T) {
+	defer func(current *namespacePolicies) { namespaces = current }(namespaces)
+	namespaces = newNamespacePolicies(nil)
+	namespaces.policies = []NamespacePolicy{{Pattern: "ci-*", ExpiresAfter: 86400}}
+
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create("ci-build", BasketConfig{Capacity: 20})
+	db.Create("demo", BasketConfig{Capacity: 20})
+	db.Get("ci-build").AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+	db.Get("demo").AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+
+	be := newBasketExpiry(db, db.Delete)
+	assert.Empty(t, be.Expire(time.Now()), "basket is not expected to expire")
+	assert.Equal(t, []string{"ci-build"}, be.Expire(time.Now().Add(25*time.Hour)), "basket of namespace is expected to expire")
+}
+
+func TestLoadNamespacePolicies(t *testing.T) {
+	file, _ := ioutil.TempFile("", "namespaces*.json")
+	file.WriteString(`[{"pattern": "ci-*", "expires_after": 86400}]`)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	policies, err := loadNamespacePolicies(file.Name())
+	if assert.NoError(t, err) && assert.Len(t, policies, 1, "wrong number of policies") {
+		assert.Equal(t, 86400, policies[0].ExpiresAfter, "wrong expiration period")
+	}
+
+	_, err = loadNamespacePolicies(file.Name() + ".unknown")
+	assert.Error(t, err, "missing file is not expected")
+}
+
+func TestNamespacePolicies_API(t *testing.T) {
+	defer namespaces.SetPolicies([]NamespacePolicy{})
+	call := func(method string, path string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "ns03-quota"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 422, call("PUT", "/api/namespaces", `[{"pattern": "ns03-*"}]`).Code, "invalid policy is not expected")
+	assert.Equal(t, 204, call("PUT", "/api/namespaces", `[{"pattern": "ns03-*", "max_bytes": 100}]`).Code,
+		"wrong HTTP result code")
+
+	w := call("GET", "/api/namespaces", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		status := []NamespaceStatus{}
+		json.Unmarshal(w.Body.Bytes(), &status)
+		if assert.Len(t, status, 1, "wrong number of namespaces") {
+			assert.Equal(t, int64(100), status[0].MaxBytes, "wrong storage quota")
+		}
+	}
+
+	assert.Equal(t, 200, call("POST", "/"+basket, "small").Code, "request is expected to fit into quota")
+	assert.Equal(t, http.StatusInsufficientStorage, call("POST", "/"+basket, strings.Repeat("x", 200)).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 1, basketsDb.Get(basket).Size(), "rejected request is not expected to be collected")
+}
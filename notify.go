@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	starjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const notifyTimeout = 10 * time.Second
+
+var notifyClient = newOutboundClient(notifyTimeout)
+
+// notifyModule is a Starlark module with builtins to send notifications from trigger scripts
+var notifyModule = &starlarkstruct.Module{
+	Name: "notify",
+	Members: starlark.StringDict{
+		"slack":   starlark.NewBuiltin("notify.slack", notifySlack),
+		"webhook": starlark.NewBuiltin("notify.webhook", notifyWebhook),
+	},
+}
+
+// notifySlack posts a text message to Slack incoming webhook: notify.slack(webhook, text)
+func notifySlack(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var webhook, text string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "webhook", &webhook, "text", &text); err != nil {
+		return nil, err
+	}
+
+	payload, err := toJSON(thread, starlarkDict("text", starlark.String(text)))
+	if err != nil {
+		return nil, err
+	}
+
+	return postNotification(b.Name(), webhook, "application/json", payload)
+}
+
+// notifyWebhook posts a payload to arbitrary URL: notify.webhook(url, payload, content_type=None),
+// string payload is sent as is, any other value is encoded as JSON
+func notifyWebhook(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url string
+	var payload starlark.Value
+	var contentType string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "payload", &payload, "content_type?", &contentType); err != nil {
+		return nil, err
+	}
+
+	var body string
+	if text, ok := payload.(starlark.String); ok {
+		body = string(text)
+		if len(contentType) == 0 {
+			contentType = "text/plain; charset=utf-8"
+		}
+	} else {
+		encoded, err := toJSON(thread, payload)
+		if err != nil {
+			return nil, err
+		}
+		body = encoded
+		if len(contentType) == 0 {
+			contentType = "application/json"
+		}
+	}
+
+	return postNotification(b.Name(), url, contentType, body)
+}
+
+// postNotification sends notification and returns HTTP status of response
+func postNotification(builtin string, url string, contentType string, body string) (starlark.Value, error) {
+	if err := checkOutboundURL(url); err != nil {
+		return nil, fmt.Errorf("%s: %s", builtin, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", builtin, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", serviceName)
+	req.Header.Set(DoNotForwardHeader, "1")
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", builtin, err)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return starlark.MakeInt(resp.StatusCode), nil
+}
+
+func toJSON(thread *starlark.Thread, value starlark.Value) (string, error) {
+	encoded, err := starlark.Call(thread, starjson.Module.Members["encode"], starlark.Tuple{value}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded.(starlark.String)), nil
+}
+
+func starlarkDict(key string, value starlark.Value) *starlark.Dict {
+	dict := starlark.NewDict(1)
+	dict.SetKey(starlark.String(key), value)
+	return dict
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyWebhook(t *testing.T) {
+	// Test HTTP server
+	var notification *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notification = ToRequestData(r)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Method = "POST"
+	data.Path = "/notify01"
+
+	out, err := scriptTrigger("notify01",
+		"print(notify.webhook('"+ts.URL+"/events', {'path': request['Path'], 'ok': True}))", data, nil)
+	if assert.NoError(t, err) && assert.NotNil(t, notification, "notification is expected") {
+		assert.Equal(t, "202\n", out, "wrong script output")
+		assert.Equal(t, "POST", notification.Method, "wrong notification method")
+		assert.Equal(t, "/events", notification.Path, "wrong notification path")
+		assert.Equal(t, "application/json", notification.Header.Get("Content-Type"), "wrong Content-Type")
+		assert.Equal(t, "1", notification.Header.Get(DoNotForwardHeader), "notification should not be forwarded")
+		assert.JSONEq(t, "{\"path\":\"/notify01\",\"ok\":true}", notification.Body, "wrong notification payload")
+	}
+}
+
+func TestNotifyWebhook_Text(t *testing.T) {
+	// Test HTTP server
+	var notification *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notification = ToRequestData(r)
+	}))
+	defer ts.Close()
+
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	_, err := scriptTrigger("notify02", "notify.webhook('"+ts.URL+"', 'a=1', content_type='application/x-www-form-urlencoded')", data, nil)
+	if assert.NoError(t, err) && assert.NotNil(t, notification, "notification is expected") {
+		assert.Equal(t, "application/x-www-form-urlencoded", notification.Header.Get("Content-Type"), "wrong Content-Type")
+		assert.Equal(t, "a=1", notification.Body, "wrong notification payload")
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	// Test HTTP server
+	var notification *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notification = ToRequestData(r)
+	}))
+	defer ts.Close()
+
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Body = "payment.failed"
+
+	_, err := scriptTrigger("notify03", "notify.slack('"+ts.URL+"', 'received: ' + request['Body'])", data, nil)
+	if assert.NoError(t, err) && assert.NotNil(t, notification, "notification is expected") {
+		assert.Equal(t, "application/json", notification.Header.Get("Content-Type"), "wrong Content-Type")
+		assert.JSONEq(t, "{\"text\":\"received: payment.failed\"}", notification.Body, "wrong notification payload")
+	}
+}
+
+func TestNotifyWebhook_Unreachable(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	_, err := scriptTrigger("notify04", "notify.webhook('http://localhost:81/', 'test')", data, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "notify.webhook", "wrong error message")
+	}
+}
+
+func TestNotifyWebhook_Denied(t *testing.T) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer ts.Close()
+	defer denyLoopbackTargets()()
+
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	for _, script := range []string{"notify.webhook('" + ts.URL + "', 'test')", "notify.slack('" + ts.URL + "', 'test')"} {
+		_, err := scriptTrigger("notify05", script, data, nil)
+		if assert.Error(t, err, "notification to denied address is not expected") {
+			assert.Contains(t, err.Error(), "is not allowed", "wrong error message")
+		}
+	}
+	assert.False(t, called, "denied address is not expected to be reached")
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	oidcCookieName   = "rb_oidc_login"
+	oidcLoginTimeout = 10 * time.Minute
+	oidcScope        = "openid email profile"
+)
+
+// invalidIdentityName matches characters of identity claim that are not allowed in user names
+var invalidIdentityName = regexp.MustCompile(`[^\w\d\-_\.]`)
+
+var oidc *oidcProvider
+
+// oidcCallbackTemplate stores the token in browser session the same way the master token is stored by web UI
+var oidcCallbackTemplate = template.Must(template.New("oidc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Request Baskets</title>
+  <script>
+    sessionStorage.setItem("master_token", {{.Token}});
+    window.location.replace({{.Redirect}});
+  </script>
+</head>
+<body></body>
+</html>`))
+
+// claimRule matches identity claim, the claim may refer to nested claims with dots, e.g. realm_access.roles=admin
+type claimRule struct {
+	claim string
+	value string
+}
+
+// oidcEndpoints describes endpoints of OpenID Connect provider published with discovery document
+type oidcEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey describes public key of OpenID Connect provider that signs identity tokens
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcLogin describes pending sign in, it is kept in signed cookie until the provider redirects back to the service
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	Redirect string `json:"redirect"` // web UI page to return to
+	Expires  int64  `json:"exp"`
+}
+
+// oidcProvider signs in users with OpenID Connect provider, identities are mapped to user accounts
+// or to the master token if identity matches admin rules
+type oidcProvider struct {
+	sync.Mutex
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	userClaim    string
+	admins       []claimRule
+	allowed      []claimRule
+	secret       []byte // signs login cookies
+	client       *http.Client
+
+	endpoints *oidcEndpoints // discovered on the first use
+	keySet    *jsonWebKeySet
+}
+
+// parseClaimRule parses rule in claim=value format
+func parseClaimRule(rule string) (claimRule, error) {
+	i := strings.Index(rule, "=")
+	if i < 1 {
+		return claimRule{}, fmt.Errorf("invalid claim rule, claim=value is expected: %s", rule)
+	}
+	return claimRule{rule[:i], rule[i+1:]}, nil
+}
+
+// Matches checks if the claim has the value or the claim is a list that contains the value
+func (rule claimRule) Matches(claims map[string]interface{}) bool {
+	var value interface{} = claims
+	for _, name := range strings.Split(rule.claim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if value, ok = object[name]; !ok {
+			return false
+		}
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if fmt.Sprint(item) == rule.value {
+				return true
+			}
+		}
+		return false
+	}
+	return value != nil && fmt.Sprint(value) == rule.value
+}
+
+// matchesAny checks if any of rules matches claims
+func matchesAny(rules []claimRule, claims map[string]interface{}) bool {
+	for _, rule := range rules {
+		if rule.Matches(claims) {
+			return true
+		}
+	}
+	return false
+}
+
+// newOIDCProvider creates OpenID Connect provider from server configuration, the provider is discovered
+// once the first user signs in
+func newOIDCProvider(config *ServerConfig) (*oidcProvider, error) {
+	issuer := strings.TrimSuffix(config.OIDCIssuer, "/")
+	if u, err := url.Parse(issuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid OpenID Connect issuer: %s", config.OIDCIssuer)
+	}
+	if len(config.OIDCClientID) == 0 {
+		return nil, fmt.Errorf("client ID of OpenID Connect provider is required")
+	}
+	if u, err := url.Parse(config.OIDCRedirect); err != nil || !u.IsAbs() {
+		return nil, fmt.Errorf("absolute redirect URL of OpenID Connect login is required, e.g. "+
+			"https://baskets.example.com%s/%s/oidc/callback", config.PathPrefix, serviceAPIPath)
+	}
+
+	p := &oidcProvider{
+		issuer:       issuer,
+		clientID:     config.OIDCClientID,
+		clientSecret: config.OIDCClientSecret,
+		redirectURL:  config.OIDCRedirect,
+		userClaim:    config.OIDCUserClaim,
+		secret:       make([]byte, 32),
+		client:       &http.Client{Timeout: 10 * time.Second}}
+	p.keySet = newJSONWebKeySet(p.client)
+	if _, err := rand.Read(p.secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret of login state: %s", err)
+	}
+
+	for _, rule := range config.OIDCAdmins {
+		parsed, err := parseClaimRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.admins = append(p.admins, parsed)
+	}
+	for _, rule := range config.OIDCAllowed {
+		parsed, err := parseClaimRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.allowed = append(p.allowed, parsed)
+	}
+
+	return p, nil
+}
+
+// Login starts sign in and returns pending login together with URL of provider authorization page
+func (p *oidcProvider) Login(redirect string) (*oidcLogin, string, error) {
+	endpoints, err := p.discover()
+	if err != nil {
+		return nil, "", err
+	}
+
+	login := &oidcLogin{Redirect: redirect, Expires: time.Now().Add(oidcLoginTimeout).Unix()}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		if *value, err = GenerateToken(); err != nil {
+			return nil, "", fmt.Errorf("failed to generate login state: %s", err)
+		}
+	}
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", oidcScope)
+	params.Set("state", login.State)
+	params.Set("nonce", login.Nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return login, endpoints.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Cookie creates cookie that keeps pending login, the cookie is signed, so it cannot be forged by the client
+func (p *oidcProvider) Cookie(login *oidcLogin) *http.Cookie {
+	data, _ := json.Marshal(login)
+	value := base64.RawURLEncoding.EncodeToString(data)
+	return &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    value + "." + p.sign(value),
+		Path:     serverConfig.PathPrefix + "/",
+		MaxAge:   int(oidcLoginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(p.redirectURL, "https:"),
+		SameSite: http.SameSiteLaxMode}
+}
+
+// ParseCookie restores pending login from the cookie
+func (p *oidcProvider) ParseCookie(cookie *http.Cookie) (*oidcLogin, error) {
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(p.sign(parts[0]))) {
+		return nil, fmt.Errorf("invalid login state")
+	}
+
+	data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	login := new(oidcLogin)
+	if err := json.Unmarshal(data, login); err != nil {
+		return nil, fmt.Errorf("invalid login state")
+	}
+	if time.Now().Unix() > login.Expires {
+		return nil, fmt.Errorf("login is expired, please try again")
+	}
+	return login, nil
+}
+
+// SignIn exchanges authorization code for identity token and returns the token of user account or
+// the master token the identity is mapped to
+func (p *oidcProvider) SignIn(code string, login *oidcLogin) (string, error) {
+	endpoints, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("code_verifier", login.Verifier)
+
+	req, _ := http.NewRequest(http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(p.clientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange authorization code: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to exchange authorization code: %s - %s", resp.Status, body)
+	}
+	tokens := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err = json.Unmarshal(body, &tokens); err != nil || len(tokens.IDToken) == 0 {
+		return "", fmt.Errorf("identity token is not issued by provider")
+	}
+
+	claims, err := p.Verify(tokens.IDToken, login.Nonce)
+	if err != nil {
+		return "", err
+	}
+	return p.Identify(claims)
+}
+
+// Verify checks signature and claims of identity token issued for the service and returns its claims,
+// nonce is verified unless empty
+func (p *oidcProvider) Verify(token string, nonce string) (map[string]interface{}, error) {
+	claims, err := verifyTokenSignature(token, p.key)
+	if err != nil {
+		return nil, err
+	}
+	if claims["iss"] != p.issuer {
+		return nil, fmt.Errorf("token is issued by unexpected issuer: %v", claims["iss"])
+	}
+	if !p.isAudience(claims) {
+		return nil, fmt.Errorf("token is issued for other client")
+	}
+	if err = checkTokenTime(claims); err != nil {
+		return nil, err
+	}
+	if len(nonce) > 0 && claims["nonce"] != nonce {
+		return nil, fmt.Errorf("token is issued for other login")
+	}
+	return claims, nil
+}
+
+// Identify maps claims of verified identity to the master token for admins or to the token of user account,
+// the account is created on the first sign in
+func (p *oidcProvider) Identify(claims map[string]interface{}) (string, error) {
+	if len(p.allowed) > 0 && !matchesAny(p.allowed, claims) && !matchesAny(p.admins, claims) {
+		return "", fmt.Errorf("identity is not allowed to sign in")
+	}
+	if matchesAny(p.admins, claims) {
+		return serverConfig.MasterToken, nil
+	}
+
+	value, _ := claims[p.userClaim].(string)
+	name := invalidIdentityName.ReplaceAllString(value, "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	subject, _ := claims["sub"].(string)
+	if len(name) == 0 || len(subject) == 0 {
+		return "", fmt.Errorf("identity has no '%s' claim", p.userClaim)
+	}
+
+	auth, err := users.Provision(name, p.issuer+"#"+subject, defaultUserConfig())
+	if err != nil {
+		return "", err
+	}
+	return auth.Token, nil
+}
+
+// isAudience checks if token is issued for the service, authorized party must be the service if present
+func (p *oidcProvider) isAudience(claims map[string]interface{}) bool {
+	if azp, present := claims["azp"]; present && azp != p.clientID {
+		return false
+	}
+	return hasAudience(claims, p.clientID)
+}
+
+// discover fetches discovery document of provider
+func (p *oidcProvider) discover() (*oidcEndpoints, error) {
+	p.Lock()
+	endpoints := p.endpoints
+	p.Unlock()
+	if endpoints != nil {
+		return endpoints, nil
+	}
+
+	endpoints = new(oidcEndpoints)
+	if err := fetchJSON(p.client, p.issuer+"/.well-known/openid-configuration", endpoints); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(endpoints.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("OpenID Connect provider is discovered with unexpected issuer: %s", endpoints.Issuer)
+	}
+
+	p.Lock()
+	p.endpoints = endpoints
+	p.Unlock()
+	return endpoints, nil
+}
+
+// key returns public key of provider by its ID, signing keys are published at the URL of discovery document
+func (p *oidcProvider) key(kid string) (crypto.PublicKey, error) {
+	endpoints, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	return p.keySet.Key(endpoints.JWKSURI, kid)
+}
+
+// sign calculates signature of login cookie value
+func (p *oidcProvider) sign(value string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// PublicKey converts JSON web key into RSA or EC P-256 public key
+func (jwk jsonWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+// decodeTokenPart decodes JSON encoded header or claims of token
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	return nil
+}
+
+// isWebRedirect checks if sign in may return to the path, only pages of web UI are accepted
+func isWebRedirect(path string) bool {
+	web := serverConfig.PathPrefix + "/" + serviceUIPath
+	return (path == web || strings.HasPrefix(path, web+"/") || strings.HasPrefix(path, web+"?")) &&
+		!strings.Contains(path, "//") && !strings.Contains(path, "\\")
+}
+
+// bearerToken returns token of Authorization header with Bearer scheme
+func bearerToken(r *http.Request) string {
+	value := r.Header.Get("Authorization")
+	if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return ""
+}
+
+// withIdentity replaces JSON web token presented as bearer token, credentials of LDAP directory user presented
+// with basic authentication, client certificate or identity headers of trusted authentication proxy with the token
+// of user account, service role or the master token the identity is mapped to, so handlers authorize requests as usual
+func withIdentity(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if token := bearerToken(r); (oidc != nil || jwt != nil) && strings.Count(token, ".") == 2 {
+			authenticated, err := authenticateBearer(r, token)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, ErrorUnauthorized, "Invalid identity token: "+err.Error(), nil)
+				return
+			}
+			r = authenticated
+		} else if username, password, ok := r.BasicAuth(); ldap != nil && ok {
+			session, status, err := ldap.Login(username, password)
+			if err != nil {
+				httpError(w, err.Error(), status)
+				return
+			}
+			r.Header.Set("Authorization", session.Token)
+		} else if clientCerts != nil && len(r.Header.Get("Authorization")) == 0 && clientCerts.Certificate(r) != nil {
+			token, err := clientCerts.Identify(r)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(token) > 0 {
+				r.Header.Set("Authorization", token)
+			}
+		} else if proxyAuth != nil && len(r.Header.Get("Authorization")) == 0 && proxyAuth.IsTrusted(r) {
+			token, status, err := proxyAuth.Identify(r)
+			if err != nil {
+				httpError(w, err.Error(), status)
+				return
+			}
+			if len(token) > 0 {
+				r.Header.Set("Authorization", token)
+			}
+		}
+		handler(w, r, ps)
+	}
+}
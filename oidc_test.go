@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testOIDCServer is fake OpenID Connect provider that issues identity tokens signed with RSA key
+type testOIDCServer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{} // claims of identity token issued for authorization code
+}
+
+func newTestOIDCServer(t *testing.T) *testOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	s := &testOIDCServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcEndpoints{s.URL, s.URL + "/authorize", s.URL + "/token", s.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": {{Kty: "RSA", Kid: "key01", Use: "sig",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "code01" || len(r.PostForm.Get("code_verifier")) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s.Sign("key01", s.claims)})
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Sign issues identity token with claims
+func (s *testOIDCServer) Sign(kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Claims returns valid claims of identity token issued for the test client
+func (s *testOIDCServer) Claims(subject string, email string) map[string]interface{} {
+	return map[string]interface{}{"iss": s.URL, "aud": "client01", "sub": subject, "email": email,
+		"email_verified": true, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+// Provider creates OpenID Connect provider configured to sign in with fake provider
+func (s *testOIDCServer) Provider(t *testing.T) *oidcProvider {
+	p, err := newOIDCProvider(&ServerConfig{OIDCIssuer: s.URL + "/", OIDCClientID: "client01",
+		OIDCRedirect: "http://localhost:55555/api/oidc/callback", OIDCUserClaim: defaultOIDCClaim,
+		OIDCAdmins: []string{"groups=admins"}, OIDCAllowed: []string{"email_verified=true"}})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return p
+}
+
+// sessionToken extracts the token stored in browser session by sign in page
+func sessionToken(page string) string {
+	match := regexp.MustCompile(`sessionStorage\.setItem\("master_token", "([^"]*)"\)`).FindStringSubmatch(page)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func TestClaimRule_Matches(t *testing.T) {
+	claims := map[string]interface{}{"email": "alice@example.com", "email_verified": true, "level": float64(3),
+		"groups": []interface{}{"dev", "admins"}, "realm_access": map[string]interface{}{"roles": []interface{}{"admin"}}}
+
+	for rule, expected := range map[string]bool{
+		"email=alice@example.com":  true,
+		"email=bob@example.com":    false,
+		"email_verified=true":      true,
+		"level=3":                  true,
+		"groups=admins":            true,
+		"groups=ops":               false,
+		"realm_access.roles=admin": true,
+		"realm_access.roles=user":  false,
+		"email.domain=example.com": false,
+		"unknown=":                 false,
+		"role=a=b":                 false} {
+		parsed, err := parseClaimRule(rule)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, parsed.Matches(claims), "wrong match of rule: %s", rule)
+		}
+	}
+
+	_, err := parseClaimRule("=admin")
+	assert.Error(t, err, "rule without claim is not expected")
+	_, err = parseClaimRule("admin")
+	assert.Error(t, err, "rule without value is not expected")
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	valid := ServerConfig{OIDCIssuer: "https://accounts.example.com", OIDCClientID: "client01",
+		OIDCRedirect: "https://baskets.example.com/api/oidc/callback"}
+	_, err := newOIDCProvider(&valid)
+	assert.NoError(t, err)
+
+	invalid := []ServerConfig{valid, valid, valid, valid, valid}
+	invalid[0].OIDCIssuer = "accounts.example.com"
+	invalid[1].OIDCClientID = ""
+	invalid[2].OIDCRedirect = "/api/oidc/callback"
+	invalid[3].OIDCAdmins = []string{"admins"}
+	invalid[4].OIDCAllowed = []string{"=true"}
+	for _, config := range invalid {
+		_, err = newOIDCProvider(&config)
+		assert.Error(t, err, "invalid configuration is not expected: %v", config)
+	}
+}
+
+func TestOIDCProvider_Verify(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	p := server.Provider(t)
+
+	claims := server.Claims("user01", "alice@example.com")
+	claims["nonce"] = "nonce01"
+	verified, err := p.Verify(server.Sign("key01", claims), "nonce01")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice@example.com", verified["email"], "wrong claims")
+	}
+	_, err = p.Verify(server.Sign("key01", claims), "")
+	assert.NoError(t, err, "nonce is not expected to be verified")
+
+	invalid := map[string]func(map[string]interface{}){
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://other.example.com" },
+		"audience": func(c map[string]interface{}) { c["aud"] = []string{"client02"} },
+		"party":    func(c map[string]interface{}) { c["aud"] = []string{"client01", "client02"}; c["azp"] = "client02" },
+		"expired":  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * jwtClockSkew).Unix() },
+		"early":    func(c map[string]interface{}) { c["nbf"] = time.Now().Add(2 * jwtClockSkew).Unix() },
+		"nonce":    func(c map[string]interface{}) { c["nonce"] = "nonce02" }}
+	for name, modify := range invalid {
+		claims := server.Claims("user01", "alice@example.com")
+		claims["nonce"] = "nonce01"
+		modify(claims)
+		_, err = p.Verify(server.Sign("key01", claims), "nonce01")
+		assert.Error(t, err, "invalid token is not expected: %s", name)
+	}
+
+	// tampered, malformed and unsigned tokens
+	token := strings.Split(server.Sign("key01", claims), ".")
+	forged, _ := json.Marshal(server.Claims("admin", "admin@example.com"))
+	_, err = p.Verify(token[0]+"."+base64.RawURLEncoding.EncodeToString(forged)+"."+token[2], "")
+	assert.EqualError(t, err, "invalid token signature")
+	_, err = p.Verify(token[0]+"."+token[1], "")
+	assert.EqualError(t, err, "malformed token")
+	none, _ := json.Marshal(map[string]string{"alg": "none", "kid": "key01"})
+	_, err = p.Verify(base64.RawURLEncoding.EncodeToString(none)+"."+token[1]+".", "")
+	assert.EqualError(t, err, "unsupported token algorithm: none")
+
+	// unknown key is fetched at most once per interval
+	_, err = p.Verify(server.Sign("key02", claims), "")
+	assert.EqualError(t, err, "token is signed with unknown key: key02")
+}
+
+func TestOIDCProvider_Identify(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	p := server.Provider(t)
+
+	admin := server.Claims("admin01", "admin@example.com")
+	admin["groups"] = []interface{}{"admins"}
+	token, err := p.Identify(admin)
+	if assert.NoError(t, err) {
+		assert.Equal(t, serverConfig.MasterToken, token, "master token is expected for admin")
+	}
+
+	// account is created on the first sign in
+	token, err = p.Identify(server.Claims("oidc01", "oidc01@example.com"))
+	if assert.NoError(t, err) {
+		if user := users.Authenticate(token); assert.NotNil(t, user, "user is expected") {
+			assert.Equal(t, "oidc01_example.com", user.Name, "wrong user name")
+			assert.Equal(t, serverConfig.UserBaskets, user.MaxBaskets, "default quota is expected")
+		}
+	}
+	again, err := p.Identify(server.Claims("oidc01", "oidc01@example.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, token, again, "the same account is expected")
+
+	// other identity with the same name, not allowed identity or identity without name
+	_, err = p.Identify(server.Claims("oidc02", "oidc01@example.com"))
+	assert.Error(t, err, "account of other identity is not expected")
+	unverified := server.Claims("oidc03", "oidc03@example.com")
+	unverified["email_verified"] = false
+	_, err = p.Identify(unverified)
+	assert.EqualError(t, err, "identity is not allowed to sign in")
+	_, err = p.Identify(server.Claims("oidc04", ""))
+	assert.EqualError(t, err, "identity has no 'email' claim")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	call := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api/oidc"+path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, 404, call("/login", nil).Code, "sign in is not expected without provider")
+	oidc = server.Provider(t)
+	defer func() { oidc = nil }()
+
+	// login redirects to provider
+	w := call("/login?redirect="+url.QueryEscape("/web/oidc01"), nil)
+	assert.Equal(t, 302, w.Code, "wrong HTTP result code")
+	location, _ := url.Parse(w.Header().Get("Location"))
+	assert.Equal(t, server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path, "wrong location")
+	query := location.Query()
+	assert.Equal(t, "client01", query.Get("client_id"), "wrong client ID")
+	assert.Equal(t, "S256", query.Get("code_challenge_method"), "PKCE is expected")
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1, "login cookie is expected") {
+		return
+	}
+	assert.True(t, cookies[0].HttpOnly, "login cookie is not expected to be available to scripts")
+
+	// callback stores token in browser session and returns to web UI
+	claims := server.Claims("login01", "login01@example.com")
+	claims["nonce"] = query.Get("nonce")
+	server.claims = claims
+	w = call("/callback?code=code01&state="+query.Get("state"), cookies[0])
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		token, err := oidc.Identify(claims)
+		if assert.NoError(t, err, "user is expected to be created") {
+			session := sessionToken(w.Body.String())
+			assert.NotEqual(t, token, session, "token of a new session is expected")
+			if user := users.Authenticate(session); assert.NotNil(t, user, "token is expected in session") {
+				assert.Equal(t, users.Authenticate(token).Name, user.Name, "session of signed in user is expected")
+			}
+		}
+		assert.Contains(t, w.Body.String(), `window.location.replace("/web/oidc01")`, "redirect to web UI is expected")
+	}
+
+	// failed callbacks
+	assert.Equal(t, 400, call("/callback?code=code01&state="+query.Get("state"), nil).Code, "cookie is expected")
+	assert.Equal(t, 400, call("/callback?code=code01&state=wrong", cookies[0]).Code, "state is expected to match")
+	forged := *cookies[0]
+	forged.Value = strings.Split(forged.Value, ".")[0] + ".abc"
+	assert.Equal(t, 400, call("/callback?code=code01&state="+query.Get("state"), &forged).Code, "signed cookie is expected")
+	assert.Equal(t, 401, call("/callback?code=code02&state="+query.Get("state"), cookies[0]).Code, "wrong code is not expected")
+	assert.Equal(t, 401, call("/callback?error=access_denied&state="+query.Get("state"), cookies[0]).Code,
+		"denied access is not expected")
+
+	// only web UI pages are accepted as redirect
+	for _, redirect := range []string{"//evil.example.com/web", "https://evil.example.com/web", "/api/baskets", "/web/..\\x"} {
+		w = call("/login?redirect="+url.QueryEscape(redirect), nil)
+		login, err := oidc.ParseCookie(w.Result().Cookies()[0])
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/web", login.Redirect, "default redirect is expected instead of: %s", redirect)
+		}
+	}
+}
+
+func TestOIDCBearerToken(t *testing.T) {
+	server := newTestOIDCServer(t)
+	defer server.Close()
+	oidc = server.Provider(t)
+	defer func() { oidc = nil }()
+	call := func(path string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api"+path, nil)
+		r.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	admin := server.Claims("bearer01", "bearer01@example.com")
+	admin["groups"] = "admins"
+	assert.Equal(t, 200, call("/stats", server.Sign("key01", admin)).Code, "admin is expected to be authorized")
+
+	user := server.Sign("key01", server.Claims("bearer02", "bearer02@example.com"))
+	assert.Equal(t, 200, call("/users/bearer02_example.com", user).Code, "user is expected to be authorized")
+	assert.Equal(t, 401, call("/stats", user).Code, "user is not expected to access stats")
+
+	expired := server.Claims("bearer02", "bearer02@example.com")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	w := call("/users/bearer02_example.com", server.Sign("key01", expired))
+	assert.Equal(t, 401, w.Code, "expired token is not expected")
+	assert.Contains(t, w.Body.String(), "Invalid identity token: token is expired", "wrong error")
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Authorization required by API operations
+const (
+	authNone   = ""
+	authBasket = "basket" // basket token, token of basket owner or master token
+	authMaster = "master" // master token only
+	authPublic = "public" // master token or user token is only required if service runs in restricted mode
+	authUser   = "user"   // user token or master token
+)
+
+// apiParam describes query parameter of API operation
+type apiParam struct {
+	Name        string
+	Type        string // JSON schema type: string, integer or boolean
+	Description string
+}
+
+// apiRoute describes API operation, the same description is used to register handler and to generate OpenAPI document
+type apiRoute struct {
+	Method   string
+	Path     string // httprouter path relative to API root, e.g. /baskets/:basket
+	Handler  httprouter.Handle
+	Tag      string
+	Summary  string
+	Auth     string
+	Scope    string // scope of basket access token that is sufficient for operation, see authorizeScope
+	Query    []apiParam
+	Request  interface{} // prototype of request body, string stands for plain text
+	Status   int         // HTTP status of successful response
+	Response interface{} // prototype of response body
+	Dispatch bool        // operation is dispatched by handler of route with parameter in place of static path segment
+}
+
+var pageParams = []apiParam{
+	{"max", "integer", "Maximum number of returned items"},
+	{"skip", "integer", "Number of items to skip"}}
+
+var searchParams = []apiParam{
+	{"q", "string", "Query text to search requests"},
+	{"in", "string", "Where to search: body, query, headers or any"},
+	{"query_type", "string", "Type of query: substring, regex or jsonpath"},
+	{"filter", "string", "Filter expression, cannot be combined with 'q'"},
+	{"ignore_case", "boolean", "Case-insensitive matching of query text"},
+	{"whole_word", "boolean", "Match query text at word boundaries only"},
+	{"from", "string", "Lower bound of request date, RFC 3339 or milliseconds since epoch"},
+	{"to", "string", "Upper bound of request date, RFC 3339 or milliseconds since epoch"},
+	{"method", "string", "HTTP method of requests"},
+	{"path", "string", "Glob pattern of request path"},
+	{"header", "string", "Header filter in format Name:value, may be repeated"}}
+
+var deliveryParams = []apiParam{
+	{"status", "string", "Delivery status: pending, delivered or failed"},
+	{"payload", "boolean", "Include event payloads"}}
+
+// apiRoutes lists operations of the service API
+var apiRoutes = []apiRoute{
+	// service details
+	{Method: "GET", Path: "/stats", Handler: GetStats, Tag: "Service", Summary: "Get service statistics",
+		Auth: authMaster, Scope: PermissionStats,
+		Query: []apiParam{{"max", "integer", "Maximum number of top baskets"}}, Status: http.StatusOK, Response: DatabaseStats{}},
+	{Method: "GET", Path: "/version", Handler: GetVersion, Tag: "Service", Summary: "Get service version",
+		Status: http.StatusOK, Response: Version{}},
+	{Method: "GET", Path: "/challenge", Handler: GetChallenge, Tag: "Service",
+		Summary: "Get proof-of-work challenge required to create baskets anonymously (404 if not required)",
+		Status:  http.StatusOK, Response: Challenge{}},
+	{Method: "GET", Path: "/search", Handler: SearchRequests, Tag: "Service", Summary: "Search requests across all baskets",
+		Auth: authMaster, Scope: ScopeRead,
+		Query: append(append([]apiParam{}, searchParams...), append(pageParams,
+			apiParam{"max_requests", "integer", "Maximum number of returned requests per basket"})...),
+		Status: http.StatusOK, Response: SearchResultsPage{}},
+	{Method: "GET", Path: "/webhooks", Handler: GetWebhooks, Tag: "Webhooks",
+		Summary: "Get global webhook subscriptions to events of all baskets", Auth: authMaster,
+		Status: http.StatusOK, Response: []WebhookConfig{}},
+	{Method: "PUT", Path: "/webhooks", Handler: UpdateWebhooks, Tag: "Webhooks",
+		Summary: "Update global webhook subscriptions", Auth: authMaster, Request: []WebhookConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/webhooks/deliveries", Handler: GetWebhookDeliveries, Tag: "Webhooks",
+		Summary: "Get the latest deliveries to global webhook subscribers", Auth: authMaster, Query: deliveryParams,
+		Status: http.StatusOK, Response: []WebhookDelivery{}},
+	{Method: "POST", Path: "/webhooks/deliveries/redrive", Handler: RedriveWebhookDeliveries, Tag: "Webhooks",
+		Summary: "Redrive failed deliveries to global webhook subscribers", Auth: authMaster,
+		Request: WebhookRedrive{}, Status: http.StatusOK, Response: []WebhookDelivery{}},
+	{Method: "GET", Path: "/spec", Handler: GetBasketsSpec, Tag: "Specs",
+		Summary: "Export setup of all baskets without collected requests", Auth: authMaster,
+		Query: []apiParam{{"format", "string", "Spec format: json or yaml"}}, Status: http.StatusOK, Response: BasketsSpec{}},
+	{Method: "PUT", Path: "/spec", Handler: UpdateBasketsSpec, Tag: "Specs",
+		Summary: "Apply setup of several baskets in JSON or YAML format, missing baskets are created", Auth: authMaster,
+		Request: BasketsSpec{}, Status: http.StatusOK, Response: BasketsSpecResult{}},
+	{Method: "POST", Path: "/batch", Handler: ExecuteBatch, Tag: "Service",
+		Summary: "Execute several operations in order, the batch stops at the first failed operation and reverts created baskets and changed responses (fails with status of the operation)",
+		Request: BatchRequest{}, Status: http.StatusOK, Response: BatchResult{}},
+	{Method: "GET", Path: "/push/key", Handler: GetPushKey, Tag: "Push",
+		Summary: "Get public key of the service (applicationServerKey) to subscribe browser to push notifications",
+		Status:  http.StatusOK, Response: PushKey{}},
+	// user accounts
+	{Method: "GET", Path: "/users", Handler: GetUsers, Tag: "Users", Summary: "Get user accounts", Auth: authMaster,
+		Status: http.StatusOK, Response: []*User{}},
+	{Method: "GET", Path: "/users/:user", Handler: GetUser, Tag: "Users", Summary: "Get user account with owned baskets",
+		Auth: authUser, Status: http.StatusOK, Response: User{}},
+	{Method: "POST", Path: "/users/:user", Handler: CreateUser, Tag: "Users",
+		Summary: "Sign up new user, settings are only accepted with master token", Auth: authPublic,
+		Request: UserConfig{}, Status: http.StatusCreated, Response: UserAuth{}},
+	{Method: "PUT", Path: "/users/:user", Handler: UpdateUser, Tag: "Users", Summary: "Update user settings",
+		Auth: authMaster, Request: UserConfig{}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/users/:user", Handler: DeleteUser, Tag: "Users",
+		Summary: "Delete user account and owned baskets", Auth: authUser, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/users/:user/sessions", Handler: GetUserSessions, Tag: "Users",
+		Summary: "Get sessions of user started with sign in to web UI", Auth: authUser, Status: http.StatusOK,
+		Response: []UserSession{}},
+	{Method: "DELETE", Path: "/users/:user/sessions/:session", Handler: DeleteUserSession, Tag: "Users",
+		Summary: "Revoke session of user", Auth: authUser, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/users/:user/sign-out", Handler: SignOutUser, Tag: "Users",
+		Summary: "Sign out user everywhere: revoke all sessions, replace user token and revoke tokens of owned baskets",
+		Auth:    authUser, Status: http.StatusOK, Response: UserAuth{}},
+	{Method: "POST", Path: "/ldap/login", Handler: LDAPLogin, Tag: "Users",
+		Summary: "Sign in with credentials of LDAP directory user, the token grants access according to groups of the user",
+		Request: LDAPCredentials{}, Status: http.StatusOK, Response: LDAPSession{}},
+	// service roles
+	{Method: "GET", Path: "/roles", Handler: GetRoles, Tag: "Roles", Summary: "Get service roles with their permissions",
+		Auth: authMaster, Status: http.StatusOK, Response: []Role{}},
+	{Method: "PUT", Path: "/roles/:role", Handler: UpdateRole, Tag: "Roles",
+		Summary: "Change permissions of role: stats, list, read, write-config, clear or delete over all baskets", Auth: authMaster,
+		Request: RoleConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/roles/:role/tokens", Handler: GetRoleTokens, Tag: "Roles",
+		Summary: "Get service tokens of role without the tokens themselves", Auth: authMaster,
+		Status: http.StatusOK, Response: []RoleToken{}},
+	{Method: "POST", Path: "/roles/:role/tokens", Handler: IssueRoleToken, Tag: "Roles",
+		Summary: "Issue named service token of role", Auth: authMaster,
+		Request: RoleToken{}, Status: http.StatusCreated, Response: RoleToken{}},
+	{Method: "DELETE", Path: "/roles/:role/tokens/:token", Handler: RevokeRoleToken, Tag: "Roles",
+		Summary: "Revoke service token of role", Auth: authMaster, Status: http.StatusNoContent},
+	// API keys
+	{Method: "GET", Path: "/keys", Handler: GetAPIKeys, Tag: "API keys",
+		Summary: "Get API keys with the time of last use without the keys themselves", Auth: authMaster,
+		Status: http.StatusOK, Response: []APIKey{}},
+	{Method: "POST", Path: "/keys", Handler: CreateAPIKey, Tag: "API keys",
+		Summary: "Create named API key that grants access of the master token", Auth: authMaster,
+		Request: APIKey{}, Status: http.StatusCreated, Response: APIKey{}},
+	{Method: "DELETE", Path: "/keys/:key", Handler: RevokeAPIKey, Tag: "API keys",
+		Summary: "Revoke API key", Auth: authMaster, Status: http.StatusNoContent},
+	// purge of requests
+	{Method: "POST", Path: "/purges", Handler: PurgeRequests, Tag: "Service",
+		Summary: "Delete requests matching search criteria in all baskets and issue receipt of deletion", Auth: authMaster,
+		Scope: ScopeClear, Query: searchParams, Status: http.StatusOK, Response: PurgeReceipt{}},
+	{Method: "GET", Path: "/purges", Handler: GetPurgeReceipts, Tag: "Service",
+		Summary: "Get receipts of purged requests, the latest purges come first", Auth: authMaster, Scope: ScopeClear,
+		Query: pageParams, Status: http.StatusOK, Response: PurgeReceiptsPage{}},
+	{Method: "GET", Path: "/purges/:purge", Handler: GetPurgeReceipt, Tag: "Service",
+		Summary: "Get receipt of purged requests, requests matching search criteria of the purge are counted again if the criteria are provided",
+		Auth:    authMaster, Scope: ScopeClear, Query: searchParams, Status: http.StatusOK, Response: PurgeReceipt{}},
+	// cleanup policies
+	{Method: "GET", Path: "/cleanup/policies", Handler: GetCleanupPolicies, Tag: "Service",
+		Summary: "Get cleanup policies of the service", Auth: authMaster, Status: http.StatusOK, Response: []CleanupPolicy{}},
+	{Method: "PUT", Path: "/cleanup/policies", Handler: UpdateCleanupPolicies, Tag: "Service",
+		Summary: "Replace cleanup policies of the service", Auth: authMaster, Request: []CleanupPolicy{}, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/cleanup/policies/:policy/run", Handler: RunCleanupPolicy, Tag: "Service",
+		Summary: "Run cleanup policy immediately, dry run only reports affected baskets", Auth: authMaster,
+		Query:  []apiParam{{"dry_run", "boolean", "Report affected baskets without cleaning them up"}},
+		Status: http.StatusOK, Response: CleanupReport{}},
+	{Method: "GET", Path: "/cleanup/reports", Handler: GetCleanupReports, Tag: "Service",
+		Summary: "Get reports of the latest runs of cleanup policies, the latest runs come first", Auth: authMaster,
+		Status: http.StatusOK, Response: []*CleanupReport{}},
+	// namespace policies
+	{Method: "GET", Path: "/namespaces", Handler: GetNamespacePolicies, Tag: "Service",
+		Summary: "Get namespace policies of the service along with storage used by namespaces", Auth: authMaster,
+		Status: http.StatusOK, Response: []NamespaceStatus{}},
+	{Method: "PUT", Path: "/namespaces", Handler: UpdateNamespacePolicies, Tag: "Service",
+		Summary: "Replace namespace policies of the service", Auth: authMaster, Request: []NamespacePolicy{},
+		Status: http.StatusNoContent},
+	// alerts
+	{Method: "GET", Path: "/alerts", Handler: GetAlerts, Tag: "Service",
+		Summary: "Get alerts of baskets that are firing at the moment", Auth: authMaster,
+		Status: http.StatusOK, Response: []Alert{}},
+	{Method: "GET", Path: "/alerts/rules", Handler: GetAlertRules, Tag: "Service",
+		Summary: "Get alert rules of the service", Auth: authMaster,
+		Status: http.StatusOK, Response: []AlertRule{}},
+	{Method: "PUT", Path: "/alerts/rules", Handler: UpdateAlertRules, Tag: "Service",
+		Summary: "Replace alert rules of the service", Auth: authMaster, Request: []AlertRule{},
+		Status: http.StatusNoContent},
+	// trash of deleted baskets
+	{Method: "GET", Path: "/trash", Handler: GetTrash, Tag: "Service",
+		Summary: "Get deleted baskets that are kept in trash, the latest deleted baskets come first", Auth: authMaster,
+		Status: http.StatusOK, Response: []*TrashedBasket{}},
+	{Method: "POST", Path: "/trash/:basket/restore", Handler: RestoreBasket, Tag: "Service",
+		Summary: "Restore deleted basket from trash", Auth: authMaster,
+		Query:  []apiParam{{"deleted", "integer", "Date of deletion in milliseconds, the latest deletion by default"}},
+		Status: http.StatusOK, Response: TrashedBasket{}},
+	// audit log
+	{Method: "GET", Path: "/audit", Handler: GetAuditLog, Tag: "Service",
+		Summary: "Find configuration changes recorded in audit log, the latest changes come first", Auth: authMaster,
+		Query: append([]apiParam{{"basket", "string", "Name of changed basket"},
+			{"actor", "string", "Actor of change, e.g. master, user:<name> or apikey:<name>"},
+			{"action", "string", "Action or group of actions, e.g. basket.update or basket"},
+			{"from", "string", "Lower bound of change date, RFC 3339 or milliseconds since epoch"},
+			{"to", "string", "Upper bound of change date, RFC 3339 or milliseconds since epoch"}}, pageParams...),
+		Status: http.StatusOK, Response: AuditPage{}},
+	// runtime diagnostics
+	{Method: "GET", Path: "/debug/runtime", Handler: GetRuntimeStats, Tag: "Service",
+		Summary: "Get runtime statistics of the service: goroutines, heap and garbage collection", Auth: authMaster,
+		Status: http.StatusOK, Response: RuntimeStats{}},
+	{Method: "GET", Path: "/debug/pprof", Handler: GetProfiles, Tag: "Service",
+		Summary: "List runtime profiles of the service", Auth: authMaster, Status: http.StatusOK, Response: []ProfileInfo{}},
+	{Method: "GET", Path: "/debug/pprof/:profile", Handler: GetProfile, Tag: "Service",
+		Summary: "Download runtime profile in pprof format, e.g. heap, goroutine, profile (CPU) or trace", Auth: authMaster,
+		Query: []apiParam{{"seconds", "integer", "Duration of CPU profile, execution trace or delta profile"},
+			{"debug", "integer", "Text format of profile instead of pprof format, e.g. 2 for goroutine stacks"},
+			{"gc", "integer", "Run garbage collection before heap profile"}},
+		Status: http.StatusOK, Response: ""},
+	// basket names
+	{Method: "GET", Path: "/baskets", Handler: GetBaskets, Tag: "Baskets",
+		Summary: "Get basket names, names of owned baskets only with user token", Auth: authUser, Scope: PermissionList,
+		Query:  append([]apiParam{{"q", "string", "Part of basket name to search"}, {"cursor", "string", "Cursor of the next page"}}, pageParams...),
+		Status: http.StatusOK, Response: BasketNamesPage{}},
+	// basket management
+	{Method: "GET", Path: "/baskets/:basket", Handler: GetBasket, Tag: "Baskets", Summary: "Get basket settings", Auth: authBasket, Scope: ScopeWriteConfig,
+		Status: http.StatusOK, Response: BasketConfig{}},
+	{Method: "POST", Path: "/baskets/:basket", Handler: CreateBasket, Tag: "Baskets", Summary: "Create new basket", Auth: authPublic,
+		Request: BasketConfig{}, Status: http.StatusCreated, Response: BasketAuth{}},
+	{Method: "PUT", Path: "/baskets/:basket", Handler: UpdateBasket, Tag: "Baskets",
+		Summary: "Update basket settings, missing basket is created (201) and its token is returned", Auth: authBasket, Scope: ScopeWriteConfig,
+		Request: BasketConfig{}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/baskets/:basket", Handler: DeleteBasket, Tag: "Baskets", Summary: "Delete basket", Auth: authBasket, Scope: ScopeDelete,
+		Status: http.StatusNoContent},
+	{Method: "POST", Path: "/baskets/:basket/rename", Handler: RenameBasket, Tag: "Baskets", Summary: "Rename basket",
+		Auth: authBasket, Request: BasketRename{}, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/baskets/:basket/clone", Handler: CloneBasket, Tag: "Baskets",
+		Summary: "Create a copy of basket settings and optionally its requests under new name", Auth: authBasket,
+		Request: BasketClone{}, Status: http.StatusCreated, Response: BasketAuth{}},
+	{Method: "POST", Path: "/baskets/:basket/token", Handler: RotateBasketToken, Tag: "Baskets",
+		Summary: "Replace basket token with a new one, the previous token is no longer accepted", Auth: authBasket,
+		Status: http.StatusOK, Response: BasketAuth{}},
+	{Method: "DELETE", Path: "/baskets/:basket/token", Handler: RevokeBasketTokens, Tag: "Baskets",
+		Summary: "Revoke basket token and read-only share token, the master token or user token of basket owner issues a new one",
+		Auth:    authBasket, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/tokens", Handler: GetBasketAccessTokens, Tag: "Baskets",
+		Summary: "Get access tokens of basket without the tokens themselves", Auth: authBasket,
+		Status: http.StatusOK, Response: []AccessToken{}},
+	{Method: "POST", Path: "/baskets/:basket/tokens", Handler: CreateBasketAccessToken, Tag: "Baskets",
+		Summary: "Issue named access token with scopes: read, write-config, clear or delete", Auth: authBasket,
+		Request: AccessToken{}, Status: http.StatusCreated, Response: AccessToken{}},
+	{Method: "DELETE", Path: "/baskets/:basket/tokens/:token", Handler: RevokeBasketAccessToken, Tag: "Baskets",
+		Summary: "Revoke access token of basket", Auth: authBasket, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/acl", Handler: GetBasketACL, Tag: "Baskets",
+		Summary: "Get access control list of basket", Auth: authBasket, Status: http.StatusOK, Response: []ACLEntry{}},
+	{Method: "PUT", Path: "/baskets/:basket/acl", Handler: UpdateBasketACL, Tag: "Baskets",
+		Summary: "Replace access control list that grants users or groups view or manage access to basket",
+		Auth:    authBasket, Request: []ACLEntry{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/share", Handler: GetBasketShare, Tag: "Baskets",
+		Summary: "Get read-only share token of basket, not found (404) if basket is not shared", Auth: authBasket,
+		Status: http.StatusOK, Response: BasketAuth{}},
+	{Method: "POST", Path: "/baskets/:basket/share", Handler: ShareBasket, Tag: "Baskets",
+		Summary: "Issue a new read-only share token that permits viewing of collected requests only, the previous one is revoked",
+		Auth:    authBasket, Status: http.StatusOK, Response: BasketAuth{}},
+	{Method: "DELETE", Path: "/baskets/:basket/share", Handler: UnshareBasket, Tag: "Baskets",
+		Summary: "Revoke read-only share token of basket", Auth: authBasket, Status: http.StatusNoContent},
+	{Method: "PUT", Path: "/baskets/:basket/view-password", Handler: UpdateBasketViewPassword, Tag: "Baskets",
+		Summary: "Protect read-only web view of basket with password, so it can be shared without tokens", Auth: authBasket,
+		Request: ViewPassword{}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/baskets/:basket/view-password", Handler: DeleteBasketViewPassword, Tag: "Baskets",
+		Summary: "Remove password of read-only web view of basket", Auth: authBasket, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/baskets/:basket/view-login", Handler: ViewBasketLogin, Tag: "Baskets",
+		Summary: "Exchange password of web view for signed URLs of basket that grant read-only access for 12 hours",
+		Request: ViewPassword{}, Status: http.StatusOK, Response: SignedURL{}},
+	{Method: "POST", Path: "/baskets/:basket/signed-url", Handler: SignBasketURL, Tag: "Baskets",
+		Summary: "Issue time-limited signed URLs that grant read-only access to basket without its token", Auth: authBasket,
+		Request: SignedURLRequest{}, Status: http.StatusOK, Response: SignedURL{}},
+	{Method: "GET", Path: "/baskets/:basket/responses/:method", Handler: GetBasketResponse, Tag: "Responses",
+		Summary: "Get response settings", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: ResponseConfig{}},
+	{Method: "PUT", Path: "/baskets/:basket/responses/:method", Handler: UpdateBasketResponse, Tag: "Responses",
+		Summary: "Update response settings", Auth: authBasket, Scope: ScopeWriteConfig, Request: ResponseConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/trigger", Handler: GetBasketTrigger, Tag: "Scripts",
+		Summary: "Get trigger script", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: TriggerConfig{}},
+	{Method: "PUT", Path: "/baskets/:basket/trigger", Handler: UpdateBasketTrigger, Tag: "Scripts",
+		Summary: "Update trigger script", Auth: authBasket, Scope: ScopeWriteConfig, Request: TriggerConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/schedules", Handler: GetBasketSchedules, Tag: "Scripts",
+		Summary: "Get scheduled scripts", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: []ScheduleConfig{}},
+	{Method: "PUT", Path: "/baskets/:basket/schedules", Handler: UpdateBasketSchedules, Tag: "Scripts",
+		Summary: "Update scheduled scripts", Auth: authBasket, Scope: ScopeWriteConfig, Request: []ScheduleConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/secrets", Handler: GetBasketSecrets, Tag: "Scripts",
+		Summary: "Get masked secrets", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: map[string]string{}},
+	{Method: "PUT", Path: "/baskets/:basket/secrets/:secret", Handler: UpdateBasketSecret, Tag: "Scripts",
+		Summary: "Set secret value", Auth: authBasket, Scope: ScopeWriteConfig, Request: "", Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/baskets/:basket/secrets/:secret", Handler: DeleteBasketSecret, Tag: "Scripts",
+		Summary: "Delete secret", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/webhooks", Handler: GetBasketWebhooks, Tag: "Webhooks",
+		Summary: "Get webhook subscriptions with masked secrets", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: []WebhookConfig{}},
+	{Method: "PUT", Path: "/baskets/:basket/webhooks", Handler: UpdateBasketWebhooks, Tag: "Webhooks",
+		Summary: "Update webhook subscriptions", Auth: authBasket, Scope: ScopeWriteConfig, Request: []WebhookConfig{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/webhooks/deliveries", Handler: GetBasketWebhookDeliveries, Tag: "Webhooks",
+		Summary: "Get the latest deliveries to webhook subscribers", Auth: authBasket, Query: deliveryParams,
+		Status: http.StatusOK, Response: []WebhookDelivery{}},
+	{Method: "POST", Path: "/baskets/:basket/webhooks/deliveries/redrive", Handler: RedriveBasketWebhookDeliveries,
+		Tag: "Webhooks", Summary: "Redrive failed deliveries to webhook subscribers", Auth: authBasket,
+		Request: WebhookRedrive{}, Status: http.StatusOK, Response: []WebhookDelivery{}},
+	{Method: "GET", Path: "/baskets/:basket/scripts", Handler: GetBasketScripts, Tag: "Scripts",
+		Summary: "Get execution statistics of scripts", Auth: authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: []*ScriptStats{}},
+	{Method: "GET", Path: "/baskets/:basket/rate-limit", Handler: GetBasketRateLimit, Tag: "Baskets",
+		Summary: "Get statistics of requests that were not collected due to rate limit or ignore filters",
+		Auth:    authBasket, Scope: ScopeWriteConfig, Status: http.StatusOK, Response: CaptureStats{}},
+	{Method: "GET", Path: "/baskets/:basket/spec", Handler: GetBasketSpec, Tag: "Specs",
+		Summary: "Export basket setup without collected requests", Auth: authBasket, Scope: ScopeWriteConfig,
+		Query: []apiParam{{"format", "string", "Spec format: json or yaml"}}, Status: http.StatusOK, Response: BasketSpec{}},
+	{Method: "PUT", Path: "/baskets/:basket/spec", Handler: UpdateBasketSpec, Tag: "Specs",
+		Summary: "Apply basket setup in JSON or YAML format, missing basket is created (201) and its token is returned", Auth: authBasket, Scope: ScopeWriteConfig,
+		Request: BasketSpec{}, Status: http.StatusNoContent},
+	// requests management
+	{Method: "POST", Path: "/baskets/:basket/push", Handler: SubscribeBasketPush, Tag: "Push",
+		Summary: "Subscribe browser to push notifications about requests collected by basket after it was idle for a while",
+		Auth:    authBasket, Request: PushSubscription{}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/baskets/:basket/push", Handler: UnsubscribeBasketPush, Tag: "Push",
+		Summary: "Cancel push subscription of browser", Auth: authBasket,
+		Query:  []apiParam{{"endpoint", "string", "Push endpoint of browser subscription"}},
+		Status: http.StatusNoContent},
+	{Method: "GET", Path: "/baskets/:basket/requests", Handler: GetBasketRequests, Tag: "Requests",
+		Summary: "Get or search collected requests, supports conditional requests with If-None-Match (304)", Auth: authBasket, Scope: ScopeRead,
+		Query: append(append([]apiParam{}, searchParams...), append(pageParams,
+			apiParam{"cursor", "string", "Cursor of the next page"},
+			apiParam{"sort", "string", "Sort order: newest, oldest, content_length or forward_latency"},
+			apiParam{"highlight", "boolean", "Report locations of matched query text"})...),
+		Status: http.StatusOK, Response: RequestsPage{}},
+	{Method: "GET", Path: "/baskets/:basket/requests/next", Handler: WaitBasketRequest, Tag: "Requests", Auth: authBasket, Scope: ScopeRead, Dispatch: true,
+		Summary: "Wait for the next collected request that matches optional search criteria, no content (204) upon timeout",
+		Query: append([]apiParam{{"timeout", "string", "Maximum time to wait, e.g. 30s (default) or number of seconds, at most 5m"},
+			{"after", "integer", "ID of the last seen request, matching requests collected after it are returned immediately"}},
+			searchParams...),
+		Status: http.StatusOK, Response: RequestData{}},
+	{Method: "GET", Path: "/baskets/:basket/requests/:id", Handler: GetBasketRequest, Tag: "Requests",
+		Summary: "Get collected request with all recorded details", Auth: authBasket, Scope: ScopeRead, Status: http.StatusOK, Response: RequestData{}},
+	{Method: "GET", Path: "/baskets/:basket/tail", Handler: TailBasketRequests, Tag: "Requests", Auth: authBasket, Scope: ScopeRead,
+		Summary: "Stream recently collected requests and requests collected afterwards as text lines until client disconnects",
+		Query: append([]apiParam{{"last", "integer", "Number of recently collected requests to print first, 10 by default"},
+			{"headers", "boolean", "Print request headers"},
+			{"body", "boolean", "Print request body"}},
+			searchParams...),
+		Status: http.StatusOK, Response: ""},
+	{Method: "POST", Path: "/baskets/:basket/assert", Handler: AssertBasketRequests, Tag: "Requests",
+		Summary: "Assert number of collected requests that match search criteria, waits for expected requests until timeout",
+		Auth:    authBasket, Scope: ScopeRead, Request: BasketAssertion{}, Status: http.StatusOK, Response: AssertionResult{}},
+	{Method: "DELETE", Path: "/baskets/:basket/requests", Handler: ClearBasket, Tag: "Requests",
+		Summary: "Delete selected requests, all requests are deleted if none are selected (204)", Auth: authBasket, Scope: ScopeClear,
+		Query:  append([]apiParam{{"id", "string", "Comma separated IDs of requests to delete, may be repeated"}}, searchParams...),
+		Status: http.StatusOK, Response: RequestsDeletion{}},
+	{Method: "GET", Path: "/baskets/:basket/export", Handler: ExportBasketRequests, Tag: "Requests",
+		Summary: "Export collected requests", Auth: authBasket, Scope: ScopeRead,
+		Query: append([]apiParam{{"format", "string", "Export format: har, postman, curl or ndjson (streamed)"},
+			{"target", "string", "Scheme and host of exported requests instead of the service URL"}}, searchParams...),
+		Status: http.StatusOK, Response: ""},
+	{Method: "POST", Path: "/baskets/:basket/import", Handler: ImportBasketRequests, Tag: "Requests",
+		Summary: "Import requests from HAR archive", Auth: authBasket,
+		Query:   []apiParam{{"format", "string", "Import format: har"}},
+		Request: harArchive{}, Status: http.StatusOK, Response: RequestsImport{}},
+	{Method: "GET", Path: "/baskets/:basket/snapshot", Handler: GetBasketSnapshot, Tag: "Specs",
+		Summary: "Download gzip compressed snapshot of basket setup and collected requests", Auth: authBasket,
+		Scope: ScopeWriteConfig, Status: http.StatusOK, Response: ""},
+	{Method: "PUT", Path: "/baskets/:basket/snapshot", Handler: RestoreBasketSnapshot, Tag: "Specs",
+		Summary: "Restore basket setup and collected requests from snapshot, missing basket is created (201) and its token is returned",
+		Auth:    authBasket, Scope: ScopeWriteConfig, Request: "", Status: http.StatusOK, Response: BasketSnapshotRestore{}},
+	{Method: "GET", Path: "/baskets/:basket/aggregate", Handler: GetBasketAggregation, Tag: "Requests",
+		Summary: "Count collected requests by groups", Auth: authBasket, Scope: ScopeRead,
+		Query:  append([]apiParam{{"by", "string", "Grouping: path, method, status or hour"}}, searchParams...),
+		Status: http.StatusOK, Response: RequestsAggregation{}},
+	{Method: "GET", Path: "/baskets/:basket/stats", Handler: GetBasketStats, Tag: "Requests",
+		Summary: "Get statistics of collected requests: request rate, methods, statuses, body size and forward errors",
+		Auth:    authBasket, Scope: ScopeRead,
+		Query:  append([]apiParam{{"interval", "string", "Interval of request rate: minute, hour or day"}}, searchParams...),
+		Status: http.StatusOK, Response: BasketRequestsStats{}},
+	{Method: "GET", Path: "/baskets/:basket/timeline", Handler: GetBasketTimeline, Tag: "Requests",
+		Summary: "Count collected requests per minute of the last hour or per hour of the last day or week",
+		Auth:    authBasket, Scope: ScopeRead,
+		Query:  []apiParam{{"range", "string", "Range of timeline: hour, day (default) or week"}},
+		Status: http.StatusOK, Response: RequestsTimeline{}},
+	{Method: "GET", Path: "/baskets/:basket/verify", Handler: VerifyBasketRequests, Tag: "Requests",
+		Summary: "Verify hash chain of collected requests, the result is reported even if the chain is broken",
+		Auth:    authBasket, Scope: ScopeRead, Status: http.StatusOK, Response: ChainVerification{}},
+}
+
+type cachedSpec struct {
+	sync.Once
+	json []byte
+	err  error
+}
+
+// openAPISpecs keeps generated OpenAPI specification of every API version
+var openAPISpecs = map[int]*cachedSpec{apiV1: new(cachedSpec), apiV2: new(cachedSpec)}
+
+// GetOpenAPISpec handles HTTP request to get OpenAPI specification of the service API
+func GetOpenAPISpec(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	apiVersion := getAPIVersion(r)
+	root := serverConfig.PathPrefix + "/" + serviceAPIPath
+	if apiVersion == apiV2 {
+		root += "/" + serviceAPIV2Path
+	}
+
+	spec := openAPISpecs[apiVersion]
+	spec.Do(func() {
+		spec.json, spec.err = json.Marshal(buildOpenAPISpec(root, apiVersion))
+	})
+	writeJSON(w, http.StatusOK, spec.json, spec.err)
+}
+
+// buildOpenAPISpec generates OpenAPI 3 document of API routes for given API version, schemas are derived from
+// Go types of request and response bodies
+func buildOpenAPISpec(root string, apiVersion int) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	for _, route := range apiRoutes {
+		route.Response = toAPIVersion(apiVersion, route.Response)
+		path, params := openAPIPath(route.Path)
+		item, exists := paths[root+path].(map[string]interface{})
+		if !exists {
+			item = make(map[string]interface{})
+			paths[root+path] = item
+		}
+
+		for _, p := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name": p.Name, "in": "query", "description": p.Description, "schema": map[string]interface{}{"type": p.Type}})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": operationID(route.Handler),
+			"tags":        []string{route.Tag},
+			"summary":     route.Summary,
+			"parameters":  params,
+			"responses":   openAPIResponses(route, schemas),
+		}
+		if apiVersion < apiV2 {
+			operation["deprecated"] = true
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{"required": true, "content": openAPIContent(route.Request, schemas)}
+		}
+		var security []map[string][]string
+		switch route.Auth {
+		case authBasket:
+			security = []map[string][]string{{"basket_token": {}}, {"user_token": {}}, {"service_token": {}}, {"bearer_jwt": {}}}
+			if route.Scope == ScopeRead {
+				security = append(security, map[string][]string{"share_token": {}}, map[string][]string{"signed_url": {}})
+			}
+			if len(route.Scope) > 0 {
+				security = append(security, map[string][]string{"access_token": {}})
+			}
+		case authMaster:
+			security = []map[string][]string{{"service_token": {}}}
+		case authPublic:
+			security = []map[string][]string{{}, {"user_token": {}}, {"service_token": {}}}
+		case authUser:
+			security = []map[string][]string{{"user_token": {}}, {"service_token": {}}}
+		}
+		if len(route.Scope) > 0 {
+			// scopes of API key schemes may not be listed, so the scope of access token or permission
+			// of role token is an extension
+			security = append(security, map[string][]string{"role_token": {}})
+			operation["x-token-scope"] = route.Scope
+		}
+		if security != nil {
+			operation["security"] = security
+		}
+
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Request Baskets API",
+			"version": version.Version},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"basket_token":  map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"share_token":   map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"signed_url":    map[string]interface{}{"type": "apiKey", "in": "query", "name": SignatureParam},
+				"access_token":  map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"role_token":    map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"user_token":    map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"service_token": map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+				"bearer_jwt":    map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}}}}
+}
+
+// operationID returns name of handler function as ID of operation
+func operationID(handler httprouter.Handle) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// openAPIPath converts httprouter path into OpenAPI path template and declares path parameters
+func openAPIPath(path string) (string, []map[string]interface{}) {
+	params := make([]map[string]interface{}, 0)
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			name := part[1:]
+			parts[i] = "{" + name + "}"
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func openAPIResponses(route apiRoute, schemas map[string]interface{}) map[string]interface{} {
+	success := map[string]interface{}{"description": http.StatusText(route.Status)}
+	if route.Response != nil {
+		success["content"] = openAPIContent(route.Response, schemas)
+	}
+
+	// errors are described by JSON error envelope
+	failure := func(status int) map[string]interface{} {
+		return map[string]interface{}{"description": http.StatusText(status), "content": openAPIContent(ErrorResponse{}, schemas)}
+	}
+
+	responses := map[string]interface{}{strconv.Itoa(route.Status): success}
+	if route.Auth != authNone {
+		responses["401"] = failure(http.StatusUnauthorized)
+	}
+	if strings.Contains(route.Path, ":basket") {
+		responses["404"] = failure(http.StatusNotFound)
+	}
+	// rate limit is applied to all operations if configured
+	responses["429"] = failure(http.StatusTooManyRequests)
+	responses["default"] = map[string]interface{}{"description": "Error", "content": openAPIContent(ErrorResponse{}, schemas)}
+	return responses
+}
+
+func openAPIContent(prototype interface{}, schemas map[string]interface{}) map[string]interface{} {
+	if _, isText := prototype.(string); isText {
+		return map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	}
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(prototype), schemas)}}
+}
+
+// jsonSchema derives JSON schema of a Go type following encoding/json rules, named structs are registered
+// as reusable schemas and referenced
+func jsonSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		schema := map[string]interface{}{"type": "object"}
+		if len(t.Name()) == 0 {
+			// anonymous struct is described inline
+			return structSchema(t, schema, schemas)
+		}
+
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, exists := schemas[t.Name()]; !exists {
+			// register schema before its properties to support recursive types
+			schemas[t.Name()] = schema
+			structSchema(t, schema, schemas)
+		}
+		return ref
+	default:
+		// interface{} - any value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes properties of struct type in the given schema
+func structSchema(t reflect.Type, schema map[string]interface{}, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchema(field.Type, schemas)
+		if len(tag) < 2 || tag[1] != "omitempty" {
+			required = append(required, name)
+		}
+	}
+	schema["properties"] = properties
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
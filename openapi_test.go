@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openAPIPath("/baskets/:basket/responses/:method")
+	assert.Equal(t, "/baskets/{basket}/responses/{method}", path, "wrong path template")
+	if assert.Len(t, params, 2, "wrong number of path parameters") {
+		assert.Equal(t, "basket", params[0]["name"])
+		assert.Equal(t, "method", params[1]["name"])
+		assert.Equal(t, "path", params[1]["in"])
+	}
+
+	path, params = openAPIPath("/version")
+	assert.Equal(t, "/version", path, "wrong path template")
+	assert.Empty(t, params, "no path parameters are expected")
+}
+
+func TestJSONSchema(t *testing.T) {
+	schemas := make(map[string]interface{})
+	ref := jsonSchema(reflect.TypeOf(RequestsPage{}), schemas)
+	assert.Equal(t, "#/components/schemas/RequestsPage", ref["$ref"], "wrong schema reference")
+
+	// nested types are registered as well
+	if assert.Contains(t, schemas, "RequestData") {
+		schema := schemas["RequestData"].(map[string]interface{})
+		properties := schema["properties"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"type": "integer", "format": "int64"}, properties["date"])
+		assert.Equal(t, map[string]interface{}{"type": "object",
+			"additionalProperties": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}},
+			properties["headers"])
+		// omitted empty fields are optional
+		assert.NotContains(t, schema["required"], "id")
+		assert.Contains(t, schema["required"], "method")
+	}
+
+	page := schemas["RequestsPage"].(map[string]interface{})
+	properties := page["properties"].(map[string]interface{})
+	assert.Equal(t, "array", properties["requests"].(map[string]interface{})["type"])
+}
+
+func TestBuildOpenAPISpec(t *testing.T) {
+	spec := buildOpenAPISpec("/prefix/api", apiV1)
+	paths := spec["paths"].(map[string]interface{})
+
+	// every registered route is described
+	for _, route := range apiRoutes {
+		path, _ := openAPIPath(route.Path)
+		if item, exists := paths["/prefix/api"+path].(map[string]interface{}); assert.True(t, exists, "missing path: %s", path) {
+			assert.Contains(t, item, map[string]string{"GET": "get", "POST": "post", "PUT": "put", "DELETE": "delete"}[route.Method])
+		}
+	}
+
+	item := paths["/prefix/api/baskets/{basket}"].(map[string]interface{})
+	create := item["post"].(map[string]interface{})
+	assert.Equal(t, "CreateBasket", create["operationId"], "wrong operation ID")
+	responses := create["responses"].(map[string]interface{})
+	assert.Contains(t, responses, "201", "created status is expected")
+	assert.Contains(t, responses, "404", "not found status is expected for basket operations")
+
+	secret := paths["/prefix/api/baskets/{basket}/secrets/{secret}"].(map[string]interface{})["put"].(map[string]interface{})
+	body := secret["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.Contains(t, body, "text/plain", "secret value is expected as plain text")
+
+	version := paths["/prefix/api/version"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.NotContains(t, version, "security", "version is not protected")
+	assert.Contains(t, version["responses"], "200")
+	assert.Equal(t, true, version["deprecated"], "API v1 is deprecated")
+}
+
+func TestBuildOpenAPISpec_V2(t *testing.T) {
+	spec := buildOpenAPISpec("/api/v2", apiV2)
+	paths := spec["paths"].(map[string]interface{})
+
+	request := paths["/api/v2/baskets/{basket}/requests/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.NotContains(t, request, "deprecated", "API v2 is not deprecated")
+	content := request["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.Equal(t, "#/components/schemas/RequestDataV2",
+		content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})["$ref"], "wrong response schema")
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "ForwardResult", "nested schemas are expected")
+	assert.NotContains(t, schemas, "RequestData", "API v1 schemas are not expected")
+}
+
+func TestJSONSchema_AnonymousStruct(t *testing.T) {
+	schemas := make(map[string]interface{})
+	schema := jsonSchema(reflect.TypeOf(struct {
+		Name string `json:"name"`
+	}{}), schemas)
+	assert.Equal(t, "object", schema["type"], "anonymous struct is expected inline")
+	assert.Contains(t, schema["properties"], "name")
+	assert.Empty(t, schemas, "anonymous struct is not expected in components")
+}
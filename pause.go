@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultPauseStatus is HTTP status of requests rejected by paused basket unless basket defines another status
+const defaultPauseStatus = http.StatusServiceUnavailable
+
+// BasketPause describes pause of basket: new requests are rejected, while collected requests stay available.
+type BasketPause struct {
+	Status int   `json:"status,omitempty"` // HTTP status of rejected requests, 503 by default
+	Until  int64 `json:"until,omitempty"`  // date in milliseconds when basket resumes collecting, 0 - until resumed
+}
+
+// Active checks if basket is paused at given time
+func (p *BasketPause) Active(now time.Time) bool {
+	return p != nil && (p.Until == 0 || now.UnixNano()/toMs < p.Until)
+}
+
+// validatePause validates pause of basket, requests may only be rejected with client or server error status
+func validatePause(pause *BasketPause) error {
+	if pause.Status != 0 && (pause.Status < 400 || pause.Status > 599) {
+		return fmt.Errorf("status of paused basket should be in range 400-599, but was %d", pause.Status)
+	}
+	if pause.Until < 0 {
+		return fmt.Errorf("end of pause should not be negative, but was %d", pause.Until)
+	}
+	return nil
+}
+
+// checkPause checks if basket collects requests; writes HTTP response and returns false if basket is paused,
+// clients are told when to retry if the pause ends at known time
+func checkPause(w http.ResponseWriter, pause *BasketPause) bool {
+	now := time.Now()
+	if !pause.Active(now) {
+		return true
+	}
+
+	status := pause.Status
+	if status == 0 {
+		status = defaultPauseStatus
+	}
+	if pause.Until > 0 {
+		seconds := (pause.Until - now.UnixNano()/toMs + 999) / 1000
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+	http.Error(w, "basket is paused and does not collect requests", status)
+	return false
+}
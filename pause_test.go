@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePause(t *testing.T) {
+	assert.NoError(t, validatePause(&BasketPause{}))
+	assert.NoError(t, validatePause(&BasketPause{Status: 410, Until: 1700000000000}))
+	assert.Error(t, validatePause(&BasketPause{Status: 200}), "success status is not expected")
+	assert.Error(t, validatePause(&BasketPause{Status: 600}), "unknown status is not expected")
+	assert.Error(t, validatePause(&BasketPause{Until: -1}), "negative end of pause is not expected")
+}
+
+func TestBasketPause_Active(t *testing.T) {
+	now := time.Now()
+	var none *BasketPause
+	assert.False(t, none.Active(now), "basket without pause is not expected to be paused")
+	assert.True(t, (&BasketPause{}).Active(now), "pause without end is expected to be active")
+	assert.True(t, (&BasketPause{Until: now.Add(time.Minute).UnixNano() / toMs}).Active(now), "pause is expected to be active")
+	assert.False(t, (&BasketPause{Until: now.Add(-time.Minute).UnixNano() / toMs}).Active(now), "pause is expected to be over")
+}
+
+func TestAcceptBasketRequest_Paused(t *testing.T) {
+	basket := "pause01"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+	send := func() *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("data"))
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, 200, send().Code, "wrong HTTP result code")
+
+	config := basketsDb.Get(basket).Config()
+	config.Pause = &BasketPause{}
+	basketsDb.Get(basket).Update(config)
+	assert.Equal(t, http.StatusServiceUnavailable, send().Code, "paused basket is expected to reject requests")
+
+	config.Pause = &BasketPause{Status: http.StatusGone, Until: time.Now().Add(time.Minute).UnixNano() / toMs}
+	basketsDb.Get(basket).Update(config)
+	w := send()
+	assert.Equal(t, http.StatusGone, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"), "wrong retry period")
+
+	// collected requests stay available
+	r, _ := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/requests", nil)
+	r.Header.Add("Authorization", serverConfig.MasterToken)
+	w = httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"count":1`, "collected request is expected")
+	}
+
+	config.Pause = nil
+	basketsDb.Get(basket).Update(config)
+	assert.Equal(t, 200, send().Code, "resumed basket is expected to collect requests")
+	assert.Equal(t, 2, basketsDb.Get(basket).Size(), "wrong basket size")
+}
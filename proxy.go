@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var proxyAuth *proxyAuthenticator
+
+// proxyAuthenticator signs in users identified by headers of trusted authentication proxy, e.g. oauth2-proxy
+// or Authelia; users are mapped to user accounts or to the master token if they are members of admin groups,
+// identity headers of requests that do not come from trusted proxies are ignored
+type proxyAuthenticator struct {
+	trusted      []*net.IPNet
+	userHeader   string
+	groupsHeader string
+	adminGroups  []string
+}
+
+// newProxyAuthenticator creates authenticator of proxy headers from server configuration
+func newProxyAuthenticator(config *ServerConfig) (*proxyAuthenticator, error) {
+	if len(config.ProxyUserHeader) == 0 {
+		return nil, fmt.Errorf("user header of authentication proxy is required")
+	}
+
+	trusted, err := parseNetworks(config.ProxyTrusted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %s", err)
+	}
+
+	return &proxyAuthenticator{
+		trusted:      trusted,
+		userHeader:   config.ProxyUserHeader,
+		groupsHeader: config.ProxyGroupsHeader,
+		adminGroups:  config.ProxyAdminGroups}, nil
+}
+
+// IsTrusted checks if request comes directly from trusted proxy
+func (a *proxyAuthenticator) IsTrusted(r *http.Request) bool {
+	return containsIP(a.trusted, remoteIP(r))
+}
+
+// Identify maps user identified by proxy headers to the master token for members of admin groups or to the token
+// of user account, the account is created on the first request and its groups follow the groups header; empty
+// token is returned if request has no identity, HTTP status is returned for failed sign in
+func (a *proxyAuthenticator) Identify(r *http.Request) (string, int, error) {
+	value := strings.TrimSpace(r.Header.Get(a.userHeader))
+	if len(value) == 0 {
+		return "", http.StatusOK, nil
+	}
+
+	var groups []string
+	if len(a.groupsHeader) > 0 {
+		for _, group := range strings.Split(r.Header.Get(a.groupsHeader), ",") {
+			if group = strings.TrimSpace(group); len(group) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+	for _, group := range groups {
+		for _, admin := range a.adminGroups {
+			if group == admin {
+				return serverConfig.MasterToken, http.StatusOK, nil
+			}
+		}
+	}
+
+	name := invalidIdentityName.ReplaceAllString(value, "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	auth, err := users.Provision(name, "proxy:"+value, defaultUserConfig())
+	if err != nil {
+		return "", http.StatusConflict, err
+	}
+
+	// group names follow the rules of user names, see basket ACL
+	valid := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group = invalidIdentityName.ReplaceAllString(group, "_"); validUserName.MatchString(group) {
+			valid = append(valid, group)
+		}
+	}
+	users.SetGroups(name, valid)
+
+	return auth.Token, http.StatusOK, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testProxyAuthenticator creates authenticator that trusts proxies of 10.1.0.0/16 network and 192.168.1.10
+func testProxyAuthenticator(t *testing.T) *proxyAuthenticator {
+	a, err := newProxyAuthenticator(&ServerConfig{ProxyTrusted: []string{"10.1.0.0/16", "192.168.1.10"},
+		ProxyUserHeader: defaultProxyUser, ProxyGroupsHeader: defaultProxyGroups, ProxyAdminGroups: []string{"admins"}})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return a
+}
+
+func TestNewProxyAuthenticator(t *testing.T) {
+	a := testProxyAuthenticator(t)
+	assert.Len(t, a.trusted, 2, "wrong number of trusted networks")
+
+	_, err := newProxyAuthenticator(&ServerConfig{ProxyTrusted: []string{"10.1.0.0/99"}, ProxyUserHeader: defaultProxyUser})
+	assert.EqualError(t, err, "invalid trusted proxy: invalid CIDR: 10.1.0.0/99")
+	_, err = newProxyAuthenticator(&ServerConfig{ProxyTrusted: []string{"proxy.local"}, ProxyUserHeader: defaultProxyUser})
+	assert.Error(t, err, "host name is not expected")
+	_, err = newProxyAuthenticator(&ServerConfig{ProxyTrusted: []string{"10.1.0.0/16"}})
+	assert.Error(t, err, "user header is expected")
+}
+
+func TestProxyAuthenticator_IsTrusted(t *testing.T) {
+	a := testProxyAuthenticator(t)
+	r, _ := http.NewRequest("GET", "http://localhost:55555/api/stats", nil)
+
+	for addr, trusted := range map[string]bool{
+		"10.1.2.3:43210":     true,
+		"192.168.1.10:43210": true,
+		"192.168.1.11:43210": false,
+		"10.2.0.1:43210":     false,
+		"[::1]:43210":        false,
+		"":                   false} {
+		r.RemoteAddr = addr
+		assert.Equal(t, trusted, a.IsTrusted(r), "wrong trust of address: %s", addr)
+	}
+}
+
+func TestProxyAuthenticator_Identify(t *testing.T) {
+	a := testProxyAuthenticator(t)
+	identify := func(user string, groups string) (string, int, error) {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api/stats", nil)
+		r.Header.Set(defaultProxyUser, user)
+		r.Header.Set(defaultProxyGroups, groups)
+		return a.Identify(r)
+	}
+
+	token, _, err := identify("admin01", "dev, admins")
+	if assert.NoError(t, err) {
+		assert.Equal(t, serverConfig.MasterToken, token, "master token is expected for admin")
+	}
+
+	// account is created on the first request, groups follow the header
+	token, _, err = identify("proxy01@example.com", "qa, dev team")
+	if assert.NoError(t, err) {
+		if user := users.Authenticate(token); assert.NotNil(t, user, "user is expected") {
+			assert.Equal(t, "proxy01_example.com", user.Name, "wrong user name")
+			assert.Equal(t, []string{"qa", "dev_team"}, user.Groups, "wrong groups")
+			assert.Equal(t, serverConfig.UserBaskets, user.MaxBaskets, "default quota is expected")
+		}
+	}
+	again, _, err := identify("proxy01@example.com", "qa")
+	if assert.NoError(t, err) {
+		assert.Equal(t, token, again, "the same account is expected")
+		assert.Equal(t, []string{"qa"}, users.Authenticate(again).Groups, "groups are expected to be updated")
+	}
+
+	// request without identity
+	token, _, err = identify("", "admins")
+	assert.NoError(t, err)
+	assert.Empty(t, token, "token is not expected without identity")
+
+	// other user with the same name
+	if _, err = users.Create("proxy02", defaultUserConfig()); assert.NoError(t, err) {
+		_, status, err := identify("proxy02", "")
+		assert.Error(t, err, "account of other user is not expected")
+		assert.Equal(t, 409, status, "wrong HTTP result code")
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	call := func(method string, path string, addr string, user string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+path, nil)
+		r.RemoteAddr = addr
+		if len(user) > 0 {
+			r.Header.Set(defaultProxyUser, user)
+			r.Header.Set(defaultProxyGroups, "proxy, "+user+"s")
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, 404, call("GET", "/proxy/login", "10.1.2.3:43210", "header01").Code,
+		"sign in is not expected without proxy")
+	proxyAuth = testProxyAuthenticator(t)
+	defer func() { proxyAuth = nil }()
+
+	// identity headers of trusted proxy authenticate requests
+	assert.Equal(t, 200, call("GET", "/users/header01", "10.1.2.3:43210", "header01").Code,
+		"user is expected to be authorized")
+	assert.Equal(t, 200, call("GET", "/stats", "10.1.2.3:43210", "admin").Code, "admin is expected to be authorized")
+	if user := users.Get("header01"); assert.NotNil(t, user, "user is expected") {
+		assert.Equal(t, []string{"proxy", "header01s"}, user.Groups, "groups of proxy are expected")
+	}
+
+	// identity headers of other clients are ignored
+	assert.Equal(t, 401, call("GET", "/users/header01", "10.2.0.1:43210", "header01").Code,
+		"user is not expected to be authorized")
+	assert.Equal(t, 401, call("GET", "/users/header01", "10.1.2.3:43210", "").Code,
+		"request without identity is not expected to be authorized")
+
+	// login page stores token in browser session and returns to web UI
+	w := call("GET", "/proxy/login?redirect="+url.QueryEscape("/web/header01"), "10.1.2.3:43210", "header01")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		r, _ := http.NewRequest("GET", "http://localhost:55555/api/proxy/login", nil)
+		r.Header.Set(defaultProxyUser, "header01")
+		token, _, err := proxyAuth.Identify(r)
+		if assert.NoError(t, err) {
+			session := sessionToken(w.Body.String())
+			assert.NotEqual(t, token, session, "token of a new session is expected")
+			if user := users.Authenticate(session); assert.NotNil(t, user, "token is expected in session") {
+				assert.Equal(t, "header01", user.Name, "session of signed in user is expected")
+			}
+		}
+		assert.Contains(t, w.Body.String(), `window.location.replace("/web/header01")`, "redirect to web UI is expected")
+	}
+	assert.Equal(t, 403, call("GET", "/proxy/login", "10.2.0.1:43210", "header01").Code, "untrusted proxy is not expected")
+	assert.Equal(t, 401, call("GET", "/proxy/login", "10.1.2.3:43210", "").Code, "identity is expected")
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPushIdle      = 5 * time.Minute
+	maxPushIdle          = 7 * 24 * time.Hour
+	maxPushSubscriptions = 10 // per basket
+	pushTTL              = 24 * time.Hour
+	pushTimeout          = 10 * time.Second
+	pushQueueSize        = 1000
+	pushWorkers          = 2
+	pushRecordSize       = 4096
+	vapidExpiration      = 12 * time.Hour
+)
+
+var pushes *pushNotifier
+
+// pushWorkerScript is a service worker that displays push notifications in browser and opens basket page once
+// notification is clicked; the worker is served under API root, so the path prefix of service is derived from its scope
+const pushWorkerScript = `self.addEventListener("push", function(event) {
+  var data = event.data ? event.data.json() : {};
+  event.waitUntil(self.registration.showNotification(data.title || "Request Baskets", {
+    body: data.body,
+    tag: data.basket,
+    data: data
+  }));
+});
+
+self.addEventListener("notificationclick", function(event) {
+  event.notification.close();
+  var scope = self.registration.scope;
+  var url = scope.substring(0, scope.lastIndexOf("/api/")) + (event.notification.data.path || "/web");
+  event.waitUntil(clients.matchAll({ type: "window" }).then(function(windows) {
+    for (var i = 0; i < windows.length; i++) {
+      if (windows[i].url === url && "focus" in windows[i]) {
+        return windows[i].focus();
+      }
+    }
+    return clients.openWindow(url);
+  }));
+});
+`
+
+// PushKey describes public key of the service that browsers use to subscribe to push notifications.
+type PushKey struct {
+	PublicKey string `json:"public_key"` // URL-safe base64 encoded P-256 public key (applicationServerKey)
+}
+
+// PushSubscription describes browser subscription to notifications about requests collected by basket, the format
+// is the same as of PushSubscription.toJSON() in browser with optional idle period.
+type PushSubscription struct {
+	Endpoint string               `json:"endpoint"`
+	Keys     PushSubscriptionKeys `json:"keys"`
+	Idle     *int                 `json:"idle,omitempty"` // seconds without requests before a request is notified, 300 by default
+}
+
+// PushSubscriptionKeys describes keys of browser subscription that are used to encrypt notifications.
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// PushNotification describes payload of push notification that is rendered by service worker.
+type PushNotification struct {
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Basket    string `json:"basket"`
+	RequestID int    `json:"request_id"`
+	Path      string `json:"path"` // path of basket web page relative to service root, e.g. /web/basket
+}
+
+// pushSubscription is a validated browser subscription
+type pushSubscription struct {
+	endpoint string
+	public   []byte // uncompressed P-256 public key of browser
+	auth     []byte
+	idle     time.Duration
+}
+
+type pushDelivery struct {
+	basket       string
+	subscription *pushSubscription
+	payload      []byte
+}
+
+// pushNotifier notifies browsers subscribed to baskets with Web Push protocol when a basket that was idle for a while
+// collects a request; subscriptions are kept in memory only, browsers renew them once basket page is open
+type pushNotifier struct {
+	sync.Mutex
+	key           *ecdsa.PrivateKey
+	subject       string // contact of the service operator, mailto: or https: URL
+	subscriptions map[string][]*pushSubscription
+	lastActivity  map[string]time.Time
+	queue         chan *pushDelivery
+	client        *http.Client
+}
+
+// newPushNotifier creates notifier that signs notifications with given URL-safe base64 encoded P-256 private key,
+// a new key is generated if none is given
+func newPushNotifier(encodedKey string, subject string) (*pushNotifier, error) {
+	var key *ecdsa.PrivateKey
+	if len(encodedKey) == 0 {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+		log.Printf("[info] generated Web Push key: %s", encodePushKey(key.D.FillBytes(make([]byte, 32))))
+	} else {
+		d, err := base64.RawURLEncoding.DecodeString(encodedKey)
+		if err != nil || len(d) != 32 {
+			return nil, fmt.Errorf("invalid Web Push key, URL-safe base64 encoded 32 bytes are expected")
+		}
+		key = &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+		key.Curve = elliptic.P256()
+		key.X, key.Y = key.Curve.ScalarBaseMult(d)
+	}
+
+	return &pushNotifier{
+		key:           key,
+		subject:       subject,
+		subscriptions: make(map[string][]*pushSubscription),
+		lastActivity:  make(map[string]time.Time),
+		queue:         make(chan *pushDelivery, pushQueueSize),
+		client:        newOutboundClient(pushTimeout)}, nil
+}
+
+// encodePushKey encodes key in URL-safe base64 without padding as expected by browsers
+func encodePushKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// decodePushKey decodes key in URL-safe base64, padding is optional
+func decodePushKey(key string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+}
+
+// Start launches background routines that deliver queued notifications
+func (n *pushNotifier) Start() {
+	for i := 0; i < pushWorkers; i++ {
+		go func() {
+			for delivery := range n.queue {
+				n.deliver(delivery)
+			}
+		}()
+	}
+}
+
+// PublicKey returns public key of the service that browsers use to subscribe
+func (n *pushNotifier) PublicKey() PushKey {
+	return PushKey{encodePushKey(elliptic.Marshal(n.key.Curve, n.key.X, n.key.Y))}
+}
+
+// Subscribe validates browser subscription and registers it for the basket, subscription with the same
+// endpoint is replaced
+func (n *pushNotifier) Subscribe(name string, subscription PushSubscription) error {
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || len(endpoint.Host) == 0 {
+		return fmt.Errorf("invalid push endpoint: %s", subscription.Endpoint)
+	}
+	if err := checkOutboundURL(subscription.Endpoint); err != nil {
+		return err
+	}
+	public, err := decodePushKey(subscription.Keys.P256dh)
+	if err != nil {
+		return fmt.Errorf("invalid p256dh key: %s", err)
+	}
+	if x, _ := elliptic.Unmarshal(elliptic.P256(), public); x == nil {
+		return fmt.Errorf("invalid p256dh key, uncompressed P-256 public key is expected")
+	}
+	auth, err := decodePushKey(subscription.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return fmt.Errorf("invalid auth secret, URL-safe base64 encoded 16 bytes are expected")
+	}
+	idle := defaultPushIdle
+	if subscription.Idle != nil {
+		idle = time.Duration(*subscription.Idle) * time.Second
+		if idle < 0 || idle > maxPushIdle {
+			return fmt.Errorf("idle period should be from 0 to %d seconds, but was %d", int(maxPushIdle/time.Second), *subscription.Idle)
+		}
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	subscriptions := n.subscriptions[name]
+	for i, s := range subscriptions {
+		if s.endpoint == subscription.Endpoint {
+			subscriptions = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+	if len(subscriptions) >= maxPushSubscriptions {
+		return fmt.Errorf("number of push subscriptions may not exceed %d", maxPushSubscriptions)
+	}
+	n.subscriptions[name] = append(subscriptions, &pushSubscription{subscription.Endpoint, public, auth, idle})
+	return nil
+}
+
+// Unsubscribe removes subscription of the basket, false is returned if there is no such subscription
+func (n *pushNotifier) Unsubscribe(name string, endpoint string) bool {
+	n.Lock()
+	defer n.Unlock()
+
+	subscriptions := n.subscriptions[name]
+	for i, s := range subscriptions {
+		if s.endpoint == endpoint {
+			if len(subscriptions) == 1 {
+				delete(n.subscriptions, name)
+				delete(n.lastActivity, name)
+			} else {
+				n.subscriptions[name] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes all subscriptions of the basket
+func (n *pushNotifier) Remove(name string) {
+	n.Lock()
+	defer n.Unlock()
+
+	delete(n.subscriptions, name)
+	delete(n.lastActivity, name)
+}
+
+// Rename moves subscriptions of the basket under its new name
+func (n *pushNotifier) Rename(name string, newName string) {
+	n.Lock()
+	defer n.Unlock()
+
+	if subscriptions, ok := n.subscriptions[name]; ok {
+		n.subscriptions[newName] = subscriptions
+		delete(n.subscriptions, name)
+		delete(n.lastActivity, name)
+	}
+}
+
+// Notify queues notifications about request collected by basket for subscriptions, which idle period has
+// passed since the previous request
+func (n *pushNotifier) Notify(name string, request *RequestData) {
+	n.Lock()
+	defer n.Unlock()
+
+	subscriptions := n.subscriptions[name]
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	now := time.Now()
+	last, seen := n.lastActivity[name]
+	n.lastActivity[name] = now
+
+	var payload []byte
+	for _, s := range subscriptions {
+		if seen && now.Sub(last) < s.idle {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(PushNotification{
+				Title:     "Request Baskets: " + name,
+				Body:      fmt.Sprintf("%s %s", request.Method, request.Path),
+				Basket:    name,
+				RequestID: request.ID,
+				Path:      "/" + serviceUIPath + "/" + name})
+			if err != nil {
+				log.Printf("[error] failed to encode push notification of basket: %s - %s", name, err)
+				return
+			}
+		}
+
+		select {
+		case n.queue <- &pushDelivery{name, s, payload}:
+		default:
+			log.Printf("[warn] push queue is full, dropping notification of basket: %s", name)
+		}
+	}
+}
+
+// deliver sends encrypted notification to push service, expired subscriptions are removed
+func (n *pushNotifier) deliver(delivery *pushDelivery) {
+	s := delivery.subscription
+	body, err := encryptPushPayload(s.public, s.auth, delivery.payload)
+	if err != nil {
+		log.Printf("[error] failed to encrypt push notification of basket: %s - %s", delivery.basket, err)
+		return
+	}
+	authorization, err := n.vapidAuthorization(s.endpoint, time.Now())
+	if err != nil {
+		log.Printf("[error] failed to sign push notification of basket: %s - %s", delivery.basket, err)
+		return
+	}
+
+	if err := checkOutboundURL(s.endpoint); err != nil {
+		log.Printf("[warn] push endpoint of basket: %s is not allowed - %s", delivery.basket, err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[warn] invalid push endpoint of basket: %s - %s", delivery.basket, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL/time.Second)))
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		log.Printf("[warn] failed to deliver push notification of basket: %s - %s", delivery.basket, err)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// browser has unsubscribed
+		n.Unsubscribe(delivery.basket, s.endpoint)
+	case resp.StatusCode >= 300:
+		log.Printf("[warn] push notification of basket: %s is rejected with status: %d", delivery.basket, resp.StatusCode)
+	}
+}
+
+// vapidAuthorization returns value of Authorization header that identifies the service to push service (RFC 8292)
+func (n *pushNotifier) vapidAuthorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header := encodePushKey([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidExpiration).Unix(),
+		"sub": n.subject})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + encodePushKey(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, n.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", unsigned, encodePushKey(signature), n.PublicKey().PublicKey), nil
+}
+
+// encryptPushPayload encrypts payload for browser with its public key and authentication secret using
+// aes128gcm content encoding of a single record (RFC 8291 and RFC 8188)
+func encryptPushPayload(public []byte, auth []byte, payload []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, public)
+	if x == nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	// ephemeral key of the service
+	private, sx, sy, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	servicePublic := elliptic.Marshal(curve, sx, sy)
+	shared, _ := curve.ScalarMult(x, y, private)
+
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), public...), servicePublic...)
+	ikm := hkdfExpand(hkdfExtract(auth, shared.FillBytes(make([]byte, 32))), keyInfo, 32)
+	prk := hkdfExtract(salt, ikm)
+	cek := hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// the only record is padded with delimiter of the last record
+	plaintext := append(append([]byte{}, payload...), 2)
+	if len(plaintext)+gcm.Overhead() > pushRecordSize {
+		return nil, fmt.Errorf("payload is too large: %d bytes", len(payload))
+	}
+
+	header := make([]byte, 16+4+1, 16+4+1+len(servicePublic))
+	copy(header, salt)
+	binary.BigEndian.PutUint32(header[16:], pushRecordSize)
+	header[20] = byte(len(servicePublic))
+	header = append(header, servicePublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdfExtract derives pseudorandom key from input keying material (RFC 5869)
+func hkdfExtract(salt []byte, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand derives key of given length from pseudorandom key, length may not exceed 32 bytes (RFC 5869)
+func hkdfExpand(prk []byte, info []byte, length int) []byte {
+	mac := hmac.New(sha256.New, prk)
+	mac.Write(info)
+	mac.Write([]byte{1})
+	return mac.Sum(nil)[:length]
+}
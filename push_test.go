@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPushBrowser generates keys of browser subscription
+func testPushBrowser(t *testing.T, endpoint string) ([]byte, PushSubscription) {
+	private, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	return private, PushSubscription{Endpoint: endpoint, Keys: PushSubscriptionKeys{
+		P256dh: encodePushKey(elliptic.Marshal(elliptic.P256(), x, y)),
+		Auth:   encodePushKey(auth)}}
+}
+
+// decryptPushPayload decrypts notification as browser does
+func decryptPushPayload(t *testing.T, private []byte, subscription PushSubscription, body []byte) string {
+	public, _ := decodePushKey(subscription.Keys.P256dh)
+	auth, _ := decodePushKey(subscription.Keys.Auth)
+
+	salt := body[:16]
+	assert.Equal(t, uint32(pushRecordSize), binary.BigEndian.Uint32(body[16:20]), "wrong record size")
+	servicePublic := body[21 : 21+int(body[20])]
+
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, servicePublic)
+	shared, _ := curve.ScalarMult(x, y, private)
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), public...), servicePublic...)
+	ikm := hkdfExpand(hkdfExtract(auth, shared.FillBytes(make([]byte, 32))), keyInfo, 32)
+	prk := hkdfExtract(salt, ikm)
+	block, _ := aes.NewCipher(hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	gcm, _ := cipher.NewGCM(block)
+
+	plaintext, err := gcm.Open(nil, hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12), body[21+int(body[20]):], nil)
+	if !assert.NoError(t, err, "payload is expected to be decrypted") || !assert.NotEmpty(t, plaintext) {
+		return ""
+	}
+	assert.Equal(t, byte(2), plaintext[len(plaintext)-1], "delimiter of the last record is expected")
+	return string(plaintext[:len(plaintext)-1])
+}
+
+func TestNewPushNotifier(t *testing.T) {
+	n, err := newPushNotifier("", "mailto:admin@example.com")
+	if assert.NoError(t, err) {
+		public, err := decodePushKey(n.PublicKey().PublicKey)
+		assert.NoError(t, err)
+		assert.Len(t, public, 65, "uncompressed public key is expected")
+
+		// the same key is restored
+		restored, err := newPushNotifier(encodePushKey(n.key.D.FillBytes(make([]byte, 32))), "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, n.PublicKey(), restored.PublicKey(), "the same public key is expected")
+		}
+	}
+
+	_, err = newPushNotifier("not a key", "")
+	assert.Error(t, err, "invalid key is not expected")
+	_, err = newPushNotifier(encodePushKey([]byte("short")), "")
+	assert.Error(t, err, "short key is not expected")
+}
+
+func TestPushNotifier_Subscribe(t *testing.T) {
+	n, _ := newPushNotifier("", "")
+	_, subscription := testPushBrowser(t, "https://push.example.com/send/1")
+	assert.NoError(t, n.Subscribe("push01", subscription))
+	assert.NoError(t, n.Subscribe("push01", subscription), "subscription is expected to be replaced")
+	assert.Len(t, n.subscriptions["push01"], 1, "single subscription is expected")
+
+	// padded keys are accepted
+	subscription.Keys.Auth += "=="
+	assert.NoError(t, n.Subscribe("push01", subscription))
+
+	idle := -1
+	invalid := []PushSubscription{
+		{Endpoint: "ftp://push.example.com", Keys: subscription.Keys},
+		{Endpoint: subscription.Endpoint, Keys: PushSubscriptionKeys{P256dh: "abc", Auth: subscription.Keys.Auth}},
+		{Endpoint: subscription.Endpoint, Keys: PushSubscriptionKeys{P256dh: subscription.Keys.P256dh, Auth: "abc"}},
+		{Endpoint: subscription.Endpoint, Keys: subscription.Keys, Idle: &idle}}
+	for _, s := range invalid {
+		assert.Error(t, n.Subscribe("push01", s), "invalid subscription is not expected: %v", s)
+	}
+
+	for i := 2; i <= maxPushSubscriptions; i++ {
+		subscription.Endpoint = "https://push.example.com/send/" + string(rune('0'+i))
+		assert.NoError(t, n.Subscribe("push01", subscription))
+	}
+	subscription.Endpoint = "https://push.example.com/send/extra"
+	assert.Error(t, n.Subscribe("push01", subscription), "too many subscriptions are not expected")
+
+	// unsubscribe, rename and remove
+	assert.True(t, n.Unsubscribe("push01", "https://push.example.com/send/1"))
+	assert.False(t, n.Unsubscribe("push01", "https://push.example.com/send/1"), "subscription is already removed")
+	n.Rename("push01", "push02")
+	assert.Len(t, n.subscriptions["push02"], maxPushSubscriptions-1, "subscriptions are expected to be moved")
+	n.Remove("push02")
+	assert.Empty(t, n.subscriptions, "no subscriptions are expected")
+}
+
+func TestPushNotifier_Notify(t *testing.T) {
+	n, _ := newPushNotifier("", "")
+	_, subscription := testPushBrowser(t, "https://push.example.com/send/1")
+	idle := 0
+	subscription.Idle = &idle
+	assert.NoError(t, n.Subscribe("push03", subscription))
+	_, subscription = testPushBrowser(t, "https://push.example.com/send/2")
+	assert.NoError(t, n.Subscribe("push03", subscription))
+
+	// the first request is notified to all subscriptions
+	n.Notify("push03", &RequestData{ID: 1, Method: "POST", Path: "/push03/hook"})
+	if assert.Len(t, n.queue, 2, "notifications are expected") {
+		delivery := <-n.queue
+		notification := new(PushNotification)
+		if assert.NoError(t, json.Unmarshal(delivery.payload, notification)) {
+			assert.Equal(t, "POST /push03/hook", notification.Body, "wrong notification")
+			assert.Equal(t, "/web/push03", notification.Path, "wrong path of basket page")
+		}
+		<-n.queue
+	}
+
+	// basket is not idle long enough for the default idle period
+	n.Notify("push03", &RequestData{ID: 2, Method: "POST", Path: "/push03/hook"})
+	if assert.Len(t, n.queue, 1, "only notification without idle period is expected") {
+		assert.Equal(t, "https://push.example.com/send/1", (<-n.queue).subscription.endpoint)
+	}
+
+	n.lastActivity["push03"] = time.Now().Add(-defaultPushIdle)
+	n.Notify("push03", &RequestData{ID: 3, Method: "POST", Path: "/push03/hook"})
+	assert.Len(t, n.queue, 2, "request after idle period is expected to be notified")
+
+	// baskets without subscriptions are not tracked
+	n.Notify("push04", &RequestData{ID: 1})
+	_, tracked := n.lastActivity["push04"]
+	assert.False(t, tracked, "basket without subscriptions is not expected to be tracked")
+}
+
+func TestPushNotifier_Deliver(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+		received <- r
+	}))
+	defer server.Close()
+
+	n, _ := newPushNotifier("", "mailto:admin@example.com")
+	private, subscription := testPushBrowser(t, server.URL+"/send/1")
+	assert.NoError(t, n.Subscribe("push05", subscription))
+	n.Start()
+
+	n.Notify("push05", &RequestData{ID: 7, Method: "PUT", Path: "/push05/hook"})
+	select {
+	case r := <-received:
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"), "wrong content encoding")
+		assert.Equal(t, "86400", r.Header.Get("TTL"), "wrong TTL")
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "vapid t="), "VAPID authorization is expected")
+
+		notification := new(PushNotification)
+		if assert.NoError(t, json.Unmarshal([]byte(decryptPushPayload(t, private, subscription, body)), notification)) {
+			assert.Equal(t, "push05", notification.Basket, "wrong basket")
+			assert.Equal(t, 7, notification.RequestID, "wrong request ID")
+		}
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "notification is expected")
+	}
+
+	// expired subscription is removed
+	status = http.StatusGone
+	n.Lock()
+	n.lastActivity["push05"] = time.Now().Add(-defaultPushIdle)
+	n.Unlock()
+	n.Notify("push05", &RequestData{ID: 8, Method: "PUT", Path: "/push05/hook"})
+	<-received
+	removed := false
+	for i := 0; i < 100 && !removed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		n.Lock()
+		removed = len(n.subscriptions["push05"]) == 0
+		n.Unlock()
+	}
+	assert.True(t, removed, "expired subscription is expected to be removed")
+}
+
+func TestVAPIDAuthorization(t *testing.T) {
+	n, _ := newPushNotifier("", "mailto:admin@example.com")
+	now := time.Now()
+	authorization, err := n.vapidAuthorization("https://push.example.com:8443/send/abc", now)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(authorization, "vapid t="), ", k=")
+	if assert.Len(t, parts, 2, "token and key are expected") {
+		assert.Equal(t, n.PublicKey().PublicKey, parts[1], "public key is expected")
+
+		token := strings.Split(parts[0], ".")
+		if assert.Len(t, token, 3, "JWT is expected") {
+			claims, _ := decodePushKey(token[1])
+			assert.JSONEq(t, `{"aud": "https://push.example.com:8443", "exp": `+big.NewInt(now.Add(vapidExpiration).Unix()).String()+
+				`, "sub": "mailto:admin@example.com"}`, string(claims), "wrong claims")
+
+			signature, _ := decodePushKey(token[2])
+			hash := sha256.Sum256([]byte(token[0] + "." + token[1]))
+			assert.True(t, ecdsa.Verify(&n.key.PublicKey, hash[:], new(big.Int).SetBytes(signature[:32]),
+				new(big.Int).SetBytes(signature[32:])), "valid signature is expected")
+		}
+	}
+}
+
+func TestPushNotifier_Denied(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+
+	n, _ := newPushNotifier("", "mailto:admin@example.com")
+	_, subscription := testPushBrowser(t, server.URL+"/send/1")
+	assert.NoError(t, n.Subscribe("push06", subscription))
+
+	// subscriptions registered before the policy is changed are not delivered either
+	defer denyLoopbackTargets()()
+	_, denied := testPushBrowser(t, server.URL+"/send/2")
+	assert.Error(t, n.Subscribe("push06", denied), "push endpoint of denied address is not expected")
+
+	n.Start()
+	n.Notify("push06", &RequestData{ID: 1, Method: "PUT", Path: "/push06/hook"})
+	select {
+	case <-received:
+		assert.Fail(t, "denied address is not expected to be reached")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Policies on collected requests once the storage quota of the service is exhausted
+const (
+	StorageReject = "reject" // respond with 507 status without collecting the request
+	StorageEvict  = "evict"  // evict the oldest requests across all baskets to make room for the request
+)
+
+// storageEvictionMargin defines percentage of the storage quota that is freed in addition by eviction,
+// so requests are not evicted one by one once the quota is reached
+const storageEvictionMargin = 10
+
+// errStorageQuota is reported once request does not fit into storage quota of basket owner, namespace or service
+var errStorageQuota = errors.New("storage quota is exhausted")
+
+// storageMeasureInterval defines how often stored bytes of basket are measured by scanning its requests,
+// in between the bytes are approximated with sizes of accepted requests
+const storageMeasureInterval = 30 * time.Second
+
+var storage = newStorageMeter()
+
+// serviceQuota limits bytes stored in all baskets, nil if the storage of the service is unlimited
+var serviceQuota *serviceStorage
+
+type basketStorage struct {
+	bytes    int64
+	measured time.Time
+}
+
+// storageMeter keeps approximate number of bytes stored in baskets owned by users with storage quota,
+// the numbers are not persisted and are measured again on service restart
+type storageMeter struct {
+	sync.Mutex
+	baskets map[string]*basketStorage
+}
+
+func newStorageMeter() *storageMeter {
+	return &storageMeter{baskets: make(map[string]*basketStorage)}
+}
+
+// Bytes returns number of bytes stored in basket, the basket is measured if it is not measured recently
+func (m *storageMeter) Bytes(name string, basket Basket) int64 {
+	m.Lock()
+	entry, exists := m.baskets[name]
+	if exists && time.Since(entry.measured) < storageMeasureInterval {
+		bytes := entry.bytes
+		m.Unlock()
+		return bytes
+	}
+	m.Unlock()
+
+	bytes := int64(0)
+	StreamRequests(basket, nil, exportPageSize, func(page []*RequestData) error {
+		for _, request := range page {
+			bytes += requestSize(request)
+		}
+		return nil
+	})
+
+	m.Lock()
+	defer m.Unlock()
+	m.baskets[name] = &basketStorage{bytes, time.Now()}
+	return bytes
+}
+
+// Add accounts bytes of accepted request until the basket is measured again
+func (m *storageMeter) Add(name string, bytes int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	if entry, exists := m.baskets[name]; exists {
+		entry.bytes += bytes
+	}
+}
+
+// Remove drops measurement of basket, e.g. once basket is cleared, renamed or deleted
+func (m *storageMeter) Remove(name string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.baskets, name)
+}
+
+// UserBytes returns number of bytes stored in all baskets owned by user
+func (m *storageMeter) UserBytes(user *User) int64 {
+	total := int64(0)
+	for _, name := range user.Baskets {
+		if basket := basketsDb.Get(name); basket != nil {
+			total += m.Bytes(name, basket)
+		}
+	}
+	return total
+}
+
+// serviceStorage keeps approximate number of bytes stored in all baskets of database and enforces the storage
+// quota of the service; the number is measured periodically and approximated with sizes of accepted requests
+type serviceStorage struct {
+	sync.Mutex
+	db       BasketsDatabase
+	maxBytes int64
+	policy   string
+	total    int64
+}
+
+func newServiceStorage(db BasketsDatabase, maxBytes int64, policy string) (*serviceStorage, error) {
+	if err := validateStoragePolicy(policy); err != nil {
+		return nil, err
+	}
+	return &serviceStorage{db: db, maxBytes: maxBytes, policy: policy}, nil
+}
+
+// validateStoragePolicy validates policy on collected requests once the storage quota is exhausted
+func validateStoragePolicy(policy string) error {
+	if policy != StorageReject && policy != StorageEvict {
+		return fmt.Errorf("unknown storage policy: %s, expected %s or %s", policy, StorageReject, StorageEvict)
+	}
+	return nil
+}
+
+// Start measures stored bytes and launches background routine that measures them every storageMeasureInterval
+func (s *serviceStorage) Start() {
+	s.Measure()
+	go func() {
+		for {
+			time.Sleep(storageMeasureInterval)
+			s.Measure()
+		}
+	}()
+}
+
+// Measure measures number of bytes stored in all baskets
+func (s *serviceStorage) Measure() int64 {
+	total := int64(0)
+	forEachBasket(s.db, func(name string, basket Basket) {
+		total += storage.Bytes(name, basket)
+	})
+
+	s.Lock()
+	defer s.Unlock()
+	s.total = total
+	return total
+}
+
+// Bytes returns approximate number of bytes stored in all baskets
+func (s *serviceStorage) Bytes() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.total
+}
+
+// Reserve accounts bytes of request that is about to be collected, returns false if the request does not fit
+// into the quota; the oldest requests across all baskets are evicted to make room if policy allows it
+func (s *serviceStorage) Reserve(bytes int64) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.total+bytes > s.maxBytes && s.policy == StorageEvict {
+		s.total -= s.evict(s.total + bytes - s.maxBytes*(100-storageEvictionMargin)/100)
+	}
+	if s.total+bytes > s.maxBytes {
+		return false
+	}
+	s.total += bytes
+	return true
+}
+
+// CollectTo adds storage usage of the service to database statistics
+func (s *serviceStorage) CollectTo(stats *DatabaseStats) {
+	stats.StoredBytes = s.Bytes()
+	stats.MaxStoredBytes = s.maxBytes
+}
+
+// evict deletes the oldest requests across all baskets until given number of bytes is freed, evicted requests
+// are archived if archive is configured; returns number of freed bytes
+func (s *serviceStorage) evict(bytes int64) int64 {
+	candidates := make(oldestRequests, 0)
+	forEachBasket(s.db, func(name string, basket Basket) {
+		if oldest := oldestRequest(basket); oldest != nil {
+			candidates = append(candidates, &basketRequest{name, basket, oldest})
+		}
+	})
+	heap.Init(&candidates)
+
+	freed, count := int64(0), 0
+	for freed < bytes && candidates.Len() > 0 {
+		next := candidates[0]
+		if archive != nil {
+			archive.Archive(next.name, ArchiveReasonQuota, []*RequestData{next.request})
+		}
+		if next.basket.DeleteRequests([]int{next.request.ID}) > 0 {
+			size := requestSize(next.request)
+			storage.Add(next.name, -size)
+			freed += size
+			count++
+		}
+
+		if oldest := oldestRequest(next.basket); oldest != nil && oldest.ID != next.request.ID {
+			next.request = oldest
+			heap.Fix(&candidates, 0)
+		} else {
+			heap.Pop(&candidates)
+		}
+	}
+
+	log.Printf("[info] evicted %d requests of %d bytes to keep storage quota: %d bytes", count, freed, s.maxBytes)
+	return freed
+}
+
+// basketRequest describes a request collected by basket
+type basketRequest struct {
+	name    string
+	basket  Basket
+	request *RequestData
+}
+
+// oldestRequests is a heap of requests ordered by date, the oldest request comes first
+type oldestRequests []*basketRequest
+
+func (h oldestRequests) Len() int            { return len(h) }
+func (h oldestRequests) Less(i, j int) bool  { return h[i].request.Date < h[j].request.Date }
+func (h oldestRequests) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *oldestRequests) Push(x interface{}) { *h = append(*h, x.(*basketRequest)) }
+func (h *oldestRequests) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// oldestRequest returns the oldest request of basket, nil if basket is empty
+func oldestRequest(basket Basket) *RequestData {
+	if size := basket.Size(); size > 0 {
+		if requests := basket.GetRequests(1, size-1).Requests; len(requests) > 0 {
+			return requests[0]
+		}
+	}
+	return nil
+}
+
+// requestSize returns approximate number of bytes that collected request occupies
+func requestSize(request *RequestData) int64 {
+	size := len(request.Method) + len(request.Path) + len(request.Query) + len(request.Body)
+	for name, values := range request.Header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+	return int64(size)
+}
+
+// defaultUserConfig returns quotas of a new user account
+func defaultUserConfig() UserConfig {
+	return UserConfig{
+		MaxBaskets:  serverConfig.UserBaskets,
+		MaxCapacity: serverConfig.UserCapacity,
+		MaxBytes:    serverConfig.UserBytes}
+}
+
+// userCapacity returns default capacity of a new basket owned by user, the default capacity of service
+// is reduced to the quota of user
+func userCapacity(owner string) int {
+	if user := users.Get(owner); user != nil && user.MaxCapacity > 0 && user.MaxCapacity < serverConfig.InitCapacity {
+		return user.MaxCapacity
+	}
+	return serverConfig.InitCapacity
+}
+
+// checkUserCapacity checks capacity of basket against the quota of its owner, baskets without owner are not checked;
+// writes HTTP response and returns false if the quota is exceeded
+func checkUserCapacity(w http.ResponseWriter, owner string, capacity int) bool {
+	if len(owner) == 0 {
+		return true
+	}
+
+	if user := users.Get(owner); user != nil && user.MaxCapacity > 0 && capacity > user.MaxCapacity {
+		writeError(w, http.StatusForbidden, ErrorQuotaExceeded,
+			fmt.Sprintf("capacity of baskets owned by user '%s' may not be greater than %d", owner, user.MaxCapacity), nil)
+		return false
+	}
+	return true
+}
+
+// exhaustedOwner returns the owner of basket if the storage quota of the owner is exhausted, nil otherwise
+func exhaustedOwner(name string) *User {
+	owner := users.Owner(name)
+	if len(owner) == 0 {
+		return nil
+	}
+
+	if user := users.Get(owner); user != nil && user.MaxBytes > 0 && storage.UserBytes(user) >= user.MaxBytes {
+		return user
+	}
+	return nil
+}
+
+// checkUserStorage checks if the owner of basket may store more requests, baskets without owner are not checked;
+// writes HTTP response and returns false if the storage quota is exhausted
+func checkUserStorage(w http.ResponseWriter, name string) bool {
+	if user := exhaustedOwner(name); user != nil {
+		http.Error(w, fmt.Sprintf("storage quota of basket owner is exhausted: %d bytes", user.MaxBytes),
+			http.StatusInsufficientStorage)
+		return false
+	}
+	return true
+}
+
+// checkServiceStorage checks if request of given size fits into the storage quota of the service and accounts it;
+// writes HTTP response and returns false if the quota is exhausted and requests may not be evicted
+func checkServiceStorage(w http.ResponseWriter, bytes int64) bool {
+	if serviceQuota == nil || serviceQuota.Reserve(bytes) {
+		return true
+	}
+
+	http.Error(w, fmt.Sprintf("storage quota of service is exhausted: %d bytes", serviceQuota.maxBytes),
+		http.StatusInsufficientStorage)
+	return false
+}
+
+// reserveStorage checks storage quotas of basket owner, basket namespace and the service the same way as they are
+// checked for collected requests and accounts request of given size; used for requests that are stored
+// without HTTP request of their own, e.g. imported requests
+func reserveStorage(name string, bytes int64) error {
+	if user := exhaustedOwner(name); user != nil {
+		return fmt.Errorf("%w: %d bytes of basket owner", errStorageQuota, user.MaxBytes)
+	}
+	if policy := namespaces.Reserve(name, bytes); policy != nil {
+		return fmt.Errorf("%w: %d bytes of namespace: %s", errStorageQuota, policy.MaxBytes, policy.Pattern)
+	}
+	if serviceQuota != nil && !serviceQuota.Reserve(bytes) {
+		return fmt.Errorf("%w: %d bytes of service", errStorageQuota, serviceQuota.maxBytes)
+	}
+	return nil
+}
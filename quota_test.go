@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceStorage_Reject(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("quota01", BasketConfig{Capacity: 20})
+	db.Get("quota01").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: strings.Repeat("a", 100)})
+
+	quota, err := newServiceStorage(db, 200, StorageReject)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(105), quota.Measure(), "wrong number of stored bytes")
+	assert.True(t, quota.Reserve(90), "request is expected to fit into quota")
+	assert.False(t, quota.Reserve(10), "request is not expected to fit into quota")
+	assert.Equal(t, int64(195), quota.Bytes(), "rejected request is not expected to be accounted")
+	assert.Equal(t, 1, db.Get("quota01").Size(), "requests are not expected to be evicted")
+
+	_, err = newServiceStorage(db, 200, "drop")
+	assert.Error(t, err, "unknown policy is not expected")
+}
+
+func TestServiceStorage_Evict(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("quota02", BasketConfig{Capacity: 20})
+	db.Create("quota03", BasketConfig{Capacity: 20})
+	body := strings.Repeat("a", 95)
+	db.Get("quota02").AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/", Body: body})
+	db.Get("quota03").AddRequest(&RequestData{Date: 2000, Method: "POST", Path: "/", Body: body})
+	db.Get("quota02").AddRequest(&RequestData{Date: 3000, Method: "POST", Path: "/", Body: body})
+	db.Get("quota03").AddRequest(&RequestData{Date: 4000, Method: "POST", Path: "/", Body: body})
+
+	quota, _ := newServiceStorage(db, 400, StorageEvict)
+	assert.Equal(t, int64(400), quota.Measure(), "wrong number of stored bytes")
+
+	// quota with margin requires to free 200 bytes
+	assert.True(t, quota.Reserve(100), "request is expected to fit into quota once requests are evicted")
+	assert.Equal(t, int64(300), quota.Bytes(), "wrong number of stored bytes")
+	if requests := db.Get("quota02").GetRequests(10, 0).Requests; assert.Len(t, requests, 1, "oldest request is expected to be evicted") {
+		assert.Equal(t, int64(3000), requests[0].Date, "wrong remaining request")
+	}
+	if requests := db.Get("quota03").GetRequests(10, 0).Requests; assert.Len(t, requests, 1, "oldest request is expected to be evicted") {
+		assert.Equal(t, int64(4000), requests[0].Date, "wrong remaining request")
+	}
+
+	assert.False(t, quota.Reserve(1000), "request larger than quota is not expected to fit")
+	assert.Equal(t, 0, db.Get("quota02").Size()+db.Get("quota03").Size(), "all requests are expected to be evicted")
+}
+
+func TestAcceptBasketRequest_StorageQuota(t *testing.T) {
+	defer func(current *serviceStorage) { serviceQuota = current }(serviceQuota)
+
+	basket := "quota04"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+	serviceQuota, _ = newServiceStorage(basketsDb, 1, StorageReject)
+
+	r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("data"))
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code, "wrong HTTP result code")
+	assert.Equal(t, 0, basketsDb.Get(basket).Size(), "request is not expected to be collected")
+}
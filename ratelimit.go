@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Headers that announce rate limit of service API
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset" // seconds until the quota is restored
+)
+
+const rateLimitWindow = time.Minute
+
+var apiLimiter *rateLimiter
+
+// rateLimiter counts requests of every client within fixed time window, clients are identified by
+// the token they present or by their IP address if request is not authorized
+type rateLimiter struct {
+	sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+}
+
+// newRateLimiter creates rate limiter that allows limit requests per window to every client,
+// limit less than 1 disables rate limiting and nil limiter is returned
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit < 1 {
+		return nil
+	}
+	return &rateLimiter{limit: limit, window: window, start: time.Now(), counts: make(map[string]int)}
+}
+
+// Allow counts request of the client and reports whether the request is within the limit,
+// number of remaining requests and time until the counters are reset are returned as well
+func (l *rateLimiter) Allow(client string, now time.Time) (bool, int, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if elapsed := now.Sub(l.start); elapsed >= l.window {
+		l.start = l.start.Add(elapsed - elapsed%l.window)
+		l.counts = make(map[string]int)
+	}
+	reset := l.start.Add(l.window).Sub(now)
+
+	count := l.counts[client]
+	if count >= l.limit {
+		return false, 0, reset
+	}
+
+	l.counts[client] = count + 1
+	return true, l.limit - count - 1, reset
+}
+
+// rateLimitClient identifies the client of HTTP request for the rate limiter
+func rateLimitClient(r *http.Request) string {
+	if token := r.Header.Get("Authorization"); len(token) > 0 {
+		return "token:" + token
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimited rejects HTTP requests that exceed the rate limit of service API with 429 status,
+// every response announces the limit with RateLimit headers
+func rateLimited(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if apiLimiter == nil {
+			handler(w, r, ps)
+			return
+		}
+
+		allowed, remaining, reset := apiLimiter.Allow(rateLimitClient(r), time.Now())
+		seconds := strconv.Itoa(int((reset + time.Second - 1) / time.Second))
+		w.Header().Set(RateLimitLimitHeader, strconv.Itoa(apiLimiter.limit))
+		w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+		w.Header().Set(RateLimitResetHeader, seconds)
+
+		if allowed {
+			handler(w, r, ps)
+		} else {
+			w.Header().Set("Retry-After", seconds)
+			httpError(w, "too many requests, retry in "+seconds+" seconds", http.StatusTooManyRequests)
+		}
+	}
+}
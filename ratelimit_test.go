@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0, time.Minute), "rate limiter is not expected")
+	assert.Nil(t, newRateLimiter(-5, time.Minute), "rate limiter is not expected")
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter(2, time.Minute)
+	now := limiter.start
+
+	allowed, remaining, reset := limiter.Allow("token:abc", now.Add(10*time.Second))
+	assert.True(t, allowed, "request is expected to be allowed")
+	assert.Equal(t, 1, remaining, "wrong number of remaining requests")
+	assert.Equal(t, 50*time.Second, reset, "wrong reset time")
+
+	allowed, remaining, _ = limiter.Allow("token:abc", now.Add(20*time.Second))
+	assert.True(t, allowed, "request is expected to be allowed")
+	assert.Equal(t, 0, remaining, "wrong number of remaining requests")
+
+	allowed, remaining, reset = limiter.Allow("token:abc", now.Add(30*time.Second))
+	assert.False(t, allowed, "request is expected to be rejected")
+	assert.Equal(t, 0, remaining, "wrong number of remaining requests")
+	assert.Equal(t, 30*time.Second, reset, "wrong reset time")
+
+	// clients are limited independently
+	allowed, _, _ = limiter.Allow("token:xyz", now.Add(30*time.Second))
+	assert.True(t, allowed, "request of another client is expected to be allowed")
+
+	// the quota is restored in the next window
+	allowed, remaining, reset = limiter.Allow("token:abc", now.Add(2*time.Minute+15*time.Second))
+	assert.True(t, allowed, "request is expected to be allowed in the next window")
+	assert.Equal(t, 1, remaining, "wrong number of remaining requests")
+	assert.Equal(t, 45*time.Second, reset, "wrong reset time")
+}
+
+func TestRateLimitClient(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost:55555/api/baskets", nil)
+	r.RemoteAddr = "192.168.1.7:40000"
+	assert.Equal(t, "ip:192.168.1.7", rateLimitClient(r), "client is expected to be identified by IP")
+
+	r.Header.Set("Authorization", "abc")
+	assert.Equal(t, "token:abc", rateLimitClient(r), "client is expected to be identified by token")
+}
+
+func TestRateLimited(t *testing.T) {
+	defer func(limiter *rateLimiter) { apiLimiter = limiter }(apiLimiter)
+
+	calls := 0
+	handler := rateLimited(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://localhost:55555/api/baskets", nil)
+		r.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		handler(w, r, nil)
+		return w
+	}
+
+	// not limited
+	apiLimiter = nil
+	w := call("abc")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Empty(t, w.Header().Get(RateLimitLimitHeader), "rate limit headers are not expected")
+
+	apiLimiter = newRateLimiter(1, time.Minute)
+	w = call("abc")
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "1", w.Header().Get(RateLimitLimitHeader), "wrong limit header")
+	assert.Equal(t, "0", w.Header().Get(RateLimitRemainingHeader), "wrong remaining header")
+	assert.NotEmpty(t, w.Header().Get(RateLimitResetHeader), "reset header is expected")
+
+	w = call("abc")
+	assert.Equal(t, 429, w.Code, "wrong HTTP result code")
+	assert.Equal(t, w.Header().Get(RateLimitResetHeader), w.Header().Get("Retry-After"), "Retry-After header is expected")
+	assert.Equal(t, 2, calls, "rejected request is not expected to be handled")
+
+	assert.Equal(t, 200, call("xyz").Code, "wrong HTTP result code")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+var reaper *idleReaper
+
+// idleReaper deletes baskets that have never collected a request nor been viewed for a number of days; the owner
+// is notified with basket_idle webhook event and the basket is deleted once the notice period is over unless
+// it is used in the meantime. Views and notices are kept in memory, so restart of the service postpones deletion.
+type idleReaper struct {
+	sync.Mutex
+	db     BasketsDatabase
+	remove func(name string)
+	after  time.Duration    // idle period before the owner is notified
+	notice time.Duration    // period between notification and deletion
+	seen   map[string]int64 // first check of unchanged baskets in milliseconds
+	viewed map[string]int64 // the latest views of baskets in milliseconds
+	warned map[string]int64 // notifications about pending deletion in milliseconds
+}
+
+func newIdleReaper(db BasketsDatabase, remove func(name string), after time.Duration, notice time.Duration) *idleReaper {
+	return &idleReaper{db: db, remove: remove, after: after, notice: notice, seen: make(map[string]int64),
+		viewed: make(map[string]int64), warned: make(map[string]int64)}
+}
+
+// Start launches background routine that checks idle baskets every retentionInterval
+func (ir *idleReaper) Start() {
+	go func() {
+		for {
+			time.Sleep(retentionInterval)
+			ir.Reap(time.Now())
+		}
+	}()
+}
+
+// Viewed records view of basket, e.g. once its requests are fetched, so the basket is not idle any more
+func (ir *idleReaper) Viewed(name string) {
+	ir.Lock()
+	defer ir.Unlock()
+	ir.viewed[name] = time.Now().UnixNano() / toMs
+}
+
+// Reap notifies owners of baskets that became idle and deletes idle baskets which notice period is over at given
+// time, returns names of deleted baskets
+func (ir *idleReaper) Reap(now time.Time) []string {
+	ir.Lock()
+	defer ir.Unlock()
+
+	nowMs := now.UnixNano() / toMs
+	seen := make(map[string]int64, len(ir.seen))
+	viewed := make(map[string]int64, len(ir.viewed))
+	warned := make(map[string]int64, len(ir.warned))
+	reaped := make([]string, 0)
+	forEachBasket(ir.db, func(name string, basket Basket) {
+		if basket.GetRequests(0, 0).TotalCount > 0 {
+			return
+		}
+
+		idleSince := basket.LastModified()
+		if idleSince == 0 {
+			if idleSince = ir.seen[name]; idleSince == 0 {
+				idleSince = nowMs
+			}
+			seen[name] = idleSince
+		}
+		if view, ok := ir.viewed[name]; ok {
+			viewed[name] = view
+			if view > idleSince {
+				idleSince = view
+			}
+		}
+		if nowMs-idleSince < ir.after.Nanoseconds()/toMs {
+			return
+		}
+
+		// basket used after notification is notified again once it is idle again
+		if date, ok := ir.warned[name]; ok && date >= idleSince {
+			if nowMs-date >= ir.notice.Nanoseconds()/toMs {
+				reaped = append(reaped, name)
+			} else {
+				warned[name] = date
+			}
+			return
+		}
+
+		log.Printf("[info] basket: %s is idle and is going to be deleted in %s", name, ir.notice)
+		warned[name] = nowMs
+		webhooks.Publish(basket, WebhookEvent{Event: EventBasketIdle, Basket: name,
+			Expires: nowMs + ir.notice.Nanoseconds()/toMs})
+	})
+	ir.seen, ir.viewed, ir.warned = seen, viewed, warned
+
+	// baskets are deleted after iteration, so pages of basket names are not shifted
+	for _, name := range reaped {
+		log.Printf("[info] deleting idle basket: %s", name)
+		ir.remove(name)
+	}
+	return reaped
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleReaper_Reap(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("reaper01", BasketConfig{Capacity: 20})
+	db.Create("reaper02", BasketConfig{Capacity: 20})
+	db.Get("reaper02").AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/reaper02"})
+
+	day := 24 * time.Hour
+	now := time.Now()
+	ir := newIdleReaper(db, db.Delete, 7*day, 3*day)
+	assert.Empty(t, ir.Reap(now), "new basket is not expected to be deleted")
+
+	// owner is notified first
+	assert.Empty(t, ir.Reap(now.Add(8*day)), "idle basket is not expected to be deleted before notice")
+	assert.Contains(t, ir.warned, "reaper01", "owner of idle basket is expected to be notified")
+	assert.NotContains(t, ir.warned, "reaper02", "basket with requests is not expected to be idle")
+
+	assert.Empty(t, ir.Reap(now.Add(10*day)), "idle basket is not expected to be deleted before notice is over")
+	assert.Equal(t, []string{"reaper01"}, ir.Reap(now.Add(11*day)), "wrong deleted baskets")
+	assert.Nil(t, db.Get("reaper01"), "idle basket is expected to be deleted")
+	assert.NotNil(t, db.Get("reaper02"), "basket with requests is not expected to be deleted")
+}
+
+func TestIdleReaper_Viewed(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("reaper03", BasketConfig{Capacity: 20})
+
+	day := 24 * time.Hour
+	now := time.Now()
+	ir := newIdleReaper(db, db.Delete, 7*day, 3*day)
+	ir.Reap(now.Add(-8 * day))
+	assert.Empty(t, ir.Reap(now.Add(-day)), "idle basket is not expected to be deleted before notice")
+
+	// view of basket after notification cancels the deletion
+	ir.Viewed("reaper03")
+	assert.Empty(t, ir.Reap(now.Add(3*day)), "viewed basket is not expected to be deleted")
+	assert.NotNil(t, db.Get("reaper03"), "viewed basket is expected to be kept")
+
+	// basket idle once again is deleted after new notice
+	assert.Empty(t, ir.Reap(now.Add(8*day)), "idle basket is not expected to be deleted before notice")
+	assert.Equal(t, []string{"reaper03"}, ir.Reap(now.Add(11*day)), "wrong deleted baskets")
+}
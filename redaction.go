@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Presets of redaction rules for commonly leaked secrets
+const (
+	RedactCardNumber  = "card_number"  // payment card numbers
+	RedactBearerToken = "bearer_token" // bearer tokens of Authorization headers and alike
+)
+
+const (
+	redactedValue        = "[REDACTED]"
+	maxRedactionRules    = 20
+	maxRedactionSettings = 4000 // size of JSON encoded rules, so the rules fit into SQL column
+)
+
+var redactionPresets = map[string]*regexp.Regexp{
+	RedactCardNumber:  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+	RedactBearerToken: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)}
+
+// RedactionRule describes sensitive data that is replaced with [REDACTED] mark before collected request
+// is stored in basket; a rule defines either a header name, a regular expression or a preset name
+type RedactionRule struct {
+	Header  string `json:"header,omitempty"`  // name of header which values are redacted
+	Pattern string `json:"pattern,omitempty"` // regular expression matched in header values, query and body
+	Preset  string `json:"preset,omitempty"`  // card_number or bearer_token
+}
+
+// expression returns regular expression of the rule, nil if the rule redacts a header
+func (rule RedactionRule) expression() (*regexp.Regexp, error) {
+	if len(rule.Preset) > 0 {
+		if expr, exists := redactionPresets[rule.Preset]; exists {
+			return expr, nil
+		}
+		return nil, fmt.Errorf("unknown redaction preset: %s, expected %s or %s", rule.Preset, RedactCardNumber,
+			RedactBearerToken)
+	}
+	if len(rule.Pattern) > 0 {
+		return regexp.Compile(rule.Pattern)
+	}
+	return nil, nil
+}
+
+// validateRedaction validates redaction rules of basket
+func validateRedaction(rules []RedactionRule) error {
+	if len(rules) > maxRedactionRules {
+		return fmt.Errorf("number of redaction rules may not be greater than %d", maxRedactionRules)
+	}
+	for _, rule := range rules {
+		defined := 0
+		for _, value := range []string{rule.Header, rule.Pattern, rule.Preset} {
+			if len(value) > 0 {
+				defined++
+			}
+		}
+		if defined != 1 {
+			return fmt.Errorf("redaction rule should define exactly one of header, pattern or preset")
+		}
+		if _, err := rule.expression(); err != nil {
+			return fmt.Errorf("invalid redaction rule: %s", err)
+		}
+	}
+	if rulesj, _ := json.Marshal(rules); len(rulesj) > maxRedactionSettings {
+		return fmt.Errorf("redaction rules may not be longer than %d characters", maxRedactionSettings)
+	}
+	return nil
+}
+
+// redactRequest applies redaction rules of basket to collected request, the request is never modified,
+// instead a redacted copy marked as redacted is returned if any sensitive data is found
+func redactRequest(data *RequestData, rules []RedactionRule) *RequestData {
+	if len(rules) == 0 {
+		return data
+	}
+
+	redacted := *data
+	redacted.Header = make(http.Header, len(data.Header))
+	for k, v := range data.Header {
+		redacted.Header[k] = append([]string(nil), v...)
+	}
+
+	for _, rule := range rules {
+		if len(rule.Header) > 0 {
+			if values := redacted.Header[http.CanonicalHeaderKey(rule.Header)]; len(values) > 0 {
+				for i := range values {
+					values[i] = redactedValue
+				}
+				redacted.Redacted = true
+			}
+			continue
+		}
+
+		expr, err := rule.expression()
+		if err != nil || expr == nil {
+			continue
+		}
+		redact := func(value string) string {
+			if expr.MatchString(value) {
+				redacted.Redacted = true
+				return expr.ReplaceAllLiteralString(value, redactedValue)
+			}
+			return value
+		}
+		for _, values := range redacted.Header {
+			for i := range values {
+				values[i] = redact(values[i])
+			}
+		}
+		redacted.Query = redact(redacted.Query)
+		redacted.Body = redact(redacted.Body)
+	}
+
+	if !redacted.Redacted {
+		return data
+	}
+	return &redacted
+}
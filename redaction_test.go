@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRedaction(t *testing.T) {
+	assert.NoError(t, validateRedaction(nil))
+	assert.NoError(t, validateRedaction([]RedactionRule{{Header: "Authorization"}, {Pattern: `secret=\w+`},
+		{Preset: RedactBearerToken}}))
+	assert.Error(t, validateRedaction([]RedactionRule{{}}), "empty rule is not expected")
+	assert.Error(t, validateRedaction([]RedactionRule{{Header: "Authorization", Preset: RedactCardNumber}}),
+		"rule with several definitions is not expected")
+	assert.Error(t, validateRedaction([]RedactionRule{{Pattern: `(`}}), "invalid pattern is not expected")
+	assert.Error(t, validateRedaction([]RedactionRule{{Preset: "password"}}), "unknown preset is not expected")
+	assert.Error(t, validateRedaction(make([]RedactionRule, maxRedactionRules+1)), "too many rules are not expected")
+	assert.Error(t, validateRedaction([]RedactionRule{{Pattern: strings.Repeat("x", maxRedactionSettings)}}),
+		"too long rules are not expected")
+}
+
+func TestRedactRequest(t *testing.T) {
+	data := &RequestData{
+		Header: http.Header{"X-Api-Key": {"key1"}, "Authorization": {"Bearer abc.def-123"}},
+		Query:  "card=4111-1111-1111-1111&page=1",
+		Body:   `{"card": "4111 1111 1111 1111", "amount": 100}`}
+	rules := []RedactionRule{{Header: "x-api-key"}, {Preset: RedactCardNumber}, {Preset: RedactBearerToken}}
+
+	redacted := redactRequest(data, rules)
+	assert.True(t, redacted.Redacted, "request is expected to be marked as redacted")
+	assert.Equal(t, "[REDACTED]", redacted.Header.Get("X-Api-Key"), "wrong redacted header")
+	assert.Equal(t, "[REDACTED]", redacted.Header.Get("Authorization"), "wrong redacted bearer token")
+	assert.Equal(t, "card=[REDACTED]&page=1", redacted.Query, "wrong redacted query")
+	assert.Equal(t, `{"card": "[REDACTED]", "amount": 100}`, redacted.Body, "wrong redacted body")
+
+	// original request is not modified
+	assert.False(t, data.Redacted, "original request is not expected to be marked")
+	assert.Equal(t, "key1", data.Header.Get("X-Api-Key"), "original header is expected")
+	assert.Equal(t, "Bearer abc.def-123", data.Header.Get("Authorization"), "original header is expected")
+
+	// request without sensitive data is kept as is
+	clean := &RequestData{Header: http.Header{"Accept": {"*/*"}}, Body: "amount=100"}
+	assert.Equal(t, clean, redactRequest(clean, rules), "request is not expected to be redacted")
+	assert.Equal(t, data, redactRequest(data, nil), "request is not expected to be redacted without rules")
+}
+
+func TestAcceptBasketRequests_Redaction(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer target.Close()
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "redaction01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "redaction": [{"pattern": "("}]}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket, auth.Token,
+		`{"capacity": 20, "forward_url": "`+target.URL+`", "redaction": [{"preset": "bearer_token"}]}`).Code,
+		"wrong HTTP result code")
+
+	assert.Equal(t, 200, call("POST", "/"+basket, "Bearer secret01", "token=secret02").Code, "wrong HTTP result code")
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.Equal(t, "[REDACTED]", page.Requests[0].Header.Get("Authorization"), "token is expected to be redacted")
+		assert.Equal(t, "token=secret02", page.Requests[0].Body, "body is not expected to be redacted")
+		assert.True(t, page.Requests[0].Redacted, "request is expected to be marked as redacted")
+	}
+
+	// request is forwarded as received
+	select {
+	case r := <-received:
+		assert.Equal(t, "Bearer secret01", r.Header.Get("Authorization"), "original request is expected to be forwarded")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "request is expected to be forwarded")
+	}
+}
+
+func TestAcceptBasketRequests_RedactionScript(t *testing.T) {
+	basket := "redaction02"
+	_, err := basketsDb.Create(basket, BasketConfig{Capacity: 20,
+		Redaction: []RedactionRule{{Preset: "bearer_token"}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).SetResponse("POST", ResponseConfig{IsScript: true,
+		Body: "print(request['Headers']['Authorization'][0])"})
+
+	// response script gets the request as received
+	r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("payload"))
+	r.Header.Add("Authorization", "Bearer secret01")
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, 202, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "Bearer secret01\n", w.Body.String(), "original request is expected in response script")
+
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.Equal(t, "[REDACTED]", page.Requests[0].Header.Get("Authorization"), "token is expected to be redacted")
+		assert.Equal(t, 202, page.Requests[0].ResponseStatus, "response status is expected to be recorded")
+	}
+}
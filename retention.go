@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// retentionInterval defines how often collected requests are checked against retention period
+const retentionInterval = time.Minute
+
+var retention *requestRetention
+
+// PurgedRequests describes number of requests deleted from a basket by purge.
+type PurgedRequests struct {
+	Basket  string `json:"basket"`
+	Deleted int    `json:"deleted"`
+}
+
+// PurgeReceipt describes purge of collected requests across all baskets, the receipt proves deletion without
+// disclosing the deleted data: search criteria are only kept as SHA-256 digest and may be verified later
+// by presenting the same criteria.
+type PurgeReceipt struct {
+	ID        string            `json:"id"`
+	Date      int64             `json:"date"`
+	Criteria  string            `json:"criteria"` // hex encoded SHA-256 digest of search criteria
+	Baskets   []*PurgedRequests `json:"baskets"`
+	Deleted   int               `json:"deleted"`
+	Remaining int               `json:"remaining"`          // requests matching the criteria after purge
+	Verified  int64             `json:"verified,omitempty"` // date of verification if remaining requests are counted again
+}
+
+// PurgeReceiptsPage describes a page of purge receipts, the latest purges come first.
+type PurgeReceiptsPage struct {
+	Receipts []*PurgeReceipt `json:"receipts"`
+	HasMore  bool            `json:"has_more"`
+}
+
+// requestRetention deletes collected requests that are older than retention period of their basket,
+// retention period of the service applies to baskets without own period and limits the periods of baskets
+type requestRetention struct {
+	db     BasketsDatabase
+	maxAge int // seconds, 0 - requests are kept until they are evicted by newer requests
+}
+
+func newRequestRetention(db BasketsDatabase, maxAge int) *requestRetention {
+	return &requestRetention{db: db, maxAge: maxAge}
+}
+
+// Start launches background routine that deletes expired requests every retentionInterval
+func (rr *requestRetention) Start() {
+	go func() {
+		for {
+			time.Sleep(retentionInterval)
+			rr.Purge(time.Now())
+		}
+	}()
+}
+
+// MaxAge returns retention period in seconds of basket with given name and configuration, 0 if requests are
+// not expired; the shortest period of basket, its namespace and the service applies
+func (rr *requestRetention) MaxAge(name string, config BasketConfig) int {
+	if policy := namespaces.Match(name); policy != nil {
+		return shortestPeriod(config.Retention, policy.Retention, rr.maxAge)
+	}
+	return shortestPeriod(config.Retention, rr.maxAge)
+}
+
+// Purge deletes requests that are expired at given time, returns number of deleted requests
+func (rr *requestRetention) Purge(now time.Time) int {
+	total := 0
+	forEachBasket(rr.db, func(name string, basket Basket) {
+		maxAge := rr.MaxAge(name, basket.Config())
+		if maxAge <= 0 {
+			return
+		}
+
+		// pruning is done by storage backend without loading collected requests
+		cutoff := now.Add(-time.Duration(maxAge)*time.Second).UnixNano() / toMs
+		if archive != nil {
+			archive.Archive(name, ArchiveReasonRetention, basket.FindRequests(&RequestsQuery{To: cutoff - 1}, basket.Size(), 0).Requests)
+		}
+		if deleted := basket.DeleteRequestsBefore(cutoff); deleted > 0 {
+			storage.Remove(name)
+			log.Printf("[info] deleted %d requests of basket: %s older than %d seconds", deleted, name, maxAge)
+			total += deleted
+		}
+	})
+	return total
+}
+
+// validateRetention validates retention period of basket in seconds, the period of basket may not be longer than
+// retention period of the service
+func validateRetention(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("retention should be a positive number of seconds, but was %d", seconds)
+	}
+	if max := serverConfig.Retention; max > 0 && seconds > max {
+		return fmt.Errorf("retention may not be longer than %d seconds", max)
+	}
+	return nil
+}
+
+// forEachBasket calls function for every basket of database, baskets deleted in the meantime are skipped
+func forEachBasket(db BasketsDatabase, fn func(name string, basket Basket)) {
+	for skip, hasMore := 0, true; hasMore; {
+		page := db.GetNames(searchNamesChunk, skip)
+		for _, name := range page.Names {
+			if basket := db.Get(name); basket != nil {
+				fn(name, basket)
+			}
+		}
+		skip += len(page.Names)
+		hasMore = page.HasMore && len(page.Names) > 0
+	}
+}
+
+// purgeRequests deletes requests matching the query in all baskets and issues receipt of the deletion,
+// criteria are search parameters of the query that are kept in receipt as a digest
+func purgeRequests(db BasketsDatabase, query *RequestsQuery, criteria url.Values) *PurgeReceipt {
+	id, _ := GenerateToken()
+	receipt := &PurgeReceipt{ID: id, Date: time.Now().UnixNano() / toMs, Criteria: criteriaDigest(criteria),
+		Baskets: make([]*PurgedRequests, 0)}
+
+	forEachBasket(db, func(name string, basket Basket) {
+		if deleted := DeleteSelectedRequests(basket, nil, query); deleted > 0 {
+			storage.Remove(name)
+			receipt.Baskets = append(receipt.Baskets, &PurgedRequests{Basket: name, Deleted: deleted})
+			receipt.Deleted += deleted
+		}
+	})
+	receipt.Remaining = countMatchingRequests(db, query)
+
+	return receipt
+}
+
+// countMatchingRequests counts requests matching the query in all baskets
+func countMatchingRequests(db BasketsDatabase, query *RequestsQuery) int {
+	count := 0
+	forEachBasket(db, func(name string, basket Basket) {
+		count += len(basket.FindRequests(query, basket.Size(), 0).Requests)
+	})
+	return count
+}
+
+// criteriaDigest calculates SHA-256 digest of search criteria, parameters other than search parameters
+// are ignored and the order of parameters does not matter
+func criteriaDigest(values url.Values) string {
+	criteria := url.Values{}
+	for _, param := range searchParams {
+		if v, ok := values[param.Name]; ok {
+			criteria[param.Name] = v
+		}
+	}
+	digest := sha256.Sum256([]byte(criteria.Encode()))
+	return hex.EncodeToString(digest[:])
+}
+
+// findPurgeReceipts returns receipts of purges recorded in audit log, the latest purges come first
+func findPurgeReceipts(max int, skip int) PurgeReceiptsPage {
+	entries := audit.Find(AuditQuery{Action: AuditRequestPurge}, max, skip)
+	page := PurgeReceiptsPage{Receipts: make([]*PurgeReceipt, 0, len(entries.Entries)), HasMore: entries.HasMore}
+	for _, entry := range entries.Entries {
+		receipt := new(PurgeReceipt)
+		if err := json.Unmarshal(entry.After, receipt); err == nil {
+			page.Receipts = append(page.Receipts, receipt)
+		}
+	}
+	return page
+}
+
+// findPurgeReceipt returns receipt of purge recorded in audit log, nil if receipt is not found
+func findPurgeReceipt(id string) *PurgeReceipt {
+	page := audit.Find(AuditQuery{Action: AuditRequestPurge}, maxAuditEntries, 0)
+	for _, entry := range page.Entries {
+		if entry.Target == id {
+			receipt := new(PurgeReceipt)
+			if err := json.Unmarshal(entry.After, receipt); err == nil {
+				return receipt
+			}
+		}
+	}
+	return nil
+}
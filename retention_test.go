@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestRetention_MaxAge(t *testing.T) {
+	rr := newRequestRetention(nil, 0)
+	assert.Equal(t, 0, rr.MaxAge("retention", BasketConfig{}), "requests are not expected to expire")
+	assert.Equal(t, 60, rr.MaxAge("retention", BasketConfig{Retention: 60}), "retention of basket is expected")
+
+	rr = newRequestRetention(nil, 3600)
+	assert.Equal(t, 3600, rr.MaxAge("retention", BasketConfig{}), "retention of service is expected")
+	assert.Equal(t, 60, rr.MaxAge("retention", BasketConfig{Retention: 60}), "shorter retention of basket is expected")
+	assert.Equal(t, 3600, rr.MaxAge("retention", BasketConfig{Retention: 7200}), "retention of service is expected to limit basket")
+}
+
+func TestRequestRetention_Purge(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	now := time.Now()
+	add := func(basket Basket, age time.Duration) {
+		basket.AddRequest(&RequestData{Date: now.Add(-age).UnixNano() / toMs, Method: "GET", Path: "/"})
+	}
+
+	db.Create("retention01", BasketConfig{Capacity: 20, Retention: 60})
+	db.Create("retention02", BasketConfig{Capacity: 20})
+	for _, name := range []string{"retention01", "retention02"} {
+		basket := db.Get(name)
+		add(basket, 2*time.Hour)
+		add(basket, 10*time.Minute)
+		add(basket, 10*time.Second)
+	}
+
+	// only basket with own retention period
+	assert.Equal(t, 2, newRequestRetention(db, 0).Purge(now), "wrong number of deleted requests")
+	assert.Equal(t, 1, db.Get("retention01").Size(), "expired requests are expected to be deleted")
+	assert.Equal(t, 3, db.Get("retention02").Size(), "requests are not expected to expire")
+
+	// retention period of service
+	assert.Equal(t, 1, newRequestRetention(db, 3600).Purge(now), "wrong number of deleted requests")
+	assert.Equal(t, 1, db.Get("retention01").Size(), "wrong number of requests")
+	assert.Equal(t, 2, db.Get("retention02").Size(), "expired requests are expected to be deleted")
+}
+
+func TestValidateRetention(t *testing.T) {
+	defer func(max int) { serverConfig.Retention = max }(serverConfig.Retention)
+
+	serverConfig.Retention = 0
+	assert.NoError(t, validateRetention(0))
+	assert.NoError(t, validateRetention(86400))
+	assert.Error(t, validateRetention(-1), "negative retention is not expected")
+
+	serverConfig.Retention = 3600
+	assert.NoError(t, validateRetention(60))
+	assert.Error(t, validateRetention(7200), "retention longer than retention of service is not expected")
+}
+
+func TestCriteriaDigest(t *testing.T) {
+	a, _ := url.ParseQuery("q=alice@example.com&in=body&max=10")
+	b, _ := url.ParseQuery("in=body&q=alice@example.com")
+	c, _ := url.ParseQuery("q=bob@example.com&in=body")
+	assert.Equal(t, criteriaDigest(a), criteriaDigest(b), "order and other parameters are expected to be ignored")
+	assert.NotEqual(t, criteriaDigest(a), criteriaDigest(c), "different criteria are expected to differ")
+	assert.NotContains(t, criteriaDigest(a), "alice", "criteria are not expected to be disclosed")
+}
+
+func TestPurgeRequests(t *testing.T) {
+	call := func(method string, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, nil)
+		r.Header.Add("Authorization", serverConfig.MasterToken)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, name := range []string{"purge01", "purge02"} {
+		if _, err := basketsDb.Create(name, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+			return
+		}
+		basket := basketsDb.Get(name)
+		basket.AddRequest(&RequestData{Date: time.Now().UnixNano() / toMs, Method: "POST", Path: "/",
+			Body: `{"email": "purge.alice@example.com"}`})
+		basket.AddRequest(&RequestData{Date: time.Now().UnixNano() / toMs, Method: "POST", Path: "/",
+			Body: `{"email": "purge.bob@example.com"}`})
+	}
+
+	assert.Equal(t, 400, call("POST", "/api/purges").Code, "criteria are expected")
+
+	w := call("POST", "/api/purges?q=purge.alice@example.com")
+	if !assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		return
+	}
+	receipt := PurgeReceipt{}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt)) {
+		assert.NotEmpty(t, receipt.ID, "receipt ID is expected")
+		assert.Equal(t, 2, receipt.Deleted, "wrong number of deleted requests")
+		assert.Len(t, receipt.Baskets, 2, "wrong number of purged baskets")
+		assert.Equal(t, 0, receipt.Remaining, "no matching requests are expected to remain")
+		assert.NotContains(t, w.Body.String(), "alice", "criteria are not expected to be disclosed")
+	}
+	assert.Equal(t, 1, basketsDb.Get("purge01").Size(), "other requests are expected to be kept")
+	assert.Equal(t, 1, basketsDb.Get("purge02").Size(), "other requests are expected to be kept")
+
+	// receipts
+	w = call("GET", "/api/purges")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), receipt.ID, "receipt is expected to be listed")
+	}
+
+	w = call("GET", "/api/purges/"+url.PathEscape(receipt.ID)+"?q=purge.alice@example.com")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		verified := PurgeReceipt{}
+		json.Unmarshal(w.Body.Bytes(), &verified)
+		assert.Equal(t, 2, verified.Deleted, "wrong number of deleted requests")
+		assert.Equal(t, 0, verified.Remaining, "no matching requests are expected to remain")
+		assert.NotZero(t, verified.Verified, "verification date is expected")
+	}
+
+	assert.Equal(t, 422, call("GET", "/api/purges/"+url.PathEscape(receipt.ID)+"?q=purge.bob@example.com").Code,
+		"other criteria are not expected to match receipt")
+	assert.Equal(t, 404, call("GET", "/api/purges/unknown").Code, "wrong HTTP result code")
+
+	w = call("GET", "/api/audit?action="+AuditRequestPurge)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), receipt.ID, "purge is expected to be recorded in audit log")
+	}
+}
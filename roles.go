@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// roles of service tokens in addition to RoleAdmin that is equivalent to the master token
+const (
+	RoleOperator = "operator" // operates baskets, e.g. inspects and deletes them
+	RoleViewer   = "viewer"   // read-only access to the service
+)
+
+// Permissions of service roles, the scopes of basket access tokens are permissions over all baskets
+const (
+	PermissionStats = "stats" // database statistics
+	PermissionList  = "list"  // names of all baskets
+)
+
+var rolePermissions = []string{PermissionStats, PermissionList, ScopeRead, ScopeWriteConfig, ScopeClear, ScopeDelete}
+
+var defaultRoles = map[string][]string{
+	RoleOperator: {PermissionStats, PermissionList, ScopeRead, ScopeClear, ScopeDelete},
+	RoleViewer:   {PermissionStats, PermissionList, ScopeRead}}
+
+var roles *roleDirectory
+
+// Role describes permissions of service role.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleConfig describes permissions of service role that are managed with the master token.
+type RoleConfig struct {
+	Permissions []string `json:"permissions"`
+}
+
+// RoleToken describes named service token of a role, the token itself is reported once it is issued.
+type RoleToken struct {
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Token   string `json:"token,omitempty"`
+	Created int64  `json:"created"`
+}
+
+// roleFile describes content of roles file
+type roleFile struct {
+	Roles  map[string][]string `json:"roles"`
+	Tokens []RoleToken         `json:"tokens"`
+}
+
+// roleDirectory keeps permissions of service roles and issued service tokens, the roles are stored in JSON file
+// if the file is configured, otherwise roles are kept in memory only
+type roleDirectory struct {
+	sync.RWMutex
+	file     string
+	roles    map[string][]string
+	tokens   map[string]RoleToken // service tokens by token
+	sessions map[string]RoleToken // in-memory tokens of identities authenticated otherwise, by token
+}
+
+// newRoleDirectory creates role directory and loads roles from the file, roles that are not defined in the file
+// keep default permissions
+func newRoleDirectory(file string) (*roleDirectory, error) {
+	d := &roleDirectory{
+		file:     file,
+		roles:    make(map[string][]string),
+		tokens:   make(map[string]RoleToken),
+		sessions: make(map[string]RoleToken)}
+	for role, permissions := range defaultRoles {
+		d.roles[role] = permissions
+	}
+	if len(file) == 0 {
+		return d, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		log.Printf("[info] roles file is not found, it is created once roles are changed: %s", file)
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read roles file: %s - %s", file, err)
+	}
+
+	content := roleFile{}
+	if err = json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse roles file: %s - %s", file, err)
+	}
+	for role, permissions := range content.Roles {
+		if _, known := d.roles[role]; !known {
+			return nil, fmt.Errorf("unknown role in roles file: %s", role)
+		}
+		if err = validatePermissions(permissions); err != nil {
+			return nil, fmt.Errorf("invalid role in roles file: %s - %s", role, err)
+		}
+		d.roles[role] = permissions
+	}
+	for _, token := range content.Tokens {
+		if !validTokenName.MatchString(token.Name) || len(token.Token) == 0 || !isServiceRole(token.Role) {
+			return nil, fmt.Errorf("invalid token in roles file: %s", token.Name)
+		}
+		d.tokens[token.Token] = token
+	}
+	log.Printf("[info] loaded %d service tokens from file: %s", len(content.Tokens), file)
+
+	return d, nil
+}
+
+// List returns all roles sorted by name, including admin role that has all permissions
+func (d *roleDirectory) List() []Role {
+	d.RLock()
+	defer d.RUnlock()
+
+	list := []Role{{RoleAdmin, append([]string{}, rolePermissions...)}}
+	for role, permissions := range d.roles {
+		list = append(list, Role{role, append([]string{}, permissions...)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Update replaces permissions of role, false is returned if role is unknown; permissions of admin role
+// cannot be changed
+func (d *roleDirectory) Update(role string, config RoleConfig) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	if _, known := d.roles[role]; !known {
+		return false
+	}
+
+	d.roles[role] = config.Permissions
+	d.save()
+	return true
+}
+
+// Issue issues a new service token of role
+func (d *roleDirectory) Issue(name string, role string) (RoleToken, error) {
+	token := RoleToken{Name: name, Role: role, Created: time.Now().UnixNano() / toMs}
+	value, err := GenerateToken()
+	if err != nil {
+		return token, fmt.Errorf("failed to generate token: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	for _, t := range d.tokens {
+		if t.Name == name {
+			return token, fmt.Errorf("Token with name '%s' already exists", name)
+		}
+	}
+
+	token.Token = value
+	d.tokens[value] = token
+	d.save()
+	return token, nil
+}
+
+// Tokens returns service tokens of role sorted by name without the tokens themselves
+func (d *roleDirectory) Tokens(role string) []RoleToken {
+	d.RLock()
+	defer d.RUnlock()
+
+	list := make([]RoleToken, 0, len(d.tokens))
+	for _, token := range d.tokens {
+		if token.Role == role {
+			token.Token = ""
+			list = append(list, token)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Revoke revokes service token of role by its name, false is returned if token is not found
+func (d *roleDirectory) Revoke(role string, name string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	for value, token := range d.tokens {
+		if token.Name == name && token.Role == role {
+			delete(d.tokens, value)
+			d.save()
+			return true
+		}
+	}
+	return false
+}
+
+// Session returns in-memory token of role for identity authenticated otherwise, e.g. with client certificate;
+// the token is issued on the first request of identity and is neither stored nor listed with service tokens
+func (d *roleDirectory) Session(name string, role string) (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	for value, t := range d.sessions {
+		if t.Name == name && t.Role == role {
+			return value, nil
+		}
+	}
+
+	value, err := GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %s", err)
+	}
+	d.sessions[value] = RoleToken{Name: name, Role: role, Created: time.Now().UnixNano() / toMs}
+	return value, nil
+}
+
+// Lookup returns service token by the token itself, e.g. to identify the actor of request
+func (d *roleDirectory) Lookup(token string) (RoleToken, bool) {
+	d.RLock()
+	defer d.RUnlock()
+
+	return d.find(token)
+}
+
+// IsAdmin checks if token is a service token of admin role
+func (d *roleDirectory) IsAdmin(token string) bool {
+	d.RLock()
+	defer d.RUnlock()
+
+	t, exists := d.find(token)
+	return exists && t.Role == RoleAdmin
+}
+
+// Authorize checks if token is a service token which role grants the permission, admin role grants all permissions
+func (d *roleDirectory) Authorize(token string, permission string) bool {
+	d.RLock()
+	defer d.RUnlock()
+
+	t, exists := d.find(token)
+	if !exists {
+		return false
+	}
+	if t.Role == RoleAdmin {
+		return true
+	}
+	for _, p := range d.roles[t.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// find finds service token or session token by the token itself
+func (d *roleDirectory) find(token string) (RoleToken, bool) {
+	if len(token) == 0 {
+		return RoleToken{}, false
+	}
+	if t, exists := d.tokens[token]; exists {
+		return t, true
+	}
+	t, exists := d.sessions[token]
+	return t, exists
+}
+
+// save writes roles and service tokens to the file if the file is configured, the file is replaced at once
+func (d *roleDirectory) save() {
+	if len(d.file) == 0 {
+		return
+	}
+
+	content := roleFile{Roles: d.roles, Tokens: make([]RoleToken, 0, len(d.tokens))}
+	for _, token := range d.tokens {
+		content.Tokens = append(content.Tokens, token)
+	}
+	sort.Slice(content.Tokens, func(i, j int) bool { return content.Tokens[i].Name < content.Tokens[j].Name })
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err == nil {
+		if err = ioutil.WriteFile(d.file+".tmp", data, 0600); err == nil {
+			err = os.Rename(d.file+".tmp", d.file)
+		}
+	}
+	if err != nil {
+		log.Printf("[error] failed to save roles file: %s - %s", d.file, err)
+	}
+}
+
+// isServiceRole checks if role is a known role of service tokens
+func isServiceRole(role string) bool {
+	_, known := defaultRoles[role]
+	return known || role == RoleAdmin
+}
+
+// validatePermissions validates permissions of role
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		known := false
+		for _, p := range rolePermissions {
+			known = known || p == permission
+		}
+		if !known {
+			return fmt.Errorf("unknown permission: %s", permission)
+		}
+	}
+	return nil
+}
+
+// isAdminToken checks if token grants full access to the service: the master token, service token of admin role
+// or API key
+func isAdminToken(token string) bool {
+	return len(token) > 0 && (token == serverConfig.MasterToken || roles.IsAdmin(token) || apiKeys.Authenticate(token))
+}
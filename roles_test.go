@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleDirectory_Authorize(t *testing.T) {
+	d, err := newRoleDirectory("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	admin, err := d.Issue("admin01", RoleAdmin)
+	assert.NoError(t, err)
+	operator, _ := d.Issue("operator01", RoleOperator)
+	viewer, _ := d.Issue("viewer01", RoleViewer)
+	_, err = d.Issue("viewer01", RoleViewer)
+	assert.Error(t, err, "token with the same name is not expected")
+
+	// default permissions
+	assert.True(t, d.IsAdmin(admin.Token), "admin token is expected")
+	assert.False(t, d.IsAdmin(operator.Token), "operator token is not expected to be admin")
+	assert.True(t, d.Authorize(admin.Token, ScopeWriteConfig), "admin is expected to have all permissions")
+	assert.True(t, d.Authorize(operator.Token, ScopeDelete), "operator is expected to delete baskets")
+	assert.False(t, d.Authorize(operator.Token, ScopeWriteConfig), "operator is not expected to change baskets")
+	assert.True(t, d.Authorize(viewer.Token, PermissionStats), "viewer is expected to get statistics")
+	assert.False(t, d.Authorize(viewer.Token, ScopeClear), "viewer is not expected to clear baskets")
+	assert.False(t, d.Authorize("", ScopeRead), "empty token is not expected")
+	assert.False(t, d.Authorize("wrong", ScopeRead), "unknown token is not expected")
+
+	// changed permissions
+	assert.True(t, d.Update(RoleViewer, RoleConfig{Permissions: []string{ScopeRead}}))
+	assert.False(t, d.Authorize(viewer.Token, PermissionStats), "permission is expected to be withdrawn")
+	assert.False(t, d.Update(RoleAdmin, RoleConfig{}), "admin role is not expected to be changed")
+	assert.False(t, d.Update("guest", RoleConfig{}), "unknown role is not expected")
+
+	// listed tokens and roles
+	if tokens := d.Tokens(RoleOperator); assert.Len(t, tokens, 1) {
+		assert.Equal(t, "operator01", tokens[0].Name, "wrong token name")
+		assert.Empty(t, tokens[0].Token, "token is not expected to be reported")
+	}
+	if list := d.List(); assert.Len(t, list, 3) {
+		assert.Equal(t, RoleAdmin, list[0].Name, "wrong role")
+		assert.Equal(t, rolePermissions, list[0].Permissions, "admin is expected to have all permissions")
+		assert.Equal(t, []string{ScopeRead}, list[2].Permissions, "wrong permissions of viewer")
+	}
+
+	// revoked token
+	assert.False(t, d.Revoke(RoleViewer, "operator01"), "token of other role is not expected to be revoked")
+	assert.True(t, d.Revoke(RoleOperator, "operator01"))
+	assert.False(t, d.Authorize(operator.Token, ScopeRead), "revoked token is not expected")
+}
+
+func TestRoleDirectory_File(t *testing.T) {
+	file := "roles01.json"
+	defer os.Remove(file)
+
+	d, err := newRoleDirectory(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+	viewer, _ := d.Issue("viewer01", RoleViewer)
+	d.Update(RoleOperator, RoleConfig{Permissions: []string{PermissionList}})
+
+	restored, err := newRoleDirectory(file)
+	if assert.NoError(t, err) {
+		assert.True(t, restored.Authorize(viewer.Token, ScopeRead), "token is expected to be restored")
+		assert.Equal(t, []string{PermissionList}, restored.roles[RoleOperator], "permissions are expected to be restored")
+	}
+
+	// roles missing in file keep default permissions
+	ioutil.WriteFile(file, []byte(`{"roles": {"viewer": ["list"]}}`), 0600)
+	if restored, err = newRoleDirectory(file); assert.NoError(t, err) {
+		assert.Equal(t, []string{PermissionList}, restored.roles[RoleViewer], "wrong permissions of viewer")
+		assert.Equal(t, defaultRoles[RoleOperator], restored.roles[RoleOperator], "default permissions are expected")
+	}
+
+	ioutil.WriteFile(file, []byte("{"), 0600)
+	_, err = newRoleDirectory(file)
+	assert.Error(t, err, "invalid file is not expected")
+	ioutil.WriteFile(file, []byte(`{"roles": {"guest": ["read"]}}`), 0600)
+	_, err = newRoleDirectory(file)
+	assert.Error(t, err, "unknown role is not expected")
+	ioutil.WriteFile(file, []byte(`{"roles": {"viewer": ["all"]}}`), 0600)
+	_, err = newRoleDirectory(file)
+	assert.Error(t, err, "unknown permission is not expected")
+	ioutil.WriteFile(file, []byte(`{"tokens": [{"name": "t1", "role": "guest", "token": "abc"}]}`), 0600)
+	_, err = newRoleDirectory(file)
+	assert.Error(t, err, "token of unknown role is not expected")
+}
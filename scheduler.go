@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// maxBasketSchedules defines maximum number of scheduled scripts per basket
+const maxBasketSchedules = 10
+
+var scheduler *scriptScheduler
+
+// stopScheduler stops background routine of the scheduler of the service
+var stopScheduler = func() {}
+
+type scheduledJob struct {
+	config   ScheduleConfig
+	schedule *cronSchedule
+}
+
+// scriptScheduler runs basket scripts according to their cron schedules
+type scriptScheduler struct {
+	sync.Mutex
+	db   BasketsDatabase
+	jobs map[string][]*scheduledJob
+}
+
+// newScriptScheduler creates a scheduler and registers scheduled scripts of all existing baskets
+func newScriptScheduler(db BasketsDatabase) *scriptScheduler {
+	s := &scriptScheduler{db: db, jobs: make(map[string][]*scheduledJob)}
+
+	for skip, hasMore := 0, true; hasMore; {
+		page := db.GetNames(100, skip)
+		for _, name := range page.Names {
+			if basket := db.Get(name); basket != nil {
+				s.Register(name, basket.GetSchedules())
+			}
+		}
+		skip += len(page.Names)
+		hasMore = page.HasMore && len(page.Names) > 0
+	}
+
+	return s
+}
+
+// Register replaces scheduled scripts of a basket, empty list unregisters the basket
+func (s *scriptScheduler) Register(name string, schedules []ScheduleConfig) {
+	jobs := make([]*scheduledJob, 0, len(schedules))
+	for _, config := range schedules {
+		schedule, err := parseCron(config.Cron)
+		if err != nil {
+			log.Printf("[warn] skipping schedule '%s' of basket: %s - %s", config.Name, name, err)
+			continue
+		}
+		jobs = append(jobs, &scheduledJob{config, schedule})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if len(jobs) > 0 {
+		s.jobs[name] = jobs
+	} else {
+		delete(s.jobs, name)
+	}
+}
+
+// Start launches background routine that checks schedules at the beginning of every minute, returned function
+// stops the routine and waits until it exits; scripts that are already running are not interrupted
+func (s *scriptScheduler) Start() (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-quit:
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.RunDue(next)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-done
+	}
+}
+
+// RunDue runs all scheduled scripts that are due at given time
+func (s *scriptScheduler) RunDue(t time.Time) {
+	s.Lock()
+	due := make(map[string][]ScheduleConfig)
+	for name, jobs := range s.jobs {
+		for _, job := range jobs {
+			if job.schedule.Matches(t) {
+				due[name] = append(due[name], job.config)
+			}
+		}
+	}
+	s.Unlock()
+
+	for name, configs := range due {
+		basket := s.db.Get(name)
+		if basket == nil {
+			// basket is gone
+			s.Register(name, nil)
+			continue
+		}
+
+		for _, config := range configs {
+			go runSchedule(name, basket, config)
+		}
+	}
+}
+
+func runSchedule(name string, basket Basket, config ScheduleConfig) {
+	span := startSpan("script.schedule", spanKindInternal)
+	span.SetAttribute("basket", name)
+	span.SetAttribute("schedule", config.Name)
+	out, err := scriptSchedule(name, config.Name, config.Script, basket)
+	span.SetError(err)
+	span.End()
+	if len(out) > 0 {
+		log.Printf("[info] schedule '%s' output of basket: %s - %s", config.Name, name, sanitizeForLog(out))
+	}
+	if err != nil {
+		log.Printf("[warn] schedule '%s' script failed for basket: %s - %s", config.Name, name, err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptScheduler_RunDue(t *testing.T) {
+	name := "schedule01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i := 0; i < 5; i++ {
+		basket.Add(createTestPOSTRequest("http://localhost/"+name, "data", "text/plain"))
+	}
+	basket.SetSchedules([]ScheduleConfig{{Name: "cleanup", Cron: "0 3 * * *", Script: "if basket.size() > 3: basket.clear()"}})
+
+	s := newScriptScheduler(db)
+	// not due yet
+	s.RunDue(time.Date(2024, 1, 15, 2, 0, 0, 0, time.UTC))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 5, basket.Size(), "basket should not be cleared")
+
+	// due
+	s.RunDue(time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, basket.Size(), "basket should be cleared by scheduled script")
+}
+
+func TestScriptScheduler_Register(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	s := newScriptScheduler(db)
+	s.Register("schedule02", []ScheduleConfig{{Name: "a", Cron: "@daily", Script: "print(1)"}, {Name: "b", Cron: "wrong", Script: ""}})
+	if assert.Len(t, s.jobs["schedule02"], 1, "one valid job is expected") {
+		assert.Equal(t, "a", s.jobs["schedule02"][0].config.Name, "wrong job")
+	}
+
+	s.Register("schedule02", nil)
+	assert.Empty(t, s.jobs, "no jobs are expected")
+
+	// unknown basket is unregistered when jobs are due
+	s.Register("unknown", []ScheduleConfig{{Name: "a", Cron: "* * * * *", Script: "print(1)"}})
+	s.RunDue(time.Now())
+	assert.Empty(t, s.jobs, "no jobs are expected")
+}
+
+func TestScriptSchedule_BasketAccess(t *testing.T) {
+	name := "schedule03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.Add(createTestPOSTRequest("http://localhost/"+name+"/a", "hello", "text/plain"))
+	basket.Add(createTestPOSTRequest("http://localhost/"+name+"/b", "world", "text/plain"))
+
+	out, err := scriptSchedule(name, "summary", "print(basket.name, basket.size())\n"+
+		"print([r['Path'] for r in basket.requests()])\n"+
+		"print([r['Body'] for r in basket.find('wor', field='body')])", basket)
+	if assert.NoError(t, err) {
+		assert.Equal(t, name+" 2\n[\"/"+name+"/b\", \"/"+name+"/a\"]\n[\"world\"]\n", out, "wrong script output")
+	}
+}
+
+func TestScriptScheduler_Start(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	stop := newScriptScheduler(db).Start()
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		// stopping again is safe
+		stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "scheduler routine is expected to exit once stopped")
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
 )
 
 func (r *RequestData) ToStarlark() *starlark.Dict {
 	res := starlark.NewDict(10)
+	res.SetKey(starlark.String("ID"), starlark.MakeInt(r.ID))
 	res.SetKey(starlark.String("Date"), starlark.MakeInt64(r.Date))
 	res.SetKey(starlark.String("ContentLength"), starlark.MakeInt64(r.ContentLength))
 	res.SetKey(starlark.String("Headers"), headerToStarDict(r.Header))
@@ -18,6 +22,7 @@ func (r *RequestData) ToStarlark() *starlark.Dict {
 	res.SetKey(starlark.String("Method"), starlark.String(r.Method))
 	res.SetKey(starlark.String("Path"), starlark.String(r.Path))
 	res.SetKey(starlark.String("Query"), starlark.String(r.Query))
+	res.SetKey(starlark.String("ForwardLatency"), starlark.MakeInt64(r.ForwardLatency))
 	return res
 }
 
@@ -33,17 +38,175 @@ func headerToStarDict(h http.Header) *starlark.Dict {
 	return res
 }
 
-func scriptResponse(bucket, script string, req *RequestData) (string, error) {
+// scriptOptions allows top-level statements in scripts, since scripts are not supposed to be libraries
+var scriptOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true}
+
+// scriptMaxSteps limits computation steps of a script, scripts may otherwise loop forever with while statements
+const scriptMaxSteps = 10000000
+
+// Timeouts of script execution
+var (
+	scriptTimeout         = 30 * time.Second // trigger and scheduled scripts, they run outside of the response path
+	responseScriptTimeout = 5 * time.Second  // response scripts delay response to the client
+)
+
+// validateScript checks that script source is a syntactically valid Starlark program
+func validateScript(filename string, script string) error {
+	_, err := scriptOptions.Parse(filename, script, 0)
+	return err
+}
+
+// runScript executes Starlark script and returns everything it prints, execution is recorded in script metrics;
+// script is cancelled once it exceeds the budget of computation steps or the timeout
+func runScript(bucket, filename, script string, timeout time.Duration, predeclared starlark.StringDict) (string, error) {
+	start := time.Now()
 	out := new(bytes.Buffer)
 	thread := &starlark.Thread{
 		Name:  bucket,
 		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(out, msg) },
 	}
-	_, err := starlark.ExecFile(thread, "test.star", []byte(script), starlark.StringDict{
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	timer := time.AfterFunc(timeout, func() { thread.Cancel(fmt.Sprintf("timeout of %s exceeded", timeout)) })
+	defer timer.Stop()
+
+	_, err := starlark.ExecFileOptions(scriptOptions, thread, filename, []byte(script), predeclared)
+	scriptMetrics.Record(bucket, scriptName(filename), time.Since(start), err)
+	metrics.Timing("scripts.latency", time.Since(start), "basket:"+bucket, "script:"+scriptName(filename))
+	slowOps.Observe(SlowScript, bucket, scriptName(filename), time.Since(start))
+	if err != nil {
+		metrics.Count("scripts.errors", 1, "basket:"+bucket, "script:"+scriptName(filename))
+	}
+	return out.String(), err
+}
+
+// envToStarlark converts basket secrets into a frozen Starlark dictionary
+func envToStarlark(env map[string]string) *starlark.Dict {
+	res := starlark.NewDict(len(env))
+	for k, v := range env {
+		res.SetKey(starlark.String(k), starlark.String(v))
+	}
+	res.Freeze()
+	return res
+}
+
+func scriptResponse(bucket, script string, req *RequestData, env map[string]string) (string, error) {
+	return runScript(bucket, "response.star", script, responseScriptTimeout, starlark.StringDict{
 		"request": req.ToStarlark(),
+		"env":     envToStarlark(env),
 	})
+}
+
+func scriptTrigger(bucket, script string, req *RequestData, env map[string]string) (string, error) {
+	return runScript(bucket, "trigger.star", script, scriptTimeout, starlark.StringDict{
+		"request": req.ToStarlark(),
+		"env":     envToStarlark(env),
+		"notify":  notifyModule,
+	})
+}
+
+// runTrigger executes basket trigger script for collected request, supposed to run outside of the response path;
+// output and error of the script are recorded with the request; the script is traced within span of collected request
+func runTrigger(name string, basket Basket, trigger *TriggerConfig, req *RequestData, span *traceSpan) {
+	script := span.Child("script.trigger", spanKindInternal)
+	script.SetAttribute("basket", name)
+	out, err := scriptTrigger(name, trigger.Script, req, basket.GetSecrets())
+	script.SetError(err)
+	script.End()
+	if len(out) > 0 {
+		log.Printf("[info] trigger output of basket: %s - %s", name, sanitizeForLog(out))
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("[warn] trigger script failed for basket: %s - %s", name, err)
 	}
-	return out.String(), err
+
+	if len(out) > 0 || err != nil {
+		basket.UpdateRequest(req.ID, func(data *RequestData) {
+			data.ScriptLog = out
+			if err != nil {
+				data.ScriptError = err.Error()
+			}
+		})
+	}
+}
+
+// basketToStarlark creates a Starlark module that gives scripts access to the data collected by basket
+func basketToStarlark(name string, basket Basket) *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "basket",
+		Members: starlark.StringDict{
+			"name": starlark.String(name),
+			"size": starlark.NewBuiltin("basket.size", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+					return nil, err
+				}
+				return starlark.MakeInt(basket.Size()), nil
+			}),
+			"requests": starlark.NewBuiltin("basket.requests", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				max, skip := defaultPageSize, 0
+				if err := starlark.UnpackArgs(b.Name(), args, kwargs, "max?", &max, "skip?", &skip); err != nil {
+					return nil, err
+				}
+				return requestsToStarlark(basket.GetRequests(max, skip).Requests), nil
+			}),
+			"find": starlark.NewBuiltin("basket.find", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				var text, field, queryType, method, path, header, filter, order string
+				var ignoreCase, wholeWord bool
+				max, skip := defaultPageSize, 0
+				if err := starlark.UnpackArgs(b.Name(), args, kwargs, "query?", &text, "field?", &field, "max?", &max, "skip?", &skip,
+					"query_type?", &queryType, "method?", &method, "path?", &path, "header?", &header, "filter?", &filter,
+					"ignore_case?", &ignoreCase, "whole_word?", &wholeWord, "sort?", &order); err != nil {
+					return nil, err
+				}
+				var query *RequestsQuery
+				var err error
+				if len(filter) > 0 {
+					query, err = NewFilterQuery(filter)
+				} else {
+					query, err = NewRequestsQuery(text, field, queryType)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", b.Name(), err)
+				}
+				query.SetOptions(ignoreCase, wholeWord)
+				if len(method) > 0 {
+					query.Method = method
+				}
+				if len(path) > 0 {
+					query.SetPath(path)
+				}
+				if len(header) > 0 {
+					if err = query.AddHeader(header); err != nil {
+						return nil, fmt.Errorf("%s: %s", b.Name(), err)
+					}
+				}
+				if err = query.SetSort(order); err != nil {
+					return nil, fmt.Errorf("%s: %s", b.Name(), err)
+				}
+				return requestsToStarlark(FindSortedRequests(basket, query, max, skip).Requests), nil
+			}),
+			"clear": starlark.NewBuiltin("basket.clear", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+					return nil, err
+				}
+				basket.Clear()
+				return starlark.None, nil
+			}),
+		},
+	}
+}
+
+func requestsToStarlark(requests []*RequestData) *starlark.List {
+	list := make([]starlark.Value, 0, len(requests))
+	for _, req := range requests {
+		list = append(list, req.ToStarlark())
+	}
+	return starlark.NewList(list)
+}
+
+func scriptSchedule(bucket, schedule, script string, basket Basket) (string, error) {
+	return runScript(bucket, "schedule/"+schedule+".star", script, scriptTimeout, starlark.StringDict{
+		"basket": basketToStarlark(bucket, basket),
+		"env":    envToStarlark(basket.GetSecrets()),
+		"notify": notifyModule,
+	})
 }
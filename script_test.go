@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateScript(t *testing.T) {
+	assert.NoError(t, validateScript("test.star", "print(request['Body'])"))
+	assert.Error(t, validateScript("test.star", "print(request['Body']"))
+}
+
+func TestScriptTrigger(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+	data.Header.Add("Content-Type", "application/json")
+	data.Method = "POST"
+	data.Path = "/trigger01/hooks"
+	data.Body = "{ \"event\" : \"created\" }"
+
+	out, err := scriptTrigger("trigger01", "print(request['Method'], request['Path'])\nprint(request['Headers']['Content-Type'][0])", data, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "POST /trigger01/hooks\napplication/json\n", out, "wrong script output")
+	}
+}
+
+func TestScriptTrigger_Error(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	out, err := scriptTrigger("trigger02", "print('before')\nfail('broken trigger')", data, nil)
+	assert.Equal(t, "before\n", out, "wrong script output")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "broken trigger", "wrong error message")
+	}
+}
+
+func TestScriptResponse_Limits(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	// budget of computation steps
+	out, err := scriptResponse("response01", "print('before')\nwhile True:\n  pass", data, nil)
+	assert.Equal(t, "before\n", out, "wrong script output")
+	if assert.Error(t, err, "endless script is expected to be cancelled") {
+		assert.Contains(t, err.Error(), "too many steps", "wrong error message")
+	}
+
+	// timeout
+	defer func(timeout time.Duration) { responseScriptTimeout = timeout }(responseScriptTimeout)
+	responseScriptTimeout = 10 * time.Millisecond
+	start := time.Now()
+	_, err = scriptResponse("response01", "while True:\n  pass", data, nil)
+	if assert.Error(t, err, "endless script is expected to be cancelled") {
+		assert.Contains(t, err.Error(), "timeout", "wrong error message")
+	}
+	assert.True(t, time.Since(start) < time.Second, "script is expected to be cancelled on timeout")
+}
+
+func TestRunTrigger_RecordsOutput(t *testing.T) {
+	name := "trigger05"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	basket := db.Get(name)
+	ok := basket.Add(createTestPOSTRequest("http://localhost/"+name, "ok", "text/plain"))
+	broken := basket.Add(createTestPOSTRequest("http://localhost/"+name, "broken", "text/plain"))
+
+	script := "print('body:', request['Body'])\nif request['Body'] == 'broken':\n  fail('broken trigger')"
+	runTrigger(name, basket, &TriggerConfig{Script: script}, ok, nil)
+	runTrigger(name, basket, &TriggerConfig{Script: script}, broken, nil)
+
+	if request := basket.GetRequest(ok.ID); assert.NotNil(t, request, "request is expected") {
+		assert.Equal(t, "body: ok\n", request.ScriptLog, "wrong script log")
+		assert.Empty(t, request.ScriptError, "no script error is expected")
+	}
+	if request := basket.GetRequest(broken.ID); assert.NotNil(t, request, "request is expected") {
+		assert.Equal(t, "body: broken\n", request.ScriptLog, "wrong script log")
+		assert.Contains(t, request.ScriptError, "broken trigger", "wrong script error")
+	}
+}
+
+func TestScriptTrigger_Env(t *testing.T) {
+	data := new(RequestData)
+	data.Header = make(http.Header)
+
+	out, err := scriptTrigger("trigger03", "print(env['API_KEY'])\nprint(env.get('MISSING', 'none'))", data,
+		map[string]string{"API_KEY": "s3cr3t"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "s3cr3t\nnone\n", out, "wrong script output")
+	}
+
+	// secrets are read-only for scripts
+	_, err = scriptTrigger("trigger03", "env['API_KEY'] = 'changed'", data, map[string]string{"API_KEY": "s3cr3t"})
+	assert.Error(t, err, "env modification is expected to fail")
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -35,6 +36,18 @@ func CreateServer(config *ServerConfig) *http.Server {
 		SourceCode:  sourceCodeURL}
 
 	log.Printf("[info] service version: %s from commit: %s (%s)", version.Version, version.CommitShort, version.Commit)
+	// basket tokens are stored as hashes
+	tokenPepper = []byte(config.TokenPepper)
+	if err := validateBodyPolicy(config.BodyPolicy); err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	if err := validateBodyCompression(config.BodyCompression); err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	bodyCompression = config.BodyCompression
+
 	// create database
 	db := createBasketsDatabase(config.DbType, config.DbFile, config.DbConnection)
 	if db == nil {
@@ -43,61 +56,385 @@ func CreateServer(config *ServerConfig) *http.Server {
 	}
 	createDefaultBaskets(db, config.Baskets)
 
+	// deleted baskets are kept in trash for a grace period and hidden from the rest of the service
+	trash = newBasketTrash(db, time.Duration(config.TrashPeriod)*time.Second)
+	trash.Start()
+
+	// user accounts that own baskets, including baskets in trash
+	directory, err := newUserDirectory(config.UsersFile, db)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	users = directory
+
+	db = &visibleDatabase{db, trash}
 	basketsDb = db
 
-	// HTTP clients
-	httpClient = new(http.Client)
-	insecureTransport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	// service roles and tokens
+	serviceRoles, err := newRoleDirectory(config.RolesFile)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	roles = serviceRoles
+
+	// long-lived API keys of automation
+	keys, err := newAPIKeyDirectory(config.APIKeysFile)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	apiKeys = keys
+
+	// audit log of configuration changes
+	auditLog, err := newAuditLog(config.AuditFile)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	audit = auditLog
+
+	// sign in with OpenID Connect provider
+	oidc = nil
+	if len(config.OIDCIssuer) > 0 {
+		provider, err := newOIDCProvider(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		oidc = provider
+	}
+
+	// sign in with credentials of LDAP directory users
+	ldap = nil
+	if len(config.LDAPURL) > 0 {
+		authenticator, err := newLDAPAuthenticator(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		ldap = authenticator
+	}
+
+	// sign in with identity headers of trusted authentication proxy
+	proxyAuth = nil
+	if len(config.ProxyTrusted) > 0 {
+		authenticator, err := newProxyAuthenticator(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		proxyAuth = authenticator
+	}
+
+	// JSON web tokens of trusted issuer
+	jwt = nil
+	if len(config.JWTIssuer) > 0 {
+		authenticator, err := newJWTAuthenticator(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		jwt = authenticator
+	}
+
+	// scheduled scripts
+	stopScheduler()
+	scheduler = newScriptScheduler(db)
+	stopScheduler = scheduler.Start()
+
+	// scheduled cleanup policies
+	cleanup = newCleanupScheduler(db, deleteBasket)
+	if len(config.CleanupPolicies) > 0 {
+		policies, err := loadCleanupPolicies(config.CleanupPolicies)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		cleanup.SetPolicies(policies)
+	}
+	cleanup.Start()
+
+	// retention and quota of namespaces of baskets
+	namespaces = newNamespacePolicies(db)
+	if len(config.NamespacePolicies) > 0 {
+		policies, err := loadNamespacePolicies(config.NamespacePolicies)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		namespaces.SetPolicies(policies)
+	}
+	namespaces.Start()
+
+	// warnings of slow operations
+	slowOps = newSlowOperations(config.SlowForward, config.SlowScript, config.SlowStorage)
+
+	// alert rules of baskets
+	if len(config.SMTP) > 0 {
+		m, err := newSMTPMailer(config.SMTP, config.SMTPFrom)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		mailer = m
+	}
+	alerts = newAlertMonitor(db, deliverAlert)
+	if len(config.AlertRules) > 0 {
+		rules, err := loadAlertRules(config.AlertRules)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		alerts.SetRules(rules)
+	}
+	alerts.Start()
+
+	// storage quota of the service
+	if config.MaxStorage > 0 {
+		quota, err := newServiceStorage(db, config.MaxStorage, config.StoragePolicy)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		serviceQuota = quota
+		serviceQuota.Start()
+	}
+
+	// tracing of collected requests
+	if len(config.OTLPEndpoint) > 0 {
+		t, err := newSpanTracer(config.OTLPEndpoint)
+		if err != nil {
+			log.Printf("[error] failed to set up tracing: %s", err)
+			return nil
+		}
+		tracer = t
+		tracer.Start()
+	}
+
+	// metrics pushed to StatsD server
+	if len(config.Statsd) > 0 {
+		exporter, err := newStatsdExporter(config.Statsd, config.StatsdPrefix, config.StatsdTags, config.StatsdInterval)
+		if err != nil {
+			log.Printf("[error] failed to set up StatsD metrics: %s", err)
+			return nil
+		}
+		metrics = exporter
+		metrics.Start(db)
+	}
+
+	// archive of evicted requests
+	if len(config.Archive) > 0 {
+		sink, err := newArchiveSink(config.Archive)
+		if err != nil {
+			log.Printf("[error] failed to set up archive of evicted requests: %s", err)
+			return nil
+		}
+		archive = newRequestArchive(sink)
+		archive.Start()
+	}
+
+	// deletion of expired requests
+	retention = newRequestRetention(db, config.Retention)
+	retention.Start()
+
+	// deletion of expired baskets
+	expiry = newBasketExpiry(db, deleteBasket)
+	expiry.Start()
+
+	// deletion of baskets that are never used
+	reaper = newIdleReaper(db, func(name string) {
+		if err := removeBasket(name); err != nil {
+			log.Printf("[error] failed to delete idle basket: %s - %s", name, err)
+		}
+	}, time.Duration(config.ReapAfter)*24*time.Hour, time.Duration(config.ReapNotice)*24*time.Hour)
+	if config.ReapAfter > 0 {
+		reaper.Start()
+	}
+
+	// webhook subscriptions
+	webhooks = newWebhookDispatcher()
+	webhooks.Start()
+	webhooks.Resume(db)
+	if len(config.MQTTBroker) > 0 {
+		publisher, err := newMQTTPublisher(config.MQTTBroker, config.MQTTTopic)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		publisher.Start()
+		webhooks.mqtt = publisher
+	}
+
+	// push notifications to browsers
+	notifier, err := newPushNotifier(config.PushKey, config.PushSubject)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	pushes = notifier
+	pushes.Start()
+
+	// rate limit of service API
+	apiLimiter = newRateLimiter(config.RateLimit, rateLimitWindow)
+
+	// anti-abuse controls of anonymous basket creation
+	creationLimiter = newRateLimiter(config.CreateLimit, creationLimitWindow)
+	challenges = nil
+	if config.CreatePoW > 0 {
+		issuer, err := newChallengeIssuer(config.CreatePoW)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		challenges = issuer
+	}
+
+	// networks allowed to access service API and web UI
+	adminNetworks = nil
+	if len(config.AdminAllowed) > 0 {
+		networks, err := parseNetworks(config.AdminAllowed)
+		if err != nil {
+			log.Printf("[error] invalid allowed network of service administration: %s", err)
+			return nil
+		}
+		adminNetworks = networks
+	}
+
+	// client certificates required to access service API and web UI
+	clientCerts = nil
+	if len(config.ClientCA) > 0 {
+		authenticator, err := newClientCertAuthenticator(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		clientCerts = authenticator
+	}
+
+	// limits of requests collected simultaneously
+	captures = newCaptureSlots(config.MaxCaptures, config.BasketCaptures)
+
+	// policy on names of new baskets
+	policy, err := newBasketNamePolicy(config.NameMinLength, config.NameReserved, config.NameBlocklist)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	namePolicy = policy
+
+	// TLS certificates of the service obtained from ACME provider
+	acmeManager = nil
+	if len(config.ACMEDomains) > 0 {
+		manager, err := newACMEManager(config)
+		if err != nil {
+			log.Printf("[error] %s", err)
+			return nil
+		}
+		acmeManager = manager
+	}
+
+	// hardening of HTTPS listener
+	hardening, err := newTLSSettings(config.TLSMinVersion, config.TLSCiphers, config.HTTP2)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+
+	// HTTP clients of forwarding, both never connect to denied networks
+	guard, err := newForwardPolicy(config.ForwardSchemes, config.ForwardDenied, config.ForwardAllowed)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	forwardGuard = guard
+	tuning, err := newForwardTuning(config.ForwardIdleConns, config.ForwardIdleTimeout, config.ForwardTLSTimeout,
+		config.ForwardKeepAlive)
+	if err != nil {
+		log.Printf("[error] %s", err)
+		return nil
+	}
+	httpClient = &http.Client{Transport: newForwardTransport(guard, tuning)}
+	insecureTransport := newForwardTransport(guard, tuning)
+	insecureTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	httpInsecureClient = &http.Client{Transport: insecureTransport}
 
 	// configure service HTTP router
 	pathPrefix := getPathPrefix(config)
 	router := httprouter.New()
 
+	apiRoot := pathPrefix + "/" + serviceAPIPath
+	apiV2Root := apiRoot + "/" + serviceAPIV2Path
+
 	//// Old API mapping ////
+	// deprecated in favor of the latest API
+	oldAPI := func(handler httprouter.Handle) httprouter.Handle {
+		return withRequestID(adminAllowed(withIdentity(rateLimited(deprecatedAPI(handler, pathPrefix, apiV2Root)))))
+	}
 	// basket names
-	router.GET(pathPrefix+"/"+serviceOldAPIPath, GetBaskets)
+	router.GET(pathPrefix+"/"+serviceOldAPIPath, oldAPI(GetBaskets))
 	// basket management
-	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket", GetBasket)
-	router.POST(pathPrefix+"/"+serviceOldAPIPath+"/:basket", CreateBasket)
-	router.PUT(pathPrefix+"/"+serviceOldAPIPath+"/:basket", UpdateBasket)
-	router.DELETE(pathPrefix+"/"+serviceOldAPIPath+"/:basket", DeleteBasket)
-	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket/responses/:method", GetBasketResponse)
-	router.PUT(pathPrefix+"/"+serviceOldAPIPath+"/:basket/responses/:method", UpdateBasketResponse)
+	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket", oldAPI(GetBasket))
+	router.POST(pathPrefix+"/"+serviceOldAPIPath+"/:basket", oldAPI(CreateBasket))
+	router.PUT(pathPrefix+"/"+serviceOldAPIPath+"/:basket", oldAPI(UpdateBasket))
+	router.DELETE(pathPrefix+"/"+serviceOldAPIPath+"/:basket", oldAPI(DeleteBasket))
+	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket/responses/:method", oldAPI(GetBasketResponse))
+	router.PUT(pathPrefix+"/"+serviceOldAPIPath+"/:basket/responses/:method", oldAPI(UpdateBasketResponse))
 	// requests management
-	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket/requests", GetBasketRequests)
-	router.DELETE(pathPrefix+"/"+serviceOldAPIPath+"/:basket/requests", ClearBasket)
+	router.GET(pathPrefix+"/"+serviceOldAPIPath+"/:basket/requests", oldAPI(GetBasketRequests))
+	router.DELETE(pathPrefix+"/"+serviceOldAPIPath+"/:basket/requests", oldAPI(ClearBasket))
 
-	//// New API mapping ////
-	// service details
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/stats", GetStats)
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/version", GetVersion)
-	// basket names
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets", GetBaskets)
-	// basket management
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", GetBasket)
-	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", CreateBasket)
-	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", UpdateBasket)
-	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", DeleteBasket)
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", GetBasketResponse)
-	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", UpdateBasketResponse)
-	// requests management
-	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", GetBasketRequests)
-	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", ClearBasket)
+	//// API mapping ////
+	// operations are listed in apiRoutes, the same list is used to generate OpenAPI specification;
+	// API v1 keeps its data model stable and is deprecated in favor of API v2; all operations are rate limited,
+	// identified by request ID, may be restricted to allowed networks and clients with certificates and accept
+	// identity tokens of OpenID Connect provider, credentials of LDAP users, client certificates or identity headers
+	// of trusted authentication proxy
+	for _, route := range apiRoutes {
+		if route.Dispatch {
+			continue
+		}
+		router.Handle(route.Method, apiRoot+route.Path,
+			withRequestID(adminAllowed(withIdentity(rateLimited(deprecatedAPI(route.Handler, apiRoot, apiV2Root))))))
+		router.Handle(route.Method, apiV2Root+route.Path,
+			withRequestID(adminAllowed(withIdentity(rateLimited(withAPIVersion(route.Handler, apiV2))))))
+	}
+	router.GET(apiRoot+"/openapi.json", adminAllowed(GetOpenAPISpec))
+	router.GET(apiV2Root+"/openapi.json", adminAllowed(withAPIVersion(GetOpenAPISpec, apiV2)))
+	router.GET(apiRoot+"/push/worker.js", adminAllowed(PushServiceWorker))
+	router.GET(apiRoot+"/oidc/login", adminAllowed(OIDCLogin))
+	router.GET(apiRoot+"/oidc/callback", adminAllowed(OIDCCallback))
+	router.GET(apiRoot+"/proxy/login", adminAllowed(ProxyLogin))
 
 	// web pages
-	router.GET(pathPrefix+"/", ForwardToWeb)
-	router.GET(pathPrefix+"/"+serviceUIPath, WebIndexPage)
-	router.GET(pathPrefix+"/"+serviceUIPath+"/:basket", WebBasketPage)
+	router.GET(pathPrefix+"/", adminAllowed(ForwardToWeb))
+	router.GET(pathPrefix+"/"+serviceUIPath, adminAllowed(WebIndexPage))
+	router.GET(pathPrefix+"/"+serviceUIPath+"/:basket", adminAllowed(WebBasketPage))
 	//router.ServeFiles(pathPrefix+"/"+serviceUIPath+"/*filepath", http.Dir("./web"))
 
+	// probes of load balancers and orchestrators, e.g. Kubernetes, are not restricted to allowed networks
+	router.GET(pathPrefix+"/"+serviceHealthPath, GetHealth)
+	router.GET(pathPrefix+"/"+serviceReadyPath, GetReadiness)
+
 	// basket requests
 	router.NotFound = http.HandlerFunc(AcceptBasketRequests)
 
 	log.Printf("[info] HTTP server is listening on %s:%d", serverConfig.ServerAddr, serverConfig.ServerPort)
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", serverConfig.ServerAddr, serverConfig.ServerPort),
-		Handler: corsAllow(router),
+		Handler: corsAllow(subdomainBaskets(router, config.BasketDomain)),
+	}
+	if acmeManager != nil {
+		server.TLSConfig = acmeTLSConfig(acmeManager, clientCerts)
+	} else if clientCerts != nil {
+		server.TLSConfig = clientCerts.TLSConfig()
+	}
+	if acmeManager != nil || len(config.TLSCert) > 0 {
+		if server.TLSConfig == nil {
+			server.TLSConfig = &tls.Config{}
+		}
+		hardening.Apply(server.TLSConfig, server)
 	}
 
 	go shutdownHook()
@@ -138,6 +475,7 @@ func shutdownHook() {
 	go func() {
 		sig := <-sigs
 		log.Printf("[info] received signal: %s, shutting down database", sig)
+		stopScheduler()
 		basketsDb.Release()
 		done <- true
 	}()
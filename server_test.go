@@ -18,13 +18,18 @@ func TestMain(m *testing.M) {
 }
 
 func testsSetup() {
+	// cheap hashing of basket tokens keeps tests fast
+	secretHashParams = argon2Params{Time: 1, Memory: 64, Threads: 1}
 	// global config
 	serverConfig = CreateConfig()
+	// test servers that receive forwarded requests listen on loopback addresses
+	serverConfig.ForwardAllowed = []string{"127.0.0.0/8", "::1"}
 	// global server creation with default settings (performs some global initialization)
 	testServer = CreateServer(serverConfig)
 }
 
 func testsShutdown() {
+	stopScheduler()
 	// release global DB
 	basketsDb.Release()
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Providers of webhook signatures verified by baskets
+const (
+	SignatureGitHub = "github" // X-Hub-Signature-256 header
+	SignatureStripe = "stripe" // Stripe-Signature header
+	SignatureSlack  = "slack"  // X-Slack-Signature and X-Slack-Request-Timestamp headers
+)
+
+// Results of webhook signature verification recorded with collected requests
+const (
+	SignatureVerified   = "verified"
+	SignatureUnverified = "unverified"
+)
+
+const (
+	defaultSignatureTolerance = 300   // seconds
+	maxSignatureTolerance     = 86400 // seconds
+)
+
+// SignatureCheck describes verification of signatures of webhooks collected by basket, the signing secret
+// is kept with secrets of basket and is referenced by name
+type SignatureCheck struct {
+	Provider  string `json:"provider"`            // github, stripe or slack
+	Secret    string `json:"secret"`              // name of basket secret with signing secret
+	Reject    bool   `json:"reject,omitempty"`    // reject unverified requests with 401 status instead of collecting them
+	Tolerance int    `json:"tolerance,omitempty"` // seconds, max age of signed timestamps, defaults to 5 minutes
+}
+
+// validateSignatureCheck validates webhook signature verification of basket
+func validateSignatureCheck(check *SignatureCheck) error {
+	switch check.Provider {
+	case SignatureGitHub, SignatureStripe, SignatureSlack:
+	default:
+		return fmt.Errorf("unknown signature provider: %s, expected %s, %s or %s", check.Provider, SignatureGitHub,
+			SignatureStripe, SignatureSlack)
+	}
+	if !validSecretName.MatchString(check.Secret) {
+		return fmt.Errorf("invalid secret name of signature; the name does not match pattern: %s",
+			validSecretName.String())
+	}
+	if check.Tolerance < 0 || check.Tolerance > maxSignatureTolerance {
+		return fmt.Errorf("signature tolerance should be between 0 and %d seconds, but was %d", maxSignatureTolerance,
+			check.Tolerance)
+	}
+	return nil
+}
+
+// verifySignature verifies signature of collected request with the signing secret
+func verifySignature(check *SignatureCheck, secret string, data *RequestData, now time.Time) error {
+	tolerance := time.Duration(check.Tolerance) * time.Second
+	if tolerance == 0 {
+		tolerance = defaultSignatureTolerance * time.Second
+	}
+
+	switch check.Provider {
+	case SignatureGitHub:
+		signature := data.Header.Get("X-Hub-Signature-256")
+		if !strings.HasPrefix(signature, "sha256=") {
+			return fmt.Errorf("missing X-Hub-Signature-256 header")
+		}
+		return compareSignature(strings.TrimPrefix(signature, "sha256="), secret, data.Body)
+	case SignatureStripe:
+		header := data.Header.Get("Stripe-Signature")
+		var timestamp string
+		var signatures []string
+		for _, item := range strings.Split(header, ",") {
+			if kv := strings.SplitN(strings.TrimSpace(item), "=", 2); len(kv) == 2 {
+				switch kv[0] {
+				case "t":
+					timestamp = kv[1]
+				case "v1":
+					signatures = append(signatures, kv[1])
+				}
+			}
+		}
+		if len(timestamp) == 0 || len(signatures) == 0 {
+			return fmt.Errorf("missing Stripe-Signature header")
+		}
+		if err := checkSignatureTimestamp(timestamp, now, tolerance); err != nil {
+			return err
+		}
+		for _, signature := range signatures {
+			if compareSignature(signature, secret, timestamp+"."+data.Body) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("signature mismatch")
+	case SignatureSlack:
+		signature := data.Header.Get("X-Slack-Signature")
+		timestamp := data.Header.Get("X-Slack-Request-Timestamp")
+		if !strings.HasPrefix(signature, "v0=") || len(timestamp) == 0 {
+			return fmt.Errorf("missing X-Slack-Signature or X-Slack-Request-Timestamp header")
+		}
+		if err := checkSignatureTimestamp(timestamp, now, tolerance); err != nil {
+			return err
+		}
+		return compareSignature(strings.TrimPrefix(signature, "v0="), secret, "v0:"+timestamp+":"+data.Body)
+	}
+	return fmt.Errorf("unknown signature provider: %s", check.Provider)
+}
+
+// compareSignature compares hex encoded signature with HMAC-SHA256 of payload in constant time
+func compareSignature(signature string, secret string, payload string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	if !hmac.Equal(expected, mac.Sum(nil)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// checkSignatureTimestamp checks that signed timestamp (seconds since epoch) is within tolerance, so captured
+// signatures cannot be replayed later
+func checkSignatureTimestamp(timestamp string, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signature timestamp: %s", timestamp)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside of tolerance")
+	}
+	return nil
+}
+
+// checkSignature verifies signature of request collected by basket and records the result with the request,
+// unverified request is rejected with 401 status if basket is configured so; writes HTTP response and returns
+// false in case of failure
+func checkSignature(w http.ResponseWriter, basket Basket, check *SignatureCheck, data *RequestData) bool {
+	if check == nil {
+		return true
+	}
+
+	secret, exists := basket.GetSecrets()[check.Secret]
+	err := fmt.Errorf("signing secret is not found: %s", check.Secret)
+	if exists {
+		err = verifySignature(check, secret, data, time.Now())
+	}
+	if err == nil {
+		data.Signature = SignatureVerified
+		return true
+	}
+
+	if check.Reject {
+		http.Error(w, "invalid webhook signature: "+err.Error(), http.StatusUnauthorized)
+		return false
+	}
+	data.Signature = SignatureUnverified
+	data.SignatureError = err.Error()
+	return true
+}
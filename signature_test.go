@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hmacHex(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateSignatureCheck(t *testing.T) {
+	assert.NoError(t, validateSignatureCheck(&SignatureCheck{Provider: SignatureGitHub, Secret: "HOOK_SECRET"}))
+	assert.NoError(t, validateSignatureCheck(&SignatureCheck{Provider: SignatureSlack, Secret: "SLACK", Tolerance: 60}))
+	assert.Error(t, validateSignatureCheck(&SignatureCheck{Provider: "gitlab", Secret: "HOOK_SECRET"}),
+		"unknown provider is not expected")
+	assert.Error(t, validateSignatureCheck(&SignatureCheck{Provider: SignatureStripe}), "secret name is expected")
+	assert.Error(t, validateSignatureCheck(&SignatureCheck{Provider: SignatureStripe, Secret: "HOOK_SECRET",
+		Tolerance: -1}), "negative tolerance is not expected")
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	old := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	body := `{"event":"push"}`
+
+	github := &SignatureCheck{Provider: SignatureGitHub, Secret: "S"}
+	data := &RequestData{Header: http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("secret", body)}}, Body: body}
+	assert.NoError(t, verifySignature(github, "secret", data, now))
+	assert.Error(t, verifySignature(github, "other", data, now), "wrong secret is not expected to verify")
+	assert.Error(t, verifySignature(github, "secret", &RequestData{Header: http.Header{}, Body: body}, now),
+		"missing signature is not expected to verify")
+
+	stripe := &SignatureCheck{Provider: SignatureStripe, Secret: "S"}
+	data = &RequestData{Header: http.Header{"Stripe-Signature": {"t=" + ts + ",v1=00ff,v1=" +
+		hmacHex("secret", ts+"."+body)}}, Body: body}
+	assert.NoError(t, verifySignature(stripe, "secret", data, now))
+	data.Header.Set("Stripe-Signature", "t="+old+",v1="+hmacHex("secret", old+"."+body))
+	assert.Error(t, verifySignature(stripe, "secret", data, now), "outdated signature is not expected to verify")
+	assert.NoError(t, verifySignature(&SignatureCheck{Provider: SignatureStripe, Secret: "S", Tolerance: 7200},
+		"secret", data, now), "signature within tolerance is expected to verify")
+
+	slack := &SignatureCheck{Provider: SignatureSlack, Secret: "S"}
+	data = &RequestData{Header: http.Header{"X-Slack-Request-Timestamp": {ts},
+		"X-Slack-Signature": {"v0=" + hmacHex("secret", "v0:"+ts+":"+body)}}, Body: body}
+	assert.NoError(t, verifySignature(slack, "secret", data, now))
+	data.Body = `{"event":"pull"}`
+	assert.Error(t, verifySignature(slack, "secret", data, now), "modified body is not expected to verify")
+}
+
+func TestAcceptBasketRequests_Signature(t *testing.T) {
+	call := func(method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "signature01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).SetSecret("HOOK_SECRET", "secret")
+	authorization := http.Header{"Authorization": {auth.Token}}
+	assert.Equal(t, 422, call("PUT", "/api/baskets/"+basket,
+		`{"capacity": 20, "signature": {"provider": "gitlab", "secret": "HOOK_SECRET"}}`, authorization).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket,
+		`{"capacity": 20, "signature": {"provider": "github", "secret": "HOOK_SECRET"}}`, authorization).Code,
+		"wrong HTTP result code")
+
+	body := `{"event":"push"}`
+	signed := http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("secret", body)}}
+	assert.Equal(t, 200, call("POST", "/"+basket, body, signed).Code, "wrong HTTP result code")
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.Equal(t, SignatureVerified, page.Requests[0].Signature, "request is expected to be verified")
+	}
+
+	// unverified requests are collected and marked
+	assert.Equal(t, 200, call("POST", "/"+basket, "forged", signed).Code, "wrong HTTP result code")
+	page = basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.Equal(t, SignatureUnverified, page.Requests[0].Signature, "request is not expected to be verified")
+		assert.Equal(t, "signature mismatch", page.Requests[0].SignatureError, "wrong verification error")
+	}
+
+	// unverified requests are rejected
+	assert.Equal(t, 204, call("PUT", "/api/baskets/"+basket,
+		`{"capacity": 20, "signature": {"provider": "github", "secret": "HOOK_SECRET", "reject": true}}`,
+		authorization).Code, "wrong HTTP result code")
+	assert.Equal(t, 401, call("POST", "/"+basket, "forged", signed).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("POST", "/"+basket, body, signed).Code, "wrong HTTP result code")
+	assert.Equal(t, 3, basketsDb.Get(basket).Size(), "rejected request is not expected to be collected")
+}
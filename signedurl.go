@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureParam is the query parameter that carries signature of signed URL to service API
+const SignatureParam = "signature"
+
+const (
+	signedTokenPrefix    = "sig_"
+	defaultSignedURLTTL  = 24 * time.Hour
+	maxSignedURLTTL      = 30 * 24 * time.Hour
+	signedURLKeyCategory = "signed-url"
+)
+
+// SignedURLRequest describes settings of a new signed URL
+type SignedURLRequest struct {
+	ExpiresIn int64 `json:"expires_in"` // lifetime of URL in seconds, 1 day if not defined
+}
+
+// SignedURL describes time-limited URLs that grant read-only access to basket without its token
+type SignedURL struct {
+	URL       string `json:"url"`     // web view of basket
+	APIURL    string `json:"api_url"` // collected requests of basket at service API
+	Signature string `json:"signature"`
+	Expires   int64  `json:"expires"`
+}
+
+// signBasket issues signature of read-only access to basket until expiration date in milliseconds since epoch,
+// signatures are bound to basket name and signed with the master token, so they are invalidated once the basket
+// is renamed or the master token is changed
+func signBasket(name string, expires int64) string {
+	payload := strconv.FormatInt(expires, 10)
+	return signedTokenPrefix + payload + "." + signBasketPayload(name, payload)
+}
+
+// verifyBasketSignature checks if signature grants access to basket at given time
+func verifyBasketSignature(name string, signature string, now time.Time) bool {
+	if !strings.HasPrefix(signature, signedTokenPrefix) {
+		return false
+	}
+
+	parts := strings.SplitN(signature[len(signedTokenPrefix):], ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(signBasketPayload(name, parts[0]))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	return err == nil && now.UnixNano()/toMs <= expires
+}
+
+// newSignedURL issues signed URLs of basket for the client of HTTP request
+func newSignedURL(r *http.Request, name string, expires int64) SignedURL {
+	signature := signBasket(name, expires)
+	base := getBaseURL(r) + serverConfig.PathPrefix
+	return SignedURL{
+		URL:       base + "/" + serviceUIPath + "/" + name + "?share=" + signature,
+		APIURL:    base + "/" + serviceAPIPath + "/baskets/" + name + "/requests?" + SignatureParam + "=" + signature,
+		Signature: signature,
+		Expires:   expires}
+}
+
+func signBasketPayload(name string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(serverConfig.MasterToken))
+	mac.Write([]byte(signedURLKeyCategory + "\n" + name + "\n" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isSignatureAuthorized checks if HTTP request presents valid signature of read-only access to basket,
+// either with the token or with signature query parameter
+func isSignatureAuthorized(r *http.Request, name string, scope string) bool {
+	if scope != ScopeRead {
+		return false
+	}
+
+	now := time.Now()
+	return verifyBasketSignature(name, r.Header.Get("Authorization"), now) ||
+		verifyBasketSignature(name, r.URL.Query().Get(SignatureParam), now)
+}
+
+// validateSignedURLRequest validates lifetime of a new signed URL and returns the lifetime
+func validateSignedURLRequest(request SignedURLRequest) (time.Duration, error) {
+	if request.ExpiresIn == 0 {
+		return defaultSignedURLTTL, nil
+	}
+
+	max := int64(maxSignedURLTTL / time.Second)
+	if request.ExpiresIn < 0 || request.ExpiresIn > max {
+		return 0, fmt.Errorf("lifetime of signed URL should be between 1 and %d seconds", max)
+	}
+	return time.Duration(request.ExpiresIn) * time.Second, nil
+}
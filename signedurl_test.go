@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyBasketSignature(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour).UnixNano() / toMs
+	signature := signBasket("signed01", expires)
+	assert.True(t, strings.HasPrefix(signature, signedTokenPrefix), "signature prefix is expected")
+
+	assert.True(t, verifyBasketSignature("signed01", signature, now), "valid signature is expected")
+	assert.True(t, verifyBasketSignature("signed01", signature, time.Unix(0, expires*toMs)), "signature is expected to expire after date")
+	assert.False(t, verifyBasketSignature("signed01", signature, now.Add(time.Hour+time.Second)), "expired signature is not expected")
+	assert.False(t, verifyBasketSignature("signed02", signature, now), "signature of other basket is not expected")
+	assert.False(t, verifyBasketSignature("signed01", signature+"x", now), "modified signature is not expected")
+	assert.False(t, verifyBasketSignature("signed01", strings.Replace(signature, "_1", "_2", 1), now),
+		"modified expiration is not expected")
+	assert.False(t, verifyBasketSignature("signed01", "", now), "empty signature is not expected")
+}
+
+func TestValidateSignedURLRequest(t *testing.T) {
+	ttl, err := validateSignedURLRequest(SignedURLRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, defaultSignedURLTTL, ttl, "default lifetime is expected")
+	}
+	ttl, err = validateSignedURLRequest(SignedURLRequest{ExpiresIn: 600})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 10*time.Minute, ttl, "wrong lifetime")
+	}
+	_, err = validateSignedURLRequest(SignedURLRequest{ExpiresIn: -1})
+	assert.Error(t, err, "negative lifetime is not expected")
+	_, err = validateSignedURLRequest(SignedURLRequest{ExpiresIn: int64(maxSignedURLTTL/time.Second) + 1})
+	assert.Error(t, err, "lifetime is expected to be limited")
+}
+
+func TestSignBasketURL(t *testing.T) {
+	basket := "signed03"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	AcceptBasketRequests(httptest.NewRecorder(), createTestPOSTRequest("http://localhost:55555/"+basket+"/path", "data", "text/plain"))
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	path := "/api/baskets/" + basket
+	assert.Equal(t, 401, call("POST", path+"/signed-url", "wrong", "").Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("POST", path+"/signed-url", auth.Token, `{"expires_in": -5}`).Code, "wrong HTTP result code")
+	assert.Equal(t, 400, call("POST", path+"/signed-url", auth.Token, `{`).Code, "wrong HTTP result code")
+
+	w := call("POST", path+"/signed-url", auth.Token, `{"expires_in": 3600}`)
+	if !assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		return
+	}
+	signed := SignedURL{}
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &signed)) {
+		return
+	}
+	assert.Equal(t, "http://localhost:55555/web/"+basket+"?share="+signed.Signature, signed.URL, "wrong URL of web view")
+	assert.Equal(t, "http://localhost:55555"+path+"/requests?signature="+signed.Signature, signed.APIURL, "wrong API URL")
+	assert.InDelta(t, time.Now().Add(time.Hour).UnixNano()/toMs, signed.Expires, 5000, "wrong expiration")
+
+	// signed URLs grant read-only access without token
+	w = call("GET", strings.TrimPrefix(signed.APIURL, "http://localhost:55555"), "", "")
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		assert.Contains(t, w.Body.String(), `"count":1`, "collected request is expected")
+	}
+	assert.Equal(t, 200, call("GET", path+"/requests", signed.Signature, "").Code, "signature is expected as token")
+	assert.Equal(t, 401, call("GET", path, signed.Signature, "").Code, "settings are not expected to be accessible")
+	assert.Equal(t, 401, call("DELETE", path+"/requests?signature="+signed.Signature, "", "").Code,
+		"requests are not expected to be deleted")
+	assert.Equal(t, 401, call("POST", path+"/signed-url", signed.Signature, "").Code,
+		"signed URL is not expected to be issued with signature")
+	if _, err = basketsDb.Create("signed04", BasketConfig{Capacity: 20}); assert.NoError(t, err) {
+		assert.Equal(t, 401, call("GET", "/api/baskets/signed04/requests?signature="+signed.Signature, "", "").Code,
+			"other basket is not expected to be accessible")
+	}
+
+	// expired URL
+	expired := signBasket(basket, time.Now().Add(-time.Second).UnixNano()/toMs)
+	assert.Equal(t, 401, call("GET", path+"/requests?signature="+expired, "", "").Code, "expired URL is not expected")
+
+	// issued URLs are recorded in audit log
+	page := audit.Find(AuditQuery{Basket: basket, Action: AuditTokenSign}, 10, 0)
+	assert.Len(t, page.Entries, 1, "audit entry is expected")
+}
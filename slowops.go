@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of operations that are watched for slowness
+const (
+	SlowForward = "forward"
+	SlowScript  = "script"
+	SlowStorage = "storage"
+)
+
+const (
+	defaultSlowForward = 5000 // ms
+	defaultSlowScript  = 1000 // ms
+	defaultSlowStorage = 100  // ms
+)
+
+var slowOps = newSlowOperations(defaultSlowForward, defaultSlowScript, defaultSlowStorage)
+
+// SlowOperationStats describes operations of a basket that exceeded latency threshold of their kind
+type SlowOperationStats struct {
+	Basket     string  `json:"basket"`
+	Kind       string  `json:"kind"`
+	Count      int     `json:"count"`
+	MaxLatency float64 `json:"max_latency_ms"`
+	Threshold  float64 `json:"threshold_ms"`
+	LastDetail string  `json:"last_detail,omitempty"` // e.g. forward URL, script or storage operation
+	LastDate   int64   `json:"last_date"`
+}
+
+// slowOperations logs and counts forwards, script executions and storage operations that exceed configurable
+// latency thresholds, the statistics are kept in memory and are reset on service restart
+type slowOperations struct {
+	sync.RWMutex
+	thresholds map[string]time.Duration // 0 - operations of the kind are not watched
+	entries    map[string]map[string]*SlowOperationStats
+}
+
+// newSlowOperations creates registry of slow operations with thresholds in milliseconds, 0 disables warnings
+func newSlowOperations(forward int, script int, storage int) *slowOperations {
+	return &slowOperations{
+		thresholds: map[string]time.Duration{
+			SlowForward: time.Duration(forward) * time.Millisecond,
+			SlowScript:  time.Duration(script) * time.Millisecond,
+			SlowStorage: time.Duration(storage) * time.Millisecond},
+		entries: make(map[string]map[string]*SlowOperationStats)}
+}
+
+// Observe records operation of a basket if it exceeds latency threshold of its kind
+func (s *slowOperations) Observe(kind string, basket string, detail string, latency time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	threshold := s.thresholds[kind]
+	if threshold <= 0 || latency < threshold {
+		return
+	}
+	log.Printf("[warn] slow %s operation of basket: %s took %s, threshold is %s - %s", kind, basket,
+		latency.Round(time.Millisecond), threshold, detail)
+	metrics.Count("operations.slow", 1, "basket:"+basket, "kind:"+kind)
+
+	kinds, exists := s.entries[basket]
+	if !exists {
+		kinds = make(map[string]*SlowOperationStats)
+		s.entries[basket] = kinds
+	}
+	entry, exists := kinds[kind]
+	if !exists {
+		entry = &SlowOperationStats{Basket: basket, Kind: kind}
+		kinds[kind] = entry
+	}
+
+	ms := float64(latency.Microseconds()) / 1000
+	entry.Count++
+	entry.Threshold = float64(threshold.Microseconds()) / 1000
+	entry.LastDetail = detail
+	entry.LastDate = time.Now().UnixNano() / toMs
+	if ms > entry.MaxLatency {
+		entry.MaxLatency = ms
+	}
+}
+
+// Remove drops collected statistics of a basket
+func (s *slowOperations) Remove(basket string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.entries, basket)
+}
+
+// Rename moves collected statistics of a basket under the new basket name
+func (s *slowOperations) Rename(basket string, newName string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if kinds, exists := s.entries[basket]; exists {
+		for _, entry := range kinds {
+			entry.Basket = newName
+		}
+		s.entries[newName] = kinds
+		delete(s.entries, basket)
+	}
+}
+
+// CollectTo updates database statistics with baskets that have the slowest operations
+func (s *slowOperations) CollectTo(stats *DatabaseStats, max int) {
+	s.RLock()
+	slow := make([]*SlowOperationStats, 0)
+	for _, kinds := range s.entries {
+		for _, entry := range kinds {
+			copied := *entry
+			slow = append(slow, &copied)
+		}
+	}
+	s.RUnlock()
+
+	sort.Slice(slow, func(i, j int) bool {
+		return slow[i].MaxLatency > slow[j].MaxLatency ||
+			(slow[i].MaxLatency == slow[j].MaxLatency && slow[i].Count > slow[j].Count)
+	})
+	if len(slow) > max {
+		slow = slow[:max]
+	}
+	if len(slow) > 0 {
+		stats.TopSlowOperations = slow
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowOperations_Observe(t *testing.T) {
+	s := newSlowOperations(100, 50, 0)
+	s.Observe(SlowForward, "slow01", "GET /fast", 20*time.Millisecond)
+	s.Observe(SlowForward, "slow01", "POST /orders", 150*time.Millisecond)
+	s.Observe(SlowForward, "slow01", "POST /orders", 120*time.Millisecond)
+	s.Observe(SlowScript, "slow02", "trigger", 60*time.Millisecond)
+	s.Observe(SlowStorage, "slow02", "add request", time.Second)
+
+	stats := DatabaseStats{}
+	s.CollectTo(&stats, 5)
+	if assert.Len(t, stats.TopSlowOperations, 2, "wrong number of slow operations") {
+		forward := stats.TopSlowOperations[0]
+		assert.Equal(t, "slow01", forward.Basket, "wrong basket")
+		assert.Equal(t, SlowForward, forward.Kind, "wrong kind")
+		assert.Equal(t, 2, forward.Count, "wrong count")
+		assert.Equal(t, 150.0, forward.MaxLatency, "wrong max latency")
+		assert.Equal(t, 100.0, forward.Threshold, "wrong threshold")
+		assert.Equal(t, "POST /orders", forward.LastDetail, "wrong detail")
+
+		assert.Equal(t, SlowScript, stats.TopSlowOperations[1].Kind, "wrong kind")
+	}
+
+	stats = DatabaseStats{}
+	s.CollectTo(&stats, 1)
+	assert.Len(t, stats.TopSlowOperations, 1, "number of slow operations is expected to be limited")
+}
+
+func TestSlowOperations_Rename(t *testing.T) {
+	s := newSlowOperations(100, 100, 100)
+	s.Observe(SlowStorage, "slow03", "add request", time.Second)
+	s.Rename("slow03", "slow04")
+
+	stats := DatabaseStats{}
+	s.CollectTo(&stats, 5)
+	if assert.Len(t, stats.TopSlowOperations, 1, "wrong number of slow operations") {
+		assert.Equal(t, "slow04", stats.TopSlowOperations[0].Basket, "wrong basket")
+	}
+
+	s.Remove("slow04")
+	stats = DatabaseStats{}
+	s.CollectTo(&stats, 5)
+	assert.Nil(t, stats.TopSlowOperations, "slow operations are not expected")
+}
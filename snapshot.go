@@ -0,0 +1,108 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	snapshotVersion = 1
+	snapshotMaxSize = 64 * 1024 * 1024 // maximum size of snapshot in bytes, both compressed and uncompressed
+)
+
+// BasketSnapshot describes header of basket snapshot. Snapshot is a gzip compressed stream of JSON documents:
+// the header is followed by collected requests from newest to oldest, so a snapshot of large basket is written
+// without loading the whole basket at once.
+type BasketSnapshot struct {
+	Version  int        `json:"version"`
+	Name     string     `json:"name"`
+	Date     int64      `json:"date"` // date of snapshot in milliseconds
+	Spec     BasketSpec `json:"spec"`
+	Requests int        `json:"requests"` // number of requests in snapshot
+}
+
+// BasketSnapshotRestore describes result of restoring basket from snapshot.
+type BasketSnapshotRestore struct {
+	Token    string `json:"token,omitempty"` // token of basket created from snapshot
+	Requests int    `json:"requests"`        // number of restored requests
+}
+
+// WriteBasketSnapshot writes snapshot of basket setup and collected requests; webhook secrets are masked and
+// secrets of scripts are not included, see BasketSpec
+func WriteBasketSnapshot(w io.Writer, name string, basket Basket, now time.Time) error {
+	zw := gzip.NewWriter(w)
+	encoder := json.NewEncoder(zw)
+
+	header := BasketSnapshot{Version: snapshotVersion, Name: name, Date: now.UnixNano() / toMs,
+		Spec: ExportBasketSpec(name, basket), Requests: basket.Size()}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	err := StreamRequests(basket, nil, exportPageSize, func(page []*RequestData) error {
+		for _, request := range page {
+			if err := encoder.Encode(request); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadBasketSnapshot reads snapshot of basket, requests are returned from newest to oldest
+func ReadBasketSnapshot(r io.Reader) (*BasketSnapshot, []*RequestData, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid snapshot: %s", err)
+	}
+	defer zr.Close()
+
+	decoder := json.NewDecoder(io.LimitReader(zr, snapshotMaxSize))
+	header := new(BasketSnapshot)
+	if err = decoder.Decode(header); err != nil {
+		return nil, nil, fmt.Errorf("invalid snapshot header: %s", err)
+	}
+	if header.Version != snapshotVersion {
+		return nil, nil, fmt.Errorf("unsupported snapshot version: %d, expected %d", header.Version, snapshotVersion)
+	}
+
+	// number of requests in header is not trusted to reserve memory
+	requests := make([]*RequestData, 0, pageCapacity(header.Requests))
+	for {
+		request := new(RequestData)
+		if err = decoder.Decode(request); err == io.EOF {
+			return header, requests, nil
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("invalid request %d of snapshot: %s", len(requests), err)
+		}
+		requests = append(requests, request)
+	}
+}
+
+// RestoreBasketRequests replaces collected requests of basket with requests of snapshot, requests that do not
+// fit into capacity of basket are skipped; requests are stored the same way as imported requests, so they are
+// redacted, encrypted and linked into hash chain according to the basket configuration, while their dates are kept;
+// returns number of restored requests, restore stops at the first request that cannot be stored
+func RestoreBasketRequests(name string, basket Basket, requests []*RequestData) (int, error) {
+	basket.Clear()
+
+	config := basket.Config()
+	count := len(requests)
+	if count > config.Capacity {
+		count = config.Capacity
+	}
+	// requests are added from oldest to newest
+	for i := count - 1; i >= 0; i-- {
+		if _, err := importRequest(name, basket, config, requests[i]); err != nil {
+			return count - 1 - i, err
+		}
+	}
+	return count, nil
+}
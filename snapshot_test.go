@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasketSnapshot(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("snapshot01", BasketConfig{Capacity: 20, ForwardURL: "http://localhost:8080/api"})
+	basket := db.Get("snapshot01")
+	basket.SetResponse("GET", ResponseConfig{Status: 201, Body: "created"})
+	for _, path := range []string{"/one", "/two", "/three"} {
+		basket.AddRequest(&RequestData{Date: 1000, Method: "POST", Path: "/snapshot01" + path, Body: path})
+	}
+
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, WriteBasketSnapshot(buf, "snapshot01", basket, time.Now())) {
+		return
+	}
+
+	snapshot, requests, err := ReadBasketSnapshot(bytes.NewReader(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "snapshot01", snapshot.Name, "wrong name of basket")
+	assert.Equal(t, "http://localhost:8080/api", snapshot.Spec.Config.ForwardURL, "wrong configuration of basket")
+	assert.Equal(t, "created", snapshot.Spec.Responses["GET"].Body, "wrong response of basket")
+	if assert.Len(t, requests, 3, "wrong number of requests") {
+		assert.Equal(t, "/three", requests[0].Body, "newest request is expected first")
+	}
+
+	// restore into smaller basket
+	db.Create("snapshot02", BasketConfig{Capacity: 2})
+	target := db.Get("snapshot02")
+	target.AddRequest(&RequestData{Date: 2000, Method: "GET", Path: "/snapshot02"})
+	restored, err := RestoreBasketRequests("snapshot02", target, requests)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, restored, "wrong number of restored requests")
+	}
+	page := target.GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 2, "wrong number of requests") {
+		assert.Equal(t, "/three", page.Requests[0].Body, "wrong newest request")
+		assert.Equal(t, "/two", page.Requests[1].Body, "wrong oldest request")
+	}
+}
+
+func TestRestoreBasketRequests_Pipeline(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("snapshot07", BasketConfig{Capacity: 20, HashChain: true, Redaction: []RedactionRule{{Header: "X-Token"}}})
+	basket := db.Get("snapshot07")
+	requests := []*RequestData{
+		{Date: 2000, Method: "POST", Path: "/snapshot07/two", Header: http.Header{"X-Token": {"abc"}}},
+		{Date: 1000, Method: "POST", Path: "/snapshot07/one", Header: http.Header{"X-Token": {"abc"}}}}
+
+	restored, err := RestoreBasketRequests("snapshot07", basket, requests)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, restored, "wrong number of restored requests")
+	}
+	for _, req := range basket.GetRequests(10, 0).Requests {
+		assert.Equal(t, redactedValue, req.Header.Get("X-Token"), "restored request is expected to be redacted")
+		assert.NotEmpty(t, req.Hash, "restored request is expected to be linked into hash chain")
+	}
+	assert.True(t, verifyChain(basket).Verified, "hash chain is expected to be verified")
+
+	// restored requests keep their dates and are found by date after newer requests are collected
+	basket.Add(createTestPOSTRequest("http://localhost/snapshot07", "new", "text/plain"))
+	page := basket.FindRequests(&RequestsQuery{From: 500, To: 1500}, 10, 0)
+	if assert.Len(t, page.Requests, 1, "restored request is expected to be found by date") {
+		assert.Equal(t, "/snapshot07/one", page.Requests[0].Path, "wrong found request")
+	}
+}
+
+func TestReadBasketSnapshot_Invalid(t *testing.T) {
+	_, _, err := ReadBasketSnapshot(strings.NewReader(`{"version": 1}`))
+	assert.Error(t, err, "compressed snapshot is expected")
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	zw.Write([]byte(`{"version": 2, "name": "snapshot"}`))
+	zw.Close()
+	_, _, err = ReadBasketSnapshot(bytes.NewReader(buf.Bytes()))
+	assert.Error(t, err, "unknown version is not expected")
+
+	// number of requests in header does not reserve memory
+	for _, count := range []string{"-1", "2000000000"} {
+		buf.Reset()
+		zw = gzip.NewWriter(buf)
+		zw.Write([]byte(`{"version": 1, "name": "snapshot", "requests": ` + count + `}` + "\n" + `{"method": "GET"}`))
+		zw.Close()
+		_, requests, err := ReadBasketSnapshot(bytes.NewReader(buf.Bytes()))
+		if assert.NoError(t, err, "wrong number of requests in header is not expected to fail") {
+			assert.Len(t, requests, 1, "wrong number of requests")
+		}
+	}
+}
+
+func TestRestoreBasketSnapshot(t *testing.T) {
+	call := func(method string, url string, token string, body []byte) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api/baskets/"+url, bytes.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	source := "snapshot03"
+	auth, err := basketsDb.Create(source, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(source).AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/" + source, Body: "hello"})
+
+	w := call("GET", source+"/snapshot", auth.Token, nil)
+	if !assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		return
+	}
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"), "wrong content type")
+	snapshot := w.Body.Bytes()
+
+	// new basket is created from snapshot
+	w = call("PUT", "snapshot04/snapshot", serverConfig.MasterToken, snapshot)
+	if assert.Equal(t, 201, w.Code, "wrong HTTP result code") {
+		result := BasketSnapshotRestore{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		assert.NotEmpty(t, result.Token, "token of created basket is expected")
+		assert.Equal(t, 1, result.Requests, "wrong number of restored requests")
+	}
+	if basket := basketsDb.Get("snapshot04"); assert.NotNil(t, basket, "restored basket is expected") {
+		assert.Equal(t, "hello", basket.GetRequests(1, 0).Requests[0].Body, "wrong restored request")
+	}
+
+	// existing basket is replaced with snapshot
+	basketsDb.Get(source).Clear()
+	assert.Equal(t, 401, call("PUT", source+"/snapshot", "wrong", snapshot).Code, "wrong HTTP result code")
+	assert.Equal(t, 200, call("PUT", source+"/snapshot", auth.Token, snapshot).Code, "wrong HTTP result code")
+	assert.Equal(t, 1, basketsDb.Get(source).Size(), "requests are expected to be restored")
+
+	assert.Equal(t, 400, call("PUT", source+"/snapshot", auth.Token, []byte("invalid")).Code, "wrong HTTP result code")
+}
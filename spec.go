@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of basket specifications
+const (
+	SpecFormatJSON = "json"
+	SpecFormatYAML = "yaml"
+)
+
+const (
+	maxBasketSpecSize  = 1024 * 1024
+	maxBasketsSpecSize = 16 * 1024 * 1024
+	specNamesPageSize  = 100
+)
+
+// BasketSpec describes basket setup without collected requests, so the setup can be kept under version control
+// and applied to another service instance. Webhook secrets are masked and secrets of scripts are never exported.
+type BasketSpec struct {
+	Name      string                    `json:"name,omitempty"` // ignored if basket name is defined by request path
+	Config    BasketConfig              `json:"config"`
+	Responses map[string]ResponseConfig `json:"responses,omitempty"` // responses by HTTP method, default if absent
+	Trigger   *TriggerConfig            `json:"trigger,omitempty"`
+	Schedules []ScheduleConfig          `json:"schedules,omitempty"`
+	Webhooks  []WebhookConfig           `json:"webhooks,omitempty"`
+}
+
+// BasketsSpec describes setup of several baskets.
+type BasketsSpec struct {
+	Baskets []BasketSpec `json:"baskets"`
+}
+
+// BasketsSpecResult describes result of applying setup of several baskets.
+type BasketsSpecResult struct {
+	Created map[string]string `json:"created"` // tokens of created baskets by basket name
+	Updated []string          `json:"updated"`
+}
+
+// isDefaultResponse checks if response configuration does not differ from default response
+func isDefaultResponse(response *ResponseConfig) bool {
+	return response.Status == defaultResponse.Status && len(response.Headers) == 0 && len(response.Body) == 0 &&
+		!response.IsTemplate && !response.IsScript
+}
+
+// ExportBasketSpec describes setup of the basket
+func ExportBasketSpec(name string, basket Basket) BasketSpec {
+	spec := BasketSpec{Name: name, Config: basket.Config(), Responses: make(map[string]ResponseConfig)}
+	for _, method := range httpMethods {
+		if response := basket.GetResponse(method); response != nil && !isDefaultResponse(response) {
+			spec.Responses[method] = *response
+		}
+	}
+	if trigger := basket.GetTrigger(); trigger != nil && len(trigger.Script) > 0 {
+		spec.Trigger = trigger
+	}
+	spec.Schedules = basket.GetSchedules()
+	spec.Webhooks = maskWebhookSecrets(basket.GetWebhooks())
+
+	return spec
+}
+
+// ExportBasketsSpec describes setup of all baskets
+func ExportBasketsSpec(db BasketsDatabase) BasketsSpec {
+	spec := BasketsSpec{Baskets: make([]BasketSpec, 0, db.Size())}
+	page := db.GetNames(specNamesPageSize, 0)
+	for {
+		for _, name := range page.Names {
+			if basket := db.Get(name); basket != nil {
+				spec.Baskets = append(spec.Baskets, ExportBasketSpec(name, basket))
+			}
+		}
+
+		if !page.HasMore {
+			return spec
+		}
+		position, err := DecodeCursor(page.NextCursor)
+		if err != nil {
+			return spec
+		}
+		page = db.GetNamesAfter(position, specNamesPageSize)
+	}
+}
+
+// validateBasketSpec validates basket setup and applies defaults to missing values
+func validateBasketSpec(spec *BasketSpec) error {
+	if spec.Config.Capacity == 0 {
+		spec.Config.Capacity = serverConfig.InitCapacity
+	}
+	if err := validateBasketConfig(&spec.Config); err != nil {
+		return err
+	}
+
+	responses := make(map[string]ResponseConfig, len(spec.Responses))
+	for method, response := range spec.Responses {
+		valid := false
+		for _, m := range httpMethods {
+			valid = valid || m == method
+		}
+		if !valid {
+			return fmt.Errorf("unknown HTTP method: %s", method)
+		}
+
+		if response.Status == 0 {
+			response.Status = defaultResponse.Status
+		}
+		if err := validateResponseConfig(&response); err != nil {
+			return fmt.Errorf("response to %s: %s", method, err)
+		}
+		responses[method] = response
+	}
+	spec.Responses = responses
+
+	if spec.Trigger != nil {
+		if err := validateTriggerConfig(spec.Trigger); err != nil {
+			return err
+		}
+	}
+	if err := validateSchedules(spec.Schedules); err != nil {
+		return err
+	}
+	return validateWebhooks(spec.Webhooks)
+}
+
+// ApplyBasketSpec replaces setup of the basket with validated specification, collected requests and secrets
+// of scripts are preserved
+func ApplyBasketSpec(name string, basket Basket, spec BasketSpec) {
+	basket.Update(spec.Config)
+
+	for _, method := range httpMethods {
+		if response, ok := spec.Responses[method]; ok {
+			basket.SetResponse(method, response)
+		} else if basket.GetResponse(method) != nil {
+			basket.SetResponse(method, defaultResponse)
+		}
+	}
+
+	trigger := TriggerConfig{}
+	if spec.Trigger != nil {
+		trigger = *spec.Trigger
+	}
+	basket.SetTrigger(trigger)
+
+	schedules := spec.Schedules
+	if schedules == nil {
+		schedules = []ScheduleConfig{}
+	}
+	basket.SetSchedules(schedules)
+	scheduler.Register(name, schedules)
+
+	subscriptions := spec.Webhooks
+	if subscriptions == nil {
+		subscriptions = []WebhookConfig{}
+	}
+	keepWebhookSecrets(subscriptions, basket.GetWebhooks())
+	basket.SetWebhooks(subscriptions)
+}
+
+// encodeSpec encodes specification in given format, YAML document uses the same field names as JSON
+func encodeSpec(spec interface{}, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil || format != SpecFormatYAML {
+		return data, err
+	}
+
+	var doc interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// decodeSpec decodes specification in JSON or YAML format, unknown fields are rejected to reveal typos
+func decodeSpec(data []byte, spec interface{}) error {
+	// JSON document is valid YAML as well
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("invalid spec: %s", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(spec)
+}
+
+// writeSpec writes specification in format requested by HTTP request, JSON is used by default
+func writeSpec(w http.ResponseWriter, r *http.Request, spec interface{}) {
+	format := r.URL.Query().Get("format")
+	contentType := "application/json; charset=UTF-8"
+	switch format {
+	case "", SpecFormatJSON:
+	case SpecFormatYAML:
+		contentType = "application/yaml; charset=UTF-8"
+	default:
+		httpError(w, "unsupported spec format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	data, err := encodeSpec(spec, format)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportBasketSpec(t *testing.T) {
+	name := "spec01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{ForwardURL: "http://localhost:12345/target", Capacity: 30})
+	basket := db.Get(name)
+	basket.Add(createTestPOSTRequest("http://localhost/"+name, "collected", "text/plain"))
+	basket.SetResponse("GET", ResponseConfig{Status: 202, Body: "accepted", Headers: http.Header{"X-Test": {"1"}}})
+	basket.SetResponse("PUT", defaultResponse)
+	basket.SetTrigger(TriggerConfig{Script: "log('received')"})
+	basket.SetSchedules([]ScheduleConfig{{Name: "ping", Cron: "* * * * *", Script: "log('ping')"}})
+	basket.SetWebhooks([]WebhookConfig{{URL: "http://localhost/hook", Secret: "s3cr3t"}})
+	basket.SetSecret("api_key", "xyz")
+
+	spec := ExportBasketSpec(name, basket)
+	assert.Equal(t, name, spec.Name, "wrong basket name")
+	assert.Equal(t, 30, spec.Config.Capacity, "wrong capacity")
+	assert.Len(t, spec.Responses, 1, "only customized responses are expected")
+	assert.Equal(t, 202, spec.Responses["GET"].Status, "wrong response status")
+	if assert.NotNil(t, spec.Trigger, "trigger is expected") {
+		assert.Equal(t, "log('received')", spec.Trigger.Script, "wrong trigger script")
+	}
+	assert.Len(t, spec.Schedules, 1, "schedule is expected")
+	if assert.Len(t, spec.Webhooks, 1, "webhook is expected") {
+		assert.Equal(t, secretMask, spec.Webhooks[0].Secret, "webhook secret is expected to be masked")
+	}
+
+	data, err := encodeSpec(spec, SpecFormatYAML)
+	if assert.NoError(t, err) {
+		yaml := string(data)
+		assert.Contains(t, yaml, "forward_url: http://localhost:12345/target", "JSON field names are expected")
+		assert.Contains(t, yaml, "X-Test:", "response headers are expected")
+		assert.NotContains(t, yaml, "collected", "collected requests are not expected")
+		assert.NotContains(t, yaml, "xyz", "secrets are not expected")
+		assert.NotContains(t, yaml, "s3cr3t", "webhook secrets are not expected")
+
+		// round trip
+		decoded := BasketSpec{}
+		if assert.NoError(t, decodeSpec(data, &decoded)) {
+			assert.Equal(t, spec, decoded, "the same spec is expected")
+		}
+	}
+}
+
+func TestDecodeSpec(t *testing.T) {
+	spec := BasketSpec{}
+	assert.NoError(t, decodeSpec([]byte(`{"name": "spec02", "config": {"capacity": 15}}`), &spec), "JSON spec is expected")
+	assert.Equal(t, 15, spec.Config.Capacity, "wrong capacity")
+
+	spec = BasketSpec{}
+	assert.NoError(t, decodeSpec([]byte("name: spec02\nresponses:\n  POST:\n    status: 201\n    body: |\n      line 1\n      line 2\n"), &spec),
+		"YAML spec is expected")
+	assert.Equal(t, "line 1\nline 2\n", spec.Responses["POST"].Body, "wrong response body")
+
+	assert.Error(t, decodeSpec([]byte("name: spec02\nconfg:\n  capacity: 15\n"), &BasketSpec{}), "unknown field is not expected")
+	assert.Error(t, decodeSpec([]byte("name: [spec02"), &BasketSpec{}), "invalid YAML is not expected")
+}
+
+func TestValidateBasketSpec(t *testing.T) {
+	spec := BasketSpec{Responses: map[string]ResponseConfig{"GET": {Body: "ok"}}}
+	if assert.NoError(t, validateBasketSpec(&spec)) {
+		assert.Equal(t, serverConfig.InitCapacity, spec.Config.Capacity, "default capacity is expected")
+		assert.Equal(t, 200, spec.Responses["GET"].Status, "default response status is expected")
+	}
+
+	invalid := []BasketSpec{
+		{Config: BasketConfig{Capacity: -1}},
+		{Responses: map[string]ResponseConfig{"get": {Status: 200}}},
+		{Responses: map[string]ResponseConfig{"GET": {Status: 700}}},
+		{Trigger: &TriggerConfig{Script: "def ("}},
+		{Schedules: []ScheduleConfig{{Name: "x", Cron: "every day"}}},
+		{Webhooks: []WebhookConfig{{URL: "ftp://localhost"}}}}
+	for _, spec := range invalid {
+		assert.Error(t, validateBasketSpec(&spec), "invalid spec is not expected: %v", spec)
+	}
+}
+
+func TestApplyBasketSpec(t *testing.T) {
+	name := "spec03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.Add(createTestPOSTRequest("http://localhost/"+name, "collected", "text/plain"))
+	basket.SetResponse("DELETE", ResponseConfig{Status: 410})
+	basket.SetTrigger(TriggerConfig{Script: "log('old')"})
+	basket.SetWebhooks([]WebhookConfig{{URL: "http://localhost/hook", Secret: "s3cr3t"}})
+
+	spec := BasketSpec{
+		Config:    BasketConfig{Capacity: 40},
+		Responses: map[string]ResponseConfig{"GET": {Status: 201, Body: "created"}},
+		Webhooks:  []WebhookConfig{{URL: "http://localhost/hook", Secret: secretMask}}}
+	if assert.NoError(t, validateBasketSpec(&spec)) {
+		ApplyBasketSpec(name, basket, spec)
+	}
+
+	assert.Equal(t, 40, basket.Config().Capacity, "wrong capacity")
+	assert.Equal(t, 1, basket.Size(), "collected requests are expected to be kept")
+	assert.Equal(t, 201, basket.GetResponse("GET").Status, "wrong response status")
+	if response := basket.GetResponse("DELETE"); response != nil {
+		assert.True(t, isDefaultResponse(response), "default response is expected to be restored")
+	}
+	if trigger := basket.GetTrigger(); trigger != nil {
+		assert.Empty(t, trigger.Script, "trigger is expected to be removed")
+	}
+	if webhooks := basket.GetWebhooks(); assert.Len(t, webhooks, 1) {
+		assert.Equal(t, "s3cr3t", webhooks[0].Secret, "masked secret is expected to be kept")
+	}
+
+	// specs of all baskets
+	db.Create("spec04", BasketConfig{Capacity: 10})
+	all := ExportBasketsSpec(db)
+	if assert.Len(t, all.Baskets, 2, "wrong number of baskets") {
+		names := []string{all.Baskets[0].Name, all.Baskets[1].Name}
+		assert.Contains(t, strings.Join(names, ","), "spec04", "basket is expected")
+	}
+}
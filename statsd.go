@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsdPrefix   = "request_baskets."
+	defaultStatsdInterval = 10
+
+	statsdMaxPacketSize = 1432 // fits into ethernet frame without fragmentation
+	statsdMaxTimings    = 10000
+)
+
+// metrics pushes metrics of the service to StatsD server, nil if metrics are not pushed
+var metrics *statsdExporter
+
+// statsdExporter aggregates counters of the service and pushes them to StatsD server over UDP every interval
+// along with gauges and timing samples; tags are sent in DogStatsD format (|#key:value), so the same metrics are
+// accepted by Datadog agent, Telegraf or StatsD exporter of Prometheus
+type statsdExporter struct {
+	sync.Mutex
+	conn     net.Conn
+	prefix   string
+	tags     string
+	interval time.Duration
+	counters map[string]int64 // counters by name with tags, e.g. "requests.collected|#basket:demo"
+	timings  []string         // encoded timing samples
+}
+
+// newStatsdExporter creates exporter of metrics to StatsD server at given address, e.g. localhost:8125;
+// constant tags, e.g. "env:prod,region:eu", are added to every metric
+func newStatsdExporter(addr string, prefix string, tags string, interval int) (*statsdExporter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval of StatsD metrics should be positive, but was %d", interval)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid StatsD address: %s - %s", addr, err)
+	}
+
+	return &statsdExporter{conn: conn, prefix: prefix, tags: strings.Trim(tags, ", "),
+		interval: time.Duration(interval) * time.Second, counters: make(map[string]int64)}, nil
+}
+
+// Start launches background routine that pushes metrics every interval, gauges are measured before every push
+func (e *statsdExporter) Start(db BasketsDatabase) {
+	go func() {
+		for {
+			time.Sleep(e.interval)
+			e.Flush(e.gauges(db))
+		}
+	}()
+}
+
+// Count increments counter of the service, tags are key:value pairs
+func (e *statsdExporter) Count(name string, value int64, tags ...string) {
+	if e == nil {
+		return
+	}
+	key := name + e.encodeTags(tags)
+
+	e.Lock()
+	defer e.Unlock()
+	e.counters[key] += value
+}
+
+// Timing records duration sample of the service, samples beyond statsdMaxTimings per interval are dropped
+func (e *statsdExporter) Timing(name string, duration time.Duration, tags ...string) {
+	if e == nil {
+		return
+	}
+	ms := strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', -1, 64)
+	sample := e.prefix + name + ":" + ms + "|ms" + e.encodeTags(tags)
+
+	e.Lock()
+	defer e.Unlock()
+	if len(e.timings) < statsdMaxTimings {
+		e.timings = append(e.timings, sample)
+	}
+}
+
+// Flush pushes aggregated counters, timing samples and given gauges to StatsD server and resets counters;
+// gauges are keyed by name optionally followed by tags encoded with encodeTags
+func (e *statsdExporter) Flush(gauges map[string]int64) {
+	e.Lock()
+	counters, timings := e.counters, e.timings
+	e.counters, e.timings = make(map[string]int64), nil
+	e.Unlock()
+
+	lines := make([]string, 0, len(counters)+len(timings)+len(gauges))
+	for _, key := range sortedMetricKeys(counters) {
+		name, tags := splitMetricKey(key)
+		lines = append(lines, e.prefix+name+":"+strconv.FormatInt(counters[key], 10)+"|c"+tags)
+	}
+	lines = append(lines, timings...)
+	for _, key := range sortedMetricKeys(gauges) {
+		name, tags := splitMetricKey(key)
+		if len(tags) == 0 {
+			tags = e.encodeTags(nil)
+		}
+		lines = append(lines, e.prefix+name+":"+strconv.FormatInt(gauges[key], 10)+"|g"+tags)
+	}
+
+	for _, packet := range packMetrics(lines, statsdMaxPacketSize) {
+		if _, err := e.conn.Write(packet); err != nil {
+			log.Printf("[warn] failed to push metrics to StatsD server - %s", err)
+			return
+		}
+	}
+}
+
+// gauges measures current state of the service: baskets, requests collected at the moment, queues and runtime
+func (e *statsdExporter) gauges(db BasketsDatabase) map[string]int64 {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	captures.Lock()
+	inFlight := captures.total
+	captures.Unlock()
+
+	gauges := map[string]int64{
+		"baskets":            int64(db.Size()),
+		"captures.in_flight": int64(inFlight),
+		"runtime.goroutines": int64(runtime.NumGoroutine()),
+		"runtime.heap_alloc": int64(mem.HeapAlloc),
+		"runtime.num_gc":     int64(mem.NumGC)}
+	if webhooks != nil {
+		gauges["queue.length"+e.encodeTags([]string{"queue:webhooks"})] = int64(len(webhooks.queue))
+	}
+	if archive != nil {
+		gauges["queue.length"+e.encodeTags([]string{"queue:archive"})] = int64(len(archive.queue))
+	}
+	if serviceQuota != nil {
+		gauges["storage.bytes"] = serviceQuota.Bytes()
+	}
+	return gauges
+}
+
+// encodeTags encodes constant tags of exporter along with given tags in DogStatsD format
+func (e *statsdExporter) encodeTags(tags []string) string {
+	all := make([]string, 0, len(tags)+1)
+	if len(e.tags) > 0 {
+		all = append(all, e.tags)
+	}
+	for _, tag := range tags {
+		// separators of DogStatsD format are not allowed in tags
+		all = append(all, strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_").Replace(tag))
+	}
+	if len(all) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(all, ",")
+}
+
+// splitMetricKey splits key of metric into name and encoded tags
+func splitMetricKey(key string) (string, string) {
+	if i := strings.Index(key, "|#"); i >= 0 {
+		return key[:i], key[i:]
+	}
+	return key, ""
+}
+
+// sortedMetricKeys returns keys of metrics in alphabetical order, so metrics are pushed in stable order
+func sortedMetricKeys(values map[string]int64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// packMetrics joins metric lines into packets of limited size separated by new lines, a line longer than
+// the limit is sent in a packet of its own
+func packMetrics(lines []string, size int) [][]byte {
+	packets := make([][]byte, 0)
+	packet := new(bytes.Buffer)
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > size {
+			packets = append(packets, packet.Bytes())
+			packet = new(bytes.Buffer)
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, packet.Bytes())
+	}
+	return packets
+}
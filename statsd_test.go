@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsdExporter_Flush(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+
+	exporter, err := newStatsdExporter(server.LocalAddr().String(), "rb.", "env:test", 10)
+	if !assert.NoError(t, err) {
+		return
+	}
+	exporter.Count("requests.collected", 1, "basket:demo")
+	exporter.Count("requests.collected", 2, "basket:demo")
+	exporter.Count("requests.collected", 1, "basket:other|x")
+	exporter.Timing("forwards.latency", 1500*time.Microsecond, "basket:demo")
+	exporter.Flush(map[string]int64{"baskets": 5})
+
+	buf := make([]byte, statsdMaxPacketSize)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"rb.requests.collected:3|c|#env:test,basket:demo",
+			"rb.requests.collected:1|c|#env:test,basket:other_x",
+			"rb.forwards.latency:1.5|ms|#env:test,basket:demo",
+			"rb.baskets:5|g|#env:test"}, strings.Split(string(buf[:n]), "\n"), "wrong metrics")
+	}
+
+	// counters are reset after push
+	exporter.Flush(map[string]int64{})
+	exporter.Flush(map[string]int64{"baskets": 6})
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err = server.ReadFrom(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "rb.baskets:6|g|#env:test", string(buf[:n]), "wrong metrics")
+	}
+}
+
+func TestStatsdExporter_Nil(t *testing.T) {
+	var exporter *statsdExporter
+	exporter.Count("requests.collected", 1, "basket:demo")
+	exporter.Timing("forwards.latency", time.Second)
+}
+
+func TestNewStatsdExporter_Invalid(t *testing.T) {
+	_, err := newStatsdExporter("localhost:8125", defaultStatsdPrefix, "", 0)
+	assert.Error(t, err, "interval is expected to be positive")
+	_, err = newStatsdExporter("localhost", defaultStatsdPrefix, "", 10)
+	assert.Error(t, err, "port is expected")
+}
+
+func TestPackMetrics(t *testing.T) {
+	packets := packMetrics([]string{"a:1|c", "b:2|c", "c:3|c", strings.Repeat("d", 20)}, 12)
+	if assert.Len(t, packets, 3, "wrong number of packets") {
+		assert.Equal(t, "a:1|c\nb:2|c", string(packets[0]), "wrong packet")
+		assert.Equal(t, "c:3|c", string(packets[1]), "wrong packet")
+		assert.Equal(t, strings.Repeat("d", 20), string(packets[2]), "long metric is expected in its own packet")
+	}
+	assert.Empty(t, packMetrics(nil, 12), "no packets are expected")
+}
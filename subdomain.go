@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// basketOfHost returns name of basket selected by subdomain of the basket domain, e.g. "demo" for host
+// "demo.baskets.example.com", empty name is returned if the host is not a subdomain of the basket domain
+func basketOfHost(host string, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	suffix := "." + strings.Trim(domain, ".")
+	if len(host) <= len(suffix) || !strings.EqualFold(host[len(host)-len(suffix):], suffix) {
+		return ""
+	}
+
+	name := host[:len(host)-len(suffix)]
+	if strings.Contains(name, ".") || !validBasketName.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// subdomainBaskets passes requests to subdomains of the basket domain to baskets selected by the subdomains,
+// the entire path of such requests is collected, so service API and web UI are only served by other hosts
+func subdomainBaskets(next http.Handler, domain string) http.Handler {
+	if len(domain) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := basketOfHost(r.Host, domain); len(name) > 0 {
+			acceptBasketRequest(w, r, name, true)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasketOfHost(t *testing.T) {
+	domain := "baskets.example.com"
+	assert.Equal(t, "demo", basketOfHost("demo.baskets.example.com", domain))
+	assert.Equal(t, "demo", basketOfHost("demo.Baskets.Example.com:8443", domain), "port is expected to be ignored")
+	assert.Equal(t, "demo", basketOfHost("demo.baskets.example.com.", domain), "trailing dot is expected to be ignored")
+	assert.Empty(t, basketOfHost("baskets.example.com", domain), "basket domain itself is not expected to select basket")
+	assert.Empty(t, basketOfHost("a.demo.baskets.example.com", domain), "nested subdomain is not expected")
+	assert.Empty(t, basketOfHost("demo.example.com", domain), "other domain is not expected")
+	assert.Empty(t, basketOfHost("demobaskets.example.com", domain), "other domain is not expected")
+	assert.Empty(t, basketOfHost("localhost:55555", domain))
+}
+
+func TestSubdomainBaskets(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer target.Close()
+
+	basket := "subdomain01"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: target.URL + "/hooks/",
+		ExpandPath: true}); !assert.NoError(t, err) {
+		return
+	}
+
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	handler := subdomainBaskets(api, "baskets.example.com")
+
+	// the entire path is collected, even if it looks like a path of service API
+	r, _ := http.NewRequest("POST", "http://"+basket+".baskets.example.com/api/events?id=1", strings.NewReader("data"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+	page := basketsDb.Get(basket).GetRequests(1, 0)
+	if assert.Len(t, page.Requests, 1, "request is expected to be collected") {
+		assert.Equal(t, "/api/events", page.Requests[0].Path, "entire path is expected")
+		assert.True(t, page.Requests[0].Subdomain, "request is expected to be marked as routed by subdomain")
+	}
+
+	select {
+	case forwarded := <-received:
+		assert.Equal(t, "/hooks/api/events", forwarded.URL.Path, "entire path is expected to be forwarded")
+		assert.Equal(t, "id=1", forwarded.URL.RawQuery, "query is expected to be forwarded")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "request is expected to be forwarded")
+	}
+
+	// unknown baskets and other hosts
+	r, _ = http.NewRequest("GET", "http://unknown01.baskets.example.com/", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+
+	r, _ = http.NewRequest("GET", "http://baskets.example.com/api/baskets", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code, "service is expected to handle other hosts")
+
+	// subdomains are ignored without basket domain
+	r, _ = http.NewRequest("GET", "http://"+basket+".baskets.example.com/", nil)
+	w = httptest.NewRecorder()
+	subdomainBaskets(api, "").ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code, "service is expected to handle requests without basket domain")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTailLines = 10
+	maxTailLines     = 1000
+)
+
+// tailCheckInterval defines how often tailing verifies that the basket still exists
+var tailCheckInterval = 30 * time.Second
+
+// tailOptions defines which details of collected requests are printed while tailing a basket
+type tailOptions struct {
+	Headers bool
+	Body    bool
+}
+
+// writeTailLine prints collected request as a single line followed by optional details indented by two spaces, e.g.
+//
+//	2024-01-02T15:04:05Z #12 POST /basket/hook?id=1 200 128B
+func writeTailLine(w io.Writer, request *RequestData, options tailOptions) error {
+	var line strings.Builder
+	path := request.Path
+	if len(request.Query) > 0 {
+		path += "?" + request.Query
+	}
+	fmt.Fprintf(&line, "%s #%d %s %s %d %dB\n", time.Unix(0, request.Date*toMs).UTC().Format(time.RFC3339),
+		request.ID, request.Method, path, request.ResponseStatus, request.ContentLength)
+
+	if options.Headers {
+		for _, name := range sortedKeys(request.Header) {
+			for _, value := range request.Header[name] {
+				fmt.Fprintf(&line, "  %s: %s\n", name, value)
+			}
+		}
+	}
+	if options.Body && len(request.Body) > 0 {
+		for _, text := range strings.Split(strings.TrimRight(request.Body, "\n"), "\n") {
+			fmt.Fprintf(&line, "  %s\n", text)
+		}
+	}
+
+	_, err := io.WriteString(w, line.String())
+	return err
+}
+
+// tailRequests prints up to last recently collected requests of the basket that match the query and keeps
+// printing requests as they are collected until the context is done or the basket is deleted, flush is called
+// once printed lines should be delivered to the client
+func tailRequests(ctx context.Context, w io.Writer, flush func(), db BasketsDatabase, name string,
+	query *RequestsQuery, last int, options tailOptions) error {
+	basket := db.Get(name)
+	if basket == nil {
+		return fmt.Errorf("basket not found: %s", name)
+	}
+
+	// the newest request marks where tailing starts, recent requests collected after it are printed while tailing
+	after := 0
+	if page := basket.GetRequests(1, 0); len(page.Requests) > 0 {
+		after = page.Requests[0].ID
+	}
+	var recent []*RequestData
+	if query != nil {
+		recent = basket.FindRequests(query, last, 0).Requests
+	} else {
+		recent = basket.GetRequests(last, 0).Requests
+	}
+	// requests are listed from newest to oldest
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].ID > after {
+			break
+		}
+		if err := writeTailLine(w, recent[i], options); err != nil {
+			return err
+		}
+	}
+	flush()
+
+	check := time.NewTicker(tailCheckInterval)
+	defer check.Stop()
+
+	for {
+		// start waiting before looking into collected requests, so no request is missed
+		waiter := arrivals.Wait(name, query)
+		found := findRequestsAfter(basket, after, query)
+		for _, request := range found {
+			if err := writeTailLine(w, request, options); err != nil {
+				arrivals.Cancel(name, waiter)
+				return err
+			}
+			after = request.ID
+		}
+		if len(found) > 0 {
+			flush()
+		}
+
+		select {
+		case <-waiter.arrived:
+		case <-check.C:
+			arrivals.Cancel(name, waiter)
+			if db.Get(name) == nil {
+				_, err := io.WriteString(w, "basket is deleted: "+name+"\n")
+				return err
+			}
+		case <-ctx.Done():
+			arrivals.Cancel(name, waiter)
+			return nil
+		}
+	}
+}
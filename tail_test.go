@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTailLine(t *testing.T) {
+	request := &RequestData{ID: 12, Date: 1704207845000, Method: "POST", Path: "/tail01/hook", Query: "id=1",
+		Header: http.Header{"Content-Type": {"text/plain"}}, Body: "line 1\nline 2\n", ContentLength: 14, ResponseStatus: 200}
+
+	var out strings.Builder
+	if assert.NoError(t, writeTailLine(&out, request, tailOptions{})) {
+		assert.Equal(t, "2024-01-02T15:04:05Z #12 POST /tail01/hook?id=1 200 14B\n", out.String(), "wrong line")
+	}
+
+	out.Reset()
+	if assert.NoError(t, writeTailLine(&out, request, tailOptions{Headers: true, Body: true})) {
+		assert.Equal(t, "2024-01-02T15:04:05Z #12 POST /tail01/hook?id=1 200 14B\n"+
+			"  Content-Type: text/plain\n  line 1\n  line 2\n", out.String(), "wrong details")
+	}
+}
+
+func TestTailRequests(t *testing.T) {
+	name := "tail02"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	for i := 0; i < 5; i++ {
+		basket.AddRequest(&RequestData{Method: "GET", Path: "/" + name})
+	}
+
+	reader, writer := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tailRequests(ctx, writer, func() {}, db, name, &RequestsQuery{Method: "GET"}, 2, tailOptions{})
+		writer.Close()
+	}()
+
+	lines := bufio.NewScanner(reader)
+	for _, id := range []string{"#4 ", "#5 "} {
+		if assert.True(t, lines.Scan(), "recent request is expected") {
+			assert.Contains(t, lines.Text(), id, "wrong recent request")
+		}
+	}
+
+	// only matching requests are printed once collected
+	basket.AddRequest(&RequestData{Method: "POST", Path: "/" + name})
+	request := basket.AddRequest(&RequestData{Method: "GET", Path: "/" + name + "/new"})
+	arrivals.Notify(name, request)
+	if assert.True(t, lines.Scan(), "collected request is expected") {
+		assert.Contains(t, lines.Text(), "#7 GET /"+name+"/new", "wrong collected request")
+	}
+
+	cancel()
+	go io.Copy(io.Discard, reader)
+	assert.NoError(t, <-done, "tailing is expected to stop without error")
+	assert.Empty(t, arrivals.waiters[name], "waiter is expected to be cancelled")
+}
+
+func TestTailRequests_DeletedBasket(t *testing.T) {
+	name := "tail03"
+	// basket is deleted while it is tailed, Bolt database is safe for concurrent access
+	db := NewBoltDatabase(name + ".db")
+	defer db.Release()
+	defer os.Remove(name + ".db")
+
+	interval := tailCheckInterval
+	tailCheckInterval = 10 * time.Millisecond
+	defer func() { tailCheckInterval = interval }()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	db.Delete(name)
+	assert.Error(t, tailRequests(context.Background(), io.Discard, func() {}, db, name, nil, 10, tailOptions{}),
+		"missing basket is not expected")
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		db.Delete(name)
+	}()
+
+	var out strings.Builder
+	assert.NoError(t, tailRequests(context.Background(), &out, func() {}, db, name, nil, 10, tailOptions{}))
+	assert.Equal(t, "basket is deleted: "+name+"\n", out.String(), "deleted basket is expected to be reported")
+}
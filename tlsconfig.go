@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultTLSMinVersion = "1.2"
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13}
+
+// tlsSettings describes hardening of HTTPS listener of the service
+type tlsSettings struct {
+	minVersion uint16
+	ciphers    []uint16 // cipher suites of TLS 1.2 and older, nil - defaults of Go
+	http2      bool
+}
+
+// newTLSSettings parses minimum TLS version and names of cipher suites, only cipher suites without known
+// security issues are accepted; cipher suites of TLS 1.3 are not configurable
+func newTLSSettings(minVersion string, ciphers []string, http2 bool) (*tlsSettings, error) {
+	if len(minVersion) == 0 {
+		minVersion = defaultTLSMinVersion
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version: %s, expected 1.0, 1.1, 1.2 or 1.3", minVersion)
+	}
+	settings := &tlsSettings{minVersion: version, http2: http2}
+
+	suites := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite
+	}
+	for _, name := range ciphers {
+		suite, found := suites[strings.ToUpper(strings.TrimSpace(name))]
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite: %s", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, fmt.Errorf("cipher suites of TLS 1.3 are not configurable: %s", name)
+		}
+		settings.ciphers = append(settings.ciphers, suite.ID)
+	}
+	return settings, nil
+}
+
+// Apply applies settings to TLS configuration and HTTP server, HTTP/2 is negotiated by default
+// unless it is disabled
+func (s *tlsSettings) Apply(config *tls.Config, server *http.Server) {
+	config.MinVersion = s.minVersion
+	if len(s.ciphers) > 0 {
+		config.CipherSuites = s.ciphers
+	}
+	if !s.http2 {
+		// non-nil map disables HTTP/2 support of HTTP server
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		protos := make([]string, 0, len(config.NextProtos))
+		for _, proto := range config.NextProtos {
+			if proto != "h2" {
+				protos = append(protos, proto)
+			}
+		}
+		config.NextProtos = protos
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTLSSettings(t *testing.T) {
+	settings, err := newTLSSettings("", nil, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint16(tls.VersionTLS12), settings.minVersion, "TLS 1.2 is expected by default")
+		assert.Nil(t, settings.ciphers, "default cipher suites are expected")
+	}
+
+	settings, err = newTLSSettings("1.3", nil, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint16(tls.VersionTLS13), settings.minVersion, "wrong minimum TLS version")
+	}
+
+	settings, err = newTLSSettings("1.2", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "tls_ecdhe_ecdsa_with_aes_256_gcm_sha384"}, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+			settings.ciphers, "wrong cipher suites")
+	}
+
+	_, err = newTLSSettings("1.4", nil, true)
+	assert.Error(t, err, "unknown TLS version is not expected")
+	_, err = newTLSSettings("1.2", []string{"TLS_RSA_WITH_RC4_128_SHA"}, true)
+	assert.Error(t, err, "insecure cipher suite is not expected")
+	_, err = newTLSSettings("1.2", []string{"TLS_AES_128_GCM_SHA256"}, true)
+	assert.Error(t, err, "cipher suite of TLS 1.3 is not expected")
+}
+
+func TestTLSSettings_Apply(t *testing.T) {
+	settings, _ := newTLSSettings("1.2", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, false)
+	config := &tls.Config{NextProtos: []string{"h2", "http/1.1", "acme-tls/1"}}
+	server := &http.Server{}
+	settings.Apply(config, server)
+
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion, "wrong minimum TLS version")
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, config.CipherSuites, "wrong cipher suites")
+	assert.Equal(t, []string{"http/1.1", "acme-tls/1"}, config.NextProtos, "HTTP/2 is not expected to be negotiated")
+	assert.NotNil(t, server.TLSNextProto, "HTTP/2 is expected to be disabled")
+
+	settings, _ = newTLSSettings("1.2", nil, true)
+	server = &http.Server{}
+	settings.Apply(config, server)
+	assert.Nil(t, server.TLSNextProto, "HTTP/2 is expected to be enabled")
+}
+
+func TestTLSSettings_Handshake(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = &tls.Config{}
+	settings, _ := newTLSSettings("1.3", nil, false)
+	settings.Apply(ts.TLS, ts.Config)
+	ts.StartTLS()
+	defer ts.Close()
+
+	legacy := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}}}
+	_, err := legacy.Get(ts.URL)
+	assert.Error(t, err, "TLS 1.2 is not expected to be accepted")
+
+	client := &http.Client{Transport: &http.Transport{ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get(ts.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version, "TLS 1.3 is expected")
+		assert.Equal(t, "HTTP/1.1", resp.Proto, "HTTP/2 is not expected")
+	}
+}
@@ -3,8 +3,36 @@ package main
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"regexp"
 )
 
+// Scopes of basket access tokens
+const (
+	ScopeRead        = "read"         // view, export and aggregate collected requests
+	ScopeWriteConfig = "write-config" // view and change settings, responses, scripts and webhooks of basket
+	ScopeClear       = "clear"        // delete collected requests
+	ScopeDelete      = "delete"       // delete basket
+)
+
+const (
+	tokenNamePattern = `^[\w\d\-_\.]{1,100}$`
+	maxAccessTokens  = 20
+)
+
+var validTokenName = regexp.MustCompile(tokenNamePattern)
+
+var tokenScopes = []string{ScopeRead, ScopeWriteConfig, ScopeClear, ScopeDelete}
+
+// AccessToken describes named token of basket that grants access within its scopes only,
+// the token itself is reported once it is issued.
+type AccessToken struct {
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	Token   string   `json:"token,omitempty"`
+	Created int64    `json:"created"`
+}
+
 // GenerateToken generates a cryptographically strong token that uses only base64 characters
 func GenerateToken() (string, error) {
 	bytes := make([]byte, 33)
@@ -15,3 +43,53 @@ func GenerateToken() (string, error) {
 
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
+
+// HasScope checks if access token grants the scope
+func (token AccessToken) HasScope(scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAccessToken validates name and scopes of a new access token
+func validateAccessToken(token AccessToken) error {
+	if !validTokenName.MatchString(token.Name) {
+		return fmt.Errorf("invalid token name; the name does not match pattern: %s", tokenNamePattern)
+	}
+	if len(token.Scopes) == 0 {
+		return fmt.Errorf("token requires at least one scope")
+	}
+
+	for _, scope := range token.Scopes {
+		known := false
+		for _, s := range tokenScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Errorf("unknown token scope: %s", scope)
+		}
+	}
+
+	return nil
+}
+
+// authorizeScope checks if token grants the scope of access to basket: either an access token of basket
+// with the scope or the read-only share token for reading
+func authorizeScope(basket Basket, token string, scope string) bool {
+	if len(token) == 0 {
+		return false
+	}
+	if scope == ScopeRead && token == basket.GetShareToken() {
+		return true
+	}
+
+	for _, t := range basket.GetAccessTokens() {
+		if t.Token == token {
+			return t.HasScope(scope)
+		}
+	}
+	return false
+}
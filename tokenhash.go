@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2Params describes cost of argon2id key derivation
+type argon2Params struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+}
+
+const (
+	tokenHashPrefix = "$argon2id$"
+	tokenSaltLength = 16
+	tokenHashLength = 32
+)
+
+// secretHashParams defines cost of hashing basket tokens and view passwords, see OWASP recommendations for argon2id
+var secretHashParams = argon2Params{Time: 2, Memory: 19 * 1024, Threads: 1}
+
+// tokenPepper is a secret of the service that is mixed into basket tokens before hashing, so leaked hashes
+// cannot be verified without the service configuration
+var tokenPepper []byte
+
+// argon2Slots limits concurrent argon2id key derivations, so wrong tokens cannot exhaust memory of the service
+var argon2Slots = make(chan struct{}, runtime.NumCPU())
+
+// hashBasketToken hashes basket token with argon2id and encodes the hash in PHC string format, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>; empty token stands for revoked token and is kept empty
+func hashBasketToken(token string) string {
+	if len(token) == 0 {
+		return ""
+	}
+
+	salt := make([]byte, tokenSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		panic(fmt.Sprintf("failed to generate salt of token: %s", err))
+	}
+
+	p := secretHashParams
+	hash := deriveKey(token, salt, p, tokenHashLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", tokenHashPrefix, argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// verifyBasketToken checks if token matches the stored hash of basket token; tokens stored before hashing was
+// introduced are compared as is and reported as outdated, so they are replaced with the hash
+func verifyBasketToken(stored string, token string) (valid bool, outdated bool) {
+	if len(stored) == 0 || len(token) == 0 {
+		return false, false
+	}
+	if !strings.HasPrefix(stored, tokenHashPrefix) {
+		valid = subtle.ConstantTimeCompare([]byte(stored), []byte(token)) == 1
+		return valid, valid
+	}
+
+	var version int
+	var p argon2Params
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return false, false
+	}
+
+	if subtle.ConstantTimeCompare(hash, deriveKey(token, salt, p, uint32(len(hash)))) != 1 {
+		return false, false
+	}
+	return true, p != secretHashParams
+}
+
+// deriveKey runs argon2id key derivation of peppered token once a slot is available
+func deriveKey(token string, salt []byte, p argon2Params, length uint32) []byte {
+	argon2Slots <- struct{}{}
+	defer func() { <-argon2Slots }()
+
+	return argon2.IDKey(pepperToken(token), salt, p.Time, p.Memory, p.Threads, length)
+}
+
+// pepperToken mixes the pepper of the service into token
+func pepperToken(token string) []byte {
+	if len(tokenPepper) == 0 {
+		return []byte(token)
+	}
+
+	mac := hmac.New(sha256.New, tokenPepper)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashBasketToken(t *testing.T) {
+	defer func(params argon2Params) { secretHashParams = params }(secretHashParams)
+	secretHashParams = argon2Params{Time: 2, Memory: 19 * 1024, Threads: 1}
+
+	hash := hashBasketToken("token_1234")
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$"), "PHC string is expected: %s", hash)
+	assert.True(t, len(hash) <= 100, "hash is expected to fit database column")
+	assert.NotEqual(t, hash, hashBasketToken("token_1234"), "random salt is expected")
+	assert.Empty(t, hashBasketToken(""), "revoked token is expected to stay empty")
+
+	valid, outdated := verifyBasketToken(hash, "token_1234")
+	assert.True(t, valid, "token is expected to match")
+	assert.False(t, outdated, "hash is not expected to be outdated")
+	valid, _ = verifyBasketToken(hash, "token_1234")
+	assert.True(t, valid, "verified token is expected to match again")
+	valid, _ = verifyBasketToken(hash, "token_12345")
+	assert.False(t, valid, "other token is not expected to match")
+	valid, _ = verifyBasketToken(hash, "")
+	assert.False(t, valid, "empty token is not expected to match")
+	valid, _ = verifyBasketToken("", "token_1234")
+	assert.False(t, valid, "revoked token is not expected to match")
+	valid, _ = verifyBasketToken(strings.Replace(hash, "t=2", "t=3", 1), "token_1234")
+	assert.False(t, valid, "token is not expected to match other parameters")
+	valid, _ = verifyBasketToken("$argon2id$v=19$broken", "token_1234")
+	assert.False(t, valid, "broken hash is not expected to match")
+
+	// hashes of other parameters are upgraded
+	secretHashParams = argon2Params{Time: 1, Memory: 64, Threads: 1}
+	valid, outdated = verifyBasketToken(hash, "token_1234")
+	assert.True(t, valid, "token is expected to match")
+	assert.True(t, outdated, "hash of other parameters is expected to be outdated")
+}
+
+func TestVerifyBasketToken_Plain(t *testing.T) {
+	// tokens stored by previous versions
+	valid, outdated := verifyBasketToken("token_1234", "token_1234")
+	assert.True(t, valid, "plain token is expected to match")
+	assert.True(t, outdated, "plain token is expected to be outdated")
+	valid, outdated = verifyBasketToken("token_1234", "token_123")
+	assert.False(t, valid, "other token is not expected to match")
+	assert.False(t, outdated, "mismatch is not expected to be outdated")
+}
+
+func TestHashBasketToken_Pepper(t *testing.T) {
+	defer func() { tokenPepper = nil }()
+
+	tokenPepper = []byte("pepper_1")
+	hash := hashBasketToken("token_1234")
+
+	tokenPepper = []byte("pepper_2")
+	valid, _ := verifyBasketToken(hash, "token_1234")
+	assert.False(t, valid, "token is not expected to match with other pepper")
+
+	tokenPepper = []byte("pepper_1")
+	valid, _ = verifyBasketToken(hash, "token_1234")
+	assert.True(t, valid, "token is expected to match with the same pepper")
+}
+
+func TestDeriveKey_Slots(t *testing.T) {
+	// hold all slots, key derivation must wait for a free one
+	for i := 0; i < cap(argon2Slots); i++ {
+		argon2Slots <- struct{}{}
+	}
+	done := make(chan []byte)
+	go func() {
+		done <- deriveKey("token_1234", []byte("salt_1234"), argon2Params{Time: 1, Memory: 64, Threads: 1}, 16)
+	}()
+
+	select {
+	case <-done:
+		t.Error("key derivation is not expected without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-argon2Slots
+	assert.Len(t, <-done, 16, "key is expected once a slot is free")
+	for i := 1; i < cap(argon2Slots); i++ {
+		<-argon2Slots
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+
+	traceQueueSize   = 2048
+	traceBatchSize   = 512
+	traceInterval    = 5 * time.Second
+	traceTimeout     = 10 * time.Second
+	traceServiceName = "request-baskets"
+	traceExportPath  = "/v1/traces"
+)
+
+// Kinds and status codes of spans as defined by OTLP
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// tracer exports spans of the service, nil if tracing is disabled
+var tracer *spanTracer
+
+// spanTracer exports finished spans with OTLP over HTTP in JSON encoding, spans are queued and exported in batches;
+// spans are dropped rather than delaying collected requests if the collector does not keep up
+type spanTracer struct {
+	endpoint string
+	client   *http.Client
+	queue    chan *otlpSpan
+	resource otlpResource
+}
+
+// traceSpan describes an operation of the service within a trace, nil spans are valid and record nothing,
+// so code paths are instrumented the same way whether tracing is enabled or not
+type traceSpan struct {
+	tracer     *spanTracer
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	sampled    bool
+	name       string
+	kind       int
+	start      time.Time
+	attributes []otlpAttribute
+	status     otlpStatus
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"` // 64-bit integers are encoded as strings
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// newSpanTracer creates tracer that exports spans to OTLP/HTTP endpoint of collector, e.g. http://localhost:4318,
+// spans are posted to /v1/traces of the endpoint unless the URL already points to it
+func newSpanTracer(endpoint string) (*spanTracer, error) {
+	target, err := url.ParseRequestURI(endpoint)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %s, HTTP(S) URL is expected", endpoint)
+	}
+	if !strings.HasSuffix(target.Path, traceExportPath) {
+		target.Path = strings.TrimSuffix(target.Path, "/") + traceExportPath
+	}
+
+	resource := otlpResource{Attributes: []otlpAttribute{
+		newAttribute("service.name", traceServiceName), newAttribute("service.version", version.Version)}}
+	return &spanTracer{endpoint: target.String(), client: &http.Client{Timeout: traceTimeout},
+		queue: make(chan *otlpSpan, traceQueueSize), resource: resource}, nil
+}
+
+// Start launches background routine that exports finished spans once a batch is full or every traceInterval
+func (t *spanTracer) Start() {
+	go func() {
+		ticker := time.NewTicker(traceInterval)
+		defer ticker.Stop()
+
+		batch := make([]*otlpSpan, 0, traceBatchSize)
+		for {
+			select {
+			case span := <-t.queue:
+				if batch = append(batch, span); len(batch) < traceBatchSize {
+					continue
+				}
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+			}
+			if err := t.export(batch); err != nil {
+				log.Printf("[warn] failed to export %d trace spans - %s", len(batch), err)
+			}
+			batch = batch[:0]
+		}
+	}()
+}
+
+// export posts batch of spans to the collector
+func (t *spanTracer) export(batch []*otlpSpan) error {
+	payload, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{Resource: t.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: traceServiceName, Version: version.Version}, Spans: batch}}}}})
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status of OTLP collector: %s", resp.Status)
+	}
+	return nil
+}
+
+// startSpan starts a root span of a new trace, returns nil if tracing is disabled
+func startSpan(name string, kind int) *traceSpan {
+	if tracer == nil {
+		return nil
+	}
+	span := &traceSpan{tracer: tracer, sampled: true, name: name, kind: kind, start: time.Now()}
+	rand.Read(span.traceID[:])
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// startServerSpan starts a span of incoming HTTP request, the span continues the trace of traceparent header
+// of the request if it is valid; returns nil if tracing is disabled
+func startServerSpan(r *http.Request, name string) *traceSpan {
+	span := startSpan(name, spanKindServer)
+	if span == nil {
+		return nil
+	}
+	if traceID, parentID, sampled, ok := parseTraceParent(r.Header.Get(TraceParentHeader)); ok {
+		span.traceID, span.parentID, span.sampled = traceID, parentID, sampled
+	}
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+	return span
+}
+
+// parseTraceParent parses W3C trace context header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceParent(value string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || strings.ToLower(value) != value {
+		return
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return
+	}
+	if traceID == [16]byte{} || parentID == [8]byte{} {
+		return
+	}
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// Child starts a span of operation within the span, returns nil if the span is nil
+func (s *traceSpan) Child(name string, kind int) *traceSpan {
+	if s == nil {
+		return nil
+	}
+	span := &traceSpan{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID, sampled: s.sampled, name: name,
+		kind: kind, start: time.Now()}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// TraceParent returns W3C trace context header of the span, so a called service continues the trace
+func (s *traceSpan) TraceParent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]), flags)
+}
+
+// Propagate returns copy of request with traceparent header of the span, the request itself is not changed
+func (s *traceSpan) Propagate(request *RequestData) *RequestData {
+	if s == nil {
+		return request
+	}
+	propagated := *request
+	propagated.Header = request.Header.Clone()
+	if propagated.Header == nil {
+		propagated.Header = make(http.Header)
+	}
+	propagated.Header.Set(TraceParentHeader, s.TraceParent())
+	return &propagated
+}
+
+// SetAttribute records attribute of the span, values are strings, integers or booleans
+func (s *traceSpan) SetAttribute(key string, value interface{}) {
+	if s != nil {
+		s.attributes = append(s.attributes, newAttribute(key, value))
+	}
+}
+
+// SetError marks the span as failed, nil error is ignored
+func (s *traceSpan) SetError(err error) {
+	if s != nil && err != nil {
+		s.status = otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+}
+
+// End finishes the span and queues it for export if the trace is sampled, the span is dropped if the queue is full
+func (s *traceSpan) End() {
+	if s == nil || !s.sampled {
+		return
+	}
+
+	span := &otlpSpan{TraceID: hex.EncodeToString(s.traceID[:]), SpanID: hex.EncodeToString(s.spanID[:]),
+		Name: s.name, Kind: s.kind, StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10), Attributes: s.attributes, Status: s.status}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+
+	select {
+	case s.tracer.queue <- span:
+	default:
+	}
+}
+
+func newAttribute(key string, value interface{}) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case int:
+		attribute.Value.IntValue = strconv.Itoa(v)
+	case int64:
+		attribute.Value.IntValue = strconv.FormatInt(v, 10)
+	case bool:
+		attribute.Value.BoolValue = &v
+	default:
+		str := fmt.Sprint(v)
+		attribute.Value.StringValue = &str
+	}
+	return attribute
+}
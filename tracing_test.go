@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	traceID, parentID, sampled, ok := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if assert.True(t, ok, "valid traceparent is expected") {
+		assert.Equal(t, byte(0x4b), traceID[0], "wrong trace ID")
+		assert.Equal(t, byte(0xb7), parentID[7], "wrong parent span ID")
+		assert.True(t, sampled, "sampled trace is expected")
+	}
+
+	_, _, sampled, ok = parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.True(t, ok, "valid traceparent is expected")
+	assert.False(t, sampled, "trace is not expected to be sampled")
+
+	for _, value := range []string{"", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01"} {
+		_, _, _, ok = parseTraceParent(value)
+		assert.False(t, ok, "invalid traceparent is not expected: %s", value)
+	}
+}
+
+func TestNewSpanTracer(t *testing.T) {
+	if st, err := newSpanTracer("http://localhost:4318"); assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost:4318/v1/traces", st.endpoint, "wrong export URL")
+	}
+	if st, err := newSpanTracer("https://otel.example.com/v1/traces"); assert.NoError(t, err) {
+		assert.Equal(t, "https://otel.example.com/v1/traces", st.endpoint, "wrong export URL")
+	}
+	_, err := newSpanTracer("localhost:4318")
+	assert.Error(t, err, "invalid endpoint is not expected")
+}
+
+func TestTraceSpan_Nil(t *testing.T) {
+	var span *traceSpan
+	child := span.Child("storage.get", spanKindInternal)
+	assert.Nil(t, child, "child of disabled span is expected to be nil")
+	child.SetAttribute("basket", "demo")
+	child.SetError(nil)
+	child.End()
+
+	request := &RequestData{Method: "GET", Header: http.Header{}}
+	assert.Equal(t, request, child.Propagate(request), "request is not expected to be changed")
+}
+
+func TestTraceCapture(t *testing.T) {
+	defer func(current *spanTracer) { tracer = current }(tracer)
+	exported := make(chan otlpTraces, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		traces := otlpTraces{}
+		json.Unmarshal(body, &traces)
+		exported <- traces
+	}))
+	defer collector.Close()
+
+	forwarded := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get(TraceParentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer target.Close()
+
+	st, _ := newSpanTracer(collector.URL)
+	tracer = st
+
+	basket := "tracing01"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: target.URL, ProxyResponse: true}); !assert.NoError(t, err) {
+		return
+	}
+
+	r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket, strings.NewReader("traced"))
+	r.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	testServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadGateway, w.Code, "wrong HTTP result code")
+
+	select {
+	case traceParent := <-forwarded:
+		assert.True(t, strings.HasPrefix(traceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"),
+			"forwarded request is expected to continue the trace")
+		assert.NotContains(t, traceParent, "00f067aa0ba902b7", "forwarded request is expected to have a new parent")
+	case <-time.After(time.Second):
+		assert.Fail(t, "request is expected to be forwarded")
+	}
+
+	// export the queued spans at once
+	spans := []*otlpSpan{}
+	for len(st.queue) > 0 {
+		spans = append(spans, <-st.queue)
+	}
+	if !assert.NoError(t, st.export(spans)) {
+		return
+	}
+
+	traces := <-exported
+	if assert.Len(t, traces.ResourceSpans, 1) && assert.Len(t, traces.ResourceSpans[0].ScopeSpans, 1) {
+		names := make(map[string]*otlpSpan)
+		for _, span := range traces.ResourceSpans[0].ScopeSpans[0].Spans {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID, "wrong trace ID of span: %s", span.Name)
+			names[span.Name] = span
+		}
+		if capture := names["capture"]; assert.NotNil(t, capture, "span of capture is expected") {
+			assert.Equal(t, "00f067aa0ba902b7", capture.ParentSpanID, "capture is expected to continue incoming trace")
+			assert.Equal(t, spanKindServer, capture.Kind, "wrong kind of span")
+		}
+		if forward := names["forward"]; assert.NotNil(t, forward, "span of forward is expected") {
+			assert.Equal(t, names["capture"].SpanID, forward.ParentSpanID, "forward is expected to be a child of capture")
+			assert.Equal(t, spanStatusError, forward.Status.Code, "failed forward is expected")
+		}
+		assert.NotNil(t, names["storage.add"], "span of storage operation is expected")
+	}
+}
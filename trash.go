@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trashPrefix starts names of baskets in trash, valid basket names never contain it, so baskets in trash
+// cannot be reached or taken by the service API
+const trashPrefix = "~"
+
+// trashNameSecret is a secret of basket in trash that keeps the original name of basket, names of secrets
+// given by users never contain trashPrefix
+const trashNameSecret = trashPrefix + "name"
+
+var trash *basketTrash
+
+// TrashedBasket describes deleted basket that is kept in trash until its grace period is over.
+type TrashedBasket struct {
+	Name     string `json:"name"`
+	Deleted  int64  `json:"deleted"` // date of deletion in milliseconds
+	Purged   int64  `json:"purged"`  // date in milliseconds when basket is deleted permanently
+	Requests int    `json:"requests"`
+}
+
+// basketTrash keeps deleted baskets for a grace period, so they can be restored; baskets are moved to trash
+// by renaming them to trashName, so the content of basket stays in the database and survives restarts;
+// owners keep their baskets in trash under the names in trash
+type basketTrash struct {
+	sync.Mutex
+	db      BasketsDatabase
+	period  time.Duration
+	deleted map[string]int64  // dates of deletion in milliseconds by names in trash
+	names   map[string]string // original names of baskets by names in trash
+}
+
+// newBasketTrash creates trash of deleted baskets and finds baskets that were left in trash by previous runs
+// of the service
+func newBasketTrash(db BasketsDatabase, period time.Duration) *basketTrash {
+	t := &basketTrash{db: db, period: period, deleted: make(map[string]int64), names: make(map[string]string)}
+	for skip, hasMore := 0, true; hasMore; {
+		page := db.FindNames(trashPrefix, searchNamesChunk, skip)
+		for _, trashed := range page.Names {
+			if date, ok := parseTrashName(trashed); ok {
+				t.deleted[trashed] = date
+				t.names[trashed] = t.originalName(trashed)
+			}
+		}
+		skip += len(page.Names)
+		hasMore = page.HasMore && len(page.Names) > 0
+	}
+	return t
+}
+
+// trashName returns random name of basket in trash, e.g. "~1700000000000~5f3a9c1e"; the name does not include
+// the original name of basket, so it never exceeds the length of basket names, see trashNameSecret
+func trashName(deleted int64) string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return trashPrefix + strconv.FormatInt(deleted, 10) + trashPrefix + hex.EncodeToString(id)
+}
+
+// parseTrashName returns date of deletion of basket in trash
+func parseTrashName(trashed string) (int64, bool) {
+	if !isTrashName(trashed) {
+		return 0, false
+	}
+	parts := strings.SplitN(trashed[len(trashPrefix):], trashPrefix, 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	deleted, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return deleted, true
+}
+
+// originalName returns the original name of basket in trash, baskets moved to trash by earlier versions of the service
+// keep it within the name in trash
+func (t *basketTrash) originalName(trashed string) string {
+	if basket := t.db.Get(trashed); basket != nil {
+		if name := basket.GetSecrets()[trashNameSecret]; len(name) > 0 {
+			return name
+		}
+	}
+	return trashed[strings.LastIndex(trashed, trashPrefix)+len(trashPrefix):]
+}
+
+// isTrashName checks if the name belongs to basket in trash
+func isTrashName(name string) bool {
+	return strings.HasPrefix(name, trashPrefix)
+}
+
+// Period returns grace period of deleted baskets, 0 if baskets are deleted at once
+func (t *basketTrash) Period() time.Duration {
+	return t.period
+}
+
+// Size returns number of baskets in trash
+func (t *basketTrash) Size() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.deleted)
+}
+
+// Add moves basket to trash, the owner of basket keeps it
+func (t *basketTrash) Add(name string, now time.Time) error {
+	t.Lock()
+	defer t.Unlock()
+
+	deleted := now.UnixNano() / toMs
+	trashed := trashName(deleted)
+	if err := t.db.Rename(name, trashed); err != nil {
+		return fmt.Errorf("failed to move basket: %s to trash - %s", name, err)
+	}
+	if basket := t.db.Get(trashed); basket != nil {
+		basket.SetSecret(trashNameSecret, name)
+	}
+	t.deleted[trashed] = deleted
+	t.names[trashed] = name
+	users.Rename(name, trashed)
+	return nil
+}
+
+// List returns baskets in trash, the latest deleted baskets go first
+func (t *basketTrash) List() []*TrashedBasket {
+	t.Lock()
+	defer t.Unlock()
+
+	baskets := make([]*TrashedBasket, 0, len(t.deleted))
+	for trashed := range t.deleted {
+		baskets = append(baskets, t.describe(trashed))
+	}
+	sort.Slice(baskets, func(i, j int) bool {
+		if baskets[i].Deleted != baskets[j].Deleted {
+			return baskets[i].Deleted > baskets[j].Deleted
+		}
+		return baskets[i].Name < baskets[j].Name
+	})
+	return baskets
+}
+
+// Restore moves basket from trash back under its name, the latest deletion of basket is restored unless
+// the date of deletion is given; returns nil if basket is not found in trash
+func (t *basketTrash) Restore(name string, deleted int64) (*TrashedBasket, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	found := ""
+	for trashed, date := range t.deleted {
+		if t.names[trashed] == name && (deleted == 0 || deleted == date) &&
+			(len(found) == 0 || date > t.deleted[found]) {
+			found = trashed
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	restored := t.describe(found)
+	if err := t.db.Rename(found, name); err != nil {
+		return nil, fmt.Errorf("failed to restore basket: %s from trash - %s", name, err)
+	}
+	if basket := t.db.Get(name); basket != nil {
+		basket.DeleteSecret(trashNameSecret)
+	}
+	delete(t.deleted, found)
+	delete(t.names, found)
+	users.Rename(found, name)
+	return restored, nil
+}
+
+// Start launches background routine that permanently deletes baskets with expired grace period
+// every retentionInterval
+func (t *basketTrash) Start() {
+	go func() {
+		for {
+			time.Sleep(retentionInterval)
+			t.Purge(time.Now())
+		}
+	}()
+}
+
+// Purge permanently deletes baskets which grace period is over at given time, returns names of deleted baskets
+func (t *basketTrash) Purge(now time.Time) []string {
+	t.Lock()
+	defer t.Unlock()
+
+	purged := make([]string, 0)
+	for trashed, date := range t.deleted {
+		if t.purgeDate(date) <= now.UnixNano()/toMs {
+			name := t.names[trashed]
+			log.Printf("[info] grace period of deleted basket: %s is over, deleting it permanently", name)
+			t.db.Delete(trashed)
+			delete(t.deleted, trashed)
+			delete(t.names, trashed)
+			users.Release(trashed)
+			purged = append(purged, name)
+		}
+	}
+	return purged
+}
+
+func (t *basketTrash) purgeDate(deleted int64) int64 {
+	return deleted + int64(t.period/time.Millisecond)
+}
+
+func (t *basketTrash) describe(trashed string) *TrashedBasket {
+	deleted := t.deleted[trashed]
+	basket := &TrashedBasket{Name: t.names[trashed], Deleted: deleted, Purged: t.purgeDate(deleted)}
+	if b := t.db.Get(trashed); b != nil {
+		basket.Requests = b.Size()
+	}
+	return basket
+}
+
+// visibleDatabase hides baskets in trash from the rest of the service, names of baskets in trash are skipped
+// while paging through names of baskets
+type visibleDatabase struct {
+	BasketsDatabase
+	trash *basketTrash
+}
+
+func (db *visibleDatabase) Get(name string) Basket {
+	if isTrashName(name) {
+		return nil
+	}
+	return db.BasketsDatabase.Get(name)
+}
+
+func (db *visibleDatabase) Size() int {
+	return db.BasketsDatabase.Size() - db.trash.Size()
+}
+
+func (db *visibleDatabase) GetNames(max int, skip int) BasketNamesPage {
+	if db.trash.Size() == 0 {
+		return db.BasketsDatabase.GetNames(max, skip)
+	}
+
+	names, last, hasMore := db.scan(func(max int, skip int) ([]string, bool) {
+		page := db.BasketsDatabase.GetNames(max, skip)
+		return page.Names, page.HasMore
+	}, max, skip)
+	page := BasketNamesPage{Names: names, Count: db.Size(), HasMore: hasMore}
+	if hasMore {
+		page.NextCursor = db.BasketsDatabase.GetNames(1, last).NextCursor
+	}
+	return page
+}
+
+func (db *visibleDatabase) GetNamesAfter(position string, max int) BasketNamesPage {
+	if db.trash.Size() == 0 {
+		return db.BasketsDatabase.GetNamesAfter(position, max)
+	}
+
+	page := BasketNamesPage{Names: make([]string, 0, max), Count: db.Size()}
+	for {
+		all := db.BasketsDatabase.GetNamesAfter(position, max-len(page.Names))
+		for _, name := range all.Names {
+			if !isTrashName(name) {
+				page.Names = append(page.Names, name)
+			}
+		}
+		page.HasMore, page.NextCursor = all.HasMore, all.NextCursor
+		if !all.HasMore || len(all.Names) == 0 || len(page.Names) == max {
+			return page
+		}
+
+		var err error
+		if position, err = DecodeCursor(all.NextCursor); err != nil {
+			return page
+		}
+	}
+}
+
+func (db *visibleDatabase) FindNames(query string, max int, skip int) BasketNamesQueryPage {
+	if db.trash.Size() == 0 {
+		return db.BasketsDatabase.FindNames(query, max, skip)
+	}
+
+	names, _, hasMore := db.scan(func(max int, skip int) ([]string, bool) {
+		page := db.BasketsDatabase.FindNames(query, max, skip)
+		return page.Names, page.HasMore
+	}, max, skip)
+	return BasketNamesQueryPage{Names: names, HasMore: hasMore}
+}
+
+// GetStats returns statistics of database without baskets in trash in top lists, totals of requests still
+// include requests of baskets in trash
+func (db *visibleDatabase) GetStats(max int) DatabaseStats {
+	trashed := db.trash.Size()
+	if trashed == 0 {
+		return db.BasketsDatabase.GetStats(max)
+	}
+
+	stats := db.BasketsDatabase.GetStats(max + trashed)
+	stats.BasketsCount -= trashed
+	stats.TopBasketsBySize = visibleBaskets(stats.TopBasketsBySize, max)
+	stats.TopBasketsByDate = visibleBaskets(stats.TopBasketsByDate, max)
+	return stats
+}
+
+// scan collects a page of names skipping names of baskets in trash, fetch returns pages of all names;
+// returns the names, position of the last name among all names and whether more names follow
+func (db *visibleDatabase) scan(fetch func(max int, skip int) ([]string, bool), max int, skip int) ([]string, int, bool) {
+	names := make([]string, 0, max)
+	last := 0
+	for offset := 0; ; {
+		page, hasMore := fetch(searchNamesChunk, offset)
+		for i, name := range page {
+			if isTrashName(name) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(names) == max {
+				return names, last, true
+			}
+			names = append(names, name)
+			last = offset + i
+		}
+		offset += len(page)
+		if !hasMore || len(page) == 0 {
+			return names, last, false
+		}
+	}
+}
+
+func visibleBaskets(baskets []*BasketInfo, max int) []*BasketInfo {
+	visible := make([]*BasketInfo, 0, max)
+	for _, info := range baskets {
+		if !isTrashName(info.Name) && len(visible) < max {
+			visible = append(visible, info)
+		}
+	}
+	return visible
+}
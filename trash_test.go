@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrashName(t *testing.T) {
+	trashed := trashName(1700000000000)
+	deleted, ok := parseTrashName(trashed)
+	assert.True(t, ok, "name in trash is expected")
+	assert.Equal(t, int64(1700000000000), deleted, "wrong date of deletion")
+	assert.NotEqual(t, trashed, trashName(1700000000000), "names in trash are expected to be unique")
+
+	_, ok = parseTrashName("demo")
+	assert.False(t, ok, "name of basket is not expected to be in trash")
+	_, ok = parseTrashName("~demo")
+	assert.False(t, ok, "invalid name in trash is not expected")
+}
+
+func TestBasketTrash(t *testing.T) {
+	raw := NewMemoryDatabase()
+	defer raw.Release()
+
+	for _, name := range []string{"trash01", "trash02", "trash03"} {
+		raw.Create(name, BasketConfig{Capacity: 20})
+	}
+	raw.Get("trash02").AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+
+	bt := newBasketTrash(raw, time.Hour)
+	db := &visibleDatabase{raw, bt}
+	now := time.Now()
+	assert.NoError(t, bt.Add("trash02", now))
+	assert.Error(t, bt.Add("unknown", now), "unknown basket is not expected")
+
+	// basket in trash is hidden
+	assert.Nil(t, db.Get("trash02"), "basket in trash is not expected")
+	for trashed := range bt.deleted {
+		assert.Nil(t, db.Get(trashed), "basket in trash is not expected")
+	}
+	assert.Equal(t, 2, db.Size(), "wrong number of baskets")
+	page := db.GetNames(1, 0)
+	assert.Equal(t, []string{"trash01"}, page.Names, "wrong names of baskets")
+	assert.True(t, page.HasMore, "more names are expected")
+	position, _ := DecodeCursor(page.NextCursor)
+	assert.Equal(t, []string{"trash03"}, db.GetNamesAfter(position, 5).Names, "wrong names of baskets")
+	assert.Equal(t, []string{"trash03"}, db.GetNames(5, 1).Names, "wrong names of baskets")
+	assert.Equal(t, []string{"trash01", "trash03"}, db.FindNames("trash", 5, 0).Names, "wrong found names")
+	for _, info := range db.GetStats(5).TopBasketsByDate {
+		assert.NotEqual(t, "trash02", info.Name, "basket in trash is not expected in statistics")
+	}
+
+	if list := bt.List(); assert.Len(t, list, 1, "wrong number of baskets in trash") {
+		assert.Equal(t, "trash02", list[0].Name, "wrong basket in trash")
+		assert.Equal(t, 1, list[0].Requests, "wrong number of requests")
+		assert.Equal(t, list[0].Deleted+3600000, list[0].Purged, "wrong date of permanent deletion")
+	}
+
+	// baskets in trash are found after restart
+	assert.Equal(t, 1, newBasketTrash(raw, time.Hour).Size(), "basket in trash is expected after restart")
+
+	// restore
+	restored, err := bt.Restore("trash01", 0)
+	assert.NoError(t, err)
+	assert.Nil(t, restored, "basket is not expected in trash")
+	restored, err = bt.Restore("trash02", 0)
+	if assert.NoError(t, err) && assert.NotNil(t, restored, "basket is expected to be restored") {
+		assert.Equal(t, "trash02", restored.Name, "wrong restored basket")
+	}
+	if basket := db.Get("trash02"); assert.NotNil(t, basket, "restored basket is expected") {
+		assert.Equal(t, 1, basket.Size(), "requests of restored basket are expected")
+	}
+	assert.Equal(t, 0, bt.Size(), "trash is expected to be empty")
+}
+
+func TestBasketTrash_Purge(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create("trash04", BasketConfig{Capacity: 20})
+	bt := newBasketTrash(db, time.Hour)
+	now := time.Now()
+	bt.Add("trash04", now)
+
+	assert.Empty(t, bt.Purge(now.Add(time.Minute)), "basket is not expected to be purged in grace period")
+	assert.Equal(t, []string{"trash04"}, bt.Purge(now.Add(time.Hour)), "wrong purged baskets")
+	assert.Equal(t, 0, db.Size(), "basket is expected to be deleted permanently")
+	assert.Empty(t, bt.List(), "trash is expected to be empty")
+}
+
+func TestBasketTrash_LongName(t *testing.T) {
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	// name in trash fits into storage of basket names regardless of the length of basket name
+	name := strings.Repeat("t", 250)
+	db.Create(name, BasketConfig{Capacity: 20})
+	bt := newBasketTrash(db, time.Hour)
+	assert.NoError(t, bt.Add(name, time.Now()))
+	for trashed := range bt.deleted {
+		assert.True(t, len(trashed) <= len(name), "name in trash is expected to fit column of basket names: %s", trashed)
+	}
+
+	// original name is found after restart
+	if list := newBasketTrash(db, time.Hour).List(); assert.Len(t, list, 1, "wrong number of baskets in trash") {
+		assert.Equal(t, name, list[0].Name, "wrong basket in trash")
+	}
+	restored, err := bt.Restore(name, 0)
+	if assert.NoError(t, err) && assert.NotNil(t, restored, "basket is expected to be restored") {
+		assert.Equal(t, name, restored.Name, "wrong restored basket")
+	}
+	if basket := db.Get(name); assert.NotNil(t, basket, "restored basket is expected") {
+		assert.Empty(t, basket.GetSecrets(), "secrets of trash are not expected in restored basket")
+	}
+}
+
+func TestRestoreBasket(t *testing.T) {
+	call := func(method string, url string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+url, nil)
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "trash05"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).AddRequest(&RequestData{Date: 1000, Method: "GET", Path: "/"})
+
+	assert.Equal(t, 204, call("DELETE", "/baskets/"+basket, auth.Token).Code, "wrong HTTP result code")
+	assert.Nil(t, basketsDb.Get(basket), "deleted basket is not expected")
+	assert.Equal(t, 404, call("GET", "/baskets/"+basket, serverConfig.MasterToken).Code, "wrong HTTP result code")
+
+	assert.Equal(t, 401, call("GET", "/trash", auth.Token).Code, "master token is expected")
+	w := call("GET", "/trash", serverConfig.MasterToken)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		list := []*TrashedBasket{}
+		json.Unmarshal(w.Body.Bytes(), &list)
+		if assert.NotEmpty(t, list, "deleted basket is expected in trash") {
+			assert.Equal(t, basket, list[0].Name, "wrong basket in trash")
+		}
+	}
+
+	// basket with the same name blocks restoring
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	assert.Equal(t, 409, call("POST", "/trash/"+basket+"/restore", serverConfig.MasterToken).Code, "wrong HTTP result code")
+	basketsDb.Delete(basket)
+
+	assert.Equal(t, 200, call("POST", "/trash/"+basket+"/restore", serverConfig.MasterToken).Code, "wrong HTTP result code")
+	if restored := basketsDb.Get(basket); assert.NotNil(t, restored, "restored basket is expected") {
+		assert.Equal(t, 1, restored.Size(), "requests of restored basket are expected")
+	}
+	assert.Equal(t, 404, call("POST", "/trash/unknown05/restore", serverConfig.MasterToken).Code, "wrong HTTP result code")
+}
+
+func TestRestoreBasket_Owner(t *testing.T) {
+	call := func(method string, url string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555/api"+url, nil)
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	basket := "trash06"
+	owner, err := users.Create("trash_owner06", UserConfig{MaxBaskets: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer users.Delete("trash_owner06")
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	users.Claim("trash_owner06", basket)
+
+	// owner keeps basket in trash along with its quota slot
+	assert.Equal(t, 204, call("DELETE", "/baskets/"+basket, auth.Token).Code, "wrong HTTP result code")
+	assert.Empty(t, users.Owner(basket), "deleted basket is not expected to be owned under its name")
+	if user := users.Get("trash_owner06"); assert.NotNil(t, user) {
+		assert.Len(t, user.Baskets, 1, "basket in trash is expected to be kept by its owner")
+	}
+	status, _ := users.Claim("trash_owner06", "trash06b")
+	assert.Equal(t, http.StatusForbidden, status, "basket in trash is expected to take quota slot")
+
+	// restored basket is owned again
+	assert.Equal(t, 200, call("POST", "/trash/"+basket+"/restore", serverConfig.MasterToken).Code, "wrong HTTP result code")
+	assert.Equal(t, "trash_owner06", users.Owner(basket), "restored basket is expected to be owned")
+	assert.True(t, users.Authorize(owner.Token, basket), "owner is expected to be authorized")
+	basketsDb.Delete(basket)
+	users.Release(basket)
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	userNamePattern = `^[\w\d\-_\.]{1,100}$`
+	maxUserSessions = 20 // the oldest session of user is revoked once the limit is exceeded
+	sessionIDLength = 12
+)
+
+var validUserName = regexp.MustCompile(userNamePattern)
+
+var users *userDirectory
+
+// User describes account that owns baskets, the token of the account authorizes access to all owned baskets.
+type User struct {
+	Name        string   `json:"name"`
+	MaxBaskets  int      `json:"max_baskets"`  // quota of owned baskets, 0 - unlimited
+	MaxCapacity int      `json:"max_capacity"` // quota of capacity of every owned basket, 0 - unlimited
+	MaxBytes    int64    `json:"max_bytes"`    // quota of bytes stored in all owned baskets, 0 - unlimited
+	Baskets     []string `json:"baskets"`
+	Created     int64    `json:"created"`
+	Groups      []string `json:"groups,omitempty"` // teams of the user, see basket ACL
+}
+
+// UserConfig describes settings of user account that are managed with the master token.
+type UserConfig struct {
+	MaxBaskets  int      `json:"max_baskets"`
+	MaxCapacity int      `json:"max_capacity"`
+	MaxBytes    int64    `json:"max_bytes"`
+	Groups      []string `json:"groups,omitempty"`
+}
+
+// UserAuth describes authorization details of a new user account.
+type UserAuth struct {
+	Token string `json:"token"`
+}
+
+// UserSession describes sign in of user to web UI, the token of session grants the same access as the token of
+// user account and is revoked separately, e.g. once the device of the user is lost
+type UserSession struct {
+	ID        string `json:"id"`
+	Origin    string `json:"origin"` // the way user signed in: oidc, ldap or proxy
+	Address   string `json:"address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Created   int64  `json:"created"`
+	Current   bool   `json:"current,omitempty"` // the session of the token presented with request
+}
+
+// userSession describes stored session of user
+type userSession struct {
+	UserSession
+	Token string `json:"token"`
+}
+
+// userAccount describes stored user account
+type userAccount struct {
+	User
+	Token    string         `json:"token"`
+	Identity string         `json:"identity,omitempty"` // identity at OpenID Connect provider that signs in as the user
+	Sessions []*userSession `json:"sessions,omitempty"`
+}
+
+// userDirectory keeps user accounts and ownership of baskets, accounts are stored in JSON file if
+// the file is configured, otherwise accounts are kept in memory only
+type userDirectory struct {
+	sync.RWMutex
+	file     string
+	accounts map[string]*userAccount
+	tokens   map[string]string // user name by token
+	owners   map[string]string // user name by owned basket
+}
+
+// newUserDirectory creates user directory and loads accounts from the file, ownership of baskets that
+// no longer exist in database is dropped
+func newUserDirectory(file string, db BasketsDatabase) (*userDirectory, error) {
+	d := &userDirectory{
+		file:     file,
+		accounts: make(map[string]*userAccount),
+		tokens:   make(map[string]string),
+		owners:   make(map[string]string)}
+	if len(file) == 0 {
+		return d, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		log.Printf("[info] users file is not found, it is created once the first user signs up: %s", file)
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %s - %s", file, err)
+	}
+
+	accounts := []*userAccount{}
+	if err = json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse users file: %s - %s", file, err)
+	}
+	for _, account := range accounts {
+		if !validUserName.MatchString(account.Name) || len(account.Token) == 0 {
+			return nil, fmt.Errorf("invalid user in users file: %s", account.Name)
+		}
+
+		owned := make([]string, 0, len(account.Baskets))
+		for _, basket := range account.Baskets {
+			if db.Get(basket) != nil {
+				owned = append(owned, basket)
+				d.owners[basket] = account.Name
+			}
+		}
+		account.Baskets = owned
+		d.accounts[account.Name] = account
+		d.tokens[account.Token] = account.Name
+		for _, session := range account.Sessions {
+			d.tokens[session.Token] = account.Name
+		}
+	}
+	log.Printf("[info] loaded %d users from file: %s", len(accounts), file)
+
+	return d, nil
+}
+
+// Create creates user account and returns its token
+func (d *userDirectory) Create(name string, config UserConfig) (UserAuth, error) {
+	auth := UserAuth{}
+	token, err := GenerateToken()
+	if err != nil {
+		return auth, fmt.Errorf("failed to generate token: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if _, exists := d.accounts[name]; exists {
+		return auth, fmt.Errorf("User with name '%s' already exists", name)
+	}
+
+	account := &userAccount{newUser(name, config), token, "", nil}
+	d.accounts[name] = account
+	d.tokens[token] = name
+	d.save()
+
+	auth.Token = token
+	return auth, nil
+}
+
+// Provision returns token of user account that belongs to the identity of OpenID Connect provider, the account
+// is created on the first sign in; fails if account with the same name belongs to other identity or has no identity
+func (d *userDirectory) Provision(name string, identity string, config UserConfig) (UserAuth, error) {
+	auth := UserAuth{}
+	token, err := GenerateToken()
+	if err != nil {
+		return auth, fmt.Errorf("failed to generate token: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if account, exists := d.accounts[name]; exists {
+		if account.Identity != identity {
+			return auth, fmt.Errorf("User with name '%s' already exists", name)
+		}
+		auth.Token = account.Token
+		return auth, nil
+	}
+
+	account := &userAccount{newUser(name, config), token, identity, nil}
+	d.accounts[name] = account
+	d.tokens[token] = name
+	d.save()
+
+	auth.Token = token
+	return auth, nil
+}
+
+// SignIn starts a new session of the user the token belongs to, e.g. once the user signs in to web UI, and returns
+// the token of session; tokens that do not belong to user accounts, e.g. the master token, are returned as is
+func (d *userDirectory) SignIn(token string, origin string, r *http.Request) (string, error) {
+	session, err := GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %s", err)
+	}
+	id, err := GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	name, exists := d.tokens[token]
+	if !exists || len(token) == 0 {
+		return token, nil
+	}
+
+	agent := r.UserAgent()
+	if len(agent) > 200 {
+		agent = agent[:200]
+	}
+	address := r.RemoteAddr
+	if ip := remoteIP(r); ip != nil {
+		address = ip.String()
+	}
+
+	account := d.accounts[name]
+	account.Sessions = append(account.Sessions, &userSession{UserSession{ID: id[:sessionIDLength], Origin: origin,
+		Address: address, UserAgent: agent, Created: time.Now().UnixNano() / toMs}, session})
+	if len(account.Sessions) > maxUserSessions {
+		delete(d.tokens, account.Sessions[0].Token)
+		account.Sessions = account.Sessions[1:]
+	}
+	d.tokens[session] = name
+	d.save()
+
+	return session, nil
+}
+
+// Sessions returns sessions of user, the oldest session comes first; the session of token is marked as current
+func (d *userDirectory) Sessions(name string, token string) []UserSession {
+	d.RLock()
+	defer d.RUnlock()
+
+	sessions := []UserSession{}
+	if account, exists := d.accounts[name]; exists {
+		for _, s := range account.Sessions {
+			session := s.UserSession
+			session.Current = len(token) > 0 && s.Token == token
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+// SignOut revokes session of user, false is returned if session is not found
+func (d *userDirectory) SignOut(name string, id string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists {
+		return false
+	}
+
+	for i, session := range account.Sessions {
+		if session.ID == id {
+			delete(d.tokens, session.Token)
+			account.Sessions = append(account.Sessions[:i], account.Sessions[i+1:]...)
+			d.save()
+			return true
+		}
+	}
+	return false
+}
+
+// SignOutAll revokes all sessions of user and replaces the token of user account, the new token is returned
+func (d *userDirectory) SignOutAll(name string) (UserAuth, error) {
+	auth := UserAuth{}
+	token, err := GenerateToken()
+	if err != nil {
+		return auth, fmt.Errorf("failed to generate token: %s", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists {
+		return auth, fmt.Errorf("user not found: %s", name)
+	}
+
+	for _, session := range account.Sessions {
+		delete(d.tokens, session.Token)
+	}
+	delete(d.tokens, account.Token)
+	account.Sessions = nil
+	account.Token = token
+	d.tokens[token] = name
+	d.save()
+
+	auth.Token = token
+	return auth, nil
+}
+
+// Get returns copy of user account, nil is returned if user is not found
+func (d *userDirectory) Get(name string) *User {
+	d.RLock()
+	defer d.RUnlock()
+
+	if account, exists := d.accounts[name]; exists {
+		return account.copy()
+	}
+	return nil
+}
+
+// Authenticate returns user account the token belongs to, nil is returned if token is unknown
+func (d *userDirectory) Authenticate(token string) *User {
+	if len(token) == 0 {
+		return nil
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	if name, exists := d.tokens[token]; exists {
+		return d.accounts[name].copy()
+	}
+	return nil
+}
+
+// Authorize checks if token belongs to the owner of basket
+func (d *userDirectory) Authorize(token string, basket string) bool {
+	d.RLock()
+	defer d.RUnlock()
+
+	owner, owned := d.owners[basket]
+	return owned && len(token) > 0 && d.tokens[token] == owner
+}
+
+// List returns all user accounts sorted by name
+func (d *userDirectory) List() []*User {
+	d.RLock()
+	defer d.RUnlock()
+
+	list := make([]*User, 0, len(d.accounts))
+	for _, account := range d.accounts {
+		list = append(list, account.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Update updates settings of user account
+func (d *userDirectory) Update(name string, config UserConfig) error {
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists {
+		return fmt.Errorf("user not found: %s", name)
+	}
+
+	account.MaxBaskets = config.MaxBaskets
+	account.MaxCapacity = config.MaxCapacity
+	account.MaxBytes = config.MaxBytes
+	account.Groups = config.Groups
+	d.save()
+	return nil
+}
+
+// SetGroups replaces groups of user account, e.g. with groups of identity reported by authentication proxy
+func (d *userDirectory) SetGroups(name string, groups []string) {
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists || strings.Join(account.Groups, ",") == strings.Join(groups, ",") {
+		return
+	}
+
+	account.Groups = groups
+	d.save()
+}
+
+// Delete deletes user account and returns names of owned baskets, false is returned if user is not found
+func (d *userDirectory) Delete(name string) ([]string, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists {
+		return nil, false
+	}
+
+	for _, basket := range account.Baskets {
+		delete(d.owners, basket)
+	}
+	delete(d.tokens, account.Token)
+	for _, session := range account.Sessions {
+		delete(d.tokens, session.Token)
+	}
+	delete(d.accounts, name)
+	d.save()
+
+	return account.Baskets, true
+}
+
+// Owner returns name of the user that owns basket, empty if basket has no owner
+func (d *userDirectory) Owner(basket string) string {
+	d.RLock()
+	defer d.RUnlock()
+
+	return d.owners[basket]
+}
+
+// Claim assigns basket to user and returns HTTP status for failed claim, fails if the quota of user is exhausted
+// or basket is owned already; the basket is claimed before it is created, so concurrent creations cannot exceed the quota
+func (d *userDirectory) Claim(name string, basket string) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	account, exists := d.accounts[name]
+	if !exists {
+		return http.StatusNotFound, fmt.Errorf("user not found: %s", name)
+	}
+	if _, owned := d.owners[basket]; owned {
+		return http.StatusConflict, fmt.Errorf("Basket with name '%s' already exists", basket)
+	}
+	if account.MaxBaskets > 0 && len(account.Baskets) >= account.MaxBaskets {
+		return http.StatusForbidden, fmt.Errorf("user '%s' may not own more than %d baskets", name, account.MaxBaskets)
+	}
+
+	account.Baskets = append(account.Baskets, basket)
+	sort.Strings(account.Baskets)
+	d.owners[basket] = name
+	d.save()
+	return http.StatusOK, nil
+}
+
+// Release drops ownership of basket, e.g. once basket is deleted
+func (d *userDirectory) Release(basket string) {
+	d.Lock()
+	defer d.Unlock()
+
+	if name, owned := d.owners[basket]; owned {
+		account := d.accounts[name]
+		account.Baskets = removeName(account.Baskets, basket)
+		delete(d.owners, basket)
+		d.save()
+	}
+}
+
+// Rename moves ownership of renamed basket to its new name
+func (d *userDirectory) Rename(basket string, newName string) {
+	d.Lock()
+	defer d.Unlock()
+
+	if name, owned := d.owners[basket]; owned {
+		account := d.accounts[name]
+		account.Baskets = append(removeName(account.Baskets, basket), newName)
+		sort.Strings(account.Baskets)
+		delete(d.owners, basket)
+		d.owners[newName] = name
+		d.save()
+	}
+}
+
+// save writes accounts to the file if the file is configured, the file is replaced at once,
+// so readers never observe partially written file
+func (d *userDirectory) save() {
+	if len(d.file) == 0 {
+		return
+	}
+
+	accounts := make([]*userAccount, 0, len(d.accounts))
+	for _, account := range d.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err == nil {
+		if err = ioutil.WriteFile(d.file+".tmp", data, 0600); err == nil {
+			err = os.Rename(d.file+".tmp", d.file)
+		}
+	}
+	if err != nil {
+		log.Printf("[error] failed to save users file: %s - %s", d.file, err)
+	}
+}
+
+// IsMember checks if user is a member of the group
+func (user *User) IsMember(group string) bool {
+	for _, g := range user.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNames returns page of names of owned baskets, see BasketsDatabase
+func (user *User) GetNames(max int, skip int) BasketNamesPage {
+	page := BasketNamesPage{make([]string, 0, max), len(user.Baskets), false, ""}
+	if skip < len(user.Baskets) {
+		page.Names = append(page.Names, user.Baskets[skip:]...)
+	}
+	return user.limit(page, max)
+}
+
+// GetNamesAfter returns page of names of owned baskets after the name at position, see BasketsDatabase
+func (user *User) GetNamesAfter(position string, max int) BasketNamesPage {
+	page := BasketNamesPage{make([]string, 0, max), len(user.Baskets), false, ""}
+	for i, name := range user.Baskets {
+		if name > position {
+			page.Names = append(page.Names, user.Baskets[i:]...)
+			break
+		}
+	}
+	return user.limit(page, max)
+}
+
+// FindNames returns page of names of owned baskets that contain query, see BasketsDatabase
+func (user *User) FindNames(query string, max int, skip int) BasketNamesQueryPage {
+	page := BasketNamesQueryPage{make([]string, 0, max), false}
+	for _, name := range user.Baskets {
+		if !strings.Contains(name, query) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+		} else if len(page.Names) < max {
+			page.Names = append(page.Names, name)
+		} else {
+			page.HasMore = true
+			break
+		}
+	}
+	return page
+}
+
+// limit cuts page of sorted names to max names
+func (user *User) limit(page BasketNamesPage, max int) BasketNamesPage {
+	if len(page.Names) > max {
+		page.Names = page.Names[:max]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(page.Names[max-1])
+	}
+	return page
+}
+
+// newUser creates user without owned baskets
+func newUser(name string, config UserConfig) User {
+	return User{Name: name, MaxBaskets: config.MaxBaskets, MaxCapacity: config.MaxCapacity, MaxBytes: config.MaxBytes,
+		Baskets: []string{}, Created: time.Now().UnixNano() / toMs, Groups: config.Groups}
+}
+
+func (account *userAccount) copy() *User {
+	user := account.User
+	user.Baskets = append([]string{}, account.Baskets...)
+	user.Groups = append([]string(nil), account.Groups...)
+	return &user
+}
+
+// removeName returns copy of names without the name
+func removeName(names []string, name string) []string {
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDirectory_Claim(t *testing.T) {
+	d, err := newUserDirectory("", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	auth, err := d.Create("user01", UserConfig{MaxBaskets: 2})
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, auth.Token, "token is expected")
+	}
+	_, err = d.Create("user01", UserConfig{})
+	assert.Error(t, err, "user with the same name is not expected")
+	d.Create("user02", UserConfig{})
+
+	// baskets are claimed within quota
+	status, err := d.Claim("user01", "basket01")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, "wrong status")
+	status, _ = d.Claim("user02", "basket01")
+	assert.Equal(t, http.StatusConflict, status, "basket is owned already")
+	d.Claim("user01", "basket02")
+	status, err = d.Claim("user01", "basket03")
+	assert.Equal(t, http.StatusForbidden, status, "quota is expected to be exceeded")
+	assert.Contains(t, err.Error(), "may not own more than 2 baskets", "wrong error")
+	status, _ = d.Claim("unknown", "basket03")
+	assert.Equal(t, http.StatusNotFound, status, "unknown user is not expected")
+
+	// ownership is authorized by user token only
+	assert.True(t, d.Authorize(auth.Token, "basket01"), "owner is expected to be authorized")
+	assert.False(t, d.Authorize(auth.Token, "basket03"), "basket without owner is not expected to be authorized")
+	assert.False(t, d.Authorize("", "basket03"), "empty token is not expected to be authorized")
+	if user := d.Authenticate(auth.Token); assert.NotNil(t, user, "user is expected") {
+		assert.Equal(t, "user01", user.Name, "wrong user")
+	}
+	assert.Nil(t, d.Authenticate("wrong"), "unknown token is not expected")
+
+	// released and renamed baskets
+	d.Release("basket01")
+	assert.Empty(t, d.Owner("basket01"), "released basket is not expected to be owned")
+	d.Rename("basket02", "basket00")
+	assert.Equal(t, "user01", d.Owner("basket00"), "renamed basket is expected to be owned")
+	d.Update("user01", UserConfig{MaxBaskets: 0})
+	for _, name := range []string{"basket04", "basket05", "basket06"} {
+		_, err = d.Claim("user01", name)
+		assert.NoError(t, err, "unlimited quota is expected")
+	}
+	assert.Equal(t, []string{"basket00", "basket04", "basket05", "basket06"}, d.Get("user01").Baskets, "wrong baskets")
+
+	// deleted user releases all baskets
+	owned, found := d.Delete("user01")
+	assert.True(t, found, "user is expected to be deleted")
+	assert.Len(t, owned, 4, "owned baskets are expected")
+	assert.Empty(t, d.Owner("basket04"), "basket of deleted user is not expected to be owned")
+	assert.Nil(t, d.Authenticate(auth.Token), "token of deleted user is not expected")
+	_, found = d.Delete("user01")
+	assert.False(t, found, "user is already deleted")
+	assert.Len(t, d.List(), 1, "single user is expected")
+}
+
+func TestUserDirectory_File(t *testing.T) {
+	file := "users01.json"
+	defer os.Remove(file)
+
+	db := NewMemoryDatabase()
+	defer db.Release()
+	db.Create("basket01", BasketConfig{Capacity: 20})
+
+	d, err := newUserDirectory(file, db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	auth, _ := d.Create("user01", UserConfig{MaxBaskets: 5})
+	d.Claim("user01", "basket01")
+	d.Claim("user01", "basket02")
+
+	// baskets that do not exist are dropped on load
+	restored, err := newUserDirectory(file, db)
+	if assert.NoError(t, err) {
+		if user := restored.Authenticate(auth.Token); assert.NotNil(t, user, "user is expected to be restored") {
+			assert.Equal(t, 5, user.MaxBaskets, "wrong quota")
+			assert.Equal(t, []string{"basket01"}, user.Baskets, "wrong baskets")
+		}
+	}
+
+	ioutil.WriteFile(file, []byte("{"), 0600)
+	_, err = newUserDirectory(file, db)
+	assert.Error(t, err, "invalid file is not expected")
+	ioutil.WriteFile(file, []byte(`[{"name": "user/01", "token": "abc"}]`), 0600)
+	_, err = newUserDirectory(file, db)
+	assert.Error(t, err, "invalid user name is not expected")
+}
+
+func TestUser_GetNames(t *testing.T) {
+	user := &User{Name: "user01", Baskets: []string{"a01", "a02", "b01", "b02", "b03"}}
+
+	page := user.GetNames(2, 1)
+	assert.Equal(t, []string{"a02", "b01"}, page.Names, "wrong names")
+	assert.Equal(t, 5, page.Count, "wrong count")
+	assert.True(t, page.HasMore, "more names are expected")
+	if position, err := DecodeCursor(page.NextCursor); assert.NoError(t, err) {
+		assert.Equal(t, "b01", position, "wrong cursor")
+
+		page = user.GetNamesAfter(position, 5)
+		assert.Equal(t, []string{"b02", "b03"}, page.Names, "wrong names after cursor")
+		assert.False(t, page.HasMore, "no more names are expected")
+	}
+	assert.Empty(t, user.GetNames(5, 10).Names, "no names are expected")
+
+	found := user.FindNames("b0", 1, 1)
+	assert.Equal(t, []string{"b02"}, found.Names, "wrong found names")
+	assert.True(t, found.HasMore, "more found names are expected")
+	assert.False(t, user.FindNames("b0", 5, 0).HasMore, "no more found names are expected")
+}
+
+func TestUserDirectory_Provision(t *testing.T) {
+	d, _ := newUserDirectory("", nil)
+	d.Create("user01", UserConfig{})
+
+	auth, err := d.Provision("user02", "https://idp.example.com#02", UserConfig{MaxBaskets: 3})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, d.Authenticate(auth.Token).MaxBaskets, "wrong quota")
+	}
+	again, err := d.Provision("user02", "https://idp.example.com#02", UserConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Token, again.Token, "the same token is expected for the same identity")
+
+	_, err = d.Provision("user02", "https://idp.example.com#03", UserConfig{})
+	assert.Error(t, err, "account of other identity is not expected")
+	_, err = d.Provision("user01", "https://idp.example.com#01", UserConfig{})
+	assert.Error(t, err, "account without identity is not expected")
+}
+
+func TestUserDirectory_Sessions(t *testing.T) {
+	d, _ := newUserDirectory("", nil)
+	auth, _ := d.Create("user01", UserConfig{})
+	r, _ := http.NewRequest("GET", "http://localhost:55555/web", nil)
+	r.RemoteAddr = "192.0.2.10:4321"
+	r.Header.Set("User-Agent", "test-browser")
+
+	// tokens of other users are returned as is
+	token, err := d.SignIn("unknown", "oidc", r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "unknown", token, "token is expected as is")
+	}
+
+	session, err := d.SignIn(auth.Token, "oidc", r)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, auth.Token, session, "token of session is expected")
+	if user := d.Authenticate(session); assert.NotNil(t, user, "session is expected to authenticate") {
+		assert.Equal(t, "user01", user.Name, "wrong user")
+	}
+	sessions := d.Sessions("user01", session)
+	if assert.Len(t, sessions, 1, "session is expected") {
+		assert.Equal(t, "oidc", sessions[0].Origin, "wrong origin")
+		assert.Equal(t, "192.0.2.10", sessions[0].Address, "wrong address")
+		assert.Equal(t, "test-browser", sessions[0].UserAgent, "wrong user agent")
+		assert.True(t, sessions[0].Current, "current session is expected")
+	}
+
+	// the oldest sessions are revoked
+	for i := 0; i < maxUserSessions; i++ {
+		d.SignIn(auth.Token, "ldap", r)
+	}
+	assert.Len(t, d.Sessions("user01", ""), maxUserSessions, "sessions are expected to be limited")
+	assert.Nil(t, d.Authenticate(session), "the oldest session is expected to be revoked")
+
+	// single session is revoked
+	other := d.Sessions("user01", "")[0]
+	assert.True(t, d.SignOut("user01", other.ID), "session is expected to be revoked")
+	assert.False(t, d.SignOut("user01", other.ID), "session is already revoked")
+	assert.Len(t, d.Sessions("user01", ""), maxUserSessions-1, "wrong number of sessions")
+
+	// all sessions and the token of account are revoked
+	session, _ = d.SignIn(auth.Token, "proxy", r)
+	renewed, err := d.SignOutAll("user01")
+	if assert.NoError(t, err) {
+		assert.Empty(t, d.Sessions("user01", ""), "sessions are not expected")
+		assert.Nil(t, d.Authenticate(session), "session is expected to be revoked")
+		assert.Nil(t, d.Authenticate(auth.Token), "old token is expected to be replaced")
+		assert.NotNil(t, d.Authenticate(renewed.Token), "new token is expected")
+	}
+	_, err = d.SignOutAll("user02")
+	assert.Error(t, err, "unknown user is not expected")
+}
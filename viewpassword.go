@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	minViewPasswordLength = 6
+	maxViewPasswordLength = 100
+	viewSessionTTL        = 12 * time.Hour
+	maxViewLoginAttempts  = 10 // per minute per client and basket
+)
+
+// viewLoginLimiter limits attempts to guess passwords of basket web views
+var viewLoginLimiter = newRateLimiter(maxViewLoginAttempts, time.Minute)
+
+// ViewPassword describes password of read-only web view of basket, the password is stored as a hash
+type ViewPassword struct {
+	Password string `json:"password"`
+}
+
+// validateViewPassword validates a new password of basket web view
+func validateViewPassword(password ViewPassword) error {
+	if len(password.Password) < minViewPasswordLength || len(password.Password) > maxViewPasswordLength {
+		return fmt.Errorf("view password should be between %d and %d characters long", minViewPasswordLength,
+			maxViewPasswordLength)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateViewPassword(t *testing.T) {
+	assert.NoError(t, validateViewPassword(ViewPassword{Password: "secret"}))
+	assert.Error(t, validateViewPassword(ViewPassword{}), "empty password is not expected")
+	assert.Error(t, validateViewPassword(ViewPassword{Password: "short"}), "short password is not expected")
+	assert.Error(t, validateViewPassword(ViewPassword{Password: strings.Repeat("x", maxViewPasswordLength+1)}),
+		"long password is not expected")
+}
+
+func TestBasketViewPassword(t *testing.T) {
+	basket := "viewpass01"
+	auth, err := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	call := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "http://localhost:55555"+path, strings.NewReader(body))
+		r.Header.Add("Authorization", token)
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	path := "/api/baskets/" + basket
+	assert.Equal(t, 404, call("POST", path+"/view-login", "", `{"password":"secret"}`).Code,
+		"login is not expected without password")
+	assert.Equal(t, 401, call("PUT", path+"/view-password", "wrong", `{"password":"secret"}`).Code,
+		"wrong HTTP result code")
+	assert.Equal(t, 400, call("PUT", path+"/view-password", auth.Token, `{`).Code, "wrong HTTP result code")
+	assert.Equal(t, 422, call("PUT", path+"/view-password", auth.Token, `{"password":"abc"}`).Code,
+		"wrong HTTP result code")
+	if !assert.Equal(t, 204, call("PUT", path+"/view-password", auth.Token, `{"password":"secret"}`).Code,
+		"wrong HTTP result code") {
+		return
+	}
+	assert.True(t, strings.HasPrefix(basketsDb.Get(basket).GetViewPassword(), tokenHashPrefix),
+		"hash of password is expected")
+
+	// password is exchanged for signed URLs
+	assert.Equal(t, 401, call("POST", path+"/view-login", "", `{"password":"wrong!"}`).Code, "wrong HTTP result code")
+	w := call("POST", path+"/view-login", "", `{"password":"secret"}`)
+	if assert.Equal(t, 200, w.Code, "wrong HTTP result code") {
+		signed := SignedURL{}
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &signed)) {
+			assert.InDelta(t, time.Now().Add(viewSessionTTL).UnixNano()/toMs, signed.Expires, 5000, "wrong expiration")
+			assert.Equal(t, 200, call("GET", path+"/requests", signed.Signature, "").Code, "read access is expected")
+			assert.Equal(t, 401, call("GET", path, signed.Signature, "").Code, "settings are not expected to be accessible")
+		}
+	}
+
+	// password is removed
+	assert.Equal(t, 204, call("DELETE", path+"/view-password", auth.Token, "").Code, "wrong HTTP result code")
+	assert.Equal(t, 404, call("POST", path+"/view-login", "", `{"password":"secret"}`).Code,
+		"login is not expected without password")
+
+	page := audit.Find(AuditQuery{Basket: basket, Action: AuditViewPassword}, 10, 0)
+	assert.Len(t, page.Entries, 2, "audit entries are expected")
+}
+
+func TestBasketViewPassword_Attempts(t *testing.T) {
+	basket := "viewpass02"
+	if _, err := basketsDb.Create(basket, BasketConfig{Capacity: 20}); !assert.NoError(t, err) {
+		return
+	}
+	basketsDb.Get(basket).SetViewPassword(hashBasketToken("secret"))
+
+	var code int
+	for i := 0; i <= maxViewLoginAttempts; i++ {
+		r, _ := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/view-login",
+			strings.NewReader(`{"password":"wrong!"}`))
+		r.RemoteAddr = "192.0.2.15:4321"
+		w := httptest.NewRecorder()
+		testServer.Handler.ServeHTTP(w, r)
+		code = w.Code
+		if i < maxViewLoginAttempts {
+			assert.Equal(t, 401, code, "wrong HTTP result code")
+		} else {
+			assert.NotEmpty(t, w.Header().Get("Retry-After"), "Retry-After header is expected")
+		}
+	}
+	assert.Equal(t, 429, code, "attempts are expected to be limited")
+}
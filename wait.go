@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+	waitPageSize       = 100
+)
+
+var arrivals = newRequestArrivals()
+
+// requestWaiter receives the first collected request that matches its query, nil query matches any request
+type requestWaiter struct {
+	query   *RequestsQuery
+	arrived chan *RequestData
+}
+
+// requestArrivals notifies clients waiting for requests collected by baskets
+type requestArrivals struct {
+	sync.Mutex
+	waiters map[string]map[*requestWaiter]bool
+}
+
+func newRequestArrivals() *requestArrivals {
+	return &requestArrivals{waiters: make(map[string]map[*requestWaiter]bool)}
+}
+
+// Wait registers waiter for the next request collected by basket, the waiter must be cancelled once it is not needed
+func (a *requestArrivals) Wait(name string, query *RequestsQuery) *requestWaiter {
+	a.Lock()
+	defer a.Unlock()
+
+	waiter := &requestWaiter{query: query, arrived: make(chan *RequestData, 1)}
+	if a.waiters[name] == nil {
+		a.waiters[name] = make(map[*requestWaiter]bool)
+	}
+	a.waiters[name][waiter] = true
+	return waiter
+}
+
+// Cancel removes waiter of basket
+func (a *requestArrivals) Cancel(name string, waiter *requestWaiter) {
+	a.Lock()
+	defer a.Unlock()
+
+	if waiters := a.waiters[name]; waiters != nil {
+		delete(waiters, waiter)
+		if len(waiters) == 0 {
+			delete(a.waiters, name)
+		}
+	}
+}
+
+// Notify passes request collected by basket to matching waiters, every waiter is notified only once
+func (a *requestArrivals) Notify(name string, request *RequestData) {
+	a.Lock()
+	defer a.Unlock()
+
+	for waiter := range a.waiters[name] {
+		if waiter.query == nil || request.Matches(waiter.query) {
+			waiter.arrived <- request
+			delete(a.waiters[name], waiter)
+		}
+	}
+	if len(a.waiters[name]) == 0 {
+		delete(a.waiters, name)
+	}
+}
+
+// findRequestAfter finds the oldest request with ID greater than given one that matches the query
+func findRequestAfter(basket Basket, after int, query *RequestsQuery) *RequestData {
+	var found *RequestData
+	for skip := 0; ; skip += waitPageSize {
+		page := basket.GetRequests(waitPageSize, skip)
+		for _, request := range page.Requests {
+			if request.ID <= after {
+				return found
+			}
+			if query == nil || request.Matches(query) {
+				found = request
+			}
+		}
+		if !page.HasMore {
+			return found
+		}
+	}
+}
+
+// parseWaitTimeout parses timeout of waiting for request, either duration (e.g. 30s) or number of seconds
+func parseWaitTimeout(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultWaitTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, errs := strconv.Atoi(value)
+		if errs != nil {
+			return 0, fmt.Errorf("invalid timeout: %s", value)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	if timeout <= 0 || timeout > maxWaitTimeout {
+		return 0, fmt.Errorf("timeout should be positive and may not be greater than %s", maxWaitTimeout)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestArrivals(t *testing.T) {
+	a := newRequestArrivals()
+
+	all := a.Wait("wait01", nil)
+	posts := a.Wait("wait01", &RequestsQuery{Method: "POST"})
+	other := a.Wait("wait02", nil)
+
+	get := &RequestData{ID: 1, Method: "GET"}
+	a.Notify("wait01", get)
+	assert.Equal(t, get, <-all.arrived, "request is expected")
+	assert.Empty(t, posts.arrived, "not matching request is not expected")
+	assert.Empty(t, other.arrived, "request of another basket is not expected")
+
+	// notified waiter is removed
+	post := &RequestData{ID: 2, Method: "POST"}
+	a.Notify("wait01", post)
+	assert.Equal(t, post, <-posts.arrived, "matching request is expected")
+	assert.Empty(t, all.arrived, "waiter is expected to be notified only once")
+	assert.Len(t, a.waiters, 1, "only waiters of another basket are expected")
+
+	a.Cancel("wait02", other)
+	assert.Empty(t, a.waiters, "no waiters are expected")
+	a.Notify("wait02", post)
+	assert.Empty(t, other.arrived, "cancelled waiter is not expected to be notified")
+}
+
+func TestFindRequestAfter(t *testing.T) {
+	name := "wait03"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 300})
+	basket := db.Get(name)
+	for i := 1; i <= 250; i++ {
+		method := "GET"
+		if i%50 == 0 {
+			method = "POST"
+		}
+		basket.AddRequest(&RequestData{Method: method, Path: "/" + name})
+	}
+
+	assert.Equal(t, 1, findRequestAfter(basket, 0, nil).ID, "the oldest request is expected")
+	assert.Equal(t, 121, findRequestAfter(basket, 120, nil).ID, "the next request is expected")
+	assert.Nil(t, findRequestAfter(basket, 250, nil), "no request is expected after the last one")
+
+	posts := &RequestsQuery{Method: "POST"}
+	assert.Equal(t, 50, findRequestAfter(basket, 0, posts).ID, "the oldest matching request is expected")
+	assert.Equal(t, 150, findRequestAfter(basket, 100, posts).ID, "the next matching request is expected")
+	assert.Nil(t, findRequestAfter(basket, 250, posts), "no matching request is expected")
+}
+
+func TestParseWaitTimeout(t *testing.T) {
+	timeout, err := parseWaitTimeout("")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultWaitTimeout, timeout, "default timeout is expected")
+
+	timeout, err = parseWaitTimeout("1500ms")
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, timeout, "wrong timeout")
+
+	timeout, err = parseWaitTimeout("45")
+	assert.NoError(t, err)
+	assert.Equal(t, 45*time.Second, timeout, "timeout in seconds is expected")
+
+	for _, value := range []string{"soon", "0", "-5s", "10m"} {
+		_, err = parseWaitTimeout(value)
+		assert.Error(t, err, "invalid timeout is not expected: %s", value)
+	}
+}
@@ -43,17 +43,29 @@ const (
       if (!token) { // fall back to master token if provided
         token = sessionStorage.getItem("master_token");
       }
+      if (!token) { // fall back to read-only share token if provided
+        token = localStorage.getItem("share_{{.Basket}}");
+      }
       return token;
     }
 
+    function isReadOnly() {
+      return !getBasketToken() && !sessionStorage.getItem("master_token") && !!localStorage.getItem("share_{{.Basket}}");
+    }
+
     function onAjaxError(jqXHR) {
       if (jqXHR.status == 401) {
         localStorage.removeItem("basket_{{.Basket}}");
+        localStorage.removeItem("share_{{.Basket}}");
         enableAutoRefresh(false);
         $("#token_dialog").modal({ keyboard : false });
       } else {
         $("#error_message_label").html("HTTP " + jqXHR.status + " - " + jqXHR.statusText);
-        $("#error_message_text").html(jqXHR.responseText);
+        var message = jqXHR.responseText;
+        try { // service API reports errors with JSON
+          message = JSON.parse(message).message || message;
+        } catch (e) {}
+        $("#error_message_text").text(message);
         $("#error_message").modal();
       }
     }
@@ -101,6 +113,10 @@ const (
         '</div><div><i class="glyphicon glyphicon-calendar" title="' + date.toString() + '"></i> ' + date.toLocaleDateString() +
         '</div></div><div class="col-md-10"><div class="panel-group" id="' + id + '">' +
         '<div class="panel panel-' + headerClass + '"><div class="panel-heading"><h4 class="panel-title">' + escapeHTML(path) +
+        (request.redacted ? ' <small>(redacted)</small>' : '') +
+        (request.signature == "verified" ? ' <small class="text-success">(signature verified)</small>' : '') +
+        (request.signature == "unverified" ? ' <small class="text-danger" title="' + escapeHTML(request.signature_error || '') +
+          '">(signature unverified)</small>' : '') +
         '<span id="' + id + '_copy_request_btn" for="' + requestId + '" class="pull-right copy-req-btn">' +
         '<span title="Copy Request Details" class="glyphicon glyphicon-copy"></span></span></h4></div></div>' +
         '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
@@ -117,7 +133,9 @@ const (
 
       if (request.body) {
         html += '<div class="panel panel-default"><div class="panel-heading"><h4 class="panel-title">' +
-          '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_body">Body</a></h4></div>' +
+          '<a class="collapsed" data-toggle="collapse" data-parent="#' + id + '" href="#' + id + '_body">Body' +
+          (request.body_truncated ? ' <small class="text-warning">(truncated)</small>' : '') +
+          (request.body_encryption ? ' <small class="text-info">(encrypted)</small>' : '') + '</a></h4></div>' +
           '<div id="' + id + '_body" class="panel-collapse collapse in">' +
           '<div class="panel-body"><pre>' + escapeHTML(request.body) + '</pre></div></div></div>';
       }
@@ -409,13 +427,28 @@ const (
         currentConfig.proxy_response != $("#basket_proxy_response").prop("checked") ||
         currentConfig.expand_path != $("#basket_expand_path").prop("checked") ||
         currentConfig.insecure_tls != $("#basket_insecure_tls").prop("checked") ||
-        currentConfig.capacity != $("#basket_capacity").val()
+        currentConfig.capacity != $("#basket_capacity").val() ||
+        (currentConfig.rate_limit ? currentConfig.rate_limit.rate : 0) != ($("#basket_rate_limit").val() || 0) ||
+        (currentConfig.rate_limit && currentConfig.rate_limit.action == "drop") != $("#basket_rate_drop").prop("checked") ||
+        !!currentConfig.pause != $("#basket_paused").prop("checked")
       )) {
         currentConfig.forward_url = $("#basket_forward_url").val();
         currentConfig.proxy_response = $("#basket_proxy_response").prop("checked");
         currentConfig.expand_path = $("#basket_expand_path").prop("checked");
         currentConfig.insecure_tls = $("#basket_insecure_tls").prop("checked");
         currentConfig.capacity = parseInt($("#basket_capacity").val());
+        var rate = parseFloat($("#basket_rate_limit").val());
+        if (rate > 0) {
+          currentConfig.rate_limit = $.extend(currentConfig.rate_limit || {}, { rate: rate,
+            action: $("#basket_rate_drop").prop("checked") ? "drop" : "reject" });
+        } else {
+          delete currentConfig.rate_limit;
+        }
+        if ($("#basket_paused").prop("checked")) {
+          currentConfig.pause = currentConfig.pause || {};
+        } else {
+          delete currentConfig.pause;
+        }
 
         $.ajax({
           method: "PUT",
@@ -471,6 +504,9 @@ const (
           $("#basket_expand_path").prop("checked", currentConfig.expand_path);
           $("#basket_insecure_tls").prop("checked", currentConfig.insecure_tls);
           $("#basket_capacity").val(currentConfig.capacity);
+          $("#basket_rate_limit").val(currentConfig.rate_limit ? currentConfig.rate_limit.rate : "");
+          $("#basket_rate_drop").prop("checked", !!currentConfig.rate_limit && currentConfig.rate_limit.action == "drop");
+          $("#basket_paused").prop("checked", !!currentConfig.pause);
           $("#config_dialog").modal();
         }
       }).fail(onAjaxError);
@@ -535,7 +571,87 @@ const (
       }
     }
 
+    function shareReadOnly() {
+      var url = "{{.Prefix}}/api/baskets/{{.Basket}}" + "/share";
+      var headers = { "Authorization" : getToken() };
+      $.ajax({
+        method: "GET",
+        url: url,
+        headers: headers
+      }).done(showReadOnlyLink).fail(function(jqXHR) {
+        if (jqXHR.status == 404) { // basket is not shared yet
+          $.ajax({
+            method: "POST",
+            url: url,
+            headers: headers
+          }).done(showReadOnlyLink).fail(onAjaxError);
+        } else {
+          onAjaxError(jqXHR);
+        }
+      });
+    }
+
+    function showReadOnlyLink(data) {
+      prompt("Anyone with this link can view requests collected by this basket,\n" +
+        "but cannot change its settings or delete anything:", window.location + "?share=" + data.token);
+    }
+
+    function shareExpiring() {
+      var hours = prompt("Anyone with the link will be able to view requests collected by this basket " +
+        "until the link expires.\nThe link expires in (hours):", "24");
+      if (hours) {
+        $.ajax({
+          method: "POST",
+          url: "{{.Prefix}}/api/baskets/{{.Basket}}/signed-url",
+          headers: { "Authorization" : getToken() },
+          contentType: "application/json",
+          data: JSON.stringify({ expires_in: Math.round(parseFloat(hours) * 3600) })
+        }).done(function(data) {
+          prompt("Anyone with this link can view requests collected by this basket until " +
+            new Date(data.expires).toLocaleString() + ":", data.url);
+        }).fail(onAjaxError);
+      }
+    }
+
+    function protectView() {
+      var password = prompt("Anyone who knows the password will be able to view requests collected by this basket " +
+        "without the token.\nEnter the password of read-only view (leave empty to remove the password):", "");
+      if (password === null) {
+        return;
+      }
+      $.ajax({
+        method: password ? "PUT" : "DELETE",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/view-password",
+        headers: { "Authorization" : getToken() },
+        contentType: "application/json",
+        data: password ? JSON.stringify({ password: password }) : null
+      }).done(function() {
+        alert(password ? "Read-only view of this basket is protected with the password." :
+          "Password of read-only view is removed.");
+      }).fail(onAjaxError);
+    }
+
+    function viewLogin(password) {
+      $.ajax({
+        method: "POST",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/view-login",
+        contentType: "application/json",
+        data: JSON.stringify({ password: password })
+      }).done(function(data) {
+        // the password is exchanged for expiring read-only share token
+        localStorage.setItem("share_{{.Basket}}", data.signature);
+        window.location.href = "{{.Prefix}}/web/{{.Basket}}";
+      }).fail(onAjaxError);
+    }
+
     function acceptSharedBasket() {
+      var share = getParam("share");
+      if (share) {
+        // remember read-only share token and remove it from location URL
+        localStorage.setItem("share_{{.Basket}}", share);
+        window.location.href = "{{.Prefix}}/web/{{.Basket}}";
+        return;
+      }
       var token = getParam("token");
       if (token) {
         var currentToken = getBasketToken();
@@ -557,11 +673,112 @@ const (
       }
     }
 
+    function pushSupported() {
+      return ("serviceWorker" in navigator) && ("PushManager" in window);
+    }
+
+    function decodePushKey(key) {
+      var padded = (key + "===".substring(0, (4 - key.length % 4) % 4)).replace(/-/g, "+").replace(/_/g, "/");
+      var raw = window.atob(padded);
+      var bytes = new Uint8Array(raw.length);
+      for (var i = 0; i < raw.length; i++) {
+        bytes[i] = raw.charCodeAt(i);
+      }
+      return bytes;
+    }
+
+    function samePushKey(buffer, key) {
+      var bytes = new Uint8Array(buffer || []);
+      if (bytes.length != key.length) {
+        return false;
+      }
+      for (var i = 0; i < key.length; i++) {
+        if (bytes[i] != key[i]) {
+          return false;
+        }
+      }
+      return true;
+    }
+
+    function showPushEnabled(enabled) {
+      var btn = $("#push");
+      if (enabled) {
+        btn.removeClass("btn-default");
+        btn.addClass("btn-success");
+        btn.attr("title", "Notifications are Enabled");
+      } else {
+        btn.removeClass("btn-success");
+        btn.addClass("btn-default");
+        btn.attr("title", "Notify me when idle basket receives a request");
+      }
+    }
+
+    // subscriptions are kept in service memory only, so the browser subscribes every time the basket page is open
+    function subscribePush(quiet) {
+      $.ajax({
+        method: "GET",
+        url: "{{.Prefix}}/api/push/key"
+      }).done(function(key) {
+        var serverKey = decodePushKey(key.public_key);
+        navigator.serviceWorker.register("{{.Prefix}}/api/push/worker.js").then(function(registration) {
+          return registration.pushManager.getSubscription().then(function(subscription) {
+            if (subscription && samePushKey(subscription.options.applicationServerKey, serverKey)) {
+              return subscription;
+            }
+            // subscription to another key is replaced, e.g. if service is restarted with a new key
+            var unsubscribed = subscription ? subscription.unsubscribe() : Promise.resolve(true);
+            return unsubscribed.then(function() {
+              return registration.pushManager.subscribe({ userVisibleOnly: true, applicationServerKey: serverKey });
+            });
+          });
+        }).then(function(subscription) {
+          $.ajax({
+            method: "POST",
+            url: "{{.Prefix}}/api/baskets/{{.Basket}}/push",
+            headers: {
+              "Authorization" : getToken()
+            },
+            contentType: "application/json",
+            data: JSON.stringify(subscription)
+          }).done(function() {
+            localStorage.setItem("push_{{.Basket}}", subscription.endpoint);
+            showPushEnabled(true);
+          }).fail(onAjaxError);
+        }).catch(function(error) {
+          if (quiet) {
+            console.log("Failed to subscribe to notifications: " + error);
+          } else {
+            alert("Failed to subscribe to notifications: " + error);
+          }
+        });
+      }).fail(onAjaxError);
+    }
+
+    function unsubscribePush() {
+      var endpoint = localStorage.getItem("push_{{.Basket}}");
+      localStorage.removeItem("push_{{.Basket}}");
+      showPushEnabled(false);
+      // browser subscription is kept since it may be used by other baskets
+      $.ajax({
+        method: "DELETE",
+        url: "{{.Prefix}}/api/baskets/{{.Basket}}/push?endpoint=" + encodeURIComponent(endpoint),
+        headers: {
+          "Authorization" : getToken()
+        }
+      });
+    }
+
     // Initialization
     $(document).ready(function() {
       $(".basket_uri").html(basketUrl);
       // dialogs
       $("#token_dialog").on("hidden.bs.modal", function (event) {
+        var password = $("#view_password").val();
+        $("#view_password").val("");
+        if (password && !$("#basket_token").val()) {
+          viewLogin(password);
+          return;
+        }
         localStorage.setItem("basket_{{.Basket}}", $("#basket_token").val());
         fetchRequests();
       });
@@ -583,9 +800,25 @@ const (
       $("#responses").on("click", function(event) {
         responses();
       });
+      $("#push").on("click", function(event) {
+        if (localStorage.getItem("push_{{.Basket}}")) {
+          unsubscribePush();
+        } else {
+          subscribePush(false);
+        }
+      });
       $("#share").on("click", function(event) {
         shareBasket();
       });
+      $("#share_readonly").on("click", function(event) {
+        shareReadOnly();
+      });
+      $("#share_expiring").on("click", function(event) {
+        shareExpiring();
+      });
+      $("#protect_view").on("click", function(event) {
+        protectView();
+      });
       $("#delete").on("click", function(event) {
         deleteRequests();
       });
@@ -614,12 +847,25 @@ const (
       if (!getBasketToken()) {
         $("#share").hide();
       }
+      // read-only share token permits viewing of collected requests only
+      var readOnly = isReadOnly();
+      if (readOnly) {
+        $("#push, #config, #responses, #share_readonly, #share_expiring, #protect_view, #delete, #destroy").hide();
+      }
       // autorefresh and initial fetch
       if (getToken()) {
         enableAutoRefresh(true);
       }
       fetchRequests();
-      fetchResponse("GET");
+      // push notifications
+      if (!pushSupported()) {
+        $("#push").hide();
+      } else if (getToken() && !readOnly && localStorage.getItem("push_{{.Basket}}")) {
+        subscribePush(true);
+      }
+      if (!readOnly) {
+        fetchResponse("GET");
+      }
     });
   })(jQuery);
   </script>
@@ -639,6 +885,9 @@ const (
           <button id="auto_refresh" type="button" title="Auto Refresh" class="btn btn-default">
             <span class="glyphicon glyphicon-repeat"></span>
           </button>
+          <button id="push" type="button" title="Notify me when idle basket receives a request" class="btn btn-default">
+            <span class="glyphicon glyphicon-bell"></span>
+          </button>
           &nbsp;
           <button id="config" type="button" title="Settings" class="btn btn-default">
             <span class="glyphicon glyphicon-cog"></span>
@@ -651,6 +900,15 @@ const (
           <button id="share" type="button" title="Share Basket" class="btn btn-default">
             <span class="glyphicon glyphicon-link"></span>
           </button>
+          <button id="share_readonly" type="button" title="Share Read-only Link" class="btn btn-default">
+            <span class="glyphicon glyphicon-eye-open"></span>
+          </button>
+          <button id="share_expiring" type="button" title="Share Expiring Read-only Link" class="btn btn-default">
+            <span class="glyphicon glyphicon-time"></span>
+          </button>
+          <button id="protect_view" type="button" title="Protect Read-only View with Password" class="btn btn-default">
+            <span class="glyphicon glyphicon-lock"></span>
+          </button>
           &nbsp;
           <button id="delete" type="button" title="Delete Requests" class="btn btn-warning">
             <span class="glyphicon glyphicon-fire"></span>
@@ -677,6 +935,10 @@ const (
             <label for="basket_token" class="control-label">Token:</label>
             <input type="password" class="form-control" id="basket_token">
           </div>
+          <div class="form-group">
+            <label for="view_password" class="control-label">Or password of read-only view:</label>
+            <input type="password" class="form-control" id="view_password">
+          </div>
         </div>
         <div class="modal-footer">
           <a href="{{.Prefix}}/web" class="btn btn-default">Back to list of Baskets</a>
@@ -719,6 +981,21 @@ const (
             <label for="basket_capacity" class="control-label">Basket Capacity:</label>
             <input type="input" class="form-control" id="basket_capacity">
           </div>
+          <div class="form-group">
+            <label for="basket_rate_limit" class="control-label">Rate Limit (requests per second, empty - unlimited):</label>
+            <input type="input" class="form-control" id="basket_rate_limit">
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" id="basket_rate_drop">
+              <abbr title="Requests over the rate limit are answered with 200 status and counted instead of 429 status">Drop Silently</abbr>
+              requests over the rate limit
+            </label>
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" id="basket_paused">
+              <abbr title="Paused basket rejects new requests while collected requests stay available">Paused</abbr>
+            </label>
+          </div>
         </div>
         <div class="modal-footer">
           <button type="button" class="btn btn-default" data-dismiss="modal">Cancel</button>
@@ -32,7 +32,11 @@ var (
         $("#master_token_dialog").modal({ keyboard : false });
       } else {
         $("#error_message_label").html("HTTP " + jqXHR.status + " - " + jqXHR.statusText);
-        $("#error_message_text").html(jqXHR.responseText);
+        var message = jqXHR.responseText;
+        try { // service API reports errors with JSON
+          message = JSON.parse(message).message || message;
+        } catch (e) {}
+        $("#error_message_text").text(message);
         $("#error_message").modal();
       }
     }
@@ -165,8 +169,24 @@ var (
 
     function saveMasterToken() {
       var token = $("#master_token").val();
+      var username = $("#ldap_username").val();
+      var password = $("#ldap_password").val();
       $("#master_token").val("");
+      $("#ldap_password").val("");
       $("#master_token_dialog").modal("hide");
+      if (username && password) {
+        // exchange credentials of directory user for token
+        $.ajax({
+          method: "POST",
+          url: "{{.Prefix}}/api/ldap/login",
+          contentType: "application/json",
+          data: JSON.stringify({ username: username, password: password })
+        }).done(function(session) {
+          sessionStorage.setItem("master_token", session.token);
+          fetchStats();
+        }).fail(onAjaxError);
+        return;
+      }
       if (token) {
         sessionStorage.setItem("master_token", token);
       } else {
@@ -257,10 +277,17 @@ var (
             <label for="master_token" class="control-label">Token:</label>
             <input type="password" class="form-control" id="master_token">
           </div>
+          {{if .LDAP}}<div class="form-group">
+            <label for="ldap_username" class="control-label">Or sign in with directory account:</label>
+            <input type="text" class="form-control" id="ldap_username" placeholder="Username" autocomplete="username">
+            <input type="password" class="form-control" id="ldap_password" placeholder="Password" autocomplete="current-password">
+          </div>{{end}}
         </div>
         <div class="modal-footer">
           <a href="{{.Prefix}}/web" class="btn btn-default">Back to list of your baskets</a>
-          <button type="submit" class="btn btn-success" data-dismiss="modal">Authorize</button>
+          {{if .SSO}}<a href="{{.Prefix}}/api/oidc/login?redirect={{.Prefix}}/web/baskets" class="btn btn-primary">Sign in with SSO</a>
+          {{else if .Proxy}}<a href="{{.Prefix}}/api/proxy/login?redirect={{.Prefix}}/web/baskets" class="btn btn-primary">Sign in with SSO</a>
+          {{end}}<button type="submit" class="btn btn-success" data-dismiss="modal">Authorize</button>
         </div>
         </form>
       </div>
@@ -24,6 +24,9 @@ var (
   (function($) {
     function randomName() {
       var name = Math.random().toString(36).substring(2, 9);
+      while (name.length < {{.NameLength}}) {
+        name += Math.random().toString(36).substring(2, 9);
+      }
       $("#basket_name").val(name);
     }
 
@@ -32,7 +35,11 @@ var (
         $("#master_token_dialog").modal({ keyboard : false });
       } else {
         $("#error_message_label").html("HTTP " + jqXHR.status + " - " + jqXHR.statusText);
-        $("#error_message_text").html(jqXHR.responseText);
+        var message = jqXHR.responseText;
+        try { // service API reports errors with JSON
+          message = JSON.parse(message).message || message;
+        } catch (e) {}
+        $("#error_message_text").text(message);
         $("#error_message").modal();
       }
     }
@@ -52,15 +59,42 @@ var (
       }
     }
 
-    function createBasket() {
+    function solveChallenge(done) {
+      // proof-of-work: find nonce, so SHA-256 hash of "<challenge>:<nonce>" starts with required number of zero bits
+      $.getJSON("{{.Prefix}}/api/challenge").done(function(c) {
+        var encoder = new TextEncoder();
+        var nonce = 0;
+        var attempt = function() {
+          crypto.subtle.digest("SHA-256", encoder.encode(c.challenge + ":" + nonce)).then(function(hash) {
+            var bytes = new Uint8Array(hash);
+            var bits = 0;
+            for (var i = 0; i < bytes.length && bytes[i] == 0; i++) {
+              bits += 8;
+            }
+            if (i < bytes.length) {
+              bits += Math.clz32(bytes[i]) - 24;
+            }
+            if (bits >= c.difficulty) {
+              done({ "X-PoW-Challenge" : c.challenge, "X-PoW-Nonce" : String(nonce) });
+            } else {
+              nonce++;
+              attempt();
+            }
+          });
+        };
+        attempt();
+      }).fail(onAjaxError);
+    }
+
+    function createBasket(challenge) {
       var basket = $.trim($("#basket_name").val());
       if (basket) {
         $.ajax({
           method: "POST",
           url: "{{.Prefix}}/api/baskets/" + basket,
-          headers: {
+          headers: $.extend({
             "Authorization" : sessionStorage.getItem("master_token")
-          }
+          }, challenge)
         }).done(function(data) {
           localStorage.setItem("basket_" + basket, data.token);
           $("#created_message_text").html("<p>Basket '" + basket +
@@ -70,9 +104,16 @@ var (
 
           // refresh
           addBasketName(basket);
-        }).always(function() {
           randomName();
-        }).fail(onAjaxError);
+        }).fail(function(jqXHR) {
+          if (jqXHR.status == 428 && !challenge) {
+            // anonymous creation requires solved challenge
+            solveChallenge(createBasket);
+          } else {
+            randomName();
+            onAjaxError(jqXHR);
+          }
+        });
       } else {
         $("#error_message_label").html("Missing basket name");
         $("#error_message_text").html("Please, provide a name of basket you would like to create");
@@ -82,8 +123,23 @@ var (
 
     function saveMasterToken() {
       var token = $("#master_token").val();
+      var username = $("#ldap_username").val();
+      var password = $("#ldap_password").val();
       $("#master_token").val("");
+      $("#ldap_password").val("");
       $("#master_token_dialog").modal("hide");
+      if (username && password) {
+        // exchange credentials of directory user for token
+        $.ajax({
+          method: "POST",
+          url: "{{.Prefix}}/api/ldap/login",
+          contentType: "application/json",
+          data: JSON.stringify({ username: username, password: password })
+        }).done(function(session) {
+          sessionStorage.setItem("master_token", session.token);
+        }).fail(onAjaxError);
+        return;
+      }
       if (token) {
         sessionStorage.setItem("master_token", token);
       } else {
@@ -178,10 +234,17 @@ var (
             <label for="master_token" class="control-label">Token:</label>
             <input type="password" class="form-control" id="master_token">
           </div>
+          {{if .LDAP}}<div class="form-group">
+            <label for="ldap_username" class="control-label">Or sign in with directory account:</label>
+            <input type="text" class="form-control" id="ldap_username" placeholder="Username" autocomplete="username">
+            <input type="password" class="form-control" id="ldap_password" placeholder="Password" autocomplete="current-password">
+          </div>{{end}}
         </div>
         <div class="modal-footer">
           <a href="." class="btn btn-default">Back to list of your baskets</a>
-          <button type="submit" class="btn btn-success" data-dismiss="modal">Authorize</button>
+          {{if .SSO}}<a href="{{.Prefix}}/api/oidc/login?redirect={{.Prefix}}/web" class="btn btn-primary">Sign in with SSO</a>
+          {{else if .Proxy}}<a href="{{.Prefix}}/api/proxy/login?redirect={{.Prefix}}/web" class="btn btn-primary">Sign in with SSO</a>
+          {{end}}<button type="submit" class="btn btn-success" data-dismiss="modal">Authorize</button>
         </div>
         </form>
       </div>
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Events that are sent to webhook subscribers
+const (
+	EventRequestReceived = "request_received"
+	EventBasketCreated   = "basket_created"
+	EventBasketExpired   = "basket_expired"
+	EventBasketIdle      = "basket_idle"
+	EventForwardFailed   = "forward_failed"
+)
+
+// Headers of webhook deliveries
+const (
+	WebhookEventHeader     = "X-Baskets-Event"
+	WebhookDeliveryHeader  = "X-Baskets-Delivery"  // ID of the event, the same for all subscribers and attempts
+	WebhookAttemptHeader   = "X-Baskets-Attempt"   // number of delivery attempt, starts from 1 once delivery is redriven
+	WebhookSignatureHeader = "X-Baskets-Signature" // "sha256=" followed by hex encoded HMAC-SHA256 of the payload
+)
+
+const (
+	maxBasketWebhooks    = 10
+	maxWebhookDeliveries = 100 // the latest deliveries that are kept per basket and for global subscribers
+	webhookMaxAttempts   = 5
+	webhookTimeout       = 10 * time.Second
+	webhookQueueSize     = 1000
+	webhookWorkers       = 4
+)
+
+// webhookEvents lists events available for subscription
+var webhookEvents = []string{EventRequestReceived, EventBasketCreated, EventBasketExpired, EventBasketIdle,
+	EventForwardFailed}
+
+// webhookRetryDelay defines delay before the second delivery attempt, the delay is doubled for every next attempt
+var webhookRetryDelay = time.Second
+
+var webhooks *webhookDispatcher
+
+// WebhookEvent describes payload of webhook delivery.
+type WebhookEvent struct {
+	ID      string       `json:"id"`
+	Event   string       `json:"event"`
+	Basket  string       `json:"basket"`
+	Date    int64        `json:"date"`
+	Request *RequestData `json:"request,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Expires int64        `json:"expires,omitempty"` // date in milliseconds when idle basket is deleted
+}
+
+// Statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery describes state of event delivery to a single subscriber. Deliveries to subscribers of a basket
+// are stored with the basket, so pending deliveries are resumed after restart of the service, while deliveries
+// to global subscribers are kept in memory only. Every event is delivered at least once unless delivery fails,
+// failed deliveries may be redriven, subscribers should use event ID to detect duplicates.
+type WebhookDelivery struct {
+	ID          string          `json:"id"`
+	EventID     string          `json:"event_id"` // sent in X-Baskets-Delivery header
+	Event       string          `json:"event"`
+	URL         string          `json:"url"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`           // attempts since delivery is created or redriven
+	Redrives    int             `json:"redrives,omitempty"` // number of times failed delivery is redriven
+	Date        int64           `json:"date"`
+	LastAttempt int64           `json:"last_attempt,omitempty"`
+	NextAttempt int64           `json:"next_attempt,omitempty"` // time of scheduled retry of pending delivery
+	LastStatus  int             `json:"last_status,omitempty"`  // response status of the last attempt
+	LastError   string          `json:"last_error,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+// WebhookRedrive describes request to redrive failed deliveries, all failed deliveries are redriven if no ID is listed
+type WebhookRedrive struct {
+	IDs []string `json:"ids,omitempty"`
+}
+
+type webhookDelivery struct {
+	config WebhookConfig
+	basket Basket // nil for deliveries to global subscribers
+	state  WebhookDelivery
+}
+
+// webhookDispatcher delivers basket events to subscribers of baskets and to global subscribers
+type webhookDispatcher struct {
+	sync.RWMutex
+	global     []WebhookConfig
+	deliveries []WebhookDelivery // deliveries to global subscribers
+	tracking   sync.Mutex        // serializes updates of delivery states
+	queue      chan *webhookDelivery
+	client     *http.Client
+	mqtt       *mqttPublisher // publishes events of baskets to MQTT broker if configured
+}
+
+func newWebhookDispatcher() *webhookDispatcher {
+	return &webhookDispatcher{
+		global: []WebhookConfig{},
+		queue:  make(chan *webhookDelivery, webhookQueueSize),
+		client: newOutboundClient(webhookTimeout)}
+}
+
+// Start launches background routines that deliver queued events
+func (d *webhookDispatcher) Start() {
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for delivery := range d.queue {
+				d.deliver(delivery)
+			}
+		}()
+	}
+}
+
+// Resume schedules pending deliveries to subscribers of all existing baskets, e.g. after restart of the service
+func (d *webhookDispatcher) Resume(db BasketsDatabase) {
+	for skip, hasMore := 0, true; hasMore; {
+		page := db.GetNames(100, skip)
+		for _, name := range page.Names {
+			if basket := db.Get(name); basket != nil {
+				for _, state := range basket.GetWebhookDeliveries() {
+					if state.Status == DeliveryPending {
+						d.resume(basket, state)
+					}
+				}
+			}
+		}
+		skip += len(page.Names)
+		hasMore = page.HasMore && len(page.Names) > 0
+	}
+}
+
+func (d *webhookDispatcher) resume(basket Basket, state WebhookDelivery) {
+	delivery := &webhookDelivery{basket: basket, state: state}
+	config, found := d.subscription(basket, state.URL)
+	if !found {
+		delivery.state.Status = DeliveryFailed
+		delivery.state.NextAttempt = 0
+		delivery.state.LastError = "subscription is removed"
+		d.track(delivery)
+		return
+	}
+
+	delivery.config = config
+	d.schedule(delivery, time.Duration(state.NextAttempt-time.Now().UnixNano()/toMs)*time.Millisecond)
+}
+
+// subscription finds subscription to URL of the basket or global subscription if basket is nil, secret of
+// found subscription is used to sign delivery that is resumed or redriven
+func (d *webhookDispatcher) subscription(basket Basket, url string) (WebhookConfig, bool) {
+	var subscriptions []WebhookConfig
+	if basket != nil {
+		subscriptions = basket.GetWebhooks()
+	} else {
+		subscriptions = d.GetGlobal()
+	}
+
+	for _, config := range subscriptions {
+		if config.URL == url {
+			return config, true
+		}
+	}
+	return WebhookConfig{}, false
+}
+
+// GetGlobal returns subscriptions to events of all baskets
+func (d *webhookDispatcher) GetGlobal() []WebhookConfig {
+	d.RLock()
+	defer d.RUnlock()
+
+	return d.global
+}
+
+// SetGlobal replaces subscriptions to events of all baskets, global subscriptions are kept in memory only
+func (d *webhookDispatcher) SetGlobal(subscriptions []WebhookConfig) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.global = subscriptions
+}
+
+// GetDeliveries returns deliveries to subscribers of the basket or to global subscribers if basket is nil,
+// the latest deliveries go first, empty status matches deliveries of any status
+func (d *webhookDispatcher) GetDeliveries(basket Basket, status string) []WebhookDelivery {
+	d.tracking.Lock()
+	defer d.tracking.Unlock()
+
+	all := d.deliveries
+	if basket != nil {
+		all = basket.GetWebhookDeliveries()
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if len(status) == 0 || all[i].Status == status {
+			deliveries = append(deliveries, all[i])
+		}
+	}
+	return deliveries
+}
+
+// Redrive schedules failed deliveries to subscribers of the basket or to global subscribers if basket is nil
+// for another round of delivery attempts; deliveries of listed IDs are redriven unless they are pending,
+// all failed deliveries are redriven if no ID is listed. Redriven deliveries are returned.
+func (d *webhookDispatcher) Redrive(basket Basket, ids []string) []WebhookDelivery {
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	d.tracking.Lock()
+	all := d.deliveries
+	if basket != nil {
+		all = basket.GetWebhookDeliveries()
+	}
+
+	updated := make([]WebhookDelivery, len(all))
+	redriven := make([]*webhookDelivery, 0)
+	for i, state := range all {
+		updated[i] = state
+		if state.Status == DeliveryPending || (len(ids) > 0 && !selected[state.ID]) ||
+			(len(ids) == 0 && state.Status != DeliveryFailed) {
+			continue
+		}
+
+		config, found := d.subscription(basket, state.URL)
+		if !found {
+			continue
+		}
+
+		state.Status = DeliveryPending
+		state.Attempts = 0
+		state.Redrives++
+		state.NextAttempt = 0
+		state.LastError = ""
+		updated[i] = state
+		redriven = append(redriven, &webhookDelivery{config: config, basket: basket, state: state})
+	}
+
+	if basket != nil {
+		basket.SetWebhookDeliveries(updated)
+	} else {
+		d.deliveries = updated
+	}
+	d.tracking.Unlock()
+
+	states := make([]WebhookDelivery, len(redriven))
+	for i, delivery := range redriven {
+		states[i] = delivery.state
+		d.enqueue(delivery)
+	}
+	return states
+}
+
+// Publish queues event for delivery to subscribers of the basket and to global subscribers,
+// basket may be nil if only global subscribers should be notified
+func (d *webhookDispatcher) Publish(basket Basket, event WebhookEvent) {
+	var payload []byte
+	publish := func(owner Basket, subscriptions []WebhookConfig) {
+		for _, config := range subscriptions {
+			if !config.Subscribes(event.Event) {
+				continue
+			}
+
+			if payload == nil {
+				if payload = encodeEvent(&event); payload == nil {
+					return
+				}
+			}
+
+			id, _ := GenerateToken()
+			delivery := &webhookDelivery{config: config, basket: owner, state: WebhookDelivery{
+				ID: id, EventID: event.ID, Event: event.Event, URL: config.URL, Status: DeliveryPending,
+				Date: time.Now().UnixNano() / toMs, Payload: payload}}
+			d.track(delivery)
+			d.enqueue(delivery)
+		}
+	}
+
+	if basket != nil {
+		publish(basket, basket.GetWebhooks())
+	}
+	publish(nil, d.GetGlobal())
+
+	if d.mqtt != nil && len(event.Basket) > 0 {
+		if payload == nil {
+			if payload = encodeEvent(&event); payload == nil {
+				return
+			}
+		}
+		d.mqtt.Publish(event.Basket, event.Event, payload)
+	}
+}
+
+// encodeEvent assigns ID to event and encodes it as JSON, nil is returned if event cannot be encoded
+func encodeEvent(event *WebhookEvent) []byte {
+	event.ID, _ = GenerateToken()
+	if event.Date == 0 {
+		event.Date = time.Now().UnixNano() / toMs
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[error] failed to encode event %s of basket: %s - %s", event.Event, event.Basket, err)
+		return nil
+	}
+	return payload
+}
+
+func (d *webhookDispatcher) enqueue(delivery *webhookDelivery) {
+	select {
+	case d.queue <- delivery:
+	default:
+		log.Printf("[warn] webhook queue is full, dropping event %s for: %s", delivery.state.Event, delivery.config.URL)
+		delivery.state.Status = DeliveryFailed
+		delivery.state.NextAttempt = 0
+		delivery.state.LastError = "webhook queue is full"
+		d.track(delivery)
+	}
+}
+
+// schedule queues delivery after delay
+func (d *webhookDispatcher) schedule(delivery *webhookDelivery, delay time.Duration) {
+	if delay <= 0 {
+		d.enqueue(delivery)
+	} else {
+		time.AfterFunc(delay, func() { d.enqueue(delivery) })
+	}
+}
+
+// track stores state of the delivery, only the latest deliveries are kept
+func (d *webhookDispatcher) track(delivery *webhookDelivery) {
+	d.tracking.Lock()
+	defer d.tracking.Unlock()
+
+	all := d.deliveries
+	if delivery.basket != nil {
+		all = delivery.basket.GetWebhookDeliveries()
+	}
+
+	// stored deliveries are never modified in place, they may be shared with readers
+	updated := make([]WebhookDelivery, 0, len(all)+1)
+	found := false
+	for _, state := range all {
+		if state.ID == delivery.state.ID {
+			state = delivery.state
+			found = true
+		}
+		updated = append(updated, state)
+	}
+	if !found {
+		updated = append(updated, delivery.state)
+	}
+	if len(updated) > maxWebhookDeliveries {
+		updated = updated[len(updated)-maxWebhookDeliveries:]
+	}
+
+	if delivery.basket != nil {
+		delivery.basket.SetWebhookDeliveries(updated)
+	} else {
+		d.deliveries = updated
+	}
+}
+
+// deliver sends event to subscriber, failed delivery is retried later with exponential backoff
+func (d *webhookDispatcher) deliver(delivery *webhookDelivery) {
+	state := &delivery.state
+	state.Attempts++
+	state.LastAttempt = time.Now().UnixNano() / toMs
+	state.NextAttempt = 0
+
+	status, retry, err := d.send(delivery)
+	state.LastStatus = status
+	if err == nil {
+		state.Status = DeliveryDelivered
+		state.LastError = ""
+		d.track(delivery)
+		return
+	}
+
+	state.LastError = err.Error()
+	if !retry || state.Attempts >= webhookMaxAttempts {
+		log.Printf("[warn] failed to deliver event %s to: %s after %d attempt(s) - %s",
+			state.Event, delivery.config.URL, state.Attempts, err)
+		state.Status = DeliveryFailed
+		d.track(delivery)
+		return
+	}
+
+	delay := webhookRetryDelay << uint(state.Attempts-1)
+	state.NextAttempt = state.LastAttempt + int64(delay/time.Millisecond)
+	d.track(delivery)
+	d.schedule(delivery, delay)
+}
+
+// send posts event to subscriber and reports response status and whether failed delivery can be retried
+func (d *webhookDispatcher) send(delivery *webhookDelivery) (int, bool, error) {
+	if err := checkOutboundURL(delivery.config.URL); err != nil {
+		return 0, false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, delivery.config.URL, bytes.NewReader(delivery.state.Payload))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", serviceName)
+	req.Header.Set(DoNotForwardHeader, "1")
+	req.Header.Set(WebhookEventHeader, delivery.state.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.state.EventID)
+	req.Header.Set(WebhookAttemptHeader, strconv.Itoa(delivery.state.Attempts))
+	if len(delivery.config.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.config.Secret, delivery.state.Payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	// client errors are permanent, unless subscriber asks to come later
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retry, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+}
+
+// SignWebhookPayload calculates signature of webhook payload
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribes checks if subscription includes the event
+func (config WebhookConfig) Subscribes(event string) bool {
+	if len(config.Events) == 0 {
+		return true
+	}
+
+	for _, e := range config.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// maskWebhookSecrets returns copy of subscriptions with masked secrets
+func maskWebhookSecrets(subscriptions []WebhookConfig) []WebhookConfig {
+	masked := make([]WebhookConfig, len(subscriptions))
+	for i, config := range subscriptions {
+		if len(config.Secret) > 0 {
+			config.Secret = secretMask
+		}
+		masked[i] = config
+	}
+	return masked
+}
+
+// keepWebhookSecrets restores secrets of updated subscriptions that are submitted with masked secret,
+// the secret is taken from existing subscription to the same URL
+func keepWebhookSecrets(subscriptions []WebhookConfig, existing []WebhookConfig) {
+	for i, config := range subscriptions {
+		if config.Secret != secretMask {
+			continue
+		}
+
+		subscriptions[i].Secret = ""
+		for _, e := range existing {
+			if e.URL == config.URL {
+				subscriptions[i].Secret = e.Secret
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookTestDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookTestServer starts HTTP server that records deliveries and responds with given statuses in turn,
+// the last status is repeated
+func newWebhookTestServer(statuses ...int) (*httptest.Server, chan *webhookTestDelivery) {
+	deliveries := make(chan *webhookTestDelivery, 10)
+	attempt := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		deliveries <- &webhookTestDelivery{r.Header, body}
+
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status = statuses[attempt]
+			if attempt < len(statuses)-1 {
+				attempt++
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	return server, deliveries
+}
+
+func receiveWebhook(t *testing.T, deliveries chan *webhookTestDelivery) *webhookTestDelivery {
+	select {
+	case delivery := <-deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "webhook delivery is expected")
+		return nil
+	}
+}
+
+func TestWebhookConfig_Subscribes(t *testing.T) {
+	all := WebhookConfig{URL: "http://localhost/all"}
+	assert.True(t, all.Subscribes(EventRequestReceived))
+	assert.True(t, all.Subscribes(EventForwardFailed))
+
+	some := WebhookConfig{URL: "http://localhost/some", Events: []string{EventBasketCreated, EventForwardFailed}}
+	assert.True(t, some.Subscribes(EventForwardFailed))
+	assert.False(t, some.Subscribes(EventRequestReceived))
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		SignWebhookPayload("key", []byte("The quick brown fox jumps over the lazy dog")), "wrong signature")
+}
+
+func TestWebhookSecrets(t *testing.T) {
+	existing := []WebhookConfig{{URL: "http://localhost/a", Secret: "abc"}, {URL: "http://localhost/b"}}
+	masked := maskWebhookSecrets(existing)
+	assert.Equal(t, secretMask, masked[0].Secret, "secret is expected to be masked")
+	assert.Empty(t, masked[1].Secret, "empty secret is not expected to be masked")
+	assert.Equal(t, "abc", existing[0].Secret, "original secret is expected to be preserved")
+
+	updated := []WebhookConfig{
+		{URL: "http://localhost/a", Secret: secretMask},
+		{URL: "http://localhost/b", Secret: "xyz"},
+		{URL: "http://localhost/c", Secret: secretMask}}
+	keepWebhookSecrets(updated, existing)
+	assert.Equal(t, "abc", updated[0].Secret, "masked secret is expected to be kept")
+	assert.Equal(t, "xyz", updated[1].Secret, "new secret is expected")
+	assert.Empty(t, updated[2].Secret, "unknown masked secret is expected to be dropped")
+}
+
+func TestWebhookDispatcher_Publish(t *testing.T) {
+	name := "webhooks01"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	server, deliveries := newWebhookTestServer()
+	defer server.Close()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.SetWebhooks([]WebhookConfig{
+		{URL: server.URL + "/basket", Events: []string{EventRequestReceived}, Secret: "s3cr3t"},
+		{URL: server.URL + "/failures", Events: []string{EventForwardFailed}}})
+
+	dispatcher := newWebhookDispatcher()
+	dispatcher.Start()
+	dispatcher.SetGlobal([]WebhookConfig{{URL: server.URL + "/global"}})
+
+	request := basket.Add(createTestPOSTRequest("http://localhost/"+name, "hello", "text/plain"))
+	dispatcher.Publish(basket, WebhookEvent{Event: EventRequestReceived, Basket: name, Request: request})
+
+	// basket and global subscribers are notified, delivery order is not guaranteed
+	ids := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		if delivery := receiveWebhook(t, deliveries); delivery != nil {
+			assert.Equal(t, EventRequestReceived, delivery.header.Get(WebhookEventHeader), "wrong event header")
+			assert.Equal(t, "1", delivery.header.Get(DoNotForwardHeader), "delivery is not expected to be forwarded")
+			assert.Equal(t, "application/json", delivery.header.Get("Content-Type"), "wrong content type")
+			ids[delivery.header.Get(WebhookDeliveryHeader)] = true
+
+			event := new(WebhookEvent)
+			if assert.NoError(t, json.Unmarshal(delivery.body, event)) {
+				assert.Equal(t, name, event.Basket, "wrong basket name")
+				assert.NotZero(t, event.Date, "event date is expected")
+				if assert.NotNil(t, event.Request, "request is expected") {
+					assert.Equal(t, "hello", event.Request.Body, "wrong request body")
+				}
+			}
+
+			if signature := delivery.header.Get(WebhookSignatureHeader); len(signature) > 0 {
+				assert.Equal(t, SignWebhookPayload("s3cr3t", delivery.body), signature, "wrong signature")
+			}
+		}
+	}
+	assert.Len(t, ids, 1, "the same delivery ID is expected for all subscribers")
+
+	// global subscribers only
+	dispatcher.Publish(nil, WebhookEvent{Event: EventBasketCreated, Basket: "webhooks02"})
+	if delivery := receiveWebhook(t, deliveries); delivery != nil {
+		assert.Equal(t, EventBasketCreated, delivery.header.Get(WebhookEventHeader), "wrong event header")
+		assert.Empty(t, delivery.header.Get(WebhookSignatureHeader), "signature is not expected without secret")
+	}
+
+	select {
+	case <-deliveries:
+		assert.Fail(t, "no more deliveries are expected")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookDispatcher_Retry(t *testing.T) {
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = 10 * time.Millisecond
+
+	dispatcher := newWebhookDispatcher()
+	dispatcher.Start()
+
+	// failed deliveries are retried until accepted
+	server, deliveries := newWebhookTestServer(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent)
+	defer server.Close()
+
+	dispatcher.SetGlobal([]WebhookConfig{{URL: server.URL}})
+	dispatcher.Publish(nil, WebhookEvent{Event: EventBasketCreated, Basket: "webhooks03"})
+	ids := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		if delivery := receiveWebhook(t, deliveries); delivery != nil {
+			ids[delivery.header.Get(WebhookDeliveryHeader)] = true
+		}
+	}
+	assert.Len(t, ids, 1, "the same delivery is expected to be retried")
+
+	// client errors are not retried
+	rejecting, rejected := newWebhookTestServer(http.StatusBadRequest, http.StatusOK)
+	defer rejecting.Close()
+
+	dispatcher.SetGlobal([]WebhookConfig{{URL: rejecting.URL}})
+	dispatcher.Publish(nil, WebhookEvent{Event: EventBasketCreated, Basket: "webhooks04"})
+	receiveWebhook(t, rejected)
+
+	select {
+	case <-rejected:
+		assert.Fail(t, "rejected delivery is not expected to be retried")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Empty(t, deliveries, "no more deliveries are expected")
+}
+
+// waitWebhookDelivery waits until delivery gets expected status and returns its state
+func waitWebhookDelivery(t *testing.T, d *webhookDispatcher, basket Basket, status string) *WebhookDelivery {
+	for i := 0; i < 500; i++ {
+		if deliveries := d.GetDeliveries(basket, status); len(deliveries) > 0 {
+			return &deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Fail(t, "delivery is expected to be "+status)
+	return nil
+}
+
+func TestWebhookDispatcher_Deliveries(t *testing.T) {
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = 10 * time.Millisecond
+
+	name := "webhooks05"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	failures := make([]int, webhookMaxAttempts)
+	for i := range failures {
+		failures[i] = http.StatusServiceUnavailable
+	}
+	server, deliveries := newWebhookTestServer(append(failures, http.StatusNoContent)...)
+	defer server.Close()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.SetWebhooks([]WebhookConfig{{URL: server.URL}})
+
+	dispatcher := newWebhookDispatcher()
+	dispatcher.Start()
+	dispatcher.Publish(basket, WebhookEvent{Event: EventRequestReceived, Basket: name})
+
+	// delivery fails after all attempts
+	for i := 1; i <= webhookMaxAttempts; i++ {
+		if delivery := receiveWebhook(t, deliveries); delivery != nil {
+			assert.Equal(t, strconv.Itoa(i), delivery.header.Get(WebhookAttemptHeader), "wrong attempt header")
+		}
+	}
+	failed := waitWebhookDelivery(t, dispatcher, basket, DeliveryFailed)
+	if failed == nil {
+		return
+	}
+	assert.Equal(t, webhookMaxAttempts, failed.Attempts, "wrong number of attempts")
+	assert.Equal(t, http.StatusServiceUnavailable, failed.LastStatus, "wrong status of the last attempt")
+	assert.Equal(t, "unexpected response status: 503", failed.LastError, "wrong error of the last attempt")
+	assert.Zero(t, failed.NextAttempt, "no more attempts are expected")
+	assert.Equal(t, server.URL, failed.URL, "wrong subscriber URL")
+	assert.NotEmpty(t, failed.Payload, "event payload is expected")
+	assert.Empty(t, dispatcher.GetDeliveries(nil, ""), "no deliveries to global subscribers are expected")
+
+	// redriven delivery starts over
+	assert.Empty(t, dispatcher.Redrive(basket, []string{"unknown"}), "unknown delivery is not expected to be redriven")
+	redriven := dispatcher.Redrive(basket, nil)
+	if assert.Len(t, redriven, 1, "failed delivery is expected to be redriven") {
+		assert.Equal(t, failed.ID, redriven[0].ID, "wrong delivery is redriven")
+		assert.Equal(t, DeliveryPending, redriven[0].Status, "redriven delivery is expected to be pending")
+	}
+	if delivery := receiveWebhook(t, deliveries); delivery != nil {
+		assert.Equal(t, "1", delivery.header.Get(WebhookAttemptHeader), "wrong attempt header")
+		assert.Equal(t, failed.EventID, delivery.header.Get(WebhookDeliveryHeader), "the same event ID is expected")
+	}
+	if delivered := waitWebhookDelivery(t, dispatcher, basket, DeliveryDelivered); delivered != nil {
+		assert.Equal(t, 1, delivered.Attempts, "wrong number of attempts")
+		assert.Equal(t, 1, delivered.Redrives, "wrong number of redrives")
+		assert.Equal(t, http.StatusNoContent, delivered.LastStatus, "wrong status of the last attempt")
+		assert.Empty(t, delivered.LastError, "no error is expected")
+	}
+
+	// delivered delivery is redriven on demand only
+	assert.Empty(t, dispatcher.Redrive(basket, nil), "only failed deliveries are expected to be redriven")
+	assert.Len(t, dispatcher.Redrive(basket, []string{failed.ID}), 1, "delivered delivery is expected to be redriven")
+	receiveWebhook(t, deliveries)
+}
+
+func TestWebhookDispatcher_Resume(t *testing.T) {
+	name := "webhooks06"
+	db := NewMemoryDatabase()
+	defer db.Release()
+
+	server, deliveries := newWebhookTestServer()
+	defer server.Close()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	basket.SetWebhooks([]WebhookConfig{{URL: server.URL, Secret: "s3cr3t"}})
+	basket.SetWebhookDeliveries([]WebhookDelivery{
+		{ID: "1", EventID: "e1", Event: EventRequestReceived, URL: server.URL, Status: DeliveryPending, Attempts: 2,
+			NextAttempt: time.Now().UnixNano() / toMs, Payload: json.RawMessage(`{"id":"e1"}`)},
+		{ID: "2", EventID: "e2", Event: EventRequestReceived, URL: server.URL + "/removed", Status: DeliveryPending},
+		{ID: "3", EventID: "e3", Event: EventRequestReceived, URL: server.URL, Status: DeliveryDelivered}})
+
+	dispatcher := newWebhookDispatcher()
+	dispatcher.Start()
+	dispatcher.Resume(db)
+
+	// pending delivery is resumed with secret of subscription
+	if delivery := receiveWebhook(t, deliveries); delivery != nil {
+		assert.Equal(t, "e1", delivery.header.Get(WebhookDeliveryHeader), "wrong event ID")
+		assert.Equal(t, "3", delivery.header.Get(WebhookAttemptHeader), "wrong attempt header")
+		assert.Equal(t, SignWebhookPayload("s3cr3t", delivery.body), delivery.header.Get(WebhookSignatureHeader))
+	}
+	for i := 0; i < 500 && len(dispatcher.GetDeliveries(basket, DeliveryDelivered)) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, dispatcher.GetDeliveries(basket, DeliveryDelivered), 2, "resumed delivery is expected to be delivered")
+
+	// delivery to removed subscription fails
+	if failed := dispatcher.GetDeliveries(basket, DeliveryFailed); assert.Len(t, failed, 1) {
+		assert.Equal(t, "2", failed[0].ID, "wrong failed delivery")
+		assert.Equal(t, "subscription is removed", failed[0].LastError, "wrong error")
+	}
+	assert.Empty(t, dispatcher.Redrive(basket, nil), "delivery to removed subscription is not expected to be redriven")
+	assert.Empty(t, deliveries, "no more deliveries are expected")
+}
+
+func TestWebhookDispatcher_Track(t *testing.T) {
+	dispatcher := newWebhookDispatcher()
+	for i := 0; i < maxWebhookDeliveries+5; i++ {
+		dispatcher.track(&webhookDelivery{state: WebhookDelivery{ID: strconv.Itoa(i), Status: DeliveryPending}})
+	}
+	dispatcher.track(&webhookDelivery{state: WebhookDelivery{ID: "10", Status: DeliveryDelivered}})
+
+	deliveries := dispatcher.GetDeliveries(nil, "")
+	if assert.Len(t, deliveries, maxWebhookDeliveries, "only the latest deliveries are expected") {
+		assert.Equal(t, strconv.Itoa(maxWebhookDeliveries+4), deliveries[0].ID, "the latest delivery is expected first")
+		assert.Equal(t, "5", deliveries[maxWebhookDeliveries-1].ID, "wrong the oldest delivery")
+	}
+	if delivered := dispatcher.GetDeliveries(nil, DeliveryDelivered); assert.Len(t, delivered, 1) {
+		assert.Equal(t, "10", delivered[0].ID, "delivery is expected to be updated in place")
+	}
+}
+
+func TestWebhookDispatcher_Denied(t *testing.T) {
+	server, deliveries := newWebhookTestServer()
+	defer server.Close()
+	defer denyLoopbackTargets()()
+
+	assert.Error(t, validateWebhooks([]WebhookConfig{{URL: server.URL + "/basket"}}),
+		"subscription to denied address is expected to be invalid")
+
+	dispatcher := newWebhookDispatcher()
+	_, retry, err := dispatcher.send(&webhookDelivery{config: WebhookConfig{URL: server.URL + "/global"},
+		state: WebhookDelivery{Event: EventBasketCreated, Payload: []byte("{}")}})
+	if assert.Error(t, err, "delivery to denied address is not expected") {
+		assert.Contains(t, err.Error(), "is not allowed", "wrong error")
+		assert.False(t, retry, "delivery to denied address is not expected to be retried")
+	}
+
+	select {
+	case <-deliveries:
+		assert.Fail(t, "denied address is not expected to be reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+}